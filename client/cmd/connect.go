@@ -45,12 +45,21 @@ type ConnectFlags struct {
 var connectFlags = ConnectFlags{}
 var inputEnvVars []string
 
+// breakGlassFlags are shared by connect and exec to skip the review of an
+// access request rule during an incident.
+var breakGlassFlags struct {
+	enabled       bool
+	justification string
+	incident      string
+}
+
 var connectExampleDesc = `hoop connect bash
 hoop connect bash -e MYENV=value -- --posix
 hoop connect postgres-srv --port 5432
 hoop connect postgres-srv -d 5m
 hoop connect postgres-srv --persistent-credential
 hoop connect postgres-srv --persistent-credential -d 30m
+hoop connect postgres-srv --break-glass --justification 'primary is down' --incident INC-1234
 `
 
 var connectLongDesc = `Connect to a remote resource.
@@ -85,7 +94,10 @@ var (
 			if dur.Seconds() < 60 {
 				return fmt.Errorf("the minimum duration is 60 seconds (60s)")
 			}
-			return nil
+			if breakGlassFlags.enabled && connectFlags.persistentCredential {
+				return fmt.Errorf("--break-glass is not supported with --persistent-credential")
+			}
+			return validateBreakGlassFlags()
 		},
 		SilenceUsage: false,
 		Run: func(cmd *cobra.Command, args []string) {
@@ -107,6 +119,7 @@ func init() {
 	connectCmd.Flags().StringVarP(&outputFlag, "output", "o", "", "Output format. One of: (json)")
 	connectCmd.Flags().BoolVar(&connectFlags.persistentCredential, "persistent-credential", false,
 		"Print persistent credentials for the resource and exit, instead of opening a local tunnel. Supported subtypes: postgres, ssh, kubernetes.")
+	addBreakGlassFlags(connectCmd)
 	rootCmd.AddCommand(connectCmd)
 }
 
@@ -161,6 +174,7 @@ func runConnect(args []string, clientEnvVars map[string]string, durationFlagChan
 		if durationFlagChanged {
 			spec[pb.SpecJitTimeout] = []byte(connectFlags.duration)
 		}
+		setBreakGlassSpec(spec)
 		if err := c.client.Send(&pb.Packet{
 			Type: pbagent.SessionOpen,
 			Spec: spec,
@@ -870,6 +884,34 @@ func newClientArgsSpec(clientArgs []string, clientEnvVars map[string]string) map
 	return spec
 }
 
+func addBreakGlassFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&breakGlassFlags.enabled, "break-glass", false,
+		"Get access right away during an incident, when allowed by the access request rule. Reviewers acknowledge it afterwards")
+	cmd.Flags().StringVar(&breakGlassFlags.justification, "justification", "", "Why the access can't wait for a review, required with --break-glass")
+	cmd.Flags().StringVar(&breakGlassFlags.incident, "incident", "", "The reference of the incident, required with --break-glass")
+}
+
+func validateBreakGlassFlags() error {
+	if !breakGlassFlags.enabled {
+		if breakGlassFlags.justification != "" || breakGlassFlags.incident != "" {
+			return fmt.Errorf("--justification and --incident require the --break-glass flag")
+		}
+		return nil
+	}
+	if strings.TrimSpace(breakGlassFlags.justification) == "" || strings.TrimSpace(breakGlassFlags.incident) == "" {
+		return fmt.Errorf("--break-glass requires the --justification and --incident flags")
+	}
+	return nil
+}
+
+func setBreakGlassSpec(spec map[string][]byte) {
+	if !breakGlassFlags.enabled {
+		return
+	}
+	spec[pb.SpecBreakGlassJustification] = []byte(breakGlassFlags.justification)
+	spec[pb.SpecBreakGlassIncident] = []byte(breakGlassFlags.incident)
+}
+
 func parseClientEnvVars() (map[string]string, error) {
 	envVar := map[string]string{}
 	var invalidEnvs []string
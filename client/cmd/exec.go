@@ -38,6 +38,7 @@ var execExampleDesc = `hoop exec bash -i 'env'
 hoop exec bash -e MYENV=val --input 'env' -- --verbose
 hoop exec bash <<< 'env'
 hoop exec --session <session_id> --output json
+hoop exec pgdemo --break-glass --justification 'fix stuck jobs' --incident INC-1234 -i 'select 1'
 `
 
 // execCmd represents the exec command
@@ -53,6 +54,10 @@ var execCmd = &cobra.Command{
 			cmd.Usage()
 			os.Exit(1)
 		}
+		if err := validateBreakGlassFlags(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		if execSessionID != "" {
@@ -77,6 +82,7 @@ func init() {
 	execCmd.Flags().StringVarP(&outputFlag, "output", "o", "", "Output format. One of: (json)")
 	execCmd.Flags().StringVar(&execSessionID, "session", "", "Execute an approved reviewed session by its ID")
 	execCmd.Flags().StringVar(&execCorrelationID, "correlation-id", "", "External workflow/task id to group related sessions")
+	addBreakGlassFlags(execCmd)
 	rootCmd.AddCommand(execCmd)
 }
 
@@ -180,6 +186,7 @@ func runExec(args []string, clientEnvVars map[string]string) {
 	c := newClientConnect(config, loader, args, pb.ClientVerbExec, execCorrelationID)
 	c.client.StartKeepAlive()
 	execSpec := newClientArgsSpec(c.clientArgs, clientEnvVars)
+	setBreakGlassSpec(execSpec)
 	isStdinInput, execInputPayload := parseExecInput(c)
 	sendOpenSessionPktFn := func() {
 		if err := c.client.Send(&pb.Packet{
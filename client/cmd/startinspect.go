@@ -22,6 +22,7 @@ import (
 var (
 	inspectConfigFlag   string
 	inspectValidateFlag bool
+	inspectTestFlag     bool
 )
 
 var startInspectCmd = &cobra.Command{
@@ -42,7 +43,8 @@ Every capability is decided by the config file, so turning on PII detection
 does not require a different binary. The file may be YAML or JSON; the
 extension picks the parser.`,
 	Example: `  hoop start inspect --config /etc/hoop-inspect/config.yaml
-  hoop start inspect --config config.yaml --validate`,
+  hoop start inspect --config config.yaml --validate
+  hoop start inspect --config config.yaml --test`,
 	// A bad config is not a usage error, and dumping the flag list under one
 	// buries the message that says which field is wrong.
 	SilenceUsage: true,
@@ -58,6 +60,14 @@ extension picks the parser.`,
 			return err
 		}
 
+		if inspectTestFlag {
+			results, err := sidecar.Test(cfg, det)
+			if err != nil {
+				return err
+			}
+			return sidecar.ReportTests(os.Stdout, results)
+		}
+
 		if inspectValidateFlag {
 			lanes, err := sidecar.Validate(cfg, det)
 			if err != nil {
@@ -95,6 +105,8 @@ func init() {
 		"Path to the inspection config file (YAML or JSON)")
 	startInspectCmd.Flags().BoolVar(&inspectValidateFlag, "validate", false,
 		"Validate the config, report what each listener resolved to, and exit")
+	startInspectCmd.Flags().BoolVar(&inspectTestFlag, "test", false,
+		"Run each listener's policy tests against its resolved stack, and exit non-zero on any failure")
 
 	startCmd.AddCommand(startInspectCmd)
 }
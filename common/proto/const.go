@@ -44,6 +44,13 @@ const (
 	SpecJitStatus           string = "jit.status"
 	SpecJitTimeout          string = "jit.timeout"
 
+	// SpecBreakGlassJustification and SpecBreakGlassIncident request
+	// break-glass access on a SessionOpen: the review of the connection's
+	// access request rule is skipped and acknowledged afterwards. The gateway
+	// only honors them for members of the rule's break-glass groups.
+	SpecBreakGlassJustification string = "breakglass.justification"
+	SpecBreakGlassIncident      string = "breakglass.incident"
+
 	// SpecMCPEventKey marks an MCPProxyConnectionWrite packet as a structured
 	// protocol event (one JSON audit record) rather than response bytes bound
 	// for the MCP client. The gateway records it and does not forward it.
@@ -52,6 +52,58 @@ func validateAccessRequestRuleBody(orgID uuid.UUID, req *openapi.AccessRequestRu
 		return fmt.Errorf("skip_review_groups can only be set when approval_required_groups is empty")
 	}
 
+	if err := validateBreakGlass(req); err != nil {
+		return err
+	}
+	return validateReviewSLA(req)
+}
+
+// validateBreakGlass checks the break-glass settings of a rule, which are
+// both optional: without groups nobody may break glass.
+func validateBreakGlass(req *openapi.AccessRequestRuleRequest) error {
+	if req.BreakGlassMaxDuration == nil {
+		return nil
+	}
+	if len(req.BreakGlassGroups) == 0 {
+		return fmt.Errorf("break_glass_groups must have at least 1 entry when break_glass_max_duration is set")
+	}
+	if *req.BreakGlassMaxDuration < 60 || *req.BreakGlassMaxDuration > 48*60*60 {
+		return fmt.Errorf("break_glass_max_duration must be between 60 and 172800 seconds")
+	}
+	return nil
+}
+
+// validateReviewSLA checks the approval SLA of a rule. Every step is
+// optional, but the ones set must run in order: remind, escalate, expire.
+func validateReviewSLA(req *openapi.AccessRequestRuleRequest) error {
+	steps := []struct {
+		name    string
+		minutes *int
+	}{
+		{"reminder_after_minutes", req.ReminderAfterMinutes},
+		{"escalation_after_minutes", req.EscalationAfterMinutes},
+		{"expiration_after_minutes", req.ExpirationAfterMinutes},
+	}
+	prev := -1
+	for i, step := range steps {
+		if step.minutes == nil {
+			continue
+		}
+		if *step.minutes < 1 {
+			return fmt.Errorf("%s must be at least 1", step.name)
+		}
+		if prev >= 0 && *step.minutes <= *steps[prev].minutes {
+			return fmt.Errorf("%s must be greater than %s", step.name, steps[prev].name)
+		}
+		prev = i
+	}
+
+	if req.EscalationAfterMinutes != nil && len(req.EscalationGroups) == 0 {
+		return fmt.Errorf("escalation_groups must have at least 1 entry when escalation_after_minutes is set")
+	}
+	if len(req.EscalationGroups) > 0 && req.EscalationAfterMinutes == nil {
+		return fmt.Errorf("escalation_after_minutes is required when escalation_groups is set")
+	}
 	return nil
 }
 
@@ -131,6 +183,12 @@ func CreateAccessRequestRule(c *gin.Context) {
 		SkipReviewGroups:       req.SkipReviewGroups,
 		AccessMaxDuration:      req.AccessMaxDuration,
 		MinApprovals:           req.MinApprovals,
+		ReminderAfterMinutes:   req.ReminderAfterMinutes,
+		EscalationAfterMinutes: req.EscalationAfterMinutes,
+		EscalationGroups:       req.EscalationGroups,
+		ExpirationAfterMinutes: req.ExpirationAfterMinutes,
+		BreakGlassGroups:       req.BreakGlassGroups,
+		BreakGlassMaxDuration:  req.BreakGlassMaxDuration,
 	}
 
 	if err := models.CreateAccessRequestRule(models.DB, accessRequestRule); err != nil {
@@ -356,6 +414,12 @@ func UpdateAccessRequestRule(c *gin.Context) {
 	existingRule.SkipReviewGroups = req.SkipReviewGroups
 	existingRule.AccessMaxDuration = req.AccessMaxDuration
 	existingRule.MinApprovals = req.MinApprovals
+	existingRule.ReminderAfterMinutes = req.ReminderAfterMinutes
+	existingRule.EscalationAfterMinutes = req.EscalationAfterMinutes
+	existingRule.EscalationGroups = req.EscalationGroups
+	existingRule.ExpirationAfterMinutes = req.ExpirationAfterMinutes
+	existingRule.BreakGlassGroups = req.BreakGlassGroups
+	existingRule.BreakGlassMaxDuration = req.BreakGlassMaxDuration
 
 	if err := models.UpdateAccessRequestRule(models.DB, existingRule); err != nil {
 		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to update access request rule")
@@ -403,6 +467,14 @@ func updateManagedAccessRequestRule(c *gin.Context, rule *models.AccessRequestRu
 		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": "skip_review_groups can only be set when approval_required_groups is empty"})
 		return
 	}
+	if err := validateBreakGlass(req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	if err := validateReviewSLA(req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
 
 	rule.ApprovalRequiredGroups = req.ApprovalRequiredGroups
 	rule.ReviewersGroups = req.ReviewersGroups
@@ -410,6 +482,12 @@ func updateManagedAccessRequestRule(c *gin.Context, rule *models.AccessRequestRu
 	rule.SkipReviewGroups = req.SkipReviewGroups
 	rule.AllGroupsMustApprove = req.AllGroupsMustApprove
 	rule.MinApprovals = req.MinApprovals
+	rule.ReminderAfterMinutes = req.ReminderAfterMinutes
+	rule.EscalationAfterMinutes = req.EscalationAfterMinutes
+	rule.EscalationGroups = req.EscalationGroups
+	rule.ExpirationAfterMinutes = req.ExpirationAfterMinutes
+	rule.BreakGlassGroups = req.BreakGlassGroups
+	rule.BreakGlassMaxDuration = req.BreakGlassMaxDuration
 
 	if err := models.UpdateAccessRequestRule(models.DB, rule); err != nil {
 		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to update access request rule")
@@ -517,6 +595,12 @@ func toAccessRequestRuleOpenApi(rule *models.AccessRequestRule) *openapi.AccessR
 		SkipReviewGroups:       rule.SkipReviewGroups,
 		AccessMaxDuration:      rule.AccessMaxDuration,
 		MinApprovals:           rule.MinApprovals,
+		ReminderAfterMinutes:   rule.ReminderAfterMinutes,
+		EscalationAfterMinutes: rule.EscalationAfterMinutes,
+		EscalationGroups:       rule.EscalationGroups,
+		ExpirationAfterMinutes: rule.ExpirationAfterMinutes,
+		BreakGlassGroups:       rule.BreakGlassGroups,
+		BreakGlassMaxDuration:  rule.BreakGlassMaxDuration,
 		CreatedAt:              rule.CreatedAt,
 		UpdatedAt:              rule.UpdatedAt,
 	}
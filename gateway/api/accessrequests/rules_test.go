@@ -0,0 +1,135 @@
+package accessrequests
+
+import (
+	"testing"
+
+	"github.com/aws/smithy-go/ptr"
+	"github.com/hoophq/hoop/gateway/api/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBreakGlass(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         *openapi.AccessRequestRuleRequest
+		expectedErr string
+	}{
+		{
+			name: "break-glass disabled",
+			req:  &openapi.AccessRequestRuleRequest{},
+		},
+		{
+			name: "break-glass groups without a max duration",
+			req:  &openapi.AccessRequestRuleRequest{BreakGlassGroups: []string{"oncall"}},
+		},
+		{
+			name: "valid break-glass",
+			req:  &openapi.AccessRequestRuleRequest{BreakGlassGroups: []string{"oncall"}, BreakGlassMaxDuration: ptr.Int(3600)},
+		},
+		{
+			name: "max duration of two days",
+			req:  &openapi.AccessRequestRuleRequest{BreakGlassGroups: []string{"oncall"}, BreakGlassMaxDuration: ptr.Int(172800)},
+		},
+		{
+			name:        "max duration without break-glass groups",
+			req:         &openapi.AccessRequestRuleRequest{BreakGlassMaxDuration: ptr.Int(3600)},
+			expectedErr: "break_glass_groups must have at least 1 entry when break_glass_max_duration is set",
+		},
+		{
+			name:        "max duration under a minute",
+			req:         &openapi.AccessRequestRuleRequest{BreakGlassGroups: []string{"oncall"}, BreakGlassMaxDuration: ptr.Int(59)},
+			expectedErr: "break_glass_max_duration must be between 60 and 172800 seconds",
+		},
+		{
+			name:        "max duration over two days",
+			req:         &openapi.AccessRequestRuleRequest{BreakGlassGroups: []string{"oncall"}, BreakGlassMaxDuration: ptr.Int(172801)},
+			expectedErr: "break_glass_max_duration must be between 60 and 172800 seconds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBreakGlass(tt.req)
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestValidateReviewSLA(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         *openapi.AccessRequestRuleRequest
+		expectedErr string
+	}{
+		{
+			name: "no SLA",
+			req:  &openapi.AccessRequestRuleRequest{},
+		},
+		{
+			name: "every step in order",
+			req: &openapi.AccessRequestRuleRequest{
+				ReminderAfterMinutes:   ptr.Int(15),
+				EscalationAfterMinutes: ptr.Int(60),
+				EscalationGroups:       []string{"sre"},
+				ExpirationAfterMinutes: ptr.Int(240),
+			},
+		},
+		{
+			name: "reminder and expiration only",
+			req:  &openapi.AccessRequestRuleRequest{ReminderAfterMinutes: ptr.Int(15), ExpirationAfterMinutes: ptr.Int(30)},
+		},
+		{
+			name:        "step under a minute",
+			req:         &openapi.AccessRequestRuleRequest{ReminderAfterMinutes: ptr.Int(0)},
+			expectedErr: "reminder_after_minutes must be at least 1",
+		},
+		{
+			name: "escalation before the reminder",
+			req: &openapi.AccessRequestRuleRequest{
+				ReminderAfterMinutes:   ptr.Int(60),
+				EscalationAfterMinutes: ptr.Int(60),
+				EscalationGroups:       []string{"sre"},
+			},
+			expectedErr: "escalation_after_minutes must be greater than reminder_after_minutes",
+		},
+		{
+			name: "expiration before the escalation",
+			req: &openapi.AccessRequestRuleRequest{
+				EscalationAfterMinutes: ptr.Int(60),
+				EscalationGroups:       []string{"sre"},
+				ExpirationAfterMinutes: ptr.Int(30),
+			},
+			expectedErr: "expiration_after_minutes must be greater than escalation_after_minutes",
+		},
+		{
+			name:        "expiration before the reminder without an escalation",
+			req:         &openapi.AccessRequestRuleRequest{ReminderAfterMinutes: ptr.Int(30), ExpirationAfterMinutes: ptr.Int(15)},
+			expectedErr: "expiration_after_minutes must be greater than reminder_after_minutes",
+		},
+		{
+			name:        "escalation without escalation groups",
+			req:         &openapi.AccessRequestRuleRequest{EscalationAfterMinutes: ptr.Int(60)},
+			expectedErr: "escalation_groups must have at least 1 entry when escalation_after_minutes is set",
+		},
+		{
+			name:        "escalation groups without an escalation",
+			req:         &openapi.AccessRequestRuleRequest{EscalationGroups: []string{"sre"}},
+			expectedErr: "escalation_after_minutes is required when escalation_groups is set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateReviewSLA(tt.req)
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package accessrequests
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hoophq/hoop/gateway/api/httputils"
+	"github.com/hoophq/hoop/gateway/api/openapi"
+	apivalidation "github.com/hoophq/hoop/gateway/api/validation"
+	"github.com/hoophq/hoop/gateway/models"
+	"github.com/hoophq/hoop/gateway/storagev2"
+	"gorm.io/gorm"
+)
+
+var accessScheduleVerbs = []string{
+	models.AccessScheduleVerbConnect,
+	models.AccessScheduleVerbExec,
+	models.AccessScheduleVerbRunbooks,
+}
+
+func validateAccessScheduleBody(req *openapi.AccessScheduleRequest) error {
+	if err := apivalidation.ValidateResourceName(req.Name); err != nil {
+		return err
+	}
+
+	if len(req.ConnectionNames) == 0 && len(req.Attributes) == 0 {
+		return fmt.Errorf("either connection_names or attributes must have at least 1 entry")
+	}
+
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		return fmt.Errorf("timezone %q is not a valid IANA time zone", req.Timezone)
+	}
+
+	for _, verb := range req.Verbs {
+		if !slices.Contains(accessScheduleVerbs, verb) {
+			return fmt.Errorf("verbs must be one of 'connect', 'exec' or 'runbooks', got %q", verb)
+		}
+	}
+
+	if len(req.RecurringWindows) == 0 && len(req.FreezePeriods) == 0 {
+		return fmt.Errorf("either recurring_windows or freeze_periods must have at least 1 entry")
+	}
+
+	for i, w := range req.RecurringWindows {
+		start, err := models.ParseScheduleClock(w.StartTime)
+		if err != nil {
+			return fmt.Errorf("recurring_windows[%d].start_time: %v", i, err)
+		}
+		end, err := models.ParseScheduleClock(w.EndTime)
+		if err != nil {
+			return fmt.Errorf("recurring_windows[%d].end_time: %v", i, err)
+		}
+		if start == end {
+			return fmt.Errorf("recurring_windows[%d] must not start and end at the same time", i)
+		}
+		for _, day := range w.Days {
+			if _, err := models.ParseScheduleDay(day); err != nil {
+				return fmt.Errorf("recurring_windows[%d].days: %v", i, err)
+			}
+		}
+	}
+
+	for i, f := range req.FreezePeriods {
+		if !f.EndsAt.After(f.StartsAt) {
+			return fmt.Errorf("freeze_periods[%d].ends_at must be after starts_at", i)
+		}
+	}
+	return nil
+}
+
+// CreateAccessSchedule
+//
+//	@Summary		Create Access Schedule
+//	@Description	Create a new access schedule for the organization. Sessions to the connections it applies to are denied outside its recurring windows and inside its freeze periods
+//	@Tags			Access Schedules
+//	@Accept			json
+//	@Produce		json
+//	@Param			request			body		openapi.AccessScheduleRequest	true	"The request body resource"
+//	@Success		201				{object}	openapi.AccessSchedule
+//	@Failure		400,422,500		{object}	openapi.HTTPError
+//	@Router			/access-requests/schedules [post]
+func CreateAccessSchedule(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	var req openapi.AccessScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return
+	}
+
+	if err := validateAccessScheduleBody(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+
+	schedule := &models.AccessSchedule{OrgID: orgID}
+	applyAccessScheduleRequest(schedule, &req)
+
+	if err := models.CreateAccessSchedule(models.DB, schedule); err != nil {
+		if err == gorm.ErrDuplicatedKey {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"message": "access schedule with the same name already exists"})
+			return
+		}
+
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to create access schedule")
+		return
+	}
+
+	if err := models.UpsertAccessScheduleAttributes(models.DB, orgID, schedule.Name, req.Attributes); err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to upsert access schedule attributes")
+		return
+	}
+	setAccessScheduleAttributes(schedule, req.Attributes)
+
+	c.JSON(http.StatusCreated, toAccessScheduleOpenApi(schedule))
+}
+
+// GetAccessSchedule
+//
+//	@Summary		Get Access Schedule
+//	@Description	Get an access schedule by name
+//	@Tags			Access Schedules
+//	@Produce		json
+//	@Param			name	path		string	true	"Access schedule name"
+//	@Success		200	{object}	openapi.AccessSchedule
+//	@Failure		400,404,500	{object}	openapi.HTTPError
+//	@Router			/access-requests/schedules/{name} [get]
+func GetAccessSchedule(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	name := c.Param("name")
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return
+	}
+
+	schedule, err := models.GetAccessScheduleByName(models.DB, name, orgID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "access schedule not found"})
+			return
+		}
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to get access schedule")
+		return
+	}
+
+	c.JSON(http.StatusOK, toAccessScheduleOpenApi(schedule))
+}
+
+// ListAccessSchedules
+//
+//	@Summary		List Access Schedules
+//	@Description	List all access schedules for the organization with pagination
+//	@Tags			Access Schedules
+//	@Produce		json
+//	@Param			page		query		int	false	"Page number (default: 1)"
+//	@Param			page_size	query		int	false	"Page size (default: 0 for all)"
+//	@Success		200	{object}	openapi.PaginatedResponse[openapi.AccessSchedule]
+//	@Failure		400,500	{object}	openapi.HTTPError
+//	@Router			/access-requests/schedules [get]
+func ListAccessSchedules(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return
+	}
+
+	queryParams := c.Request.URL.Query()
+	opts := models.AccessSchedulesFilterOption{Page: 1}
+	if pageStr := queryParams.Get("page"); pageStr != "" {
+		page, err := parseIntParam(pageStr, "page")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		opts.Page = page
+	}
+	if pageSizeStr := queryParams.Get("page_size"); pageSizeStr != "" {
+		pageSize, err := parseIntParam(pageSizeStr, "page_size")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		opts.PageSize = pageSize
+	}
+
+	schedules, total, err := models.ListAccessSchedules(models.DB, orgID, opts)
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to list access schedules")
+		return
+	}
+
+	data := []openapi.AccessSchedule{}
+	for _, schedule := range schedules {
+		data = append(data, *toAccessScheduleOpenApi(&schedule))
+	}
+
+	c.JSON(http.StatusOK, openapi.PaginatedResponse[openapi.AccessSchedule]{
+		Pages: openapi.Pagination{
+			Total: int(total),
+			Page:  opts.Page,
+			Size:  opts.PageSize,
+		},
+		Data: data,
+	})
+}
+
+// UpdateAccessSchedule
+//
+//	@Summary		Update Access Schedule
+//	@Description	Update an access schedule by name
+//	@Tags			Access Schedules
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	path		string							true	"Access schedule name"
+//	@Param			request	body		openapi.AccessScheduleRequest	true	"The request body resource"
+//	@Success		200		{object}	openapi.AccessSchedule
+//	@Failure		400,404,422,500	{object}	openapi.HTTPError
+//	@Router			/access-requests/schedules/{name} [put]
+func UpdateAccessSchedule(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	name := c.Param("name")
+
+	var req openapi.AccessScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return
+	}
+
+	schedule, err := models.GetAccessScheduleByName(models.DB, name, orgID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "access schedule not found"})
+			return
+		}
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to get access schedule")
+		return
+	}
+
+	if err := validateAccessScheduleBody(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+
+	applyAccessScheduleRequest(schedule, &req)
+	if err := models.UpdateAccessSchedule(models.DB, schedule); err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to update access schedule")
+		return
+	}
+
+	if err := models.UpsertAccessScheduleAttributes(models.DB, orgID, schedule.Name, req.Attributes); err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to upsert access schedule attributes")
+		return
+	}
+	setAccessScheduleAttributes(schedule, req.Attributes)
+
+	c.JSON(http.StatusOK, toAccessScheduleOpenApi(schedule))
+}
+
+// DeleteAccessSchedule
+//
+//	@Summary		Delete Access Schedule
+//	@Description	Delete an access schedule by name
+//	@Tags			Access Schedules
+//	@Produce		json
+//	@Param			name	path		string	true	"Access schedule name"
+//	@Success		204	"No Content"
+//	@Failure		400,404,500	{object}	openapi.HTTPError
+//	@Router			/access-requests/schedules/{name} [delete]
+func DeleteAccessSchedule(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	name := c.Param("name")
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return
+	}
+
+	if err := models.DeleteAccessScheduleByName(models.DB, name, orgID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "access schedule not found"})
+			return
+		}
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to delete access schedule")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func applyAccessScheduleRequest(schedule *models.AccessSchedule, req *openapi.AccessScheduleRequest) {
+	schedule.Name = req.Name
+	schedule.Description = req.Description
+	schedule.Timezone = req.Timezone
+	schedule.Verbs = req.Verbs
+	schedule.ConnectionNames = req.ConnectionNames
+	schedule.OverrideReviewersGroups = req.OverrideReviewersGroups
+
+	schedule.RecurringWindows = make([]models.AccessScheduleWindow, len(req.RecurringWindows))
+	for i, w := range req.RecurringWindows {
+		schedule.RecurringWindows[i] = models.AccessScheduleWindow{Days: w.Days, StartTime: w.StartTime, EndTime: w.EndTime}
+	}
+	schedule.FreezePeriods = make([]models.AccessScheduleFreeze, len(req.FreezePeriods))
+	for i, f := range req.FreezePeriods {
+		schedule.FreezePeriods[i] = models.AccessScheduleFreeze{Name: f.Name, StartsAt: f.StartsAt.UTC(), EndsAt: f.EndsAt.UTC()}
+	}
+}
+
+func setAccessScheduleAttributes(schedule *models.AccessSchedule, attributeNames []string) {
+	schedule.ScheduleAttributes = make([]models.AccessScheduleAttribute, 0, len(attributeNames))
+	for _, attr := range attributeNames {
+		schedule.ScheduleAttributes = append(schedule.ScheduleAttributes, models.AccessScheduleAttribute{
+			OrgID: schedule.OrgID, AttributeName: attr, AccessScheduleName: schedule.Name,
+		})
+	}
+}
+
+func toAccessScheduleOpenApi(schedule *models.AccessSchedule) *openapi.AccessSchedule {
+	attrs := make([]string, len(schedule.ScheduleAttributes))
+	for i, sa := range schedule.ScheduleAttributes {
+		attrs[i] = sa.AttributeName
+	}
+	windows := make([]openapi.AccessScheduleWindow, len(schedule.RecurringWindows))
+	for i, w := range schedule.RecurringWindows {
+		windows[i] = openapi.AccessScheduleWindow{Days: w.Days, StartTime: w.StartTime, EndTime: w.EndTime}
+	}
+	freezes := make([]openapi.AccessScheduleFreeze, len(schedule.FreezePeriods))
+	for i, f := range schedule.FreezePeriods {
+		freezes[i] = openapi.AccessScheduleFreeze{Name: f.Name, StartsAt: f.StartsAt, EndsAt: f.EndsAt}
+	}
+	return &openapi.AccessSchedule{
+		ID:                      schedule.ID.String(),
+		Name:                    schedule.Name,
+		Description:             schedule.Description,
+		Timezone:                schedule.Timezone,
+		Verbs:                   schedule.Verbs,
+		ConnectionNames:         schedule.ConnectionNames,
+		Attributes:              attrs,
+		RecurringWindows:        windows,
+		FreezePeriods:           freezes,
+		OverrideReviewersGroups: schedule.OverrideReviewersGroups,
+		CreatedAt:               schedule.CreatedAt,
+		UpdatedAt:               schedule.UpdatedAt,
+	}
+}
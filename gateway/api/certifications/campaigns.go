@@ -0,0 +1,311 @@
+package apicertifications
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/gateway/api/httputils"
+	"github.com/hoophq/hoop/gateway/api/openapi"
+	apivalidation "github.com/hoophq/hoop/gateway/api/validation"
+	"github.com/hoophq/hoop/gateway/idp"
+	"github.com/hoophq/hoop/gateway/models"
+	"github.com/hoophq/hoop/gateway/services"
+	"github.com/hoophq/hoop/gateway/storagev2"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultCampaignDurationDays = 14
+	maxCampaignDurationDays     = 90
+	maxCampaignRecurrenceDays   = 366
+)
+
+func validateCampaignBody(req *openapi.CertificationCampaignRequest) error {
+	if err := apivalidation.ValidateResourceName(req.Name); err != nil {
+		return err
+	}
+	if len(req.ReviewersGroups) == 0 {
+		return fmt.Errorf("reviewers_groups must have at least 1 entry")
+	}
+
+	if req.DurationDays == 0 {
+		req.DurationDays = defaultCampaignDurationDays
+	}
+	if req.DurationDays < 1 || req.DurationDays > maxCampaignDurationDays {
+		return fmt.Errorf("duration_days must be between 1 and %v", maxCampaignDurationDays)
+	}
+
+	if req.RecurrenceDays != nil {
+		// runs of the same campaign must not overlap, or a membership would be
+		// attested twice and revoked by whichever run closes first
+		if *req.RecurrenceDays < req.DurationDays || *req.RecurrenceDays > maxCampaignRecurrenceDays {
+			return fmt.Errorf("recurrence_days must be between duration_days (%v) and %v", req.DurationDays, maxCampaignRecurrenceDays)
+		}
+		if req.NextRunAt == nil {
+			return fmt.Errorf("next_run_at is required with recurrence_days")
+		}
+	}
+	return nil
+}
+
+// CreateCertificationCampaign
+//
+//	@Summary		Create Certification Campaign
+//	@Description	Create a certification campaign. Each run snapshots the memberships of the campaign's groups, with the connections and access request rules they grant, for its reviewers to attest to keep or revoke before the run's deadline
+//	@Tags			Certifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			request			body		openapi.CertificationCampaignRequest	true	"The request body resource"
+//	@Success		201				{object}	openapi.CertificationCampaign
+//	@Failure		400,422,500		{object}	openapi.HTTPError
+//	@Router			/certifications/campaigns [post]
+func CreateCertificationCampaign(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	var req openapi.CertificationCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return
+	}
+
+	if err := validateCampaignBody(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+
+	campaign := &models.CertificationCampaign{OrgID: orgID}
+	applyCampaignRequest(campaign, &req)
+	if err := models.CreateCertificationCampaign(models.DB, campaign); err != nil {
+		if err == gorm.ErrDuplicatedKey {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"message": "certification campaign with the same name already exists"})
+			return
+		}
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to create certification campaign")
+		return
+	}
+
+	c.JSON(http.StatusCreated, toCampaignOpenApi(campaign))
+}
+
+// GetCertificationCampaign
+//
+//	@Summary		Get Certification Campaign
+//	@Description	Get a certification campaign by name
+//	@Tags			Certifications
+//	@Produce		json
+//	@Param			name	path		string	true	"Certification campaign name"
+//	@Success		200	{object}	openapi.CertificationCampaign
+//	@Failure		400,404,500	{object}	openapi.HTTPError
+//	@Router			/certifications/campaigns/{name} [get]
+func GetCertificationCampaign(c *gin.Context) {
+	campaign, ok := getCampaign(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toCampaignOpenApi(campaign))
+}
+
+// ListCertificationCampaigns
+//
+//	@Summary		List Certification Campaigns
+//	@Description	List the certification campaigns of the organization
+//	@Tags			Certifications
+//	@Produce		json
+//	@Success		200	{array}		openapi.CertificationCampaign
+//	@Failure		500	{object}	openapi.HTTPError
+//	@Router			/certifications/campaigns [get]
+func ListCertificationCampaigns(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return
+	}
+
+	campaigns, err := models.ListCertificationCampaigns(models.DB, orgID)
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to list certification campaigns")
+		return
+	}
+	data := []openapi.CertificationCampaign{}
+	for _, campaign := range campaigns {
+		data = append(data, *toCampaignOpenApi(&campaign))
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// UpdateCertificationCampaign
+//
+//	@Summary		Update Certification Campaign
+//	@Description	Update a certification campaign by name. Runs already started keep the reviewers and memberships they started with
+//	@Tags			Certifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	path		string									true	"Certification campaign name"
+//	@Param			request	body		openapi.CertificationCampaignRequest	true	"The request body resource"
+//	@Success		200		{object}	openapi.CertificationCampaign
+//	@Failure		400,404,422,500	{object}	openapi.HTTPError
+//	@Router			/certifications/campaigns/{name} [put]
+func UpdateCertificationCampaign(c *gin.Context) {
+	var req openapi.CertificationCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	campaign, ok := getCampaign(c)
+	if !ok {
+		return
+	}
+
+	if err := validateCampaignBody(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+
+	applyCampaignRequest(campaign, &req)
+	if err := models.UpdateCertificationCampaign(models.DB, campaign); err != nil {
+		if err == gorm.ErrDuplicatedKey {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"message": "certification campaign with the same name already exists"})
+			return
+		}
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to update certification campaign")
+		return
+	}
+
+	c.JSON(http.StatusOK, toCampaignOpenApi(campaign))
+}
+
+// DeleteCertificationCampaign
+//
+//	@Summary		Delete Certification Campaign
+//	@Description	Delete a certification campaign by name. Its runs and their evidence are kept
+//	@Tags			Certifications
+//	@Produce		json
+//	@Param			name	path		string	true	"Certification campaign name"
+//	@Success		204	"No Content"
+//	@Failure		400,404,500	{object}	openapi.HTTPError
+//	@Router			/certifications/campaigns/{name} [delete]
+func DeleteCertificationCampaign(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return
+	}
+
+	if err := models.DeleteCertificationCampaignByName(models.DB, orgID, c.Param("name")); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "certification campaign not found"})
+			return
+		}
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to delete certification campaign")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// StartCertificationRun
+//
+//	@Summary		Start Certification Run
+//	@Description	Start a run of a certification campaign now, outside of its schedule
+//	@Tags			Certifications
+//	@Produce		json
+//	@Param			name	path		string	true	"Certification campaign name"
+//	@Success		201	{object}	openapi.CertificationRun
+//	@Failure		400,404,500	{object}	openapi.HTTPError
+//	@Router			/certifications/campaigns/{name}/runs [post]
+func StartCertificationRun(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	campaign, ok := getCampaign(c)
+	if !ok {
+		return
+	}
+
+	idpManagedGroups, err := idp.SyncsGroups()
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to load server auth config")
+		return
+	}
+	run, err := models.StartCertificationRun(models.DB, campaign, &ctx.UserEmail, time.Now().UTC(), idpManagedGroups)
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to start certification run")
+		return
+	}
+	tally, err := models.CountCertificationDecisions(models.DB, run.ID)
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to count certification items")
+		return
+	}
+	log.With("run", run.ID, "user", ctx.UserEmail).Infof("certification run of campaign %v started, items=%v",
+		campaign.Name, tally.Total())
+	go services.NotifyCertificationStarted(run, tally.Total())
+
+	c.JSON(http.StatusCreated, toRunOpenApi(run, tally))
+}
+
+// getCampaign fetches the campaign named in the path, answering the request
+// when it can't.
+func getCampaign(c *gin.Context) (*models.CertificationCampaign, bool) {
+	ctx := storagev2.ParseContext(c)
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return nil, false
+	}
+
+	campaign, err := models.GetCertificationCampaignByName(models.DB, orgID, c.Param("name"))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "certification campaign not found"})
+			return nil, false
+		}
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to get certification campaign")
+		return nil, false
+	}
+	return campaign, true
+}
+
+func applyCampaignRequest(campaign *models.CertificationCampaign, req *openapi.CertificationCampaignRequest) {
+	campaign.Name = req.Name
+	campaign.Description = req.Description
+	campaign.GroupNames = req.GroupNames
+	campaign.ReviewersGroups = req.ReviewersGroups
+	campaign.DurationDays = req.DurationDays
+	campaign.RecurrenceDays = req.RecurrenceDays
+	campaign.NextRunAt = nil
+	if req.NextRunAt != nil {
+		nextRunAt := req.NextRunAt.UTC()
+		campaign.NextRunAt = &nextRunAt
+	}
+}
+
+func toCampaignOpenApi(campaign *models.CertificationCampaign) *openapi.CertificationCampaign {
+	return &openapi.CertificationCampaign{
+		ID:              campaign.ID.String(),
+		Name:            campaign.Name,
+		Description:     campaign.Description,
+		GroupNames:      campaign.GroupNames,
+		ReviewersGroups: campaign.ReviewersGroups,
+		DurationDays:    campaign.DurationDays,
+		RecurrenceDays:  campaign.RecurrenceDays,
+		NextRunAt:       campaign.NextRunAt,
+		CreatedAt:       campaign.CreatedAt,
+		UpdatedAt:       campaign.UpdatedAt,
+	}
+}
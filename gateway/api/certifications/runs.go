@@ -0,0 +1,366 @@
+package apicertifications
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go/ptr"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/gateway/api/httputils"
+	"github.com/hoophq/hoop/gateway/api/openapi"
+	"github.com/hoophq/hoop/gateway/models"
+	"github.com/hoophq/hoop/gateway/services"
+	"github.com/hoophq/hoop/gateway/storagev2"
+	"github.com/hoophq/hoop/gateway/utils"
+)
+
+// ListCertificationRuns
+//
+//	@Summary		List Certification Runs
+//	@Description	List the runs of certification campaigns, newest first
+//	@Tags			Certifications
+//	@Produce		json
+//	@Param			campaign	query		string	false	"Filter by the campaign name"
+//	@Param			status		query		string	false	"Filter by the status of the run"	Enums(open, completed)
+//	@Success		200			{array}		openapi.CertificationRun
+//	@Failure		500			{object}	openapi.HTTPError
+//	@Router			/certifications/runs [get]
+func ListCertificationRuns(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return
+	}
+
+	runs, err := models.ListCertificationRuns(models.DB, orgID, models.CertificationRunsFilterOption{
+		CampaignName: c.Query("campaign"),
+		Status:       c.Query("status"),
+	})
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to list certification runs")
+		return
+	}
+	data := []openapi.CertificationRun{}
+	for _, run := range runs {
+		tally, err := models.CountCertificationDecisions(models.DB, run.ID)
+		if err != nil {
+			httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to count certification items")
+			return
+		}
+		data = append(data, *toRunOpenApi(&run, tally))
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// GetCertificationRun
+//
+//	@Summary		Get Certification Run
+//	@Description	Get a certification run by id
+//	@Tags			Certifications
+//	@Produce		json
+//	@Param			id	path		string	true	"The id of the run"
+//	@Success		200	{object}	openapi.CertificationRun
+//	@Failure		404,500	{object}	openapi.HTTPError
+//	@Router			/certifications/runs/{id} [get]
+func GetCertificationRun(c *gin.Context) {
+	run, tally, ok := getRun(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toRunOpenApi(run, tally))
+}
+
+// GetCertificationEvidence
+//
+//	@Summary		Export Certification Evidence
+//	@Description	Export the audit evidence of a certification run: every membership it certified, what the membership granted and who attested it, when and how. Memberships flagged idp_managed are never removed by the gateway: a revoke decision on them has no revoked_at and must be carried out in the identity provider. Use format=csv for a spreadsheet
+//	@Tags			Certifications
+//	@Produce		json,text/csv
+//	@Param			id		path		string	true	"The id of the run"
+//	@Param			format	query		string	false	"The format of the evidence"	Enums(json, csv)
+//	@Success		200		{object}	openapi.CertificationEvidence
+//	@Failure		400,404,500	{object}	openapi.HTTPError
+//	@Router			/certifications/runs/{id}/evidence [get]
+func GetCertificationEvidence(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "format must be json or csv"})
+		return
+	}
+	run, tally, ok := getRun(c)
+	if !ok {
+		return
+	}
+	items, err := models.ListCertificationItems(models.DB, run.OrgID, models.CertificationItemsFilterOption{RunID: run.ID.String()})
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to list certification items")
+		return
+	}
+
+	evidence := openapi.CertificationEvidence{
+		Run:         *toRunOpenApi(run, tally),
+		Items:       []openapi.CertificationItem{},
+		GeneratedAt: time.Now().UTC(),
+	}
+	for _, item := range items {
+		evidence.Items = append(evidence.Items, *toItemOpenApi(&item))
+	}
+
+	filename := fmt.Sprintf("certification-%s-%s.%s", run.CampaignName, run.StartedAt.Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	if format == "json" {
+		c.JSON(http.StatusOK, evidence)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(evidenceCSVHeader)
+	for _, item := range evidence.Items {
+		_ = w.Write(evidenceCSVRecord(run, &item))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.With("run", run.ID).Warnf("failed writing certification evidence, reason=%v", err)
+	}
+}
+
+var evidenceCSVHeader = []string{
+	"campaign", "run_id", "run_started_at", "run_deadline_at", "run_completed_at",
+	"user_email", "group", "connections", "access_request_rules", "idp_managed",
+	"decision", "decided_by", "decided_at", "note", "revoked_at",
+}
+
+// evidenceCSVRecord is the line of item in the csv evidence of run, in the
+// order of evidenceCSVHeader.
+func evidenceCSVRecord(run *models.CertificationRun, item *openapi.CertificationItem) []string {
+	return []string{
+		run.CampaignName,
+		run.ID.String(),
+		formatTime(&run.StartedAt),
+		formatTime(&run.DeadlineAt),
+		formatTime(run.CompletedAt),
+		item.UserEmail,
+		item.GroupName,
+		strings.Join(item.ConnectionNames, ";"),
+		strings.Join(item.AccessRequestRules, ";"),
+		strconv.FormatBool(item.IdpManaged),
+		item.Decision,
+		ptr.ToString(item.DecidedBy),
+		formatTime(item.DecidedAt),
+		ptr.ToString(item.Note),
+		formatTime(item.RevokedAt),
+	}
+}
+
+// ListCertificationItems
+//
+//	@Summary		List Certification Items
+//	@Description	List the memberships under certification. Admins and auditors see the items of every run; other users only see the items of the open runs their groups review
+//	@Tags			Certifications
+//	@Produce		json
+//	@Param			run_id		query		string	false	"Filter by the run"
+//	@Param			decision	query		string	false	"Filter by the attestation"	Enums(pending, keep, revoke, no_response)
+//	@Success		200			{array}		openapi.CertificationItem
+//	@Failure		500			{object}	openapi.HTTPError
+//	@Router			/certifications/items [get]
+func ListCertificationItems(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return
+	}
+
+	opts := models.CertificationItemsFilterOption{
+		RunID:    c.Query("run_id"),
+		Decision: c.Query("decision"),
+	}
+	if opts.RunID != "" {
+		if _, err := uuid.Parse(opts.RunID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "run_id must be a valid uuid"})
+			return
+		}
+	}
+	if !ctx.IsAdmin() && !ctx.IsAuditor() {
+		opts.ReviewerGroups = ctx.GetUserGroups()
+		if opts.ReviewerGroups == nil {
+			opts.ReviewerGroups = []string{}
+		}
+	}
+	items, err := models.ListCertificationItems(models.DB, orgID, opts)
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to list certification items")
+		return
+	}
+	data := []openapi.CertificationItem{}
+	for _, item := range items {
+		data = append(data, *toItemOpenApi(&item))
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// DecideCertificationItem
+//
+//	@Summary		Attest Certification Item
+//	@Description	Attest to keep or revoke a membership under certification. Only members of the reviewers groups of its run, or admins, may attest it, and never for their own membership. Revoking removes the user from the group at once, unless the identity provider manages the membership (idp_managed): the decision is then only attested and must be carried out in the identity provider
+//	@Tags			Certifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string								true	"The id of the item"
+//	@Param			request	body		openapi.CertificationDecisionRequest	true	"The request body resource"
+//	@Success		200		{object}	openapi.CertificationItem
+//	@Failure		400,403,404,409,500	{object}	openapi.HTTPError
+//	@Router			/certifications/items/{id}/decision [post]
+func DecideCertificationItem(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	var req openapi.CertificationDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if req.Decision != models.CertificationDecisionKeep && req.Decision != models.CertificationDecisionRevoke {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "decision must be keep or revoke"})
+		return
+	}
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return
+	}
+	itemID := c.Param("id")
+	if _, err := uuid.Parse(itemID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "certification item not found"})
+		return
+	}
+
+	item, err := models.GetCertificationItem(models.DB, orgID, itemID)
+	switch err {
+	case nil:
+	case models.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"message": "certification item not found"})
+		return
+	default:
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to get certification item")
+		return
+	}
+	run, err := models.GetCertificationRun(models.DB, orgID, item.RunID.String())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to get certification run")
+		return
+	}
+
+	if item.UserEmail == ctx.UserEmail {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "unable to attest your own membership"})
+		return
+	}
+	if !ctx.IsAdmin() && !utils.SlicesHasIntersection(run.ReviewersGroups, ctx.GetUserGroups()) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "access denied"})
+		return
+	}
+
+	item, err = models.DecideCertificationItem(models.DB, orgID, itemID, req.Decision, ctx.UserEmail, req.Note)
+	switch err {
+	case nil:
+	case models.ErrCertificationItemDecided:
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+		return
+	default:
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to attest certification item")
+		return
+	}
+	log.With("run", run.ID, "item", item.ID, "reviewer", ctx.UserEmail).
+		Infof("certification item attested, decision=%v, user=%v, group=%v", item.Decision, item.UserEmail, item.GroupName)
+	if item.Decision == models.CertificationDecisionRevoke {
+		go services.NotifyCertificationRevoked(run, item)
+	}
+
+	c.JSON(http.StatusOK, toItemOpenApi(item))
+}
+
+// getRun fetches the run in the path with its tally, answering the request
+// when it can't.
+func getRun(c *gin.Context) (*models.CertificationRun, models.CertificationTally, bool) {
+	ctx := storagev2.ParseContext(c)
+
+	orgID, err := uuid.Parse(ctx.GetOrgID())
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "invalid organization ID")
+		return nil, models.CertificationTally{}, false
+	}
+	runID := c.Param("id")
+	if _, err := uuid.Parse(runID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "certification run not found"})
+		return nil, models.CertificationTally{}, false
+	}
+
+	run, err := models.GetCertificationRun(models.DB, orgID, runID)
+	switch err {
+	case nil:
+	case models.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"message": "certification run not found"})
+		return nil, models.CertificationTally{}, false
+	default:
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to get certification run")
+		return nil, models.CertificationTally{}, false
+	}
+	tally, err := models.CountCertificationDecisions(models.DB, run.ID)
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed to count certification items")
+		return nil, models.CertificationTally{}, false
+	}
+	return run, tally, true
+}
+
+func toRunOpenApi(run *models.CertificationRun, tally models.CertificationTally) *openapi.CertificationRun {
+	return &openapi.CertificationRun{
+		ID:              run.ID.String(),
+		CampaignName:    run.CampaignName,
+		ReviewersGroups: run.ReviewersGroups,
+		Status:          run.Status,
+		StartedBy:       run.StartedBy,
+		StartedAt:       run.StartedAt,
+		DeadlineAt:      run.DeadlineAt,
+		CompletedAt:     run.CompletedAt,
+		Pending:         tally.Pending,
+		Kept:            tally.Keep,
+		Revoked:         tally.Revoke,
+		NoResponse:      tally.NoResponse,
+	}
+}
+
+func toItemOpenApi(item *models.CertificationItem) *openapi.CertificationItem {
+	return &openapi.CertificationItem{
+		ID:                 item.ID.String(),
+		RunID:              item.RunID.String(),
+		UserEmail:          item.UserEmail,
+		GroupName:          item.GroupName,
+		ConnectionNames:    item.ConnectionNames,
+		AccessRequestRules: item.AccessRequestRules,
+		IdpManaged:         item.IdpManaged,
+		Decision:           item.Decision,
+		DecidedBy:          item.DecidedBy,
+		DecidedAt:          item.DecidedAt,
+		Note:               item.Note,
+		RevokedAt:          item.RevokedAt,
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,53 @@
+package apicertifications
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hoophq/hoop/gateway/models"
+)
+
+// The gateway never removes a membership the identity provider syncs, so the
+// evidence must tell the auditor a revoke decision on it has to be carried out
+// in the identity provider.
+func TestEvidenceRecordsIdpManagedMemberships(t *testing.T) {
+	now := time.Date(2026, 4, 3, 14, 12, 0, 0, time.UTC)
+	run := &models.CertificationRun{ID: uuid.New(), CampaignName: "quarterly", StartedAt: now, DeadlineAt: now}
+	item := &models.CertificationItem{
+		ID:         uuid.New(),
+		RunID:      run.ID,
+		UserEmail:  "drew.k@acme.com",
+		GroupName:  "dba",
+		IdpManaged: true,
+		Decision:   models.CertificationDecisionRevoke,
+		DecidedAt:  &now,
+	}
+
+	apiItem := toItemOpenApi(item)
+	if !apiItem.IdpManaged {
+		t.Fatal("expected the item to be flagged as managed by the identity provider")
+	}
+	columns := evidenceColumns(t, evidenceCSVRecord(run, apiItem))
+	if columns["idp_managed"] != "true" || columns["decision"] != "revoke" || columns["revoked_at"] != "" {
+		t.Errorf("unexpected evidence record %v", columns)
+	}
+
+	item.IdpManaged = false
+	columns = evidenceColumns(t, evidenceCSVRecord(run, toItemOpenApi(item)))
+	if columns["idp_managed"] != "false" {
+		t.Errorf("expected idp_managed=false for a membership managed in the gateway, got %q", columns["idp_managed"])
+	}
+}
+
+func evidenceColumns(t *testing.T, record []string) map[string]string {
+	t.Helper()
+	if len(record) != len(evidenceCSVHeader) {
+		t.Fatalf("expected %v columns, got %v", len(evidenceCSVHeader), len(record))
+	}
+	columns := map[string]string{}
+	for i, name := range evidenceCSVHeader {
+		columns[name] = record[i]
+	}
+	return columns
+}
@@ -118,6 +118,29 @@ func CreateConnectionCredentials(c *gin.Context) {
 		return
 	}
 
+	// Break-glass issues the credentials right away, bounded by the rule's
+	// break-glass max duration instead of its access max duration.
+	breakGlass := req.BreakGlass != nil
+	if breakGlass {
+		if !requiresReview {
+			c.AbortWithStatusJSON(400, gin.H{"message": "break-glass is only available for connections that require a review"})
+			return
+		}
+		req.BreakGlass.Justification = strings.TrimSpace(req.BreakGlass.Justification)
+		req.BreakGlass.IncidentReference = strings.TrimSpace(req.BreakGlass.IncidentReference)
+		err := services.ValidateBreakGlass(accessRule, ctx.GetUserGroups(), req.BreakGlass.Justification, req.BreakGlass.IncidentReference)
+		if err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"message": err.Error()})
+			return
+		}
+		maxDuration := services.BreakGlassMaxDuration(accessRule)
+		if time.Duration(req.AccessDurationSec)*time.Second > maxDuration {
+			c.AbortWithStatusJSON(400, gin.H{"message": fmt.Sprintf(
+				"break-glass access duration cannot exceed %d seconds for this connection", int(maxDuration.Seconds()))})
+			return
+		}
+	}
+
 	// A matching rule where the user skips the approval review still bounds
 	// credential lifetime: skipping the review must not grant persistent
 	// access nor exceed the rule's max duration.
@@ -149,7 +172,7 @@ func CreateConnectionCredentials(c *gin.Context) {
 			c.AbortWithStatusJSON(400, gin.H{"message": "access duration cannot exceed 48 hours"})
 			return
 		}
-		if accessRule != nil && accessRule.AccessMaxDuration != nil &&
+		if !breakGlass && accessRule != nil && accessRule.AccessMaxDuration != nil &&
 			req.AccessDurationSec > *accessRule.AccessMaxDuration {
 			c.AbortWithStatusJSON(400, gin.H{"message": fmt.Sprintf(
 				"access duration cannot exceed %d seconds for this connection", *accessRule.AccessMaxDuration)})
@@ -205,7 +228,7 @@ func CreateConnectionCredentials(c *gin.Context) {
 	// If review/JIT is required, create review record and return 202.
 	// Review-required connections always need a bounded access window — the
 	// configure-session step in the UI is responsible for supplying it.
-	if requiresReview {
+	if requiresReview && !breakGlass {
 		reviewID, err := createConnectionCredentialsReview(ctx, conn, accessRule, sid, req.AccessDurationSec)
 		if err != nil {
 			log.Errorf("failed creating review, err=%v", err)
@@ -228,6 +251,14 @@ func CreateConnectionCredentials(c *gin.Context) {
 		return
 	}
 
+	if breakGlass {
+		if err := createConnectionCredentialsBreakGlass(ctx, conn, accessRule, sid, req); err != nil {
+			log.Errorf("failed creating break-glass review, err=%v", err)
+			c.AbortWithStatusJSON(500, gin.H{"message": "failed creating break-glass review"})
+			return
+		}
+	}
+
 	// Non-review connections: if no duration is provided, issue a persistent
 	// credential (no expiration) — mirrors the machine-identity flow.
 	var expireAt time.Time
@@ -409,6 +440,9 @@ func ResumeConnectionCredentials(c *gin.Context) {
 	case models.ReviewStatusRejected:
 		c.AbortWithStatusJSON(403, gin.H{"message": "review was rejected"})
 		return
+	case models.ReviewStatusExpired:
+		c.AbortWithStatusJSON(403, gin.H{"message": "review expired without a decision"})
+		return
 	case models.ReviewStatusApproved:
 		// continue — use session status as the source of truth below
 	default:
@@ -1128,6 +1162,26 @@ func createConnectionCredentialsReview(ctx *storagev2.Context, conn *models.Conn
 		return "", fmt.Errorf("user %s not found", ctx.UserEmail)
 	}
 
+	newRev, err := newConnectionCredentialsReview(ctx, conn, accessRule, sessionID, accessDurationSec, user.SlackID)
+	if err != nil {
+		return "", err
+	}
+
+	log.With("sid", sessionID, "id", newRev.ID, "user", ctx.UserID, "org", ctx.OrgID,
+		"type", models.ReviewTypeJit, "duration", fmt.Sprintf("%vs", newRev.AccessDurationSec)).
+		Infof("creating review for connection credentials")
+
+	// Create review with empty session input (credentials don't have input)
+	if err := models.CreateReview(newRev, ""); err != nil {
+		return "", fmt.Errorf("failed saving review: %w", err)
+	}
+
+	return newRev.ID, nil
+}
+
+// newConnectionCredentialsReview builds the pending review of a credentials
+// request, with the approval SLA of accessRule applied to it.
+func newConnectionCredentialsReview(ctx *storagev2.Context, conn *models.Connection, accessRule *models.AccessRequestRule, sessionID string, accessDurationSec int, slackID string) (*models.Review, error) {
 	// Determine reviewers: use access rule if available, otherwise use connection reviewers
 	var reviewerGroups []string
 	if accessRule != nil && len(accessRule.ReviewersGroups) > 0 {
@@ -1135,7 +1189,7 @@ func createConnectionCredentialsReview(ctx *storagev2.Context, conn *models.Conn
 	} else if len(conn.Reviewers) > 0 {
 		reviewerGroups = conn.Reviewers
 	} else {
-		return "", fmt.Errorf("no reviewers configured for connection")
+		return nil, fmt.Errorf("no reviewers configured for connection")
 	}
 
 	// Create review groups
@@ -1151,10 +1205,9 @@ func createConnectionCredentialsReview(ctx *storagev2.Context, conn *models.Conn
 
 	// Create review record - always JIT type for credentials
 	accessDuration := time.Duration(accessDurationSec) * time.Second
-	reviewID := uuid.NewString()
 
 	newRev := &models.Review{
-		ID:                    reviewID,
+		ID:                    uuid.NewString(),
 		OrgID:                 ctx.OrgID,
 		Type:                  models.ReviewTypeJit,
 		SessionID:             sessionID,
@@ -1166,7 +1219,7 @@ func createConnectionCredentialsReview(ctx *storagev2.Context, conn *models.Conn
 		OwnerID:               ctx.UserID,
 		OwnerEmail:            ctx.UserEmail,
 		OwnerName:             &ctx.UserName,
-		OwnerSlackID:          &user.SlackID,
+		OwnerSlackID:          &slackID,
 		Status:                models.ReviewStatusPending,
 		ReviewGroups:          reviewGroups,
 		AccessRequestRuleName: &accessRule.Name,
@@ -1175,17 +1228,51 @@ func createConnectionCredentialsReview(ctx *storagev2.Context, conn *models.Conn
 		CreatedAt:             time.Now().UTC(),
 		RevokedAt:             nil,
 	}
+	accessRule.ApplyReviewSLA(newRev)
+	return newRev, nil
+}
 
-	log.With("sid", sessionID, "id", newRev.ID, "user", ctx.UserID, "org", ctx.OrgID,
-		"type", models.ReviewTypeJit, "duration", fmt.Sprintf("%vs", accessDuration.Seconds())).
-		Infof("creating review for connection credentials")
+// createConnectionCredentialsBreakGlass saves the approved review of a
+// break-glass credential issuance, with the retrospective review its
+// reviewers acknowledge afterwards, and notifies them.
+func createConnectionCredentialsBreakGlass(ctx *storagev2.Context, conn *models.Connection, accessRule *models.AccessRequestRule, sessionID string, req openapi.ConnectionCredentialsRequest) error {
+	user, err := models.GetUserByEmail(ctx.UserEmail)
+	if err != nil {
+		return fmt.Errorf("failed fetching user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user %s not found", ctx.UserEmail)
+	}
 
-	// Create review with empty session input (credentials don't have input)
-	if err := models.CreateReview(newRev, ""); err != nil {
-		return "", fmt.Errorf("failed saving review: %w", err)
+	now := time.Now().UTC()
+	revokeAt := now.Add(time.Duration(req.AccessDurationSec) * time.Second)
+	newRev := &models.Review{
+		ID:                    uuid.NewString(),
+		OrgID:                 ctx.OrgID,
+		Type:                  models.ReviewTypeJit,
+		SessionID:             sessionID,
+		ConnectionName:        conn.Name,
+		ConnectionID:          sql.NullString{String: conn.ID, Valid: true},
+		AccessDurationSec:     int64(req.AccessDurationSec),
+		OwnerID:               ctx.UserID,
+		OwnerEmail:            ctx.UserEmail,
+		OwnerName:             &ctx.UserName,
+		OwnerSlackID:          &user.SlackID,
+		Status:                models.ReviewStatusApproved,
+		AccessRequestRuleName: &accessRule.Name,
+		CreatedAt:             now,
+		RevokedAt:             &revokeAt,
 	}
+	bg := services.NewBreakGlassAccess(newRev, accessRule, req.BreakGlass.Justification, req.BreakGlass.IncidentReference)
 
-	return reviewID, nil
+	log.With("sid", sessionID, "id", newRev.ID, "user", ctx.UserEmail, "org", ctx.OrgID,
+		"rule", accessRule.Name, "incident", bg.IncidentReference, "duration", fmt.Sprintf("%vs", req.AccessDurationSec)).
+		Warnf("break-glass access granted for connection credentials")
+	if err := models.CreateBreakGlassReview(newRev, bg, ""); err != nil {
+		return fmt.Errorf("failed saving break-glass review: %w", err)
+	}
+	go services.NotifyBreakGlass(newRev, bg)
+	return nil
 }
 
 // checkConnectionRequiresReview checks if a connection requires review/JIT approval.
@@ -0,0 +1,55 @@
+package apiconnections
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoop/gateway/models"
+	"github.com/hoophq/hoop/gateway/storagev2"
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+)
+
+// A credentials request waits on the same approval SLA as a session under the
+// access request rule: without the deadlines the reminder, escalation and
+// expiration jobs never pick the review up.
+func TestConnectionCredentialsReviewAppliesReviewSLA(t *testing.T) {
+	ctx := &storagev2.Context{APIContext: &types.APIContext{OrgID: "org", UserID: "user", UserEmail: "user@example.com"}}
+	conn := &models.Connection{ID: "conn-id", Name: "pg-prod"}
+	reminder, escalation, expiration := 15, 60, 240
+	accessRule := &models.AccessRequestRule{
+		Name:                   "prod-credentials",
+		ReviewersGroups:        []string{"dba"},
+		ReminderAfterMinutes:   &reminder,
+		EscalationAfterMinutes: &escalation,
+		EscalationGroups:       []string{"sre"},
+		ExpirationAfterMinutes: &expiration,
+	}
+
+	rev, err := newConnectionCredentialsReview(ctx, conn, accessRule, "sid", 3600, "U123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, deadline := range []struct {
+		name string
+		at   *time.Time
+		want time.Duration
+	}{
+		{"remind_at", rev.RemindAt, 15 * time.Minute},
+		{"escalate_at", rev.EscalateAt, time.Hour},
+		{"expire_at", rev.ExpireAt, 4 * time.Hour},
+	} {
+		if deadline.at == nil {
+			t.Errorf("expected %s to be set", deadline.name)
+			continue
+		}
+		if got := deadline.at.Sub(rev.CreatedAt); got != deadline.want {
+			t.Errorf("%s = created_at+%v, want created_at+%v", deadline.name, got, deadline.want)
+		}
+	}
+	if len(rev.EscalationGroups) != 1 || rev.EscalationGroups[0] != "sre" {
+		t.Errorf("expected the rule escalation groups, got %v", rev.EscalationGroups)
+	}
+	if rev.Type != models.ReviewTypeJit || len(rev.ReviewGroups) != 1 || rev.ReviewGroups[0].GroupName != "dba" {
+		t.Errorf("unexpected review %+v", rev)
+	}
+}
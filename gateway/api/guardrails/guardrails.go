@@ -13,6 +13,7 @@ import (
 	"github.com/hoophq/hoop/common/log"
 	"github.com/hoophq/hoop/gateway/api/httputils"
 	"github.com/hoophq/hoop/gateway/api/openapi"
+	"github.com/hoophq/hoop/gateway/guardrails"
 	"github.com/hoophq/hoop/gateway/models"
 	"github.com/hoophq/hoop/gateway/services"
 	"github.com/hoophq/hoop/gateway/storagev2"
@@ -63,7 +64,7 @@ func validateOssRulesLimitations(req *openapi.GuardRailRuleRequest) error {
 //	@Produce		json
 //	@Param			request		body		openapi.GuardRailRuleRequest	true	"The request body resource"
 //	@Success		201			{object}	openapi.GuardRailRuleResponse
-//	@Failure		400,409,500	{object}	openapi.HTTPError
+//	@Failure		400,409,422,500	{object}	openapi.HTTPError
 //	@Router			/guardrails [post]
 func Post(c *gin.Context) {
 	ctx := storagev2.ParseContext(c)
@@ -139,7 +140,7 @@ func Post(c *gin.Context) {
 //	@Produce		json
 //	@Param			request	body		openapi.GuardRailRuleRequest	true	"The request body resource"
 //	@Success		200		{object}	openapi.GuardRailRuleResponse
-//	@Failure		400,500	{object}	openapi.HTTPError
+//	@Failure		400,422,500	{object}	openapi.HTTPError
 //	@Router			/guardrails/{id} [put]
 func Put(c *gin.Context) {
 	ctx := storagev2.ParseContext(c)
@@ -337,9 +338,32 @@ func parseRequestPayload(c *gin.Context) *openapi.GuardRailRuleRequest {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return nil
 	}
+	if err := validateClassifierRules(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return nil
+	}
 	return &req
 }
 
+// validateClassifierRules refuses operation, table and unbounded_write rules
+// the gateway could never enforce, see guardrails.ValidateRules.
+func validateClassifierRules(req *openapi.GuardRailRuleRequest) error {
+	for _, direction := range []string{"input", "output"} {
+		rules := req.Input
+		if direction == "output" {
+			rules = req.Output
+		}
+		data, err := json.Marshal(rules)
+		if err != nil {
+			return fmt.Errorf("unable to encode %v rules: %v", direction, err)
+		}
+		if err := guardrails.ValidateRules(direction, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Helper to filter out empty connection IDs
 func filterEmptyIDs(ids []string) []string {
 	result := make([]string, 0, len(ids))
@@ -315,7 +315,7 @@ func makeReviewsUpdateHandler(releaseConnFn reviewapi.TransportReleaseConnection
 
 		rev, err := reviewapi.DoReview(sc, args.ID, status, reviewTimeWindow, args.ForceReview, args.RejectionReason)
 		switch err {
-		case reviewapi.ErrNotEligible, reviewapi.ErrSelfApproval, reviewapi.ErrWrongState:
+		case reviewapi.ErrNotEligible, reviewapi.ErrSelfApproval, reviewapi.ErrWrongState, reviewapi.ErrExpired:
 			return errResult(err.Error()), nil, nil
 		case reviewapi.ErrForbidden:
 			return errResult("access denied"), nil, nil
@@ -415,7 +415,8 @@ func isReviewTerminal(s models.ReviewStatusType) bool {
 	case models.ReviewStatusApproved,
 		models.ReviewStatusRejected,
 		models.ReviewStatusRevoked,
-		models.ReviewStatusExecuted:
+		models.ReviewStatusExecuted,
+		models.ReviewStatusExpired:
 		return true
 	}
 	return false
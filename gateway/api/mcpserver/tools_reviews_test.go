@@ -18,6 +18,7 @@ func TestIsReviewTerminal(t *testing.T) {
 		{models.ReviewStatusRejected, true},
 		{models.ReviewStatusRevoked, true},
 		{models.ReviewStatusExecuted, true},
+		{models.ReviewStatusExpired, true},
 	}
 	for _, tc := range tests {
 		t.Run(string(tc.status), func(t *testing.T) {
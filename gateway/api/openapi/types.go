@@ -691,6 +691,16 @@ type ExecRequest struct {
 	ClientArgs []string `json:"client_args" example:"--verbose"`
 	// External workflow/task identifier that groups sessions belonging to the same logical run
 	CorrelationID *string `json:"correlation_id,omitempty" example:"task-12345"`
+	// Skip the review of the connection's access request rule during an incident.
+	// Only allowed for members of the rule's break-glass groups
+	BreakGlass *SessionBreakGlassRequest `json:"break_glass,omitempty"`
+}
+
+type SessionBreakGlassRequest struct {
+	// Why the access can't wait for a review
+	Justification string `json:"justification" binding:"required" example:"checkout is failing, need to inspect the orders table"`
+	// The incident the access is needed for
+	IncidentReference string `json:"incident_reference" binding:"required" example:"INC-4821"`
 }
 
 type ExecResponse struct {
@@ -1100,6 +1110,7 @@ const (
 	ReviewStatusProcessing ReviewStatusType = "PROCESSING"
 	ReviewStatusExecuted   ReviewStatusType = "EXECUTED"
 	ReviewStatusUnknown    ReviewStatusType = "UNKNOWN"
+	ReviewStatusExpired    ReviewStatusType = "EXPIRED"
 
 	ReviewStatusRequestApprovedType ReviewRequestStatusType = ReviewRequestStatusType(ReviewStatusApproved)
 	ReviewStatusRequestRejectedType ReviewRequestStatusType = ReviewRequestStatusType(ReviewStatusRejected)
@@ -1139,6 +1150,7 @@ type SessionReview struct {
 	// * PROCESSING - The review is being executed
 	// * EXECUTED - The review was executed
 	// * UNKNOWN - Unable to know the status of the review
+	// * EXPIRED - Nobody reviewed the resource before its deadline
 	Status ReviewStatusType `json:"status"`
 	// The time when this review was revoked
 	RevokeAt *time.Time `json:"revoke_at" readonly:"true" example:""`
@@ -1182,6 +1194,7 @@ type Review struct {
 	// * PROCESSING - The review is being executed
 	// * EXECUTED - The review was executed
 	// * UNKNOWN - Unable to know the status of the review
+	// * EXPIRED - Nobody reviewed the resource before its deadline
 	Status ReviewStatusType `json:"status"`
 	// The time when this review was revoked
 	RevokeAt *time.Time `json:"revoke_at" readonly:"true" example:""`
@@ -1193,12 +1206,22 @@ type Review struct {
 	TimeWindow *ReviewSessionTimeWindow `json:"time_window" readonly:"true"`
 	// The name of the access request rule that triggered this review, if null means it was triggered by the review plugin
 	AccessRequestRuleName *string `json:"access_request_rule_name" readonly:"true" example:"default-access-request-rule"`
+	// The access schedules this review overrides, set when schedules denied the session. Each one requires the approval of one of its own override reviewers groups, on top of the approvals of the access request rule
+	AccessScheduleNames []string `json:"access_schedule_names,omitempty" readonly:"true" example:"prod-business-hours"`
+	// The break-glass access this review granted, set when the user skipped the review during an incident
+	BreakGlass *BreakGlassAccess `json:"break_glass,omitempty" readonly:"true"`
 	// The minimum number of approvals required for this review
 	MinApprovals *int `json:"min_approvals" readonly:"true" example:"2"`
 	// Groups that can force approve sessions for this review
 	ForceApprovalGroups []string `json:"force_approval_groups" readonly:"true" example:"sre-team"`
 	// The reason provided by the reviewer when rejecting this review
 	RejectionReason *string `json:"rejection_reason,omitempty" readonly:"true" example:"This command is not allowed in production."`
+	// The time a reminder was sent to the reviewers, when the access request rule sets one
+	ReminderSentAt *time.Time `json:"reminder_sent_at,omitempty" readonly:"true" example:"2024-07-25T16:26:35.317601Z"`
+	// The time the review escalated to the rule's escalation groups
+	EscalatedAt *time.Time `json:"escalated_at,omitempty" readonly:"true" example:"2024-07-25T17:56:35.317601Z"`
+	// The time the review expires if nobody reviews it
+	ExpireAt *time.Time `json:"expire_at,omitempty" readonly:"true" example:"2024-07-25T23:56:35.317601Z"`
 }
 
 type ReviewOwner struct {
@@ -1235,6 +1258,8 @@ type ReviewGroup struct {
 	ReviewDate *time.Time `json:"review_date" readonly:"true" example:"2024-07-25T19:36:41Z"`
 	// Indicates if this group is forcing the review
 	ForcedReview bool `json:"forced_review" readonly:"true" example:"false"`
+	// The access schedule this group approves overriding, empty for the groups of the access request rule
+	AccessScheduleName *string `json:"access_schedule_name,omitempty" readonly:"true" example:"prod-business-hours"`
 }
 
 type Plugin struct {
@@ -1836,13 +1861,23 @@ type GuardRailRuleRequest struct {
 
 	// The input rule. Each rule entry accepts an optional "message" field that
 	// is shown to the user when that specific rule is hit.
+	//
+	// Input rules also accept the types the gateway evaluates on the classified
+	// SQL statement: "operation" denies the listed verbs, "table" denies the
+	// listed tables, narrowed by an "access" of read or write, and
+	// "unbounded_write" denies an UPDATE or DELETE without a filter, optionally
+	// narrowed to "tables". Sessions of connections these can't be enforced on
+	// are refused.
 	/*
 		{
 			"name": "deny-select",
 			"description": "<optional-description>",
 			"input": {
 				"rules": [
-					{"type": "deny_words_list", "words": ["SELECT"], "pattern_regex": "", "message": "<optional-message>"}
+					{"type": "deny_words_list", "words": ["SELECT"], "pattern_regex": "", "message": "<optional-message>"},
+					{"type": "operation", "operations": ["drop", "truncate"]},
+					{"type": "table", "tables": ["public.customers"], "access": "write"},
+					{"type": "unbounded_write", "tables": ["orders"]}
 				]
 			},
 			"output": {
@@ -2785,6 +2820,9 @@ type LocalUserRequest struct {
 
 type ConnectionCredentialsRequest struct {
 	AccessDurationSec int `json:"access_duration_seconds"`
+	// Issue the credentials without waiting for the review of the connection's
+	// access request rule. Only allowed for members of the rule's break-glass groups
+	BreakGlass *SessionBreakGlassRequest `json:"break_glass,omitempty"`
 }
 
 type ConnectionCredentialsResponse struct {
@@ -3509,6 +3547,21 @@ type AccessRequestRule struct {
 	AccessMaxDuration *int `json:"access_max_duration" example:"3600"`
 	// Minimum number of approvals required
 	MinApprovals *int `json:"min_approvals" example:"2"`
+	// Minutes after a review is created before a reminder is sent to its
+	// reviewers through the Slack and webhook plugins
+	ReminderAfterMinutes *int `json:"reminder_after_minutes" example:"30"`
+	// Minutes after a review is created before escalation_groups are added as
+	// reviewers and notified
+	EscalationAfterMinutes *int `json:"escalation_after_minutes" example:"120"`
+	// Groups added as reviewers when the review escalates
+	EscalationGroups []string `json:"escalation_groups" example:"sre-managers"`
+	// Minutes after a review is created before it expires and moves to the EXPIRED status
+	ExpirationAfterMinutes *int `json:"expiration_after_minutes" example:"480"`
+	// Groups whose members may skip the review during an incident. Access is
+	// granted at once and the reviewers acknowledge it afterwards
+	BreakGlassGroups []string `json:"break_glass_groups" example:"oncall"`
+	// Maximum break-glass access duration in seconds. Defaults to one hour
+	BreakGlassMaxDuration *int `json:"break_glass_max_duration" example:"1800"`
 	// Set to "hoop" when the rule is materialized and lifecycle-managed by a
 	// protection profile; only approval settings and group lists can be
 	// changed on managed rules, and they cannot be deleted
@@ -3546,6 +3599,263 @@ type AccessRequestRuleRequest struct {
 	AccessMaxDuration *int `json:"access_max_duration,omitempty" example:"3600"`
 	// Minimum number of approvals required
 	MinApprovals *int `json:"min_approvals,omitempty" example:"2"`
+	// Minutes after a review is created before a reminder is sent to its
+	// reviewers through the Slack and webhook plugins
+	ReminderAfterMinutes *int `json:"reminder_after_minutes,omitempty" example:"30"`
+	// Minutes after a review is created before escalation_groups are added as
+	// reviewers and notified. Requires escalation_groups
+	EscalationAfterMinutes *int `json:"escalation_after_minutes,omitempty" example:"120"`
+	// Groups added as reviewers when the review escalates
+	EscalationGroups []string `json:"escalation_groups,omitempty" example:"sre-managers"`
+	// Minutes after a review is created before it expires and moves to the
+	// EXPIRED status. Must come after the reminder and the escalation
+	ExpirationAfterMinutes *int `json:"expiration_after_minutes,omitempty" example:"480"`
+	// Groups whose members may skip the review during an incident by providing
+	// a justification and an incident reference. Access is granted at once and
+	// the reviewers acknowledge it afterwards
+	BreakGlassGroups []string `json:"break_glass_groups,omitempty" example:"oncall"`
+	// Maximum break-glass access duration in seconds, between 60 and 172800.
+	// Defaults to one hour
+	BreakGlassMaxDuration *int `json:"break_glass_max_duration,omitempty" example:"1800"`
+}
+
+type BreakGlassAccess struct {
+	// The review granted by the break-glass access
+	ReviewID string `json:"review_id" format:"uuid" readonly:"true" example:"9F9745B4-C77B-4D52-84D3-E24F67E3623C"`
+	// The session granted by the break-glass access
+	SessionID string `json:"session_id" format:"uuid" readonly:"true" example:"35DB0A2F-E5CE-4AD8-A308-55C3108956E5"`
+	// The connection accessed
+	ConnectionName string `json:"connection_name" readonly:"true" example:"pgdemo"`
+	// The access request rule whose review was skipped
+	AccessRequestRuleName *string `json:"access_request_rule_name" readonly:"true" example:"prod-databases"`
+	// The email of the user who broke glass
+	OwnerEmail string `json:"owner_email" readonly:"true" example:"john.wick@bad.org"`
+	// Why the user could not wait for a review
+	Justification string `json:"justification" readonly:"true" example:"checkout is failing, need to inspect the orders table"`
+	// The incident the access was needed for
+	IncidentReference string `json:"incident_reference" readonly:"true" example:"INC-4821"`
+	// Groups that may acknowledge the retrospective review
+	ReviewersGroups []string `json:"reviewers_groups" readonly:"true" example:"sre,dba"`
+	// The time the retrospective review was acknowledged, null while it is pending
+	AcknowledgedAt *time.Time `json:"acknowledged_at" readonly:"true" example:"2024-07-25T15:56:35.317601Z"`
+	// The email of the reviewer who acknowledged it
+	AcknowledgedBy *string `json:"acknowledged_by" readonly:"true" example:"alex.morgan@acme.com"`
+	// The note left by the reviewer who acknowledged it
+	AcknowledgementNote *string `json:"acknowledgement_note" readonly:"true" example:"read-only queries, matches the incident timeline"`
+	// The time the access was granted
+	CreatedAt time.Time `json:"created_at" readonly:"true" example:"2024-07-25T15:56:35.317601Z"`
+}
+
+type BreakGlassAcknowledgeRequest struct {
+	// A note on what the reviewer checked, kept with the acknowledgement
+	Note *string `json:"note" example:"read-only queries, matches the incident timeline"`
+}
+
+type BreakGlassReportParams struct {
+	// Filter by the email of the user who broke glass
+	UserEmail string `json:"user_email" example:"john.wick@bad.org"`
+	// Filter by the connection accessed
+	ConnectionName string `json:"connection_name" example:"pgdemo"`
+	// Filter by the retrospective review state
+	Acknowledged string `json:"acknowledged" enums:"true,false" example:"false"`
+	// Start Date, defaults to 30 days ago
+	StartDate string `json:"start_date" format:"date" example:"2024-07-25"`
+	// End Date, defaults to tomorrow
+	EndDate string `json:"end_date" format:"date" example:"2024-07-26"`
+}
+
+type BreakGlassReport struct {
+	// The number of break-glass accesses in the period
+	Total int `json:"total" example:"4"`
+	// The number of break-glass accesses whose retrospective review is pending
+	Unacknowledged int `json:"unacknowledged" example:"1"`
+	// The break-glass accesses, newest first
+	Items []BreakGlassAccess `json:"items"`
+}
+
+type AccessScheduleWindow struct {
+	// Days of the week the window recurs on. Empty means every day
+	Days []string `json:"days" enums:"sun,mon,tue,wed,thu,fri,sat" example:"mon,tue,wed,thu,fri"`
+	// The time of day the window opens, in 24-hour HH:MM format
+	StartTime string `json:"start_time" binding:"required" example:"08:00"`
+	// The time of day the window closes, in 24-hour HH:MM format. A time at or
+	// before start_time makes the window run past midnight
+	EndTime string `json:"end_time" binding:"required" example:"20:00"`
+}
+
+type AccessScheduleFreeze struct {
+	// A label for the freeze, shown to users it denies
+	Name string `json:"name" example:"friday-release"`
+	// The time the freeze starts
+	StartsAt time.Time `json:"starts_at" binding:"required" example:"2026-05-08T15:00:00Z"`
+	// The time the freeze ends
+	EndsAt time.Time `json:"ends_at" binding:"required" example:"2026-05-09T03:00:00Z"`
+}
+
+type AccessSchedule struct {
+	// The resource identifier
+	ID string `json:"id" format:"uuid" readonly:"true" example:"15B5A2FD-0706-4A47-B1CF-B93CCFC5B3D7"`
+	// The name of the access schedule
+	Name string `json:"name" example:"prod-business-hours"`
+	// The description of the access schedule
+	Description *string `json:"description" example:"Production is only reachable during business hours"`
+	// The IANA time zone recurring windows are read in
+	Timezone string `json:"timezone" example:"America/Sao_Paulo"`
+	// The verbs this schedule applies to. Empty means all of them
+	Verbs []string `json:"verbs" enums:"connect,exec,runbooks" example:"exec,runbooks"`
+	// Connection names that this schedule applies to
+	ConnectionNames []string `json:"connection_names" example:"pgdemo,mysql-prod"`
+	// Attributes associated with this access schedule
+	Attributes []string `json:"attributes" example:"production,pii"`
+	// Windows in which access is allowed. Empty means access is allowed at any
+	// time outside the freeze periods
+	RecurringWindows []AccessScheduleWindow `json:"recurring_windows"`
+	// Periods in which access is denied, even inside a recurring window
+	FreezePeriods []AccessScheduleFreeze `json:"freeze_periods"`
+	// Groups that can approve a review overriding this schedule. Empty means
+	// the schedule can't be overridden
+	OverrideReviewersGroups []string `json:"override_reviewers_groups" example:"sre-managers"`
+	// The time the resource was created
+	CreatedAt time.Time `json:"created_at" readonly:"true" example:"2024-07-25T15:56:35.317601Z"`
+	// The time the resource was updated
+	UpdatedAt time.Time `json:"updated_at" readonly:"true" example:"2024-07-25T15:56:35.317601Z"`
+}
+
+type AccessScheduleRequest struct {
+	// The name of the access schedule
+	Name string `json:"name" binding:"required" example:"prod-business-hours"`
+	// The description of the access schedule
+	Description *string `json:"description" example:"Production is only reachable during business hours"`
+	// The IANA time zone recurring windows are read in. Defaults to UTC
+	Timezone string `json:"timezone" example:"America/Sao_Paulo"`
+	// The verbs this schedule applies to. Empty means all of them
+	Verbs []string `json:"verbs" enums:"connect,exec,runbooks" example:"exec,runbooks"`
+	// Connection names that this schedule applies to
+	ConnectionNames []string `json:"connection_names" example:"pgdemo,mysql-prod"`
+	// Attributes associated with this access schedule
+	Attributes []string `json:"attributes" example:"production,pii"`
+	// Windows in which access is allowed
+	RecurringWindows []AccessScheduleWindow `json:"recurring_windows"`
+	// Periods in which access is denied, even inside a recurring window
+	FreezePeriods []AccessScheduleFreeze `json:"freeze_periods"`
+	// Groups that can approve a review overriding this schedule. Empty means
+	// the schedule can't be overridden
+	OverrideReviewersGroups []string `json:"override_reviewers_groups" example:"sre-managers"`
+}
+
+type CertificationCampaign struct {
+	// The resource identifier
+	ID string `json:"id" format:"uuid" readonly:"true" example:"15B5A2FD-0706-4A47-B1CF-B93CCFC5B3D7"`
+	// The name of the campaign
+	Name string `json:"name" example:"quarterly-prod-access"`
+	// The description of the campaign
+	Description *string `json:"description" example:"Quarterly review of who can reach production"`
+	// Groups whose memberships are certified. Empty certifies every group that
+	// grants a connection or reviews an access request rule
+	GroupNames []string `json:"group_names" example:"dba,sre"`
+	// Groups that attest the memberships. Admins may attest them as well
+	ReviewersGroups []string `json:"reviewers_groups" example:"security"`
+	// Days a run stays open for attestations
+	DurationDays int `json:"duration_days" example:"14"`
+	// Days between scheduled runs. Null runs the campaign only when started by hand
+	RecurrenceDays *int `json:"recurrence_days" example:"90"`
+	// The time of the next scheduled run
+	NextRunAt *time.Time `json:"next_run_at" example:"2026-07-01T09:00:00Z"`
+	// The time the resource was created
+	CreatedAt time.Time `json:"created_at" readonly:"true" example:"2024-07-25T15:56:35.317601Z"`
+	// The time the resource was updated
+	UpdatedAt time.Time `json:"updated_at" readonly:"true" example:"2024-07-25T15:56:35.317601Z"`
+}
+
+type CertificationCampaignRequest struct {
+	// The name of the campaign
+	Name string `json:"name" binding:"required" example:"quarterly-prod-access"`
+	// The description of the campaign
+	Description *string `json:"description" example:"Quarterly review of who can reach production"`
+	// Groups whose memberships are certified. Empty certifies every group that
+	// grants a connection or reviews an access request rule
+	GroupNames []string `json:"group_names" example:"dba,sre"`
+	// Groups that attest the memberships. Admins may attest them as well
+	ReviewersGroups []string `json:"reviewers_groups" binding:"required" example:"security"`
+	// Days a run stays open for attestations. Defaults to 14
+	DurationDays int `json:"duration_days" example:"14"`
+	// Days between scheduled runs. Null runs the campaign only when started by hand
+	RecurrenceDays *int `json:"recurrence_days" example:"90"`
+	// The time of the next scheduled run. Required with recurrence_days
+	NextRunAt *time.Time `json:"next_run_at" example:"2026-07-01T09:00:00Z"`
+}
+
+type CertificationRun struct {
+	// The resource identifier
+	ID string `json:"id" format:"uuid" readonly:"true" example:"6F0C1A52-4F9E-4D0A-9A55-1F2B7C3D4E5F"`
+	// The name of the campaign when the run started
+	CampaignName string `json:"campaign_name" example:"quarterly-prod-access"`
+	// The groups that attest the memberships of this run
+	ReviewersGroups []string `json:"reviewers_groups" example:"security"`
+	// The status of the run
+	Status string `json:"status" enums:"open,completed" example:"open"`
+	// The user who started the run. Empty when the schedule did
+	StartedBy *string `json:"started_by" example:"alex.morgan@acme.com"`
+	// The time the run started
+	StartedAt time.Time `json:"started_at" example:"2026-04-01T09:00:00Z"`
+	// The time memberships left unattested are revoked
+	DeadlineAt time.Time `json:"deadline_at" example:"2026-04-15T09:00:00Z"`
+	// The time the run completed
+	CompletedAt *time.Time `json:"completed_at" example:"2026-04-15T09:00:30Z"`
+	// The number of memberships waiting for an attestation
+	Pending int `json:"pending" example:"3"`
+	// The number of memberships attested to keep
+	Kept int `json:"kept" example:"35"`
+	// The number of memberships attested to revoke
+	Revoked int `json:"revoked" example:"2"`
+	// The number of memberships revoked for lack of an attestation
+	NoResponse int `json:"no_response" example:"0"`
+}
+
+type CertificationItem struct {
+	// The resource identifier
+	ID string `json:"id" format:"uuid" readonly:"true" example:"9B1D3E2C-6A4F-4C2B-8E1D-7A5B3C9D1E2F"`
+	// The run this membership is certified in
+	RunID string `json:"run_id" format:"uuid" example:"6F0C1A52-4F9E-4D0A-9A55-1F2B7C3D4E5F"`
+	// The email of the member
+	UserEmail string `json:"user_email" example:"drew.k@acme.com"`
+	// The group under certification
+	GroupName string `json:"group_name" example:"dba"`
+	// The connections the group granted when the run started
+	ConnectionNames []string `json:"connection_names" example:"pgdemo,mysql-prod"`
+	// The access request rules the group reviewed when the run started
+	AccessRequestRules []string `json:"access_request_rules" example:"prod-databases"`
+	// The identity provider syncs the membership on login. The gateway never removes it,
+	// a revoke decision is only attested and must be carried out in the identity provider
+	IdpManaged bool `json:"idp_managed" example:"false"`
+	// The attestation of the membership
+	Decision string `json:"decision" enums:"pending,keep,revoke,no_response" example:"keep"`
+	// The reviewer who attested the membership
+	DecidedBy *string `json:"decided_by" example:"alex.morgan@acme.com"`
+	// The time the membership was attested
+	DecidedAt *time.Time `json:"decided_at" example:"2026-04-03T14:12:00Z"`
+	// The note of the reviewer
+	Note *string `json:"note" example:"still on the database team"`
+	// The time the membership was removed
+	RevokedAt *time.Time `json:"revoked_at" example:"2026-04-03T14:12:00Z"`
+}
+
+type CertificationDecisionRequest struct {
+	// Keep or revoke the membership. Revoking removes the user from the group at once,
+	// unless the identity provider manages the membership (idp_managed): it's then only attested
+	Decision string `json:"decision" binding:"required" enums:"keep,revoke" example:"keep"`
+	// A note kept in the evidence of the run
+	Note *string `json:"note" example:"still on the database team"`
+}
+
+// CertificationEvidence is the audit record of a run
+type CertificationEvidence struct {
+	// The run
+	Run CertificationRun `json:"run"`
+	// Every membership certified in the run, with its attestation
+	Items []CertificationItem `json:"items"`
+	// The time the evidence was generated
+	GeneratedAt time.Time `json:"generated_at" example:"2026-04-15T10:00:00Z"`
 }
 
 type AIProviderRequest struct {
@@ -0,0 +1,76 @@
+package apireports
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoophq/hoop/gateway/api/httputils"
+	"github.com/hoophq/hoop/gateway/api/openapi"
+	reviewapi "github.com/hoophq/hoop/gateway/api/review"
+	"github.com/hoophq/hoop/gateway/models"
+	"github.com/hoophq/hoop/gateway/storagev2"
+)
+
+// Break-Glass Reports
+//
+//	@Summary		Break-Glass Reports
+//	@Description	Lists the break-glass accesses granted in a period, with the state of their retrospective reviews.
+//	@Tags			Reports
+//	@Produce		json
+//	@Param			params	query		openapi.BreakGlassReportParams	false	"-"
+//	@Success		200		{object}	openapi.BreakGlassReport
+//	@Failure		400,500	{object}	openapi.HTTPError
+//	@Router			/reports/break-glass [get]
+func BreakGlassReport(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	opts := models.BreakGlassFilterOption{
+		StartDate:      today.AddDate(0, 0, -30),
+		EndDate:        today.AddDate(0, 0, 1),
+		OwnerEmail:     c.Query("user_email"),
+		ConnectionName: c.Query("connection_name"),
+	}
+
+	var err error
+	if v := c.Query("start_date"); v != "" {
+		if opts.StartDate, err = time.Parse(time.DateOnly, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": ErrInvalidDateFormat.Error()})
+			return
+		}
+	}
+	if v := c.Query("end_date"); v != "" {
+		if opts.EndDate, err = time.Parse(time.DateOnly, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": ErrInvalidDateFormat.Error()})
+			return
+		}
+	}
+	if opts.EndDate.Sub(opts.StartDate).Hours() > maxDaysRange {
+		c.JSON(http.StatusBadRequest, gin.H{"message": ErrInvalidDateRange.Error()})
+		return
+	}
+	if v := c.Query("acknowledged"); v != "" {
+		acknowledged, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid acknowledged value, expected true or false"})
+			return
+		}
+		opts.Acknowledged = &acknowledged
+	}
+
+	items, err := models.ListBreakGlassAccesses(ctx.GetOrgID(), opts)
+	if err != nil {
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed listing break-glass accesses: %v", err)
+		return
+	}
+	report := openapi.BreakGlassReport{Items: []openapi.BreakGlassAccess{}}
+	for _, bg := range items {
+		report.Items = append(report.Items, *reviewapi.ToOpenApiBreakGlassAccess(&bg))
+		if bg.AcknowledgedAt == nil {
+			report.Unacknowledged++
+		}
+	}
+	report.Total = len(report.Items)
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,135 @@
+package reviewapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aws/smithy-go/ptr"
+	"github.com/gin-gonic/gin"
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/gateway/api/httputils"
+	"github.com/hoophq/hoop/gateway/api/openapi"
+	"github.com/hoophq/hoop/gateway/events"
+	"github.com/hoophq/hoop/gateway/models"
+	"github.com/hoophq/hoop/gateway/storagev2"
+	"github.com/hoophq/hoop/gateway/transport/plugins/webhooks"
+	"github.com/hoophq/hoop/gateway/utils"
+)
+
+// AcknowledgeBreakGlass
+//
+//	@Summary		Acknowledge Break-Glass Access
+//	@Description	Acknowledge the retrospective review of a break-glass access. Only members of the reviewers groups of its access request rule, or admins, may acknowledge it, and never the user who broke glass.
+//	@Tags			Reviews
+//	@Param			id	path	string	true	"Resource identifier of the review or its session"
+//	@Accept			json
+//	@Produce		json
+//	@Param			request				body		openapi.BreakGlassAcknowledgeRequest	true	"The request body resource"
+//	@Success		200					{object}	openapi.BreakGlassAccess
+//	@Failure		400,403,404,409,500	{object}	openapi.HTTPError
+//	@Router			/reviews/{id}/break-glass/acknowledge [post]
+func AcknowledgeBreakGlass(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+
+	var req openapi.BreakGlassAcknowledgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	rev, err := models.GetReviewByIdOrSid(ctx.GetOrgID(), c.Param("id"))
+	switch err {
+	case nil:
+	case models.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"message": ErrNotFound.Error()})
+		return
+	default:
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed fetching review: %v", err)
+		return
+	}
+	bg, err := models.GetBreakGlassAccess(ctx.GetOrgID(), rev.ID)
+	switch err {
+	case nil:
+	case models.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"message": "review was not granted by a break-glass access"})
+		return
+	default:
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed fetching break-glass access: %v", err)
+		return
+	}
+
+	switch err := validateBreakGlassAcknowledger(ctx, rev, bg); err {
+	case nil:
+	case ErrSelfAcknowledgement:
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	default:
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "access denied"})
+		return
+	}
+
+	bg, err = models.AcknowledgeBreakGlassAccess(ctx.GetOrgID(), rev.ID, ctx.UserEmail, req.Note)
+	switch err {
+	case nil:
+	case models.ErrBreakGlassAcknowledged:
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+		return
+	default:
+		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed acknowledging break-glass access: %v", err)
+		return
+	}
+
+	err = webhooks.SendMessage(ctx.GetOrgID(), webhooks.EventBreakGlassAcknowledgedType, map[string]any{
+		"event_type": webhooks.EventBreakGlassAcknowledgedType,
+		"event_payload": map[string]any{
+			"review_id":          rev.ID,
+			"session_id":         bg.SessionID,
+			"user":               bg.OwnerEmail,
+			"connection":         bg.ConnectionName,
+			"reviewer":           ctx.UserEmail,
+			"incident_reference": bg.IncidentReference,
+			"note":               ptr.ToString(bg.AcknowledgementNote),
+		},
+	})
+	if err != nil {
+		log.With("sid", bg.SessionID).Warnf("failed sending break-glass acknowledgement webhook, reason=%v", err)
+	}
+	events.PublishBreakGlassAcknowledged(ctx.GetOrgID(), events.SessionEventBase{
+		SessionID:  bg.SessionID,
+		User:       bg.OwnerEmail,
+		Connection: bg.ConnectionName,
+		OccurredAt: time.Now().UTC(),
+	}, rev.ID, ctx.UserEmail, bg.IncidentReference, ptr.ToString(bg.AcknowledgementNote))
+
+	c.JSON(http.StatusOK, ToOpenApiBreakGlassAccess(bg))
+}
+
+// validateBreakGlassAcknowledger checks the user in ctx may acknowledge the
+// break-glass access bg granted the review rev: a member of the reviewers
+// groups of its rule, or an admin, who isn't the user who broke glass.
+func validateBreakGlassAcknowledger(ctx *storagev2.Context, rev *models.Review, bg *models.BreakGlassAccess) error {
+	if rev.OwnerID == ctx.UserID {
+		return ErrSelfAcknowledgement
+	}
+	if !ctx.IsAdmin() && !utils.SlicesHasIntersection(bg.ReviewersGroups, ctx.UserGroups) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func ToOpenApiBreakGlassAccess(bg *models.BreakGlassAccess) *openapi.BreakGlassAccess {
+	return &openapi.BreakGlassAccess{
+		ReviewID:              bg.ReviewID,
+		SessionID:             bg.SessionID,
+		ConnectionName:        bg.ConnectionName,
+		AccessRequestRuleName: bg.AccessRequestRuleName,
+		OwnerEmail:            bg.OwnerEmail,
+		Justification:         bg.Justification,
+		IncidentReference:     bg.IncidentReference,
+		ReviewersGroups:       bg.ReviewersGroups,
+		AcknowledgedAt:        bg.AcknowledgedAt,
+		AcknowledgedBy:        bg.AcknowledgedBy,
+		AcknowledgementNote:   bg.AcknowledgementNote,
+		CreatedAt:             bg.CreatedAt,
+	}
+}
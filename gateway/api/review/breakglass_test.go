@@ -0,0 +1,50 @@
+package reviewapi
+
+import (
+	"testing"
+
+	"github.com/hoophq/hoop/gateway/models"
+	"github.com/hoophq/hoop/gateway/storagev2"
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBreakGlassAcknowledger(t *testing.T) {
+	rev := &models.Review{ID: "review-id", OwnerID: "user1"}
+	bg := &models.BreakGlassAccess{ReviewID: "review-id", ReviewersGroups: []string{"dba", "security"}}
+	tests := []struct {
+		name        string
+		ctx         *storagev2.Context
+		expectedErr error
+	}{
+		{
+			name: "member of the reviewers groups",
+			ctx:  newFakeContext("user2", "user2@example.com", []string{"engineering", "security"}),
+		},
+		{
+			name: "admin outside the reviewers groups",
+			ctx:  newFakeContext("user2", "user2@example.com", []string{types.GroupAdmin}),
+		},
+		{
+			name:        "user acknowledging their own access",
+			ctx:         newFakeContext("user1", "user1@example.com", []string{"dba"}),
+			expectedErr: ErrSelfAcknowledgement,
+		},
+		{
+			name:        "admin acknowledging their own access",
+			ctx:         newFakeContext("user1", "user1@example.com", []string{types.GroupAdmin}),
+			expectedErr: ErrSelfAcknowledgement,
+		},
+		{
+			name:        "user outside the reviewers groups",
+			ctx:         newFakeContext("user2", "user2@example.com", []string{"engineering"}),
+			expectedErr: ErrForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedErr, validateBreakGlassAcknowledger(tt.ctx, rev, bg))
+		})
+	}
+}
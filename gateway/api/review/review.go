@@ -29,8 +29,10 @@ var (
 	ErrWrongState           = errors.New("review is in wrong state")
 	ErrNotEligible          = errors.New("not eligible for review")
 	ErrSelfApproval         = errors.New("unable to self approve review")
+	ErrSelfAcknowledgement  = errors.New("unable to acknowledge your own break-glass access")
 	ErrGroupAlreadyReviewed = errors.New("it was already reviewed")
 	ErrForbidden            = errors.New("forbidden")
+	ErrExpired              = errors.New("review expired")
 	ErrUnknownStatus        = errors.New("unknown status")
 )
 
@@ -64,7 +66,17 @@ func (h *handler) GetByIdOrSid(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"message": models.ErrNotFound.Error()})
 		return
 	case nil:
-		c.JSON(http.StatusOK, toOpenApiReview(review))
+		resp := toOpenApiReview(review)
+		bg, err := models.GetBreakGlassAccess(ctx.GetOrgID(), review.ID)
+		switch err {
+		case nil:
+			resp.BreakGlass = ToOpenApiBreakGlassAccess(bg)
+		case models.ErrNotFound:
+		default:
+			httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed fetching break-glass access: %v", err)
+			return
+		}
+		c.JSON(http.StatusOK, resp)
 	default:
 		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed fetching review: %v", err)
 		return
@@ -136,7 +148,7 @@ func ParseTimeWindow(timeWindow *openapi.ReviewSessionTimeWindow) (*models.Revie
 //	@Produce				json
 //	@Param					request			body		openapi.ReviewRequest	true	"The request body resource"
 //	@Success				200				{object}	openapi.Review
-//	@Failure				400,403,404,500	{object}	openapi.HTTPError
+//	@Failure				400,403,404,409,500	{object}	openapi.HTTPError
 //	@Router					/reviews/{id} [put]
 func (h *handler) ReviewByIdOrSid(c *gin.Context) {
 	ctx := storagev2.ParseContext(c)
@@ -167,6 +179,8 @@ func (h *handler) ReviewByIdOrSid(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 	case ErrForbidden:
 		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "access denied"})
+	case ErrExpired:
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
 	case nil:
 		if rev.Status == models.ReviewStatusApproved || rev.Status == models.ReviewStatusRejected {
 			// release any gRPC connection waiting for a review
@@ -195,7 +209,7 @@ func (h *handler) ReviewByIdOrSid(c *gin.Context) {
 //	@Produce				json
 //	@Param					request			body		openapi.ReviewRequest	true	"The request body resource"
 //	@Success				200				{object}	openapi.Review
-//	@Failure				400,403,404,500	{object}	openapi.HTTPError
+//	@Failure				400,403,404,409,500	{object}	openapi.HTTPError
 //	@Router					/sessions/{session_id}/review [put]
 func (h *handler) ReviewBySid(c *gin.Context) { h.ReviewByIdOrSid(c) }
 
@@ -212,7 +226,8 @@ func UpdateSlackMessage(rev *models.Review) error {
 	}
 
 	switch rev.Status {
-	case models.ReviewStatusPending, models.ReviewStatusApproved, models.ReviewStatusRejected:
+	case models.ReviewStatusPending, models.ReviewStatusApproved, models.ReviewStatusRejected,
+		models.ReviewStatusExpired:
 	default:
 		return nil
 	}
@@ -221,10 +236,12 @@ func UpdateSlackMessage(rev *models.Review) error {
 		ReviewID:    rev.ID,
 		IsApproved:  rev.Status == models.ReviewStatusApproved,
 		IsRejected:  rev.Status == models.ReviewStatusRejected,
+		IsExpired:   rev.Status == models.ReviewStatusExpired,
 		TotalGroups: len(rev.ReviewGroups),
 	}
 	for _, rg := range rev.ReviewGroups {
-		if rg.Status == models.ReviewStatusPending {
+		// groups still pending when the review expired were never reviewed
+		if rg.Status == models.ReviewStatusPending || rg.Status == models.ReviewStatusExpired {
 			continue
 		}
 		reviewedAt := time.Now().UTC()
@@ -284,7 +301,11 @@ func DoReview(ctx *storagev2.Context, reviewIdOrSid string, status models.Review
 		rev.RejectionReason = &rejectionReason
 	}
 
-	if err := models.UpdateReview(rev); err != nil {
+	switch err := models.UpdateReview(rev); err {
+	case nil:
+	case models.ErrReviewExpired:
+		return nil, ErrExpired
+	default:
 		return nil, fmt.Errorf("failed updating review state, reason=%v", err)
 	}
 
@@ -354,7 +375,7 @@ func doForcedReview(ctx *storagev2.Context, rev *models.Review, connection *mode
 
 	// update review group to
 	forceGroupIndex := slices.IndexFunc(rev.ReviewGroups, func(rg models.ReviewGroups) bool {
-		return rg.GroupName == *forceGroupFound
+		return rg.GroupName == *forceGroupFound && rg.AccessScheduleName == nil
 	})
 
 	reviewedAt := time.Now().UTC()
@@ -383,6 +404,11 @@ func doForcedReview(ctx *storagev2.Context, rev *models.Review, connection *mode
 		})
 	}
 
+	// forcing a review stands in for the approvals of the access request
+	// rule, never for the override reviewers of a schedule
+	if status == models.ReviewStatusApproved && !scheduleOverridesApproved(rev) {
+		return rev, nil
+	}
 	rev.Status = status
 
 	return rev, nil
@@ -391,8 +417,16 @@ func doForcedReview(ctx *storagev2.Context, rev *models.Review, connection *mode
 func doIndividualReview(ctx *storagev2.Context, rev *models.Review, connection *models.Connection, status models.ReviewStatusType) (*models.Review, error) {
 	reviewedAt := time.Now().UTC()
 	approvedCount := 0
-	reviewsCountNeeded := len(rev.ReviewGroups)
-	if rev.AccessRequestRuleName != nil {
+	forcedApproval := false
+	// the groups approving a schedule override are counted apart, see
+	// scheduleOverridesApproved
+	reviewsCountNeeded := 0
+	for _, r := range rev.ReviewGroups {
+		if r.AccessScheduleName == nil {
+			reviewsCountNeeded++
+		}
+	}
+	if rev.AccessRequestRuleName != nil || len(rev.AccessScheduleNames) > 0 {
 		if rev.MinApprovals != nil {
 			reviewsCountNeeded = min(reviewsCountNeeded, *rev.MinApprovals)
 		}
@@ -415,8 +449,10 @@ func doIndividualReview(ctx *storagev2.Context, rev *models.Review, connection *
 		}
 
 		// count approved reviews
-		if rev.ReviewGroups[i].Status == models.ReviewStatusApproved {
+		if rev.ReviewGroups[i].Status == models.ReviewStatusApproved && r.AccessScheduleName == nil {
 			approvedCount++
+			// a review forced while a schedule override was still pending
+			forcedApproval = forcedApproval || r.ForcedReview
 		}
 	}
 
@@ -459,7 +495,7 @@ func doIndividualReview(ctx *storagev2.Context, rev *models.Review, connection *
 	// Update the overall review status based on individual review group statuses
 	if status == models.ReviewStatusApproved {
 		// Only approve the review if all required review groups have approved
-		if approvedCount == reviewsCountNeeded {
+		if (approvedCount == reviewsCountNeeded || forcedApproval) && scheduleOverridesApproved(rev) {
 			rev.Status = models.ReviewStatusApproved
 		}
 		// Otherwise, keep status as pending (no explicit assignment needed)
@@ -471,6 +507,24 @@ func doIndividualReview(ctx *storagev2.Context, rev *models.Review, connection *
 	return rev, nil
 }
 
+// scheduleOverridesApproved reports whether every access schedule that
+// denied the session was approved by one of its own override reviewers
+// groups. The approval of one schedule's reviewers never lifts another one:
+// whoever approves working outside business hours may not be the one who
+// decides about a change freeze.
+func scheduleOverridesApproved(rev *models.Review) bool {
+	for _, scheduleName := range rev.AccessScheduleNames {
+		approved := slices.ContainsFunc(rev.ReviewGroups, func(rg models.ReviewGroups) bool {
+			return rg.AccessScheduleName != nil && *rg.AccessScheduleName == scheduleName &&
+				rg.Status == models.ReviewStatusApproved
+		})
+		if !approved {
+			return false
+		}
+	}
+	return true
+}
+
 func validateReviewStatusTransition(ctx *storagev2.Context, rev *models.Review, status models.ReviewStatusType) error {
 	// user can only approve, reject or revoke a review
 	switch status {
@@ -521,12 +575,13 @@ func toOpenApiReview(r *models.Review) *openapi.Review {
 			}
 		}
 		itemGroups = append(itemGroups, openapi.ReviewGroup{
-			ID:           rg.ID,
-			Group:        rg.GroupName,
-			Status:       openapi.ReviewRequestStatusType(rg.Status),
-			ReviewedBy:   reviewOwner,
-			ReviewDate:   rg.ReviewedAt,
-			ForcedReview: rg.ForcedReview,
+			ID:                 rg.ID,
+			Group:              rg.GroupName,
+			Status:             openapi.ReviewRequestStatusType(rg.Status),
+			ReviewedBy:         reviewOwner,
+			ReviewDate:         rg.ReviewedAt,
+			ForcedReview:       rg.ForcedReview,
+			AccessScheduleName: rg.AccessScheduleName,
 		})
 	}
 	var timeWindow *openapi.ReviewSessionTimeWindow
@@ -550,8 +605,12 @@ func toOpenApiReview(r *models.Review) *openapi.Review {
 		ReviewGroupsData:      itemGroups,
 		TimeWindow:            timeWindow,
 		AccessRequestRuleName: r.AccessRequestRuleName,
+		AccessScheduleNames:   r.AccessScheduleNames,
 		MinApprovals:          r.MinApprovals,
 		ForceApprovalGroups:   r.ForceApprovalGroups,
 		RejectionReason:       r.RejectionReason,
+		ReminderSentAt:        r.ReminderSentAt,
+		EscalatedAt:           r.EscalatedAt,
+		ExpireAt:              r.ExpireAt,
 	}
 }
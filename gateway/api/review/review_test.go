@@ -48,6 +48,17 @@ func newFakeReview(ownerID, status, typ string, groups []models.ReviewGroups, ac
 	}
 }
 
+// newFakeScheduleReview returns a pending review overriding scheduleNames,
+// waiting on accessRequestRule as well when it's set.
+func newFakeScheduleReview(accessRequestRule *models.AccessRequestRule, groups []models.ReviewGroups, scheduleNames ...string) *models.Review {
+	rev := newFakeReview("user1", "PENDING", "onetime", groups, accessRequestRule)
+	if rev.MinApprovals == nil {
+		rev.MinApprovals = ptr.Int(1)
+	}
+	rev.AccessScheduleNames = scheduleNames
+	return rev
+}
+
 type inputData struct {
 	ctx    *storagev2.Context
 	rev    *models.Review
@@ -379,6 +390,110 @@ func TestDoReview(t *testing.T) {
 				assert.WithinDuration(t, expectedRevoke, *rev.RevokedAt, time.Minute)
 			},
 		},
+		{
+			name: "one schedule override reviewers do not lift another schedule",
+			input: inputData{
+				ctx: newFakeContext("user2", "user2@example.com", []string{"on-call"}),
+				rev: newFakeScheduleReview(nil, []models.ReviewGroups{
+					{GroupName: "on-call", Status: models.ReviewStatusPending, AccessScheduleName: ptr.String("business-hours")},
+					{GroupName: "release-managers", Status: models.ReviewStatusPending, AccessScheduleName: ptr.String("release-freeze")},
+				}, "business-hours", "release-freeze"),
+				con:    &models.Connection{},
+				status: models.ReviewStatusApproved,
+			},
+			validateFunc: func(t *testing.T, rev *models.Review) {
+				assert.Equal(t, models.ReviewStatusApproved, rev.ReviewGroups[0].Status)
+				assert.Equal(t, models.ReviewStatusPending, rev.ReviewGroups[1].Status)
+				assert.Equal(t, models.ReviewStatusPending, rev.Status)
+			},
+		},
+		{
+			name: "approve once every schedule override is approved by its own reviewers",
+			input: inputData{
+				ctx: newFakeContext("user3", "user3@example.com", []string{"release-managers"}),
+				rev: newFakeScheduleReview(nil, []models.ReviewGroups{
+					{GroupName: "on-call", Status: models.ReviewStatusApproved, AccessScheduleName: ptr.String("business-hours")},
+					{GroupName: "release-managers", Status: models.ReviewStatusPending, AccessScheduleName: ptr.String("release-freeze")},
+				}, "business-hours", "release-freeze"),
+				con:    &models.Connection{},
+				status: models.ReviewStatusApproved,
+			},
+			validateFunc: func(t *testing.T, rev *models.Review) {
+				assert.Equal(t, models.ReviewStatusApproved, rev.Status)
+			},
+		},
+		{
+			name: "schedule override approval still waits on the access request rule",
+			input: inputData{
+				ctx: newFakeContext("user2", "user2@example.com", []string{"on-call"}),
+				rev: newFakeScheduleReview(&models.AccessRequestRule{MinApprovals: ptr.Int(1)}, []models.ReviewGroups{
+					{GroupName: "dba", Status: models.ReviewStatusPending},
+					{GroupName: "on-call", Status: models.ReviewStatusPending, AccessScheduleName: ptr.String("business-hours")},
+				}, "business-hours"),
+				con:    &models.Connection{},
+				status: models.ReviewStatusApproved,
+			},
+			validateFunc: func(t *testing.T, rev *models.Review) {
+				assert.Equal(t, models.ReviewStatusPending, rev.ReviewGroups[0].Status)
+				assert.Equal(t, models.ReviewStatusApproved, rev.ReviewGroups[1].Status)
+				assert.Equal(t, models.ReviewStatusPending, rev.Status)
+			},
+		},
+		{
+			name: "access request rule approval still waits on the schedule override",
+			input: inputData{
+				ctx: newFakeContext("user3", "user3@example.com", []string{"dba"}),
+				rev: newFakeScheduleReview(&models.AccessRequestRule{MinApprovals: ptr.Int(1)}, []models.ReviewGroups{
+					{GroupName: "dba", Status: models.ReviewStatusPending},
+					{GroupName: "on-call", Status: models.ReviewStatusPending, AccessScheduleName: ptr.String("business-hours")},
+				}, "business-hours"),
+				con:    &models.Connection{},
+				status: models.ReviewStatusApproved,
+			},
+			validateFunc: func(t *testing.T, rev *models.Review) {
+				assert.Equal(t, models.ReviewStatusApproved, rev.ReviewGroups[0].Status)
+				assert.Equal(t, models.ReviewStatusPending, rev.Status)
+			},
+		},
+		{
+			name: "force approve does not lift a schedule override",
+			input: inputData{
+				ctx: newFakeContext("user2", "user2@example.com", []string{"admin"}),
+				rev: newFakeScheduleReview(&models.AccessRequestRule{
+					MinApprovals:        ptr.Int(1),
+					ForceApprovalGroups: []string{"admin"},
+				}, []models.ReviewGroups{
+					{GroupName: "dba", Status: models.ReviewStatusPending},
+					{GroupName: "admin", Status: models.ReviewStatusPending, AccessScheduleName: ptr.String("business-hours")},
+				}, "business-hours"),
+				con:    &models.Connection{},
+				status: models.ReviewStatusApproved,
+				force:  true,
+			},
+			validateFunc: func(t *testing.T, rev *models.Review) {
+				assert.Len(t, rev.ReviewGroups, 3)
+				assert.True(t, rev.ReviewGroups[2].ForcedReview)
+				assert.Equal(t, models.ReviewStatusPending, rev.ReviewGroups[1].Status)
+				assert.Equal(t, models.ReviewStatusPending, rev.Status)
+			},
+		},
+		{
+			name: "force approved review is approved once the schedule override is",
+			input: inputData{
+				ctx: newFakeContext("user3", "user3@example.com", []string{"on-call"}),
+				rev: newFakeScheduleReview(&models.AccessRequestRule{MinApprovals: ptr.Int(2)}, []models.ReviewGroups{
+					{GroupName: "dba", Status: models.ReviewStatusPending},
+					{GroupName: "security", Status: models.ReviewStatusPending},
+					{GroupName: "admin", Status: models.ReviewStatusApproved, ForcedReview: true},
+					{GroupName: "on-call", Status: models.ReviewStatusPending, AccessScheduleName: ptr.String("business-hours")},
+				}, "business-hours"),
+				con:    &models.Connection{},
+				status: models.ReviewStatusApproved,
+			},
+			validateFunc: func(t *testing.T, rev *models.Review) {
+				assert.Equal(t, models.ReviewStatusApproved, rev.Status)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -26,6 +26,7 @@ import (
 	"github.com/hoophq/hoop/gateway/api/apiroutes"
 	apiattributes "github.com/hoophq/hoop/gateway/api/attributes"
 	auditlogapi "github.com/hoophq/hoop/gateway/api/auditlog"
+	apicertifications "github.com/hoophq/hoop/gateway/api/certifications"
 	apiconnections "github.com/hoophq/hoop/gateway/api/connections"
 	apidatamasking "github.com/hoophq/hoop/gateway/api/datamasking"
 	apieventrouting "github.com/hoophq/hoop/gateway/api/eventrouting"
@@ -684,6 +685,10 @@ func (api *Api) buildRoutes(r *apiroutes.Router) {
 		api.TrackRequest(analytics.EventUpdateReview),
 		reviewHandler.ReviewByIdOrSid,
 	)
+	r.POST("/reviews/:id/break-glass/acknowledge",
+		r.AuthMiddleware,
+		reviewapi.AcknowledgeBreakGlass,
+	)
 
 	r.GET("/access-requests/rules",
 		apiroutes.AdminAndAuditorAccessRole,
@@ -710,6 +715,86 @@ func (api *Api) buildRoutes(r *apiroutes.Router) {
 		r.AuthMiddleware,
 		accessrequestsapi.DeleteAccessRequestRule,
 	)
+	r.GET("/access-requests/schedules",
+		apiroutes.AdminAndAuditorAccessRole,
+		r.AuthMiddleware,
+		accessrequestsapi.ListAccessSchedules,
+	)
+	r.POST("/access-requests/schedules",
+		apiroutes.AdminOnlyAccessRole,
+		r.AuthMiddleware,
+		accessrequestsapi.CreateAccessSchedule,
+	)
+	r.GET("/access-requests/schedules/:name",
+		apiroutes.AdminAndAuditorAccessRole,
+		r.AuthMiddleware,
+		accessrequestsapi.GetAccessSchedule,
+	)
+	r.PUT("/access-requests/schedules/:name",
+		apiroutes.AdminOnlyAccessRole,
+		r.AuthMiddleware,
+		accessrequestsapi.UpdateAccessSchedule,
+	)
+	r.DELETE("/access-requests/schedules/:name",
+		apiroutes.AdminOnlyAccessRole,
+		r.AuthMiddleware,
+		accessrequestsapi.DeleteAccessSchedule,
+	)
+
+	r.GET("/certifications/campaigns",
+		apiroutes.AdminAndAuditorAccessRole,
+		r.AuthMiddleware,
+		apicertifications.ListCertificationCampaigns,
+	)
+	r.POST("/certifications/campaigns",
+		apiroutes.AdminOnlyAccessRole,
+		r.AuthMiddleware,
+		apicertifications.CreateCertificationCampaign,
+	)
+	r.GET("/certifications/campaigns/:name",
+		apiroutes.AdminAndAuditorAccessRole,
+		r.AuthMiddleware,
+		apicertifications.GetCertificationCampaign,
+	)
+	r.PUT("/certifications/campaigns/:name",
+		apiroutes.AdminOnlyAccessRole,
+		r.AuthMiddleware,
+		apicertifications.UpdateCertificationCampaign,
+	)
+	r.DELETE("/certifications/campaigns/:name",
+		apiroutes.AdminOnlyAccessRole,
+		r.AuthMiddleware,
+		apicertifications.DeleteCertificationCampaign,
+	)
+	r.POST("/certifications/campaigns/:name/runs",
+		apiroutes.AdminOnlyAccessRole,
+		r.AuthMiddleware,
+		apicertifications.StartCertificationRun,
+	)
+	r.GET("/certifications/runs",
+		apiroutes.AdminAndAuditorAccessRole,
+		r.AuthMiddleware,
+		apicertifications.ListCertificationRuns,
+	)
+	r.GET("/certifications/runs/:id",
+		apiroutes.AdminAndAuditorAccessRole,
+		r.AuthMiddleware,
+		apicertifications.GetCertificationRun,
+	)
+	r.GET("/certifications/runs/:id/evidence",
+		apiroutes.AdminAndAuditorAccessRole,
+		r.AuthMiddleware,
+		apicertifications.GetCertificationEvidence,
+	)
+	r.GET("/certifications/items",
+		apiroutes.ReadOnlyAccessRole,
+		r.AuthMiddleware,
+		apicertifications.ListCertificationItems,
+	)
+	r.POST("/certifications/items/:id/decision",
+		r.AuthMiddleware,
+		apicertifications.DecideCertificationItem,
+	)
 
 	r.POST("/agents",
 		apiroutes.AdminOnlyAccessRole,
@@ -914,6 +999,10 @@ func (api *Api) buildRoutes(r *apiroutes.Router) {
 		apiroutes.ReadOnlyAccessRole,
 		r.AuthMiddleware,
 		apireports.SessionReport)
+	r.GET("/reports/break-glass",
+		apiroutes.AdminAndAuditorAccessRole,
+		r.AuthMiddleware,
+		apireports.BreakGlassReport)
 
 	r.GET("/plugins/runbooks/connections/:name/templates",
 		apiroutes.ReadOnlyAccessRole,
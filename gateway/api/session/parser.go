@@ -151,12 +151,13 @@ func topOpenApiReview(r *models.SessionReview) *openapi.SessionReview {
 			}
 		}
 		itemGroups = append(itemGroups, openapi.ReviewGroup{
-			ID:           rg.ID,
-			Group:        rg.GroupName,
-			Status:       openapi.ReviewRequestStatusType(rg.Status),
-			ReviewedBy:   reviewOwner,
-			ReviewDate:   rg.ReviewedAt,
-			ForcedReview: rg.ForcedReview,
+			ID:                 rg.ID,
+			Group:              rg.GroupName,
+			Status:             openapi.ReviewRequestStatusType(rg.Status),
+			ReviewedBy:         reviewOwner,
+			ReviewDate:         rg.ReviewedAt,
+			ForcedReview:       rg.ForcedReview,
+			AccessScheduleName: rg.AccessScheduleName,
 		})
 	}
 	var timeWindow *openapi.ReviewSessionTimeWindow
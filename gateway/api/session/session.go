@@ -45,15 +45,16 @@ var (
 )
 
 type SessionPostBody struct {
-	Script         string                    `json:"script"`
-	Connection     string                    `json:"connection"`
-	EnvVars        map[string]string         `json:"env_vars"`
-	Labels         openapi.SessionLabelsType `json:"labels"`
-	Metadata       map[string]any            `json:"metadata"`
-	ClientArgs     []string                  `json:"client_args"`
-	JiraFields     map[string]string         `json:"jira_fields"`
-	SessionBatchID *string                   `json:"session_batch_id"`
-	CorrelationID  *string                   `json:"correlation_id"`
+	Script         string                            `json:"script"`
+	Connection     string                            `json:"connection"`
+	EnvVars        map[string]string                 `json:"env_vars"`
+	Labels         openapi.SessionLabelsType         `json:"labels"`
+	Metadata       map[string]any                    `json:"metadata"`
+	ClientArgs     []string                          `json:"client_args"`
+	JiraFields     map[string]string                 `json:"jira_fields"`
+	SessionBatchID *string                           `json:"session_batch_id"`
+	CorrelationID  *string                           `json:"correlation_id"`
+	BreakGlass     *openapi.SessionBreakGlassRequest `json:"break_glass"`
 }
 
 // AIAnalyzeInput carries everything needed to analyze a session's script and
@@ -359,6 +360,7 @@ func Post(c *gin.Context) {
 		ConnectionName: conn.Name,
 		BearerToken:    apiroutes.GetAccessTokenFromRequest(c),
 		UserAgent:      userAgent,
+		BreakGlass:     req.BreakGlass,
 	})
 	if err != nil {
 		httputils.AbortWithErr(c, http.StatusInternalServerError, err, "failed creating client: %v", err)
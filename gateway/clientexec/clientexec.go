@@ -51,6 +51,7 @@ type clientExec struct {
 	ctx                       context.Context
 	cancelFn                  context.CancelFunc
 	connectionCommandOverride []byte
+	breakGlass                *openapi.SessionBreakGlassRequest
 	sessionID                 string
 	orgID                     string
 }
@@ -64,6 +65,9 @@ type Options struct {
 	Origin                    string
 	Verb                      string
 	UserAgent                 string
+	// BreakGlass requests break-glass access to the connection, see
+	// pb.SpecBreakGlassJustification
+	BreakGlass *openapi.SessionBreakGlassRequest
 
 	ImpersonateUserSubject string
 }
@@ -188,6 +192,7 @@ func New(opts *Options) (*clientExec, error) {
 		sessionID:                 opts.SessionID,
 		orgID:                     opts.OrgID,
 		connectionCommandOverride: connectionCommandJson,
+		breakGlass:                opts.BreakGlass,
 	}, nil
 }
 
@@ -212,6 +217,11 @@ func (c *clientExec) Run(inputPayload []byte, clientEnvVars map[string]string, c
 		openSessionSpec[pb.SpecConnectionCommand] = c.connectionCommandOverride
 	}
 
+	if c.breakGlass != nil {
+		openSessionSpec[pb.SpecBreakGlassJustification] = []byte(c.breakGlass.Justification)
+		openSessionSpec[pb.SpecBreakGlassIncident] = []byte(c.breakGlass.IncidentReference)
+	}
+
 	now := time.Now().UTC()
 	resp := c.run(inputPayload, openSessionSpec)
 	resp.ExecutionTimeMili = time.Since(now).Milliseconds()
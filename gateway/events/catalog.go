@@ -89,7 +89,7 @@ var Catalog = map[string]EventType{
 		Category: "Access",
 		Description: "Fires when a review is rejected via the API, Slack, or MCP. `reason` is the " +
 			"free-form text supplied at decision time (empty when none was given). Does not fire " +
-			"on auto-expiration or force-rejection.",
+			"on auto-expiration (see access.review_expired) or force-rejection.",
 		Schema: []SchemaField{
 			{Name: "session_id", Type: "string", Required: true},
 			{Name: "user", Type: "string(email)", Required: true},
@@ -109,6 +109,155 @@ var Catalog = map[string]EventType{
 			"occurred_at": "2026-05-04T14:50:00Z",
 		},
 	},
+	"access.review_expired": {
+		Name:     "access.review_expired",
+		Category: "Access",
+		Description: "Fires when a pending review reaches the expiration deadline of its access " +
+			"request rule without a decision. The session is closed and the review moves to " +
+			"EXPIRED. `rule` is the access request rule that set the deadline.",
+		Schema: []SchemaField{
+			{Name: "session_id", Type: "string", Required: true},
+			{Name: "user", Type: "string(email)", Required: true},
+			{Name: "connection", Type: "string", Required: true},
+			{Name: "review_id", Type: "string", Required: true},
+			{Name: "rule", Type: "string", Required: false},
+			{Name: "occurred_at", Type: "string(ISO 8601)", Required: true},
+		},
+		SamplePayload: map[string]any{
+			"session_id":  "ses_01HX9C2IJK",
+			"user":        "drew.k@acme.com",
+			"connection":  "conn-prod-pg",
+			"review_id":   "rev_01HX9C2IJL",
+			"rule":        "prod-databases",
+			"occurred_at": "2026-05-04T22:50:00Z",
+		},
+	},
+	"access.break_glass_used": {
+		Name:     "access.break_glass_used",
+		Category: "Access",
+		Description: "Fires when a member of an access request rule's break-glass groups skips its " +
+			"review during an incident. Access is granted at once and a retrospective review is " +
+			"opened for the rule's reviewers. `duration_sec` is 0 for a one-off execution.",
+		Schema: []SchemaField{
+			{Name: "session_id", Type: "string", Required: true},
+			{Name: "user", Type: "string(email)", Required: true},
+			{Name: "connection", Type: "string", Required: true},
+			{Name: "review_id", Type: "string", Required: true},
+			{Name: "rule", Type: "string", Required: false},
+			{Name: "justification", Type: "string", Required: true},
+			{Name: "incident_reference", Type: "string", Required: true},
+			{Name: "duration_sec", Type: "int", Required: true},
+			{Name: "occurred_at", Type: "string(ISO 8601)", Required: true},
+		},
+		SamplePayload: map[string]any{
+			"session_id":         "ses_01HX9C3MNO",
+			"user":               "drew.k@acme.com",
+			"connection":         "conn-prod-pg",
+			"review_id":          "rev_01HX9C3MNP",
+			"rule":               "prod-databases",
+			"justification":      "checkout is failing, need to inspect the orders table",
+			"incident_reference": "INC-4821",
+			"duration_sec":       1800,
+			"occurred_at":        "2026-05-04T03:12:00Z",
+		},
+	},
+	"access.break_glass_acknowledged": {
+		Name:     "access.break_glass_acknowledged",
+		Category: "Access",
+		Description: "Fires when a reviewer acknowledges the retrospective review of a " +
+			"break-glass access. `reviewer` is the acknowledging user; `note` is the free-form " +
+			"text supplied with the acknowledgement (empty when none was given).",
+		Schema: []SchemaField{
+			{Name: "session_id", Type: "string", Required: true},
+			{Name: "user", Type: "string(email)", Required: true},
+			{Name: "connection", Type: "string", Required: true},
+			{Name: "review_id", Type: "string", Required: true},
+			{Name: "reviewer", Type: "string(email)", Required: true},
+			{Name: "incident_reference", Type: "string", Required: true},
+			{Name: "note", Type: "string", Required: false},
+			{Name: "occurred_at", Type: "string(ISO 8601)", Required: true},
+		},
+		SamplePayload: map[string]any{
+			"session_id":         "ses_01HX9C3MNO",
+			"user":               "drew.k@acme.com",
+			"connection":         "conn-prod-pg",
+			"review_id":          "rev_01HX9C3MNP",
+			"reviewer":           "alex.morgan@acme.com",
+			"incident_reference": "INC-4821",
+			"note":               "read-only queries, matches the incident timeline",
+			"occurred_at":        "2026-05-04T09:40:00Z",
+		},
+	},
+	"access.certification_started": {
+		Name:     "access.certification_started",
+		Category: "Access",
+		Description: "Fires when a certification campaign starts a run, on its schedule or by hand. " +
+			"`items` is the number of group memberships the reviewers must attest to keep or " +
+			"revoke before `deadline_at`; the ones left unanswered are revoked.",
+		Schema: []SchemaField{
+			{Name: "run_id", Type: "string", Required: true},
+			{Name: "campaign", Type: "string", Required: true},
+			{Name: "reviewers_groups", Type: "string[]", Required: true},
+			{Name: "items", Type: "int", Required: true},
+			{Name: "deadline_at", Type: "string(ISO 8601)", Required: true},
+			{Name: "occurred_at", Type: "string(ISO 8601)", Required: true},
+		},
+		SamplePayload: map[string]any{
+			"run_id":           "6f0c1a52-4f9e-4d0a-9a55-1f2b7c3d4e5f",
+			"campaign":         "quarterly-prod-access",
+			"reviewers_groups": []string{"security"},
+			"items":            42,
+			"deadline_at":      "2026-05-18T09:00:00Z",
+			"occurred_at":      "2026-05-04T09:00:00Z",
+		},
+	},
+	"access.certification_revoked": {
+		Name:     "access.certification_revoked",
+		Category: "Access",
+		Description: "Fires when a certification run removes a user from a group. `decision` is " +
+			"`revoke` when a reviewer (`reviewer`) decided so, or `no_response` when nobody " +
+			"attested the membership before the run's deadline. `connections` lists what the " +
+			"group granted when the run started.",
+		Schema: []SchemaField{
+			{Name: "run_id", Type: "string", Required: true},
+			{Name: "campaign", Type: "string", Required: true},
+			{Name: "user", Type: "string(email)", Required: true},
+			{Name: "group", Type: "string", Required: true},
+			{Name: "connections", Type: "string[]", Required: true},
+			{Name: "decision", Type: "string", Required: true},
+			{Name: "reviewer", Type: "string(email)", Required: false},
+			{Name: "occurred_at", Type: "string(ISO 8601)", Required: true},
+		},
+		SamplePayload: map[string]any{
+			"run_id":      "6f0c1a52-4f9e-4d0a-9a55-1f2b7c3d4e5f",
+			"campaign":    "quarterly-prod-access",
+			"user":        "drew.k@acme.com",
+			"group":       "dba",
+			"connections": []string{"conn-prod-pg"},
+			"decision":    "revoke",
+			"reviewer":    "alex.morgan@acme.com",
+			"occurred_at": "2026-05-06T14:12:00Z",
+		},
+	},
+	"access.certification_completed": {
+		Name:     "access.certification_completed",
+		Category: "Access",
+		Description: "Fires when a certification run closes, at its deadline or once every " +
+			"membership was attested. `revoked` counts the memberships removed at closing, the " +
+			"ones left unanswered included; revocations decided earlier fired their own events.",
+		Schema: []SchemaField{
+			{Name: "run_id", Type: "string", Required: true},
+			{Name: "campaign", Type: "string", Required: true},
+			{Name: "revoked", Type: "int", Required: true},
+			{Name: "occurred_at", Type: "string(ISO 8601)", Required: true},
+		},
+		SamplePayload: map[string]any{
+			"run_id":      "6f0c1a52-4f9e-4d0a-9a55-1f2b7c3d4e5f",
+			"campaign":    "quarterly-prod-access",
+			"revoked":     3,
+			"occurred_at": "2026-05-18T09:00:30Z",
+		},
+	},
 	"session.guardrail_violation": {
 		Name:     "session.guardrail_violation",
 		Category: "Session",
@@ -83,6 +83,77 @@ func PublishJITDenied(orgID string, base SessionEventBase, reviewerEmail, review
 	}, "review.status_change", reviewID+":access.jit_denied")
 }
 
+func PublishReviewExpired(orgID string, base SessionEventBase, reviewID, ruleName string) {
+	Publish(orgID, "access.review_expired", map[string]any{
+		"session_id":  base.SessionID,
+		"user":        base.User,
+		"connection":  base.Connection,
+		"review_id":   reviewID,
+		"rule":        ruleName,
+		"occurred_at": base.OccurredAt.UTC().Format(time.RFC3339),
+	}, "review.sla", reviewID+":access.review_expired")
+}
+
+func PublishBreakGlassUsed(orgID string, base SessionEventBase, reviewID, ruleName, justification, incidentReference string, durationSec int64) {
+	Publish(orgID, "access.break_glass_used", map[string]any{
+		"session_id":         base.SessionID,
+		"user":               base.User,
+		"connection":         base.Connection,
+		"review_id":          reviewID,
+		"rule":               ruleName,
+		"justification":      justification,
+		"incident_reference": incidentReference,
+		"duration_sec":       durationSec,
+		"occurred_at":        base.OccurredAt.UTC().Format(time.RFC3339),
+	}, "review.break_glass", reviewID+":access.break_glass_used")
+}
+
+func PublishBreakGlassAcknowledged(orgID string, base SessionEventBase, reviewID, reviewerEmail, incidentReference, note string) {
+	Publish(orgID, "access.break_glass_acknowledged", map[string]any{
+		"session_id":         base.SessionID,
+		"user":               base.User,
+		"connection":         base.Connection,
+		"review_id":          reviewID,
+		"reviewer":           reviewerEmail,
+		"incident_reference": incidentReference,
+		"note":               note,
+		"occurred_at":        base.OccurredAt.UTC().Format(time.RFC3339),
+	}, "review.break_glass", reviewID+":access.break_glass_acknowledged")
+}
+
+func PublishCertificationStarted(orgID, runID, campaign string, reviewersGroups []string, items int, deadlineAt time.Time) {
+	Publish(orgID, "access.certification_started", map[string]any{
+		"run_id":           runID,
+		"campaign":         campaign,
+		"reviewers_groups": reviewersGroups,
+		"items":            items,
+		"deadline_at":      deadlineAt.UTC().Format(time.RFC3339),
+		"occurred_at":      time.Now().UTC().Format(time.RFC3339),
+	}, "certification", runID+":access.certification_started")
+}
+
+func PublishCertificationRevoked(orgID, runID, campaign, itemID, userEmail, group string, connections []string, decision, reviewerEmail string) {
+	Publish(orgID, "access.certification_revoked", map[string]any{
+		"run_id":      runID,
+		"campaign":    campaign,
+		"user":        userEmail,
+		"group":       group,
+		"connections": connections,
+		"decision":    decision,
+		"reviewer":    reviewerEmail,
+		"occurred_at": time.Now().UTC().Format(time.RFC3339),
+	}, "certification", itemID+":access.certification_revoked")
+}
+
+func PublishCertificationCompleted(orgID, runID, campaign string, revoked int) {
+	Publish(orgID, "access.certification_completed", map[string]any{
+		"run_id":      runID,
+		"campaign":    campaign,
+		"revoked":     revoked,
+		"occurred_at": time.Now().UTC().Format(time.RFC3339),
+	}, "certification", runID+":access.certification_completed")
+}
+
 // PublishSensitiveDataDetected emits alert.sensitive_data_detected. The "types" are whatever the
 // configured DLP provider reports (Presidio entity names, GCP DLP info types, etc.) — these are
 // heuristic matches, not a guarantee that the value is regulated PII. Use PublishDataMasked when
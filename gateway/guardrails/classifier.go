@@ -0,0 +1,215 @@
+package guardrails
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/hoophq/hoopinspect"
+)
+
+// sqlOperations are the verbs an operation rule may name. A MERGE is
+// classified as an update.
+var sqlOperations = []hoopinspect.Operation{
+	hoopinspect.OpSelect, hoopinspect.OpInsert, hoopinspect.OpUpdate, hoopinspect.OpDelete,
+	hoopinspect.OpCreate, hoopinspect.OpDrop, hoopinspect.OpAlter, hoopinspect.OpTruncate,
+	hoopinspect.OpGrant, hoopinspect.OpRevoke, hoopinspect.OpCall, hoopinspect.OpShow,
+	hoopinspect.OpSet, hoopinspect.OpBegin, hoopinspect.OpCommit, hoopinspect.OpRollback,
+	hoopinspect.OpOther,
+}
+
+// IsClassifier reports whether the rule matches on what hoopinspect reads
+// from a statement instead of its raw bytes. The agent only knows how to
+// match bytes, so these rules are enforced by the gateway.
+func (r *Rule) IsClassifier() bool {
+	switch r.Type {
+	case operationType, tableType, unboundedWriteType:
+		return true
+	}
+	return false
+}
+
+// SplitClassifierRules separates the classifier rules from the rules shipped
+// to the agent, keeping each rule set in its own entry. A rule set left
+// without rules on either side is dropped.
+func SplitClassifierRules(dataRules []DataRules) (agentRules, classifierRules []DataRules) {
+	for _, dataRule := range dataRules {
+		var agentItems, classifierItems []Rule
+		for _, rule := range dataRule.Items {
+			if rule.IsClassifier() {
+				classifierItems = append(classifierItems, rule)
+				continue
+			}
+			agentItems = append(agentItems, rule)
+		}
+		if len(agentItems) > 0 {
+			agentRules = append(agentRules, DataRules{Items: agentItems})
+		}
+		if len(classifierItems) > 0 {
+			classifierRules = append(classifierRules, DataRules{Items: classifierItems})
+		}
+	}
+	return
+}
+
+// Protocol returns the dialect the statements of a connection type are
+// analyzed in. It returns false for a type whose input is not SQL the
+// classifier can read.
+func Protocol(connType pb.ConnectionType) (hoopinspect.Protocol, bool) {
+	switch connType {
+	case pb.ConnectionTypePostgres:
+		return hoopinspect.Postgres, true
+	case pb.ConnectionTypeMySQL:
+		return hoopinspect.MySQL, true
+	case pb.ConnectionTypeMSSQL:
+		return hoopinspect.MSSQL, true
+	}
+	return "", false
+}
+
+// ValidateStatement evaluates the classifier rules of dataRules against a
+// statement, or a script of statements, in the dialect of proto. Rules of
+// the other types are skipped, the agent enforces them.
+//
+// The rules fail closed: a statement the classifier could not fully analyze
+// matches every classifier rule, since the part it could not read may be the
+// one the rule exists to catch.
+func ValidateStatement(streamDirection string, dataRules []DataRules, proto hoopinspect.Protocol, statement string) error {
+	if strings.TrimSpace(statement) == "" {
+		return nil
+	}
+	var analysis *hoopinspect.SQLAnalysis
+	for _, dataRule := range dataRules {
+		for _, rule := range dataRule.Items {
+			if !rule.IsClassifier() {
+				continue
+			}
+			if analysis == nil {
+				a := hoopinspect.AnalyzeSQL(statement, proto)
+				analysis = &a
+			}
+			if err := rule.validateAnalysis(streamDirection, analysis); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Rule) validateAnalysis(streamDirection string, a *hoopinspect.SQLAnalysis) error {
+	errMatch := &ErrRuleMatch{
+		streamDirection: streamDirection,
+		ruleType:        r.Type,
+		operations:      r.Operations,
+		tables:          r.Tables,
+		rule:            *r,
+	}
+	if !a.Complete || a.Operation == hoopinspect.OpUnknown {
+		errMatch.reason = "statement could not be analyzed"
+		if a.Reason != "" {
+			errMatch.reason += ": " + a.Reason
+		}
+		return errMatch
+	}
+
+	switch r.Type {
+	case operationType:
+		for _, op := range r.Operations {
+			op := hoopinspect.Operation(strings.ToLower(op))
+			if a.Operation == op || slices.Contains(a.Effects, op) {
+				errMatch.matched = append(errMatch.matched, string(op))
+			}
+		}
+	case tableType:
+		for _, rel := range a.Relations {
+			if r.Access != "" && r.Access != string(rel.Access) {
+				continue
+			}
+			if r.matchesTable(rel.Name) {
+				errMatch.matched = append(errMatch.matched, rel.Name)
+			}
+		}
+	case unboundedWriteType:
+		for _, w := range a.UnboundedWrites {
+			if len(w.Relations) == 0 {
+				// a target the classifier could not name may be the one the
+				// rule protects
+				errMatch.matched = append(errMatch.matched, string(w.Operation))
+				continue
+			}
+			for _, name := range w.Relations {
+				if len(r.Tables) == 0 || r.matchesTable(name) {
+					errMatch.matched = append(errMatch.matched, name)
+				}
+			}
+		}
+	}
+	if len(errMatch.matched) > 0 {
+		return errMatch
+	}
+	return nil
+}
+
+// matchesTable compares a relation name reported by the classifier, which
+// is lowercased, with the tables of the rule. A name qualified on one side
+// only matches on the qualifiers both sides have: the statement may rely on
+// the search path to reach the table the rule qualifies, and a bare rule
+// name covers the table in any schema.
+func (r *Rule) matchesTable(name string) bool {
+	for _, table := range r.Tables {
+		table = strings.ToLower(table)
+		if name == table ||
+			strings.HasSuffix(name, "."+table) ||
+			strings.HasSuffix(table, "."+name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRules checks the classifier rules of an encoded rule set before it
+// is stored. Classifier rules only apply to the input of a session, and a
+// rule that could never match is refused rather than stored.
+func ValidateRules(streamDirection string, data []byte) error {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	var dataRule DataRules
+	if err := json.Unmarshal(data, &dataRule); err != nil {
+		return fmt.Errorf("unable to decode %v rules, reason=%v", streamDirection, err)
+	}
+	for _, rule := range dataRule.Items {
+		if !rule.IsClassifier() {
+			continue
+		}
+		if streamDirection != "input" {
+			return fmt.Errorf("%v rules are only supported on input", rule.Type)
+		}
+		switch rule.Type {
+		case operationType:
+			if len(rule.Operations) == 0 {
+				return fmt.Errorf("operation rule requires at least one operation")
+			}
+			for _, op := range rule.Operations {
+				if !slices.Contains(sqlOperations, hoopinspect.Operation(strings.ToLower(op))) {
+					return fmt.Errorf("operation rule has unknown operation %q", op)
+				}
+			}
+		case tableType:
+			if len(rule.Tables) == 0 {
+				return fmt.Errorf("table rule requires at least one table")
+			}
+			switch rule.Access {
+			case "", string(hoopinspect.AccessRead), string(hoopinspect.AccessWrite):
+			default:
+				return fmt.Errorf("table rule has unknown access %q, accepted values are read, write or empty for either", rule.Access)
+			}
+		}
+		if slices.Contains(rule.Tables, "") {
+			return fmt.Errorf("%v rule has an empty table name", rule.Type)
+		}
+	}
+	return nil
+}
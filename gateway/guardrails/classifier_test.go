@@ -0,0 +1,228 @@
+package guardrails
+
+import (
+	"testing"
+
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/hoophq/hoopinspect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifierRules(t *testing.T) {
+	for _, tt := range []struct {
+		msg       string
+		rule      Rule
+		statement string
+		matched   []string
+		err       string
+	}{
+		{
+			msg:       "it should not match an operation inside a string literal",
+			rule:      Rule{Type: operationType, Operations: []string{"delete"}},
+			statement: "SELECT 'DELETE FROM customers'",
+		},
+		{
+			msg:       "it should match a delete inside a common table expression",
+			rule:      Rule{Type: operationType, Operations: []string{"DELETE"}},
+			statement: "WITH d AS (DELETE FROM customers RETURNING *) SELECT * FROM d",
+			matched:   []string{"delete"},
+			err:       "validation error, match guard rails input rule, type=operation, operations=[DELETE]",
+		},
+		{
+			msg:       "it should match any statement of a script",
+			rule:      Rule{Type: operationType, Operations: []string{"drop"}},
+			statement: "SELECT 1; DROP TABLE orders;",
+			matched:   []string{"drop"},
+			err:       "validation error, match guard rails input rule, type=operation, operations=[drop]",
+		},
+		{
+			msg:       "it should match a schema qualified table",
+			rule:      Rule{Type: tableType, Tables: []string{"Customers"}},
+			statement: "SELECT * FROM public.customers",
+			matched:   []string{"public.customers"},
+			err:       "validation error, match guard rails input rule, type=table, tables=[Customers]",
+		},
+		{
+			msg:       "it should match a schema qualified rule on an unqualified table",
+			rule:      Rule{Type: tableType, Tables: []string{"public.customers"}},
+			statement: "SELECT * FROM customers",
+			matched:   []string{"customers"},
+			err:       "validation error, match guard rails input rule, type=table, tables=[public.customers]",
+		},
+		{
+			msg:       "it should match an unqualified rule on a schema qualified table",
+			rule:      Rule{Type: tableType, Tables: []string{"customers"}, Access: "write"},
+			statement: "DELETE FROM sales.customers WHERE id = 1",
+			matched:   []string{"sales.customers"},
+			err:       "validation error, match guard rails input rule, type=table, tables=[customers]",
+		},
+		{
+			msg:       "it should not match a table of another schema",
+			rule:      Rule{Type: tableType, Tables: []string{"public.customers"}},
+			statement: "SELECT * FROM sales.customers",
+		},
+		{
+			msg:       "it should not match a read on a write table rule",
+			rule:      Rule{Type: tableType, Tables: []string{"customers"}, Access: "write"},
+			statement: "INSERT INTO staging SELECT * FROM customers",
+		},
+		{
+			msg:       "it should match a write on a write table rule",
+			rule:      Rule{Type: tableType, Tables: []string{"customers"}, Access: "write"},
+			statement: "UPDATE customers SET name = 'x' WHERE id = 1",
+			matched:   []string{"customers"},
+			err:       "validation error, match guard rails input rule, type=table, tables=[customers]",
+		},
+		{
+			msg:       "it should match a delete without where",
+			rule:      Rule{Type: unboundedWriteType},
+			statement: "DELETE FROM orders",
+			matched:   []string{"orders"},
+			err:       "validation error, match guard rails input rule, type=unbounded_write",
+		},
+		{
+			msg:       "it should match an update filtered by a constant",
+			rule:      Rule{Type: unboundedWriteType, Tables: []string{"orders"}},
+			statement: "UPDATE orders SET status = 'x' WHERE 1=1",
+			matched:   []string{"orders"},
+			err:       "validation error, match guard rails input rule, type=unbounded_write, tables=[orders]",
+		},
+		{
+			msg:       "it should match an unbounded write on an unqualified table of a qualified rule",
+			rule:      Rule{Type: unboundedWriteType, Tables: []string{"public.customers"}},
+			statement: "DELETE FROM customers",
+			matched:   []string{"customers"},
+			err:       "validation error, match guard rails input rule, type=unbounded_write, tables=[public.customers]",
+		},
+		{
+			msg:       "it should match an unbounded write on a qualified table of an unqualified rule",
+			rule:      Rule{Type: unboundedWriteType, Tables: []string{"customers"}},
+			statement: "UPDATE public.customers SET name = 'x'",
+			matched:   []string{"public.customers"},
+			err:       "validation error, match guard rails input rule, type=unbounded_write, tables=[customers]",
+		},
+		{
+			msg:       "it should not match a filtered delete",
+			rule:      Rule{Type: unboundedWriteType},
+			statement: "DELETE FROM orders WHERE id = 1",
+		},
+		{
+			msg:       "it should not match an unbounded write on other tables",
+			rule:      Rule{Type: unboundedWriteType, Tables: []string{"customers"}},
+			statement: "DELETE FROM orders",
+		},
+		{
+			msg:       "it should fail closed on a statement it can't classify",
+			rule:      Rule{Type: tableType, Tables: []string{"customers"}},
+			statement: `\d customers`,
+			err:       "validation error, match guard rails input rule, type=table, reason=statement could not be analyzed",
+		},
+		{
+			msg:       "it should skip empty statements",
+			rule:      Rule{Type: unboundedWriteType},
+			statement: "  ",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			dataRules := []DataRules{{Items: []Rule{tt.rule}}}
+			err := ValidateStatement("input", dataRules, hoopinspect.Postgres, tt.statement)
+			if tt.err == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+			errMatch, ok := err.(*ErrRuleMatch)
+			if assert.True(t, ok) {
+				assert.Equal(t, "input", errMatch.Direction())
+				assert.Equal(t, tt.rule.Type, errMatch.Rule().Type)
+				assert.Equal(t, tt.matched, errMatch.Matched())
+			}
+		})
+	}
+}
+
+func TestClassifierRulesSkipRawRules(t *testing.T) {
+	dataRules := []DataRules{{Items: []Rule{{Type: denyWordListType, Words: []string{"customers"}}}}}
+	assert.Nil(t, ValidateStatement("input", dataRules, hoopinspect.Postgres, "SELECT * FROM customers"))
+	assert.Nil(t, Validate("input", []byte(`[{"rules":[{"type":"operation","operations":["select"]}]}]`),
+		[]byte("SELECT * FROM customers")))
+}
+
+func TestSplitClassifierRules(t *testing.T) {
+	dataRules := []DataRules{
+		{Items: []Rule{
+			{Type: denyWordListType, Words: []string{"SECRET"}},
+			{Type: operationType, Operations: []string{"drop"}},
+		}},
+		{Items: []Rule{{Type: unboundedWriteType}}},
+		{Items: []Rule{}},
+	}
+	agentRules, classifierRules := SplitClassifierRules(dataRules)
+	assert.Equal(t, []DataRules{{Items: []Rule{{Type: denyWordListType, Words: []string{"SECRET"}}}}}, agentRules)
+	assert.Equal(t, []DataRules{
+		{Items: []Rule{{Type: operationType, Operations: []string{"drop"}}}},
+		{Items: []Rule{{Type: unboundedWriteType}}},
+	}, classifierRules)
+}
+
+func TestProtocol(t *testing.T) {
+	proto, ok := Protocol(pb.ConnectionTypeMSSQL)
+	assert.True(t, ok)
+	assert.Equal(t, hoopinspect.MSSQL, proto)
+
+	_, ok = Protocol(pb.ConnectionTypeMongoDB)
+	assert.False(t, ok)
+}
+
+func TestValidateRules(t *testing.T) {
+	for _, tt := range []struct {
+		msg       string
+		direction string
+		data      string
+		err       string
+	}{
+		{
+			msg:       "it should accept classifier rules on input",
+			direction: "input",
+			data:      `{"rules":[{"type":"operation","operations":["delete","DROP"]},{"type":"table","tables":["customers"],"access":"write"},{"type":"unbounded_write"}]}`,
+		},
+		{
+			msg:       "it should ignore raw rules",
+			direction: "output",
+			data:      `{"rules":[{"type":"deny_words_list","words":["SECRET"]}]}`,
+		},
+		{
+			msg:       "it should refuse classifier rules on output",
+			direction: "output",
+			data:      `{"rules":[{"type":"unbounded_write"}]}`,
+			err:       "unbounded_write rules are only supported on input",
+		},
+		{
+			msg:       "it should refuse unknown operations",
+			direction: "input",
+			data:      `{"rules":[{"type":"operation","operations":["erase"]}]}`,
+			err:       `operation rule has unknown operation "erase"`,
+		},
+		{
+			msg:       "it should refuse a table rule without tables",
+			direction: "input",
+			data:      `{"rules":[{"type":"table","access":"read"}]}`,
+			err:       "table rule requires at least one table",
+		},
+		{
+			msg:       "it should refuse an unknown access",
+			direction: "input",
+			data:      `{"rules":[{"type":"table","tables":["customers"],"access":"delete"}]}`,
+			err:       `table rule has unknown access "delete", accepted values are read, write or empty for either`,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			err := ValidateRules(tt.direction, []byte(tt.data))
+			if tt.err == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
@@ -11,6 +11,12 @@ import (
 const (
 	denyWordListType      string = "deny_words_list"
 	patternMatchRegexType string = "pattern_match"
+
+	// The classifier rule types match on what hoopinspect.AnalyzeSQL reads
+	// from the statement rather than on its raw bytes, see classifier.go.
+	operationType      string = "operation"
+	tableType          string = "table"
+	unboundedWriteType string = "unbounded_write"
 )
 
 type ErrRuleMatch struct {
@@ -18,9 +24,20 @@ type ErrRuleMatch struct {
 	ruleType        string
 	words           []string
 	patternRegex    string
+	operations      []string
+	tables          []string
+	// reason is set when a classifier rule failed closed on a statement it
+	// could not analyze
+	reason  string
+	rule    Rule
+	matched []string
 }
 
 func (e ErrRuleMatch) Error() string {
+	if e.reason != "" {
+		return fmt.Sprintf("validation error, match guard rails %v rule, type=%v, reason=%v",
+			e.streamDirection, e.ruleType, e.reason)
+	}
 	switch e.ruleType {
 	case denyWordListType:
 		return fmt.Sprintf("validation error, match guard rails %v rule, type=%v, words=%v",
@@ -28,10 +45,28 @@ func (e ErrRuleMatch) Error() string {
 	case patternMatchRegexType:
 		return fmt.Sprintf("validation error, match guard rails %v rule, type=%v, pattern=%v",
 			e.streamDirection, e.ruleType, e.patternRegex)
+	case operationType:
+		return fmt.Sprintf("validation error, match guard rails %v rule, type=%v, operations=%v",
+			e.streamDirection, e.ruleType, e.operations)
+	case tableType, unboundedWriteType:
+		if len(e.tables) > 0 {
+			return fmt.Sprintf("validation error, match guard rails %v rule, type=%v, tables=%v",
+				e.streamDirection, e.ruleType, e.tables)
+		}
 	}
 	return fmt.Sprintf("validation error, match guard rails %v rule, type=%v", e.streamDirection, e.ruleType)
 }
 
+// Direction returns the stream direction of the matched rule, input or output.
+func (e ErrRuleMatch) Direction() string { return e.streamDirection }
+
+// Rule returns the rule entry that matched.
+func (e ErrRuleMatch) Rule() Rule { return e.rule }
+
+// Matched returns what in the statement matched a classifier rule: the
+// operations or the tables. It is empty when the rule failed closed.
+func (e ErrRuleMatch) Matched() []string { return e.matched }
+
 type DataRules struct {
 	Items []Rule `json:"rules"`
 }
@@ -40,6 +75,16 @@ type Rule struct {
 	Type         string   `json:"type"`
 	Words        []string `json:"words"`
 	PatternRegex string   `json:"pattern_regex"`
+	// Operations lists the statement verbs an operation rule denies, as
+	// hoopinspect classifies them: select, insert, update, delete, drop ...
+	Operations []string `json:"operations,omitempty"`
+	// Tables lists the relations a table rule denies, and narrows an
+	// unbounded_write rule to those targets. Names are compared lowercased,
+	// a bare name matches any schema qualification and a qualified name also
+	// matches the table referenced unqualified.
+	Tables []string `json:"tables,omitempty"`
+	// Access narrows a table rule to "read" or "write". Empty matches either.
+	Access string `json:"access,omitempty"`
 	// Message is an optional, admin-defined message shown to the user when this
 	// specific rule entry is hit. When empty, the generic validation message is used.
 	Message string `json:"message"`
@@ -56,7 +101,7 @@ func (r *Rule) validate(streamDirection string, data []byte) error {
 			if !strings.Contains(string(data), word) {
 				continue
 			}
-			return &ErrRuleMatch{streamDirection: streamDirection, ruleType: r.Type, words: r.Words}
+			return &ErrRuleMatch{streamDirection: streamDirection, ruleType: r.Type, words: r.Words, rule: *r}
 		}
 	case patternMatchRegexType:
 		// skip empty regex
@@ -68,8 +113,11 @@ func (r *Rule) validate(streamDirection string, data []byte) error {
 			return fmt.Errorf("failed parsing regex, reason=%v", err)
 		}
 		if regex.Match(data) {
-			return &ErrRuleMatch{streamDirection: streamDirection, ruleType: r.Type, patternRegex: r.PatternRegex}
+			return &ErrRuleMatch{streamDirection: streamDirection, ruleType: r.Type, patternRegex: r.PatternRegex, rule: *r}
 		}
+	case operationType, tableType, unboundedWriteType:
+		// classifier rules need the dialect of the connection to read the
+		// statement, they are evaluated by ValidateStatement
 	default:
 		return fmt.Errorf("unknown rule type %q", r.Type)
 	}
@@ -107,6 +107,33 @@ func LoadServerAuthConfig() (*models.ServerAuthConfig, idptypes.ProviderType, er
 	}
 }
 
+// SyncsGroups reports whether logins through the configured provider sync
+// the group memberships of users: an oidc provider with a groups claim or
+// Google Workspace groups, or a saml provider with a groups claim. A group
+// removed in the gateway comes back on the next login of a synced user.
+func SyncsGroups() (bool, error) {
+	serverAuthConfig, providerType, err := LoadServerAuthConfig()
+	if err != nil {
+		return false, err
+	}
+	return syncsGroups(serverAuthConfig, providerType)
+}
+
+func syncsGroups(serverAuthConfig *models.ServerAuthConfig, providerType idptypes.ProviderType) (bool, error) {
+	switch providerType {
+	case idptypes.ProviderTypeOIDC, idptypes.ProviderTypeIDP:
+		opts, err := NewOidcProviderOptions(serverAuthConfig)
+		if err != nil {
+			return false, err
+		}
+		return opts.SyncsGroups(), nil
+	case idptypes.ProviderTypeSAML:
+		return serverAuthConfig != nil && serverAuthConfig.SamlConfig != nil &&
+			serverAuthConfig.SamlConfig.GroupsClaim != "", nil
+	}
+	return false, nil
+}
+
 type userInfoTokenVerifier struct {
 	UserInfoTokenVerifier
 	serverConfig            idptypes.ServerConfig
@@ -350,3 +350,85 @@ func TestUserInfoTokenVerifier_hasServerConfigChanged(t *testing.T) {
 		})
 	})
 }
+
+func TestSyncsGroups(t *testing.T) {
+	t.Setenv("IDP_URI", "")
+	t.Setenv("IDP_ISSUER", "")
+	const gsuiteScope = "https://www.googleapis.com/auth/cloud-identity.groups.readonly"
+	tests := []struct {
+		name             string
+		providerType     idptypes.ProviderType
+		serverAuthConfig *models.ServerAuthConfig
+		want             bool
+	}{
+		{
+			name:         "local auth",
+			providerType: idptypes.ProviderTypeLocal,
+		},
+		{
+			name:         "oidc with a groups claim",
+			providerType: idptypes.ProviderTypeOIDC,
+			serverAuthConfig: &models.ServerAuthConfig{
+				OidcConfig: &models.ServerAuthOidcConfig{IssuerURL: "https://idp.example.com", GroupsClaim: "groups"},
+			},
+			want: true,
+		},
+		{
+			name:         "oidc without a groups claim",
+			providerType: idptypes.ProviderTypeOIDC,
+			serverAuthConfig: &models.ServerAuthConfig{
+				OidcConfig: &models.ServerAuthOidcConfig{IssuerURL: "https://idp.example.com"},
+			},
+		},
+		{
+			name:         "oidc syncing google workspace groups",
+			providerType: idptypes.ProviderTypeOIDC,
+			serverAuthConfig: &models.ServerAuthConfig{
+				OidcConfig: &models.ServerAuthOidcConfig{IssuerURL: "https://accounts.google.com", Scopes: []string{gsuiteScope}},
+			},
+			want: true,
+		},
+		{
+			name:         "oidc with the google workspace scope on another issuer",
+			providerType: idptypes.ProviderTypeOIDC,
+			serverAuthConfig: &models.ServerAuthConfig{
+				OidcConfig: &models.ServerAuthOidcConfig{IssuerURL: "https://idp.example.com", Scopes: []string{gsuiteScope}},
+			},
+		},
+		{
+			name:         "saml with a groups claim",
+			providerType: idptypes.ProviderTypeSAML,
+			serverAuthConfig: &models.ServerAuthConfig{
+				SamlConfig: &models.ServerAuthSamlConfig{IdpMetadataURL: "https://idp.example.com/metadata", GroupsClaim: "memberOf"},
+			},
+			want: true,
+		},
+		{
+			name:         "saml without a groups claim",
+			providerType: idptypes.ProviderTypeSAML,
+			serverAuthConfig: &models.ServerAuthConfig{
+				SamlConfig: &models.ServerAuthSamlConfig{IdpMetadataURL: "https://idp.example.com/metadata"},
+			},
+		},
+		{
+			name:         "saml without a config",
+			providerType: idptypes.ProviderTypeSAML,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := syncsGroups(tt.serverAuthConfig, tt.providerType)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("oidc configured by env keeps the default groups claim", func(t *testing.T) {
+		t.Setenv("IDP_ISSUER", "https://idp.example.com")
+		t.Setenv("IDP_GROUPS_CLAIM", "")
+		got, err := syncsGroups(nil, idptypes.ProviderTypeOIDC)
+		assert.NoError(t, err)
+		assert.True(t, got)
+	})
+}
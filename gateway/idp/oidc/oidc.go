@@ -108,10 +108,21 @@ func New(opts Options) (*Provider, error) {
 	p.oidcProvider = oidcProvider
 	p.idTokenVerifier = oidcProvider.Verifier(oidcConfig)
 	p.jwks = jwksKeyFunc
-	p.mustFetchGsuiteGroups = p.IssuerURL == googleIssuerURL && slices.Contains(scopes, gsuiteGroupsScope)
+	p.mustFetchGsuiteGroups = mustFetchGsuiteGroups(p.IssuerURL, scopes)
 	return p, nil
 }
 
+// SyncsGroups reports whether a login replaces the groups of the user with
+// the ones the provider asserts, read from the groups claim of the id token
+// or fetched from Google Workspace when its groups scope is requested.
+func (o Options) SyncsGroups() bool {
+	return o.GroupsClaim != "" || mustFetchGsuiteGroups(o.IssuerURL, addCustomScopes(nil, o.CustomScopes))
+}
+
+func mustFetchGsuiteGroups(issuerURL string, scopes []string) bool {
+	return issuerURL == googleIssuerURL && slices.Contains(scopes, gsuiteGroupsScope)
+}
+
 func ParseOptionsFromEnv() (p Options, err error) {
 	if idpURI := os.Getenv("IDP_URI"); idpURI != "" {
 		u, err := url.Parse(idpURI)
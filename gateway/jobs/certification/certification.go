@@ -0,0 +1,88 @@
+// Package certification runs the schedule of certification campaigns: it
+// starts the runs that came due and closes the runs past their deadline,
+// revoking the group memberships nobody attested.
+//
+// As in the reviewsla job, every step is claimed in the database, so
+// replicas sweep side by side without starting or closing a run twice, and a
+// gateway that was down catches up on its first sweep. The revocations are
+// part of the claim; only the notifications that follow it may be lost.
+package certification
+
+import (
+	"context"
+	"time"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/gateway/idp"
+	"github.com/hoophq/hoop/gateway/models"
+	"github.com/hoophq/hoop/gateway/services"
+	"gorm.io/gorm"
+)
+
+const (
+	// sweepInterval bounds how late a run starts or closes. Campaigns are
+	// scheduled in days, so a minute is well inside their resolution.
+	sweepInterval = time.Minute
+
+	// sweepTimeout bounds the claims of a single tick. Starting a run
+	// snapshots every membership in its scope in the same transaction.
+	sweepTimeout = 50 * time.Second
+
+	// sweepBatchSize caps the campaigns started and the runs closed per tick.
+	sweepBatchSize = 10
+)
+
+// Run sweeps once immediately, then every sweepInterval until ctx is done.
+func Run(ctx context.Context, db *gorm.DB) {
+	sweep(ctx, db)
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweep(ctx, db)
+		}
+	}
+}
+
+func sweep(ctx context.Context, db *gorm.DB) {
+	now := time.Now().UTC()
+	claimCtx, cancel := context.WithTimeout(ctx, sweepTimeout)
+	defer cancel()
+
+	// Close first, so a run due to close is never left open behind a batch
+	// of campaigns to start.
+	completed, err := models.CompleteDueCertificationRuns(claimCtx, db, now, sweepBatchSize)
+	if err != nil {
+		log.Errorf("failed completing certification runs, reason=%v", err)
+	}
+	// the items record whether the identity provider syncs the groups, so
+	// without the auth config the campaigns wait for the next sweep instead.
+	var started []models.CertificationRun
+	idpManagedGroups, err := idp.SyncsGroups()
+	if err != nil {
+		log.Errorf("failed loading server auth config, skipping the start of certification runs, reason=%v", err)
+	} else {
+		started, err = models.StartDueCertificationRuns(claimCtx, db, now, sweepBatchSize, idpManagedGroups)
+		if err != nil {
+			log.Errorf("failed starting certification runs, reason=%v", err)
+		}
+	}
+	if n := len(completed) + len(started); n > 0 {
+		log.Infof("certification runs claimed, completed=%v, started=%v", len(completed), len(started))
+	}
+
+	for _, run := range completed {
+		services.NotifyCertificationCompleted(&run.CertificationRun, run.Revoked)
+	}
+	for _, run := range started {
+		tally, err := models.CountCertificationDecisions(db, run.ID)
+		if err != nil {
+			log.With("run", run.ID).Warnf("failed counting certification items, reason=%v", err)
+		}
+		services.NotifyCertificationStarted(&run, tally.Total())
+	}
+}
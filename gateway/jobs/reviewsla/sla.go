@@ -0,0 +1,260 @@
+// Package reviewsla runs the approval SLA of access request rules: it
+// reminds the reviewers of a review left pending, escalates it to the rule's
+// escalation groups and finally expires it.
+//
+// The deadlines live on the review itself (see
+// models.AccessRequestRule.ApplyReviewSLA), so nothing is kept in memory: a
+// gateway that restarts picks up every step that came due while it was down
+// on its first sweep, and editing a rule never moves the deadlines of reviews
+// already waiting on it.
+//
+// Each step is claimed in the database before anybody is notified. Replicas
+// sweep side by side and take disjoint rows, and a step is never run twice;
+// the price is that a gateway dying between the claim and the notification
+// loses that one reminder or escalation message. The state change itself
+// (new review groups, the EXPIRED status) is part of the claim and is never
+// lost.
+package reviewsla
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/smithy-go/ptr"
+	"github.com/hoophq/hoop/common/log"
+	reviewapi "github.com/hoophq/hoop/gateway/api/review"
+	"github.com/hoophq/hoop/gateway/appconfig"
+	"github.com/hoophq/hoop/gateway/events"
+	"github.com/hoophq/hoop/gateway/models"
+	slackservice "github.com/hoophq/hoop/gateway/slack"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+	"github.com/hoophq/hoop/gateway/transport/plugins/webhooks"
+	"gorm.io/gorm"
+)
+
+const (
+	// sweepInterval bounds how late a step may run after its deadline. Rule
+	// deadlines are set in minutes, so thirty seconds is well inside the
+	// resolution anybody configures.
+	sweepInterval = 30 * time.Second
+
+	// sweepTimeout bounds the claims of a single tick. Notifications run
+	// after the claims commit and are not bound by it: Slack allows one post
+	// per second per channel, so a batch of escalations may take longer than
+	// the interval, and the ticker simply drops the ticks it missed.
+	sweepTimeout = 20 * time.Second
+
+	// sweepBatchSize caps the rows each step claims per tick, which keeps a
+	// backlog left by a long outage from turning into one burst of Slack
+	// posts that trips its rate limits.
+	sweepBatchSize = 50
+)
+
+// Run sweeps once immediately, then every sweepInterval until ctx is done.
+// release closes the client waiting on an expired review, when that client
+// is connected to this gateway.
+func Run(ctx context.Context, db *gorm.DB, release reviewapi.TransportReleaseConnectionFunc) {
+	sweep(ctx, db, release)
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweep(ctx, db, release)
+		}
+	}
+}
+
+func sweep(ctx context.Context, db *gorm.DB, release reviewapi.TransportReleaseConnectionFunc) {
+	now := time.Now().UTC()
+	claimCtx, cancel := context.WithTimeout(ctx, sweepTimeout)
+	defer cancel()
+
+	// Expire first: every claim only takes pending reviews, so a review due
+	// for several steps at once (the gateway was down) gets no reminder or
+	// escalation once it is past its final deadline.
+	expired, err := models.ExpirePendingReviews(claimCtx, db, now, sweepBatchSize)
+	if err != nil {
+		log.Errorf("failed expiring pending reviews, reason=%v", err)
+	}
+	escalated, err := models.ClaimReviewEscalations(claimCtx, db, now, sweepBatchSize)
+	if err != nil {
+		log.Errorf("failed claiming review escalations, reason=%v", err)
+	}
+	reminded, err := models.ClaimReviewReminders(claimCtx, db, now, sweepBatchSize)
+	if err != nil {
+		log.Errorf("failed claiming review reminders, reason=%v", err)
+	}
+	if n := len(expired) + len(escalated) + len(reminded); n > 0 {
+		log.Infof("review sla steps claimed, expired=%v, escalated=%v, reminded=%v",
+			len(expired), len(escalated), len(reminded))
+	}
+
+	for _, step := range expired {
+		onExpired(step, release)
+	}
+	for _, step := range escalated {
+		onEscalated(step)
+	}
+	for _, step := range reminded {
+		onReminder(step)
+	}
+}
+
+func onExpired(step models.ReviewSLAStep, release reviewapi.TransportReleaseConnectionFunc) {
+	release(step.OrgID, step.SessionID, ptr.ToString(step.OwnerSlackID), models.ReviewStatusExpired.Str(), "", "")
+
+	if rev, err := models.GetReviewByIdOrSid(step.OrgID, step.ID); err != nil {
+		log.With("sid", step.SessionID).Warnf("failed fetching expired review, reason=%v", err)
+	} else if err := reviewapi.UpdateSlackMessage(rev); err != nil {
+		log.With("sid", step.SessionID).Warnf("failed updating slack review message, reason=%v", err)
+	}
+	if slackSvc := slackservice.GetServiceInstance(step.OrgID); slackSvc != nil && ptr.ToString(step.OwnerSlackID) != "" {
+		_ = slackSvc.PostMessage(*step.OwnerSlackID, expiredMessage(step))
+	}
+
+	sendWebhook(step, webhooks.EventReviewExpiredType, nil)
+	events.PublishReviewExpired(step.OrgID, events.SessionEventBase{
+		SessionID:  step.SessionID,
+		User:       step.OwnerEmail,
+		Connection: step.ConnectionName,
+		OccurredAt: time.Now().UTC(),
+	}, step.ID, ptr.ToString(step.AccessRequestRuleName))
+}
+
+func onEscalated(step models.ReviewSLAStep) {
+	sendWebhook(step, webhooks.EventReviewEscalatedType, map[string]any{
+		"escalation_groups": []string(step.EscalationGroups),
+	})
+
+	slackSvc, channels := slackChannels(step)
+	if slackSvc == nil {
+		return
+	}
+	if err := slackSvc.PostReviewNotice(channels, escalationNotice(step, time.Now().UTC())); err != nil {
+		log.With("sid", step.SessionID).Warnf("failed posting review escalation notice, reason=%v", err)
+	}
+
+	// a review message of its own, with buttons for the escalation groups only;
+	// the original message keeps the buttons of the groups it was sent to
+	sreq := &slackservice.MessageReviewRequest{
+		ID:             step.ID,
+		Name:           ptr.ToString(step.OwnerName),
+		Email:          step.OwnerEmail,
+		ApprovalGroups: step.EscalationGroups,
+		Connection:     step.ConnectionName,
+		SessionID:      step.SessionID,
+		SlackChannels:  channels,
+		WebappURL:      sessionURL(step),
+	}
+	if conn, err := models.GetConnectionByOrgAndName(step.OrgID, step.ConnectionName); err == nil {
+		sreq.ConnectionType = conn.Type
+	}
+	if step.AccessDurationSec > 0 {
+		ad := time.Duration(step.AccessDurationSec) * time.Second
+		sreq.SessionTime = &ad
+	}
+	if rev, err := models.GetReviewByIdOrSid(step.OrgID, step.ID); err == nil {
+		sreq.Script, _ = rev.GetBlobInput()
+	}
+	result := slackSvc.SendMessageReview(sreq)
+	log.With("sid", step.SessionID).Infof("review escalation slack message sent, %v", result)
+}
+
+func onReminder(step models.ReviewSLAStep) {
+	sendWebhook(step, webhooks.EventReviewReminderType, nil)
+
+	slackSvc, channels := slackChannels(step)
+	if slackSvc == nil {
+		return
+	}
+	if err := slackSvc.PostReviewNotice(channels, reminderNotice(step, time.Now().UTC())); err != nil {
+		log.With("sid", step.SessionID).Warnf("failed posting review reminder, reason=%v", err)
+	}
+}
+
+// slackChannels returns the org's Slack service and the channels the
+// connection's slack plugin posts reviews to. The service is nil when the
+// connection does not review through Slack.
+func slackChannels(step models.ReviewSLAStep) (*slackservice.SlackService, []string) {
+	slackSvc := slackservice.GetServiceInstance(step.OrgID)
+	if slackSvc == nil || !step.ConnectionID.Valid {
+		return nil, nil
+	}
+	pluginConn, err := models.GetPluginConnection(step.OrgID, plugintypes.PluginSlackName, step.ConnectionID.String)
+	switch err {
+	case nil:
+		return slackSvc, pluginConn.Config
+	case models.ErrNotFound:
+		return nil, nil
+	default:
+		log.With("sid", step.SessionID).Warnf("failed fetching slack plugin connection, reason=%v", err)
+		return nil, nil
+	}
+}
+
+func sendWebhook(step models.ReviewSLAStep, eventType string, extra map[string]any) {
+	err := webhooks.SendMessage(step.OrgID, eventType, map[string]any{
+		"event_type":    eventType,
+		"event_payload": webhookPayload(step, extra),
+	})
+	if err != nil {
+		log.With("sid", step.SessionID).Warnf("failed sending %v webhook, reason=%v", eventType, err)
+	}
+}
+
+// webhookPayload describes the review of step, with the fields of extra that
+// are specific to the event.
+func webhookPayload(step models.ReviewSLAStep, extra map[string]any) map[string]any {
+	payload := map[string]any{
+		"review_id":           step.ID,
+		"session_id":          step.SessionID,
+		"user":                step.OwnerEmail,
+		"connection":          step.ConnectionName,
+		"access_request_rule": ptr.ToString(step.AccessRequestRuleName),
+		"created_at":          step.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	for k, v := range extra {
+		payload[k] = v
+	}
+	return payload
+}
+
+// expiredMessage is sent to the owner of a review when it expires.
+func expiredMessage(step models.ReviewSLAStep) string {
+	return fmt.Sprintf("Your access request to %s expired without a review.\nFollow this link to see the details: %s",
+		step.ConnectionName, sessionURL(step))
+}
+
+// escalationNotice is posted to the review channels when a review is
+// escalated, next to the review message sent to the escalation groups.
+func escalationNotice(step models.ReviewSLAStep, now time.Time) string {
+	return fmt.Sprintf("Escalated: the access request of %s to %s has waited %v for a review. Reviewers of %v may now approve it.",
+		ownerLabel(step), step.ConnectionName, waited(step, now), step.EscalationGroups)
+}
+
+// reminderNotice is posted to the review channels when a review is due for
+// its reminder.
+func reminderNotice(step models.ReviewSLAStep, now time.Time) string {
+	return fmt.Sprintf("Reminder: the access request of %s to %s has waited %v for a review.\nFollow this link to review it: %s",
+		ownerLabel(step), step.ConnectionName, waited(step, now), sessionURL(step))
+}
+
+func sessionURL(step models.ReviewSLAStep) string {
+	return fmt.Sprintf("%s/sessions/%s", appconfig.Get().ApiURL(), step.SessionID)
+}
+
+func ownerLabel(step models.ReviewSLAStep) string {
+	if name := ptr.ToString(step.OwnerName); name != "" {
+		return fmt.Sprintf("%s (%s)", name, step.OwnerEmail)
+	}
+	return step.OwnerEmail
+}
+
+func waited(step models.ReviewSLAStep, now time.Time) time.Duration {
+	return now.Sub(step.CreatedAt).Round(time.Minute)
+}
@@ -0,0 +1,79 @@
+package reviewsla
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go/ptr"
+	"github.com/hoophq/hoop/gateway/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFakeStep(ownerName *string) models.ReviewSLAStep {
+	return models.ReviewSLAStep{
+		ID:                    "review-id",
+		OrgID:                 "org-id",
+		SessionID:             "session-id",
+		ConnectionName:        "pg-prod",
+		OwnerEmail:            "drew.k@acme.com",
+		OwnerName:             ownerName,
+		AccessRequestRuleName: ptr.String("prod-approval"),
+		EscalationGroups:      []string{"sre", "cto"},
+		CreatedAt:             time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWebhookPayload(t *testing.T) {
+	step := newFakeStep(nil)
+	base := map[string]any{
+		"review_id":           "review-id",
+		"session_id":          "session-id",
+		"user":                "drew.k@acme.com",
+		"connection":          "pg-prod",
+		"access_request_rule": "prod-approval",
+		"created_at":          "2026-03-10T12:00:00Z",
+	}
+	assert.Equal(t, base, webhookPayload(step, nil))
+
+	escalated := webhookPayload(step, map[string]any{"escalation_groups": []string(step.EscalationGroups)})
+	assert.Equal(t, []string{"sre", "cto"}, escalated["escalation_groups"])
+	delete(escalated, "escalation_groups")
+	assert.Equal(t, base, escalated)
+}
+
+func TestNotifications(t *testing.T) {
+	now := time.Date(2026, 3, 10, 13, 30, 20, 0, time.UTC)
+	tests := []struct {
+		name     string
+		msg      string
+		contains []string
+	}{
+		{
+			name:     "reminder",
+			msg:      reminderNotice(newFakeStep(ptr.String("Drew K")), now),
+			contains: []string{"Reminder:", "Drew K (drew.k@acme.com)", "to pg-prod", "waited 1h30m0s", "/sessions/session-id"},
+		},
+		{
+			name:     "reminder of an owner without a name",
+			msg:      reminderNotice(newFakeStep(nil), now),
+			contains: []string{"access request of drew.k@acme.com to pg-prod"},
+		},
+		{
+			name:     "escalation",
+			msg:      escalationNotice(newFakeStep(ptr.String("Drew K")), now),
+			contains: []string{"Escalated:", "Drew K (drew.k@acme.com)", "waited 1h30m0s", "Reviewers of [sre cto] may now approve it"},
+		},
+		{
+			name:     "expiration",
+			msg:      expiredMessage(newFakeStep(nil)),
+			contains: []string{"Your access request to pg-prod expired without a review", "/sessions/session-id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, want := range tt.contains {
+				assert.Contains(t, tt.msg, want)
+			}
+		})
+	}
+}
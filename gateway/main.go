@@ -27,7 +27,9 @@ import (
 	_ "github.com/hoophq/hoop/gateway/federation/gcpiam"
 	_ "github.com/hoophq/hoop/gateway/federation/gcpoauth"
 	"github.com/hoophq/hoop/gateway/idp"
+	"github.com/hoophq/hoop/gateway/jobs/certification"
 	"github.com/hoophq/hoop/gateway/jobs/credentialsweeper"
+	"github.com/hoophq/hoop/gateway/jobs/reviewsla"
 	"github.com/hoophq/hoop/gateway/models"
 	modelsbootstrap "github.com/hoophq/hoop/gateway/models/bootstrap"
 	"github.com/hoophq/hoop/gateway/pglite"
@@ -231,6 +233,14 @@ func Run() {
 	// for every tenant on the deployment.
 	go credentialsweeper.Run(context.Background(), models.DB)
 
+	// Remind, escalate and expire reviews left pending past the approval SLA
+	// of their access request rule.
+	go reviewsla.Run(context.Background(), models.DB, g.ReleaseConnectionOnReview)
+
+	// Start the scheduled runs of certification campaigns and revoke the
+	// memberships left unattested when a run reaches its deadline.
+	go certification.Run(context.Background(), models.DB)
+
 	if grpc.ShouldDebugGrpc() {
 		log.SetGrpcLogger()
 	}
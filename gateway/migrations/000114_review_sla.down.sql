@@ -0,0 +1,24 @@
+BEGIN;
+SET search_path TO private;
+
+DROP INDEX IF EXISTS idx_reviews_pending_sla;
+
+ALTER TABLE reviews DROP COLUMN IF EXISTS expire_at;
+ALTER TABLE reviews DROP COLUMN IF EXISTS escalated_at;
+ALTER TABLE reviews DROP COLUMN IF EXISTS escalation_groups;
+ALTER TABLE reviews DROP COLUMN IF EXISTS escalate_at;
+ALTER TABLE reviews DROP COLUMN IF EXISTS reminder_sent_at;
+ALTER TABLE reviews DROP COLUMN IF EXISTS remind_at;
+
+ALTER TABLE access_request_rules DROP COLUMN IF EXISTS expiration_after_minutes;
+ALTER TABLE access_request_rules DROP COLUMN IF EXISTS escalation_groups;
+ALTER TABLE access_request_rules DROP COLUMN IF EXISTS escalation_after_minutes;
+ALTER TABLE access_request_rules DROP COLUMN IF EXISTS reminder_after_minutes;
+
+-- Postgres cannot drop an enum label, so EXPIRED stays in the type. Expired
+-- reviews are folded into REJECTED, the closest status the previous binary
+-- understands: the session was refused either way.
+UPDATE review_groups SET status = 'REJECTED' WHERE status = 'EXPIRED';
+UPDATE reviews SET status = 'REJECTED' WHERE status = 'EXPIRED';
+
+COMMIT;
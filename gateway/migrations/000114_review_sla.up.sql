@@ -0,0 +1,36 @@
+BEGIN;
+SET search_path TO private;
+
+-- A review that nobody answers used to stay PENDING forever. EXPIRED is where
+-- the reviewsla job moves it once its deadline passes. Adding a label inside
+-- a transaction is fine as long as nothing here uses it before COMMIT.
+ALTER TYPE enum_reviews_status ADD VALUE IF NOT EXISTS 'EXPIRED';
+
+-- Approval SLA of an access request rule, in minutes from the review's
+-- creation. Each step is optional; NULL disables it.
+ALTER TABLE access_request_rules ADD COLUMN IF NOT EXISTS reminder_after_minutes INT NULL;
+ALTER TABLE access_request_rules ADD COLUMN IF NOT EXISTS escalation_after_minutes INT NULL;
+ALTER TABLE access_request_rules ADD COLUMN IF NOT EXISTS escalation_groups TEXT[] NULL;
+ALTER TABLE access_request_rules ADD COLUMN IF NOT EXISTS expiration_after_minutes INT NULL;
+
+-- The deadlines are resolved when the review is created, so editing the rule
+-- later never moves the deadline of a review already waiting, and the job
+-- reads one table. The *_sent_at / escalated_at columns record that a step
+-- ran, which is what keeps a step from repeating across ticks, replicas and
+-- restarts.
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS remind_at TIMESTAMP NULL;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMP NULL;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS escalate_at TIMESTAMP NULL;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS escalation_groups TEXT[] NULL;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMP NULL;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS expire_at TIMESTAMP NULL;
+
+-- Supports the reviewsla job's tick queries. Only pending reviews carrying a
+-- deadline are indexed, which is a handful of rows at any time: a review
+-- leaves the index as soon as anyone decides it or the job expires it.
+CREATE INDEX IF NOT EXISTS idx_reviews_pending_sla
+    ON reviews (created_at)
+    WHERE status = 'PENDING'
+    AND (remind_at IS NOT NULL OR escalate_at IS NOT NULL OR expire_at IS NOT NULL);
+
+COMMIT;
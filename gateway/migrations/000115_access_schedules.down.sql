@@ -0,0 +1,9 @@
+BEGIN;
+SET search_path TO private;
+
+ALTER TABLE review_groups DROP COLUMN IF EXISTS access_schedule_name;
+ALTER TABLE reviews DROP COLUMN IF EXISTS access_schedule_names;
+DROP TABLE IF EXISTS access_schedules_attributes;
+DROP TABLE IF EXISTS access_schedules;
+
+COMMIT;
@@ -0,0 +1,49 @@
+BEGIN;
+SET search_path TO private;
+
+-- Access schedules restrict when connections may be used. A schedule with
+-- recurring_windows only allows access inside them (business hours); its
+-- freeze_periods deny access inside them (change freezes). Both are read in
+-- the schedule's time zone. verbs narrows the schedule to connect, exec
+-- and/or runbooks; empty applies it to all of them.
+CREATE TABLE IF NOT EXISTS access_schedules(
+    id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES orgs(id),
+
+    name VARCHAR(254) NOT NULL,
+    description TEXT,
+
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    verbs TEXT[] NOT NULL DEFAULT '{}',
+    connection_names TEXT[] NOT NULL DEFAULT '{}',
+
+    recurring_windows JSONB NOT NULL DEFAULT '[]',
+    freeze_periods JSONB NOT NULL DEFAULT '[]',
+
+    -- groups that may approve a review overriding the schedule; empty means
+    -- the schedule cannot be overridden
+    override_reviewers_groups TEXT[] NOT NULL DEFAULT '{}',
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_access_schedules_org_name ON access_schedules(org_id, name);
+
+CREATE TABLE IF NOT EXISTS access_schedules_attributes (
+    org_id UUID NOT NULL,
+    access_schedule_name VARCHAR(254) NOT NULL,
+    attribute_name VARCHAR(255) NOT NULL,
+    PRIMARY KEY (org_id, access_schedule_name, attribute_name),
+    FOREIGN KEY (org_id, access_schedule_name) REFERENCES access_schedules(org_id, name) ON UPDATE CASCADE ON DELETE CASCADE,
+    FOREIGN KEY (org_id, attribute_name) REFERENCES attributes(org_id, name) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+-- set on reviews of sessions one or more schedules denied, so the transport
+-- only accepts them as the override of every one of these schedules
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS access_schedule_names TEXT[] NULL;
+-- the schedule a review group approves overriding; null for the groups of the
+-- access request rule the review also waits on
+ALTER TABLE review_groups ADD COLUMN IF NOT EXISTS access_schedule_name TEXT NULL;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+SET search_path TO private;
+
+DROP TABLE IF EXISTS review_break_glass;
+
+ALTER TABLE access_request_rules DROP COLUMN IF EXISTS break_glass_max_duration;
+ALTER TABLE access_request_rules DROP COLUMN IF EXISTS break_glass_groups;
+
+COMMIT;
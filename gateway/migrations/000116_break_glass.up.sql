@@ -0,0 +1,34 @@
+BEGIN;
+SET search_path TO private;
+
+-- Members of break_glass_groups may skip the review of a rule during an
+-- incident. Access is granted at once for at most break_glass_max_duration
+-- seconds and the rule's reviewers acknowledge it afterwards.
+ALTER TABLE access_request_rules ADD COLUMN IF NOT EXISTS break_glass_groups TEXT[] NULL;
+ALTER TABLE access_request_rules ADD COLUMN IF NOT EXISTS break_glass_max_duration INT NULL;
+
+-- One row per break-glass review. It is the retrospective review: it holds
+-- what the user declared when breaking glass and who acknowledged it, and it
+-- is what the break-glass report reads.
+CREATE TABLE review_break_glass(
+    review_id UUID PRIMARY KEY REFERENCES reviews (id) ON DELETE CASCADE,
+    org_id UUID NOT NULL REFERENCES orgs (id),
+    session_id UUID NOT NULL,
+    connection_name VARCHAR(128) NOT NULL,
+    access_request_rule_name TEXT NULL,
+    owner_email TEXT NOT NULL,
+
+    justification TEXT NOT NULL,
+    incident_reference TEXT NOT NULL,
+    reviewers_groups TEXT[] NULL,
+
+    acknowledged_at TIMESTAMP NULL,
+    acknowledged_by TEXT NULL,
+    acknowledgement_note TEXT NULL,
+
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_review_break_glass_org_created ON review_break_glass (org_id, created_at);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+SET search_path TO private;
+
+DROP TABLE IF EXISTS certification_items;
+DROP TABLE IF EXISTS certification_runs;
+DROP TABLE IF EXISTS certification_campaigns;
+
+COMMIT;
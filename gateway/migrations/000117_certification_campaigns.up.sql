@@ -0,0 +1,83 @@
+BEGIN;
+SET search_path TO private;
+
+-- A certification campaign re-validates group memberships, the entitlements
+-- that grant access to connections (through the access control plugin and
+-- attributes) and the right to review access requests. Each run snapshots the
+-- memberships in scope as items that the campaign's reviewers attest to keep
+-- or revoke before the run's deadline; an item left unanswered is revoked.
+CREATE TABLE IF NOT EXISTS certification_campaigns(
+    id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES orgs(id),
+
+    name VARCHAR(254) NOT NULL,
+    description TEXT,
+
+    -- groups whose memberships are certified; empty certifies every group
+    -- that grants a connection or reviews an access request rule
+    group_names TEXT[] NOT NULL DEFAULT '{}',
+    reviewers_groups TEXT[] NOT NULL DEFAULT '{}',
+
+    -- days a run stays open for attestations
+    duration_days INT NOT NULL DEFAULT 14,
+    -- days between scheduled runs; NULL runs only when started by hand
+    recurrence_days INT NULL,
+    next_run_at TIMESTAMP NULL,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_certification_campaigns_org_name ON certification_campaigns(org_id, name);
+CREATE INDEX IF NOT EXISTS idx_certification_campaigns_next_run ON certification_campaigns(next_run_at) WHERE next_run_at IS NOT NULL;
+
+-- Runs and their items are the audit evidence, so they outlive the campaign:
+-- the campaign's name and reviewers are copied into the run when it starts.
+CREATE TABLE IF NOT EXISTS certification_runs(
+    id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES orgs(id),
+    campaign_id UUID NULL REFERENCES certification_campaigns(id) ON DELETE SET NULL,
+    campaign_name TEXT NOT NULL,
+    reviewers_groups TEXT[] NOT NULL DEFAULT '{}',
+
+    -- open or completed
+    status VARCHAR(32) NOT NULL DEFAULT 'open',
+    -- the user who started the run; NULL when the scheduler did
+    started_by TEXT NULL,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deadline_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_certification_runs_org_started ON certification_runs(org_id, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_certification_runs_open_deadline ON certification_runs(deadline_at) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS certification_items(
+    id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES orgs(id),
+    run_id UUID NOT NULL REFERENCES certification_runs(id) ON DELETE CASCADE,
+
+    user_id UUID NULL,
+    user_email TEXT NOT NULL,
+    group_name TEXT NOT NULL,
+    -- what the membership granted when the run started
+    connection_names TEXT[] NOT NULL DEFAULT '{}',
+    access_request_rules TEXT[] NOT NULL DEFAULT '{}',
+    -- the identity provider syncs the membership on login, so the gateway
+    -- leaves its revocation to the provider and only records the decision
+    idp_managed BOOLEAN NOT NULL DEFAULT FALSE,
+
+    -- pending, keep, revoke or no_response
+    decision VARCHAR(32) NOT NULL DEFAULT 'pending',
+    decided_by TEXT NULL,
+    decided_at TIMESTAMP NULL,
+    note TEXT NULL,
+    -- set once the membership is removed
+    revoked_at TIMESTAMP NULL,
+
+    UNIQUE(run_id, user_id, group_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_certification_items_run ON certification_items(run_id, decision);
+
+COMMIT;
@@ -43,6 +43,18 @@ type AccessRequestRule struct {
 	AccessMaxDuration *int `gorm:"column:access_max_duration"`
 	MinApprovals      *int `gorm:"column:min_approvals"`
 
+	// Approval SLA, in minutes from the review's creation. A nil step is
+	// disabled. See ApplyReviewSLA for how a review picks them up.
+	ReminderAfterMinutes   *int           `gorm:"column:reminder_after_minutes"`
+	EscalationAfterMinutes *int           `gorm:"column:escalation_after_minutes"`
+	EscalationGroups       pq.StringArray `gorm:"column:escalation_groups;type:text[]"`
+	ExpirationAfterMinutes *int           `gorm:"column:expiration_after_minutes"`
+
+	// Members of BreakGlassGroups may skip the review during an incident,
+	// for at most BreakGlassMaxDuration seconds (one hour when nil).
+	BreakGlassGroups      pq.StringArray `gorm:"column:break_glass_groups;type:text[]"`
+	BreakGlassMaxDuration *int           `gorm:"column:break_glass_max_duration"`
+
 	RuleAttributes []AccessRequestRuleAttribute `gorm:"foreignKey:OrgID,AccessRuleName;references:OrgID,Name"`
 
 	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
@@ -53,6 +65,26 @@ func (m AccessRequestRule) TableName() string {
 	return "private.access_request_rules"
 }
 
+// ApplyReviewSLA resolves the rule's approval SLA into deadlines on a review
+// about to be created, counted from rev.CreatedAt. The review keeps its own
+// copy so that editing the rule never moves the deadline of a review already
+// waiting on it, and so the reviewsla job reads one table.
+func (m *AccessRequestRule) ApplyReviewSLA(rev *Review) {
+	after := func(minutes *int) *time.Time {
+		if minutes == nil || *minutes <= 0 {
+			return nil
+		}
+		t := rev.CreatedAt.Add(time.Duration(*minutes) * time.Minute)
+		return &t
+	}
+	rev.RemindAt = after(m.ReminderAfterMinutes)
+	if len(m.EscalationGroups) > 0 {
+		rev.EscalateAt = after(m.EscalationAfterMinutes)
+		rev.EscalationGroups = m.EscalationGroups
+	}
+	rev.ExpireAt = after(m.ExpirationAfterMinutes)
+}
+
 func GetAccessRequestRuleByResourceNameAndAccessType(db *gorm.DB, orgID uuid.UUID, resourceName, accessType string) (*AccessRequestRule, error) {
 	var accessRequestRule AccessRequestRule
 	result := db.
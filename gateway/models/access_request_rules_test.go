@@ -0,0 +1,85 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplyReviewSLA(t *testing.T) {
+	createdAt := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	minutes := func(v int) *int { return &v }
+	after := func(m int) *time.Time {
+		v := createdAt.Add(time.Duration(m) * time.Minute)
+		return &v
+	}
+
+	tests := []struct {
+		name             string
+		rule             AccessRequestRule
+		remindAt         *time.Time
+		escalateAt       *time.Time
+		escalationGroups []string
+		expireAt         *time.Time
+	}{
+		{
+			name: "rule without an SLA",
+		},
+		{
+			name: "every step set",
+			rule: AccessRequestRule{
+				ReminderAfterMinutes:   minutes(15),
+				EscalationAfterMinutes: minutes(60),
+				EscalationGroups:       []string{"sre"},
+				ExpirationAfterMinutes: minutes(240),
+			},
+			remindAt:         after(15),
+			escalateAt:       after(60),
+			escalationGroups: []string{"sre"},
+			expireAt:         after(240),
+		},
+		{
+			name: "zero or negative minutes leave the step unset",
+			rule: AccessRequestRule{
+				ReminderAfterMinutes:   minutes(0),
+				EscalationAfterMinutes: minutes(-5),
+				EscalationGroups:       []string{"sre"},
+				ExpirationAfterMinutes: minutes(120),
+			},
+			escalationGroups: []string{"sre"},
+			expireAt:         after(120),
+		},
+		{
+			name: "escalation without escalation groups is skipped",
+			rule: AccessRequestRule{
+				ReminderAfterMinutes:   minutes(15),
+				EscalationAfterMinutes: minutes(60),
+			},
+			remindAt: after(15),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rev := &Review{CreatedAt: createdAt}
+			tt.rule.ApplyReviewSLA(rev)
+			for _, deadline := range []struct {
+				name      string
+				got, want *time.Time
+			}{
+				{"remind_at", rev.RemindAt, tt.remindAt},
+				{"escalate_at", rev.EscalateAt, tt.escalateAt},
+				{"expire_at", rev.ExpireAt, tt.expireAt},
+			} {
+				switch {
+				case deadline.want == nil && deadline.got != nil:
+					t.Errorf("expected %s to be unset, got %v", deadline.name, *deadline.got)
+				case deadline.want != nil && (deadline.got == nil || !deadline.got.Equal(*deadline.want)):
+					t.Errorf("%s = %v, want %v", deadline.name, deadline.got, *deadline.want)
+				}
+			}
+			if len(rev.EscalationGroups) != len(tt.escalationGroups) {
+				t.Errorf("escalation_groups = %v, want %v", rev.EscalationGroups, tt.escalationGroups)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+const (
+	AccessScheduleVerbConnect  = "connect"
+	AccessScheduleVerbExec     = "exec"
+	AccessScheduleVerbRunbooks = "runbooks"
+)
+
+// accessScheduleDays maps the day names accepted in a recurring window.
+var accessScheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// AccessScheduleWindow is a recurring window in which a schedule allows
+// access, read in the schedule's time zone. An end time at or before the
+// start time runs past midnight into the next day. No days means every day.
+type AccessScheduleWindow struct {
+	Days      []string `json:"days"`
+	StartTime string   `json:"start_time"`
+	EndTime   string   `json:"end_time"`
+}
+
+// AccessScheduleFreeze is a one-off period in which a schedule denies access.
+type AccessScheduleFreeze struct {
+	Name     string    `json:"name"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+type AccessSchedule struct {
+	ID    uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	OrgID uuid.UUID `gorm:"column:org_id;index:idx_access_schedules_org_name,unique"`
+
+	Name        string  `gorm:"column:name;index:idx_access_schedules_org_name,unique"`
+	Description *string `gorm:"column:description"`
+
+	Timezone        string         `gorm:"column:timezone"`
+	Verbs           pq.StringArray `gorm:"column:verbs;type:text[]"`
+	ConnectionNames pq.StringArray `gorm:"column:connection_names;type:text[]"`
+
+	RecurringWindows []AccessScheduleWindow `gorm:"column:recurring_windows;serializer:json"`
+	FreezePeriods    []AccessScheduleFreeze `gorm:"column:freeze_periods;serializer:json"`
+
+	OverrideReviewersGroups pq.StringArray `gorm:"column:override_reviewers_groups;type:text[]"`
+
+	ScheduleAttributes []AccessScheduleAttribute `gorm:"foreignKey:OrgID,AccessScheduleName;references:OrgID,Name"`
+
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (m AccessSchedule) TableName() string {
+	return "private.access_schedules"
+}
+
+// ParseScheduleClock parses a "HH:MM" time of day into minutes after midnight.
+func ParseScheduleClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM in 24-hour format", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ParseScheduleDay parses a recurring window day name ("mon", "tue", ...).
+func ParseScheduleDay(v string) (time.Weekday, error) {
+	d, ok := accessScheduleDays[strings.ToLower(v)]
+	if !ok {
+		return 0, fmt.Errorf("invalid day %q, expected one of sun, mon, tue, wed, thu, fri or sat", v)
+	}
+	return d, nil
+}
+
+// Check reports why the schedule denies verb at t, or an empty string when
+// it allows it. Freeze periods take precedence over recurring windows. The
+// error is only returned for a schedule that can't be read (an unknown time
+// zone or a malformed window), which callers must treat as a denial: the API
+// validates both, so it means the row was written some other way.
+func (m *AccessSchedule) Check(verb string, t time.Time) (string, error) {
+	if len(m.Verbs) > 0 && !slices.Contains(m.Verbs, verb) {
+		return "", nil
+	}
+
+	for _, f := range m.FreezePeriods {
+		if !t.Before(f.StartsAt) && t.Before(f.EndsAt) {
+			reason := fmt.Sprintf("access is frozen by schedule %q until %s", m.Name, f.EndsAt.UTC().Format(time.RFC3339))
+			if f.Name != "" {
+				reason = fmt.Sprintf("access is frozen by schedule %q (%s) until %s", m.Name, f.Name, f.EndsAt.UTC().Format(time.RFC3339))
+			}
+			return reason, nil
+		}
+	}
+
+	if len(m.RecurringWindows) == 0 {
+		return "", nil
+	}
+	loc, err := time.LoadLocation(m.Timezone)
+	if err != nil {
+		return "", fmt.Errorf("schedule %q has an invalid time zone: %v", m.Name, err)
+	}
+	local := t.In(loc)
+	for _, w := range m.RecurringWindows {
+		inside, err := w.contains(local)
+		if err != nil {
+			return "", fmt.Errorf("schedule %q has an invalid window: %v", m.Name, err)
+		}
+		if inside {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("access is outside the hours allowed by schedule %q (%s)", m.Name, m.Timezone), nil
+}
+
+// contains reports whether local falls inside the window. A window past
+// midnight belongs to the day it starts on, so "fri 22:00-02:00" covers
+// Saturday 01:00 and not Friday 01:00.
+func (w AccessScheduleWindow) contains(local time.Time) (bool, error) {
+	start, err := ParseScheduleClock(w.StartTime)
+	if err != nil {
+		return false, err
+	}
+	end, err := ParseScheduleClock(w.EndTime)
+	if err != nil {
+		return false, err
+	}
+	onDay := func(d time.Weekday) (bool, error) {
+		if len(w.Days) == 0 {
+			return true, nil
+		}
+		for _, name := range w.Days {
+			wd, err := ParseScheduleDay(name)
+			if err != nil {
+				return false, err
+			}
+			if wd == d {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	clock := local.Hour()*60 + local.Minute()
+	if start < end {
+		if clock < start || clock >= end {
+			return false, nil
+		}
+		return onDay(local.Weekday())
+	}
+	if clock >= start {
+		return onDay(local.Weekday())
+	}
+	if clock < end {
+		return onDay((local.Weekday() + 6) % 7)
+	}
+	return false, nil
+}
+
+func GetAccessScheduleByName(db *gorm.DB, name string, orgID uuid.UUID) (*AccessSchedule, error) {
+	var schedule AccessSchedule
+	err := db.Preload("ScheduleAttributes").Where("name = ? AND org_id = ?", name, orgID).First(&schedule).Error
+	return &schedule, err
+}
+
+func CreateAccessSchedule(db *gorm.DB, schedule *AccessSchedule) error {
+	return db.Omit("ScheduleAttributes").Create(schedule).Error
+}
+
+func UpdateAccessSchedule(db *gorm.DB, schedule *AccessSchedule) error {
+	return db.Omit("ScheduleAttributes").Save(schedule).Error
+}
+
+type AccessSchedulesFilterOption struct {
+	Page     int
+	PageSize int
+}
+
+func ListAccessSchedules(db *gorm.DB, orgID uuid.UUID, opts AccessSchedulesFilterOption) ([]AccessSchedule, int64, error) {
+	var schedules []AccessSchedule
+	var total int64
+
+	if err := db.Model(&AccessSchedule{}).Where("org_id = ?", orgID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query := db.Preload("ScheduleAttributes").Where("org_id = ?", orgID).Order("name ASC")
+	if opts.PageSize > 0 {
+		offset := 0
+		if opts.Page > 1 {
+			offset = (opts.Page - 1) * opts.PageSize
+		}
+		query = query.Limit(opts.PageSize).Offset(offset)
+	}
+
+	if err := query.Find(&schedules).Error; err != nil {
+		return nil, 0, err
+	}
+	return schedules, total, nil
+}
+
+func DeleteAccessScheduleByName(db *gorm.DB, name string, orgID uuid.UUID) error {
+	result := db.Where("name = ? AND org_id = ?", name, orgID).Delete(&AccessSchedule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAccessSchedulesForConnection returns every schedule that applies to the
+// connection, by name or through one of its attributes. Unlike access request
+// rules, where the first match wins, schedules only ever take access away, so
+// a session must pass all of them.
+func GetAccessSchedulesForConnection(db *gorm.DB, orgID uuid.UUID, connectionName string) ([]AccessSchedule, error) {
+	attributeNamesSubQuery := db.Model(&ConnectionAttribute{}).
+		Select("attribute_name").
+		Where("org_id = ? AND connection_name = ?", orgID, connectionName)
+
+	scheduleNamesSubQuery := db.Model(&AccessScheduleAttribute{}).
+		Distinct("access_schedule_name").
+		Where("org_id = ? AND attribute_name IN (?)", orgID, attributeNamesSubQuery)
+
+	var schedules []AccessSchedule
+	err := db.
+		Where("org_id = ?", orgID).
+		Where("connection_names @> ? OR name IN (?)", pq.Array([]string{connectionName}), scheduleNamesSubQuery).
+		Order("name ASC").
+		Find(&schedules).
+		Error
+	return schedules, err
+}
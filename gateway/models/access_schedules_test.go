@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccessScheduleCheck(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	at := func(day, clock string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", day+" "+clock, saoPaulo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	businessHours := &AccessSchedule{
+		Name:     "prod-hours",
+		Timezone: "America/Sao_Paulo",
+		RecurringWindows: []AccessScheduleWindow{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, StartTime: "08:00", EndTime: "20:00"},
+		},
+	}
+	// 2026-05-08 is a Friday
+	freeze := &AccessSchedule{
+		Name:  "friday-freeze",
+		Verbs: []string{AccessScheduleVerbExec, AccessScheduleVerbRunbooks},
+		FreezePeriods: []AccessScheduleFreeze{
+			{Name: "release", StartsAt: at("2026-05-08", "12:00"), EndsAt: at("2026-05-09", "00:00")},
+		},
+	}
+	overnight := &AccessSchedule{
+		Name:     "maintenance",
+		Timezone: "UTC",
+		RecurringWindows: []AccessScheduleWindow{
+			{Days: []string{"fri"}, StartTime: "22:00", EndTime: "02:00"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		schedule *AccessSchedule
+		verb     string
+		t        time.Time
+		denied   bool
+	}{
+		{"inside business hours", businessHours, AccessScheduleVerbConnect, at("2026-05-06", "09:30"), false},
+		{"before business hours", businessHours, AccessScheduleVerbConnect, at("2026-05-06", "07:59"), true},
+		{"end of window is exclusive", businessHours, AccessScheduleVerbExec, at("2026-05-06", "20:00"), true},
+		{"weekend", businessHours, AccessScheduleVerbConnect, at("2026-05-09", "10:00"), true},
+		{"window read in the schedule time zone", businessHours, AccessScheduleVerbConnect,
+			time.Date(2026, 5, 6, 23, 30, 0, 0, time.UTC), true}, // 20:30 in São Paulo
+		{"inside freeze", freeze, AccessScheduleVerbExec, at("2026-05-08", "15:00"), true},
+		{"freeze skips other verbs", freeze, AccessScheduleVerbConnect, at("2026-05-08", "15:00"), false},
+		{"after freeze", freeze, AccessScheduleVerbRunbooks, at("2026-05-09", "00:00"), false},
+		{"overnight window on its start day", overnight, AccessScheduleVerbConnect,
+			time.Date(2026, 5, 8, 23, 0, 0, 0, time.UTC), false},
+		{"overnight window past midnight", overnight, AccessScheduleVerbConnect,
+			time.Date(2026, 5, 9, 1, 30, 0, 0, time.UTC), false},
+		{"overnight window belongs to its start day", overnight, AccessScheduleVerbConnect,
+			time.Date(2026, 5, 8, 1, 30, 0, 0, time.UTC), true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reason, err := tc.schedule.Check(tc.verb, tc.t)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := reason != ""; got != tc.denied {
+				t.Errorf("denied = %v (%q), want %v", got, reason, tc.denied)
+			}
+		})
+	}
+
+	broken := &AccessSchedule{Name: "broken", Timezone: "Mars/Olympus",
+		RecurringWindows: []AccessScheduleWindow{{StartTime: "08:00", EndTime: "20:00"}}}
+	if _, err := broken.Check(AccessScheduleVerbConnect, time.Now()); err == nil {
+		t.Error("expected an error for an unknown time zone")
+	}
+}
@@ -51,6 +51,17 @@ func (AccessRequestRuleAttribute) TableName() string {
 	return "private.access_request_rules_attributes"
 }
 
+// Access Schedule and Attribute
+type AccessScheduleAttribute struct {
+	OrgID              uuid.UUID `gorm:"column:org_id;primaryKey"`
+	AttributeName      string    `gorm:"column:attribute_name;primaryKey"`
+	AccessScheduleName string    `gorm:"column:access_schedule_name;primaryKey"`
+}
+
+func (AccessScheduleAttribute) TableName() string {
+	return "private.access_schedules_attributes"
+}
+
 // Guardrail Rule and Attribute
 type GuardrailRuleAttribute struct {
 	OrgID             uuid.UUID `gorm:"column:org_id;primaryKey"`
@@ -399,6 +410,34 @@ func UpsertAccessRequestRuleAttributes(db *gorm.DB, orgID uuid.UUID, ruleName st
 	})
 }
 
+// UpsertAccessScheduleAttributes replaces all attribute associations for the given access schedule.
+// If an attribute name does not exist in the attributes table, it is created automatically.
+func UpsertAccessScheduleAttributes(db *gorm.DB, orgID uuid.UUID, scheduleName string, attributeNames []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("org_id = ? AND access_schedule_name = ?", orgID, scheduleName).
+			Delete(&AccessScheduleAttribute{}).Error; err != nil {
+			return err
+		}
+		if len(attributeNames) == 0 {
+			return nil
+		}
+
+		attributes := make([]Attribute, len(attributeNames))
+		for i, name := range attributeNames {
+			attributes[i] = Attribute{OrgID: orgID, Name: name}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&attributes).Error; err != nil {
+			return err
+		}
+
+		assocs := make([]AccessScheduleAttribute, len(attributeNames))
+		for i, name := range attributeNames {
+			assocs[i] = AccessScheduleAttribute{OrgID: orgID, AttributeName: name, AccessScheduleName: scheduleName}
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&assocs).Error
+	})
+}
+
 // UpsertGuardrailRuleAttributes replaces all attribute associations for the given guardrail rule.
 // If an attribute name does not exist in the attributes table, it is created automatically.
 func UpsertGuardrailRuleAttributes(db *gorm.DB, orgID uuid.UUID, ruleName string, attributeNames []string) error {
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// DefaultBreakGlassMaxDuration bounds a break-glass grant when its access
+// request rule does not set one.
+const DefaultBreakGlassMaxDuration = time.Hour
+
+var ErrBreakGlassAcknowledged = errors.New("break-glass access was already acknowledged")
+
+// BreakGlassAccess is the retrospective review of a session that skipped its
+// access request rule's review during an incident. The review it belongs to
+// is created approved; this record stays open until one of the rule's
+// reviewers acknowledges it.
+type BreakGlassAccess struct {
+	ReviewID              string         `gorm:"column:review_id;primaryKey"`
+	OrgID                 string         `gorm:"column:org_id"`
+	SessionID             string         `gorm:"column:session_id"`
+	ConnectionName        string         `gorm:"column:connection_name"`
+	AccessRequestRuleName *string        `gorm:"column:access_request_rule_name"`
+	OwnerEmail            string         `gorm:"column:owner_email"`
+	Justification         string         `gorm:"column:justification"`
+	IncidentReference     string         `gorm:"column:incident_reference"`
+	ReviewersGroups       pq.StringArray `gorm:"column:reviewers_groups;type:text[]"`
+
+	AcknowledgedAt      *time.Time `gorm:"column:acknowledged_at"`
+	AcknowledgedBy      *string    `gorm:"column:acknowledged_by"`
+	AcknowledgementNote *string    `gorm:"column:acknowledgement_note"`
+
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (BreakGlassAccess) TableName() string { return "private.review_break_glass" }
+
+// CreateBreakGlassReview saves an already decided review together with its
+// break-glass record and tags the session, so a grant never exists without
+// the retrospective review that accounts for it.
+func CreateBreakGlassReview(rev *Review, bg *BreakGlassAccess, input string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := createReview(tx, rev, input); err != nil {
+			return err
+		}
+		bg.ReviewID = rev.ID
+		if err := tx.Create(bg).Error; err != nil {
+			return err
+		}
+		labels, err := json.Marshal(map[string]string{
+			"break_glass":        "true",
+			"incident_reference": bg.IncidentReference,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Table("private.sessions").
+			Where("org_id = ? AND id = ?", rev.OrgID, rev.SessionID).
+			UpdateColumn("labels", gorm.Expr("COALESCE(labels, '{}'::jsonb) || ?::jsonb", string(labels))).
+			Error
+	})
+}
+
+func GetBreakGlassAccess(orgID, reviewID string) (*BreakGlassAccess, error) {
+	var bg BreakGlassAccess
+	err := DB.Where("org_id = ? AND review_id = ?", orgID, reviewID).First(&bg).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrNotFound
+	}
+	return &bg, err
+}
+
+// AcknowledgeBreakGlassAccess closes the retrospective review. It only ever
+// succeeds once: a second reviewer gets ErrBreakGlassAcknowledged instead of
+// overwriting who acknowledged it.
+func AcknowledgeBreakGlassAccess(orgID, reviewID, reviewerEmail string, note *string) (*BreakGlassAccess, error) {
+	now := time.Now().UTC()
+	res := DB.Model(&BreakGlassAccess{}).
+		Where("org_id = ? AND review_id = ? AND acknowledged_at IS NULL", orgID, reviewID).
+		Updates(map[string]any{
+			"acknowledged_at":      now,
+			"acknowledged_by":      reviewerEmail,
+			"acknowledgement_note": note,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	bg, err := GetBreakGlassAccess(orgID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrBreakGlassAcknowledged
+	}
+	return bg, nil
+}
+
+type BreakGlassFilterOption struct {
+	StartDate      time.Time
+	EndDate        time.Time
+	OwnerEmail     string
+	ConnectionName string
+	// Acknowledged filters on the retrospective review; nil returns both.
+	Acknowledged *bool
+}
+
+// ListBreakGlassAccesses returns the break-glass records created in the
+// option's date range, newest first.
+func ListBreakGlassAccesses(orgID string, opts BreakGlassFilterOption) ([]BreakGlassAccess, error) {
+	query := DB.Where("org_id = ? AND created_at >= ? AND created_at < ?", orgID, opts.StartDate, opts.EndDate)
+	if opts.OwnerEmail != "" {
+		query = query.Where("owner_email = ?", opts.OwnerEmail)
+	}
+	if opts.ConnectionName != "" {
+		query = query.Where("connection_name = ?", opts.ConnectionName)
+	}
+	if opts.Acknowledged != nil {
+		if *opts.Acknowledged {
+			query = query.Where("acknowledged_at IS NOT NULL")
+		} else {
+			query = query.Where("acknowledged_at IS NULL")
+		}
+	}
+	var items []BreakGlassAccess
+	return items, query.Order("created_at DESC").Find(&items).Error
+}
@@ -0,0 +1,67 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hoophq/hoop/gateway/models"
+)
+
+// Two reviewers acknowledging the same break-glass access at once must not
+// overwrite each other: the first one closes it, the second gets a conflict.
+func TestAcknowledgeBreakGlassAccessOnlyOnce(t *testing.T) {
+	startTestDB(t)
+	ruleName := "prod-approval"
+	now := time.Now().UTC()
+	revokeAt := now.Add(time.Hour)
+	rev := &models.Review{
+		ID:                    uuid.NewString(),
+		OrgID:                 testOrgID,
+		SessionID:             uuid.NewString(),
+		Type:                  models.ReviewTypeJit,
+		Status:                models.ReviewStatusApproved,
+		ConnectionName:        "pg-prod",
+		AccessDurationSec:     3600,
+		OwnerID:               "user1",
+		OwnerEmail:            "user1@hoop.dev",
+		AccessRequestRuleName: &ruleName,
+		CreatedAt:             now,
+		RevokedAt:             &revokeAt,
+	}
+	bg := &models.BreakGlassAccess{
+		OrgID:                 testOrgID,
+		SessionID:             rev.SessionID,
+		ConnectionName:        rev.ConnectionName,
+		AccessRequestRuleName: &ruleName,
+		OwnerEmail:            rev.OwnerEmail,
+		Justification:         "database is down",
+		IncidentReference:     "INC-42",
+		ReviewersGroups:       []string{"dba"},
+		CreatedAt:             now,
+	}
+	if err := models.CreateBreakGlassReview(rev, bg, ""); err != nil {
+		t.Fatalf("create break-glass review: %v", err)
+	}
+
+	note := "checked the incident timeline"
+	got, err := models.AcknowledgeBreakGlassAccess(testOrgID, rev.ID, "reviewer1@hoop.dev", &note)
+	if err != nil {
+		t.Fatalf("acknowledge break-glass access: %v", err)
+	}
+	if got.AcknowledgedAt == nil || got.AcknowledgedBy == nil || *got.AcknowledgedBy != "reviewer1@hoop.dev" {
+		t.Errorf("expected the access to be acknowledged by reviewer1, got %+v", got)
+	}
+
+	_, err = models.AcknowledgeBreakGlassAccess(testOrgID, rev.ID, "reviewer2@hoop.dev", nil)
+	if err != models.ErrBreakGlassAcknowledged {
+		t.Fatalf("expected ErrBreakGlassAcknowledged on the second acknowledgement, got %v", err)
+	}
+	got, err = models.GetBreakGlassAccess(testOrgID, rev.ID)
+	if err != nil {
+		t.Fatalf("get break-glass access: %v", err)
+	}
+	if *got.AcknowledgedBy != "reviewer1@hoop.dev" || *got.AcknowledgementNote != note {
+		t.Errorf("expected the first acknowledgement to be kept, got %+v", got)
+	}
+}
@@ -0,0 +1,461 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+const (
+	CertificationRunStatusOpen      = "open"
+	CertificationRunStatusCompleted = "completed"
+
+	CertificationDecisionPending = "pending"
+	CertificationDecisionKeep    = "keep"
+	CertificationDecisionRevoke  = "revoke"
+	// CertificationDecisionNoResponse is set on the items left pending when
+	// their run reaches its deadline; they are revoked as if decided so.
+	CertificationDecisionNoResponse = "no_response"
+)
+
+var ErrCertificationItemDecided = errors.New("certification item was already decided or its run is completed")
+
+type CertificationCampaign struct {
+	ID    uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	OrgID uuid.UUID `gorm:"column:org_id;index:idx_certification_campaigns_org_name,unique"`
+
+	Name        string  `gorm:"column:name;index:idx_certification_campaigns_org_name,unique"`
+	Description *string `gorm:"column:description"`
+
+	GroupNames      pq.StringArray `gorm:"column:group_names;type:text[]"`
+	ReviewersGroups pq.StringArray `gorm:"column:reviewers_groups;type:text[]"`
+
+	DurationDays   int        `gorm:"column:duration_days"`
+	RecurrenceDays *int       `gorm:"column:recurrence_days"`
+	NextRunAt      *time.Time `gorm:"column:next_run_at"`
+
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (CertificationCampaign) TableName() string { return "private.certification_campaigns" }
+
+// CertificationRun is one pass of a campaign over the memberships in its
+// scope. It keeps the campaign's name and reviewers as they were when it
+// started, so its evidence reads the same after the campaign changes.
+type CertificationRun struct {
+	ID              uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	OrgID           uuid.UUID      `gorm:"column:org_id"`
+	CampaignID      *uuid.UUID     `gorm:"column:campaign_id"`
+	CampaignName    string         `gorm:"column:campaign_name"`
+	ReviewersGroups pq.StringArray `gorm:"column:reviewers_groups;type:text[]"`
+
+	Status      string     `gorm:"column:status"`
+	StartedBy   *string    `gorm:"column:started_by"`
+	StartedAt   time.Time  `gorm:"column:started_at"`
+	DeadlineAt  time.Time  `gorm:"column:deadline_at"`
+	CompletedAt *time.Time `gorm:"column:completed_at"`
+}
+
+func (CertificationRun) TableName() string { return "private.certification_runs" }
+
+// CertificationItem is a group membership under certification, with what
+// the group granted when the run started.
+type CertificationItem struct {
+	ID                 uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	OrgID              uuid.UUID      `gorm:"column:org_id"`
+	RunID              uuid.UUID      `gorm:"column:run_id"`
+	UserID             *string        `gorm:"column:user_id"`
+	UserEmail          string         `gorm:"column:user_email"`
+	GroupName          string         `gorm:"column:group_name"`
+	ConnectionNames    pq.StringArray `gorm:"column:connection_names;type:text[]"`
+	AccessRequestRules pq.StringArray `gorm:"column:access_request_rules;type:text[]"`
+	// IdpManaged is set when the identity provider syncs the memberships of
+	// its users on login. The gateway never revokes these: the provider would
+	// add them back on the next login, so the decision is only attested.
+	IdpManaged bool `gorm:"column:idp_managed"`
+
+	Decision  string     `gorm:"column:decision"`
+	DecidedBy *string    `gorm:"column:decided_by"`
+	DecidedAt *time.Time `gorm:"column:decided_at"`
+	Note      *string    `gorm:"column:note"`
+	RevokedAt *time.Time `gorm:"column:revoked_at"`
+}
+
+func (CertificationItem) TableName() string { return "private.certification_items" }
+
+// NextCertificationRun returns the first scheduled run of a campaign after
+// now. A gateway that was down for several periods runs the campaign once,
+// not once per missed period.
+func NextCertificationRun(last, now time.Time, recurrenceDays int) time.Time {
+	next := last.AddDate(0, 0, recurrenceDays)
+	for !next.After(now) {
+		next = next.AddDate(0, 0, recurrenceDays)
+	}
+	return next
+}
+
+func GetCertificationCampaignByName(db *gorm.DB, orgID uuid.UUID, name string) (*CertificationCampaign, error) {
+	var campaign CertificationCampaign
+	err := db.Where("org_id = ? AND name = ?", orgID, name).First(&campaign).Error
+	return &campaign, err
+}
+
+func CreateCertificationCampaign(db *gorm.DB, campaign *CertificationCampaign) error {
+	return db.Create(campaign).Error
+}
+
+func UpdateCertificationCampaign(db *gorm.DB, campaign *CertificationCampaign) error {
+	return db.Save(campaign).Error
+}
+
+func ListCertificationCampaigns(db *gorm.DB, orgID uuid.UUID) ([]CertificationCampaign, error) {
+	var campaigns []CertificationCampaign
+	err := db.Where("org_id = ?", orgID).Order("name ASC").Find(&campaigns).Error
+	return campaigns, err
+}
+
+func DeleteCertificationCampaignByName(db *gorm.DB, orgID uuid.UUID, name string) error {
+	result := db.Where("org_id = ? AND name = ?", orgID, name).Delete(&CertificationCampaign{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// StartCertificationRun opens a run of campaign and snapshots its items.
+// startedBy is the email of the user who started it, or nil for the
+// scheduler. idpManagedGroups flags the items as memberships the identity
+// provider syncs.
+func StartCertificationRun(db *gorm.DB, campaign *CertificationCampaign, startedBy *string, now time.Time, idpManagedGroups bool) (*CertificationRun, error) {
+	var run *CertificationRun
+	err := db.Transaction(func(tx *gorm.DB) (err error) {
+		run, err = startCertificationRun(tx, campaign, startedBy, now, idpManagedGroups)
+		return err
+	})
+	return run, err
+}
+
+// startCertificationRun snapshots the active users' memberships of the
+// campaign's groups, or of every group granting a connection or reviewing an
+// access request rule when the campaign names none. The admin group is never
+// certified: revoking it on no response could lock the organization out.
+func startCertificationRun(tx *gorm.DB, campaign *CertificationCampaign, startedBy *string, now time.Time, idpManagedGroups bool) (*CertificationRun, error) {
+	run := &CertificationRun{
+		ID:              uuid.New(),
+		OrgID:           campaign.OrgID,
+		CampaignID:      &campaign.ID,
+		CampaignName:    campaign.Name,
+		ReviewersGroups: campaign.ReviewersGroups,
+		Status:          CertificationRunStatusOpen,
+		StartedBy:       startedBy,
+		StartedAt:       now,
+		DeadlineAt:      now.AddDate(0, 0, campaign.DurationDays),
+	}
+	if err := tx.Create(run).Error; err != nil {
+		return nil, err
+	}
+	err := tx.Exec(`
+	WITH grants AS (
+		SELECT g.group_name, c.name AS connection_name
+		FROM private.connections c
+		JOIN private.plugins ac ON ac.name = 'access_control' AND ac.org_id = c.org_id
+		JOIN private.plugin_connections acc ON acc.connection_id = c.id AND acc.plugin_id = ac.id
+		CROSS JOIN LATERAL unnest(acc.config) AS g(group_name)
+		WHERE c.org_id = @org_id
+		UNION
+		SELECT acga.group_name, ca.connection_name
+		FROM private.access_control_groups_attributes acga
+		JOIN private.connections_attributes ca
+			ON ca.org_id = acga.org_id AND ca.attribute_name = acga.attribute_name
+		WHERE acga.org_id = @org_id
+	)
+	INSERT INTO private.certification_items
+		(org_id, run_id, user_id, user_email, group_name, connection_names, access_request_rules, idp_managed)
+	SELECT m.org_id, @run_id, m.user_id, m.email, m.name, m.connection_names, m.access_request_rules, @idp_managed
+	FROM (
+		SELECT ug.org_id, u.id AS user_id, u.email, ug.name,
+			COALESCE((
+				SELECT array_agg(DISTINCT g.connection_name ORDER BY g.connection_name)
+				FROM grants g WHERE g.group_name = ug.name
+			), ARRAY[]::TEXT[]) AS connection_names,
+			COALESCE((
+				SELECT array_agg(r.name ORDER BY r.name)
+				FROM private.access_request_rules r
+				WHERE r.org_id = ug.org_id AND ug.name = ANY(r.reviewers_groups)
+			), ARRAY[]::TEXT[]) AS access_request_rules
+		FROM private.user_groups ug
+		JOIN private.users u ON u.id = ug.user_id AND u.org_id = ug.org_id
+		WHERE ug.org_id = @org_id AND u.status = 'active' AND ug.name <> @admin_group
+			AND (cardinality(@group_names::TEXT[]) = 0 OR ug.name = ANY(@group_names::TEXT[]))
+	) m
+	WHERE cardinality(@group_names::TEXT[]) > 0
+		OR cardinality(m.connection_names) > 0
+		OR cardinality(m.access_request_rules) > 0`,
+		map[string]any{
+			"org_id":      campaign.OrgID,
+			"run_id":      run.ID,
+			"admin_group": types.GroupAdmin,
+			"group_names": pq.Array([]string(campaign.GroupNames)),
+			"idp_managed": idpManagedGroups,
+		}).Error
+	return run, err
+}
+
+// StartDueCertificationRuns starts a run of up to limit campaigns whose
+// scheduled run is due, and moves their next run one recurrence ahead.
+// Campaigns are claimed with SKIP LOCKED, so gateway replicas never start
+// the same run twice.
+func StartDueCertificationRuns(ctx context.Context, db *gorm.DB, now time.Time, limit int, idpManagedGroups bool) ([]CertificationRun, error) {
+	var runs []CertificationRun
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var campaigns []CertificationCampaign
+		err := tx.Raw(`
+		SELECT * FROM private.certification_campaigns
+		WHERE next_run_at <= ?
+		ORDER BY next_run_at
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED`, now, limit).
+			Scan(&campaigns).
+			Error
+		if err != nil {
+			return err
+		}
+		for _, campaign := range campaigns {
+			run, err := startCertificationRun(tx, &campaign, nil, now, idpManagedGroups)
+			if err != nil {
+				return err
+			}
+			var nextRunAt *time.Time
+			if campaign.RecurrenceDays != nil && *campaign.RecurrenceDays > 0 {
+				next := NextCertificationRun(*campaign.NextRunAt, now, *campaign.RecurrenceDays)
+				nextRunAt = &next
+			}
+			err = tx.Model(&CertificationCampaign{}).
+				Where("id = ?", campaign.ID).
+				UpdateColumn("next_run_at", nextRunAt).
+				Error
+			if err != nil {
+				return err
+			}
+			runs = append(runs, *run)
+		}
+		return nil
+	})
+	return runs, err
+}
+
+// CompletedCertificationRun is a run closed by CompleteDueCertificationRuns
+// with the items it revoked on the way.
+type CompletedCertificationRun struct {
+	CertificationRun
+	Revoked []CertificationItem
+}
+
+// CompleteDueCertificationRuns closes up to limit open runs that reached
+// their deadline or have nothing left to decide. Items still pending are
+// marked no_response and, like the items decided revoke, lose their
+// membership unless it's IdpManaged. Claimed as StartDueCertificationRuns
+// claims.
+func CompleteDueCertificationRuns(ctx context.Context, db *gorm.DB, now time.Time, limit int) ([]CompletedCertificationRun, error) {
+	var completed []CompletedCertificationRun
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var runs []CertificationRun
+		err := tx.Raw(`
+		SELECT * FROM private.certification_runs r
+		WHERE r.status = 'open' AND (r.deadline_at <= @now OR NOT EXISTS (
+			SELECT 1 FROM private.certification_items i
+			WHERE i.run_id = r.id AND i.decision = 'pending'
+		))
+		ORDER BY r.deadline_at
+		LIMIT @limit
+		FOR UPDATE SKIP LOCKED`, map[string]any{"now": now, "limit": limit}).
+			Scan(&runs).
+			Error
+		if err != nil {
+			return err
+		}
+		for _, run := range runs {
+			err := tx.Model(&CertificationItem{}).
+				Where("run_id = ? AND decision = ?", run.ID, CertificationDecisionPending).
+				Updates(map[string]any{"decision": CertificationDecisionNoResponse, "decided_at": now}).
+				Error
+			if err != nil {
+				return err
+			}
+			var items []CertificationItem
+			err = tx.Where("run_id = ? AND decision IN ? AND revoked_at IS NULL AND NOT idp_managed", run.ID,
+				[]string{CertificationDecisionRevoke, CertificationDecisionNoResponse}).
+				Find(&items).
+				Error
+			if err != nil {
+				return err
+			}
+			for i := range items {
+				if err := revokeCertificationItem(tx, &items[i], now); err != nil {
+					return err
+				}
+			}
+			run.Status = CertificationRunStatusCompleted
+			run.CompletedAt = &now
+			err = tx.Model(&CertificationRun{}).
+				Where("id = ?", run.ID).
+				Updates(map[string]any{"status": run.Status, "completed_at": now}).
+				Error
+			if err != nil {
+				return err
+			}
+			completed = append(completed, CompletedCertificationRun{CertificationRun: run, Revoked: items})
+		}
+		return nil
+	})
+	return completed, err
+}
+
+// revokeCertificationItem removes the membership the item certifies. A user
+// removed from the group since the run started leaves nothing to delete and
+// the item is still marked revoked.
+func revokeCertificationItem(tx *gorm.DB, item *CertificationItem, now time.Time) error {
+	if item.UserID != nil {
+		err := tx.Exec(`DELETE FROM private.user_groups WHERE org_id = ? AND user_id = ? AND name = ?`,
+			item.OrgID, *item.UserID, item.GroupName).Error
+		if err != nil {
+			return err
+		}
+	}
+	item.RevokedAt = &now
+	return tx.Model(&CertificationItem{}).
+		Where("id = ?", item.ID).
+		UpdateColumn("revoked_at", now).
+		Error
+}
+
+// DecideCertificationItem records the attestation of a pending item of an
+// open run. A revoke decision removes the membership in the same
+// transaction, unless it's IdpManaged: the revocation is then left to the
+// identity provider and revoked_at stays empty. Deciding an item twice
+// returns ErrCertificationItemDecided.
+func DecideCertificationItem(db *gorm.DB, orgID uuid.UUID, itemID, decision, reviewerEmail string, note *string) (*CertificationItem, error) {
+	now := time.Now().UTC()
+	var item CertificationItem
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Exec(`
+		UPDATE private.certification_items i
+		SET decision = ?, decided_by = ?, decided_at = ?, note = ?
+		FROM private.certification_runs r
+		WHERE r.id = i.run_id AND r.status = 'open'
+			AND i.org_id = ? AND i.id = ? AND i.decision = 'pending'`,
+			decision, reviewerEmail, now, note, orgID, itemID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrCertificationItemDecided
+		}
+		if err := tx.Where("org_id = ? AND id = ?", orgID, itemID).First(&item).Error; err != nil {
+			return err
+		}
+		if decision == CertificationDecisionRevoke && !item.IdpManaged {
+			return revokeCertificationItem(tx, &item, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func GetCertificationRun(db *gorm.DB, orgID uuid.UUID, id string) (*CertificationRun, error) {
+	var run CertificationRun
+	err := db.Where("org_id = ? AND id = ?", orgID, id).First(&run).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrNotFound
+	}
+	return &run, err
+}
+
+type CertificationRunsFilterOption struct {
+	CampaignName string
+	Status       string
+}
+
+func ListCertificationRuns(db *gorm.DB, orgID uuid.UUID, opts CertificationRunsFilterOption) ([]CertificationRun, error) {
+	query := db.Where("org_id = ?", orgID)
+	if opts.CampaignName != "" {
+		query = query.Where("campaign_name = ?", opts.CampaignName)
+	}
+	if opts.Status != "" {
+		query = query.Where("status = ?", opts.Status)
+	}
+	var runs []CertificationRun
+	return runs, query.Order("started_at DESC").Find(&runs).Error
+}
+
+// CertificationTally counts the items of a run by decision.
+type CertificationTally struct {
+	Pending    int `gorm:"column:pending"`
+	Keep       int `gorm:"column:keep"`
+	Revoke     int `gorm:"column:revoke"`
+	NoResponse int `gorm:"column:no_response"`
+}
+
+func (t CertificationTally) Total() int { return t.Pending + t.Keep + t.Revoke + t.NoResponse }
+
+func CountCertificationDecisions(db *gorm.DB, runID uuid.UUID) (CertificationTally, error) {
+	var tally CertificationTally
+	err := db.Raw(`
+	SELECT
+		COUNT(*) FILTER (WHERE decision = 'pending') AS pending,
+		COUNT(*) FILTER (WHERE decision = 'keep') AS keep,
+		COUNT(*) FILTER (WHERE decision = 'revoke') AS revoke,
+		COUNT(*) FILTER (WHERE decision = 'no_response') AS no_response
+	FROM private.certification_items
+	WHERE run_id = ?`, runID).
+		Scan(&tally).
+		Error
+	return tally, err
+}
+
+func GetCertificationItem(db *gorm.DB, orgID uuid.UUID, id string) (*CertificationItem, error) {
+	var item CertificationItem
+	err := db.Where("org_id = ? AND id = ?", orgID, id).First(&item).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrNotFound
+	}
+	return &item, err
+}
+
+type CertificationItemsFilterOption struct {
+	RunID    string
+	Decision string
+	// ReviewerGroups narrows the items to the open runs these groups review;
+	// nil returns the items of every run.
+	ReviewerGroups []string
+}
+
+func ListCertificationItems(db *gorm.DB, orgID uuid.UUID, opts CertificationItemsFilterOption) ([]CertificationItem, error) {
+	query := db.Where("org_id = ?", orgID)
+	if opts.RunID != "" {
+		query = query.Where("run_id = ?", opts.RunID)
+	}
+	if opts.Decision != "" {
+		query = query.Where("decision = ?", opts.Decision)
+	}
+	if opts.ReviewerGroups != nil {
+		query = query.Where(`run_id IN (
+			SELECT id FROM private.certification_runs
+			WHERE org_id = ? AND status = 'open' AND reviewers_groups && ?
+		)`, orgID, pq.Array(opts.ReviewerGroups))
+	}
+	var items []CertificationItem
+	return items, query.Order("group_name ASC, user_email ASC").Find(&items).Error
+}
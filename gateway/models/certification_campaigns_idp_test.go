@@ -0,0 +1,85 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hoophq/hoop/gateway/models"
+)
+
+// The identity provider adds a removed membership back on the user's next
+// login, so the memberships of a run started with idpManagedGroups are only
+// attested: neither a revoke decision nor the deadline removes them.
+func TestIdpManagedCertificationItemsAreAttestOnly(t *testing.T) {
+	startTestDB(t)
+	seedUser(t, "user-dba", 0)
+	seedUser(t, "user-idle", 0)
+	err := models.DB.Exec(`
+		INSERT INTO private.user_groups (org_id, user_id, name)
+		SELECT org_id, id, 'dba' FROM private.users WHERE org_id = ? AND subject IN ('user-dba', 'user-idle')`, testOrgID).Error
+	if err != nil {
+		t.Fatalf("seed user groups: %v", err)
+	}
+	countMemberships := func() int64 {
+		var memberships int64
+		err := models.DB.Raw(`SELECT count(*) FROM private.user_groups WHERE org_id = ? AND name = 'dba'`, testOrgID).
+			Scan(&memberships).Error
+		if err != nil {
+			t.Fatalf("count memberships: %v", err)
+		}
+		return memberships
+	}
+
+	campaign := &models.CertificationCampaign{
+		OrgID:           uuid.MustParse(testOrgID),
+		Name:            "quarterly",
+		GroupNames:      []string{"dba"},
+		ReviewersGroups: []string{"security"},
+		DurationDays:    14,
+	}
+	if err := models.CreateCertificationCampaign(models.DB, campaign); err != nil {
+		t.Fatalf("create campaign: %v", err)
+	}
+	run, err := models.StartCertificationRun(models.DB, campaign, nil, time.Now().UTC(), true)
+	if err != nil {
+		t.Fatalf("start run: %v", err)
+	}
+	items, err := models.ListCertificationItems(models.DB, campaign.OrgID, models.CertificationItemsFilterOption{RunID: run.ID.String()})
+	if err != nil || len(items) != 2 {
+		t.Fatalf("expected both dba memberships under certification, got items=%+v err=%v", items, err)
+	}
+	var revokeItem models.CertificationItem
+	for _, item := range items {
+		if !item.IdpManaged {
+			t.Errorf("expected item %v to be flagged as managed by the identity provider", item.UserEmail)
+		}
+		if item.UserEmail == "user-dba@hoop.dev" {
+			revokeItem = item
+		}
+	}
+
+	item, err := models.DecideCertificationItem(models.DB, campaign.OrgID, revokeItem.ID.String(),
+		models.CertificationDecisionRevoke, "reviewer@hoop.dev", nil)
+	if err != nil {
+		t.Fatalf("revoke item: %v", err)
+	}
+	if item.Decision != models.CertificationDecisionRevoke || item.RevokedAt != nil {
+		t.Errorf("expected the revoke decision to be attested only, got %+v", item)
+	}
+	if got := countMemberships(); got != 2 {
+		t.Errorf("expected the gateway to keep the memberships, got %v", got)
+	}
+
+	completed, err := models.CompleteDueCertificationRuns(context.Background(), models.DB, run.DeadlineAt.Add(time.Minute), 10)
+	if err != nil || len(completed) != 1 {
+		t.Fatalf("expected the run to complete, got runs=%+v err=%v", completed, err)
+	}
+	if len(completed[0].Revoked) != 0 {
+		t.Errorf("expected no membership revoked when the run completes, got %+v", completed[0].Revoked)
+	}
+	if got := countMemberships(); got != 2 {
+		t.Errorf("expected the unanswered membership to be kept, got %v", got)
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextCertificationRun(t *testing.T) {
+	last := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		msg  string
+		now  time.Time
+		want time.Time
+	}{
+		{
+			msg:  "it must schedule one recurrence ahead when running on time",
+			now:  last,
+			want: time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			msg:  "it must skip the periods missed while the gateway was down",
+			now:  time.Date(2026, 7, 15, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2026, 9, 28, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			msg:  "it must not schedule the next run at now",
+			now:  time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC),
+			want: time.Date(2026, 6, 30, 9, 0, 0, 0, time.UTC),
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			if got := NextCertificationRun(last, tt.now, 90); !got.Equal(tt.want) {
+				t.Errorf("expected next run at %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -24,6 +25,9 @@ const (
 	ReviewStatusProcessing ReviewStatusType = "PROCESSING"
 	ReviewStatusExecuted   ReviewStatusType = "EXECUTED"
 	ReviewStatusUnknown    ReviewStatusType = "UNKNOWN"
+	// ReviewStatusExpired is set by the reviewsla job on a review nobody
+	// decided before its access request rule's expiration deadline.
+	ReviewStatusExpired ReviewStatusType = "EXPIRED"
 
 	ReviewTypeJit     ReviewType = "jit"
 	ReviewTypeOneTime ReviewType = "onetime"
@@ -31,6 +35,10 @@ const (
 
 func (t ReviewStatusType) Str() string { return string(t) }
 
+// ErrReviewExpired is returned when a review is written after the reviewsla
+// job expired it.
+var ErrReviewExpired = fmt.Errorf("review expired")
+
 // reviewStatusLabels mirrors private.enum_reviews_status. It exists so a caller
 // can tell a real status from arbitrary input before comparing against the enum
 // column: casting a non-label to enum_reviews_status is an error, not an empty
@@ -44,6 +52,7 @@ var reviewStatusLabels = map[ReviewStatusType]struct{}{
 	ReviewStatusProcessing: {},
 	ReviewStatusExecuted:   {},
 	ReviewStatusUnknown:    {},
+	ReviewStatusExpired:    {},
 }
 
 // IsValidReviewStatus reports whether v is a label of private.enum_reviews_status.
@@ -73,11 +82,24 @@ type Review struct {
 	AccessRequestRuleName *string        `gorm:"column:access_request_rule_name"`
 	ForceApprovalGroups   pq.StringArray `gorm:"column:force_approval_groups;type:text[]"`
 	MinApprovals          *int           `gorm:"column:min_approvals"`
+	// AccessScheduleNames are the access schedules that denied the session,
+	// each one waiting on the approval of its own override reviewers.
+	AccessScheduleNames pq.StringArray `gorm:"column:access_schedule_names;type:text[]"`
 
 	CreatedAt       time.Time         `gorm:"column:created_at"`
 	RevokedAt       *time.Time        `gorm:"column:revoked_at"`
 	TimeWindow      *ReviewTimeWindow `gorm:"column:time_window;serializer:json;"`
 	RejectionReason *string           `gorm:"column:rejection_reason"`
+
+	// Approval SLA deadlines, copied from the access request rule when the
+	// review is created (AccessRequestRule.ApplyReviewSLA). ReminderSentAt
+	// and EscalatedAt record that the reviewsla job ran the step.
+	RemindAt         *time.Time     `gorm:"column:remind_at"`
+	ReminderSentAt   *time.Time     `gorm:"column:reminder_sent_at"`
+	EscalateAt       *time.Time     `gorm:"column:escalate_at"`
+	EscalationGroups pq.StringArray `gorm:"column:escalation_groups;type:text[]"`
+	EscalatedAt      *time.Time     `gorm:"column:escalated_at"`
+	ExpireAt         *time.Time     `gorm:"column:expire_at"`
 }
 
 type ReviewTimeWindow struct {
@@ -97,6 +119,9 @@ type ReviewGroups struct {
 	OwnerSlackID *string          `json:"owner_slack_id"`
 	ReviewedAt   *time.Time       `json:"reviewed_at"`
 	ForcedReview bool             `json:"forced_review"`
+	// AccessScheduleName is the schedule this group approves overriding. It's
+	// empty for the groups of the access request rule.
+	AccessScheduleName *string `json:"access_schedule_name"`
 }
 
 // RejectedByEmail returns the email of the reviewer whose group rejected the
@@ -124,6 +149,8 @@ type ReviewJit struct {
 	OwnerEmail        string     `gorm:"column:owner_email"`
 	CreatedAt         time.Time  `gorm:"column:created_at"`
 	RevokedAt         *time.Time `gorm:"column:revoked_at"`
+
+	AccessScheduleNames pq.StringArray `gorm:"column:access_schedule_names;type:text[]"`
 }
 
 func generateBlobInputID(reviewID string) string {
@@ -173,13 +200,16 @@ func GetReviewByIdOrSid(orgID, id string) (*Review, error) {
 					'owner_email', rg.owner_email,
 					'owner_name', rg.owner_name,
 					'owner_slack_id', rg.owner_slack_id,
-					'reviewed_at', to_char(rg.reviewed_at, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"')
+					'reviewed_at', to_char(rg.reviewed_at, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+					'forced_review', rg.forced_review,
+					'access_schedule_name', rg.access_schedule_name
 				)
 			)
 			FROM private.review_groups AS rg
 			WHERE rg.review_id = rv.id
 		) AS review_groups,
-	created_at, revoked_at, rejection_reason
+	created_at, revoked_at, rejection_reason, access_schedule_names,
+	remind_at, reminder_sent_at, escalate_at, escalation_groups, escalated_at, expire_at
 	FROM private.reviews rv
 	WHERE org_id = ? AND (id = ? OR session_id = ?)`, orgID, id, id).
 		First(&review).
@@ -208,13 +238,16 @@ func ListReviews(orgID string) (*[]Review, error) {
 					'owner_email', rg.owner_email,
 					'owner_name', rg.owner_name,
 					'owner_slack_id', rg.owner_slack_id,
-					'reviewed_at', to_char(rg.reviewed_at, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"')
+					'reviewed_at', to_char(rg.reviewed_at, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+					'forced_review', rg.forced_review,
+					'access_schedule_name', rg.access_schedule_name
 				)
 			)
 			FROM private.review_groups AS rg
 			WHERE rg.review_id = rv.id
 		) AS review_groups,
-	created_at, revoked_at, rejection_reason
+	created_at, revoked_at, rejection_reason, access_schedule_names,
+	remind_at, reminder_sent_at, escalate_at, escalation_groups, escalated_at, expire_at
 	FROM private.reviews rv
 	WHERE org_id = ?`, orgID).
 		Find(&reviews).
@@ -229,65 +262,69 @@ func ListReviews(orgID string) (*[]Review, error) {
 // Create the review object, when input is not empty it generates a blob id
 // and save the input as well.
 func CreateReview(rev *Review, input string) error {
-	return DB.Transaction(func(tx *gorm.DB) error {
-		blobID := generateBlobInputID(rev.ID)
-		if input != "" {
-			rev.BlobInputID = sql.NullString{String: blobID, Valid: true}
+	return DB.Transaction(func(tx *gorm.DB) error { return createReview(tx, rev, input) })
+}
+
+func createReview(tx *gorm.DB, rev *Review, input string) error {
+	blobID := generateBlobInputID(rev.ID)
+	if input != "" {
+		rev.BlobInputID = sql.NullString{String: blobID, Valid: true}
+	}
+	err := tx.Table("private.reviews").
+		Create(rev).
+		Error
+	if err != nil {
+		return err
+	}
+
+	if input != "" {
+		blobInput := Blob{
+			ID:         blobID,
+			OrgID:      rev.OrgID,
+			Type:       "review-input",
+			BlobStream: json.RawMessage(fmt.Sprintf("[%q]", input)),
 		}
-		err := tx.Table("private.reviews").
-			Create(rev).
+		err = tx.Table("private.blobs").
+			Create(blobInput).
 			Error
 		if err != nil {
-			return err
-		}
-
-		if input != "" {
-			blobInput := Blob{
-				ID:         blobID,
-				OrgID:      rev.OrgID,
-				Type:       "review-input",
-				BlobStream: json.RawMessage(fmt.Sprintf("[%q]", input)),
-			}
-			err = tx.Table("private.blobs").
-				Create(blobInput).
-				Error
-			if err != nil {
-				return fmt.Errorf("failed creating review blob input, reason=%v", err)
-			}
+			return fmt.Errorf("failed creating review blob input, reason=%v", err)
 		}
+	}
 
-		var errs []string
-		for _, rg := range rev.ReviewGroups {
-			err = tx.Table("private.review_groups").
-				Create(map[string]any{
-					"id":             rg.ID,
-					"org_id":         rg.OrgID,
-					"review_id":      rev.ID,
-					"group_name":     rg.GroupName,
-					"status":         rg.Status,
-					"owner_id":       rg.OwnerID,
-					"owner_email":    rg.OwnerEmail,
-					"owner_slack_id": rg.OwnerSlackID,
-					"reviewed_at":    rg.ReviewedAt,
-				}).
-				Error
+	var errs []string
+	for _, rg := range rev.ReviewGroups {
+		err = tx.Table("private.review_groups").
+			Create(map[string]any{
+				"id":                   rg.ID,
+				"org_id":               rg.OrgID,
+				"review_id":            rev.ID,
+				"group_name":           rg.GroupName,
+				"status":               rg.Status,
+				"owner_id":             rg.OwnerID,
+				"owner_email":          rg.OwnerEmail,
+				"owner_slack_id":       rg.OwnerSlackID,
+				"reviewed_at":          rg.ReviewedAt,
+				"access_schedule_name": rg.AccessScheduleName,
+			}).
+			Error
 
-			if err != nil {
-				errs = append(errs, fmt.Sprintf("%v", err))
-			}
-		}
-		if len(errs) > 0 {
-			return fmt.Errorf("%v", errs)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v", err))
 		}
-		return nil
-	})
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
 }
 
 // Lookup for the latest review jit approved
 func GetApprovedReviewJit(orgID, ownerUserID, connectionID string) (*ReviewJit, error) {
 	var jit ReviewJit
 	err := DB.Raw(`
-	SELECT id, org_id, session_id, type, access_duration_sec, owner_email, created_at, revoked_at
+	SELECT id, org_id, session_id, type, access_duration_sec, owner_email, created_at, revoked_at,
+		access_schedule_names
 	FROM private.reviews
 	WHERE org_id = ? AND type = 'jit' AND status = 'APPROVED' AND owner_id = ? AND connection_id = ?
 	ORDER BY created_at DESC
@@ -301,17 +338,21 @@ func GetApprovedReviewJit(orgID, ownerUserID, connectionID string) (*ReviewJit,
 }
 
 // update the review resource,
-// it updates the session status when the review status is approved, rejected or revoked
+// it updates the session status when the review status is approved, rejected or revoked.
+// It returns ErrReviewExpired when the review expired since it was loaded.
 func UpdateReview(rev *Review) error {
 	return DB.Transaction(func(tx *gorm.DB) error {
+		// An expired review is final. The reviewsla job may expire a review
+		// between a reviewer loading it as pending and this write, and
+		// without the guard the decision would reopen its session.
 		res := tx.Table("private.reviews").
-			Where("org_id = ?", rev.OrgID).
+			Where("org_id = ? AND status <> ?", rev.OrgID, ReviewStatusExpired).
 			Updates(rev)
 		if res.Error != nil {
 			return res.Error
 		}
 		if res.RowsAffected == 0 {
-			return fmt.Errorf("no record updated for review %s", rev.ID)
+			return ErrReviewExpired
 		}
 		var errs []string
 		for _, rg := range rev.ReviewGroups {
@@ -348,9 +389,11 @@ func UpdateReview(rev *Review) error {
 	})
 }
 
+// UpdateReviewStatus sets the status of a review. An expired review is final,
+// it's left as is and ErrNotFound returned, as for a missing review.
 func UpdateReviewStatus(orgID, id string, status ReviewStatusType) error {
 	res := DB.Table("private.reviews").
-		Where("org_id = ? AND id = ?", orgID, id).
+		Where("org_id = ? AND id = ? AND status <> ?", orgID, id, ReviewStatusExpired).
 		UpdateColumn("status", status)
 	if res.Error != nil {
 		return res.Error
@@ -397,3 +440,140 @@ func ReconcileStaleReviews(db *gorm.DB) (int64, error) {
 		ReviewStatusProcessing, ReviewStatusUnknown)
 	return res.RowsAffected, res.Error
 }
+
+// ReviewSLAStep is a pending review the reviewsla job claimed one approval
+// SLA step for: a reminder, an escalation or its expiration.
+type ReviewSLAStep struct {
+	ID                    string         `gorm:"column:id"`
+	OrgID                 string         `gorm:"column:org_id"`
+	SessionID             string         `gorm:"column:session_id"`
+	Type                  ReviewType     `gorm:"column:type"`
+	ConnectionName        string         `gorm:"column:connection_name"`
+	ConnectionID          sql.NullString `gorm:"column:connection_id"`
+	AccessDurationSec     int64          `gorm:"column:access_duration_sec"`
+	OwnerEmail            string         `gorm:"column:owner_email"`
+	OwnerName             *string        `gorm:"column:owner_name"`
+	OwnerSlackID          *string        `gorm:"column:owner_slack_id"`
+	AccessRequestRuleName *string        `gorm:"column:access_request_rule_name"`
+	EscalationGroups      pq.StringArray `gorm:"column:escalation_groups;type:text[]"`
+	CreatedAt             time.Time      `gorm:"column:created_at"`
+}
+
+// reviewSLAReturning is the column list every claim returns as a
+// ReviewSLAStep.
+const reviewSLAReturning = `r.id, r.org_id, r.session_id, r.type, r.connection_name, r.connection_id,
+	r.access_duration_sec, r.owner_email, r.owner_name, r.owner_slack_id,
+	r.access_request_rule_name, r.escalation_groups, r.created_at`
+
+// ClaimReviewReminders marks up to limit pending reviews whose reminder is
+// due as reminded, and returns them for the caller to notify.
+//
+// Claiming before notifying makes the reminder at-most-once: the rows are
+// taken FOR UPDATE SKIP LOCKED, so replicas sweeping at the same time take
+// disjoint sets, and a reminder already claimed is never claimed again. A
+// gateway that dies between the claim and the send loses that reminder, which
+// is the better failure for a nudge than sending it twice.
+func ClaimReviewReminders(ctx context.Context, db *gorm.DB, now time.Time, limit int) ([]ReviewSLAStep, error) {
+	var steps []ReviewSLAStep
+	err := db.WithContext(ctx).Raw(`
+	WITH due AS (
+		SELECT id
+		FROM private.reviews
+		WHERE status = 'PENDING' AND remind_at <= @now AND reminder_sent_at IS NULL
+		ORDER BY remind_at
+		LIMIT @limit
+		FOR UPDATE SKIP LOCKED
+	)
+	UPDATE private.reviews r
+	SET reminder_sent_at = @now
+	FROM due
+	WHERE r.id = due.id
+	RETURNING `+reviewSLAReturning,
+		map[string]any{"now": now, "limit": limit}).
+		Scan(&steps).
+		Error
+	return steps, err
+}
+
+// ClaimReviewEscalations marks up to limit pending reviews whose escalation
+// is due as escalated, adds a pending review group for each escalation group
+// the review does not have yet, and returns them for the caller to notify.
+// Claimed as ClaimReviewReminders claims.
+//
+// An escalation group is an extra reviewer, not an extra approval: the
+// number of approvals the review needs is pinned to what it was before the
+// groups were added, so one approval from the escalation group stands in for
+// a group that did not answer. The groups of access schedule overrides are
+// not counted: they approve overriding their schedule on top of the rule.
+func ClaimReviewEscalations(ctx context.Context, db *gorm.DB, now time.Time, limit int) ([]ReviewSLAStep, error) {
+	var steps []ReviewSLAStep
+	err := db.WithContext(ctx).Raw(`
+	WITH due AS (
+		SELECT id
+		FROM private.reviews
+		WHERE status = 'PENDING' AND escalate_at <= @now AND escalated_at IS NULL
+		ORDER BY escalate_at
+		LIMIT @limit
+		FOR UPDATE SKIP LOCKED
+	), escalated AS (
+		UPDATE private.reviews r
+		SET escalated_at = @now,
+			min_approvals = COALESCE(r.min_approvals,
+				(SELECT count(*) FROM private.review_groups rg
+				WHERE rg.review_id = r.id AND rg.access_schedule_name IS NULL))
+		FROM due
+		WHERE r.id = due.id
+		RETURNING `+reviewSLAReturning+`
+	), groups AS (
+		INSERT INTO private.review_groups (org_id, review_id, group_name, status)
+		SELECT DISTINCT e.org_id, e.id, g.name, 'PENDING'::private.enum_reviews_status
+		FROM escalated e, unnest(e.escalation_groups) AS g(name)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM private.review_groups rg
+			WHERE rg.review_id = e.id AND rg.group_name = g.name AND rg.access_schedule_name IS NULL
+		)
+	)
+	SELECT * FROM escalated`,
+		map[string]any{"now": now, "limit": limit}).
+		Scan(&steps).
+		Error
+	return steps, err
+}
+
+// ExpirePendingReviews moves up to limit pending reviews past their
+// expiration deadline to EXPIRED, along with their undecided review groups,
+// and closes their sessions the way a rejection does. Claimed as
+// ClaimReviewReminders claims; the status change is itself the claim.
+func ExpirePendingReviews(ctx context.Context, db *gorm.DB, now time.Time, limit int) ([]ReviewSLAStep, error) {
+	var steps []ReviewSLAStep
+	err := db.WithContext(ctx).Raw(`
+	WITH due AS (
+		SELECT id
+		FROM private.reviews
+		WHERE status = 'PENDING' AND expire_at <= @now
+		ORDER BY expire_at
+		LIMIT @limit
+		FOR UPDATE SKIP LOCKED
+	), expired AS (
+		UPDATE private.reviews r
+		SET status = 'EXPIRED'
+		FROM due
+		WHERE r.id = due.id
+		RETURNING `+reviewSLAReturning+`
+	), groups AS (
+		UPDATE private.review_groups rg
+		SET status = 'EXPIRED'
+		FROM expired e
+		WHERE rg.review_id = e.id AND rg.status = 'PENDING'
+	), sessions AS (
+		UPDATE private.sessions s
+		SET status = 'done'
+		FROM expired e
+		WHERE s.org_id = e.org_id AND s.id = e.session_id AND s.status <> 'done'
+	)
+	SELECT * FROM expired`,
+		map[string]any{"now": now, "limit": limit}).
+		Scan(&steps).
+		Error
+	return steps, err
+}
@@ -0,0 +1,196 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hoophq/hoop/gateway/models"
+)
+
+// The reviewsla job expires, escalates and then reminds in a single sweep.
+// Every claim only takes pending reviews, so a review past its expiration
+// deadline is expired without a reminder or an escalation, and a step is
+// claimed once however many sweeps run.
+func TestReviewSLAClaims(t *testing.T) {
+	startTestDB(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	createdAt := now.Add(-3 * time.Hour)
+	deadline := func(minutes int) *time.Time {
+		v := createdAt.Add(time.Duration(minutes) * time.Minute)
+		return &v
+	}
+	newReview := func(status models.ReviewStatusType, remindAt, escalateAt, expireAt *time.Time) *models.Review {
+		rev := &models.Review{
+			ID:             uuid.NewString(),
+			OrgID:          testOrgID,
+			SessionID:      uuid.NewString(),
+			Type:           models.ReviewTypeOneTime,
+			Status:         status,
+			ConnectionName: "pg-prod",
+			OwnerID:        "user1",
+			OwnerEmail:     "user1@hoop.dev",
+			ReviewGroups: []models.ReviewGroups{
+				{ID: uuid.NewString(), OrgID: testOrgID, GroupName: "dba", Status: models.ReviewStatusPending},
+			},
+			CreatedAt:        createdAt,
+			RemindAt:         remindAt,
+			EscalateAt:       escalateAt,
+			EscalationGroups: []string{"sre"},
+			ExpireAt:         expireAt,
+		}
+		if err := models.CreateReview(rev, ""); err != nil {
+			t.Fatalf("create review: %v", err)
+		}
+		return rev
+	}
+	stepIDs := func(steps []models.ReviewSLAStep, err error) []string {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("claim review sla steps: %v", err)
+		}
+		var ids []string
+		for _, step := range steps {
+			ids = append(ids, step.ID)
+		}
+		return ids
+	}
+	assertSteps := func(name string, got []string, want ...string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("%s claimed %v, want %v", name, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s claimed %v, want %v", name, got, want)
+			}
+		}
+	}
+
+	overdue := newReview(models.ReviewStatusPending, deadline(15), deadline(60), deadline(120))
+	waiting := newReview(models.ReviewStatusPending, deadline(15), deadline(60), deadline(600))
+	newReview(models.ReviewStatusApproved, deadline(15), deadline(60), deadline(120))
+
+	// the order of a sweep
+	assertSteps("expire", stepIDs(models.ExpirePendingReviews(ctx, models.DB, now, 10)), overdue.ID)
+	assertSteps("escalate", stepIDs(models.ClaimReviewEscalations(ctx, models.DB, now, 10)), waiting.ID)
+	assertSteps("remind", stepIDs(models.ClaimReviewReminders(ctx, models.DB, now, 10)), waiting.ID)
+
+	// the next sweep finds nothing left to do
+	assertSteps("expire", stepIDs(models.ExpirePendingReviews(ctx, models.DB, now, 10)))
+	assertSteps("escalate", stepIDs(models.ClaimReviewEscalations(ctx, models.DB, now, 10)))
+	assertSteps("remind", stepIDs(models.ClaimReviewReminders(ctx, models.DB, now, 10)))
+
+	rev, err := models.GetReviewByIdOrSid(testOrgID, overdue.ID)
+	if err != nil {
+		t.Fatalf("get expired review: %v", err)
+	}
+	if rev.Status != models.ReviewStatusExpired || len(rev.ReviewGroups) != 1 ||
+		rev.ReviewGroups[0].Status != models.ReviewStatusExpired {
+		t.Errorf("expected the review and its pending group to be expired, got %+v", rev)
+	}
+
+	rev, err = models.GetReviewByIdOrSid(testOrgID, waiting.ID)
+	if err != nil {
+		t.Fatalf("get escalated review: %v", err)
+	}
+	if rev.Status != models.ReviewStatusPending || rev.EscalatedAt == nil || rev.ReminderSentAt == nil {
+		t.Errorf("expected a pending review escalated and reminded, got %+v", rev)
+	}
+	// the approvals the review needed before the escalation stay the same
+	if rev.MinApprovals == nil || *rev.MinApprovals != 1 {
+		t.Errorf("expected min_approvals=1, got %v", rev.MinApprovals)
+	}
+	groups := map[string]models.ReviewStatusType{}
+	for _, rg := range rev.ReviewGroups {
+		groups[rg.GroupName] = rg.Status
+	}
+	if len(groups) != 2 || groups["dba"] != models.ReviewStatusPending || groups["sre"] != models.ReviewStatusPending {
+		t.Errorf("expected the escalation group to join the review, got %v", groups)
+	}
+}
+
+// An expired review is final: a reviewer deciding it after the job expired it,
+// or a plugin starting its execution, must not reopen it.
+func TestUpdateExpiredReview(t *testing.T) {
+	startTestDB(t)
+	rev := &models.Review{
+		ID:             uuid.NewString(),
+		OrgID:          testOrgID,
+		SessionID:      uuid.NewString(),
+		Type:           models.ReviewTypeOneTime,
+		Status:         models.ReviewStatusExpired,
+		ConnectionName: "pg-prod",
+		OwnerID:        "user1",
+		OwnerEmail:     "user1@hoop.dev",
+		CreatedAt:      time.Now().UTC(),
+	}
+	if err := models.CreateReview(rev, ""); err != nil {
+		t.Fatalf("create review: %v", err)
+	}
+
+	rev.Status = models.ReviewStatusApproved
+	if err := models.UpdateReview(rev); err != models.ErrReviewExpired {
+		t.Errorf("expected ErrReviewExpired, got %v", err)
+	}
+	err := models.UpdateReviewStatus(testOrgID, rev.ID, models.ReviewStatusProcessing)
+	if err != models.ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	got, err := models.GetReviewByIdOrSid(testOrgID, rev.ID)
+	if err != nil {
+		t.Fatalf("get review: %v", err)
+	}
+	if got.Status != models.ReviewStatusExpired {
+		t.Errorf("expected the review to stay expired, got %v", got.Status)
+	}
+}
+
+// The groups approving an access schedule override are not approvals of the
+// rule, so an escalation pins min_approvals to the rule's groups only.
+func TestReviewEscalationCountsRuleGroupsOnly(t *testing.T) {
+	startTestDB(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	escalateAt := now.Add(-time.Minute)
+	scheduleName := "business-hours"
+	rev := &models.Review{
+		ID:             uuid.NewString(),
+		OrgID:          testOrgID,
+		SessionID:      uuid.NewString(),
+		Type:           models.ReviewTypeOneTime,
+		Status:         models.ReviewStatusPending,
+		ConnectionName: "pg-prod",
+		OwnerID:        "user1",
+		OwnerEmail:     "user1@hoop.dev",
+		ReviewGroups: []models.ReviewGroups{
+			{ID: uuid.NewString(), OrgID: testOrgID, GroupName: "dba", Status: models.ReviewStatusPending},
+			{ID: uuid.NewString(), OrgID: testOrgID, GroupName: "security", Status: models.ReviewStatusPending},
+			{ID: uuid.NewString(), OrgID: testOrgID, GroupName: "on-call", Status: models.ReviewStatusPending,
+				AccessScheduleName: &scheduleName},
+		},
+		AccessScheduleNames: []string{scheduleName},
+		CreatedAt:           now.Add(-time.Hour),
+		EscalateAt:          &escalateAt,
+		EscalationGroups:    []string{"sre"},
+	}
+	if err := models.CreateReview(rev, ""); err != nil {
+		t.Fatalf("create review: %v", err)
+	}
+
+	steps, err := models.ClaimReviewEscalations(context.Background(), models.DB, now, 10)
+	if err != nil || len(steps) != 1 {
+		t.Fatalf("expected the review to be escalated, got steps=%+v err=%v", steps, err)
+	}
+	got, err := models.GetReviewByIdOrSid(testOrgID, rev.ID)
+	if err != nil {
+		t.Fatalf("get review: %v", err)
+	}
+	if got.MinApprovals == nil || *got.MinApprovals != 2 {
+		t.Errorf("expected min_approvals=2, got %v", got.MinApprovals)
+	}
+	if len(got.ReviewGroups) != 4 {
+		t.Errorf("expected the escalation group to join the review, got %+v", got.ReviewGroups)
+	}
+}
@@ -164,6 +164,9 @@ type SessionGuardRailMatchedRule struct {
 	Type         string   `json:"type"`
 	Words        []string `json:"words,omitempty"`
 	PatternRegex string   `json:"pattern_regex,omitempty"`
+	Operations   []string `json:"operations,omitempty"`
+	Tables       []string `json:"tables,omitempty"`
+	Access       string   `json:"access,omitempty"`
 }
 
 type SessionGuardRailsInfo struct {
@@ -353,7 +356,8 @@ func GetSessionByID(orgID, sid string) (*Session, error) {
 							'owner_name', rg.owner_name,
 							'owner_slack_id', rg.owner_slack_id,
 							'forced_review', rg.forced_review,
-							'reviewed_at', to_char(rg.reviewed_at, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"')
+							'reviewed_at', to_char(rg.reviewed_at, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+							'access_schedule_name', rg.access_schedule_name
 						)
 					)
 					FROM private.review_groups AS rg
@@ -724,7 +728,8 @@ func ListSessions(orgID string, userId string, isAuditorOrAdmin bool, opt Sessio
 								'owner_name', rg.owner_name,
 								'owner_slack_id', rg.owner_slack_id,
 								'forced_review', rg.forced_review,
-								'reviewed_at', to_char(rg.reviewed_at, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"')
+								'reviewed_at', to_char(rg.reviewed_at, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+								'access_schedule_name', rg.access_schedule_name
 							)
 						)
 						FROM private.review_groups AS rg
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/gateway/appconfig"
+	"github.com/hoophq/hoop/gateway/events"
+	"github.com/hoophq/hoop/gateway/models"
+	slackservice "github.com/hoophq/hoop/gateway/slack"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+	"github.com/hoophq/hoop/gateway/transport/plugins/webhooks"
+	"github.com/hoophq/hoop/gateway/utils"
+)
+
+const (
+	maxBreakGlassJustificationSize = 2000
+	maxBreakGlassIncidentSize      = 255
+)
+
+// ValidateBreakGlass checks that a user with userGroups may skip the review of
+// rule, and that the break-glass request accounts for it. The returned error is
+// meant to be shown to the user.
+func ValidateBreakGlass(rule *models.AccessRequestRule, userGroups []string, justification, incident string) error {
+	if rule == nil || !utils.SlicesHasIntersection(rule.BreakGlassGroups, userGroups) {
+		return fmt.Errorf("break-glass access is not allowed for your groups by the access request rule")
+	}
+	if justification == "" || incident == "" {
+		return fmt.Errorf("break-glass access requires a justification and an incident reference")
+	}
+	if len(justification) > maxBreakGlassJustificationSize || len(incident) > maxBreakGlassIncidentSize {
+		return fmt.Errorf("break-glass justification must not exceed %v characters and the incident reference %v characters",
+			maxBreakGlassJustificationSize, maxBreakGlassIncidentSize)
+	}
+	return nil
+}
+
+// BreakGlassMaxDuration returns how long a break-glass grant of rule may last.
+func BreakGlassMaxDuration(rule *models.AccessRequestRule) time.Duration {
+	if rule.BreakGlassMaxDuration != nil {
+		return time.Duration(*rule.BreakGlassMaxDuration) * time.Second
+	}
+	return models.DefaultBreakGlassMaxDuration
+}
+
+// NewBreakGlassAccess builds the retrospective review of rev, trimming the
+// justification and incident reference the same way ValidateBreakGlass expects them.
+func NewBreakGlassAccess(rev *models.Review, rule *models.AccessRequestRule, justification, incident string) *models.BreakGlassAccess {
+	return &models.BreakGlassAccess{
+		OrgID:                 rev.OrgID,
+		SessionID:             rev.SessionID,
+		ConnectionName:        rev.ConnectionName,
+		AccessRequestRuleName: &rule.Name,
+		OwnerEmail:            rev.OwnerEmail,
+		Justification:         strings.TrimSpace(justification),
+		IncidentReference:     strings.TrimSpace(incident),
+		ReviewersGroups:       rule.ReviewersGroups,
+		CreatedAt:             rev.CreatedAt,
+	}
+}
+
+// NotifyBreakGlass tells the reviewers of a break-glass access through the
+// webhooks, the event catalog and the slack plugin of the connection.
+func NotifyBreakGlass(rev *models.Review, bg *models.BreakGlassAccess) {
+	ruleName := ""
+	if bg.AccessRequestRuleName != nil {
+		ruleName = *bg.AccessRequestRuleName
+	}
+	err := webhooks.SendMessage(bg.OrgID, webhooks.EventBreakGlassUsedType, map[string]any{
+		"event_type": webhooks.EventBreakGlassUsedType,
+		"event_payload": map[string]any{
+			"review_id":           rev.ID,
+			"session_id":          bg.SessionID,
+			"user":                bg.OwnerEmail,
+			"connection":          bg.ConnectionName,
+			"access_request_rule": ruleName,
+			"justification":       bg.Justification,
+			"incident_reference":  bg.IncidentReference,
+			"access_duration_sec": rev.AccessDurationSec,
+			"reviewers_groups":    []string(bg.ReviewersGroups),
+			"created_at":          bg.CreatedAt.Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		log.With("sid", bg.SessionID).Warnf("failed sending break-glass webhook, reason=%v", err)
+	}
+
+	events.PublishBreakGlassUsed(bg.OrgID, events.SessionEventBase{
+		SessionID:  bg.SessionID,
+		User:       bg.OwnerEmail,
+		Connection: bg.ConnectionName,
+		OccurredAt: bg.CreatedAt,
+	}, rev.ID, ruleName, bg.Justification, bg.IncidentReference, rev.AccessDurationSec)
+
+	slackSvc := slackservice.GetServiceInstance(bg.OrgID)
+	if slackSvc == nil || !rev.ConnectionID.Valid {
+		return
+	}
+	pluginConn, err := models.GetPluginConnection(bg.OrgID, plugintypes.PluginSlackName, rev.ConnectionID.String)
+	if err != nil {
+		if err != models.ErrNotFound {
+			log.With("sid", bg.SessionID).Warnf("failed fetching slack plugin connection, reason=%v", err)
+		}
+		return
+	}
+	sessionURL := fmt.Sprintf("%s/sessions/%s", appconfig.Get().ApiURL(), bg.SessionID)
+	msg := fmt.Sprintf("Break-glass: %s accessed %s without a review for incident %s.\n>%s\nReviewers of %v must acknowledge it: %s",
+		bg.OwnerEmail, bg.ConnectionName, bg.IncidentReference, bg.Justification, bg.ReviewersGroups, sessionURL)
+	if err := slackSvc.PostReviewNotice(pluginConn.Config, msg); err != nil {
+		log.With("sid", bg.SessionID).Warnf("failed posting break-glass notice, reason=%v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go/ptr"
+	"github.com/hoophq/hoop/gateway/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBreakGlass(t *testing.T) {
+	rule := &models.AccessRequestRule{Name: "prod", BreakGlassGroups: []string{"sre", "on-call"}}
+	tests := []struct {
+		name          string
+		rule          *models.AccessRequestRule
+		userGroups    []string
+		justification string
+		incident      string
+		expectedErr   string
+	}{
+		{
+			name:          "member of a break-glass group",
+			rule:          rule,
+			userGroups:    []string{"engineering", "on-call"},
+			justification: "database is down",
+			incident:      "INC-42",
+		},
+		{
+			name:          "not a member of any break-glass group",
+			rule:          rule,
+			userGroups:    []string{"engineering"},
+			justification: "database is down",
+			incident:      "INC-42",
+			expectedErr:   "not allowed for your groups",
+		},
+		{
+			name:          "rule without break-glass groups",
+			rule:          &models.AccessRequestRule{Name: "prod"},
+			userGroups:    []string{"sre"},
+			justification: "database is down",
+			incident:      "INC-42",
+			expectedErr:   "not allowed for your groups",
+		},
+		{
+			name:          "no access request rule",
+			userGroups:    []string{"sre"},
+			justification: "database is down",
+			incident:      "INC-42",
+			expectedErr:   "not allowed for your groups",
+		},
+		{
+			name:        "empty justification",
+			rule:        rule,
+			userGroups:  []string{"sre"},
+			incident:    "INC-42",
+			expectedErr: "requires a justification and an incident reference",
+		},
+		{
+			name:          "empty incident reference",
+			rule:          rule,
+			userGroups:    []string{"sre"},
+			justification: "database is down",
+			expectedErr:   "requires a justification and an incident reference",
+		},
+		{
+			name:          "justification at the size limit",
+			rule:          rule,
+			userGroups:    []string{"sre"},
+			justification: strings.Repeat("a", maxBreakGlassJustificationSize),
+			incident:      "INC-42",
+		},
+		{
+			name:          "justification too long",
+			rule:          rule,
+			userGroups:    []string{"sre"},
+			justification: strings.Repeat("a", maxBreakGlassJustificationSize+1),
+			incident:      "INC-42",
+			expectedErr:   "must not exceed",
+		},
+		{
+			name:          "incident reference too long",
+			rule:          rule,
+			userGroups:    []string{"sre"},
+			justification: "database is down",
+			incident:      strings.Repeat("a", maxBreakGlassIncidentSize+1),
+			expectedErr:   "must not exceed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBreakGlass(tt.rule, tt.userGroups, tt.justification, tt.incident)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestBreakGlassMaxDuration(t *testing.T) {
+	require.Equal(t, models.DefaultBreakGlassMaxDuration, BreakGlassMaxDuration(&models.AccessRequestRule{}))
+	require.Equal(t, 15*time.Minute, BreakGlassMaxDuration(&models.AccessRequestRule{BreakGlassMaxDuration: ptr.Int(900)}))
+}
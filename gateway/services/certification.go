@@ -0,0 +1,69 @@
+package services
+
+import (
+	"time"
+
+	"github.com/aws/smithy-go/ptr"
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/gateway/events"
+	"github.com/hoophq/hoop/gateway/models"
+	"github.com/hoophq/hoop/gateway/transport/plugins/webhooks"
+)
+
+// NotifyCertificationStarted tells the reviewers of run, through the
+// webhooks and the event catalog, that it waits for their attestations.
+func NotifyCertificationStarted(run *models.CertificationRun, items int) {
+	orgID := run.OrgID.String()
+	sendCertificationWebhook(orgID, webhooks.EventCertificationStartedType, map[string]any{
+		"run_id":           run.ID.String(),
+		"campaign":         run.CampaignName,
+		"reviewers_groups": []string(run.ReviewersGroups),
+		"items":            items,
+		"deadline_at":      run.DeadlineAt.UTC().Format(time.RFC3339),
+	})
+	events.PublishCertificationStarted(orgID, run.ID.String(), run.CampaignName, run.ReviewersGroups, items, run.DeadlineAt)
+}
+
+// NotifyCertificationRevoked reports a membership removed by run.
+func NotifyCertificationRevoked(run *models.CertificationRun, item *models.CertificationItem) {
+	orgID := run.OrgID.String()
+	reviewer := ptr.ToString(item.DecidedBy)
+	sendCertificationWebhook(orgID, webhooks.EventCertificationRevokedType, map[string]any{
+		"run_id":      run.ID.String(),
+		"campaign":    run.CampaignName,
+		"item_id":     item.ID.String(),
+		"user":        item.UserEmail,
+		"group":       item.GroupName,
+		"connections": []string(item.ConnectionNames),
+		"decision":    item.Decision,
+		"reviewer":    reviewer,
+		"idp_managed": item.IdpManaged,
+	})
+	events.PublishCertificationRevoked(orgID, run.ID.String(), run.CampaignName, item.ID.String(),
+		item.UserEmail, item.GroupName, item.ConnectionNames, item.Decision, reviewer)
+}
+
+// NotifyCertificationCompleted reports the closing of run and every
+// membership it revoked when closing.
+func NotifyCertificationCompleted(run *models.CertificationRun, revoked []models.CertificationItem) {
+	for i := range revoked {
+		NotifyCertificationRevoked(run, &revoked[i])
+	}
+	orgID := run.OrgID.String()
+	sendCertificationWebhook(orgID, webhooks.EventCertificationCompletedType, map[string]any{
+		"run_id":   run.ID.String(),
+		"campaign": run.CampaignName,
+		"revoked":  len(revoked),
+	})
+	events.PublishCertificationCompleted(orgID, run.ID.String(), run.CampaignName, len(revoked))
+}
+
+func sendCertificationWebhook(orgID, eventType string, payload map[string]any) {
+	err := webhooks.SendMessage(orgID, eventType, map[string]any{
+		"event_type":    eventType,
+		"event_payload": payload,
+	})
+	if err != nil {
+		log.With("run", payload["run_id"]).Warnf("failed sending %v webhook, reason=%v", eventType, err)
+	}
+}
@@ -32,7 +32,8 @@ type SlackService struct {
 
 	// sentReviewItems tracks review messages posted by SendMessageReview so they
 	// can be updated when the review state changes outside of a Slack
-	// interaction (API, webapp or MCP review). Key is review_id. Entries are
+	// interaction (API, webapp or MCP review). Key is review_id; an escalated
+	// review holds both the original and the escalation messages. Entries are
 	// removed on terminal updates and expire after sentReviewRetention.
 	sentReviewMu    sync.Mutex
 	sentReviewItems map[string][]sentReviewMessage
@@ -358,7 +359,7 @@ func (s *SlackService) trackSentReviewMessages(reviewID string, sent []sentRevie
 			delete(s.sentReviewItems, id)
 		}
 	}
-	s.sentReviewItems[reviewID] = sent
+	s.sentReviewItems[reviewID] = append(s.sentReviewItems[reviewID], sent...)
 }
 
 // ReviewedGroup describes one approver group's recorded outcome, used to
@@ -376,6 +377,7 @@ type UpdateReviewMessageRequest struct {
 	ReviewID       string
 	IsApproved     bool
 	IsRejected     bool
+	IsExpired      bool
 	ReviewedGroups []ReviewedGroup
 	TotalGroups    int
 }
@@ -397,7 +399,7 @@ func (s *SlackService) HasTrackedReviewMessages(reviewID string) bool {
 // effort: messages posted by another gateway instance or before a restart are
 // not tracked and are silently skipped.
 func (s *SlackService) UpdateReviewMessage(req *UpdateReviewMessageRequest) error {
-	done := req.IsApproved || req.IsRejected
+	done := req.IsApproved || req.IsRejected || req.IsExpired
 	s.sentReviewMu.Lock()
 	items := s.sentReviewItems[req.ReviewID]
 	if done {
@@ -451,7 +453,7 @@ func reviewOutcomeSection(rg ReviewedGroup) *slack.SectionBlock {
 // terminal rejection is never rendered without attribution. Never mutates
 // m.blocks.
 func rebuildReviewBlocks(m *sentReviewMessage, req *UpdateReviewMessageRequest, reviewed map[string]ReviewedGroup) []slack.Block {
-	done := req.IsApproved || req.IsRejected
+	done := req.IsApproved || req.IsRejected || req.IsExpired
 	matched := make(map[string]bool, len(reviewed))
 	blocks := make([]slack.Block, 0, len(m.blocks)+2)
 	for _, b := range m.blocks {
@@ -499,6 +501,13 @@ func rebuildReviewBlocks(m *sentReviewMessage, req *UpdateReviewMessageRequest,
 				Type: slack.MarkdownType,
 				Text: text,
 			}, nil, nil))
+	case req.IsExpired:
+		blocks = append(blocks,
+			slack.NewDividerBlock(),
+			slack.NewSectionBlock(&slack.TextBlockObject{
+				Type: slack.MarkdownType,
+				Text: "*Review expired without a decision*\n",
+			}, nil, nil))
 	case !req.IsRejected:
 		blocks = append(blocks, slack.NewContextBlock("",
 			slack.NewTextBlockObject(slack.MarkdownType,
@@ -683,6 +692,27 @@ func (s *SlackService) PostMessage(slackOrChannelID, message string) error {
 	return nil
 }
 
+// PostReviewNotice posts a plain message about a review to the given
+// channels and the default channel, the same set SendMessageReview posts the
+// review message to.
+func (s *SlackService) PostReviewNotice(slackChannels []string, message string) error {
+	if s.slackChannel != "" && !slices.Contains(slackChannels, s.slackChannel) {
+		slackChannels = append(slackChannels, s.slackChannel)
+	}
+	var errs []string
+	for _, slackChannel := range slackChannels {
+		if _, _, err := s.apiClient.PostMessage(slackChannel, slack.MsgOptionText(message, false)); err != nil {
+			errs = append(errs, fmt.Sprintf(`"%v - %v"`, slackChannel, err))
+		}
+		// Slack allows 1 post message per second. reference: https://api.slack.com/apis/rate-limits
+		time.Sleep(time.Millisecond * 1200)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed posting review notice on channels %v", errs)
+	}
+	return nil
+}
+
 func (s *SlackService) PostEphemeralMessage(msg *MessageReviewResponse, message string, msgArgs ...any) error {
 	channelID := msg.item.Channel.ID
 	userID := msg.item.User.ID
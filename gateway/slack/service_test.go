@@ -178,6 +178,23 @@ func TestRebuildReviewBlocks(t *testing.T) {
 		t.Errorf("synthetic rejection: outcome section missing, blocks=%d", len(blocks))
 	}
 
+	// expiry is terminal: remaining buttons and labels go, the expired notice
+	// replaces the progress context
+	ereq := &UpdateReviewMessageRequest{ReviewID: revID, IsExpired: true,
+		ReviewedGroups: []ReviewedGroup{reviewed["admin"]}, TotalGroups: 2}
+	blocks = rebuildReviewBlocks(m, ereq, reviewed)
+	if len(blocks) != 5 {
+		t.Fatalf("expired: got %d blocks, want 5", len(blocks))
+	}
+	for _, b := range blocks {
+		if _, ok := b.(*slack.ActionBlock); ok {
+			t.Errorf("expired: terminal message must not keep buttons")
+		}
+	}
+	if sec, ok := blocks[4].(*slack.SectionBlock); !ok || !strings.Contains(sec.Text.Text, "expired") {
+		t.Errorf("expired: missing expired section, got %T", blocks[4])
+	}
+
 	// original blocks are shared across channels and must stay intact
 	if _, ok := original[2].(*slack.ActionBlock); !ok {
 		t.Errorf("original block set mutated: %T", original[2])
@@ -328,6 +328,12 @@ func buildLegacyGuardRailErrorMessage(rawInfo []byte) (string, bool) {
 		} else if item.Rule.PatternRegex != "" {
 			parts = append(parts,
 				fmt.Sprintf("match guard rail %s rule, type=%s, patterns=%s", scope, ruleType, item.Rule.PatternRegex))
+		} else if len(item.Rule.Operations) > 0 {
+			parts = append(parts,
+				fmt.Sprintf("match guard rail %s rule, type=%s, operations=%v", scope, ruleType, item.Rule.Operations))
+		} else if len(item.Rule.Tables) > 0 {
+			parts = append(parts,
+				fmt.Sprintf("match guard rail %s rule, type=%s, tables=%v", scope, ruleType, item.Rule.Tables))
 		} else {
 			parts = append(parts, fmt.Sprintf("match guard rail %s rule, type=%s", scope, ruleType))
 		}
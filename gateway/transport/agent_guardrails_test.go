@@ -198,6 +198,18 @@ func TestEncodeGuardRailRules(t *testing.T) {
 		}
 	})
 
+	t.Run("classifier rules are kept from the agent", func(t *testing.T) {
+		payload, err := encodeGuardRailRules(&models.ConnectionGuardRailRules{
+			GuardRailInputRules: []byte(`[{"rules":[{"type":"unbounded_write"}]}]`),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if payload != nil {
+			t.Fatalf("expected nil payload for classifier only rules, got %q", string(payload))
+		}
+	})
+
 	t.Run("invalid rules yield an error", func(t *testing.T) {
 		if _, err := encodeGuardRailRules(&models.ConnectionGuardRailRules{
 			GuardRailInputRules: []byte("{bad-json"),
@@ -279,12 +279,22 @@ func handleExtensionOnReceive(pctx plugintypes.Context, pkt *pb.Packet) error {
 	return transportext.OnReceive(extContext, pkt)
 }
 
-func getGuardRailsRulesForConnection(pctx *plugintypes.Context) (json.RawMessage, error) {
+// getGuardRailsRulesForConnection returns the guardrail payload shipped to the
+// agent and the classifier input rules the gateway enforces itself.
+func getGuardRailsRulesForConnection(pctx *plugintypes.Context) (json.RawMessage, []guardrails.DataRules, error) {
 	connGuardRailRules, err := services.GetGuardRailRulesForConnection(pctx.OrgID, pctx.ConnectionName)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed obtaining guard rail rules, err=%v", err)
+		return nil, nil, status.Errorf(codes.Internal, "failed obtaining guard rail rules, err=%v", err)
 	}
-	return encodeGuardRailRules(connGuardRailRules)
+	guardRailRulesJsonData, err := encodeGuardRailRules(connGuardRailRules)
+	if err != nil {
+		return nil, nil, err
+	}
+	classifierRules, err := decodeGuardRailClassifierRules(connGuardRailRules)
+	if err != nil {
+		return nil, nil, err
+	}
+	return guardRailRulesJsonData, classifierRules, nil
 }
 
 // encodeGuardRailRules turns the connection's stored guardrail rules into the
@@ -330,6 +340,10 @@ func encodeGuardRailRules(connGuardRailRules *models.ConnectionGuardRailRules) (
 		}
 	}
 
+	// classifier rules are enforced by the gateway, the agent would refuse a
+	// rule type it doesn't know
+	inputRules, _ = guardrails.SplitClassifierRules(inputRules)
+	outputRules, _ = guardrails.SplitClassifierRules(outputRules)
 	inputRules = configuredGuardRailRules(inputRules)
 	outputRules = configuredGuardRailRules(outputRules)
 	if len(inputRules) == 0 && len(outputRules) == 0 {
@@ -542,8 +556,9 @@ func (s *Server) processClientPacket(stream *streamclient.ProxyStream, pkt *pb.P
 
 		var guardRailRulesJsonData json.RawMessage
 		if pctx.ClientVerb != pb.ClientVerbPlainExec {
+			var classifierRules []guardrails.DataRules
 			var err error
-			guardRailRulesJsonData, err = getGuardRailsRulesForConnection(&pctx)
+			guardRailRulesJsonData, classifierRules, err = getGuardRailsRulesForConnection(&pctx)
 
 			if err != nil {
 				log.With("sid", pctx.SID, "connection", pctx.ConnectionName).Errorf(err.Error())
@@ -569,6 +584,16 @@ func (s *Server) processClientPacket(stream *streamclient.ProxyStream, pkt *pb.P
 			// gateway-side connection-type or feature-flag gate. libhoop is the sole
 			// authority — it enforces the input rules in-process and fails closed at
 			// proxy construction for protocol paths that cannot evaluate them.
+			//
+			// The classifier rules (operation, table and unbounded_write) are the
+			// exception: they never reach the agent. The gateway evaluates them on
+			// the statements the client sends (see onGuardRailClassifierRules) and
+			// fails closed here for the sessions it can't read statements from.
+			if err := admitGuardRailClassifierRules(&pctx, classifierRules); err != nil {
+				log.With("sid", pctx.SID, "connection", pctx.ConnectionName).Info(err)
+				return err
+			}
+			stream.SetGuardRailClassifierRules(classifierRules)
 		}
 
 		// Resolve the AI session analyzer config for HTTP-family connections.
@@ -644,6 +669,9 @@ func (s *Server) processClientPacket(stream *streamclient.ProxyStream, pkt *pb.P
 		log.With("sid", pctx.SID, "agent-name", pctx.AgentName).Infof("opening session with agent, sent=%v", err == nil)
 		return err
 	default:
+		if denied := onGuardRailClassifierRules(stream, pctx, pkt); denied {
+			return nil
+		}
 		return stream.SendToAgent(pkt)
 	}
 }
@@ -866,7 +894,20 @@ func (s *Server) ReleaseConnectionOnReview(orgID, sid, reviewOwnerSlackID, revie
 
 	proxyStream := streamclient.GetProxyStream(sid)
 	if proxyStream != nil {
-		if reviewStatus == string(openapi.ReviewStatusRejected) {
+		switch reviewStatus {
+		case string(openapi.ReviewStatusExpired):
+			// an expired review was never approved: close the waiting client
+			// like a rejection, with no reviewer to attribute it to
+			expiredMsg := "Access request expired without a review"
+			if err := proxyStream.Send(&pb.Packet{
+				Type:    pbclient.SessionClose,
+				Spec:    map[string][]byte{pb.SpecGatewaySessionID: []byte(sid)},
+				Payload: []byte(expiredMsg),
+			}); err != nil {
+				log.With("sid", sid).Warnf("failed sending session close to client on review expiration: %v", err)
+			}
+			proxyStream.Close(fmt.Errorf("%s", expiredMsg))
+		case string(openapi.ReviewStatusRejected):
 			deniedMsg := buildReviewDeniedMessage(rejectReason, rejectedBy)
 			// Deliver the denial (reason + reviewer) to the client BEFORE tearing
 			// the stream down. Close cancels the stream context, so sending after
@@ -880,7 +921,7 @@ func (s *Server) ReleaseConnectionOnReview(orgID, sid, reviewOwnerSlackID, revie
 				log.With("sid", sid).Warnf("failed sending session close to client on review rejection: %v", err)
 			}
 			proxyStream.Close(fmt.Errorf("%s", deniedMsg))
-		} else {
+		default:
 			if err := proxyStream.Send(&pb.Packet{
 				Type: pbclient.SessionOpenApproveOK,
 				Spec: map[string][]byte{pb.SpecGatewaySessionID: []byte(sid)},
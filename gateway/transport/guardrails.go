@@ -0,0 +1,214 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/pgtypes"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+	pbclient "github.com/hoophq/hoop/common/proto/client"
+	"github.com/hoophq/hoop/gateway/guardrails"
+	"github.com/hoophq/hoop/gateway/models"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+	"github.com/hoophq/hoop/gateway/transport/streamclient"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// guardRailDeniedExitCode is the exit code of an execution denied by a
+// classifier rule, the internal exit code the agent ends its own guardrail
+// denials with.
+const guardRailDeniedExitCode = 254
+
+// decodeGuardRailClassifierRules returns the input rules of the connection
+// the gateway enforces itself. Classifier rules are only accepted on input,
+// see guardrails.ValidateRules.
+func decodeGuardRailClassifierRules(connGuardRailRules *models.ConnectionGuardRailRules) ([]guardrails.DataRules, error) {
+	if connGuardRailRules == nil || connGuardRailRules.GuardRailInputRules == nil {
+		return nil, nil
+	}
+	inputRules, err := guardrails.Decode(connGuardRailRules.GuardRailInputRules)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed decoding guard rail input rules, err=%v", err)
+	}
+	_, classifierRules := guardrails.SplitClassifierRules(inputRules)
+	return classifierRules, nil
+}
+
+// admitGuardRailClassifierRules refuses a session the classifier rules of
+// its connection can't be enforced on. The gateway reads statements from the
+// input of an exec, which runbooks run through as well, in the dialect of a
+// SQL connection, and from the messages of the native Postgres proxy. Any
+// other session would run unguarded, so like the agent (DEP-48) it fails
+// closed instead.
+func admitGuardRailClassifierRules(pctx *plugintypes.Context, classifierRules []guardrails.DataRules) error {
+	if len(classifierRules) == 0 {
+		return nil
+	}
+	connType := pctx.ProtoConnectionType()
+	_, isSQL := guardrails.Protocol(connType)
+	switch {
+	case isSQL && pctx.ClientVerb == pb.ClientVerbExec:
+		return nil
+	case connType == pb.ConnectionTypePostgres && pctx.ClientVerb == pb.ClientVerbConnect:
+		return nil
+	}
+	return status.Errorf(codes.FailedPrecondition,
+		"connection has operation, table or unbounded_write guardrail rules configured, but they can't be enforced "+
+			"on %v sessions of %v connections; remove these rules from the connection to use it this way",
+		pctx.ClientVerb, connType)
+}
+
+// onGuardRailClassifierRules evaluates the classifier rules of the session on
+// a packet sent by the client, answering the client itself on a denial. It
+// reports whether the packet was denied, in which case it must not reach the
+// agent.
+//
+// A denied execution ends the session, as a guardrail denial in the agent
+// does. A denied Postgres statement ends the client connection that sent it
+// with a FATAL error: answering with an ERROR would leave the client waiting
+// on a ReadyForQuery only the server can send.
+func onGuardRailClassifierRules(stream *streamclient.ProxyStream, pctx plugintypes.Context, pkt *pb.Packet) bool {
+	classifierRules := stream.GuardRailClassifierRules()
+	if len(classifierRules) == 0 {
+		return false
+	}
+	proto, _ := guardrails.Protocol(pctx.ProtoConnectionType())
+
+	switch pb.PacketType(pkt.Type) {
+	case pbagent.ExecWriteStdin:
+		err := guardrails.ValidateStatement("input", classifierRules, proto, string(pkt.Payload))
+		var errMatch *guardrails.ErrRuleMatch
+		if !errors.As(err, &errMatch) {
+			return false
+		}
+		msg := guardRailDenialMessage(errMatch)
+		log.With("sid", pctx.SID, "connection", pctx.ConnectionName).Infof("execution denied by guardrails, %v", errMatch)
+		recordGuardRailDenial(pctx, errMatch)
+
+		exitCode := guardRailDeniedExitCode
+		// deliver the denial before closing the stream, closing it cancels
+		// the context the client receives on
+		if err := stream.Send(&pb.Packet{
+			Type:    pbclient.SessionClose,
+			Payload: []byte(msg),
+			Spec: map[string][]byte{
+				pb.SpecGatewaySessionID:  []byte(pctx.SID),
+				pb.SpecClientExitCodeKey: []byte(strconv.Itoa(exitCode)),
+			},
+		}); err != nil {
+			log.With("sid", pctx.SID).Warnf("failed sending session close to client on guardrails denial: %v", err)
+		}
+		_ = stream.Close(plugintypes.NewPacketErr(msg, &exitCode))
+		return true
+	case pbagent.PGConnectionWrite:
+		statements, err := pgClientStatements(pkt.Payload)
+		var msg string
+		if err != nil {
+			// a statement the gateway can't read is one it can't vouch for
+			msg = "Blocked by Guardrails: unable to read the statement to enforce the rules on it"
+			log.With("sid", pctx.SID, "connection", pctx.ConnectionName).Warnf("%v, reason=%v", msg, err)
+		} else {
+			var errMatch *guardrails.ErrRuleMatch
+			for _, statement := range statements {
+				err := guardrails.ValidateStatement("input", classifierRules, proto, statement)
+				if errors.As(err, &errMatch) {
+					break
+				}
+			}
+			if errMatch == nil {
+				return false
+			}
+			msg = guardRailDenialMessage(errMatch)
+			log.With("sid", pctx.SID, "connection", pctx.ConnectionName).Infof("postgres statement denied by guardrails, %v", errMatch)
+			recordGuardRailDenial(pctx, errMatch)
+		}
+
+		connSpec := map[string][]byte{
+			pb.SpecGatewaySessionID:   []byte(pctx.SID),
+			pb.SpecClientConnectionID: pkt.Spec[pb.SpecClientConnectionID],
+		}
+		_ = stream.Send(&pb.Packet{
+			Type:    pbclient.PGConnectionWrite,
+			Payload: pgtypes.NewFatalError("%s", msg).Encode(),
+			Spec:    connSpec,
+		})
+		_ = stream.Send(&pb.Packet{Type: pbclient.TCPConnectionClose, Spec: connSpec})
+		_ = stream.SendToAgent(&pb.Packet{Type: pbagent.TCPConnectionClose, Spec: connSpec})
+		return true
+	}
+	return false
+}
+
+// pgClientStatements returns the statements of the simple query and parse
+// messages in the payload of a client packet. Other messages carry no
+// statement to evaluate. A payload starting with an untyped message, the
+// startup, SSL and cancel requests, carries none either.
+func pgClientStatements(payload []byte) ([]string, error) {
+	if len(payload) == 0 || payload[0] == 0x00 {
+		return nil, nil
+	}
+	var statements []string
+	buf := bytes.NewBuffer(payload)
+	for buf.Len() > 0 {
+		pkt, err := pgtypes.Decode(buf)
+		if err != nil {
+			return nil, err
+		}
+		switch pkt.Type() {
+		case pgtypes.ClientSimpleQuery, pgtypes.ClientParse:
+		default:
+			continue
+		}
+		// a parse message holds at least the terminators of its name and
+		// query, a simple query the one of its query
+		if len(pkt.Frame()) < 2 && pkt.Type() == pgtypes.ClientParse || len(pkt.Frame()) < 1 {
+			return nil, fmt.Errorf("malformed %v message", pkt.Type())
+		}
+		query := pgtypes.ParseQuery(pkt.Encode())
+		if query == nil {
+			return nil, fmt.Errorf("malformed %v message", pkt.Type())
+		}
+		statements = append(statements, string(query))
+	}
+	return statements, nil
+}
+
+// guardRailDenialMessage is the message shown to the client, prefixed with
+// the message of the rule when the admin defined one.
+func guardRailDenialMessage(errMatch *guardrails.ErrRuleMatch) string {
+	msg := "Blocked by the following Guardrails rule: " + errMatch.Error()
+	if ruleMessage := errMatch.Rule().Message; ruleMessage != "" {
+		msg = "Blocked by the following Guardrails rule: " + ruleMessage + ", " + errMatch.Error()
+	}
+	return msg
+}
+
+// recordGuardRailDenial appends the match to the guardrails info of the
+// session, in the shape the agent reports its own matches with.
+func recordGuardRailDenial(pctx plugintypes.Context, errMatch *guardrails.ErrRuleMatch) {
+	rule := errMatch.Rule()
+	rawInfo, err := json.Marshal([]models.SessionGuardRailsInfo{{
+		Rule: models.SessionGuardRailMatchedRule{
+			Type:       rule.Type,
+			Operations: rule.Operations,
+			Tables:     rule.Tables,
+			Access:     rule.Access,
+		},
+		Direction:    errMatch.Direction(),
+		MatchedWords: errMatch.Matched(),
+		Message:      rule.Message,
+	}})
+	if err != nil {
+		log.With("sid", pctx.SID).Errorf("unable to encode guardrails info, reason=%v", err)
+		return
+	}
+	if err := models.UpdateSessionGuardRailsInfo(pctx.OrgID, pctx.SID, rawInfo); err != nil {
+		log.With("sid", pctx.SID).Errorf("unable to save guardrails info, reason=%v", err)
+	}
+}
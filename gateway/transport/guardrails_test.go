@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"encoding/binary"
+	"testing"
+
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/hoophq/hoop/gateway/models"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDecodeGuardRailClassifierRules(t *testing.T) {
+	rules, err := decodeGuardRailClassifierRules(&models.ConnectionGuardRailRules{
+		GuardRailInputRules:  []byte(`[{"rules":[{"type":"deny_words_list","words":["SECRET"]},{"type":"operation","operations":["drop"]}]}]`),
+		GuardRailOutputRules: []byte(`[{"rules":[{"type":"deny_words_list","words":["SECRET"]}]}]`),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rules) != 1 || len(rules[0].Items) != 1 || rules[0].Items[0].Type != "operation" {
+		t.Fatalf("expected only the operation rule, got %+v", rules)
+	}
+
+	rules, err = decodeGuardRailClassifierRules(&models.ConnectionGuardRailRules{GuardRailInputRules: []byte("[]")})
+	if err != nil || len(rules) != 0 {
+		t.Fatalf("expected no rules for a connection without guardrails, got rules=%+v err=%v", rules, err)
+	}
+}
+
+// Classifier rules a session can't be read through must refuse the session,
+// otherwise the rules would silently not apply.
+func TestAdmitGuardRailClassifierRules(t *testing.T) {
+	rules, _ := decodeGuardRailClassifierRules(&models.ConnectionGuardRailRules{
+		GuardRailInputRules: []byte(`[{"rules":[{"type":"unbounded_write"}]}]`),
+	})
+	for _, tt := range []struct {
+		connType string
+		subType  string
+		verb     string
+		admitted bool
+	}{
+		{"database", "postgres", pb.ClientVerbExec, true},
+		{"database", "mysql", pb.ClientVerbExec, true},
+		{"database", "mssql", pb.ClientVerbExec, true},
+		{"database", "postgres", pb.ClientVerbConnect, true},
+		{"database", "mysql", pb.ClientVerbConnect, false},
+		{"database", "mongodb", pb.ClientVerbExec, false},
+		{"custom", "", pb.ClientVerbExec, false},
+	} {
+		pctx := &plugintypes.Context{ConnectionType: tt.connType, ConnectionSubType: tt.subType, ClientVerb: tt.verb}
+		err := admitGuardRailClassifierRules(pctx, rules)
+		if tt.admitted && err != nil {
+			t.Errorf("%v/%v %v: unexpected error: %v", tt.connType, tt.subType, tt.verb, err)
+		}
+		if !tt.admitted && status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("%v/%v %v: expected the session to be refused, got %v", tt.connType, tt.subType, tt.verb, err)
+		}
+		if err := admitGuardRailClassifierRules(pctx, nil); err != nil {
+			t.Errorf("%v/%v %v: a session without classifier rules must be admitted, got %v", tt.connType, tt.subType, tt.verb, err)
+		}
+	}
+}
+
+func newPGMessage(typ byte, frame []byte) []byte {
+	msg := []byte{typ, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(msg[1:], uint32(len(frame)+4))
+	return append(msg, frame...)
+}
+
+func TestPGClientStatements(t *testing.T) {
+	query := newPGMessage('Q', []byte("DELETE FROM orders\x00"))
+	parse := newPGMessage('P', []byte("stmt\x00UPDATE orders SET status = $1\x00\x00\x00"))
+	sync := newPGMessage('S', nil)
+
+	statements, err := pgClientStatements(query)
+	if err != nil || len(statements) != 1 || statements[0] != "DELETE FROM orders" {
+		t.Fatalf("unexpected simple query statements=%q err=%v", statements, err)
+	}
+
+	// a statement must not hide behind another message of the same packet
+	payload := append(append(append([]byte{}, sync...), parse...), sync...)
+	statements, err = pgClientStatements(payload)
+	if err != nil || len(statements) != 1 || statements[0] != "UPDATE orders SET status = $1" {
+		t.Fatalf("unexpected parse statements=%q err=%v", statements, err)
+	}
+
+	// startup message
+	statements, err = pgClientStatements([]byte{0, 0, 0, 8, 0, 3, 0, 0})
+	if err != nil || statements != nil {
+		t.Fatalf("expected no statements for a startup message, got %q err=%v", statements, err)
+	}
+
+	if _, err := pgClientStatements(query[:len(query)-3]); err == nil {
+		t.Fatal("expected an error for a truncated message")
+	}
+	if _, err := pgClientStatements(newPGMessage('P', []byte("stmt"))); err == nil {
+		t.Fatal("expected an error for a malformed parse message")
+	}
+}
@@ -27,6 +27,11 @@ func getValidatedJitReview(pctx plugintypes.Context) (*plugintypes.ConnectRespon
 		return nil, plugintypes.InternalErr("failed listing time based reviews", err)
 	}
 
+	if jitr != nil && !coversAccessScheduleOverrides(jitr.AccessScheduleNames, pctx.AccessScheduleOverrides) {
+		log.With("sid", pctx.SID, "id", jitr.ID, "user", jitr.OwnerEmail, "org", pctx.OrgID).
+			Infof("jit review does not override the access schedules denying the session")
+		jitr = nil
+	}
 	if jitr != nil {
 		err = validateJit(jitr, time.Now().UTC())
 		switch err {
@@ -82,9 +87,28 @@ func getValidatedOneTimeReview(pctx plugintypes.Context) (bool, *plugintypes.Con
 	return false, nil, nil
 }
 
-func createReview(pctx plugintypes.Context, isJitReview bool, accessRequestRule *models.AccessRequestRule, accessDuration time.Duration, sessionInput string, inputEnvVars map[string]string, inputClientArgs []string) (*models.Review, error) {
+// newRuleReview builds the review accessRequestRule requires of the session.
+func newRuleReview(pctx plugintypes.Context, isJitReview bool, accessRequestRule *models.AccessRequestRule, accessDuration time.Duration, inputEnvVars map[string]string, inputClientArgs []string) *models.Review {
+	var minApprovals int
+	if accessRequestRule.AllGroupsMustApprove {
+		minApprovals = len(accessRequestRule.ReviewersGroups)
+	} else {
+		minApprovals = *accessRequestRule.MinApprovals
+	}
+
+	newRev := newReview(pctx, isJitReview, accessRequestRule.ReviewersGroups, accessDuration, inputEnvVars, inputClientArgs)
+	newRev.ForceApprovalGroups = accessRequestRule.ForceApprovalGroups
+	newRev.AccessRequestRuleName = &accessRequestRule.Name
+	newRev.MinApprovals = &minApprovals
+	accessRequestRule.ApplyReviewSLA(newRev)
+	return newRev
+}
+
+// newReview builds a pending review of the session, with one pending group
+// per reviewers group.
+func newReview(pctx plugintypes.Context, isJitReview bool, reviewersGroups []string, accessDuration time.Duration, inputEnvVars map[string]string, inputClientArgs []string) *models.Review {
 	var reviewGroups []models.ReviewGroups
-	for _, approvalGroupName := range accessRequestRule.ReviewersGroups {
+	for _, approvalGroupName := range reviewersGroups {
 		reviewGroups = append(reviewGroups, models.ReviewGroups{
 			ID:        uuid.NewString(),
 			OrgID:     pctx.OrgID,
@@ -98,52 +122,71 @@ func createReview(pctx plugintypes.Context, isJitReview bool, accessRequestRule
 		reviewType = models.ReviewTypeJit
 	}
 
-	var minApprovals int
-	if accessRequestRule.AllGroupsMustApprove {
-		minApprovals = len(reviewGroups)
-	} else {
-		minApprovals = *accessRequestRule.MinApprovals
-	}
-
-	newRev := &models.Review{
-		ID:                    uuid.NewString(),
-		OrgID:                 pctx.OrgID,
-		Type:                  reviewType,
-		SessionID:             pctx.SID,
-		ConnectionName:        pctx.ConnectionName,
-		ConnectionID:          sql.NullString{String: pctx.ConnectionID, Valid: true},
-		AccessDurationSec:     int64(accessDuration.Seconds()),
-		InputEnvVars:          inputEnvVars,
-		InputClientArgs:       inputClientArgs,
-		OwnerID:               pctx.UserID,
-		OwnerEmail:            pctx.UserEmail,
-		OwnerName:             ptr.String(pctx.UserName),
-		OwnerSlackID:          ptr.String(pctx.UserSlackID),
-		Status:                models.ReviewStatusPending,
-		ReviewGroups:          reviewGroups,
-		ForceApprovalGroups:   accessRequestRule.ForceApprovalGroups,
-		AccessRequestRuleName: &accessRequestRule.Name,
-		MinApprovals:          &minApprovals,
-		CreatedAt:             time.Now().UTC(),
-		RevokedAt:             nil,
+	return &models.Review{
+		ID:                uuid.NewString(),
+		OrgID:             pctx.OrgID,
+		Type:              reviewType,
+		SessionID:         pctx.SID,
+		ConnectionName:    pctx.ConnectionName,
+		ConnectionID:      sql.NullString{String: pctx.ConnectionID, Valid: true},
+		AccessDurationSec: int64(accessDuration.Seconds()),
+		InputEnvVars:      inputEnvVars,
+		InputClientArgs:   inputClientArgs,
+		OwnerID:           pctx.UserID,
+		OwnerEmail:        pctx.UserEmail,
+		OwnerName:         ptr.String(pctx.UserName),
+		OwnerSlackID:      ptr.String(pctx.UserSlackID),
+		Status:            models.ReviewStatusPending,
+		ReviewGroups:      reviewGroups,
+		CreatedAt:         time.Now().UTC(),
+		RevokedAt:         nil,
 	}
+}
 
+func saveReview(pctx plugintypes.Context, newRev *models.Review, sessionInput string) error {
 	log.With("sid", pctx.SID, "id", newRev.ID, "user", pctx.UserID, "org", pctx.OrgID,
-		"type", reviewType, "duration", fmt.Sprintf("%vm", accessDuration.Minutes())).
+		"type", newRev.Type, "duration", fmt.Sprintf("%vm", time.Duration(newRev.AccessDurationSec*int64(time.Second)).Minutes())).
 		Infof("creating review")
 
 	if err := models.CreateReview(newRev, sessionInput); err != nil {
-		return nil, plugintypes.InternalErr("failed saving review", err)
+		return plugintypes.InternalErr("failed saving review", err)
 	}
 
 	// update session input when executing ad-hoc executions via cli
 	if strings.HasPrefix(pctx.ClientOrigin, pb.ConnectionOriginClient) {
 		if err := models.UpdateSessionInput(pctx.OrgID, pctx.SID, sessionInput); err != nil {
-			return nil, plugintypes.InternalErr("failed updating session input", err)
+			return plugintypes.InternalErr("failed updating session input", err)
 		}
 	}
+	return nil
+}
 
-	return newRev, nil
+// parseSessionInput decodes the input of an ad-hoc execution from its
+// session open packet.
+func parseSessionInput(pkt *pb.Packet) (sessionInput string, inputEnvVars map[string]string, inputClientArgs []string, err error) {
+	sessionInput = string(pkt.Payload)
+	if encInputEnvVars, ok := pkt.Spec[pb.SpecClientExecEnvVar]; ok {
+		if err := pb.GobDecodeInto(encInputEnvVars, &inputEnvVars); err != nil {
+			return "", nil, nil, plugintypes.InternalErr("failed decoding input env vars", err)
+		}
+	}
+	if encInputClientArgs, ok := pkt.Spec[pb.SpecClientExecArgsKey]; ok {
+		if err := pb.GobDecodeInto(encInputClientArgs, &inputClientArgs); err != nil {
+			return "", nil, nil, plugintypes.InternalErr("failed decoding input client args", err)
+		}
+	}
+	return sessionInput, inputEnvVars, inputClientArgs, nil
+}
+
+// waitingApproval is the response holding the client until its review is
+// decided.
+func waitingApproval(pctx plugintypes.Context, pkt *pb.Packet, newRev *models.Review) *plugintypes.ConnectResponse {
+	setSpecReview(pkt)
+	return &plugintypes.ConnectResponse{Context: nil, ClientPacket: &pb.Packet{
+		Type:    pbclient.SessionOpenWaitingApproval,
+		Payload: fmt.Appendf(nil, "%s/sessions/%s", appconfig.Get().FullApiURL(), newRev.SessionID),
+		Spec:    map[string][]byte{pb.SpecGatewaySessionID: []byte(pctx.SID)},
+	}}
 }
 
 func OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plugintypes.ConnectResponse, error) {
@@ -156,7 +199,22 @@ func OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plugintypes.ConnectRe
 		return nil, nil
 	}
 
+	// access schedules denied the session unless a review overrides them,
+	// which the review of the access request rule then waits on as well
+	overrides := pctx.AccessScheduleOverrides
+
 	// 1. check if there's an existing one-time review for this session, if yes validate and return it
+	if len(overrides) > 0 {
+		otrev, err := models.GetReviewByIdOrSid(pctx.OrgID, pctx.SID)
+		if err != nil && err != models.ErrNotFound {
+			return nil, plugintypes.InternalErr("failed fetching review", err)
+		}
+		if otrev != nil && otrev.Type == models.ReviewTypeOneTime &&
+			!coversAccessScheduleOverrides(otrev.AccessScheduleNames, overrides) {
+			return nil, plugintypes.InvalidArgument("%s, the approval of this session does not override it, "+
+				"run it again to request an override", overrides[0].Reason)
+		}
+	}
 	isApproved, resp, err := getValidatedOneTimeReview(pctx)
 	if err != nil {
 		return nil, err
@@ -204,27 +262,29 @@ func OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plugintypes.ConnectRe
 	if accessRule == nil {
 		log.With("sid", pctx.SID, "orgid", pctx.OrgID, "user-id", pctx.UserID, "connection-id", pctx.ConnectionID,
 			"access-type", accessType).Infof("no access rule found for this resource and access type")
+	} else if !ruleRequiresReview(pctx, accessRule) {
+		accessRule = nil
+	}
+	if accessRule == nil && len(overrides) == 0 {
 		return nil, nil
 	}
 
-	if len(accessRule.ApprovalRequiredGroups) > 0 {
-		needsReview := utils.SlicesHasIntersection(accessRule.ApprovalRequiredGroups, pctx.UserGroups)
-		if !needsReview {
-			log.With("sid", pctx.SID, "orgid", pctx.GetOrgID(), "user-id", pctx.UserID, "connection-id", pctx.ConnectionID,
-				"access-rule-id", accessRule.ID).Infof("user is not part of access rule approval groups, skipping review")
-			return nil, nil
-		}
-	}
+	// break-glass requests bound their own duration, see onBreakGlass. They
+	// skip the review of a rule, never the override of an access schedule.
+	breakGlass := isBreakGlassRequest(pkt) && accessRule != nil && len(overrides) == 0
 
 	// Access duration for JIT reviews
 	var accessDuration time.Duration
-	if isJitReview {
+	if isJitReview && !breakGlass {
 		accessDuration, err = time.ParseDuration(string(durationStr))
 		if err != nil {
 			return nil, plugintypes.InvalidArgument("invalid access time duration, got=%v", string(durationStr))
 		}
 
-		var accessMaxDuration = accessRule.AccessMaxDuration
+		var accessMaxDuration *int
+		if accessRule != nil {
+			accessMaxDuration = accessRule.AccessMaxDuration
+		}
 		if accessMaxDuration != nil {
 			maxDuration := time.Duration(*accessMaxDuration) * time.Second
 
@@ -237,11 +297,8 @@ func OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plugintypes.ConnectRe
 		}
 	}
 
-	if len(accessRule.ApprovalRequiredGroups) == 0 && len(accessRule.SkipReviewGroups) > 0 &&
-		utils.SlicesHasIntersection(accessRule.SkipReviewGroups, pctx.UserGroups) {
-		log.With("sid", pctx.SID, "orgid", pctx.GetOrgID(), "user-id", pctx.UserID, "connection-id", pctx.ConnectionID,
-			"access-rule-id", accessRule.ID).Infof("user is part of access rule skip review groups, skipping review")
-		return nil, nil
+	if breakGlass {
+		return onBreakGlass(pctx, pkt, accessRule, isJitReview)
 	}
 
 	// these values are only used for ad-hoc executions
@@ -249,31 +306,56 @@ func OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plugintypes.ConnectRe
 	var inputEnvVars map[string]string
 	var inputClientArgs []string
 	if !isJitReview {
-		sessionInput = string(pkt.Payload)
-		if encInputEnvVars, ok := pkt.Spec[pb.SpecClientExecEnvVar]; ok {
-			if err := pb.GobDecodeInto(encInputEnvVars, &inputEnvVars); err != nil {
-				return nil, plugintypes.InternalErr("failed decoding input env vars", err)
-			}
-		}
-		if encInputClientArgs, ok := pkt.Spec[pb.SpecClientExecArgsKey]; ok {
-			if err := pb.GobDecodeInto(encInputClientArgs, &inputClientArgs); err != nil {
-				return nil, plugintypes.InternalErr("failed decoding input client args", err)
-			}
+		sessionInput, inputEnvVars, inputClientArgs, err = parseSessionInput(pkt)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	newRev, err := createReview(pctx, isJitReview, accessRule, accessDuration, sessionInput, inputEnvVars, inputClientArgs)
-	if err != nil {
+	newRev := newSessionReview(pctx, isJitReview, accessRule, overrides, accessDuration, inputEnvVars, inputClientArgs)
+	if err := saveReview(pctx, newRev, sessionInput); err != nil {
 		return nil, err
 	}
+	return waitingApproval(pctx, pkt, newRev), nil
+}
 
-	setSpecReview(pkt)
+// newSessionReview builds the review the session waits on: the review of
+// accessRule, when one applies, and the override of every access schedule
+// denying it.
+func newSessionReview(pctx plugintypes.Context, isJitReview bool, accessRule *models.AccessRequestRule, overrides []plugintypes.AccessScheduleOverride, accessDuration time.Duration, inputEnvVars map[string]string, inputClientArgs []string) *models.Review {
+	var newRev *models.Review
+	if accessRule != nil {
+		newRev = newRuleReview(pctx, isJitReview, accessRule, accessDuration, inputEnvVars, inputClientArgs)
+	} else {
+		newRev = newReview(pctx, isJitReview, nil, accessDuration, inputEnvVars, inputClientArgs)
+	}
+	if len(overrides) > 0 {
+		log.With("sid", pctx.SID, "orgid", pctx.OrgID, "user-id", pctx.UserID, "connection-id", pctx.ConnectionID,
+			"schedules", len(overrides)).Infof("requesting review to override access schedules, reason=%v", overrides[0].Reason)
+		addAccessScheduleOverrides(pctx, newRev, overrides)
+	}
+	return newRev
+}
 
-	return &plugintypes.ConnectResponse{Context: nil, ClientPacket: &pb.Packet{
-		Type:    pbclient.SessionOpenWaitingApproval,
-		Payload: fmt.Appendf(nil, "%s/sessions/%s", appconfig.Get().FullApiURL(), newRev.SessionID),
-		Spec:    map[string][]byte{pb.SpecGatewaySessionID: []byte(pctx.SID)},
-	}}, nil
+// ruleRequiresReview reports whether accessRule requires the user to wait on
+// a review, as its approval required and skip review groups decide.
+func ruleRequiresReview(pctx plugintypes.Context, accessRule *models.AccessRequestRule) bool {
+	if len(accessRule.ApprovalRequiredGroups) > 0 {
+		needsReview := utils.SlicesHasIntersection(accessRule.ApprovalRequiredGroups, pctx.UserGroups)
+		if !needsReview {
+			log.With("sid", pctx.SID, "orgid", pctx.GetOrgID(), "user-id", pctx.UserID, "connection-id", pctx.ConnectionID,
+				"access-rule-id", accessRule.ID).Infof("user is not part of access rule approval groups, skipping review")
+			return false
+		}
+	}
+
+	if len(accessRule.ApprovalRequiredGroups) == 0 && len(accessRule.SkipReviewGroups) > 0 &&
+		utils.SlicesHasIntersection(accessRule.SkipReviewGroups, pctx.UserGroups) {
+		log.With("sid", pctx.SID, "orgid", pctx.GetOrgID(), "user-id", pctx.UserID, "connection-id", pctx.ConnectionID,
+			"access-rule-id", accessRule.ID).Infof("user is part of access rule skip review groups, skipping review")
+		return false
+	}
+	return true
 }
 
 // indicate to other plugins that this packet has the review enabled
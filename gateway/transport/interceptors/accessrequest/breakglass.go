@@ -0,0 +1,104 @@
+package accessrequestinterceptor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hoophq/hoop/common/log"
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/hoophq/hoop/gateway/models"
+	"github.com/hoophq/hoop/gateway/services"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+)
+
+func isBreakGlassRequest(pkt *pb.Packet) bool {
+	_, hasJustification := pkt.Spec[pb.SpecBreakGlassJustification]
+	_, hasIncident := pkt.Spec[pb.SpecBreakGlassIncident]
+	return hasJustification || hasIncident
+}
+
+// onBreakGlass grants the session without waiting for the review of
+// accessRule. The review is saved already decided, with a break-glass record
+// the rule's reviewers acknowledge afterwards, and they are notified at once.
+func onBreakGlass(pctx plugintypes.Context, pkt *pb.Packet, accessRule *models.AccessRequestRule, isJitReview bool) (*plugintypes.ConnectResponse, error) {
+	justification := strings.TrimSpace(string(pkt.Spec[pb.SpecBreakGlassJustification]))
+	incident := strings.TrimSpace(string(pkt.Spec[pb.SpecBreakGlassIncident]))
+	newRev, sessionInput, err := newBreakGlassReview(pctx, pkt, accessRule, isJitReview, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	accessDuration := time.Duration(newRev.AccessDurationSec) * time.Second
+	bg := services.NewBreakGlassAccess(newRev, accessRule, justification, incident)
+
+	log.With("sid", pctx.SID, "id", newRev.ID, "user", pctx.UserEmail, "org", pctx.OrgID,
+		"rule", accessRule.Name, "incident", incident, "duration", accessDuration.String()).
+		Warnf("break-glass access granted")
+	if err := models.CreateBreakGlassReview(newRev, bg, sessionInput); err != nil {
+		return nil, plugintypes.InternalErr("failed saving break-glass review", err)
+	}
+	if strings.HasPrefix(pctx.ClientOrigin, pb.ConnectionOriginClient) && sessionInput != "" {
+		if err := models.UpdateSessionInput(pctx.OrgID, pctx.SID, sessionInput); err != nil {
+			return nil, plugintypes.InternalErr("failed updating session input", err)
+		}
+	}
+
+	go services.NotifyBreakGlass(newRev, bg)
+
+	if !isJitReview {
+		return nil, nil
+	}
+	newCtx, cancel := context.WithTimeout(pctx.Context, accessDuration)
+	_ = cancel // the context expires via timeout or when the parent context is done
+	setSpecReview(pkt)
+	return &plugintypes.ConnectResponse{Context: newCtx, ClientPacket: nil}, nil
+}
+
+// newBreakGlassReview builds the review a break-glass request is granted
+// with. A jit access lasts the requested duration, bounded by the rule, or
+// the rule's bound when none was requested; it's saved approved. An ad-hoc
+// execution starts right away, so its review is saved processing.
+func newBreakGlassReview(pctx plugintypes.Context, pkt *pb.Packet, accessRule *models.AccessRequestRule, isJitReview bool, now time.Time) (*models.Review, string, error) {
+	justification := strings.TrimSpace(string(pkt.Spec[pb.SpecBreakGlassJustification]))
+	incident := strings.TrimSpace(string(pkt.Spec[pb.SpecBreakGlassIncident]))
+	if err := services.ValidateBreakGlass(accessRule, pctx.UserGroups, justification, incident); err != nil {
+		return nil, "", plugintypes.InvalidArgument("%v", err)
+	}
+	maxDuration := services.BreakGlassMaxDuration(accessRule)
+
+	var accessDuration time.Duration
+	var sessionInput string
+	var inputEnvVars map[string]string
+	var inputClientArgs []string
+	var err error
+	if isJitReview {
+		accessDuration = maxDuration
+		if d, ok := pkt.Spec[pb.SpecJitTimeout]; ok {
+			accessDuration, err = time.ParseDuration(string(d))
+			if err != nil {
+				return nil, "", plugintypes.InvalidArgument("invalid access time duration, got=%v", string(d))
+			}
+			if accessDuration > maxDuration {
+				return nil, "", plugintypes.InvalidArgument("break-glass access must not be greater than %v", maxDuration)
+			}
+		}
+	} else {
+		sessionInput, inputEnvVars, inputClientArgs, err = parseSessionInput(pkt)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	newRev := newReview(pctx, isJitReview, nil, accessDuration, inputEnvVars, inputClientArgs)
+	newRev.AccessRequestRuleName = &accessRule.Name
+	newRev.CreatedAt = now
+	if isJitReview {
+		revokeAt := now.Add(accessDuration)
+		newRev.Status = models.ReviewStatusApproved
+		newRev.RevokedAt = &revokeAt
+	} else {
+		// the execution starts right away, as it does for an approved review
+		newRev.Status = models.ReviewStatusProcessing
+	}
+	return newRev, sessionInput, nil
+}
@@ -0,0 +1,111 @@
+package accessrequestinterceptor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go/ptr"
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/hoophq/hoop/gateway/models"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBreakGlassReview(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	accessRule := &models.AccessRequestRule{
+		Name:                  "prod-approval",
+		ReviewersGroups:       []string{"dba"},
+		BreakGlassGroups:      []string{"sre"},
+		BreakGlassMaxDuration: ptr.Int(1800),
+	}
+	breakGlassSpec := func(jitTimeout string) map[string][]byte {
+		spec := map[string][]byte{
+			pb.SpecBreakGlassJustification: []byte("database is down"),
+			pb.SpecBreakGlassIncident:      []byte("INC-42"),
+		}
+		if jitTimeout != "" {
+			spec[pb.SpecJitTimeout] = []byte(jitTimeout)
+		}
+		return spec
+	}
+	tests := []struct {
+		name             string
+		userGroups       []string
+		isJitReview      bool
+		pkt              *pb.Packet
+		expectedErr      string
+		expectedStatus   models.ReviewStatusType
+		expectedDuration time.Duration
+		expectedInput    string
+	}{
+		{
+			name:             "jit access defaults to the rule maximum",
+			userGroups:       []string{"sre"},
+			isJitReview:      true,
+			pkt:              &pb.Packet{Spec: breakGlassSpec("")},
+			expectedStatus:   models.ReviewStatusApproved,
+			expectedDuration: 30 * time.Minute,
+		},
+		{
+			name:             "jit access within the rule maximum",
+			userGroups:       []string{"sre"},
+			isJitReview:      true,
+			pkt:              &pb.Packet{Spec: breakGlassSpec("10m")},
+			expectedStatus:   models.ReviewStatusApproved,
+			expectedDuration: 10 * time.Minute,
+		},
+		{
+			name:        "jit access above the rule maximum",
+			userGroups:  []string{"sre"},
+			isJitReview: true,
+			pkt:         &pb.Packet{Spec: breakGlassSpec("31m")},
+			expectedErr: "break-glass access must not be greater than 30m0s",
+		},
+		{
+			name:        "jit access with an invalid duration",
+			userGroups:  []string{"sre"},
+			isJitReview: true,
+			pkt:         &pb.Packet{Spec: breakGlassSpec("forever")},
+			expectedErr: "invalid access time duration",
+		},
+		{
+			name:           "one time execution starts right away",
+			userGroups:     []string{"sre"},
+			pkt:            &pb.Packet{Payload: []byte("SELECT 1"), Spec: breakGlassSpec("")},
+			expectedStatus: models.ReviewStatusProcessing,
+			expectedInput:  "SELECT 1",
+		},
+		{
+			name:        "user outside the break-glass groups",
+			userGroups:  []string{"dba"},
+			isJitReview: true,
+			pkt:         &pb.Packet{Spec: breakGlassSpec("")},
+			expectedErr: "not allowed for your groups",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pctx := plugintypes.Context{OrgID: "org", SID: "sid", UserID: "user", UserGroups: tt.userGroups}
+			rev, sessionInput, err := newBreakGlassReview(pctx, tt.pkt, accessRule, tt.isJitReview, now)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				assert.Nil(t, rev)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rev.Status)
+			assert.Equal(t, "prod-approval", *rev.AccessRequestRuleName)
+			assert.Equal(t, tt.expectedInput, sessionInput)
+			assert.Equal(t, int64(tt.expectedDuration.Seconds()), rev.AccessDurationSec)
+			if tt.isJitReview {
+				assert.Equal(t, models.ReviewTypeJit, rev.Type)
+				assert.Equal(t, now.Add(tt.expectedDuration), *rev.RevokedAt)
+				return
+			}
+			assert.Equal(t, models.ReviewTypeOneTime, rev.Type)
+			assert.Nil(t, rev.RevokedAt)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package accessrequestinterceptor
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/hoophq/hoop/gateway/models"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+)
+
+// coversAccessScheduleOverrides reports whether a review overrides every
+// access schedule denying the session. Only reviews requested for these
+// schedules count: an approval given under an access request rule alone, or
+// for another schedule, was never meant to lift them.
+func coversAccessScheduleOverrides(scheduleNames []string, overrides []plugintypes.AccessScheduleOverride) bool {
+	for _, override := range overrides {
+		if !slices.Contains(scheduleNames, override.ScheduleName) {
+			return false
+		}
+	}
+	return true
+}
+
+// addAccessScheduleOverrides makes the review wait on the override reviewers
+// of every schedule denying the session, on top of the groups of the access
+// request rule it may already wait on. Each schedule is approved apart by one
+// of its own groups, see reviewapi.scheduleOverridesApproved.
+func addAccessScheduleOverrides(pctx plugintypes.Context, rev *models.Review, overrides []plugintypes.AccessScheduleOverride) {
+	for _, override := range overrides {
+		scheduleName := override.ScheduleName
+		rev.AccessScheduleNames = append(rev.AccessScheduleNames, scheduleName)
+		for _, groupName := range override.ReviewersGroups {
+			rev.ReviewGroups = append(rev.ReviewGroups, models.ReviewGroups{
+				ID:                 uuid.NewString(),
+				OrgID:              pctx.OrgID,
+				GroupName:          groupName,
+				Status:             models.ReviewStatusPending,
+				AccessScheduleName: &scheduleName,
+			})
+		}
+	}
+	if rev.MinApprovals == nil {
+		minApprovals := 1
+		rev.MinApprovals = &minApprovals
+	}
+}
@@ -0,0 +1,67 @@
+package accessrequestinterceptor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go/ptr"
+	"github.com/hoophq/hoop/gateway/models"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSessionReviewWithScheduleOverrides(t *testing.T) {
+	pctx := plugintypes.Context{OrgID: "org", SID: "sid", UserID: "user", ConnectionName: "pg-prod"}
+	accessRule := &models.AccessRequestRule{
+		Name:                 "prod-approval",
+		ReviewersGroups:      []string{"dba", "security"},
+		ForceApprovalGroups:  []string{"admin"},
+		MinApprovals:         ptr.Int(2),
+		ReminderAfterMinutes: ptr.Int(30),
+	}
+	overrides := []plugintypes.AccessScheduleOverride{
+		{ScheduleName: "business-hours", Reason: "outside business hours", ReviewersGroups: []string{"on-call"}},
+		{ScheduleName: "release-freeze", Reason: "frozen", ReviewersGroups: []string{"release-managers", "cto"}},
+	}
+
+	rev := newSessionReview(pctx, false, accessRule, overrides, 0, nil, nil)
+	// the rule's review still runs, with the schedules waiting on top of it
+	assert.Equal(t, "prod-approval", *rev.AccessRequestRuleName)
+	assert.Equal(t, 2, *rev.MinApprovals)
+	assert.Equal(t, []string{"admin"}, []string(rev.ForceApprovalGroups))
+	assert.NotNil(t, rev.RemindAt)
+	assert.Equal(t, []string{"business-hours", "release-freeze"}, []string(rev.AccessScheduleNames))
+
+	groupSchedules := map[string]string{}
+	for _, rg := range rev.ReviewGroups {
+		assert.Equal(t, models.ReviewStatusPending, rg.Status)
+		if rg.AccessScheduleName != nil {
+			groupSchedules[rg.GroupName] = *rg.AccessScheduleName
+		} else {
+			groupSchedules[rg.GroupName] = ""
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"dba":              "",
+		"security":         "",
+		"on-call":          "business-hours",
+		"release-managers": "release-freeze",
+		"cto":              "release-freeze",
+	}, groupSchedules)
+
+	// without a rule only the override reviewers decide
+	rev = newSessionReview(pctx, true, nil, overrides[:1], time.Hour, nil, nil)
+	assert.Nil(t, rev.AccessRequestRuleName)
+	assert.Equal(t, models.ReviewTypeJit, rev.Type)
+	assert.Equal(t, 1, *rev.MinApprovals)
+	assert.Len(t, rev.ReviewGroups, 1)
+	assert.Equal(t, "business-hours", *rev.ReviewGroups[0].AccessScheduleName)
+}
+
+func TestCoversAccessScheduleOverrides(t *testing.T) {
+	overrides := []plugintypes.AccessScheduleOverride{{ScheduleName: "business-hours"}, {ScheduleName: "release-freeze"}}
+	assert.True(t, coversAccessScheduleOverrides([]string{"release-freeze", "business-hours"}, overrides))
+	assert.False(t, coversAccessScheduleOverrides([]string{"business-hours"}, overrides))
+	assert.False(t, coversAccessScheduleOverrides(nil, overrides))
+	assert.True(t, coversAccessScheduleOverrides(nil, nil))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/google/uuid"
@@ -306,6 +307,12 @@ func (i *interceptor) StreamServerInterceptor(srv any, ss grpc.ServerStream, inf
 		ctxVal = gwctx
 	}
 
+	if gwctx, ok := ctxVal.(*GatewayContext); ok {
+		if err := enforceAccessSchedules(gwctx, md, time.Now().UTC()); err != nil {
+			return err
+		}
+	}
+
 	return handler(srv, &serverStreamWrapper{ss, nil, ctxVal})
 }
 
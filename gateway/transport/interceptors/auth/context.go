@@ -6,6 +6,7 @@ import (
 
 	"github.com/hoophq/hoop/gateway/models"
 	"github.com/hoophq/hoop/gateway/storagev2/types"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -22,6 +23,9 @@ type GatewayContext struct {
 	IdentityType string
 	// MachineIdentityID is populated when IdentityType == "machine".
 	MachineIdentityID string
+	// AccessScheduleOverrides is populated when access schedules deny the
+	// session unless a review approves it.
+	AccessScheduleOverrides []plugintypes.AccessScheduleOverride
 }
 
 func (c *GatewayContext) ValidateConnectionAttrs() error {
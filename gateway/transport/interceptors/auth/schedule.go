@@ -0,0 +1,95 @@
+package authinterceptor
+
+import (
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/google/uuid"
+	commongrpc "github.com/hoophq/hoop/common/grpc"
+	"github.com/hoophq/hoop/common/log"
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/hoophq/hoop/gateway/models"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// scheduleVerb maps the client verb and origin to the verb access schedules
+// are written against, the same split validateConnectionAccessMode uses. It
+// returns an empty string for streams schedules do not govern.
+func scheduleVerb(md metadata.MD) string {
+	clientVerb, clientOrigin := commongrpc.MetaGet(md, "verb"), commongrpc.MetaGet(md, "origin")
+	switch {
+	case clientVerb == pb.ClientVerbConnect:
+		return models.AccessScheduleVerbConnect
+	case clientVerb == pb.ClientVerbExec && clientOrigin == pb.ConnectionOriginClientAPIRunbooks:
+		return models.AccessScheduleVerbRunbooks
+	case clientVerb == pb.ClientVerbExec:
+		return models.AccessScheduleVerbExec
+	}
+	return ""
+}
+
+// enforceAccessSchedules denies the stream when an access schedule of its
+// connection does not allow it at now. A schedule that can be overridden does
+// not deny it here: the override is recorded on the context and the access
+// request interceptor holds the session until a review approves it. When
+// several schedules deny the session, any one that can't be overridden wins.
+func enforceAccessSchedules(gwctx *GatewayContext, md metadata.MD, now time.Time) error {
+	verb := scheduleVerb(md)
+	if verb == "" || gwctx.Connection.Name == "" {
+		return nil
+	}
+	orgID, err := uuid.Parse(gwctx.UserContext.OrgID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "internal error, invalid organization")
+	}
+	schedules, err := models.GetAccessSchedulesForConnection(models.DB, orgID, gwctx.Connection.Name)
+	if err != nil {
+		log.Errorf("failed fetching access schedules for connection %v, err=%v", gwctx.Connection.Name, err)
+		sentry.CaptureException(err)
+		return status.Errorf(codes.Internal, "internal error, failed to obtain access schedules")
+	}
+
+	overrides, err := accessScheduleOverrides(schedules, verb, now)
+	if err != nil {
+		log.With("user", gwctx.UserContext.UserEmail, "connection", gwctx.Connection.Name, "verb", verb).
+			Infof("access schedule denied session, reason=%v", err)
+		return err
+	}
+	for _, override := range overrides {
+		log.With("user", gwctx.UserContext.UserEmail, "connection", gwctx.Connection.Name, "verb", verb).
+			Infof("access schedule denied session, schedule=%v, can-override=true", override.ScheduleName)
+	}
+	gwctx.AccessScheduleOverrides = overrides
+	return nil
+}
+
+// accessScheduleOverrides returns an override for every schedule that denies
+// verb at now. Each one keeps its own reviewers: the approval of a business
+// hours override must not lift a change freeze. It returns an error when a
+// schedule that can't be overridden denies it, or can't be evaluated.
+func accessScheduleOverrides(schedules []models.AccessSchedule, verb string, now time.Time) ([]plugintypes.AccessScheduleOverride, error) {
+	var overrides []plugintypes.AccessScheduleOverride
+	for _, schedule := range schedules {
+		reason, err := schedule.Check(verb, now)
+		if err != nil {
+			// fail closed: a schedule nobody can read must not open access
+			log.Errorf("failed evaluating access schedule, err=%v", err)
+			return nil, status.Errorf(codes.FailedPrecondition, "access schedule %q is misconfigured", schedule.Name)
+		}
+		if reason == "" {
+			continue
+		}
+		if len(schedule.OverrideReviewersGroups) == 0 {
+			return nil, status.Error(codes.FailedPrecondition, reason)
+		}
+		overrides = append(overrides, plugintypes.AccessScheduleOverride{
+			ScheduleName:    schedule.Name,
+			Reason:          reason,
+			ReviewersGroups: schedule.OverrideReviewersGroups,
+		})
+	}
+	return overrides, nil
+}
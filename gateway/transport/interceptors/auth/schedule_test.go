@@ -0,0 +1,58 @@
+package authinterceptor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoop/gateway/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccessScheduleOverrides(t *testing.T) {
+	// a Friday night, outside business hours and inside the release freeze
+	now := time.Date(2026, 5, 8, 22, 0, 0, 0, time.UTC)
+	businessHours := models.AccessSchedule{
+		Name:     "business-hours",
+		Timezone: "UTC",
+		RecurringWindows: []models.AccessScheduleWindow{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, StartTime: "08:00", EndTime: "20:00"},
+		},
+		OverrideReviewersGroups: []string{"on-call"},
+	}
+	freeze := models.AccessSchedule{
+		Name: "release-freeze",
+		FreezePeriods: []models.AccessScheduleFreeze{
+			{Name: "release", StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)},
+		},
+		OverrideReviewersGroups: []string{"release-managers"},
+	}
+	open := models.AccessSchedule{
+		Name:                    "always-open",
+		Timezone:                "UTC",
+		RecurringWindows:        []models.AccessScheduleWindow{{StartTime: "00:00", EndTime: "23:59"}},
+		OverrideReviewersGroups: []string{"admin"},
+	}
+
+	overrides, err := accessScheduleOverrides([]models.AccessSchedule{businessHours, open, freeze},
+		models.AccessScheduleVerbExec, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(overrides) != 2 {
+		t.Fatalf("expected an override for each denying schedule, got %+v", overrides)
+	}
+	for i, want := range []models.AccessSchedule{businessHours, freeze} {
+		got := overrides[i]
+		if got.ScheduleName != want.Name || got.Reason == "" ||
+			len(got.ReviewersGroups) != 1 || got.ReviewersGroups[0] != want.OverrideReviewersGroups[0] {
+			t.Errorf("override %d = %+v, want schedule %q reviewed by %v", i, got, want.Name, want.OverrideReviewersGroups)
+		}
+	}
+
+	freeze.OverrideReviewersGroups = nil
+	_, err = accessScheduleOverrides([]models.AccessSchedule{businessHours, freeze}, models.AccessScheduleVerbExec, now)
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected a schedule that can't be overridden to deny the session, got %v", err)
+	}
+}
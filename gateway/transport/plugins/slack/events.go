@@ -99,6 +99,8 @@ func (p *slackPlugin) performReview(ev *event, ctx *storagev2.Context, status mo
 		msg = "Unable to self approval review, contact another member of you team to approve it"
 	case reviewapi.ErrNotEligible:
 		msg = "You're not eligible to approve/reject this review"
+	case reviewapi.ErrExpired:
+		msg = "The review has expired"
 	case nil:
 		isApproved := rev.Status == models.ReviewStatusApproved
 		isStillPending := rev.Status == models.ReviewStatusPending
@@ -92,6 +92,12 @@ type Context struct {
 	// CorrelationID groups related sessions spawned by an external workflow/task.
 	CorrelationID string
 
+	// AccessScheduleOverrides are set when access schedules deny the session
+	// but let an approved review override them. The access request
+	// interceptor then holds the session until a review approved by the
+	// override reviewers of every one of them.
+	AccessScheduleOverrides []AccessScheduleOverride
+
 	ParamsData GenericMap
 
 	// hook to cleanup the transport extensions package handlers
@@ -101,6 +107,14 @@ type Context struct {
 	FlushLogsToDisk bool
 }
 
+// AccessScheduleOverride describes the schedule a session needs a review to
+// override, and who may approve it.
+type AccessScheduleOverride struct {
+	ScheduleName    string
+	Reason          string
+	ReviewersGroups []string
+}
+
 type PluginResource interface {
 	GetName() string
 	GetOrgID() string
@@ -6,5 +6,13 @@ const (
 	eventMSTeamsReviewCreateType     = "microsoftteams.review.create"
 	EventDBRoleJobFinishedType       = "dbroles.job.finished"
 	EventDBRoleJobCustomFinishedType = "dbroles.custom.job.finished"
+	EventReviewReminderType          = "review.reminder"
+	EventReviewEscalatedType         = "review.escalated"
+	EventReviewExpiredType           = "review.expired"
+	EventBreakGlassUsedType          = "review.break_glass.used"
+	EventBreakGlassAcknowledgedType  = "review.break_glass.acknowledged"
+	EventCertificationStartedType    = "certification.run.started"
+	EventCertificationRevokedType    = "certification.access.revoked"
+	EventCertificationCompletedType  = "certification.run.completed"
 	maxInputSize                     = 10 * 1000 // 10KB
 )
@@ -153,8 +153,9 @@ func (s *Server) Connect(stream pb.Transport_ConnectServer) (err error) {
 		UserSlackID:    gwctx.UserContext.UserSlackID,
 		UserGroups:     gwctx.UserContext.UserGroups,
 
-		IdentityType:      gwctx.IdentityType,
-		MachineIdentityID: gwctx.MachineIdentityID,
+		IdentityType:            gwctx.IdentityType,
+		MachineIdentityID:       gwctx.MachineIdentityID,
+		AccessScheduleOverrides: gwctx.AccessScheduleOverrides,
 
 		ConnectionID:                        gwctx.Connection.ID,
 		ConnectionName:                      gwctx.Connection.Name,
@@ -11,6 +11,7 @@ import (
 	"github.com/hoophq/hoop/common/memory"
 	pb "github.com/hoophq/hoop/common/proto"
 	pbagent "github.com/hoophq/hoop/common/proto/agent"
+	"github.com/hoophq/hoop/gateway/guardrails"
 	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
 	streamtypes "github.com/hoophq/hoop/gateway/transport/streamclient/types"
 	"google.golang.org/grpc/codes"
@@ -63,6 +64,11 @@ type ProxyStream struct {
 	pluginCtx         *plugintypes.Context
 	stateTime         time.Time
 	isSessionReviewed atomic.Bool
+
+	// guardRailClassifierRules are the input guardrail rules the gateway
+	// evaluates itself. They are loaded when the session opens and read by
+	// the same goroutine handling the client packets.
+	guardRailClassifierRules []guardrails.DataRules
 }
 
 func GetProxyStream(sid string) *ProxyStream {
@@ -173,6 +179,14 @@ func (s *ProxyStream) IsAgentOnline() bool { return IsAgentOnline(s.StreamAgentI
 func (s *ProxyStream) isReviewed() bool    { return s.isSessionReviewed.Load() }
 func (s *ProxyStream) SetReviewed()        { s.isSessionReviewed.Store(true) }
 
+func (s *ProxyStream) GuardRailClassifierRules() []guardrails.DataRules {
+	return s.guardRailClassifierRules
+}
+
+func (s *ProxyStream) SetGuardRailClassifierRules(rules []guardrails.DataRules) {
+	s.guardRailClassifierRules = rules
+}
+
 // If the agent is a multi connection type, it returns a deterministic uuid
 // based on the agent id and the id of the connection, otherwise it returns the
 // agent_id of the connection
@@ -11,6 +11,10 @@ import (
 func init() {
 	RegisterBuilder(SQLBuilder{Protocol_: hoopinspect.Postgres})
 	RegisterBuilder(SQLBuilder{Protocol_: hoopinspect.MSSQL})
+	RegisterBuilder(SQLBuilder{Protocol_: hoopinspect.MySQL})
+	// MongoDB is not SQL, but its statement has the same shape: a text, an
+	// operation and the collections in Tables.
+	RegisterBuilder(SQLBuilder{Protocol_: hoopinspect.MongoDB})
 	RegisterBuilder(HTTPBuilder{})
 }
 
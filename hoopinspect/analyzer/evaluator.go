@@ -148,6 +148,11 @@ type Config struct {
 	// pathological workload, not a quota.
 	MaxCalls int
 
+	// Observe, when set, is called after each provider call with its
+	// duration and its error, nil on a usable answer. A cached verdict is
+	// not a call and is not observed.
+	Observe func(elapsed time.Duration, err error)
+
 	// Redact rewrites content before it leaves the process. Nil sends the
 	// statement as-is.
 	Redact func(string) string
@@ -474,16 +479,18 @@ func (e *Evaluator) classify(
 	callCtx, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
 	defer cancel()
 
+	start := time.Now()
 	res, err := e.cfg.Provider.Classify(callCtx, e.prompt, text)
+	if err == nil && (res == nil || !res.RiskLevel.Valid()) {
+		err = fmt.Errorf("provider returned no usable risk level")
+	}
+	if e.cfg.Observe != nil {
+		e.cfg.Observe(time.Since(start), err)
+	}
 	if err != nil {
 		e.errs.Add(1)
 		return Result{}, StatusError, err
 	}
-	if res == nil || !res.RiskLevel.Valid() {
-		e.errs.Add(1)
-		return Result{}, StatusError, fmt.Errorf(
-			"provider returned no usable risk level")
-	}
 
 	e.cache.put(cacheKey, *res)
 	return *res, StatusOK, nil
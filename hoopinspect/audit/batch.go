@@ -0,0 +1,268 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrRejected marks a delivery the collector refused outright, such as an
+// HTTP 400 or 401. A BatchSink does not retry it with backoff: a collector
+// that refuses a batch has usually been misconfigured, and the fix belongs
+// to the collector, not to the events.
+var ErrRejected = errors.New("audit: collector rejected the batch")
+
+// Deliver ships one batch to a remote collector. It returns nil only when
+// the collector has accepted every event in it, and an error wrapping
+// ErrRejected when retrying the same batch cannot succeed.
+type Deliver func(ctx context.Context, batch []Event) error
+
+// BatchOptions tunes a BatchSink. The zero value is usable.
+type BatchOptions struct {
+	// MaxBatch is the most events one delivery carries. Default 500.
+	MaxBatch int
+
+	// FlushInterval is how long an event waits for a batch to fill before
+	// it is sent anyway. Default one second.
+	FlushInterval time.Duration
+
+	// MaxPending bounds the events accepted and not yet delivered; past it,
+	// Write returns ErrQueueFull. Default 10 batches.
+	MaxPending int
+
+	// Retries is how many times a failed batch is retried, with exponential
+	// backoff, before Write starts refusing. Default 3; negative for none.
+	Retries int
+
+	// Backoff is the first retry's delay, doubled on each retry up to
+	// MaxBackoff. Defaults 500ms and 30s.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Timeout bounds one delivery attempt. Default ten seconds.
+	Timeout time.Duration
+}
+
+func (o BatchOptions) maxBatch() int {
+	if o.MaxBatch <= 0 {
+		return 500
+	}
+	return o.MaxBatch
+}
+
+func (o BatchOptions) flushInterval() time.Duration {
+	if o.FlushInterval <= 0 {
+		return time.Second
+	}
+	return o.FlushInterval
+}
+
+func (o BatchOptions) maxPending() int {
+	if o.MaxPending <= 0 {
+		return 10 * o.maxBatch()
+	}
+	return o.MaxPending
+}
+
+func (o BatchOptions) retries() int {
+	switch {
+	case o.Retries < 0:
+		return 0
+	case o.Retries == 0:
+		return 3
+	}
+	return o.Retries
+}
+
+func (o BatchOptions) backoff() (first, ceiling time.Duration) {
+	first, ceiling = o.Backoff, o.MaxBackoff
+	if first <= 0 {
+		first = 500 * time.Millisecond
+	}
+	if ceiling <= 0 {
+		ceiling = 30 * time.Second
+	}
+	return first, max(first, ceiling)
+}
+
+func (o BatchOptions) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return 10 * time.Second
+	}
+	return o.Timeout
+}
+
+// BatchSink collects events and ships them to a remote collector in batches,
+// from a goroutine of its own. NewHTTPSink and NewOTLPSink build one.
+//
+// An event Write accepted is held until the collector takes it. A batch that
+// fails is kept, at the head of the queue, and retried; once its retries are
+// spent, Write refuses new events until a delivery goes through. That
+// refusal is what makes fail_closed mean something for a remote sink: the
+// alternative, accepting events into a buffer that is not draining, reports
+// every statement recorded while none of them are. Under fail-open the
+// refusals are logged and the statements run.
+//
+// A collector that accepts a batch but never acknowledges it gets the batch
+// again on retry, so delivery is at least once. Each event carries its
+// session and timestamp, which is what a collector deduplicates on.
+type BatchSink struct {
+	name    string
+	deliver Deliver
+	opts    SinkOptions
+	batch   BatchOptions
+
+	mu      sync.Mutex
+	pending []Event
+	failing error // the last delivery's failure; nil once one succeeds
+	closed  bool
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewBatchSink delivers batches with deliver. name appears in its errors.
+//
+// opts applies as it does to a JSONLSink, except Chain, which is ignored: a
+// chain resumes from the file it was written to, and a collector's copy is
+// not one this process can read back.
+func NewBatchSink(name string, deliver Deliver, opts SinkOptions, batch BatchOptions) *BatchSink {
+	opts.Chain = nil
+	s := &BatchSink{
+		name:    name,
+		deliver: deliver,
+		opts:    opts,
+		batch:   batch,
+		kick:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Write queues ev for the next batch. It returns an error, without queueing,
+// while deliveries are failing or when MaxPending events are already queued.
+func (s *BatchSink) Write(_ context.Context, ev Event) error {
+	ev = s.opts.apply(ev)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	if s.failing != nil {
+		return fmt.Errorf("audit: %s sink is not delivering: %w", s.name, s.failing)
+	}
+	if len(s.pending) >= s.batch.maxPending() {
+		return fmt.Errorf("audit: %s sink: %w", s.name, ErrQueueFull)
+	}
+	s.pending = append(s.pending, ev)
+	if len(s.pending) >= s.batch.maxBatch() {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Pending reports how many events are accepted and not yet delivered.
+func (s *BatchSink) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+func (s *BatchSink) run() {
+	defer close(s.done)
+	t := time.NewTicker(s.batch.flushInterval())
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			for s.flush() {
+			}
+			return
+		case <-s.kick:
+			for s.flush() && s.Pending() >= s.batch.maxBatch() {
+			}
+		case <-t.C:
+			s.flush()
+		}
+	}
+}
+
+// flush delivers the head of the queue, reporting whether it went through.
+func (s *BatchSink) flush() bool {
+	s.mu.Lock()
+	n := min(len(s.pending), s.batch.maxBatch())
+	// A copy: Write appends to pending while the batch is in flight, and
+	// an append into shared backing storage would race the encoder.
+	batch := append([]Event(nil), s.pending[:n]...)
+	s.mu.Unlock()
+	if n == 0 {
+		return false
+	}
+
+	err := s.send(batch)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = err
+	if err != nil {
+		return false
+	}
+	s.pending = append([]Event(nil), s.pending[n:]...)
+	return true
+}
+
+// send makes one delivery attempt and its retries.
+func (s *BatchSink) send(batch []Event) error {
+	delay, ceiling := s.batch.backoff()
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), s.batch.timeout())
+		err := s.deliver(ctx, batch)
+		cancel()
+		if err == nil || errors.Is(err, ErrRejected) || attempt >= s.batch.retries() {
+			return err
+		}
+		// Half the delay plus up to half again at random, so replicas that
+		// lost the same collector do not all come back on the same tick.
+		time.Sleep(delay/2 + rand.N(delay/2+1))
+		delay = min(2*delay, ceiling)
+	}
+}
+
+// Close stops accepting events, delivers what is queued, and reports what
+// could not be. Safe to call twice; the second call returns the first call's
+// result.
+//
+// A batch gets its retries at Close as it would at any flush, so a collector
+// that blips during a rolling restart does not cost the last second of
+// events. Against one that is down, Close takes as long as the retries do.
+func (s *BatchSink) Close() error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.stop)
+		<-s.done
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if n := len(s.pending); n > 0 {
+			s.closeErr = fmt.Errorf("audit: %s sink: %d event(s) undelivered at close: %w", s.name, n, s.failing)
+		}
+	})
+	return s.closeErr
+}
+
+var _ Sink = (*BatchSink)(nil)
@@ -0,0 +1,228 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// fastRetries keeps a test's backoff out of its runtime.
+var fastRetries = BatchOptions{FlushInterval: 10 * time.Millisecond, Retries: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+// collector is an HTTP receiver that fails its first failures requests with
+// status, then records every batch it accepts.
+type collector struct {
+	mu       sync.Mutex
+	status   int
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures > 0 {
+		c.failures--
+		w.WriteHeader(c.status)
+		return
+	}
+	c.requests = append(c.requests, r)
+	c.bodies = append(c.bodies, body)
+}
+
+func (c *collector) received() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]byte(nil), c.bodies...)
+}
+
+func TestHTTPSinkSignsAndBatches(t *testing.T) {
+	c := &collector{status: http.StatusServiceUnavailable, failures: 1}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	key := []byte("shared-secret")
+	batch := fastRetries
+	batch.MaxBatch = 3
+	s := NewHTTPSink(HTTPOptions{URL: srv.URL, HMACKey: key, Header: http.Header{"X-Api-Key": {"k"}}},
+		SinkOptions{RedactStatements: true}, batch)
+	for range 5 {
+		if err := s.Write(context.Background(), Event{Kind: KindStatement, SessionID: "s1", Statement: "SELECT ssn FROM people"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := c.received()
+	if len(bodies) != 2 || bytes.Count(bodies[0], []byte("\n")) != 3 || bytes.Count(bodies[1], []byte("\n")) != 2 {
+		t.Fatalf("batches = %q, want 3 events then 2, the 503 retried", bodies)
+	}
+	if bytes.Contains(bodies[0], []byte("ssn")) {
+		t.Errorf("redact_statements was not applied: %s", bodies[0])
+	}
+	r := c.requests[0]
+	if got := SignBody(key, r.Header.Get(HeaderTimestamp), bodies[0]); r.Header.Get(HeaderSignature) != got {
+		t.Errorf("signature = %q, want %q", r.Header.Get(HeaderSignature), got)
+	}
+	if r.Header.Get("X-Api-Key") != "k" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("headers = %v", r.Header)
+	}
+}
+
+// The fail_closed contract: once a collector has refused a batch through
+// its retries, Write reports it, and the events already accepted are kept
+// for when it recovers.
+func TestBatchSinkRefusesWritesWhileUndelivered(t *testing.T) {
+	var mu sync.Mutex
+	down := true
+	var delivered []Event
+	s := NewBatchSink("test", func(_ context.Context, batch []Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			return errors.New("connection refused")
+		}
+		delivered = append(delivered, batch...)
+		return nil
+	}, SinkOptions{}, fastRetries)
+	defer s.Close()
+
+	if err := s.Write(context.Background(), Event{Kind: KindStatement, Statement: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	var err error
+	for err == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		err = s.Write(context.Background(), Event{Kind: KindStatement, Statement: "later"})
+	}
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("Write while the collector is down: err = %v", err)
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	for s.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := s.Write(context.Background(), Event{Kind: KindStatement}); err != nil {
+		t.Errorf("Write after recovery: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(delivered) == 0 || delivered[0].Statement != "first" {
+		t.Errorf("delivered = %+v, want the held events first", delivered)
+	}
+}
+
+func TestBatchSinkBoundsPending(t *testing.T) {
+	block := make(chan struct{})
+	s := NewBatchSink("test", func(context.Context, []Event) error { <-block; return nil },
+		SinkOptions{}, BatchOptions{MaxBatch: 1, MaxPending: 2})
+	defer func() { close(block); s.Close() }()
+
+	var err error
+	for range 4 {
+		if err = s.Write(context.Background(), Event{Kind: KindStatement}); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrQueueFull) {
+		t.Errorf("err = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestHTTPSinkDoesNotRetryARejection(t *testing.T) {
+	c := &collector{status: http.StatusUnauthorized, failures: 100}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	err := HTTPOptions{URL: srv.URL}.deliver(context.Background(), []Event{{Kind: KindStatement}})
+	if !errors.Is(err, ErrRejected) {
+		t.Errorf("a 401: err = %v, want ErrRejected", err)
+	}
+	c.mu.Lock()
+	c.status = http.StatusTooManyRequests
+	c.mu.Unlock()
+	if err := (HTTPOptions{URL: srv.URL}).deliver(context.Background(), []Event{{Kind: KindStatement}}); err == nil || errors.Is(err, ErrRejected) {
+		t.Errorf("a 429: err = %v, want a retryable error", err)
+	}
+}
+
+func TestOTLPSinkRecords(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	s := NewOTLPSink(OTLPOptions{Endpoint: srv.URL + "/v1/logs"}, SinkOptions{}, fastRetries)
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := s.Write(context.Background(), Event{
+		Kind: KindViolation, Timestamp: at, SessionID: "s1", Principal: "alice",
+		Protocol: hoopinspect.Postgres, Operation: hoopinspect.OpDelete, Rule: "no-deletes",
+		Statement: "DELETE FROM t WHERE a > 1",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var req otlpLogsRequest
+	if bodies := c.received(); len(bodies) != 1 || json.Unmarshal(bodies[0], &req) != nil {
+		t.Fatalf("bodies = %q", bodies)
+	}
+	if c.requests[0].URL.Path != "/v1/logs" {
+		t.Errorf("path = %s", c.requests[0].URL.Path)
+	}
+	rl := req.ResourceLogs[0]
+	if *rl.Resource.Attributes[0].Value.StringValue != "hoop-inspect" {
+		t.Errorf("service.name = %q", *rl.Resource.Attributes[0].Value.StringValue)
+	}
+	rec := rl.ScopeLogs[0].LogRecords[0]
+	if rec.SeverityText != "WARN" || rec.TimeUnixNano != "1772366400000000000" {
+		t.Errorf("record = %+v", rec)
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(*rec.Body.StringValue), &ev); err != nil || ev.Statement != "DELETE FROM t WHERE a > 1" {
+		t.Errorf("body = %s (%v)", *rec.Body.StringValue, err)
+	}
+	attrs := map[string]string{}
+	for _, kv := range rec.Attributes {
+		if kv.Value.StringValue != nil {
+			attrs[kv.Key] = *kv.Value.StringValue
+		}
+	}
+	if attrs["hoop.rule"] != "no-deletes" || attrs["hoop.principal"] != "alice" {
+		t.Errorf("attributes = %v", attrs)
+	}
+}
+
+// An HTTP receiver reads the request the way it reads the file.
+func TestHTTPSinkBodyIsJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	ev := Event{Kind: KindStatement, SessionID: "s1", Statement: "SELECT 1 WHERE a > 1", Timestamp: time.Unix(0, 0).UTC()}
+	if err := NewJSONLSink(&buf, SinkOptions{}).Write(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	line, _ := marshalEvent(ev)
+	sc := bufio.NewScanner(&buf)
+	if !sc.Scan() || sc.Text() != string(line) {
+		t.Errorf("JSONLSink wrote %q, the sinks send %q", sc.Text(), line)
+	}
+}
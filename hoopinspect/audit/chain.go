@@ -0,0 +1,376 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// checkpointContext prefixes every signed message, so a checkpoint key that
+// is also used for something else cannot be tricked into signing a
+// checkpoint, nor a checkpoint replayed as a signature over anything else.
+const checkpointContext = "hoopinspect audit checkpoint v1\x00"
+
+// ChainOptions configures a Chain.
+type ChainOptions struct {
+	// CheckpointEvery signs every Nth event. Zero or a nil SigningKey means
+	// no checkpoints.
+	CheckpointEvery int
+
+	// SigningKey signs checkpoints. It is the piece the chain cannot do
+	// without: the hashes alone are computable by anyone, so a writer who
+	// can edit the file can also re-chain it. A checkpoint can only be
+	// forged by the holder of this key.
+	SigningKey ed25519.PrivateKey
+}
+
+// Chain links events into a hash chain.
+//
+// Each sealed event carries a sequence number, the previous event's hash,
+// and its own hash, computed over that previous hash and the event's
+// canonical encoding. Deleting a record leaves a gap in ChainSeq, moving one
+// breaks the order, and editing one breaks its Hash and the PrevHash of the
+// record after it. VerifyJSONL and Verifier find all three.
+//
+// A Chain belongs to exactly one sink, and that sink seals and advances
+// under the same lock that serializes its writes. The sequence numbers are
+// the write order; two sinks sharing a chain would interleave it.
+type Chain struct {
+	opts ChainOptions
+
+	mu   sync.Mutex
+	seq  uint64
+	prev string
+}
+
+// NewChain starts a chain at sequence 1.
+func NewChain(opts ChainOptions) *Chain {
+	return &Chain{opts: opts}
+}
+
+// Resume continues a chain after the last event already persisted, so a
+// restarted process appends to its file without a break in the chain.
+func (c *Chain) Resume(seq uint64, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq, c.prev = seq, hash
+}
+
+// Seal returns ev as the next link: numbered, hashed and, when a checkpoint
+// is due, signed. It does not advance the chain; call Advance once the event
+// is durably written, so a failed write is retried as the same link rather
+// than leaving a gap that would read as a deleted record.
+//
+// The timestamp is cut to microseconds before hashing, the resolution the
+// sqlite store keeps. A hash over nanoseconds would not survive the round
+// trip, and every stored record would verify as modified.
+func (c *Chain) Seal(ev Event) Event {
+	c.mu.Lock()
+	seq, prev := c.seq+1, c.prev
+	c.mu.Unlock()
+
+	ev.Timestamp = ev.Timestamp.UTC().Truncate(time.Microsecond)
+	ev.ChainSeq, ev.PrevHash, ev.Hash, ev.Checkpoint = seq, prev, "", ""
+	ev = canonical(ev)
+	ev.Hash = chainHash(ev)
+	if every := uint64(c.opts.CheckpointEvery); every > 0 && c.opts.SigningKey != nil && seq%every == 0 {
+		sig := ed25519.Sign(c.opts.SigningKey, checkpointMessage(seq, ev.Hash))
+		ev.Checkpoint = base64.StdEncoding.EncodeToString(sig)
+	}
+	return ev
+}
+
+// Advance records ev, as Seal returned it, as the chain's newest link.
+func (c *Chain) Advance(ev Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq, c.prev = ev.ChainSeq, ev.Hash
+}
+
+// canonical returns ev as it reads back from its own JSON. The encoder
+// rewrites what it cannot represent, invalid UTF-8 in a statement most
+// often, so hashing the event before the round trip would hash a record
+// that was never stored.
+func canonical(ev Event) Event {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return ev
+	}
+	var out Event
+	if json.Unmarshal(body, &out) != nil {
+		return ev
+	}
+	return out
+}
+
+// chainHash is the hex SHA-256 of the previous hash and the event's
+// canonical encoding: the event as JSON with its own Hash and Checkpoint
+// empty. encoding/json sorts map keys and omits the empty fields, so an
+// event that round-trips through any of the sinks encodes the same way.
+func chainHash(ev Event) string {
+	ev.Hash, ev.Checkpoint = "", ""
+	body, err := json.Marshal(ev)
+	if err != nil {
+		// Every field of Event is marshalable by construction.
+		panic(fmt.Sprintf("audit: encoding event for the chain: %v", err))
+	}
+	h := sha256.New()
+	h.Write([]byte(ev.PrevHash))
+	h.Write([]byte{'\n'})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkpointMessage is what a checkpoint signs. The hash already commits to
+// every record before it, so signing one link vouches for the prefix.
+func checkpointMessage(seq uint64, hash string) []byte {
+	return []byte(checkpointContext + strconv.FormatUint(seq, 10) + "\x00" + hash)
+}
+
+// Kinds of ChainProblem.
+const (
+	ProblemGap       = "gap"       // one or more records are missing
+	ProblemReorder   = "reorder"   // a record appears out of sequence
+	ProblemModified  = "modified"  // a record's content does not match its hash
+	ProblemSignature = "signature" // a checkpoint does not verify
+	ProblemUnchained = "unchained" // a record without a hash after the chain began
+)
+
+// ChainProblem is one thing Verifier found wrong.
+type ChainProblem struct {
+	// Position is the record's 1-based position in the input: the line in
+	// a JSONL file, the row in storage order for a store.
+	Position int
+	Seq      uint64
+	Kind     string
+	Detail   string
+}
+
+func (p ChainProblem) String() string {
+	return fmt.Sprintf("record %d (seq %d): %s: %s", p.Position, p.Seq, p.Kind, p.Detail)
+}
+
+// ChainReport is what a verification found.
+type ChainReport struct {
+	// Records is how many records were read, Unchained how many of them
+	// precede the chain (written before it was turned on).
+	Records   int
+	Unchained int
+
+	// First and Last are the sequence numbers of the first and last
+	// chained records.
+	First, Last uint64
+
+	// Checkpoints is how many signed checkpoints verified. Signed is the
+	// sequence number of the last of them: everything up to it is vouched
+	// for by the key holder, everything after it only by the hashes.
+	Checkpoints int
+	Signed      uint64
+
+	// Unverified counts checkpoints that were present but not checked,
+	// because no public key was given.
+	Unverified int
+
+	Problems []ChainProblem
+}
+
+// OK reports whether the chain verified without a problem.
+func (r ChainReport) OK() bool { return len(r.Problems) == 0 }
+
+// Verifier checks records fed to it in storage order.
+type Verifier struct {
+	pub ed25519.PublicKey
+
+	report ChainReport
+	last   *Event
+}
+
+// NewVerifier checks checkpoints against pub. A nil pub still checks the
+// hashes and the order, and counts the checkpoints it could not check.
+func NewVerifier(pub ed25519.PublicKey) *Verifier {
+	return &Verifier{pub: pub}
+}
+
+// Add checks the next record.
+func (v *Verifier) Add(ev Event) {
+	v.report.Records++
+	pos := v.report.Records
+	problem := func(kind, format string, args ...any) {
+		v.report.Problems = append(v.report.Problems,
+			ChainProblem{Position: pos, Seq: ev.ChainSeq, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if ev.ChainSeq == 0 || ev.Hash == "" {
+		// Records written before chaining was turned on are legitimate
+		// history. One after the chain began is a record nobody sealed.
+		if v.last == nil {
+			v.report.Unchained++
+		} else {
+			problem(ProblemUnchained, "record has no seq or hash")
+		}
+		return
+	}
+
+	if got := chainHash(ev); got != ev.Hash {
+		problem(ProblemModified, "content does not match its hash")
+	}
+
+	switch prev := v.last; {
+	case prev == nil:
+		v.report.First = ev.ChainSeq
+		if ev.ChainSeq != 1 {
+			problem(ProblemGap, "chain starts at seq %d; records 1-%d are missing", ev.ChainSeq, ev.ChainSeq-1)
+		}
+	case ev.ChainSeq == prev.ChainSeq+1:
+		if ev.PrevHash != prev.Hash {
+			problem(ProblemModified, "does not link to seq %d; that record or this one was changed", prev.ChainSeq)
+		}
+	case ev.ChainSeq > prev.ChainSeq+1:
+		problem(ProblemGap, "records %d-%d are missing", prev.ChainSeq+1, ev.ChainSeq-1)
+	default:
+		problem(ProblemReorder, "follows seq %d", prev.ChainSeq)
+	}
+
+	if ev.Checkpoint != "" {
+		v.checkpoint(ev, problem)
+	}
+
+	// A reordered record does not move the high-water mark back: the
+	// records after it are checked against the highest seq seen.
+	if v.last == nil || ev.ChainSeq > v.last.ChainSeq {
+		v.report.Last = ev.ChainSeq
+		last := ev
+		v.last = &last
+	}
+}
+
+func (v *Verifier) checkpoint(ev Event, problem func(string, string, ...any)) {
+	if v.pub == nil {
+		v.report.Unverified++
+		return
+	}
+	sig, err := base64.StdEncoding.DecodeString(ev.Checkpoint)
+	if err != nil || !ed25519.Verify(v.pub, checkpointMessage(ev.ChainSeq, ev.Hash), sig) {
+		problem(ProblemSignature, "checkpoint signature does not verify")
+		return
+	}
+	v.report.Checkpoints++
+	v.report.Signed = ev.ChainSeq
+}
+
+// Report returns what the records added so far showed.
+func (v *Verifier) Report() ChainReport {
+	r := v.report
+	r.Problems = append([]ChainProblem(nil), r.Problems...)
+	return r
+}
+
+// maxJSONLLine bounds one record. Statements are capped well below it; a
+// line this long is not a record this package wrote.
+const maxJSONLLine = 16 << 20
+
+// VerifyJSONL checks a JSONL audit file in file order.
+//
+// A line that is not a JSON event is a problem, not an error: a verifier
+// that stops at the first damaged line reports nothing about the rest.
+// The error is for failing to read r at all.
+func VerifyJSONL(r io.Reader, pub ed25519.PublicKey) (ChainReport, error) {
+	v := NewVerifier(pub)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), maxJSONLLine)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal(line, &ev); err != nil {
+			v.report.Records++
+			v.report.Problems = append(v.report.Problems, ChainProblem{
+				Position: v.report.Records, Kind: ProblemModified, Detail: "not a JSON event: " + err.Error(),
+			})
+			continue
+		}
+		v.Add(ev)
+	}
+	if err := sc.Err(); err != nil {
+		return v.Report(), fmt.Errorf("audit: reading records: %w", err)
+	}
+	return v.Report(), nil
+}
+
+// ChainTail reads a JSONL audit file and returns its last chained record's
+// seq and hash, for Chain.Resume. An empty or unchained file returns zeros.
+func ChainTail(r io.Reader) (uint64, string, error) {
+	var (
+		seq  uint64
+		hash string
+	)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), maxJSONLLine)
+	for sc.Scan() {
+		var ev struct {
+			ChainSeq uint64 `json:"chain_seq"`
+			Hash     string `json:"hash"`
+		}
+		if json.Unmarshal(sc.Bytes(), &ev) != nil || ev.Hash == "" {
+			continue
+		}
+		seq, hash = ev.ChainSeq, ev.Hash
+	}
+	if err := sc.Err(); err != nil {
+		return 0, "", fmt.Errorf("audit: reading chain tail: %w", err)
+	}
+	return seq, hash, nil
+}
+
+// ErrNotEd25519 is returned for a key file holding some other kind of key.
+var ErrNotEd25519 = errors.New("audit: not an Ed25519 key")
+
+// ParseSigningKey reads a PEM "PRIVATE KEY" block (PKCS #8), the format
+// `openssl genpkey -algorithm ed25519` writes.
+func ParseSigningKey(pemBytes []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("audit: signing key: no PEM PRIVATE KEY block")
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("audit: signing key: %w", err)
+	}
+	priv, ok := k.(ed25519.PrivateKey)
+	if !ok {
+		return nil, ErrNotEd25519
+	}
+	return priv, nil
+}
+
+// ParseVerifyKey reads a PEM "PUBLIC KEY" block (PKIX), the format
+// `openssl pkey -pubout` writes.
+func ParseVerifyKey(pemBytes []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("audit: public key: no PEM PUBLIC KEY block")
+	}
+	k, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("audit: public key: %w", err)
+	}
+	pub, ok := k.(ed25519.PublicKey)
+	if !ok {
+		return nil, ErrNotEd25519
+	}
+	return pub, nil
+}
@@ -0,0 +1,213 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// chainedFile writes n events through a chained JSONLSink and returns the
+// file's lines.
+func chainedFile(t *testing.T, n int, opts ChainOptions) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	s := NewJSONLSink(&buf, SinkOptions{Chain: NewChain(opts)})
+	at := time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	for i := range n {
+		ev := Event{
+			Kind: KindStatement, SessionID: "s1", Principal: "alice", Timestamp: at.Add(time.Duration(i) * time.Second),
+			Protocol: hoopinspect.Postgres, Operation: hoopinspect.OpSelect, Allowed: true,
+			Statement: fmt.Sprintf("SELECT %d WHERE a > 1 AND b = '\xff'", i),
+			Tables:    []string{}, Metadata: map[string]string{"z": "1", "a": "2"},
+		}
+		if err := s.Write(context.Background(), ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+}
+
+func verifyLines(t *testing.T, lines []string, pub ed25519.PublicKey) ChainReport {
+	t.Helper()
+	rep, err := VerifyJSONL(strings.NewReader(strings.Join(lines, "\n")+"\n"), pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rep
+}
+
+func problemKinds(rep ChainReport) []string {
+	var kinds []string
+	for _, p := range rep.Problems {
+		kinds = append(kinds, p.Kind)
+	}
+	return kinds
+}
+
+func TestChainedJSONLVerifies(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	lines := chainedFile(t, 10, ChainOptions{CheckpointEvery: 4, SigningKey: priv})
+
+	rep := verifyLines(t, lines, pub)
+	if !rep.OK() {
+		t.Fatalf("an untouched file failed verification: %v", rep.Problems)
+	}
+	if rep.Records != 10 || rep.First != 1 || rep.Last != 10 || rep.Checkpoints != 2 || rep.Signed != 8 {
+		t.Errorf("report = %+v, want 10 records, seq 1-10, checkpoints at 4 and 8", rep)
+	}
+	if rep := verifyLines(t, lines, nil); !rep.OK() || rep.Unverified != 2 {
+		t.Errorf("without a key: report = %+v, want OK with 2 unverified checkpoints", rep)
+	}
+}
+
+// Each edit an auditor worries about shows up as its own kind of problem.
+func TestVerifyFindsTampering(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	lines := chainedFile(t, 8, ChainOptions{CheckpointEvery: 4, SigningKey: priv})
+	edit := func(line string, f func(*Event)) string {
+		var ev Event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatal(err)
+		}
+		f(&ev)
+		b, _ := json.Marshal(ev)
+		return string(b)
+	}
+
+	for name, c := range map[string]struct {
+		lines []string
+		want  string
+	}{
+		"deleted record": {
+			append(append([]string{}, lines[:2]...), lines[3:]...), ProblemGap,
+		},
+		"deleted head": {
+			lines[1:], ProblemGap,
+		},
+		"swapped records": {
+			append(append([]string{}, lines[:2]...), append([]string{lines[3], lines[2]}, lines[4:]...)...), ProblemReorder,
+		},
+		"edited principal": {
+			append(append(append([]string{}, lines[:4]...),
+				edit(lines[4], func(ev *Event) { ev.Principal = "mallory" })), lines[5:]...),
+			ProblemModified,
+		},
+		"unsealed insert": {
+			append(append(append([]string{}, lines[:4]...),
+				`{"kind":"statement","session_id":"s1","principal":"mallory","allowed":true}`), lines[4:]...),
+			ProblemUnchained,
+		},
+		"torn line": {
+			append(append(append([]string{}, lines[:4]...), `{"kind":"stat`), lines[4:]...),
+			ProblemModified,
+		},
+	} {
+		rep := verifyLines(t, c.lines, pub)
+		if !strings.Contains(strings.Join(problemKinds(rep), " "), c.want) {
+			t.Errorf("%s: problems = %v, want a %s", name, rep.Problems, c.want)
+		}
+	}
+}
+
+// An editor who re-chains the file recomputes every hash, and the chain alone
+// cannot tell. The checkpoint signature can.
+func TestCheckpointsCatchARechainedFile(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	lines := chainedFile(t, 8, ChainOptions{CheckpointEvery: 4, SigningKey: priv})
+
+	var forged []string
+	prev := ""
+	for i, line := range lines {
+		var ev Event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			ev.Principal = "mallory"
+		}
+		sig := ev.Checkpoint
+		ev.PrevHash = prev
+		ev.Hash = chainHash(ev)
+		ev.Checkpoint = sig
+		prev = ev.Hash
+		b, _ := json.Marshal(ev)
+		forged = append(forged, string(b))
+	}
+
+	if rep := verifyLines(t, forged, nil); !rep.OK() {
+		t.Fatalf("a consistently re-chained file should pass the hash check alone: %v", rep.Problems)
+	}
+	rep := verifyLines(t, forged, pub)
+	if kinds := problemKinds(rep); len(kinds) != 2 || kinds[0] != ProblemSignature {
+		t.Errorf("problems = %v, want both checkpoints refused", rep.Problems)
+	}
+}
+
+// A restarted sidecar appends to its file, and the chain carries on where
+// the file left off.
+func TestChainResumesFromTheFileTail(t *testing.T) {
+	var buf bytes.Buffer
+	write := func(s *JSONLSink, n int) {
+		for range n {
+			if err := s.Write(context.Background(), Event{Kind: KindStatement, SessionID: "s1"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write(NewJSONLSink(&buf, SinkOptions{Chain: NewChain(ChainOptions{})}), 3)
+
+	seq, hash, err := ChainTail(bytes.NewReader(buf.Bytes()))
+	if err != nil || seq != 3 || hash == "" {
+		t.Fatalf("ChainTail = %d, %q, %v; want seq 3", seq, hash, err)
+	}
+	c := NewChain(ChainOptions{})
+	c.Resume(seq, hash)
+	write(NewJSONLSink(&buf, SinkOptions{Chain: c}), 2)
+
+	rep, err := VerifyJSONL(&buf, nil)
+	if err != nil || !rep.OK() || rep.Last != 5 {
+		t.Errorf("report = %+v, %v; want seq 1-5 and no problems", rep, err)
+	}
+}
+
+// Lines from before the chain was turned on are history, not tampering.
+func TestVerifyAcceptsRecordsFromBeforeTheChain(t *testing.T) {
+	lines := append([]string{`{"kind":"session_start","session_id":"s0"}`}, chainedFile(t, 2, ChainOptions{})...)
+	rep := verifyLines(t, lines, nil)
+	if !rep.OK() || rep.Unchained != 1 || rep.Last != 2 {
+		t.Errorf("report = %+v, want OK with one unchained record", rep)
+	}
+}
+
+func TestParseChainKeys(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+	gotPriv, err := ParseSigningKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil || !gotPriv.Equal(priv) {
+		t.Errorf("ParseSigningKey: %v", err)
+	}
+	der, _ = x509.MarshalPKIXPublicKey(pub)
+	gotPub, err := ParseVerifyKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil || !gotPub.Equal(pub) {
+		t.Errorf("ParseVerifyKey: %v", err)
+	}
+
+	ec, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ = x509.MarshalPKCS8PrivateKey(ec)
+	if _, err := ParseSigningKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})); !errors.Is(err, ErrNotEd25519) {
+		t.Errorf("an ECDSA key: err = %v, want ErrNotEd25519", err)
+	}
+}
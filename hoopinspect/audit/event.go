@@ -22,6 +22,7 @@ package audit
 import (
 	"context"
 	"maps"
+	"strconv"
 	"time"
 
 	"github.com/hoophq/hoopinspect"
@@ -52,6 +53,11 @@ const (
 	// was masked without recording the values.
 	KindMasked Kind = "masked"
 
+	// KindLimited is emitted when a result set was truncated at a
+	// policy.ResultLimit. A limit that DENIES is a KindViolation instead,
+	// because a refused result is a refusal whichever mechanism made it.
+	KindLimited Kind = "limited"
+
 	// KindError is emitted for transport or upstream failures, so a session
 	// that died mid-flight is distinguishable from one that completed.
 	KindError Kind = "error"
@@ -93,7 +99,8 @@ type Event struct {
 	Tables []string `json:"tables,omitempty"`
 
 	// Allowed is the policy outcome. Only meaningful on KindStatement and
-	// KindViolation.
+	// KindViolation; a KindLimited event reports true, since what it records
+	// was delivered, only shorter.
 	Allowed bool `json:"allowed"`
 
 	// Rule identifies the policy rule that denied, when one did.
@@ -128,6 +135,15 @@ type Event struct {
 
 	// Metadata carries deployment-specific fields.
 	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// ChainSeq, PrevHash and Hash link the record into a hash chain, and
+	// Checkpoint signs a link, when the sink that wrote it has a Chain.
+	// Empty otherwise; see Chain. ChainSeq rather than Seq because a store
+	// numbers its records too, and the two count different things.
+	ChainSeq   uint64 `json:"chain_seq,omitempty"`
+	PrevHash   string `json:"prev_hash,omitempty"`
+	Hash       string `json:"hash,omitempty"`
+	Checkpoint string `json:"checkpoint,omitempty"`
 }
 
 // Sink persists events.
@@ -260,3 +276,44 @@ func MaskedEvent(s *session.Session, entities []string, count int) Event {
 		MaskedCount:    count,
 	}
 }
+
+// LimitEvent records a result set that crossed a policy.ResultLimit.
+//
+// action is what the gate did about it: "deny" produces a KindViolation,
+// because a refused result is a refusal whichever mechanism made it;
+// "truncate", or "observe" on a lane that enforces nothing, produces a
+// KindLimited.
+//
+// rows and bytes are what the client had received from that result set
+// before the crossing. The server's own total is not known at that point
+// and, for a denial, never will be.
+//
+// Metadata keys:
+//
+//	"limit.action"  "deny", "truncate" or "observe"
+//	"limit.rows"    rows delivered before the crossing
+//	"limit.bytes"   row bytes delivered before the crossing
+func LimitEvent(s *session.Session, rule, message, action string, rows int, bytes int64) Event {
+	kind := KindLimited
+	if action == "deny" {
+		kind = KindViolation
+	}
+	md := make(map[string]string, len(s.Metadata)+3)
+	maps.Copy(md, s.Metadata)
+	md["limit.action"] = action
+	md["limit.rows"] = strconv.Itoa(rows)
+	md["limit.bytes"] = strconv.FormatInt(bytes, 10)
+	return Event{
+		Kind:       kind,
+		Timestamp:  time.Now().UTC(),
+		SessionID:  s.ID,
+		Principal:  s.Identity.Principal(),
+		Protocol:   s.Protocol,
+		Connection: s.Connection,
+		Direction:  hoopinspect.FromServer,
+		Allowed:    kind == KindLimited,
+		Rule:       rule,
+		Message:    message,
+		Metadata:   md,
+	}
+}
@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers an HTTPSink signs its requests with.
+const (
+	HeaderTimestamp = "X-Hoop-Timestamp"
+	HeaderSignature = "X-Hoop-Signature"
+)
+
+// HTTPOptions configures NewHTTPSink.
+type HTTPOptions struct {
+	// URL receives each batch as a POST.
+	URL string
+
+	// Client sends the requests. Defaults to a client with no timeout of its
+	// own, since BatchOptions.Timeout bounds every attempt.
+	Client *http.Client
+
+	// Header is added to every request, for a collector's API key.
+	Header http.Header
+
+	// HMACKey, when set, signs every request body. See NewHTTPSink.
+	HMACKey []byte
+}
+
+// NewHTTPSink posts batches of events to a collector over HTTP, as JSON
+// lines: the same bytes a JSONLSink writes, so a receiver that can read the
+// file can read the request.
+//
+// With an HMACKey, each request carries two headers. X-Hoop-Timestamp is the
+// Unix time it was signed, and X-Hoop-Signature is "sha256=" and the hex
+// HMAC-SHA256 of the timestamp, a ".", and the body. A receiver recomputes
+// it, compares with hmac.Equal, and refuses a timestamp more than a few
+// minutes old. Signing the timestamp with the body is what makes a captured
+// request useless to replay later; signing the body alone would not.
+//
+// A 408, a 429 or a 5xx is retried. Any other status outside 2xx wraps
+// ErrRejected.
+func NewHTTPSink(o HTTPOptions, opts SinkOptions, batch BatchOptions) *BatchSink {
+	return NewBatchSink("http", o.deliver, opts, batch)
+}
+
+func (o HTTPOptions) deliver(ctx context.Context, batch []Event) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	enc.SetEscapeHTML(false)
+	for _, ev := range batch {
+		if err := enc.Encode(ev); err != nil {
+			return fmt.Errorf("%w: encoding event: %v", ErrRejected, err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.URL, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+	for k, vs := range o.Header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if len(o.HMACKey) > 0 {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(HeaderTimestamp, ts)
+		req.Header.Set(HeaderSignature, SignBody(o.HMACKey, ts, body.Bytes()))
+	}
+	return post(o.Client, req)
+}
+
+// SignBody returns the X-Hoop-Signature value for body sent at ts, for a
+// receiver to compare against the header with hmac.Equal.
+func SignBody(key []byte, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// marshalEvent is one event as a JSONLSink writes it, without the newline.
+func marshalEvent(ev Event) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(ev); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte{'\n'}), nil
+}
+
+// post sends req and sorts the answer into delivered, retryable and
+// rejected.
+func post(c *http.Client, req *http.Request) error {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drained so the connection is reused, and bounded: the answer is an
+	// acknowledgement, and a collector that streams more is not one to wait
+	// on.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch code := resp.StatusCode; {
+	case code >= 200 && code < 300:
+		return nil
+	case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests, code >= 500:
+		return fmt.Errorf("%s: %s", req.URL.Redacted(), resp.Status)
+	default:
+		return fmt.Errorf("%w: %s: %s", ErrRejected, req.URL.Redacted(), resp.Status)
+	}
+}
@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// OTLPOptions configures NewOTLPSink.
+type OTLPOptions struct {
+	// Endpoint is the collector's logs URL, path included: for an
+	// OpenTelemetry Collector, https://collector:4318/v1/logs.
+	Endpoint string
+
+	// Client and Header are as in HTTPOptions.
+	Client *http.Client
+	Header http.Header
+
+	// ServiceName is the resource's service.name. Default "hoop-inspect".
+	ServiceName string
+}
+
+// NewOTLPSink exports events as OpenTelemetry log records over OTLP/HTTP,
+// with the JSON encoding.
+//
+// JSON rather than protobuf because protobuf would need a dependency this
+// module does not take, and every collector that speaks OTLP/HTTP accepts
+// both. Each record's body is the event's JSON line, whole, so nothing the
+// file holds is lost on the way; the fields a query filters on are repeated
+// as attributes. A violation is severity WARN and an error ERROR, so a
+// backend's severity filter finds denials without parsing the body.
+//
+// A collector's partial success, some records refused and the rest kept,
+// counts as delivered. Retrying it would duplicate what was kept to resend
+// what will be refused again, and the collector's own metrics report the
+// refusal.
+func NewOTLPSink(o OTLPOptions, opts SinkOptions, batch BatchOptions) *BatchSink {
+	if o.ServiceName == "" {
+		o.ServiceName = "hoop-inspect"
+	}
+	return NewBatchSink("otlp", o.deliver, opts, batch)
+}
+
+// The OTLP/JSON shapes this sink writes, a subset of
+// opentelemetry/proto/logs/v1. 64-bit integers are strings, as the protobuf
+// JSON mapping has them.
+type (
+	otlpLogsRequest struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeLogs struct {
+		Scope      otlpScope       `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano   string         `json:"timeUnixNano"`
+		SeverityNumber int            `json:"severityNumber"`
+		SeverityText   string         `json:"severityText"`
+		Body           otlpAnyValue   `json:"body"`
+		Attributes     []otlpKeyValue `json:"attributes"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		BoolValue   *bool   `json:"boolValue,omitempty"`
+	}
+)
+
+func otlpString(k, v string) otlpKeyValue {
+	return otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: &v}}
+}
+
+// OpenTelemetry severity numbers: INFO, WARN and ERROR.
+const (
+	otlpInfo  = 9
+	otlpWarn  = 13
+	otlpError = 17
+)
+
+func otlpRecord(ev Event) (otlpLogRecord, error) {
+	line, err := marshalEvent(ev)
+	if err != nil {
+		return otlpLogRecord{}, err
+	}
+	body := string(line)
+	rec := otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(ev.Timestamp.UnixNano(), 10),
+		SeverityNumber: otlpInfo,
+		SeverityText:   "INFO",
+		Body:           otlpAnyValue{StringValue: &body},
+	}
+	switch ev.Kind {
+	case KindViolation:
+		rec.SeverityNumber, rec.SeverityText = otlpWarn, "WARN"
+	case KindError:
+		rec.SeverityNumber, rec.SeverityText = otlpError, "ERROR"
+	}
+
+	allowed := ev.Allowed
+	rec.Attributes = []otlpKeyValue{
+		otlpString("hoop.kind", string(ev.Kind)),
+		{Key: "hoop.allowed", Value: otlpAnyValue{BoolValue: &allowed}},
+	}
+	for _, kv := range [][2]string{
+		{"hoop.session_id", string(ev.SessionID)},
+		{"hoop.principal", ev.Principal},
+		{"hoop.connection", ev.Connection},
+		{"hoop.protocol", string(ev.Protocol)},
+		{"hoop.operation", string(ev.Operation)},
+		{"hoop.rule", ev.Rule},
+	} {
+		if kv[1] != "" {
+			rec.Attributes = append(rec.Attributes, otlpString(kv[0], kv[1]))
+		}
+	}
+	return rec, nil
+}
+
+func (o OTLPOptions) deliver(ctx context.Context, batch []Event) error {
+	records := make([]otlpLogRecord, 0, len(batch))
+	for _, ev := range batch {
+		rec, err := otlpRecord(ev)
+		if err != nil {
+			return fmt.Errorf("%w: encoding event: %v", ErrRejected, err)
+		}
+		records = append(records, rec)
+	}
+	body, err := json.Marshal(otlpLogsRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource:  otlpResource{Attributes: []otlpKeyValue{otlpString("service.name", o.ServiceName)}},
+		ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: "hoopinspect/audit"}, LogRecords: records}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+	for k, vs := range o.Header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return post(o.Client, req)
+}
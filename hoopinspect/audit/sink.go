@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 )
@@ -34,7 +35,8 @@ var (
 	// down sessions that were about to finish cleanly.
 	ErrSinkClosed = errors.New("audit: sink is closed")
 
-	// ErrQueueFull is returned by AsyncSink.Write when the queue has no room.
+	// ErrQueueFull is returned by AsyncSink.Write when the queue has no room,
+	// and by a BatchSink's when MaxPending events are waiting.
 	//
 	// The alternative designs are both worse. Blocking would make a slow
 	// audit store stall the user's query, the problem AsyncSink exists to
@@ -68,6 +70,11 @@ type SinkOptions struct {
 	// Defaults to time.Now. Injectable so a test can assert on an exact
 	// line instead of a regex.
 	Now func() time.Time
+
+	// Chain, when set, seals each event into a hash chain as it is written.
+	// Sealing happens after redaction and truncation, so the hash covers
+	// the record as stored rather than one nobody can see.
+	Chain *Chain
 }
 
 func (o SinkOptions) maxStatementBytes() int {
@@ -194,9 +201,16 @@ func (s *JSONLSink) Write(_ context.Context, ev Event) error {
 	if s.closed {
 		return ErrSinkClosed
 	}
+	// Sealed under the same lock, so the chain's order is the file's.
+	if s.opts.Chain != nil {
+		ev = s.opts.Chain.Seal(ev)
+	}
 	if err := s.enc.Encode(ev); err != nil {
 		return fmt.Errorf("audit: encoding event: %w", err)
 	}
+	if s.opts.Chain != nil {
+		s.opts.Chain.Advance(ev)
+	}
 	return nil
 }
 
@@ -376,6 +390,10 @@ type AsyncSink struct {
 	mu     sync.Mutex
 	closed bool
 
+	// dropped counts events refused with ErrQueueFull. The caller decided
+	// what each refusal meant; this is how many decisions there were.
+	dropped atomic.Int64
+
 	// errMu guards the drain goroutine's failure record. Only the first
 	// error is kept: a backing store that is down fails for every event, and
 	// joining ten thousand identical errors buries the one you need.
@@ -438,10 +456,21 @@ func (a *AsyncSink) Write(_ context.Context, ev Event) error {
 	case a.queue <- ev:
 		return nil
 	default:
+		a.dropped.Add(1)
 		return ErrQueueFull
 	}
 }
 
+// Depth reports how many events are queued and not yet drained. A queue that
+// sits near Capacity is the warning before ErrQueueFull.
+func (a *AsyncSink) Depth() int { return len(a.queue) }
+
+// Capacity reports the queue's size.
+func (a *AsyncSink) Capacity() int { return cap(a.queue) }
+
+// Dropped reports how many events Write refused with ErrQueueFull.
+func (a *AsyncSink) Dropped() int64 { return a.dropped.Load() }
+
 // Close stops accepting events, drains what is queued, then closes inner.
 // Safe to call twice; the second call returns the first call's result.
 //
@@ -593,6 +593,9 @@ func TestAsyncSinkReturnsErrQueueFullWithoutBlocking(t *testing.T) {
 	if got.accepted < 2 {
 		t.Errorf("queue accepted only %d events, want at least queueSize=2", got.accepted)
 	}
+	if a.Dropped() != 1 || a.Capacity() != 2 {
+		t.Errorf("Dropped() = %d, Capacity() = %d; want the one refusal and a queue of 2", a.Dropped(), a.Capacity())
+	}
 
 	inner.unblock()
 	if err := a.Close(); err != nil {
@@ -0,0 +1,221 @@
+package audit
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FacilityLocal0 is the syslog facility a SyslogSink uses unless told
+// otherwise: the first of the eight reserved for local use, which is where a
+// site routes an application's messages.
+const FacilityLocal0 = 16
+
+// SyslogOptions configures NewSyslogSink.
+type SyslogOptions struct {
+	// Network is "udp", "tcp" or "tls".
+	Network string
+
+	// Addr is the collector's host:port.
+	Addr string
+
+	// TLS configures the "tls" network. Nil verifies the collector against
+	// the system roots.
+	TLS *tls.Config
+
+	// Facility is the syslog facility, 1 through 23. Zero means
+	// FacilityLocal0: facility 0 is the kernel's.
+	Facility int
+
+	// AppName and Hostname fill the RFC 5424 header. Defaults
+	// "hoop-inspect" and os.Hostname.
+	AppName  string
+	Hostname string
+
+	// Timeout bounds a dial and a write. Default five seconds.
+	Timeout time.Duration
+}
+
+// SyslogSink writes each event as an RFC 5424 message to a syslog collector.
+//
+// The MSG part is the event's JSON line, the same bytes a JSONLSink writes,
+// so a SIEM parses it the way it parses the file. MSGID is the event's kind,
+// and a violation goes out at severity warning and an error at err, so a
+// collector can route denials without parsing JSON. Over TCP and TLS each
+// message is framed by octet counting, as RFC 5425 requires for TLS and RFC
+// 6587 allows for TCP: a statement may contain a newline, and
+// newline-framed syslog would split it into two messages.
+//
+// Write is synchronous and returns the transport's error, so a collector
+// that is down fails the write, which is what fail_closed acts on. Wrap the
+// sink in an AsyncSink to keep the round trip off the data path. UDP reports
+// nothing about delivery; a TCP write can succeed into the kernel's buffer of
+// a connection the collector has already dropped. Use TLS where losing a
+// record matters.
+type SyslogSink struct {
+	opts SinkOptions
+	o    SyslogOptions
+	pid  string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// NewSyslogSink returns a sink for the collector at o.Addr. It does not
+// dial: the first Write does, so a collector that is down at startup fails
+// statements, the way one that goes down later does, rather than the start.
+func NewSyslogSink(o SyslogOptions, opts SinkOptions) (*SyslogSink, error) {
+	switch o.Network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("audit: syslog network %q: want udp, tcp or tls", o.Network)
+	}
+	if _, _, err := net.SplitHostPort(o.Addr); err != nil {
+		return nil, fmt.Errorf("audit: syslog addr: %w", err)
+	}
+	if o.Facility < 0 || o.Facility > 23 {
+		return nil, fmt.Errorf("audit: syslog facility %d is outside 0-23", o.Facility)
+	}
+	if o.Facility == 0 {
+		o.Facility = FacilityLocal0
+	}
+	if o.AppName == "" {
+		o.AppName = "hoop-inspect"
+	}
+	if o.Hostname == "" {
+		o.Hostname, _ = os.Hostname()
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	opts.Chain = nil
+	return &SyslogSink{opts: opts, o: o, pid: strconv.Itoa(os.Getpid())}, nil
+}
+
+// Syslog severities.
+const (
+	severityErr     = 3
+	severityWarning = 4
+	severityInfo    = 6
+)
+
+// format renders ev as one RFC 5424 message, unframed.
+func (s *SyslogSink) format(ev Event) ([]byte, error) {
+	line, err := marshalEvent(ev)
+	if err != nil {
+		return nil, fmt.Errorf("audit: encoding event: %w", err)
+	}
+	sev := severityInfo
+	switch ev.Kind {
+	case KindViolation:
+		sev = severityWarning
+	case KindError:
+		sev = severityErr
+	}
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	header := fmt.Sprintf("<%d>1 %s %s %s %s %s - ",
+		s.o.Facility*8+sev,
+		ev.Timestamp.Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(s.o.Hostname, 255),
+		headerField(s.o.AppName, 48),
+		headerField(s.pid, 128),
+		headerField(string(ev.Kind), 32),
+	)
+	return append([]byte(header), line...), nil
+}
+
+// headerField makes v a legal RFC 5424 header field: printable ASCII with no
+// spaces, at most max bytes, and "-" for nothing.
+func headerField(v string, max int) string {
+	v = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, v)
+	if v == "" {
+		return "-"
+	}
+	return v[:min(len(v), max)]
+}
+
+// Write sends ev. A write that fails on an established connection is retried
+// once on a new one: a collector that restarted leaves a dead connection
+// behind, and the first write after is the one that finds out.
+func (s *SyslogSink) Write(_ context.Context, ev Event) error {
+	msg, err := s.format(s.opts.apply(ev))
+	if err != nil {
+		return err
+	}
+	if s.o.Network != "udp" {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	redial := s.conn != nil
+	err = s.send(msg)
+	if err != nil && redial {
+		err = s.send(msg)
+	}
+	if err != nil {
+		return fmt.Errorf("audit: syslog %s: %w", s.o.Addr, err)
+	}
+	return nil
+}
+
+// send writes msg, dialing first when there is no connection, and drops
+// the connection on failure so the next send dials afresh.
+func (s *SyslogSink) send(msg []byte) error {
+	if s.conn == nil {
+		c, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = c
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(s.o.Timeout))
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: s.o.Timeout}
+	if s.o.Network == "tls" {
+		return tls.DialWithDialer(d, "tcp", s.o.Addr, s.o.TLS)
+	}
+	return d.Dial(s.o.Network, s.o.Addr)
+}
+
+// Close closes the connection. Safe to call twice.
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("audit: closing syslog connection: %w", err)
+	}
+	return nil
+}
+
+var _ Sink = (*SyslogSink)(nil)
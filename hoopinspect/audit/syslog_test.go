@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogMessageFormat(t *testing.T) {
+	s, err := NewSyslogSink(SyslogOptions{Network: "udp", Addr: "127.0.0.1:514", Hostname: "relay 1", Facility: 10}, SinkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 3, 1, 12, 0, 0, 123456000, time.UTC)
+	msg, err := s.format(Event{Kind: KindViolation, Timestamp: at, SessionID: "s1", Rule: "no-deletes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// authpriv (10) * 8 + warning (4)
+	want := "<84>1 2026-03-01T12:00:00.123456Z relay_1 hoop-inspect " + s.pid + " violation - {"
+	if !strings.HasPrefix(string(msg), want) {
+		t.Errorf("message = %q, want prefix %q", msg, want)
+	}
+
+	for _, o := range []SyslogOptions{
+		{Network: "unix", Addr: "127.0.0.1:514"},
+		{Network: "udp", Addr: "collector"},
+		{Network: "tcp", Addr: "127.0.0.1:514", Facility: 24},
+	} {
+		if _, err := NewSyslogSink(o, SinkOptions{}); err == nil {
+			t.Errorf("%+v was accepted", o)
+		}
+	}
+}
+
+// Over TCP every message is octet-counted, so a statement with a newline in
+// it arrives as one message.
+func TestSyslogTCPFramesAndReconnects(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan string, 4)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(c)
+			n, err := r.ReadString(' ')
+			if err != nil {
+				c.Close()
+				continue
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(n))
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(r, buf); err == nil {
+				got <- string(buf)
+			}
+			// One message per connection, so the sink has to redial.
+			c.Close()
+		}
+	}()
+
+	s, err := NewSyslogSink(SyslogOptions{Network: "tcp", Addr: ln.Addr().String()}, SinkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Write(context.Background(), Event{Kind: KindStatement, Statement: "SELECT 1\nFROM t"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-got:
+		if !strings.Contains(m, `"statement":"SELECT 1\nFROM t"`) {
+			t.Errorf("message = %q", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message")
+	}
+
+	// The collector hung up. The next write may land in the dead
+	// connection's buffer; the one after must redial.
+	deadline := time.After(5 * time.Second)
+	for {
+		if err := s.Write(context.Background(), Event{Kind: KindStatement, Statement: "again"}); err != nil {
+			t.Fatalf("write after the collector hung up: %v", err)
+		}
+		select {
+		case m := <-got:
+			if !strings.Contains(m, "again") {
+				t.Errorf("message = %q", m)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("the sink never reconnected")
+		}
+	}
+}
+
+func TestSyslogWriteFailsWhenTheCollectorIsDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s, err := NewSyslogSink(SyslogOptions{Network: "tcp", Addr: addr, Timeout: time.Second}, SinkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(context.Background(), Event{Kind: KindStatement}); err == nil {
+		t.Error("a write to a closed port succeeded")
+	}
+}
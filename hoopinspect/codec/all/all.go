@@ -11,7 +11,10 @@
 package all
 
 import (
+	_ "github.com/hoophq/hoopinspect/codec/grpc"
 	_ "github.com/hoophq/hoopinspect/codec/http"
+	_ "github.com/hoophq/hoopinspect/codec/mongodb"
 	_ "github.com/hoophq/hoopinspect/codec/mssql"
+	_ "github.com/hoophq/hoopinspect/codec/mysql"
 	_ "github.com/hoophq/hoopinspect/codec/postgres"
 )
@@ -0,0 +1,474 @@
+package grpc
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// This file reads protobuf: the FileDescriptorSet an operator supplies, and
+// the messages it describes.
+//
+// The set is what `protoc --descriptor_set_out=api.pb --include_imports`
+// writes, or `buf build -o api.pb`. It is itself a protobuf message, so the
+// decoder that reads a request reads the schema too, and no generated code
+// or protobuf runtime is linked. Only the parts a rule can use are kept:
+// services and their methods, messages and their fields. Options, enums'
+// value names, comments and source info are skipped.
+
+// Descriptors is a parsed FileDescriptorSet. It is immutable once built and
+// safe to share between connections.
+type Descriptors struct {
+	// methods is keyed by the request path, "/pkg.Service/Method".
+	methods  map[string]*methodDesc
+	messages map[string]*messageDesc
+}
+
+type methodDesc struct {
+	service, name string
+	input, output *messageDesc
+}
+
+type messageDesc struct {
+	// name is fully qualified, without protoc's leading dot.
+	name   string
+	fields map[uint64]*fieldDesc
+}
+
+type fieldDesc struct {
+	name     string
+	kind     fieldKind
+	repeated bool
+
+	// message is the field's type when kind is kindMessage.
+	message  *messageDesc
+	typeName string
+}
+
+// fieldKind is FieldDescriptorProto.Type.
+type fieldKind uint64
+
+const (
+	kindDouble   fieldKind = 1
+	kindFloat    fieldKind = 2
+	kindInt64    fieldKind = 3
+	kindUint64   fieldKind = 4
+	kindInt32    fieldKind = 5
+	kindFixed64  fieldKind = 6
+	kindFixed32  fieldKind = 7
+	kindBool     fieldKind = 8
+	kindString   fieldKind = 9
+	kindGroup    fieldKind = 10
+	kindMessage  fieldKind = 11
+	kindBytes    fieldKind = 12
+	kindUint32   fieldKind = 13
+	kindEnum     fieldKind = 14
+	kindSfixed32 fieldKind = 15
+	kindSfixed64 fieldKind = 16
+	kindSint32   fieldKind = 17
+	kindSint64   fieldKind = 18
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// errProto is bytes that are not the protobuf they claim to be.
+var errProto = errors.New("hoopinspect/grpc: malformed protobuf")
+
+// ParseDescriptorSet reads a serialized FileDescriptorSet.
+//
+// Every type a method or field names must be in the set, so a set built
+// without --include_imports is refused rather than loaded with holes: a
+// field whose type is missing cannot be decoded, and the rule written
+// against it would never fire.
+func ParseDescriptorSet(data []byte) (*Descriptors, error) {
+	d := &Descriptors{methods: map[string]*methodDesc{}, messages: map[string]*messageDesc{}}
+	type pendingMethod struct {
+		path, service, name, input, output string
+	}
+	var methods []pendingMethod
+
+	err := eachField(data, func(num uint64, wt int, v []byte, _ uint64) error {
+		if num != 1 || wt != wireBytes { // FileDescriptorSet.file
+			return nil
+		}
+		var pkg string
+		var msgs, services [][]byte
+		err := eachField(v, func(num uint64, wt int, v []byte, _ uint64) error {
+			if wt != wireBytes {
+				return nil
+			}
+			switch num {
+			case 2: // package
+				pkg = string(v)
+			case 4: // message_type
+				msgs = append(msgs, v)
+			case 6: // service
+				services = append(services, v)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if err := d.addMessage(qualify(pkg, ""), m); err != nil {
+				return err
+			}
+		}
+		for _, s := range services {
+			var svc string
+			var ms [][]byte
+			err := eachField(s, func(num uint64, wt int, v []byte, _ uint64) error {
+				switch {
+				case num == 1 && wt == wireBytes:
+					svc = string(v)
+				case num == 2 && wt == wireBytes:
+					ms = append(ms, v)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			full := qualify(pkg, svc)
+			for _, m := range ms {
+				pm := pendingMethod{service: full}
+				err := eachField(m, func(num uint64, wt int, v []byte, _ uint64) error {
+					if wt != wireBytes {
+						return nil
+					}
+					switch num {
+					case 1:
+						pm.name = string(v)
+					case 2:
+						pm.input = strings.TrimPrefix(string(v), ".")
+					case 3:
+						pm.output = strings.TrimPrefix(string(v), ".")
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				pm.path = "/" + full + "/" + pm.name
+				methods = append(methods, pm)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("hoopinspect/grpc: descriptor set: %w", err)
+	}
+
+	for _, m := range d.messages {
+		for _, f := range m.fields {
+			if f.kind != kindMessage && f.kind != kindGroup {
+				continue
+			}
+			if f.message = d.messages[f.typeName]; f.message == nil {
+				return nil, fmt.Errorf("hoopinspect/grpc: descriptor set: field %s.%s has type %s, "+
+					"which the set does not define (build it with --include_imports)",
+					m.name, f.name, f.typeName)
+			}
+		}
+	}
+	for _, pm := range methods {
+		in, out := d.messages[pm.input], d.messages[pm.output]
+		if in == nil || out == nil {
+			return nil, fmt.Errorf("hoopinspect/grpc: descriptor set: method %s takes or returns "+
+				"a type the set does not define (build it with --include_imports)", pm.path)
+		}
+		d.methods[pm.path] = &methodDesc{service: pm.service, name: pm.name, input: in, output: out}
+	}
+	if len(d.methods) == 0 {
+		return nil, errors.New("hoopinspect/grpc: descriptor set defines no service methods")
+	}
+	return d, nil
+}
+
+func (d *Descriptors) method(path string) *methodDesc {
+	if d == nil {
+		return nil
+	}
+	return d.methods[path]
+}
+
+// addMessage records a DescriptorProto and its nested types under scope.
+func (d *Descriptors) addMessage(scope string, b []byte) error {
+	m := &messageDesc{fields: map[uint64]*fieldDesc{}}
+	var nested, fields [][]byte
+	err := eachField(b, func(num uint64, wt int, v []byte, _ uint64) error {
+		if wt != wireBytes {
+			return nil
+		}
+		switch num {
+		case 1:
+			m.name = qualify(scope, string(v))
+		case 2:
+			fields = append(fields, v)
+		case 3:
+			nested = append(nested, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, fb := range fields {
+		f := &fieldDesc{}
+		var num uint64
+		err := eachField(fb, func(n uint64, wt int, v []byte, x uint64) error {
+			switch {
+			case n == 1 && wt == wireBytes:
+				f.name = string(v)
+			case n == 3 && wt == wireVarint:
+				num = x
+			case n == 4 && wt == wireVarint:
+				f.repeated = x == 3 // LABEL_REPEATED
+			case n == 5 && wt == wireVarint:
+				f.kind = fieldKind(x)
+			case n == 6 && wt == wireBytes:
+				f.typeName = strings.TrimPrefix(string(v), ".")
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		m.fields[num] = f
+	}
+	d.messages[m.name] = m
+	for _, n := range nested {
+		if err := d.addMessage(m.name, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func qualify(scope, name string) string {
+	switch {
+	case scope == "":
+		return name
+	case name == "":
+		return scope
+	}
+	return scope + "." + name
+}
+
+// eachField walks one message's fields in wire order. v is a length-
+// delimited field's bytes and x a varint or fixed field's value.
+func eachField(b []byte, fn func(num uint64, wt int, v []byte, x uint64) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errProto
+		}
+		b = b[n:]
+		num, wt := key>>3, int(key&7)
+		if num == 0 {
+			return errProto
+		}
+		var (
+			v []byte
+			x uint64
+		)
+		switch wt {
+		case wireVarint:
+			if x, n = binary.Uvarint(b); n <= 0 {
+				return errProto
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errProto
+			}
+			x, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errProto
+			}
+			x, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return errProto
+			}
+			v, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			// Groups were deprecated before proto3 existed, and no
+			// schema a gRPC service is built from still declares one.
+			return fmt.Errorf("%w: wire type %d", errProto, wt)
+		}
+		if err := fn(num, wt, v, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decoded is one request message, read against its type.
+type decoded struct {
+	// value is the message as JSON-ready maps and slices, keyed by field
+	// name the way protoc names them.
+	value map[string]any
+
+	// types lists, in the order they were met, the message types the
+	// message set fields of, and fields names those fields per type. They
+	// become the statement's Relations: a type is a table, its fields are
+	// its columns.
+	types  []string
+	fields map[string][]string
+}
+
+// maxMessageDepth bounds nesting, as the protobuf runtimes do, so a message
+// that nests itself cannot exhaust the stack.
+const maxMessageDepth = 100
+
+// decodeMessage reads a message of type m.
+func decodeMessage(m *messageDesc, b []byte) (decoded, error) {
+	out := decoded{fields: map[string][]string{}}
+	v, err := out.read(m, b, 0)
+	out.value = v
+	return out, err
+}
+
+func (d *decoded) read(m *messageDesc, b []byte, depth int) (map[string]any, error) {
+	if depth > maxMessageDepth {
+		return nil, fmt.Errorf("%w: nested deeper than %d", errProto, maxMessageDepth)
+	}
+	obj := map[string]any{}
+	err := eachField(b, func(num uint64, wt int, v []byte, x uint64) error {
+		f := m.fields[num]
+		if f == nil {
+			// A field the schema does not know: a newer client, or a
+			// descriptor set out of date. Its number is all there is to
+			// name it by.
+			obj[strconv.FormatUint(num, 10)] = "(unknown field)"
+			d.note(m.name, strconv.FormatUint(num, 10))
+			return nil
+		}
+		d.note(m.name, f.name)
+
+		var vals []any
+		switch {
+		case f.kind == kindMessage:
+			if wt != wireBytes {
+				return fmt.Errorf("%w: field %s.%s", errProto, m.name, f.name)
+			}
+			sub, err := d.read(f.message, v, depth+1)
+			if err != nil {
+				return err
+			}
+			vals = []any{sub}
+		case (f.kind == kindString || f.kind == kindBytes) && wt != wireBytes:
+			return fmt.Errorf("%w: field %s.%s", errProto, m.name, f.name)
+		case f.kind == kindString:
+			vals = []any{string(v)}
+		case f.kind == kindBytes:
+			vals = []any{base64.StdEncoding.EncodeToString(v)}
+		case wt == wireBytes:
+			// A packed repeated scalar: the values back to back.
+			for len(v) > 0 {
+				var n int
+				switch f.kind {
+				case kindDouble, kindFixed64, kindSfixed64:
+					if len(v) < 8 {
+						return errProto
+					}
+					x, n = binary.LittleEndian.Uint64(v), 8
+				case kindFloat, kindFixed32, kindSfixed32:
+					if len(v) < 4 {
+						return errProto
+					}
+					x, n = uint64(binary.LittleEndian.Uint32(v)), 4
+				default:
+					if x, n = binary.Uvarint(v); n <= 0 {
+						return errProto
+					}
+				}
+				vals = append(vals, scalar(f.kind, x))
+				v = v[n:]
+			}
+		default:
+			vals = []any{scalar(f.kind, x)}
+		}
+
+		if !f.repeated {
+			obj[f.name] = vals[len(vals)-1] // the last one wins, as on the server
+			return nil
+		}
+		list, _ := obj[f.name].([]any)
+		obj[f.name] = append(list, vals...)
+		return nil
+	})
+	return obj, err
+}
+
+func (d *decoded) note(msg, field string) {
+	if _, ok := d.fields[msg]; !ok {
+		d.types = append(d.types, msg)
+	}
+	for _, have := range d.fields[msg] {
+		if have == field {
+			return
+		}
+	}
+	d.fields[msg] = append(d.fields[msg], field)
+}
+
+// scalar renders a varint or fixed field as its declared type. 64-bit
+// integers are strings, as protobuf's JSON mapping writes them, since a JSON
+// number loses precision past 2^53.
+func scalar(k fieldKind, x uint64) any {
+	switch k {
+	case kindDouble:
+		return float(math.Float64frombits(x))
+	case kindFloat:
+		return float(float64(math.Float32frombits(uint32(x))))
+	case kindBool:
+		return x != 0
+	case kindInt32, kindSfixed32, kindEnum:
+		return int64(int32(x))
+	case kindUint32, kindFixed32:
+		return int64(uint32(x))
+	case kindSint32:
+		return int64(int32(uint32(x)>>1) ^ -int32(x&1))
+	case kindInt64, kindSfixed64:
+		return strconv.FormatInt(int64(x), 10)
+	case kindSint64:
+		return strconv.FormatInt(int64(x>>1)^-int64(x&1), 10)
+	}
+	return strconv.FormatUint(x, 10)
+}
+
+// float renders a floating-point field. JSON has no NaN or infinity, so
+// those are the strings protobuf's JSON mapping uses for them.
+func float(f float64) any {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+// render is the message as JSON, for Statement.HTTP.Body.
+func (d decoded) render() string {
+	b, _ := json.Marshal(d.value) // strings, numbers, bools, maps and slices only
+	return string(b)
+}
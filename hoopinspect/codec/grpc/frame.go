@@ -0,0 +1,95 @@
+package grpc
+
+import "encoding/binary"
+
+// HTTP/2 framing, RFC 9113 section 4. Every frame is a 9-byte header and a
+// payload:
+//
+//	uint24 length of the payload
+//	uint8  type
+//	uint8  flags
+//	uint32 stream id, high bit reserved
+const frameHeaderLen = 9
+
+// clientPreface opens every HTTP/2 connection a client makes with prior
+// knowledge, which is how Envoy and every gRPC client speak h2c.
+const clientPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// Frame types.
+const (
+	frameData         = 0x0
+	frameHeaders      = 0x1
+	frameRSTStream    = 0x3
+	frameSettings     = 0x4
+	framePushPromise  = 0x5
+	frameGoAway       = 0x7
+	frameContinuation = 0x9
+)
+
+// Frame flags. END_STREAM and ACK share a bit; the frame type says which.
+const (
+	flagEndStream  = 0x1
+	flagEndHeaders = 0x4
+	flagPadded     = 0x8
+	flagPriority   = 0x20
+)
+
+// frame is one frame's header and the bytes it spans in the buffer it was
+// read from.
+type frame struct {
+	typ, flags uint8
+	stream     uint32
+
+	// raw is the whole frame, header included; payload is raw past the
+	// header.
+	raw, payload []byte
+}
+
+// readFrame reads the frame at the start of b. ok is false when b holds
+// only part of it.
+func readFrame(b []byte) (f frame, ok bool) {
+	if len(b) < frameHeaderLen {
+		return frame{}, false
+	}
+	n := int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+	if len(b) < frameHeaderLen+n {
+		return frame{}, false
+	}
+	return frame{
+		typ:     b[3],
+		flags:   b[4],
+		stream:  binary.BigEndian.Uint32(b[5:]) & 0x7fffffff,
+		raw:     b[:frameHeaderLen+n],
+		payload: b[frameHeaderLen : frameHeaderLen+n],
+	}, true
+}
+
+// content returns a DATA or HEADERS payload without its padding and, on a
+// HEADERS frame, its priority block: the bytes that mean something. off is
+// where they start within payload. ok is false when the padding claims more
+// than the frame holds, which RFC 9113 makes a connection error.
+func (f frame) content() (b []byte, off int, ok bool) {
+	p := f.payload
+	pad := 0
+	if f.flags&flagPadded != 0 {
+		if len(p) < 1 {
+			return nil, 0, false
+		}
+		pad, off = int(p[0]), 1
+	}
+	if f.typ == frameHeaders && f.flags&flagPriority != 0 {
+		off += 5
+	}
+	if off+pad > len(p) {
+		return nil, 0, false
+	}
+	return p[off : len(p)-pad], off, true
+}
+
+// appendFrame appends a frame to dst.
+func appendFrame(dst []byte, typ, flags uint8, stream uint32, payload []byte) []byte {
+	n := len(payload)
+	dst = append(dst, byte(n>>16), byte(n>>8), byte(n), typ, flags)
+	dst = binary.BigEndian.AppendUint32(dst, stream&0x7fffffff)
+	return append(dst, payload...)
+}
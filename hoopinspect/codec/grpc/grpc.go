@@ -0,0 +1,629 @@
+// Package grpc inspects gRPC calls carried over cleartext HTTP/2 (h2c).
+//
+// # Where it sits
+//
+// A gRPC lane terminates TLS in front of the relay (Envoy, typically) and
+// speaks h2c with prior knowledge to it, the way Envoy speaks to any gRPC
+// upstream. The codec reads the HTTP/2 connection itself: frames, HPACK
+// header blocks and the length-prefixed messages inside DATA frames. A
+// client that opens with anything but the HTTP/2 preface (a TLS
+// ClientHello, an HTTP/1.1 Upgrade) is refused, since every request it sent
+// after that would be unreadable.
+//
+// # Methods are resources
+//
+// Every call names its method in :path, "/package.Service/Method". That path
+// is the statement's Resource, unnormalized since a method path holds no ids,
+// so an http_resource rule written for a REST lane reads a gRPC one
+// unchanged. This one closes a whole service:
+//
+//	type: http_resource
+//	resources: ["/acme.admin.v1.AdminService/**"]
+//
+// Operation comes from the method name's leading verb, the way the GraphQL
+// reader guesses a mutation's effect: GetUser and ListUsers are selects,
+// CreateUser an insert, DeleteUser a delete, and a verb the table does not
+// know is OpCall. A guess is a guess; a rule that must hold whatever the
+// method is called should name the method.
+//
+// # Messages
+//
+// With a FileDescriptorSet (Options.Descriptors), each request message is
+// decoded against its method's input type and becomes a statement of its
+// own: the message type is a relation and the fields it sets are its
+// columns, so a column rule such as "user.ssn" fires on a call that sends
+// ssn. Responses are decoded only for masking; see mask.go. A message that
+// cannot be read (compressed with an encoding other than gzip, larger than
+// MaxMessageBytes, or not the type the schema says) is OpUnknown with
+// Metadata["sql.incomplete"] saying why, so a rule naming `unknown` refuses
+// it.
+//
+// # Metadata
+//
+//	http.proto      "HTTP/2.0"
+//	grpc.service    the fully qualified service, "acme.v1.Users"
+//	grpc.method     the method name, "GetUser"
+//	grpc.stream_id  the HTTP/2 stream carrying the call
+//	grpc.message    on a message statement, its position in the call from 1
+//
+// grpc.stream_id is what a denial answers: the relay ends that call with a
+// gRPC status rather than dropping a connection other calls share.
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+func init() {
+	hoopinspect.Register(func() hoopinspect.Codec { return New(Options{}) })
+}
+
+// Metadata keys. grpc.stream_id is read by the relay's deny writer.
+const (
+	MetadataService  = "grpc.service"
+	MetadataMethod   = "grpc.method"
+	MetadataStreamID = "grpc.stream_id"
+	MetadataMessage  = "grpc.message"
+)
+
+// Options configures what the codec decodes and exposes.
+//
+// As with the http codec, the defaults expose nothing a policy engine's
+// decision log should not hold: no message contents, no headers.
+type Options struct {
+	// Descriptors is the schema request and response messages are decoded
+	// against. Nil reads methods only: every call is judged by its path
+	// and verb, and no message is decoded or masked.
+	Descriptors *Descriptors
+
+	// CaptureMessages renders each decoded request message as JSON into
+	// Statement.HTTP.Body. Off by default, for the reason http's
+	// CaptureBody is.
+	CaptureMessages bool
+
+	// MaxMessageBytes bounds a request message the codec will buffer and
+	// decode. One larger is not read and comes back as OpUnknown. Defaults
+	// to DefaultMaxMessageBytes.
+	MaxMessageBytes int
+
+	// Headers names the request metadata to expose to policy, matched
+	// case-insensitively. Pseudo-headers are never exposed; :path and
+	// :authority have fields of their own.
+	Headers []string
+}
+
+// DefaultMaxMessageBytes is gRPC's own default limit on a received message,
+// so a message any stock server accepts is one the codec reads.
+const DefaultMaxMessageBytes = 4 << 20
+
+// ErrMalformed means the client's bytes are not valid HTTP/2.
+//
+// The codec never returns it bare. HTTP/2 cannot be resynchronized: a frame
+// boundary lost or a header block skipped leaves every later frame misread,
+// so forwarding past it would carry requests no rule saw. It arrives wrapped
+// in hoopinspect.ErrStreamUnsafe, which the gate denies.
+var ErrMalformed = errors.New("hoopinspect/grpc: malformed stream")
+
+// Codec decodes one gRPC connection.
+//
+// The gate builds one per direction. The two halves share the calls in
+// flight, since a response is decoded against the method its request named
+// and only the request carries the name; Pair joins them.
+type Codec struct {
+	opts    Options
+	headers map[string]bool // lowercased allowlist
+	conn    *conn
+
+	// The request half.
+	preface bool
+	hpack   *hpackDecoder
+	last    uint32              // highest stream id a request has opened
+	calls   map[uint32]*request // calls whose request messages are read
+
+	// block is a header block waiting for its CONTINUATION frames.
+	block      []byte
+	blockFor   uint32
+	blockEnds  bool // the HEADERS frame that opened it ended the stream
+	continuing bool
+
+	// The response half; see mask.go.
+	resp responseHalf
+}
+
+// New returns a Codec.
+func New(opts Options) *Codec {
+	if opts.MaxMessageBytes <= 0 {
+		opts.MaxMessageBytes = DefaultMaxMessageBytes
+	}
+	h := make(map[string]bool, len(opts.Headers))
+	for _, name := range opts.Headers {
+		h[strings.ToLower(name)] = true
+	}
+	return &Codec{
+		opts:    opts,
+		headers: h,
+		conn:    &conn{methods: map[uint32]*methodDesc{}, ended: map[uint32]bool{}},
+		hpack:   newHPACKDecoder(),
+		calls:   map[uint32]*request{},
+	}
+}
+
+func (c *Codec) Protocol() hoopinspect.Protocol { return hoopinspect.GRPC }
+
+// Pair makes c the response half of the connection whose request half is
+// request. It implements gate.Pairer and must run before either half
+// decodes anything.
+func (c *Codec) Pair(request hoopinspect.Codec) {
+	if rc, ok := request.(*Codec); ok {
+		c.conn = rc.conn
+	}
+}
+
+// conn is what the two halves of one connection share: the method each open
+// call invoked, so the response half knows what type its messages are.
+type conn struct {
+	mu      sync.Mutex
+	methods map[uint32]*methodDesc
+
+	// ended holds the calls the server finished on its last read. They are
+	// forgotten on the next one rather than at once, because the gate
+	// decodes a chunk before it masks it, and the masking pass over that
+	// same chunk still has to find them.
+	ended map[uint32]bool
+}
+
+func (c *conn) open(id uint32, m *methodDesc) {
+	c.mu.Lock()
+	c.methods[id] = m
+	c.mu.Unlock()
+}
+
+func (c *conn) method(id uint32) *methodDesc {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.methods[id]
+}
+
+// Decode implements hoopinspect.Codec.
+//
+// Only requests yield statements. The server's frames are read so the calls
+// it finishes are forgotten; what they carry is masking's business.
+func (c *Codec) Decode(dir hoopinspect.Direction, data []byte) ([]hoopinspect.Statement, int, error) {
+	if dir == hoopinspect.FromServer {
+		return nil, c.decodeResponses(data), nil
+	}
+	stmts, n, err := c.decodeRequests(data)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", hoopinspect.ErrStreamUnsafe, err)
+	}
+	return stmts, n, err
+}
+
+func (c *Codec) decodeRequests(data []byte) ([]hoopinspect.Statement, int, error) {
+	pos := 0
+	if !c.preface {
+		n := min(len(data), len(clientPreface))
+		if string(data[:n]) != clientPreface[:n] {
+			if len(data) >= 3 && data[0] == 0x16 && data[1] == 0x03 {
+				return nil, 0, fmt.Errorf("%w: the client opened a TLS session; terminate TLS "+
+					"in front of this lane and speak h2c to it", ErrMalformed)
+			}
+			return nil, 0, fmt.Errorf("%w: the client did not open with the HTTP/2 preface; "+
+				"a gRPC lane reads h2c with prior knowledge only", ErrMalformed)
+		}
+		if n < len(clientPreface) {
+			return nil, 0, nil
+		}
+		c.preface, pos = true, len(clientPreface)
+	}
+
+	var stmts []hoopinspect.Statement
+	for {
+		f, ok := readFrame(data[pos:])
+		if !ok {
+			return stmts, pos, nil
+		}
+		pos += len(f.raw)
+		got, err := c.requestFrame(f)
+		stmts = append(stmts, got...)
+		if err != nil {
+			return stmts, pos, err
+		}
+	}
+}
+
+func (c *Codec) requestFrame(f frame) ([]hoopinspect.Statement, error) {
+	if c.continuing && (f.typ != frameContinuation || f.stream != c.blockFor) {
+		return nil, fmt.Errorf("%w: header block on stream %d interrupted by a frame of type %d",
+			ErrMalformed, c.blockFor, f.typ)
+	}
+
+	switch f.typ {
+	case frameHeaders:
+		content, _, ok := f.content()
+		if !ok {
+			return nil, fmt.Errorf("%w: HEADERS padding exceeds the frame", ErrMalformed)
+		}
+		ends := f.flags&flagEndStream != 0
+		if f.flags&flagEndHeaders != 0 {
+			return c.headerBlock(f.stream, content, ends)
+		}
+		c.block = append(c.block[:0], content...)
+		c.blockFor, c.blockEnds, c.continuing = f.stream, ends, true
+
+	case frameContinuation:
+		if !c.continuing {
+			return nil, fmt.Errorf("%w: CONTINUATION with no header block open", ErrMalformed)
+		}
+		c.block = append(c.block, f.payload...)
+		if f.flags&flagEndHeaders != 0 {
+			c.continuing = false
+			return c.headerBlock(c.blockFor, c.block, c.blockEnds)
+		}
+
+	case frameData:
+		content, _, ok := f.content()
+		if !ok {
+			return nil, fmt.Errorf("%w: DATA padding exceeds the frame", ErrMalformed)
+		}
+		r := c.calls[f.stream]
+		if r == nil {
+			return nil, nil
+		}
+		stmts := c.messages(r, content)
+		if f.flags&flagEndStream != 0 {
+			delete(c.calls, f.stream)
+		}
+		return stmts, nil
+
+	case frameRSTStream:
+		delete(c.calls, f.stream)
+	}
+	return nil, nil
+}
+
+// headerBlock decodes a complete header block, and returns the call it
+// opens, if it opens one.
+//
+// Every block is decoded, trailers included, because each may change the
+// HPACK table the next one is read against.
+func (c *Codec) headerBlock(id uint32, block []byte, ends bool) ([]hoopinspect.Statement, error) {
+	fields, err := c.hpack.decode(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: stream %d: %w", ErrMalformed, id, err)
+	}
+	if id == 0 || id <= c.last {
+		// Trailers, or a client reusing a stream id, which the server
+		// refuses. Neither starts a call.
+		if ends {
+			delete(c.calls, id)
+		}
+		return nil, nil
+	}
+	c.last = id
+
+	r := c.request(id, fields)
+	if r.method != nil {
+		c.conn.open(id, r.method)
+		if !ends {
+			c.calls[id] = r
+		}
+	}
+	return []hoopinspect.Statement{r.statement()}, nil
+}
+
+// request is one call, as its request headers describe it.
+type request struct {
+	id       uint32
+	detail   hoopinspect.HTTPDetail
+	op       hoopinspect.Operation
+	service  string
+	name     string
+	encoding string
+
+	// method is the call's schema, nil when there are no descriptors or
+	// they do not define it. Only a call with a method has its messages
+	// read.
+	method *methodDesc
+
+	// buf holds a request message that has not fully arrived, and skip
+	// the bytes of an oversized one still to pass over.
+	buf  []byte
+	skip int
+	seq  int
+}
+
+func (c *Codec) request(id uint32, fields []headerField) *request {
+	r := &request{id: id}
+	for _, f := range fields {
+		switch f.name {
+		case ":method":
+			r.detail.Method = strings.ToUpper(f.value)
+		case ":path":
+			r.detail.Path = f.value
+		case ":authority":
+			r.detail.Host = f.value
+		case "host":
+			if r.detail.Host == "" {
+				r.detail.Host = f.value
+			}
+		case "content-type":
+			ct := f.value
+			if i := strings.IndexByte(ct, ';'); i >= 0 {
+				ct = ct[:i]
+			}
+			r.detail.ContentType = strings.ToLower(strings.TrimSpace(ct))
+		case "grpc-encoding":
+			r.encoding = strings.ToLower(f.value)
+		}
+		if !strings.HasPrefix(f.name, ":") && c.headers[f.name] {
+			if r.detail.Headers == nil {
+				r.detail.Headers = map[string]string{}
+			}
+			if prev, ok := r.detail.Headers[f.name]; ok {
+				r.detail.Headers[f.name] = prev + ", " + f.value
+			} else {
+				r.detail.Headers[f.name] = f.value
+			}
+		}
+	}
+	if i := strings.IndexByte(r.detail.Path, '?'); i >= 0 {
+		r.detail.Path = r.detail.Path[:i]
+	}
+	r.detail.Resource = r.detail.Path
+
+	r.op = hoopinspect.OpOther
+	if svc, name, ok := splitMethod(r.detail.Path); ok && isGRPC(r.detail.ContentType) {
+		r.service, r.name = svc, name
+		r.op = methodOperation(name)
+		r.method = c.opts.Descriptors.method(r.detail.Path)
+	}
+	return r
+}
+
+// statement is the call as its headers describe it. Each message the call
+// sends becomes a statement of its own; see message.
+func (r *request) statement() hoopinspect.Statement {
+	d := r.detail
+	s := hoopinspect.Statement{
+		Protocol:  hoopinspect.GRPC,
+		Direction: hoopinspect.FromClient,
+		Text:      d.Path,
+		Operation: r.op,
+		HTTP:      &d,
+		Metadata: map[string]string{
+			"http.proto":     "HTTP/2.0",
+			MetadataStreamID: strconv.FormatUint(uint64(r.id), 10),
+		},
+	}
+	if d.Resource != "" {
+		s.Tables = []string{strings.ToLower(d.Resource)}
+	}
+	if r.service != "" {
+		s.Metadata[MetadataService] = r.service
+		s.Metadata[MetadataMethod] = r.name
+	}
+	return s
+}
+
+// messages reads the length-prefixed messages in a call's DATA:
+//
+//	uint8  compressed flag
+//	uint32 length, big-endian
+//	       message
+func (c *Codec) messages(r *request, data []byte) []hoopinspect.Statement {
+	var stmts []hoopinspect.Statement
+	if r.skip > 0 {
+		k := min(r.skip, len(data))
+		data, r.skip = data[k:], r.skip-k
+	}
+	r.buf = append(r.buf, data...)
+
+	b := r.buf
+	for len(b) >= 5 {
+		n := binary.BigEndian.Uint32(b[1:5])
+		if uint64(n) > uint64(c.opts.MaxMessageBytes) {
+			r.seq++
+			stmts = append(stmts, r.unreadable(fmt.Sprintf(
+				"message of %d bytes exceeds the %d-byte limit", n, c.opts.MaxMessageBytes)))
+			k := min(int(n), len(b)-5)
+			b, r.skip = b[5+k:], int(n)-k
+			continue
+		}
+		if len(b) < 5+int(n) {
+			break
+		}
+		stmts = append(stmts, c.message(r, b[0], b[5:5+n]))
+		b = b[5+n:]
+	}
+	r.buf = append(r.buf[:0], b...)
+	return stmts
+}
+
+// message decodes one request message into a statement of its own.
+func (c *Codec) message(r *request, flag byte, body []byte) hoopinspect.Statement {
+	r.seq++
+	if flag&1 != 0 {
+		if r.encoding != "gzip" {
+			return r.unreadable(fmt.Sprintf("message compressed with %q, which the relay cannot read",
+				r.encoding))
+		}
+		var err error
+		if body, err = gunzip(body, c.opts.MaxMessageBytes); err != nil {
+			return r.unreadable(err.Error())
+		}
+	}
+	dm, err := decodeMessage(r.method.input, body)
+	if err != nil {
+		return r.unreadable(fmt.Sprintf("message does not decode as %s: %v", r.method.input.name, err))
+	}
+
+	s := r.statement()
+	s.Metadata[MetadataMessage] = strconv.Itoa(r.seq)
+
+	access, use := hoopinspect.AccessWrite, hoopinspect.ColumnWritten
+	if r.op == hoopinspect.OpSelect {
+		access, use = hoopinspect.AccessRead, hoopinspect.ColumnFiltered
+	}
+	s.Tables = nil
+	for _, typ := range dm.types {
+		rel := hoopinspect.Relation{Name: strings.ToLower(typ), Access: access}
+		for _, f := range dm.fields[typ] {
+			rel.Columns = append(rel.Columns, hoopinspect.ColumnRef{
+				Name: strings.ToLower(f),
+				Uses: []hoopinspect.ColumnUse{use},
+			})
+		}
+		s.Relations = append(s.Relations, rel)
+		s.Tables = append(s.Tables, rel.Name)
+	}
+	if len(s.Tables) == 0 {
+		// An empty message sets nothing, and still calls the method.
+		s.Tables = []string{strings.ToLower(r.detail.Resource)}
+	}
+	if c.opts.CaptureMessages {
+		s.HTTP.Body = dm.render()
+	}
+	return s
+}
+
+// unreadable is a message statement for a message that could not be read.
+func (r *request) unreadable(why string) hoopinspect.Statement {
+	s := r.statement()
+	s.Operation = hoopinspect.OpUnknown
+	s.Metadata[MetadataMessage] = strconv.Itoa(r.seq)
+	s.Metadata[hoopinspect.MetadataSQLIncomplete] = why
+	return s
+}
+
+// gunzip inflates a message, refusing one that inflates past limit: the
+// compressed size says nothing about the real one.
+func gunzip(b []byte, limit int) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("gzip message: %w", err)
+	}
+	out, err := io.ReadAll(io.LimitReader(zr, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("gzip message: %w", err)
+	}
+	if len(out) > limit {
+		return nil, fmt.Errorf("message inflates past the %d-byte limit", limit)
+	}
+	return out, nil
+}
+
+// decodeResponses reads the server's frames and forgets the calls they end.
+func (c *Codec) decodeResponses(data []byte) int {
+	c.conn.mu.Lock()
+	defer c.conn.mu.Unlock()
+	for id := range c.conn.ended {
+		delete(c.conn.methods, id)
+		delete(c.conn.ended, id)
+	}
+
+	pos := 0
+	for {
+		f, ok := readFrame(data[pos:])
+		if !ok {
+			return pos
+		}
+		pos += len(f.raw)
+		if f.typ == frameRSTStream ||
+			(f.typ == frameData || f.typ == frameHeaders) && f.flags&flagEndStream != 0 {
+			c.conn.ended[f.stream] = true
+		}
+	}
+}
+
+// splitMethod splits "/package.Service/Method".
+func splitMethod(path string) (service, method string, ok bool) {
+	rest, ok := strings.CutPrefix(path, "/")
+	if !ok {
+		return "", "", false
+	}
+	service, method, ok = strings.Cut(rest, "/")
+	if !ok || service == "" || method == "" || strings.Contains(method, "/") {
+		return "", "", false
+	}
+	return service, method, true
+}
+
+// isGRPC reports a gRPC content type: application/grpc, or a subtype such
+// as application/grpc+proto.
+func isGRPC(ct string) bool {
+	return ct == "application/grpc" || strings.HasPrefix(ct, "application/grpc+")
+}
+
+// methodVerbs maps a method name's leading word to the operation it
+// suggests, in the spirit of the API design guides most gRPC services follow.
+var methodVerbs = []struct {
+	verb string
+	op   hoopinspect.Operation
+}{
+	{"Get", hoopinspect.OpSelect},
+	{"List", hoopinspect.OpSelect},
+	{"Search", hoopinspect.OpSelect},
+	{"Watch", hoopinspect.OpSelect},
+	{"Query", hoopinspect.OpSelect},
+	{"Read", hoopinspect.OpSelect},
+	{"Find", hoopinspect.OpSelect},
+	{"Lookup", hoopinspect.OpSelect},
+	{"Check", hoopinspect.OpSelect},
+	{"Describe", hoopinspect.OpSelect},
+	{"Count", hoopinspect.OpSelect},
+	{"Create", hoopinspect.OpInsert},
+	{"Insert", hoopinspect.OpInsert},
+	{"Add", hoopinspect.OpInsert},
+	{"Update", hoopinspect.OpUpdate},
+	{"Patch", hoopinspect.OpUpdate},
+	{"Set", hoopinspect.OpUpdate},
+	{"Modify", hoopinspect.OpUpdate},
+	{"Replace", hoopinspect.OpUpdate},
+	{"Put", hoopinspect.OpUpdate},
+	{"Edit", hoopinspect.OpUpdate},
+	{"Delete", hoopinspect.OpDelete},
+	{"Remove", hoopinspect.OpDelete},
+	{"Destroy", hoopinspect.OpDelete},
+	{"Purge", hoopinspect.OpDelete},
+	{"Drop", hoopinspect.OpDrop},
+	{"Truncate", hoopinspect.OpTruncate},
+}
+
+// methodOperation guesses a method's effect from its leading word.
+// BatchDeleteUsers is a delete. The word must end where the verb does, so
+// Settle is not a Set and Getaway not a Get.
+func methodOperation(name string) hoopinspect.Operation {
+	if rest := name[len(wordPrefix(name, "Batch")):]; rest != "" {
+		name = rest
+	}
+	for _, v := range methodVerbs {
+		if wordPrefix(name, v.verb) != "" {
+			return v.op
+		}
+	}
+	return hoopinspect.OpCall
+}
+
+// wordPrefix returns the prefix of name matching verb, case-insensitively,
+// when a word boundary follows it, and "" otherwise.
+func wordPrefix(name, verb string) string {
+	if len(name) < len(verb) || !strings.EqualFold(name[:len(verb)], verb) {
+		return ""
+	}
+	if len(name) > len(verb) && unicode.IsLower(rune(name[len(verb)])) {
+		return ""
+	}
+	return name[:len(verb)]
+}
@@ -0,0 +1,321 @@
+package grpc_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+	hg "github.com/hoophq/hoopinspect/codec/grpc"
+	"github.com/hoophq/hoopinspect/policy"
+)
+
+// Protobuf, HPACK and HTTP/2 written out by hand, so the tests depend on
+// nothing but the RFCs and the protobuf encoding guide.
+
+func varint(b []byte, v uint64) []byte { return binary.AppendUvarint(b, v) }
+
+func bytesField(num int, v []byte) []byte {
+	b := varint(nil, uint64(num)<<3|2)
+	b = varint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func strField(num int, v string) []byte { return bytesField(num, []byte(v)) }
+
+func intField(num int, v uint64) []byte {
+	return varint(varint(nil, uint64(num)<<3), v)
+}
+
+func join(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+// field is a FieldDescriptorProto: name, number, label, type, type_name.
+func field(name string, num int, kind uint64, typeName string) []byte {
+	b := join(strField(1, name), intField(3, uint64(num)), intField(4, 1), intField(5, kind))
+	if typeName != "" {
+		b = append(b, strField(6, typeName)...)
+	}
+	return b
+}
+
+func message(name string, fields ...[]byte) []byte {
+	b := strField(1, name)
+	for _, f := range fields {
+		b = append(b, bytesField(2, f)...)
+	}
+	return b
+}
+
+func method(name, in, out string) []byte {
+	return join(strField(1, name), strField(2, in), strField(3, out))
+}
+
+// descriptorSet describes
+//
+//	package acme.v1;
+//	message User { string name = 1; string ssn = 2; int64 id = 3; }
+//	message GetUserRequest { int64 id = 1; }
+//	message CreateUserRequest { User user = 1; }
+//	service Users {
+//	  rpc GetUser(GetUserRequest) returns (User);
+//	  rpc CreateUser(CreateUserRequest) returns (User);
+//	}
+func descriptorSet() []byte {
+	const (
+		tInt64   = 3
+		tString  = 9
+		tMessage = 11
+	)
+	file := join(
+		strField(1, "acme/v1/users.proto"),
+		strField(2, "acme.v1"),
+		bytesField(4, message("User",
+			field("name", 1, tString, ""),
+			field("ssn", 2, tString, ""),
+			field("id", 3, tInt64, ""))),
+		bytesField(4, message("GetUserRequest", field("id", 1, tInt64, ""))),
+		bytesField(4, message("CreateUserRequest", field("user", 1, tMessage, ".acme.v1.User"))),
+		bytesField(6, join(
+			strField(1, "Users"),
+			bytesField(2, method("GetUser", ".acme.v1.GetUserRequest", ".acme.v1.User")),
+			bytesField(2, method("CreateUser", ".acme.v1.CreateUserRequest", ".acme.v1.User")))),
+	)
+	return bytesField(1, file)
+}
+
+func descriptors(t *testing.T) *hg.Descriptors {
+	t.Helper()
+	d, err := hg.ParseDescriptorSet(descriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func frame(typ, flags byte, stream uint32, payload []byte) []byte {
+	n := len(payload)
+	b := []byte{byte(n >> 16), byte(n >> 8), byte(n), typ, flags}
+	b = binary.BigEndian.AppendUint32(b, stream)
+	return append(b, payload...)
+}
+
+// headerBlock encodes every field as a literal without indexing, with raw
+// strings, which any decoder reads without touching its table.
+func headerBlock(fields ...string) []byte {
+	var b []byte
+	for i := 0; i < len(fields); i += 2 {
+		b = append(b, 0x00, byte(len(fields[i])))
+		b = append(b, fields[i]...)
+		b = append(b, byte(len(fields[i+1])))
+		b = append(b, fields[i+1]...)
+	}
+	return b
+}
+
+func call(stream uint32, path string, extra ...string) []byte {
+	fields := append([]string{
+		":method", "POST", ":scheme", "http", ":path", path,
+		":authority", "users.internal", "content-type", "application/grpc",
+	}, extra...)
+	return frame(0x1, 0x4, stream, headerBlock(fields...))
+}
+
+func grpcMessage(b []byte) []byte {
+	return append(binary.BigEndian.AppendUint32([]byte{0}, uint32(len(b))), b...)
+}
+
+const preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+func opening() []byte { return append([]byte(preface), frame(0x4, 0, 0, nil)...) }
+
+func inspect(t *testing.T, c *hg.Codec, chunks ...[]byte) []hoopinspect.Statement {
+	t.Helper()
+	insp := hoopinspect.NewWithCodec(c)
+	var out []hoopinspect.Statement
+	for _, ch := range chunks {
+		stmts, err := insp.Inspect(hoopinspect.FromClient, ch)
+		if err != nil {
+			t.Fatalf("Inspect: %v", err)
+		}
+		out = append(out, stmts...)
+	}
+	return out
+}
+
+func TestMethodIsResource(t *testing.T) {
+	stmts := inspect(t, hg.New(hg.Options{Headers: []string{"X-Tenant"}}),
+		opening(), call(1, "/acme.admin.v1.AdminService/DeleteTenant", "x-tenant", "t1", "authorization", "secret"))
+	if len(stmts) != 1 {
+		t.Fatalf("got %d statements, want 1", len(stmts))
+	}
+	s := stmts[0]
+	if s.Protocol != hoopinspect.GRPC || s.Operation != hoopinspect.OpDelete {
+		t.Errorf("Protocol, Operation = %q, %q; want grpc, delete", s.Protocol, s.Operation)
+	}
+	if s.HTTP == nil || s.HTTP.Resource != "/acme.admin.v1.AdminService/DeleteTenant" || s.HTTP.Host != "users.internal" {
+		t.Fatalf("HTTP = %+v", s.HTTP)
+	}
+	if len(s.HTTP.Headers) != 1 || s.HTTP.Headers["x-tenant"] != "t1" {
+		t.Errorf("Headers = %v, want only the allowlisted one", s.HTTP.Headers)
+	}
+	if s.Metadata[hg.MetadataService] != "acme.admin.v1.AdminService" ||
+		s.Metadata[hg.MetadataMethod] != "DeleteTenant" || s.Metadata[hg.MetadataStreamID] != "1" {
+		t.Errorf("Metadata = %v", s.Metadata)
+	}
+
+	rules, err := policy.NewRules([]policy.Rule{
+		policy.Rule{Name: "no-admin", Type: policy.MatchHTTPResource}.
+			WithResources("/acme.admin.v1.AdminService/**"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := rules.Evaluate(s); !v.Denied {
+		t.Error("an http_resource rule did not match the method path")
+	}
+}
+
+func TestMethodOperations(t *testing.T) {
+	for name, want := range map[string]hoopinspect.Operation{
+		"GetUser":          hoopinspect.OpSelect,
+		"ListUsers":        hoopinspect.OpSelect,
+		"CreateUser":       hoopinspect.OpInsert,
+		"updateUser":       hoopinspect.OpUpdate,
+		"BatchDeleteUsers": hoopinspect.OpDelete,
+		"DropTable":        hoopinspect.OpDrop,
+		"Settle":           hoopinspect.OpCall,
+		"Getaway":          hoopinspect.OpCall,
+		"Batch":            hoopinspect.OpCall,
+	} {
+		stmts := inspect(t, hg.New(hg.Options{}), opening(), call(1, "/acme.v1.Users/"+name))
+		if len(stmts) != 1 || stmts[0].Operation != want {
+			t.Errorf("%s: %v, want %q", name, stmts, want)
+		}
+	}
+}
+
+// RFC 7541 C.4: Huffman-coded strings, and a second request reading its
+// :authority from the dynamic table the first one filled.
+func TestHPACKDynamicTable(t *testing.T) {
+	first, _ := hex.DecodeString("828684418cf1e3c2e5f23a6ba0ab90f4ff")
+	second, _ := hex.DecodeString("828684be5886a8eb10649cbf")
+	stmts := inspect(t, hg.New(hg.Options{Headers: []string{"cache-control"}}),
+		opening(), frame(0x1, 0x5, 1, first), frame(0x1, 0x5, 3, second))
+	if len(stmts) != 2 {
+		t.Fatalf("got %d statements, want 2", len(stmts))
+	}
+	s := stmts[1]
+	if s.HTTP.Host != "www.example.com" || s.HTTP.Headers["cache-control"] != "no-cache" {
+		t.Errorf("second request: %+v", s.HTTP)
+	}
+	// Not gRPC: the path is still a resource, and nothing is guessed.
+	if s.Operation != hoopinspect.OpOther || s.Metadata[hg.MetadataMethod] != "" {
+		t.Errorf("Operation = %q, Metadata = %v", s.Operation, s.Metadata)
+	}
+}
+
+func TestContinuationFrames(t *testing.T) {
+	block := headerBlock(":method", "POST", ":path", "/acme.v1.Users/GetUser", "content-type", "application/grpc")
+	stmts := inspect(t, hg.New(hg.Options{}), opening(),
+		frame(0x1, 0x0, 1, block[:10]), frame(0x9, 0x0, 1, block[10:20]), frame(0x9, 0x4, 1, block[20:]))
+	if len(stmts) != 1 || stmts[0].HTTP.Path != "/acme.v1.Users/GetUser" {
+		t.Errorf("statements = %v", stmts)
+	}
+}
+
+// Each request message is a statement whose relations are the message types
+// it sets fields of, so a column rule reaches a field inside a nested message.
+func TestMessagesBecomeRelations(t *testing.T) {
+	msg := grpcMessage(bytesField(1, join(strField(1, "ada"), strField(2, "123-45-6789"))))
+	c := hg.New(hg.Options{Descriptors: descriptors(t), CaptureMessages: true})
+	stmts := inspect(t, c, opening(), call(1, "/acme.v1.Users/CreateUser"),
+		frame(0x0, 0, 1, msg[:9]), frame(0x0, 0x1, 1, msg[9:]))
+	if len(stmts) != 2 {
+		t.Fatalf("got %d statements, want the call and its message", len(stmts))
+	}
+	s := stmts[1]
+	if s.Operation != hoopinspect.OpInsert || s.Metadata[hg.MetadataMessage] != "1" {
+		t.Errorf("Operation = %q, Metadata = %v", s.Operation, s.Metadata)
+	}
+	if got := strings.Join(s.Tables, ","); got != "acme.v1.createuserrequest,acme.v1.user" {
+		t.Errorf("Tables = %q", got)
+	}
+	var ssn bool
+	for _, r := range s.Relations {
+		for _, col := range r.Columns {
+			if r.Name == "acme.v1.user" && col.Name == "ssn" && col.Has(hoopinspect.ColumnWritten) {
+				ssn = r.Access == hoopinspect.AccessWrite
+			}
+		}
+	}
+	if !ssn {
+		t.Errorf("Relations = %+v, want acme.v1.user.ssn written", s.Relations)
+	}
+	if s.HTTP.Body != `{"user":{"name":"ada","ssn":"123-45-6789"}}` {
+		t.Errorf("Body = %s", s.HTTP.Body)
+	}
+
+	rules, err := policy.NewRules([]policy.Rule{
+		{Name: "no-ssn", Type: policy.MatchColumn, Columns: []string{"user.ssn"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := rules.Evaluate(s); v.Rule != "no-ssn" {
+		t.Errorf("verdict = %+v, want the column rule", v)
+	}
+}
+
+func TestUnreadableMessagesFailClosed(t *testing.T) {
+	opts := hg.Options{Descriptors: descriptors(t), MaxMessageBytes: 64}
+	compressed := append([]byte{1, 0, 0, 0, 2}, 0xde, 0xad)
+	for name, data := range map[string][]byte{
+		"oversized":  grpcMessage(strField(1, strings.Repeat("x", 100))),
+		"compressed": compressed,
+		"malformed":  grpcMessage([]byte{0x0a, 0x05, 'a'}),
+	} {
+		stmts := inspect(t, hg.New(opts), opening(),
+			call(1, "/acme.v1.Users/CreateUser", "grpc-encoding", "snappy"), frame(0x0, 0x1, 1, data))
+		if len(stmts) != 2 {
+			t.Fatalf("%s: got %d statements", name, len(stmts))
+		}
+		s := stmts[1]
+		if s.Operation != hoopinspect.OpUnknown || s.Metadata[hoopinspect.MetadataSQLIncomplete] == "" {
+			t.Errorf("%s: Operation = %q, Metadata = %v; want unknown", name, s.Operation, s.Metadata)
+		}
+	}
+}
+
+// HTTP/2 cannot be resynchronized, so anything the codec cannot follow is a
+// stream the relay must not forward.
+func TestUnreadableConnectionIsUnsafe(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"tls":     {0x16, 0x03, 0x01, 0x02, 0x00, 0x01},
+		"http1":   []byte("POST /acme.v1.Users/GetUser HTTP/1.1\r\nUpgrade: h2c\r\n\r\n"),
+		"hpack":   append(opening(), frame(0x1, 0x4, 1, []byte{0xff, 0x7f})...),
+		"badcont": append(opening(), frame(0x9, 0x4, 1, nil)...),
+	} {
+		_, err := hoopinspect.NewWithCodec(hg.New(hg.Options{})).Inspect(hoopinspect.FromClient, data)
+		if !errors.Is(err, hoopinspect.ErrStreamUnsafe) {
+			t.Errorf("%s: err = %v, want ErrStreamUnsafe", name, err)
+		}
+	}
+}
+
+func TestParseDescriptorSetRefusesHoles(t *testing.T) {
+	file := join(
+		strField(2, "acme.v1"),
+		bytesField(4, message("Req", field("who", 1, 11, ".google.protobuf.Empty"))),
+		bytesField(6, join(strField(1, "S"), bytesField(2, method("M", ".acme.v1.Req", ".acme.v1.Req")))),
+	)
+	_, err := hg.ParseDescriptorSet(bytesField(1, file))
+	if err == nil || !strings.Contains(err.Error(), "--include_imports") {
+		t.Errorf("err = %v, want a missing import named", err)
+	}
+	if _, err := hg.ParseDescriptorSet(nil); err == nil {
+		t.Error("an empty set was accepted")
+	}
+}
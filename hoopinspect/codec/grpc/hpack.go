@@ -0,0 +1,314 @@
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// This file decodes HPACK (RFC 7541), the header compression HTTP/2 puts on
+// every HEADERS frame.
+//
+// Only the client's half is decoded. A request's method lives in its
+// :path, which only a decoded header block gives up; nothing a server sends
+// in its headers changes what a rule decides, and its DATA frames are found
+// by stream id alone.
+//
+// The decoder is stateful across the connection, which is why it cannot be
+// skipped even for a request nobody will judge: every header block may add
+// to the dynamic table, and a block decoded out of turn (or not at all)
+// leaves every later one reading the wrong entries.
+
+// errHPACK is a header block that does not decode. The connection cannot be
+// read past it.
+var errHPACK = errors.New("hoopinspect/grpc: malformed header block")
+
+// maxHeaderTableSize bounds the dynamic table an encoder may ask for. The
+// peer's limit comes from a SETTINGS frame travelling the other way, which
+// the request half never reads; this is well above any server default, so a
+// real encoder never meets it, and it keeps a hostile one from sizing the
+// table to the whole buffer.
+const maxHeaderTableSize = 1 << 20
+
+type headerField struct {
+	name, value string
+}
+
+// size is the entry's size as RFC 7541 section 4.1 counts it.
+func (f headerField) size() int { return len(f.name) + len(f.value) + 32 }
+
+// hpackDecoder holds one direction's dynamic table.
+type hpackDecoder struct {
+	// dynamic is newest first, the order HPACK indexes it in.
+	dynamic []headerField
+	size    int
+	maxSize int
+}
+
+func newHPACKDecoder() *hpackDecoder {
+	return &hpackDecoder{maxSize: 4096} // SETTINGS_HEADER_TABLE_SIZE's default
+}
+
+// decode reads one complete header block.
+func (d *hpackDecoder) decode(block []byte) ([]headerField, error) {
+	var out []headerField
+	for len(block) > 0 {
+		b := block[0]
+		switch {
+		case b&0x80 != 0: // indexed field
+			idx, rest, err := readInt(block, 7)
+			if err != nil {
+				return nil, err
+			}
+			f, err := d.at(idx)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, f)
+			block = rest
+
+		case b&0xc0 == 0x40: // literal, added to the table
+			f, rest, err := d.literal(block, 6)
+			if err != nil {
+				return nil, err
+			}
+			d.add(f)
+			out = append(out, f)
+			block = rest
+
+		case b&0xe0 == 0x20: // dynamic table size update
+			n, rest, err := readInt(block, 5)
+			if err != nil {
+				return nil, err
+			}
+			if n > maxHeaderTableSize {
+				return nil, fmt.Errorf("%w: table size %d", errHPACK, n)
+			}
+			d.maxSize = int(n)
+			d.evict()
+			block = rest
+
+		default: // literal without indexing (0000) or never indexed (0001)
+			f, rest, err := d.literal(block, 4)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, f)
+			block = rest
+		}
+	}
+	return out, nil
+}
+
+func (d *hpackDecoder) literal(block []byte, prefix uint8) (headerField, []byte, error) {
+	idx, rest, err := readInt(block, prefix)
+	if err != nil {
+		return headerField{}, nil, err
+	}
+	var f headerField
+	if idx > 0 {
+		named, err := d.at(idx)
+		if err != nil {
+			return headerField{}, nil, err
+		}
+		f.name = named.name
+	} else if f.name, rest, err = readString(rest); err != nil {
+		return headerField{}, nil, err
+	}
+	if f.value, rest, err = readString(rest); err != nil {
+		return headerField{}, nil, err
+	}
+	return f, rest, nil
+}
+
+func (d *hpackDecoder) at(idx uint64) (headerField, error) {
+	switch {
+	case idx == 0:
+	case idx <= uint64(len(staticTable)):
+		return staticTable[idx-1], nil
+	case idx-uint64(len(staticTable)) <= uint64(len(d.dynamic)):
+		return d.dynamic[idx-uint64(len(staticTable))-1], nil
+	}
+	return headerField{}, fmt.Errorf("%w: index %d", errHPACK, idx)
+}
+
+func (d *hpackDecoder) add(f headerField) {
+	d.dynamic = append([]headerField{f}, d.dynamic...)
+	d.size += f.size()
+	d.evict()
+}
+
+// evict drops the oldest entries until the table fits. An entry larger
+// than the whole table empties it, as section 4.4 requires.
+func (d *hpackDecoder) evict() {
+	for d.size > d.maxSize && len(d.dynamic) > 0 {
+		last := len(d.dynamic) - 1
+		d.size -= d.dynamic[last].size()
+		d.dynamic = d.dynamic[:last]
+	}
+}
+
+// readInt reads an HPACK integer with an n-bit prefix (section 5.1).
+func readInt(b []byte, n uint8) (uint64, []byte, error) {
+	if len(b) == 0 {
+		return 0, nil, errHPACK
+	}
+	limit := uint64(1)<<n - 1
+	v := uint64(b[0]) & limit
+	b = b[1:]
+	if v < limit {
+		return v, b, nil
+	}
+	for shift := uint(0); ; shift += 7 {
+		if len(b) == 0 || shift > 28 {
+			// A header integer past 2^35 is no table index or length
+			// anyone sends.
+			return 0, nil, errHPACK
+		}
+		c := b[0]
+		b = b[1:]
+		v += uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v, b, nil
+		}
+	}
+}
+
+// readString reads a string literal, Huffman-coded or raw (section 5.2).
+func readString(b []byte) (string, []byte, error) {
+	if len(b) == 0 {
+		return "", nil, errHPACK
+	}
+	huffman := b[0]&0x80 != 0
+	n, rest, err := readInt(b, 7)
+	if err != nil {
+		return "", nil, err
+	}
+	if n > uint64(len(rest)) {
+		return "", nil, errHPACK
+	}
+	raw := rest[:n]
+	if !huffman {
+		return string(raw), rest[n:], nil
+	}
+	s, err := huffmanDecode(raw)
+	if err != nil {
+		return "", nil, err
+	}
+	return s, rest[n:], nil
+}
+
+// huffmanNode is one branch of the decoding tree built from huffmanCodes.
+type huffmanNode struct {
+	next [2]*huffmanNode
+	sym  int // -1 on an inner node
+}
+
+var huffmanRoot = func() *huffmanNode {
+	root := &huffmanNode{sym: -1}
+	for sym, c := range huffmanCodes {
+		n := root
+		for i := int(c.bits) - 1; i >= 0; i-- {
+			bit := (c.code >> uint(i)) & 1
+			if n.next[bit] == nil {
+				n.next[bit] = &huffmanNode{sym: -1}
+			}
+			n = n.next[bit]
+		}
+		n.sym = sym
+	}
+	return root
+}()
+
+// huffmanDecode decodes a Huffman-coded string. The padding after the last
+// symbol must be a prefix of EOS, all 1 bits and shorter than a byte; any
+// other tail is an encoding error the RFC tells a decoder to refuse.
+func huffmanDecode(b []byte) (string, error) {
+	var out strings.Builder
+	n := huffmanRoot
+	pending, ones := 0, true
+	for _, c := range b {
+		for i := 7; i >= 0; i-- {
+			bit := (c >> uint(i)) & 1
+			n = n.next[bit]
+			if n == nil {
+				return "", fmt.Errorf("%w: bad Huffman code", errHPACK)
+			}
+			pending++
+			ones = ones && bit == 1
+			if n.sym >= 0 {
+				out.WriteByte(byte(n.sym))
+				n, pending, ones = huffmanRoot, 0, true
+			}
+		}
+	}
+	if pending > 7 || !ones {
+		return "", fmt.Errorf("%w: bad Huffman padding", errHPACK)
+	}
+	return out.String(), nil
+}
+
+// staticTable is RFC 7541 Appendix A.
+var staticTable = []headerField{
+	{":authority", ""},
+	{":method", "GET"},
+	{":method", "POST"},
+	{":path", "/"},
+	{":path", "/index.html"},
+	{":scheme", "http"},
+	{":scheme", "https"},
+	{":status", "200"},
+	{":status", "204"},
+	{":status", "206"},
+	{":status", "304"},
+	{":status", "400"},
+	{":status", "404"},
+	{":status", "500"},
+	{"accept-charset", ""},
+	{"accept-encoding", "gzip, deflate"},
+	{"accept-language", ""},
+	{"accept-ranges", ""},
+	{"accept", ""},
+	{"access-control-allow-origin", ""},
+	{"age", ""},
+	{"allow", ""},
+	{"authorization", ""},
+	{"cache-control", ""},
+	{"content-disposition", ""},
+	{"content-encoding", ""},
+	{"content-language", ""},
+	{"content-length", ""},
+	{"content-location", ""},
+	{"content-range", ""},
+	{"content-type", ""},
+	{"cookie", ""},
+	{"date", ""},
+	{"etag", ""},
+	{"expect", ""},
+	{"expires", ""},
+	{"from", ""},
+	{"host", ""},
+	{"if-match", ""},
+	{"if-modified-since", ""},
+	{"if-none-match", ""},
+	{"if-range", ""},
+	{"if-unmodified-since", ""},
+	{"last-modified", ""},
+	{"link", ""},
+	{"location", ""},
+	{"max-forwards", ""},
+	{"proxy-authenticate", ""},
+	{"proxy-authorization", ""},
+	{"range", ""},
+	{"referer", ""},
+	{"refresh", ""},
+	{"retry-after", ""},
+	{"server", ""},
+	{"set-cookie", ""},
+	{"strict-transport-security", ""},
+	{"transfer-encoding", ""},
+	{"user-agent", ""},
+	{"vary", ""},
+	{"via", ""},
+	{"www-authenticate", ""},
+}
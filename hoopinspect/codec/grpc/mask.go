@@ -0,0 +1,498 @@
+package grpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// CellMasker rewrites one response field's value. column is the field's name
+// as the schema declares it. Declared as an alias, as in the SQL codecs, so
+// this codec satisfies gate.Reframer structurally.
+type CellMasker = func(column string, value []byte) []byte
+
+// maxHeldBytes bounds the response bytes the rewriter holds back, across the
+// whole connection, while a value spans frames.
+//
+// It has to stay under the client's flow-control window, 65,535 bytes until
+// the client raises it. The server stops sending when the window is spent and
+// the client only reopens it for DATA it has received, so holding a window's
+// worth waits for a frame that cannot come.
+const maxHeldBytes = 48 << 10
+
+// Rewrite masks the string and bytes fields of response messages in place.
+//
+// # Same length
+//
+// Every gRPC message declares its length in its prefix, every protobuf field
+// and nested message in its own, and every DATA frame in its header; the
+// client also counts DATA against its flow-control windows, which the server
+// computed from the sizes it sent. Re-framing all of that on the fly is not
+// possible once a message's first frame has gone, so a masked value is
+// fitted to the length of the original: cut on a character boundary, or
+// padded with '*'. [REDACTED:EMAIL_ADDRESS] in place of a 15-byte address
+// arrives as "[REDACTED:EMAIL". Nothing the client or the server counted
+// changes.
+//
+// # Holding
+//
+// A value is masked whole, and a value may straddle DATA frames, so a frame
+// holding part of one waits until the rest arrives. Only that call waits:
+// DATA of other calls overtakes it. Any other frame keeps its place behind
+// the frames held before it, since header blocks share one HPACK table and
+// must reach the client in the order they were encoded.
+//
+// # Giving up
+//
+// A call is reset when its response cannot be masked: a compressed message,
+// bytes that do not decode as the schema's type, or a value spanning more
+// than maxHeldBytes. The client receives RST_STREAM(INTERNAL_ERROR) for the
+// call, and the call's remaining DATA is zeroed rather than dropped, so the
+// flow-control windows on both sides still agree. Rewrite reports it as an
+// error, which the gate records.
+//
+// A call whose method the descriptors do not define, and every call on a
+// codec built without descriptors, passes through unread.
+func (c *Codec) Rewrite(data []byte, mask CellMasker) ([]byte, hoopinspect.ReframeResult, error) {
+	r := &c.resp
+	if (mask == nil || c.opts.Descriptors == nil) && len(r.held) == 0 && len(r.partial) == 0 {
+		return data, hoopinspect.ReframeResult{}, nil
+	}
+	if len(r.partial) > 0 {
+		data = append(r.partial, data...)
+		r.partial = nil
+	}
+
+	var (
+		out  []byte
+		res  hoopinspect.ReframeResult
+		errs []error
+	)
+	for {
+		f, ok := readFrame(data)
+		if !ok {
+			break
+		}
+		data = data[len(f.raw):]
+		h := &heldFrame{raw: bytes.Clone(f.raw), typ: f.typ, stream: f.stream}
+		r.held = append(r.held, h)
+		r.heldBytes += len(h.raw)
+		if err := c.responseFrame(h, f, mask, &res, &out); err != nil {
+			errs = append(errs, err)
+		}
+		out = r.release(out)
+	}
+	if r.heldBytes > maxHeldBytes {
+		for _, id := range r.spanning() {
+			errs = append(errs, r.giveUp(id, fmt.Errorf("a value spans more than %d bytes of frames",
+				maxHeldBytes), &out))
+		}
+		out = r.release(out)
+	}
+	r.partial = bytes.Clone(data)
+
+	var err error
+	if len(errs) > 0 {
+		err = errs[0]
+	}
+	return out, res, err
+}
+
+// Flush releases every held frame. A value still waiting for the rest of
+// itself is zeroed: half a value cannot be masked, and the connection is
+// closing. A partial frame is dropped, since no client can read it.
+func (c *Codec) Flush(mask CellMasker) []byte {
+	r := &c.resp
+	for _, id := range r.spanning() {
+		r.streams[id].zeroValue()
+	}
+	var out []byte
+	for _, h := range r.held {
+		out = append(out, h.raw...)
+	}
+	r.held, r.heldBytes, r.partial = nil, 0, nil
+	return out
+}
+
+// responseHalf is the rewriter's state for one connection.
+type responseHalf struct {
+	partial   []byte // a frame not yet complete
+	held      []*heldFrame
+	heldBytes int
+	streams   map[uint32]*walker
+}
+
+// heldFrame is a frame the rewriter has read and not yet released.
+type heldFrame struct {
+	raw    []byte
+	typ    uint8
+	stream uint32
+
+	// open reports that part of a value not yet complete lies in this
+	// frame, so it cannot go until the value has been masked.
+	open bool
+}
+
+func (c *Codec) responseFrame(h *heldFrame, f frame, mask CellMasker, res *hoopinspect.ReframeResult, out *[]byte) error {
+	r := &c.resp
+	if f.stream == 0 {
+		return nil
+	}
+	ends := f.typ == frameRSTStream ||
+		(f.typ == frameData || f.typ == frameHeaders) && f.flags&flagEndStream != 0
+	defer func() {
+		if ends {
+			if w := r.streams[f.stream]; w != nil {
+				w.zeroValue()
+			}
+			delete(r.streams, f.stream)
+		}
+	}()
+	if f.typ != frameData {
+		return nil
+	}
+
+	w := r.streams[f.stream]
+	if w == nil {
+		w = &walker{}
+		if m := c.conn.method(f.stream); m != nil && c.opts.Descriptors != nil {
+			w.out = m.output
+		}
+		if r.streams == nil {
+			r.streams = map[uint32]*walker{}
+		}
+		r.streams[f.stream] = w
+	}
+	_, off, ok := f.content()
+	switch {
+	case w.gone:
+		h.zero(off, ok)
+		return nil
+	case w.out == nil || mask == nil:
+		return nil
+	case !ok:
+		return r.giveUp(f.stream, fmt.Errorf("%w: DATA padding exceeds the frame", ErrMalformed), out)
+	}
+	end := len(h.raw)
+	if f.flags&flagPadded != 0 {
+		end -= int(f.payload[0])
+	}
+	if err := w.feed(h, frameHeaderLen+off, end, mask, res); err != nil {
+		return r.giveUp(f.stream, err, out)
+	}
+	return nil
+}
+
+// release moves every frame that may go into out, in order, and keeps the
+// rest.
+func (r *responseHalf) release(out []byte) []byte {
+	var (
+		keep    = r.held[:0]
+		blocked = map[uint32]bool{}
+		barrier bool
+	)
+	for _, h := range r.held {
+		if h.open || blocked[h.stream] || (h.typ != frameData && barrier) {
+			keep = append(keep, h)
+			if h.stream != 0 {
+				blocked[h.stream] = true
+			}
+			if h.typ != frameData {
+				barrier = true
+			}
+			continue
+		}
+		out = append(out, h.raw...)
+		r.heldBytes -= len(h.raw)
+	}
+	clear(r.held[len(keep):])
+	r.held = keep
+	return out
+}
+
+// spanning lists the calls with a value waiting for its next frame.
+func (r *responseHalf) spanning() []uint32 {
+	var ids []uint32
+	for id, w := range r.streams {
+		if len(w.segs) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// giveUp resets a call whose response cannot be masked.
+func (r *responseHalf) giveUp(id uint32, cause error, out *[]byte) error {
+	w := r.streams[id]
+	if w == nil || w.gone {
+		return nil
+	}
+	*out = appendFrame(*out, frameRSTStream, 0, id, []byte{0, 0, 0, 2}) // INTERNAL_ERROR
+	w.gone = true
+	w.segs, w.value = nil, nil
+	for _, h := range r.held {
+		if h.stream == id && h.typ == frameData {
+			f, _ := readFrame(h.raw)
+			_, off, ok := f.content()
+			h.zero(off, ok)
+			h.open = false
+		}
+	}
+	return fmt.Errorf("hoopinspect/grpc: stream %d reset, its response could not be masked: %w", id, cause)
+}
+
+// zero blanks a DATA frame's content, keeping its length. off is where the
+// content starts in the payload; without a readable padding length the whole
+// payload goes.
+func (h *heldFrame) zero(off int, ok bool) {
+	p := h.raw[frameHeaderLen:]
+	if ok && h.raw[4]&flagPadded != 0 {
+		p = p[:len(p)-int(p[0])]
+	}
+	if ok {
+		p = p[off:]
+	}
+	clear(p)
+}
+
+// walker reads one call's response messages as their bytes arrive, frame by
+// frame, without waiting for a whole message.
+type walker struct {
+	out  *messageDesc // the response type; nil passes the call through
+	gone bool         // given up: every later DATA frame is zeroed
+
+	prefix  []byte // the message prefix read so far
+	inMsg   bool
+	pos     int          // bytes of the current message read
+	end     int          // where the innermost message being read ends
+	msg     *messageDesc // its type
+	stack   []level      // the messages enclosing it
+	changed bool         // a value in this message was masked
+
+	state int
+	vint  uint64 // a varint being read
+	shift uint
+	field *fieldDesc
+	skip  int // bytes left of the field being skipped or read
+
+	// value is a string or bytes field being read, and segs where its
+	// bytes lie in held frames.
+	value []byte
+	segs  []segment
+}
+
+type level struct {
+	msg *messageDesc
+	end int
+}
+
+type segment struct {
+	h      *heldFrame
+	off, n int
+}
+
+const (
+	stKey = iota
+	stVarint
+	stLen
+	stSkip
+	stValue
+)
+
+// feed reads h.raw[from:to], the content of one DATA frame.
+func (w *walker) feed(h *heldFrame, from, to int, mask CellMasker, res *hoopinspect.ReframeResult) error {
+	b := h.raw[:to]
+	i := from
+	for i < len(b) {
+		if !w.inMsg {
+			k := min(5-len(w.prefix), len(b)-i)
+			w.prefix = append(w.prefix, b[i:i+k]...)
+			i += k
+			if len(w.prefix) < 5 {
+				return nil
+			}
+			if w.prefix[0] != 0 {
+				return fmt.Errorf("the message is compressed")
+			}
+			n := binary.BigEndian.Uint32(w.prefix[1:])
+			w.prefix = w.prefix[:0]
+			w.inMsg, w.pos, w.end, w.msg, w.stack = true, 0, int(n), w.out, w.stack[:0]
+			w.state, w.changed = stKey, false
+			w.pop(res)
+			continue
+		}
+
+		switch w.state {
+		case stKey, stVarint, stLen:
+			c := b[i]
+			i++
+			w.pos++
+			if w.shift > 63 {
+				return errProto
+			}
+			w.vint |= uint64(c&0x7f) << w.shift
+			w.shift += 7
+			if c&0x80 != 0 {
+				if w.pos >= w.end {
+					return errProto
+				}
+				continue
+			}
+			v := w.vint
+			w.vint, w.shift = 0, 0
+			var err error
+			switch w.state {
+			case stKey:
+				err = w.key(v)
+			case stLen:
+				err = w.length(v)
+			default:
+				w.state = stKey
+			}
+			if err != nil {
+				return err
+			}
+
+		case stSkip:
+			k := min(w.skip, len(b)-i)
+			i, w.pos, w.skip = i+k, w.pos+k, w.skip-k
+			if w.skip == 0 {
+				w.state = stKey
+			}
+
+		case stValue:
+			k := min(w.skip, len(b)-i)
+			w.value = append(w.value, b[i:i+k]...)
+			w.segs = append(w.segs, segment{h: h, off: i, n: k})
+			h.open = true
+			i, w.pos, w.skip = i+k, w.pos+k, w.skip-k
+			if w.skip == 0 {
+				w.maskValue(mask, res)
+				w.state = stKey
+			}
+		}
+		w.pop(res)
+	}
+	return nil
+}
+
+// key starts a field.
+func (w *walker) key(v uint64) error {
+	num, wt := v>>3, int(v&7)
+	if num == 0 {
+		return errProto
+	}
+	w.field = nil
+	if w.msg != nil {
+		w.field = w.msg.fields[num]
+	}
+	switch wt {
+	case wireVarint:
+		w.state = stVarint
+	case wireFixed64:
+		w.skip, w.state = 8, stSkip
+	case wireFixed32:
+		w.skip, w.state = 4, stSkip
+	case wireBytes:
+		w.state = stLen
+	default:
+		return fmt.Errorf("%w: wire type %d", errProto, wt)
+	}
+	if w.state == stSkip && w.skip > w.end-w.pos {
+		return errProto
+	}
+	return nil
+}
+
+// length starts a length-delimited field's contents.
+func (w *walker) length(l uint64) error {
+	if l > uint64(w.end-w.pos) {
+		return errProto
+	}
+	n, f := int(l), w.field
+	switch {
+	case n == 0:
+		w.state = stKey
+	case f != nil && f.kind == kindMessage:
+		if len(w.stack) >= maxMessageDepth {
+			return fmt.Errorf("%w: nested deeper than %d", errProto, maxMessageDepth)
+		}
+		w.stack = append(w.stack, level{msg: w.msg, end: w.end})
+		w.msg, w.end, w.state = f.message, w.pos+n, stKey
+	case f != nil && (f.kind == kindString || f.kind == kindBytes):
+		w.skip, w.state = n, stValue
+	default:
+		w.skip, w.state = n, stSkip
+	}
+	return nil
+}
+
+// pop closes every message that has ended.
+func (w *walker) pop(res *hoopinspect.ReframeResult) {
+	for w.inMsg && w.state == stKey && w.pos == w.end {
+		if len(w.stack) == 0 {
+			w.inMsg = false
+			if w.changed {
+				res.Rows++
+			}
+			return
+		}
+		top := w.stack[len(w.stack)-1]
+		w.stack = w.stack[:len(w.stack)-1]
+		w.msg, w.end = top.msg, top.end
+	}
+}
+
+// maskValue masks a completed value into the frames it lies in.
+func (w *walker) maskValue(mask CellMasker, res *hoopinspect.ReframeResult) {
+	masked := mask(w.field.name, w.value)
+	if !bytes.Equal(masked, w.value) {
+		fitted := fit(masked, len(w.value))
+		j := 0
+		for _, s := range w.segs {
+			copy(s.h.raw[s.off:s.off+s.n], fitted[j:j+s.n])
+			j += s.n
+		}
+		res.Cells++
+		w.changed = true
+	}
+	w.release()
+}
+
+// zeroValue blanks a value that will never complete.
+func (w *walker) zeroValue() {
+	for _, s := range w.segs {
+		clear(s.h.raw[s.off : s.off+s.n])
+	}
+	w.release()
+}
+
+func (w *walker) release() {
+	for _, s := range w.segs {
+		s.h.open = false
+	}
+	w.segs, w.value = nil, nil
+}
+
+// fit makes b exactly n bytes: cut on a character boundary and padded with
+// '*'.
+func fit(b []byte, n int) []byte {
+	if len(b) > n {
+		cut := n
+		for cut > 0 && !utf8.RuneStart(b[cut]) {
+			cut--
+		}
+		b = b[:cut]
+	}
+	out := make([]byte, n)
+	copy(out, b)
+	for i := len(b); i < n; i++ {
+		out[i] = '*'
+	}
+	return out
+}
@@ -0,0 +1,126 @@
+package grpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+	hg "github.com/hoophq/hoopinspect/codec/grpc"
+	"github.com/hoophq/hoopinspect/gate"
+	"github.com/hoophq/hoopinspect/session"
+)
+
+// ssnMasker masks the ssn column and nothing else.
+type ssnMasker struct{}
+
+func (ssnMasker) Mask(data []byte) ([]byte, []string, int) { return data, nil, 0 }
+
+func (ssnMasker) MaskCell(column string, value []byte) ([]byte, []string, int) {
+	if column != "ssn" {
+		return value, nil, 0
+	}
+	return []byte("[REDACTED:SSN]"), []string{"SSN"}, 1
+}
+
+func grpcGate(t *testing.T, opts hg.Options) *gate.Gate {
+	t.Helper()
+	g, err := gate.New(session.New(hoopinspect.GRPC, session.Identity{}), gate.Config{
+		Masker:       ssnMasker{},
+		CodecFactory: func() hoopinspect.Codec { return hg.New(opts) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// frames splits a server stream back into frames.
+func frames(t *testing.T, b []byte) (out [][]byte) {
+	t.Helper()
+	for len(b) > 0 {
+		if len(b) < 9 {
+			t.Fatalf("%d trailing bytes", len(b))
+		}
+		n := 9 + (int(b[0])<<16 | int(b[1])<<8 | int(b[2]))
+		out, b = append(out, b[:n]), b[n:]
+	}
+	return out
+}
+
+// The response half decodes against the method the request half saw, which
+// the gate joins; a value split across two DATA frames is held until it is
+// whole, masked to its own length, and released in order.
+func TestResponseMaskedAcrossFrames(t *testing.T) {
+	ctx := context.Background()
+	g := grpcGate(t, hg.Options{Descriptors: descriptors(t)})
+	if d := g.Request(ctx, join(opening(), call(1, "/acme.v1.Users/GetUser"),
+		frame(0x0, 0x1, 1, grpcMessage(intField(1, 7))))); !d.Allowed {
+		t.Fatalf("request denied: %+v", d)
+	}
+
+	msg := grpcMessage(join(strField(1, "ada"), strField(2, "123-45-6789"), intField(3, 7)))
+	head := join(frame(0x4, 0, 0, nil), frame(0x1, 0x4, 1, []byte{0x88}))
+	data1 := frame(0x0, 0, 1, msg[:14]) // ends inside the ssn
+	data2 := frame(0x0, 0, 1, msg[14:])
+	trailers := frame(0x1, 0x5, 1, []byte{0x88})
+
+	d := g.Response(ctx, join(head, data1))
+	if !bytes.Equal(d.Payload, head) {
+		t.Fatalf("first payload = %x, want the frames before the split value", d.Payload)
+	}
+	d = g.Response(ctx, join(data2, trailers))
+	if d.MaskedCount != 1 {
+		t.Errorf("MaskedCount = %d, want 1", d.MaskedCount)
+	}
+
+	got := frames(t, d.Payload)
+	if len(got) != 3 || !bytes.Equal(got[2], trailers) {
+		t.Fatalf("second payload = %x", d.Payload)
+	}
+	body := append(append([]byte{}, got[0][9:]...), got[1][9:]...)
+	want := grpcMessage(join(strField(1, "ada"), strField(2, "[REDACTED:S"), intField(3, 7)))
+	if !bytes.Equal(body, want) {
+		t.Errorf("message = %q, want %q", body, want)
+	}
+	if len(got[0]) != len(data1) || len(got[1]) != len(data2) {
+		t.Error("a masked frame changed size")
+	}
+}
+
+// A call waiting on a split value holds only itself: another call's DATA
+// goes ahead of it.
+func TestHeldCallDoesNotBlockOthers(t *testing.T) {
+	ctx := context.Background()
+	g := grpcGate(t, hg.Options{Descriptors: descriptors(t)})
+	g.Request(ctx, join(opening(), call(1, "/acme.v1.Users/GetUser"), call(3, "/acme.v1.Users/GetUser")))
+
+	msg := grpcMessage(strField(2, "123-45-6789"))
+	other := frame(0x0, 0, 3, grpcMessage(strField(1, "bob")))
+	d := g.Response(ctx, join(frame(0x0, 0, 1, msg[:9]), other))
+	if !bytes.Equal(d.Payload, other) {
+		t.Errorf("payload = %x, want stream 3's frame alone", d.Payload)
+	}
+}
+
+// A compressed response cannot be masked to its own length. The call is
+// reset and its bytes zeroed, never forwarded as they were.
+func TestUnmaskableResponseResetsTheCall(t *testing.T) {
+	ctx := context.Background()
+	g := grpcGate(t, hg.Options{Descriptors: descriptors(t)})
+	g.Request(ctx, join(opening(), call(1, "/acme.v1.Users/GetUser")))
+
+	data := frame(0x0, 0, 1, append([]byte{1, 0, 0, 0, 3}, "ssn"...))
+	d := g.Response(ctx, data)
+	if d.Err == nil {
+		t.Error("no error reported")
+	}
+	got := frames(t, d.Payload)
+	if len(got) != 2 || got[0][3] != 0x3 || binary.BigEndian.Uint32(got[0][9:]) != 2 {
+		t.Fatalf("payload = %x, want RST_STREAM(INTERNAL_ERROR) first", d.Payload)
+	}
+	if len(got[1]) != len(data) || bytes.Contains(got[1], []byte("ssn")) {
+		t.Errorf("DATA = %x, want the same size, zeroed", got[1])
+	}
+}
@@ -0,0 +1,797 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// This file reads GraphQL out of HTTP requests.
+//
+// Every GraphQL request is `POST /graphql`, so for a GraphQL API the method
+// and the resource say nothing: a read and a mutation that drops a project
+// look the same to an http_resource rule. The document in the body says
+// which is which, and it is small enough to parse here without a dependency.
+//
+// # Mapping onto statements
+//
+// An operation becomes what a SQL statement becomes. A query or subscription
+// is OpSelect; a mutation is a write, classified by its root field's leading
+// verb (deleteProject is OpDelete, createProject OpInsert, anything else
+// OpUpdate), and Operation is the most consequential of them as on every
+// other lane. Root fields become Relations, lowercased, read for a query and
+// write for a mutation, so a table rule naming `deleteproject` or `users`
+// works on a GraphQL lane as it does on a SQL one. A root field's own
+// sub-selections become its projected columns, so a column rule on
+// `users.ssn` refuses `{ users { ssn } }`. A mutation's arguments are what it
+// writes and cannot be attributed, so a mutation writes AllColumns.
+//
+// Tables follows Relations, and the resource no longer appears there: a
+// table rule written against `/graphql` stops matching. http_resource rules
+// read HTTPDetail.Resource and are unaffected.
+//
+// # Failing closed
+//
+// A request that is GraphQL and cannot be read comes back as OpUnknown with
+// the reason in Metadata["sql.incomplete"], the key every codec uses, so the
+// one rule naming `unknown` refuses it. That covers a document that does not
+// parse, a body cut at MaxBodyBytes, and a persisted query: a client sending
+// only a hash asks the server to run a document the relay has never seen, and
+// unless Options.PersistedQueries knows the hash, the relay cannot say what
+// it does.
+//
+// A request is GraphQL when the path's last segment is "graphql", when the
+// Content-Type is application/graphql, or when the body is shaped like a
+// GraphQL request: an object (or a batch of them) whose "query" is a
+// document. The last is deliberately loose. A search API whose "query" field
+// reads `mutation tests` is taken for GraphQL and refused as unreadable, which
+// is the right side to err on.
+
+// Metadata keys a GraphQL request sets.
+const (
+	// MetadataGraphQLOperation is the operation type, or the types of a
+	// batch joined by commas: "query", "mutation", "subscription".
+	MetadataGraphQLOperation = "graphql.operation"
+
+	// MetadataGraphQLOperationName is the operation the client named.
+	MetadataGraphQLOperationName = "graphql.operation_name"
+)
+
+// graphqlRequest is one GraphQL request as a client sends it.
+//
+// Query is a pointer because a persisted query omits it, and that has to be
+// told apart from a client that sent an empty one.
+type graphqlRequest struct {
+	Query         *string `json:"query"`
+	OperationName string  `json:"operationName"`
+	Extensions    struct {
+		PersistedQuery *struct {
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+
+	// ID and DocumentID are the persisted-document spellings that are not
+	// Apollo's.
+	ID         string `json:"id"`
+	DocumentID string `json:"documentId"`
+}
+
+// persistedID is the hash or id the request names instead of a document.
+func (q graphqlRequest) persistedID() string {
+	switch {
+	case q.Extensions.PersistedQuery != nil && q.Extensions.PersistedQuery.SHA256Hash != "":
+		return q.Extensions.PersistedQuery.SHA256Hash
+	case q.DocumentID != "":
+		return q.DocumentID
+	}
+	return q.ID
+}
+
+// graphqlAnalysis is what a GraphQL request does, in Statement terms.
+type graphqlAnalysis struct {
+	effects    []hoopinspect.Operation
+	relations  []hoopinspect.Relation
+	types      []string
+	names      []string
+	incomplete string
+}
+
+// graphql reports whether the request is GraphQL and, if so, what it does.
+func (i *Inspector) graphql(r *http.Request, d *hoopinspect.HTTPDetail, b drained) (graphqlAnalysis, bool) {
+	endpoint := strings.EqualFold(path.Base(d.Path), "graphql") ||
+		d.ContentType == "application/graphql"
+
+	var reqs []graphqlRequest
+	switch {
+	case d.Method == "GET":
+		q := r.URL.Query()
+		if !q.Has("query") && !q.Has("extensions") && !q.Has("id") && !q.Has("documentId") {
+			return graphqlAnalysis{}, false
+		}
+		gr := graphqlRequest{OperationName: q.Get("operationName"), ID: q.Get("id"), DocumentID: q.Get("documentId")}
+		if q.Has("query") {
+			s := q.Get("query")
+			gr.Query = &s
+		}
+		if ext := q.Get("extensions"); ext != "" {
+			if err := json.Unmarshal([]byte(ext), &gr.Extensions); err != nil && endpoint {
+				return graphqlAnalysis{incomplete: "graphql: extensions is not JSON"}, true
+			}
+		}
+		reqs = []graphqlRequest{gr}
+
+	case d.ContentType == "application/graphql":
+		if b.truncated {
+			return graphqlAnalysis{incomplete: "graphql: document exceeds the body limit"}, true
+		}
+		doc := string(b.data)
+		reqs = []graphqlRequest{{Query: &doc}}
+
+	default:
+		body := bytes.TrimSpace(b.data)
+		if len(body) == 0 || (body[0] != '{' && body[0] != '[') {
+			if endpoint && len(body) > 0 {
+				return graphqlAnalysis{incomplete: "graphql: body is not a GraphQL request"}, true
+			}
+			return graphqlAnalysis{}, false
+		}
+		if b.truncated {
+			// A cut body cannot be decoded, and might hide a mutation past
+			// the cut. Only a body that says it is GraphQL is refused.
+			if endpoint || bytes.Contains(body, []byte(`"query"`)) {
+				return graphqlAnalysis{incomplete: "graphql: request exceeds the body limit"}, true
+			}
+			return graphqlAnalysis{}, false
+		}
+		var err error
+		if body[0] == '[' {
+			err = json.Unmarshal(body, &reqs)
+		} else {
+			var one graphqlRequest
+			err = json.Unmarshal(body, &one)
+			reqs = []graphqlRequest{one}
+		}
+		if err != nil {
+			if endpoint {
+				return graphqlAnalysis{incomplete: "graphql: body is not a GraphQL request"}, true
+			}
+			return graphqlAnalysis{}, false
+		}
+		if !endpoint && !looksLikeGraphQL(reqs) {
+			return graphqlAnalysis{}, false
+		}
+	}
+
+	var a graphqlAnalysis
+	if len(reqs) == 0 {
+		a.incomplete = "graphql: empty batch"
+	}
+	for _, gr := range reqs {
+		doc, err := i.document(gr)
+		if err == nil {
+			err = a.add(doc, gr.OperationName)
+		}
+		if err != nil {
+			a.incomplete = err.Error()
+			break
+		}
+	}
+	return a, true
+}
+
+// looksLikeGraphQL reports whether every request in a body nobody declared as
+// GraphQL carries a document or a persisted id in the places GraphQL puts
+// them.
+func looksLikeGraphQL(reqs []graphqlRequest) bool {
+	if len(reqs) == 0 {
+		return false
+	}
+	for _, gr := range reqs {
+		if gr.Query == nil {
+			if gr.Extensions.PersistedQuery == nil {
+				return false
+			}
+			continue
+		}
+		toks, err := lexGraphQL(*gr.Query)
+		if err != nil || len(toks) == 0 {
+			// A "query" that does not even lex is some other API's
+			// search string.
+			return false
+		}
+		switch t := toks[0]; {
+		case t.kind == gqlPunct && t.val == "{":
+		case t.kind == gqlName && (t.val == "query" || t.val == "mutation" ||
+			t.val == "subscription" || t.val == "fragment"):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// document returns the request's parsed document, looking a persisted one up
+// when the request sends only its id.
+func (i *Inspector) document(gr graphqlRequest) (gqlDocument, error) {
+	src := ""
+	switch {
+	case gr.Query != nil && *gr.Query != "":
+		src = *gr.Query
+	case gr.persistedID() != "":
+		id := gr.persistedID()
+		known, ok := i.opts.PersistedQueries[id]
+		if !ok {
+			return gqlDocument{}, fmt.Errorf("graphql: persisted query %q is not known to the relay", id)
+		}
+		src = known
+	default:
+		return gqlDocument{}, errors.New("graphql: request carries no document")
+	}
+	return parseGraphQL(src)
+}
+
+// add folds the operation a request runs into the analysis. With no
+// operation name the document must hold one operation, or the server refuses
+// it; every operation counts then, so a refusal here never depends on which
+// one the server would have picked.
+func (a *graphqlAnalysis) add(doc gqlDocument, name string) error {
+	ops := doc.ops
+	if name != "" {
+		ops = nil
+		for _, op := range doc.ops {
+			if op.name == name {
+				ops = append(ops, op)
+			}
+		}
+		if len(ops) == 0 {
+			return fmt.Errorf("graphql: operation %q is not in the document", name)
+		}
+		a.names = appendOnce(a.names, name)
+	}
+	if len(ops) == 0 {
+		return errors.New("graphql: document has no operation")
+	}
+	for _, op := range ops {
+		a.types = appendOnce(a.types, op.kind)
+		roots, err := doc.expand(op.sel, nil)
+		if err != nil {
+			return err
+		}
+		for _, f := range roots {
+			if f.name == "__typename" {
+				continue
+			}
+			acc := hoopinspect.AccessRead
+			effect := hoopinspect.OpSelect
+			if op.kind == "mutation" {
+				acc, effect = hoopinspect.AccessWrite, mutationEffect(f.name)
+			}
+			a.effects = append(a.effects, effect)
+			cols, err := doc.columns(f, acc)
+			if err != nil {
+				return err
+			}
+			a.relate(strings.ToLower(f.name), acc, cols)
+		}
+	}
+	return nil
+}
+
+// relate records a relation, merging a repeat the way the SQL codecs do:
+// write dominates, and columns are the union.
+func (a *graphqlAnalysis) relate(name string, acc hoopinspect.Access, cols []hoopinspect.ColumnRef) {
+	for i := range a.relations {
+		r := &a.relations[i]
+		if r.Name != name {
+			continue
+		}
+		if acc == hoopinspect.AccessWrite {
+			r.Access = acc
+		}
+		for _, c := range cols {
+			merged := false
+			for j := range r.Columns {
+				if r.Columns[j].Name == c.Name {
+					for _, u := range c.Uses {
+						if !r.Columns[j].Has(u) {
+							r.Columns[j].Uses = append(r.Columns[j].Uses, u)
+						}
+					}
+					merged = true
+				}
+			}
+			if !merged {
+				r.Columns = append(r.Columns, c)
+			}
+		}
+		return
+	}
+	a.relations = append(a.relations, hoopinspect.Relation{Name: name, Access: acc, Columns: cols})
+}
+
+// columns lists a root field's sub-selections as projected columns, and for a
+// mutation adds AllColumns written.
+func (doc gqlDocument) columns(f gqlSelection, acc hoopinspect.Access) ([]hoopinspect.ColumnRef, error) {
+	var cols []hoopinspect.ColumnRef
+	if acc == hoopinspect.AccessWrite {
+		cols = append(cols, hoopinspect.ColumnRef{
+			Name: hoopinspect.AllColumns, Uses: []hoopinspect.ColumnUse{hoopinspect.ColumnWritten},
+		})
+	}
+	children, err := doc.expand(f.children, nil)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, c := range children {
+		name := strings.ToLower(c.name)
+		if name == "__typename" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		cols = append(cols, hoopinspect.ColumnRef{
+			Name: name, Uses: []hoopinspect.ColumnUse{hoopinspect.ColumnProjected},
+		})
+	}
+	return cols, nil
+}
+
+// mutationEffect classifies a mutation root field by its leading verb. What a
+// resolver does is not visible from here, so the names carry it; a field
+// whose name says nothing is an update, the least specific write.
+func mutationEffect(field string) hoopinspect.Operation {
+	lower := strings.ToLower(field)
+	for _, v := range []struct {
+		prefix string
+		op     hoopinspect.Operation
+	}{
+		{"delete", hoopinspect.OpDelete}, {"remove", hoopinspect.OpDelete},
+		{"destroy", hoopinspect.OpDelete}, {"purge", hoopinspect.OpDelete},
+		{"drop", hoopinspect.OpDrop}, {"truncate", hoopinspect.OpTruncate},
+		{"create", hoopinspect.OpInsert}, {"insert", hoopinspect.OpInsert},
+		{"add", hoopinspect.OpInsert},
+	} {
+		if strings.HasPrefix(lower, v.prefix) {
+			return v.op
+		}
+	}
+	return hoopinspect.OpUpdate
+}
+
+// graphqlSeverity orders operations the way the lexer does, so Operation
+// means the same thing on a GraphQL request as on a SQL statement.
+func graphqlSeverity(op hoopinspect.Operation) int {
+	switch op {
+	case hoopinspect.OpDrop, hoopinspect.OpTruncate:
+		return 6
+	case hoopinspect.OpDelete:
+		return 5
+	case hoopinspect.OpUpdate:
+		return 3
+	case hoopinspect.OpInsert:
+		return 2
+	case hoopinspect.OpSelect:
+		return 1
+	}
+	return 0
+}
+
+// apply writes the analysis onto a request statement.
+func (a graphqlAnalysis) apply(s *hoopinspect.Statement) {
+	if len(a.types) > 0 {
+		s.Metadata[MetadataGraphQLOperation] = strings.Join(a.types, ",")
+	}
+	if len(a.names) > 0 {
+		s.Metadata[MetadataGraphQLOperationName] = strings.Join(a.names, ",")
+	}
+	if a.incomplete != "" {
+		s.Operation = hoopinspect.OpUnknown
+		s.Metadata[hoopinspect.MetadataSQLIncomplete] = a.incomplete
+		return
+	}
+	s.Effects = a.effects
+	s.Relations = a.relations
+	s.Tables = nil
+	for _, r := range a.relations {
+		s.Tables = append(s.Tables, r.Name)
+	}
+	best := -1
+	for _, e := range a.effects {
+		if r := graphqlSeverity(e); r > best {
+			best, s.Operation = r, e
+		}
+	}
+}
+
+func appendOnce(dst []string, s string) []string {
+	for _, have := range dst {
+		if have == s {
+			return dst
+		}
+	}
+	return append(dst, s)
+}
+
+// --- parsing ----------------------------------------------------------------
+
+// gqlDocument is an executable GraphQL document, reduced to what policy
+// reads: each operation's selections, and the fragments they spread.
+type gqlDocument struct {
+	ops       []gqlOperation
+	fragments map[string][]gqlSelection
+}
+
+type gqlOperation struct {
+	kind, name string
+	sel        []gqlSelection
+}
+
+// gqlSelection is a field, or a spread of the named fragment. An inline
+// fragment has no node of its own: its selections are lifted into the
+// enclosing set, since policy does not care which type they were
+// conditioned on.
+type gqlSelection struct {
+	name     string
+	spread   string
+	children []gqlSelection
+}
+
+// maxGraphQLDepth bounds selection nesting and fragment expansion, so a
+// hostile document cannot exhaust the stack.
+const maxGraphQLDepth = 128
+
+// expand resolves fragment spreads in a selection set, returning its fields.
+func (doc gqlDocument) expand(sel []gqlSelection, seen []string) ([]gqlSelection, error) {
+	if len(seen) > maxGraphQLDepth {
+		return nil, errors.New("graphql: fragments nest too deeply")
+	}
+	var out []gqlSelection
+	for _, s := range sel {
+		if s.spread == "" {
+			out = append(out, s)
+			continue
+		}
+		for _, name := range seen {
+			if name == s.spread {
+				return nil, fmt.Errorf("graphql: fragment %q spreads itself", s.spread)
+			}
+		}
+		frag, ok := doc.fragments[s.spread]
+		if !ok {
+			return nil, fmt.Errorf("graphql: fragment %q is not defined", s.spread)
+		}
+		fields, err := doc.expand(frag, append(seen, s.spread))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, fields...)
+	}
+	return out, nil
+}
+
+// ValidateGraphQL reports whether src is a GraphQL document this package can
+// read. A persisted query the relay is given is checked with it at load, so a
+// typo in one fails the configuration instead of every request that names it.
+func ValidateGraphQL(src string) error {
+	doc, err := parseGraphQL(src)
+	if err != nil {
+		return err
+	}
+	for _, op := range doc.ops {
+		if _, err := doc.expand(op.sel, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseGraphQL parses an executable GraphQL document: operations and
+// fragments. A document carrying type-system definitions is refused, since a
+// server executes none of it and a request sending one is not a request.
+//
+// Arguments, variables and directives are skipped rather than parsed. Their
+// values decide what a resolver does with a field, never which field runs,
+// and a policy question about them is a question for the body.
+func parseGraphQL(src string) (gqlDocument, error) {
+	toks, err := lexGraphQL(src)
+	if err != nil {
+		return gqlDocument{}, err
+	}
+	p := &gqlParser{toks: toks}
+	doc := gqlDocument{fragments: map[string][]gqlSelection{}}
+	for !p.done() {
+		t := p.peek()
+		switch {
+		case t.is(gqlPunct, "{"):
+			sel, err := p.selectionSet(0)
+			if err != nil {
+				return gqlDocument{}, err
+			}
+			doc.ops = append(doc.ops, gqlOperation{kind: "query", sel: sel})
+
+		case t.kind == gqlName && (t.val == "query" || t.val == "mutation" || t.val == "subscription"):
+			p.pos++
+			op := gqlOperation{kind: t.val}
+			if p.peek().kind == gqlName {
+				op.name = p.next().val
+			}
+			if p.peek().is(gqlPunct, "(") {
+				if err := p.skipBalanced(); err != nil {
+					return gqlDocument{}, err
+				}
+			}
+			if err := p.directives(); err != nil {
+				return gqlDocument{}, err
+			}
+			if op.sel, err = p.selectionSet(0); err != nil {
+				return gqlDocument{}, err
+			}
+			doc.ops = append(doc.ops, op)
+
+		case t.is(gqlName, "fragment"):
+			p.pos++
+			name := p.next()
+			if name.kind != gqlName || !p.next().is(gqlName, "on") || p.next().kind != gqlName {
+				return gqlDocument{}, errors.New("graphql: malformed fragment definition")
+			}
+			if err := p.directives(); err != nil {
+				return gqlDocument{}, err
+			}
+			sel, err := p.selectionSet(0)
+			if err != nil {
+				return gqlDocument{}, err
+			}
+			doc.fragments[name.val] = sel
+
+		default:
+			return gqlDocument{}, fmt.Errorf("graphql: unexpected %q where a definition starts", t.val)
+		}
+	}
+	if len(doc.ops) == 0 {
+		return gqlDocument{}, errors.New("graphql: document has no operation")
+	}
+	return doc, nil
+}
+
+type gqlParser struct {
+	toks []gqlToken
+	pos  int
+}
+
+func (p *gqlParser) done() bool { return p.pos >= len(p.toks) }
+
+func (p *gqlParser) peek() gqlToken {
+	if p.done() {
+		return gqlToken{}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *gqlParser) next() gqlToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *gqlParser) selectionSet(depth int) ([]gqlSelection, error) {
+	if depth > maxGraphQLDepth {
+		return nil, errors.New("graphql: selections nest too deeply")
+	}
+	if !p.next().is(gqlPunct, "{") {
+		return nil, errors.New("graphql: expected a selection set")
+	}
+	var out []gqlSelection
+	for {
+		t := p.peek()
+		switch {
+		case p.done():
+			return nil, errors.New("graphql: unterminated selection set")
+		case t.is(gqlPunct, "}"):
+			p.pos++
+			if len(out) == 0 {
+				return nil, errors.New("graphql: empty selection set")
+			}
+			return out, nil
+
+		case t.is(gqlPunct, "..."):
+			p.pos++
+			if n := p.peek(); n.kind == gqlName && n.val != "on" {
+				p.pos++
+				if err := p.directives(); err != nil {
+					return nil, err
+				}
+				out = append(out, gqlSelection{spread: n.val})
+				continue
+			}
+			if p.peek().is(gqlName, "on") {
+				p.pos++
+				if p.next().kind != gqlName {
+					return nil, errors.New("graphql: inline fragment without a type")
+				}
+			}
+			if err := p.directives(); err != nil {
+				return nil, err
+			}
+			inner, err := p.selectionSet(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, inner...)
+
+		case t.kind == gqlName:
+			p.pos++
+			f := gqlSelection{name: t.val}
+			if p.peek().is(gqlPunct, ":") {
+				p.pos++
+				n := p.next()
+				if n.kind != gqlName {
+					return nil, errors.New("graphql: alias without a field")
+				}
+				f.name = n.val
+			}
+			if p.peek().is(gqlPunct, "(") {
+				if err := p.skipBalanced(); err != nil {
+					return nil, err
+				}
+			}
+			if err := p.directives(); err != nil {
+				return nil, err
+			}
+			if p.peek().is(gqlPunct, "{") {
+				children, err := p.selectionSet(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				f.children = children
+			}
+			out = append(out, f)
+
+		default:
+			return nil, fmt.Errorf("graphql: unexpected %q in a selection set", t.val)
+		}
+	}
+}
+
+func (p *gqlParser) directives() error {
+	for p.peek().is(gqlPunct, "@") {
+		p.pos++
+		if p.next().kind != gqlName {
+			return errors.New("graphql: directive without a name")
+		}
+		if p.peek().is(gqlPunct, "(") {
+			if err := p.skipBalanced(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// skipBalanced skips a parenthesized group, brackets and braces inside it
+// included. Strings are single tokens, so a brace inside one cannot unbalance
+// it.
+func (p *gqlParser) skipBalanced() error {
+	var stack []string
+	closer := map[string]string{"(": ")", "[": "]", "{": "}"}
+	for !p.done() {
+		t := p.next()
+		if t.kind != gqlPunct {
+			continue
+		}
+		if c, open := closer[t.val]; open {
+			if len(stack) > maxGraphQLDepth {
+				return errors.New("graphql: arguments nest too deeply")
+			}
+			stack = append(stack, c)
+			continue
+		}
+		if len(stack) > 0 && t.val == stack[len(stack)-1] {
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return nil
+			}
+			continue
+		}
+		if t.val == ")" || t.val == "]" || t.val == "}" {
+			return fmt.Errorf("graphql: unbalanced %q", t.val)
+		}
+	}
+	return errors.New("graphql: unterminated arguments")
+}
+
+// --- lexing -----------------------------------------------------------------
+
+type gqlKind uint8
+
+const (
+	gqlPunct gqlKind = iota + 1
+	gqlName
+	gqlValue // a string or a number; policy never reads either
+)
+
+type gqlToken struct {
+	kind gqlKind
+	val  string
+}
+
+func (t gqlToken) is(k gqlKind, v string) bool { return t.kind == k && t.val == v }
+
+// lexGraphQL splits a document into tokens. Whitespace, commas and comments
+// are insignificant in GraphQL and dropped.
+func lexGraphQL(src string) ([]gqlToken, error) {
+	var toks []gqlToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case strings.HasPrefix(src[i:], "\ufeff"):
+			i += len("\ufeff")
+		case c == '#':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			toks = append(toks, gqlToken{gqlPunct, "..."})
+			i += 3
+		case strings.IndexByte("!$&()[]{}:=@|", c) >= 0:
+			toks = append(toks, gqlToken{gqlPunct, string(c)})
+			i++
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' ||
+				src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, gqlToken{gqlName, src[i:j]})
+			i = j
+		case c == '-' || c >= '0' && c <= '9':
+			j := i + 1
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' ||
+				src[j] == 'e' || src[j] == 'E' || src[j] == '+' || src[j] == '-') {
+				j++
+			}
+			toks = append(toks, gqlToken{gqlValue, src[i:j]})
+			i = j
+		case strings.HasPrefix(src[i:], `"""`):
+			j := i + 3
+			for {
+				k := strings.Index(src[j:], `"""`)
+				if k < 0 {
+					return nil, errors.New("graphql: unterminated block string")
+				}
+				j += k
+				if src[j-1] == '\\' {
+					j += 3 // \""" is an escaped delimiter
+					continue
+				}
+				break
+			}
+			toks = append(toks, gqlToken{gqlValue, src[i : j+3]})
+			i = j + 3
+		case c == '"':
+			j := i + 1
+			for ; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\\' {
+					j++
+				} else if src[j] == '\n' || src[j] == '\r' {
+					return nil, errors.New("graphql: line break in a string")
+				}
+			}
+			if j >= len(src) {
+				return nil, errors.New("graphql: unterminated string")
+			}
+			toks = append(toks, gqlToken{gqlValue, src[i : j+1]})
+			i = j + 1
+		default:
+			return nil, fmt.Errorf("graphql: unexpected character %q", rune(c))
+		}
+	}
+	return toks, nil
+}
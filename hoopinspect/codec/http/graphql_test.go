@@ -0,0 +1,254 @@
+package http_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+	hi "github.com/hoophq/hoopinspect/codec/http"
+	"github.com/hoophq/hoopinspect/policy"
+)
+
+func gqlBody(t *testing.T, query, operationName string) string {
+	t.Helper()
+	b, err := json.Marshal(map[string]string{"query": query, "operationName": operationName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func inspectGraphQL(t *testing.T, opts hi.Options, body string) hoopinspect.Statement {
+	t.Helper()
+	return hi.New(opts).InspectRequest(req(t, "POST", "/graphql", body), []byte(body))
+}
+
+func relation(s hoopinspect.Statement, name string) *hoopinspect.Relation {
+	for i := range s.Relations {
+		if s.Relations[i].Name == name {
+			return &s.Relations[i]
+		}
+	}
+	return nil
+}
+
+func column(r *hoopinspect.Relation, name string) *hoopinspect.ColumnRef {
+	for i := range r.Columns {
+		if r.Columns[i].Name == name {
+			return &r.Columns[i]
+		}
+	}
+	return nil
+}
+
+func TestGraphQLQueryIsASelect(t *testing.T) {
+	s := inspectGraphQL(t, hi.Options{}, gqlBody(t, `
+		# comments and commas are not tokens
+		query Orders($id: ID!, $first: Int = 10) @cached(ttl: 60) {
+			user(id: $id) { name, ssn }
+			o: orders(first: $first, filter: {status: "open}", tags: ["a", "b"]}) {
+				edges { node { id total } }
+			}
+			__typename
+		}`, ""))
+
+	if s.Operation != hoopinspect.OpSelect {
+		t.Fatalf("Operation = %q, want select; metadata %v", s.Operation, s.Metadata)
+	}
+	if got := strings.Join(s.Tables, ","); got != "user,orders" {
+		t.Errorf("Tables = %q, want the root fields, aliases resolved", got)
+	}
+	user := relation(s, "user")
+	if user == nil || user.Access != hoopinspect.AccessRead {
+		t.Fatalf("user relation = %+v, want a read", user)
+	}
+	ssn := column(user, "ssn")
+	if ssn == nil || !ssn.Has(hoopinspect.ColumnProjected) {
+		t.Errorf("user columns = %+v, want ssn projected", user.Columns)
+	}
+	if s.Metadata[hi.MetadataGraphQLOperation] != "query" {
+		t.Errorf("graphql.operation = %q", s.Metadata[hi.MetadataGraphQLOperation])
+	}
+	if s.HTTP.Resource != "/graphql" {
+		t.Errorf("Resource = %q, want the path kept for http_resource rules", s.HTTP.Resource)
+	}
+}
+
+func TestGraphQLMutationEffects(t *testing.T) {
+	s := inspectGraphQL(t, hi.Options{}, gqlBody(t, `mutation {
+		renameProject(id: 1, name: "x") { id }
+		deleteProject(id: 2) { id }
+	}`, ""))
+
+	if s.Operation != hoopinspect.OpDelete {
+		t.Errorf("Operation = %q, want the worst effect, delete", s.Operation)
+	}
+	if len(s.Effects) != 2 || s.Effects[0] != hoopinspect.OpUpdate || s.Effects[1] != hoopinspect.OpDelete {
+		t.Errorf("Effects = %v, want [update delete]", s.Effects)
+	}
+	r := relation(s, "deleteproject")
+	if r == nil || r.Access != hoopinspect.AccessWrite {
+		t.Fatalf("deleteproject relation = %+v, want a write", r)
+	}
+	if c := column(r, hoopinspect.AllColumns); c == nil || !c.Has(hoopinspect.ColumnWritten) {
+		t.Errorf("columns = %+v, want every column written", r.Columns)
+	}
+}
+
+// Fragments, named and inline, contribute their fields to the selection they
+// are spread into: hiding ssn in a fragment must not hide it from a rule.
+func TestGraphQLFragmentsAreExpanded(t *testing.T) {
+	s := inspectGraphQL(t, hi.Options{}, gqlBody(t, `
+		query { user(id: 1) { ...Sensitive ... on Admin { role } } }
+		fragment Sensitive on User { ssn }`, ""))
+
+	user := relation(s, "user")
+	if user == nil || column(user, "ssn") == nil || column(user, "role") == nil {
+		t.Fatalf("user relation = %+v, want ssn and role from the fragments", user)
+	}
+}
+
+func TestGraphQLOperationName(t *testing.T) {
+	doc := `query Read { users { id } } mutation Wipe { dropUsers }`
+
+	if s := inspectGraphQL(t, hi.Options{}, gqlBody(t, doc, "Read")); s.Operation != hoopinspect.OpSelect {
+		t.Errorf("named read: Operation = %q, want select", s.Operation)
+	}
+	// Without a name every operation counts, so the verdict cannot depend
+	// on which one the server would have run.
+	if s := inspectGraphQL(t, hi.Options{}, gqlBody(t, doc, "")); s.Operation != hoopinspect.OpDrop {
+		t.Errorf("unnamed: Operation = %q, want drop", s.Operation)
+	}
+	s := inspectGraphQL(t, hi.Options{}, gqlBody(t, doc, "Missing"))
+	if s.Operation != hoopinspect.OpUnknown || s.Metadata[hoopinspect.MetadataSQLIncomplete] == "" {
+		t.Errorf("missing name: Operation = %q, metadata %v; want unknown", s.Operation, s.Metadata)
+	}
+}
+
+func TestGraphQLBatch(t *testing.T) {
+	body := fmt.Sprintf("[%s,%s]",
+		gqlBody(t, `{ users { id } }`, ""),
+		gqlBody(t, `mutation { createUser(name: "x") { id } }`, ""))
+	s := inspectGraphQL(t, hi.Options{}, body)
+	if s.Operation != hoopinspect.OpInsert || len(s.Relations) != 2 {
+		t.Errorf("Operation = %q, Relations = %+v; want insert over both", s.Operation, s.Relations)
+	}
+	if s.Metadata[hi.MetadataGraphQLOperation] != "query,mutation" {
+		t.Errorf("graphql.operation = %q", s.Metadata[hi.MetadataGraphQLOperation])
+	}
+}
+
+func TestGraphQLOverGETAndRawBody(t *testing.T) {
+	insp := hi.New(hi.Options{})
+	q := url.Values{"query": {`{ users { email } }`}}
+	s := insp.InspectRequest(req(t, "GET", "/api/graphql?"+q.Encode(), ""), nil)
+	if s.Operation != hoopinspect.OpSelect || relation(s, "users") == nil {
+		t.Errorf("GET: Operation = %q, Relations = %+v", s.Operation, s.Relations)
+	}
+
+	body := `mutation { removeUser(id: 1) }`
+	r := req(t, "POST", "/v1/query", body)
+	r.Header.Set("Content-Type", "application/graphql")
+	if s := insp.InspectRequest(r, []byte(body)); s.Operation != hoopinspect.OpDelete {
+		t.Errorf("application/graphql: Operation = %q, want delete", s.Operation)
+	}
+}
+
+// A persisted query sends a hash in place of the document. Unless the relay
+// has been told what it stands for, it could be anything, and is refused.
+func TestGraphQLPersistedQueries(t *testing.T) {
+	body := `{"operationName":"Wipe","extensions":{"persistedQuery":{"version":1,"sha256Hash":"abc123"}}}`
+
+	s := inspectGraphQL(t, hi.Options{}, body)
+	if s.Operation != hoopinspect.OpUnknown {
+		t.Fatalf("unknown hash: Operation = %q, want unknown", s.Operation)
+	}
+	if got := s.Metadata[hoopinspect.MetadataSQLIncomplete]; !strings.Contains(got, "abc123") {
+		t.Errorf("sql.incomplete = %q, want the hash named", got)
+	}
+
+	known := hi.Options{PersistedQueries: map[string]string{"abc123": `mutation Wipe { deleteEverything }`}}
+	if s := inspectGraphQL(t, known, body); s.Operation != hoopinspect.OpDelete {
+		t.Errorf("known hash: Operation = %q, want delete", s.Operation)
+	}
+}
+
+func TestGraphQLUnreadableFailsClosed(t *testing.T) {
+	for name, body := range map[string]string{
+		"syntax":      gqlBody(t, `query { users { id }`, ""),
+		"no document": `{"variables": {}}`,
+		"not json":    `{"query": `,
+		"cycle":       gqlBody(t, `{ ...A } fragment A on Q { ...A }`, ""),
+		"undefined":   gqlBody(t, `{ ...Nope }`, ""),
+	} {
+		s := inspectGraphQL(t, hi.Options{}, body)
+		if s.Operation != hoopinspect.OpUnknown || s.Metadata[hoopinspect.MetadataSQLIncomplete] == "" {
+			t.Errorf("%s: Operation = %q, metadata %v; want unknown and incomplete",
+				name, s.Operation, s.Metadata)
+		}
+	}
+}
+
+// The stream decoder caps the body it reads. A GraphQL request cut at the cap
+// may hide a mutation past it, so it is refused, captured or not.
+func TestGraphQLTruncatedStreamFailsClosed(t *testing.T) {
+	body := gqlBody(t, `{ users { id } }`+strings.Repeat(" ", 100)+`mutation { dropUsers }`, "")
+	raw := fmt.Sprintf("POST /graphql HTTP/1.1\r\nHost: h\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s",
+		len(body), body)
+
+	stmts, _, err := hi.New(hi.Options{MaxBodyBytes: 32}).Decode(hoopinspect.FromClient, []byte(raw))
+	if err != nil || len(stmts) != 1 {
+		t.Fatalf("Decode = %d statements, %v", len(stmts), err)
+	}
+	if stmts[0].Operation != hoopinspect.OpUnknown {
+		t.Errorf("Operation = %q, want unknown", stmts[0].Operation)
+	}
+}
+
+// Plain JSON APIs stay plain HTTP, including one whose "query" is a search
+// string rather than a document.
+func TestNonGraphQLBodiesUntouched(t *testing.T) {
+	insp := hi.New(hi.Options{})
+	for _, body := range []string{`{"name": "x"}`, `{"query": "red shoes"}`} {
+		s := insp.InspectRequest(req(t, "POST", "/search", body), []byte(body))
+		if s.Operation != hoopinspect.OpPost || s.Metadata[hi.MetadataGraphQLOperation] != "" {
+			t.Errorf("%s: Operation = %q, metadata %v; want a plain POST", body, s.Operation, s.Metadata)
+		}
+	}
+}
+
+// Root fields are tables and sub-selections columns, so the rules a SQL lane
+// uses read a GraphQL lane unchanged.
+func TestGraphQLUnderTableAndColumnRules(t *testing.T) {
+	rules, err := policy.NewRules([]policy.Rule{
+		{Name: "no-drops", Type: policy.MatchOperation, Operations: []hoopinspect.Operation{hoopinspect.OpDelete}},
+		{Name: "no-ssn", Type: policy.MatchColumn, Columns: []string{"user.ssn"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		query, rule string
+	}{
+		{`{ user(id: 1) { name } }`, ""},
+		{`{ user(id: 1) { ssn } }`, "no-ssn"},
+		{`mutation { deleteUser(id: 1) }`, "no-drops"},
+	} {
+		v := rules.Evaluate(inspectGraphQL(t, hi.Options{}, gqlBody(t, tc.query, "")))
+		if v.Rule != tc.rule || v.Denied != (tc.rule != "") {
+			t.Errorf("%s: verdict = %+v, want rule %q", tc.query, v, tc.rule)
+		}
+	}
+}
+
+func TestValidateGraphQL(t *testing.T) {
+	if err := hi.ValidateGraphQL(`query Q { a { ...F } } fragment F on A { b }`); err != nil {
+		t.Errorf("valid document: %v", err)
+	}
+	if err := hi.ValidateGraphQL(`type Query { a: Int }`); err == nil {
+		t.Error("a type definition was accepted as an executable document")
+	}
+}
@@ -31,6 +31,13 @@
 // `*http.Request` in `inspectHandler` and `*http.Response` in
 // `modifyResponse`, with the body buffered. Handing those straight to
 // InspectRequest / InspectResponse costs one struct build.
+//
+// # GraphQL
+//
+// A GraphQL request is read for what it runs, not how it travels: its
+// operation maps to OpSelect or a write, and its root fields become
+// Relations, so operation and table rules apply to it. The body is read for
+// this whether or not CaptureBody is set. See graphql.go.
 package http
 
 import (
@@ -60,14 +67,21 @@ type Options struct {
 	// Off by default.
 	CaptureBody bool
 
-	// MaxBodyBytes truncates a captured body. Ignored when CaptureBody is
-	// false. Defaults to DefaultMaxBodyBytes.
+	// MaxBodyBytes truncates a captured body, and bounds how much of a
+	// streamed GraphQL request is read: one larger is refused as unreadable.
+	// Defaults to DefaultMaxBodyBytes.
 	MaxBodyBytes int
 
 	// Headers names the headers to expose to policy, matched
 	// case-insensitively. Anything not listed is dropped, and there is no
 	// "capture all" switch.
 	Headers []string
+
+	// PersistedQueries maps a persisted-query hash or document id to the
+	// GraphQL document it stands for. A GraphQL request that sends only an
+	// id the map does not hold is unreadable and comes back as OpUnknown;
+	// see graphql.go.
+	PersistedQueries map[string]string
 }
 
 // DefaultMaxBodyBytes bounds a captured body. A policy that needs more than
@@ -133,7 +147,7 @@ func (i *Inspector) inspectRequest(r *http.Request, b drained) hoopinspect.State
 	}
 	i.attachBody(d, b)
 
-	return hoopinspect.Statement{
+	st := hoopinspect.Statement{
 		Protocol:  hoopinspect.HTTP,
 		Direction: hoopinspect.FromClient,
 		Text:      d.Method + " " + r.URL.RequestURI(),
@@ -142,6 +156,10 @@ func (i *Inspector) inspectRequest(r *http.Request, b drained) hoopinspect.State
 		HTTP:      d,
 		Metadata:  map[string]string{"http.proto": r.Proto},
 	}
+	if gq, ok := i.graphql(r, d, b); ok {
+		gq.apply(&st)
+	}
+	return st
 }
 
 // InspectResponse builds a Statement from a parsed response.
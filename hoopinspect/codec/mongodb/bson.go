@@ -0,0 +1,460 @@
+package mongodb
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A minimal BSON reader: enough to walk a command document and render it as
+// text, and no more. There is no encoder and no reflection into Go types; the
+// deny frame in package proxy writes its four fields by hand.
+//
+// The official driver would do all of this and pull in a module graph larger
+// than the rest of this library. A command is a document of at most a few
+// kilobytes whose first key names what it does; that is a few hundred lines,
+// not a dependency.
+//
+// Spec: https://bsonspec.org/spec.html
+
+// BSON element types.
+const (
+	bsonDouble     = 0x01
+	bsonString     = 0x02
+	bsonDocument   = 0x03
+	bsonArray      = 0x04
+	bsonBinary     = 0x05
+	bsonUndefined  = 0x06
+	bsonObjectID   = 0x07
+	bsonBool       = 0x08
+	bsonDateTime   = 0x09
+	bsonNull       = 0x0a
+	bsonRegex      = 0x0b
+	bsonDBPointer  = 0x0c
+	bsonJavaScript = 0x0d
+	bsonSymbol     = 0x0e
+	bsonCodeScope  = 0x0f
+	bsonInt32      = 0x10
+	bsonTimestamp  = 0x11
+	bsonInt64      = 0x12
+	bsonDecimal128 = 0x13
+	bsonMinKey     = 0xff
+	bsonMaxKey     = 0x7f
+)
+
+// maxDepth bounds nesting. The server refuses documents nested deeper than
+// 100 levels of its own; a reader that recursed without a bound would let a
+// client spend the relay's stack instead.
+const maxDepth = 200
+
+// document is one BSON document, length prefix and trailing NUL included,
+// that has passed validate. Every accessor below relies on that and does no
+// bounds checking of its own.
+type document []byte
+
+// element is one key/value pair of a document.
+type element struct {
+	typ   byte
+	key   string
+	value []byte
+}
+
+// readDocument validates the document at the start of b and returns it with
+// its size.
+func readDocument(b []byte) (document, int, error) {
+	if len(b) < 5 {
+		return nil, 0, ErrMalformed
+	}
+	n := int(int32(binary.LittleEndian.Uint32(b)))
+	if n < 5 || n > len(b) {
+		return nil, 0, ErrMalformed
+	}
+	d := document(b[:n])
+	if err := d.validate(0); err != nil {
+		return nil, 0, err
+	}
+	return d, n, nil
+}
+
+// validate walks every element, recursing into subdocuments, so the
+// accessors never meet a length that runs off the end.
+func (d document) validate(depth int) error {
+	if depth > maxDepth || len(d) < 5 || d[len(d)-1] != 0 {
+		return ErrMalformed
+	}
+	for pos := 4; pos < len(d)-1; {
+		e, n, ok := nextElement(d[pos : len(d)-1])
+		if !ok {
+			return ErrMalformed
+		}
+		if e.typ == bsonDocument || e.typ == bsonArray {
+			if err := document(e.value).validate(depth + 1); err != nil {
+				return err
+			}
+		}
+		if e.typ == bsonCodeScope {
+			// int32 total, string code, document scope.
+			_, sn, ok := bsonStringAt(e.value[4:])
+			if !ok || 4+sn > len(e.value) {
+				return ErrMalformed
+			}
+			if err := document(e.value[4+sn:]).validate(depth + 1); err != nil {
+				return err
+			}
+		}
+		pos += n
+	}
+	return nil
+}
+
+// nextElement decodes the element at the start of b: a type byte, a
+// NUL-terminated key, and a value whose size depends on the type.
+func nextElement(b []byte) (element, int, bool) {
+	if len(b) < 2 {
+		return element{}, 0, false
+	}
+	typ := b[0]
+	nul := indexNUL(b[1:])
+	if nul < 0 {
+		return element{}, 0, false
+	}
+	key := string(b[1 : 1+nul])
+	pos := 1 + nul + 1
+	size, ok := valueSize(typ, b[pos:])
+	if !ok || size > len(b)-pos {
+		return element{}, 0, false
+	}
+	return element{typ: typ, key: key, value: b[pos : pos+size]}, pos + size, true
+}
+
+// valueSize returns how many bytes a value of the given type occupies at
+// the start of b.
+func valueSize(typ byte, b []byte) (int, bool) {
+	switch typ {
+	case bsonDouble, bsonDateTime, bsonTimestamp, bsonInt64:
+		return 8, true
+	case bsonInt32:
+		return 4, true
+	case bsonDecimal128:
+		return 16, true
+	case bsonObjectID:
+		return 12, true
+	case bsonBool:
+		return 1, true
+	case bsonUndefined, bsonNull, bsonMinKey, bsonMaxKey:
+		return 0, true
+	case bsonString, bsonJavaScript, bsonSymbol:
+		_, n, ok := bsonStringAt(b)
+		return n, ok
+	case bsonDocument, bsonArray, bsonCodeScope:
+		if len(b) < 4 {
+			return 0, false
+		}
+		n := int(int32(binary.LittleEndian.Uint32(b)))
+		return n, n >= 5 && n <= len(b)
+	case bsonBinary:
+		if len(b) < 5 {
+			return 0, false
+		}
+		n := int(int32(binary.LittleEndian.Uint32(b)))
+		return 5 + n, n >= 0 && n <= len(b)-5
+	case bsonRegex:
+		i := indexNUL(b)
+		if i < 0 {
+			return 0, false
+		}
+		j := indexNUL(b[i+1:])
+		if j < 0 {
+			return 0, false
+		}
+		return i + 1 + j + 1, true
+	case bsonDBPointer:
+		_, n, ok := bsonStringAt(b)
+		return n + 12, ok
+	}
+	return 0, false
+}
+
+// bsonStringAt decodes a BSON string: an int32 length counting the trailing
+// NUL, then the bytes.
+func bsonStringAt(b []byte) (string, int, bool) {
+	if len(b) < 4 {
+		return "", 0, false
+	}
+	n := int(int32(binary.LittleEndian.Uint32(b)))
+	if n < 1 || n > len(b)-4 || b[4+n-1] != 0 {
+		return "", 0, false
+	}
+	return string(b[4 : 4+n-1]), 4 + n, true
+}
+
+// elements returns every element in order.
+func (d document) elements() []element {
+	var out []element
+	for pos := 4; pos < len(d)-1; {
+		e, n, _ := nextElement(d[pos : len(d)-1])
+		out = append(out, e)
+		pos += n
+	}
+	return out
+}
+
+// first returns the first element. A command is named by its first key, and
+// the server dispatches on it alone.
+func (d document) first() (element, bool) {
+	if len(d) <= 5 {
+		return element{}, false
+	}
+	e, _, _ := nextElement(d[4 : len(d)-1])
+	return e, true
+}
+
+// lookup returns the first element with the given key.
+func (d document) lookup(key string) (element, bool) {
+	for pos := 4; pos < len(d)-1; {
+		e, n, _ := nextElement(d[pos : len(d)-1])
+		if e.key == key {
+			return e, true
+		}
+		pos += n
+	}
+	return element{}, false
+}
+
+func (e element) str() (string, bool) {
+	if e.typ != bsonString {
+		return "", false
+	}
+	s, _, _ := bsonStringAt(e.value)
+	return s, true
+}
+
+func (e element) doc() (document, bool) {
+	if e.typ != bsonDocument && e.typ != bsonArray {
+		return nil, false
+	}
+	return document(e.value), true
+}
+
+// truthy follows the server's reading of a flag: a boolean, or any number
+// other than zero.
+func (e element) truthy() bool {
+	switch e.typ {
+	case bsonBool:
+		return e.value[0] != 0
+	case bsonInt32:
+		return binary.LittleEndian.Uint32(e.value) != 0
+	case bsonInt64:
+		return binary.LittleEndian.Uint64(e.value) != 0
+	case bsonDouble:
+		return math.Float64frombits(binary.LittleEndian.Uint64(e.value)) != 0
+	}
+	return false
+}
+
+// renderJSON writes a document as relaxed Extended JSON, the notation
+// mongosh and the server's own logs use, so an audit record reads the way
+// the operator would have typed the command.
+//
+// https://www.mongodb.com/docs/manual/reference/mongodb-extended-json/
+func (d document) renderJSON(sb *strings.Builder, array bool) {
+	open, close := byte('{'), byte('}')
+	if array {
+		open, close = '[', ']'
+	}
+	sb.WriteByte(open)
+	for i, e := range d.elements() {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if !array {
+			writeJSONString(sb, e.key)
+			sb.WriteString(": ")
+		}
+		e.renderJSON(sb)
+	}
+	sb.WriteByte(close)
+}
+
+func (e element) renderJSON(sb *strings.Builder) {
+	v := e.value
+	switch e.typ {
+	case bsonDouble:
+		f := math.Float64frombits(binary.LittleEndian.Uint64(v))
+		switch {
+		case math.IsNaN(f):
+			sb.WriteString(`{"$numberDouble": "NaN"}`)
+		case math.IsInf(f, 1):
+			sb.WriteString(`{"$numberDouble": "Infinity"}`)
+		case math.IsInf(f, -1):
+			sb.WriteString(`{"$numberDouble": "-Infinity"}`)
+		case f == math.Trunc(f) && math.Abs(f) < 1e15:
+			// A whole double keeps its ".0" so it does not read as an int.
+			sb.WriteString(strconv.FormatFloat(f, 'f', 1, 64))
+		default:
+			sb.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	case bsonString:
+		s, _ := e.str()
+		writeJSONString(sb, s)
+	case bsonDocument:
+		document(v).renderJSON(sb, false)
+	case bsonArray:
+		document(v).renderJSON(sb, true)
+	case bsonBinary:
+		n := int(binary.LittleEndian.Uint32(v))
+		sb.WriteString(`{"$binary": {"base64": "`)
+		sb.WriteString(base64.StdEncoding.EncodeToString(v[5 : 5+n]))
+		sb.WriteString(`", "subType": "`)
+		sb.WriteString(hex.EncodeToString(v[4:5]))
+		sb.WriteString(`"}}`)
+	case bsonUndefined:
+		sb.WriteString(`{"$undefined": true}`)
+	case bsonObjectID:
+		sb.WriteString(`{"$oid": "`)
+		sb.WriteString(hex.EncodeToString(v))
+		sb.WriteString(`"}`)
+	case bsonBool:
+		sb.WriteString(strconv.FormatBool(v[0] != 0))
+	case bsonDateTime:
+		ms := int64(binary.LittleEndian.Uint64(v))
+		t := time.UnixMilli(ms).UTC()
+		if t.Year() >= 1970 && t.Year() <= 9999 {
+			sb.WriteString(`{"$date": "`)
+			sb.WriteString(t.Format("2006-01-02T15:04:05.000Z"))
+			sb.WriteString(`"}`)
+		} else {
+			sb.WriteString(`{"$date": {"$numberLong": "`)
+			sb.WriteString(strconv.FormatInt(ms, 10))
+			sb.WriteString(`"}}`)
+		}
+	case bsonNull:
+		sb.WriteString("null")
+	case bsonRegex:
+		i := indexNUL(v)
+		sb.WriteString(`{"$regularExpression": {"pattern": `)
+		writeJSONString(sb, string(v[:i]))
+		sb.WriteString(`, "options": `)
+		writeJSONString(sb, string(v[i+1:len(v)-1]))
+		sb.WriteString(`}}`)
+	case bsonDBPointer:
+		ref, n, _ := bsonStringAt(v)
+		sb.WriteString(`{"$dbPointer": {"$ref": `)
+		writeJSONString(sb, ref)
+		sb.WriteString(`, "$id": {"$oid": "`)
+		sb.WriteString(hex.EncodeToString(v[n:]))
+		sb.WriteString(`"}}}`)
+	case bsonJavaScript:
+		s, _, _ := bsonStringAt(v)
+		sb.WriteString(`{"$code": `)
+		writeJSONString(sb, s)
+		sb.WriteString(`}`)
+	case bsonSymbol:
+		s, _, _ := bsonStringAt(v)
+		sb.WriteString(`{"$symbol": `)
+		writeJSONString(sb, s)
+		sb.WriteString(`}`)
+	case bsonCodeScope:
+		s, n, _ := bsonStringAt(v[4:])
+		sb.WriteString(`{"$code": `)
+		writeJSONString(sb, s)
+		sb.WriteString(`, "$scope": `)
+		document(v[4+n:]).renderJSON(sb, false)
+		sb.WriteString(`}`)
+	case bsonInt32:
+		sb.WriteString(strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(v))), 10))
+	case bsonTimestamp:
+		sb.WriteString(`{"$timestamp": {"t": `)
+		sb.WriteString(strconv.FormatUint(uint64(binary.LittleEndian.Uint32(v[4:])), 10))
+		sb.WriteString(`, "i": `)
+		sb.WriteString(strconv.FormatUint(uint64(binary.LittleEndian.Uint32(v)), 10))
+		sb.WriteString(`}}`)
+	case bsonInt64:
+		sb.WriteString(strconv.FormatInt(int64(binary.LittleEndian.Uint64(v)), 10))
+	case bsonDecimal128:
+		sb.WriteString(`{"$numberDecimal": "`)
+		sb.WriteString(decimal128String(binary.LittleEndian.Uint64(v[8:]), binary.LittleEndian.Uint64(v)))
+		sb.WriteString(`"}`)
+	case bsonMinKey:
+		sb.WriteString(`{"$minKey": 1}`)
+	case bsonMaxKey:
+		sb.WriteString(`{"$maxKey": 1}`)
+	}
+}
+
+// decimal128String formats an IEEE 754-2008 decimal128 in binary integer
+// decimal encoding, following the string conversion in the BSON decimal128
+// specification.
+func decimal128String(high, low uint64) string {
+	sign := ""
+	if high>>63 == 1 {
+		sign = "-"
+	}
+	switch (high >> 58) & 0x1f {
+	case 0x1e:
+		return sign + "Infinity"
+	case 0x1f:
+		return "NaN"
+	}
+
+	var exp int
+	coef := new(big.Int)
+	if (high>>61)&3 == 3 {
+		// The second form's coefficient always exceeds the format's
+		// maximum, which the specification defines as zero.
+		exp = int((high>>47)&0x3fff) - 6176
+	} else {
+		exp = int((high>>49)&0x3fff) - 6176
+		coef.SetUint64(high & (1<<49 - 1))
+		coef.Lsh(coef, 64)
+		coef.Or(coef, new(big.Int).SetUint64(low))
+	}
+
+	digits := coef.String()
+	adjusted := exp + len(digits) - 1
+	if exp > 0 || adjusted < -6 {
+		out := digits[:1]
+		if len(digits) > 1 {
+			out += "." + digits[1:]
+		}
+		e := "E+"
+		if adjusted < 0 {
+			e = "E"
+		}
+		return sign + out + e + strconv.Itoa(adjusted)
+	}
+	if exp == 0 {
+		return sign + digits
+	}
+	point := len(digits) + exp
+	if point <= 0 {
+		return sign + "0." + strings.Repeat("0", -point) + digits
+	}
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// writeJSONString writes s as a JSON string. HTML escaping is off: the
+// reader is an operator, and `{"age": {"$lt": 30}}` should not arrive as
+// \u003c.
+func writeJSONString(sb *strings.Builder, s string) {
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	sb.WriteString(strings.TrimSuffix(buf.String(), "\n"))
+}
+
+func indexNUL(b []byte) int {
+	for i := range b {
+		if b[i] == 0 {
+			return i
+		}
+	}
+	return -1
+}
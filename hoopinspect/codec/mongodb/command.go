@@ -0,0 +1,529 @@
+package mongodb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// housekeeping commands produce no statement. Every driver sends them on
+// connect and on a heartbeat, they touch no data, and a statement for each
+// would bury the audit trail under "hello" every ten seconds.
+//
+// Authentication is here too. The SASL conversation is the driver proving who
+// it is, and its payloads are credential material an audit record must not
+// hold.
+var housekeeping = map[string]bool{
+	"hello":           true,
+	"ismaster":        true,
+	"ping":            true,
+	"buildinfo":       true,
+	"saslstart":       true,
+	"saslcontinue":    true,
+	"authenticate":    true,
+	"getnonce":        true,
+	"logout":          true,
+	"startsession":    true,
+	"endsessions":     true,
+	"refreshsessions": true,
+	"getlasterror":    true,
+	"whatsmyuri":      true,
+}
+
+// showCommands describe the server or the database and touch no collection.
+var showCommands = map[string]bool{
+	"listcollections":         true,
+	"listdatabases":           true,
+	"dbstats":                 true,
+	"serverstatus":            true,
+	"currentop":               true,
+	"hostinfo":                true,
+	"getparameter":            true,
+	"getcmdlineopts":          true,
+	"connectionstatus":        true,
+	"usersinfo":               true,
+	"rolesinfo":               true,
+	"replsetgetstatus":        true,
+	"replsetgetconfig":        true,
+	"getlog":                  true,
+	"listcommands":            true,
+	"top":                     true,
+	"lockinfo":                true,
+	"getdefaultrwconcern":     true,
+	"listshards":              true,
+	"connpoolstats":           true,
+	"atlasversion":            true,
+	"getfreemonitoringstatus": true,
+}
+
+// analysis is what one command does, in the vocabulary the SQL codecs use.
+type analysis struct {
+	// db is the database the command runs in, which is also the database
+	// of every collection it names without one.
+	db string
+
+	effects   []hoopinspect.Operation
+	relations []hoopinspect.Relation
+
+	// incomplete names why the command could not be classified. Non-empty
+	// means OpUnknown.
+	incomplete string
+}
+
+func (a *analysis) effect(op hoopinspect.Operation) {
+	for _, e := range a.effects {
+		if e == op {
+			return
+		}
+	}
+	a.effects = append(a.effects, op)
+}
+
+// touch records a collection in the command's own database.
+func (a *analysis) touch(coll string, acc hoopinspect.Access) {
+	if coll == "" {
+		return
+	}
+	if a.db == "" {
+		a.record(coll, acc)
+		return
+	}
+	a.record(a.db+"."+coll, acc)
+}
+
+// record adds a relation by its full name, write dominating read.
+func (a *analysis) record(name string, acc hoopinspect.Access) {
+	name = strings.ToLower(name)
+	for i, r := range a.relations {
+		if r.Name == name {
+			if acc == hoopinspect.AccessWrite {
+				a.relations[i].Access = acc
+			}
+			return
+		}
+	}
+	a.relations = append(a.relations, hoopinspect.Relation{Name: name, Access: acc})
+}
+
+// target records a stage or option that names a collection either as a
+// string in the command's database or as {db: ..., coll: ...}, the two
+// spellings `$out`, `$merge` and `$lookup` accept.
+func (a *analysis) target(e element, acc hoopinspect.Access) {
+	if s, ok := e.str(); ok {
+		a.touch(s, acc)
+		return
+	}
+	d, ok := e.doc()
+	if !ok || e.typ != bsonDocument {
+		return
+	}
+	coll := stringField(d, "coll")
+	if db := stringField(d, "db"); db != "" && coll != "" {
+		a.record(db+"."+coll, acc)
+		return
+	}
+	a.touch(coll, acc)
+}
+
+// severity mirrors the lexer's ordering of verbs, so Operation means the
+// same thing on a MongoDB lane as on a SQL one: the most consequential
+// effect, not the command's name.
+func severity(op hoopinspect.Operation) int {
+	switch op {
+	case hoopinspect.OpDrop, hoopinspect.OpTruncate:
+		return 6
+	case hoopinspect.OpDelete:
+		return 5
+	case hoopinspect.OpAlter, hoopinspect.OpGrant, hoopinspect.OpRevoke:
+		return 4
+	case hoopinspect.OpUpdate:
+		return 3
+	case hoopinspect.OpInsert, hoopinspect.OpCreate, hoopinspect.OpCall:
+		return 2
+	case hoopinspect.OpSelect, hoopinspect.OpShow:
+		return 1
+	}
+	return 0
+}
+
+// statement turns the analysis into a client Statement.
+func (a analysis) statement(h header, opcode, cmd, text string) hoopinspect.Statement {
+	s := hoopinspect.Statement{
+		Protocol:  hoopinspect.MongoDB,
+		Direction: hoopinspect.FromClient,
+		Text:      text,
+		Operation: hoopinspect.OpOther,
+		Effects:   a.effects,
+		Relations: a.relations,
+		Database:  a.db,
+		Metadata: map[string]string{
+			"mongodb.command":    cmd,
+			"mongodb.opcode":     opcode,
+			"mongodb.request_id": strconv.Itoa(int(h.requestID)),
+		},
+	}
+	best := -1
+	for _, e := range a.effects {
+		if r := severity(e); r > best {
+			best, s.Operation = r, e
+		}
+	}
+	if a.incomplete != "" {
+		s.Operation = hoopinspect.OpUnknown
+		s.Metadata[hoopinspect.MetadataSQLIncomplete] = a.incomplete
+	}
+	for _, r := range a.relations {
+		s.Tables = append(s.Tables, r.Name)
+	}
+	return s
+}
+
+// classify maps a command onto operations and collections.
+//
+// The server matches command names case-sensitively, with a handful of
+// lowercase aliases (findandmodify, ismaster). Matching here is
+// case-insensitive: reading a name the server will reject as a command it
+// would accept costs nothing, and the reverse is a bypass.
+func classify(inv invocation, db string) analysis {
+	a := analysis{db: db}
+	cmd, _ := inv.body.first()
+	name := strings.ToLower(cmd.key)
+	coll, _ := cmd.str()
+	body := inv.body
+
+	switch {
+	case name == "find" || name == "count" || name == "distinct" || name == "geonear":
+		a.effect(hoopinspect.OpSelect)
+		a.touch(coll, hoopinspect.AccessRead)
+
+	case name == "aggregate":
+		// `aggregate: 1` runs against the database, for stages such as
+		// $currentOp and $documents that have no source collection.
+		a.effect(hoopinspect.OpSelect)
+		a.touch(coll, hoopinspect.AccessRead)
+		if p, ok := body.lookup("pipeline"); ok {
+			a.pipeline(p)
+		}
+
+	case name == "getmore":
+		a.effect(hoopinspect.OpSelect)
+		a.touch(stringField(body, "collection"), hoopinspect.AccessRead)
+
+	case name == "mapreduce":
+		a.effect(hoopinspect.OpSelect)
+		a.touch(coll, hoopinspect.AccessRead)
+		a.mapReduceOut(body)
+
+	case name == "insert":
+		a.effect(hoopinspect.OpInsert)
+		a.touch(coll, hoopinspect.AccessWrite)
+
+	case name == "update":
+		a.effect(hoopinspect.OpUpdate)
+		a.touch(coll, hoopinspect.AccessWrite)
+		for _, u := range inv.documents("updates") {
+			if e, ok := u.lookup("upsert"); ok && e.truthy() {
+				a.effect(hoopinspect.OpInsert)
+			}
+		}
+
+	case name == "delete":
+		a.effect(hoopinspect.OpDelete)
+		a.touch(coll, hoopinspect.AccessWrite)
+
+	case name == "findandmodify":
+		if e, ok := body.lookup("remove"); ok && e.truthy() {
+			a.effect(hoopinspect.OpDelete)
+		} else {
+			a.effect(hoopinspect.OpUpdate)
+		}
+		if e, ok := body.lookup("upsert"); ok && e.truthy() {
+			a.effect(hoopinspect.OpInsert)
+		}
+		a.touch(coll, hoopinspect.AccessWrite)
+
+	case name == "bulkwrite":
+		a.bulkWrite(inv)
+
+	case name == "create":
+		// A view reads its source on every query, and the pipeline is
+		// where the source's own lookups hide.
+		a.effect(hoopinspect.OpCreate)
+		a.touch(coll, hoopinspect.AccessWrite)
+		a.touch(stringField(body, "viewOn"), hoopinspect.AccessRead)
+		if p, ok := body.lookup("pipeline"); ok {
+			a.pipeline(p)
+		}
+
+	case name == "createindexes" || name == "createsearchindexes":
+		a.effect(hoopinspect.OpCreate)
+		a.touch(coll, hoopinspect.AccessWrite)
+
+	case name == "clonecollectionascapped":
+		a.effect(hoopinspect.OpCreate)
+		a.touch(coll, hoopinspect.AccessRead)
+		a.touch(stringField(body, "toCollection"), hoopinspect.AccessWrite)
+
+	case name == "drop" || name == "dropindexes" || name == "deleteindexes" || name == "dropsearchindex":
+		a.effect(hoopinspect.OpDrop)
+		a.touch(coll, hoopinspect.AccessWrite)
+
+	case name == "dropdatabase":
+		a.effect(hoopinspect.OpDrop)
+
+	case name == "collmod" || name == "converttocapped" || name == "compact" ||
+		name == "reindex" || name == "updatesearchindex":
+		a.effect(hoopinspect.OpAlter)
+		a.touch(coll, hoopinspect.AccessWrite)
+
+	case name == "renamecollection":
+		// Both names are full namespaces, and the command runs against
+		// admin. dropTarget replaces whatever held the new name.
+		a.effect(hoopinspect.OpAlter)
+		if e, ok := body.lookup("dropTarget"); ok && e.truthy() {
+			a.effect(hoopinspect.OpDrop)
+		}
+		a.record(coll, hoopinspect.AccessWrite)
+		if to := stringField(body, "to"); to != "" {
+			a.record(to, hoopinspect.AccessWrite)
+		}
+
+	case name == "shardcollection" || name == "reshardcollection":
+		a.effect(hoopinspect.OpAlter)
+		if coll != "" {
+			a.record(coll, hoopinspect.AccessWrite)
+		}
+
+	case name == "createuser" || name == "createrole":
+		a.effect(hoopinspect.OpCreate)
+	case name == "dropuser" || name == "droprole" ||
+		name == "dropallusersfromdatabase" || name == "dropallrolesfromdatabase":
+		a.effect(hoopinspect.OpDrop)
+	case name == "updateuser" || name == "updaterole":
+		a.effect(hoopinspect.OpAlter)
+	case strings.HasPrefix(name, "grant"):
+		a.effect(hoopinspect.OpGrant)
+	case strings.HasPrefix(name, "revoke"):
+		a.effect(hoopinspect.OpRevoke)
+
+	case name == "committransaction":
+		a.effect(hoopinspect.OpCommit)
+	case name == "aborttransaction":
+		a.effect(hoopinspect.OpRollback)
+
+	case name == "explain":
+		a.explain(cmd)
+
+	case name == "listindexes" || name == "collstats" || name == "validate" ||
+		name == "listsearchindexes":
+		a.effect(hoopinspect.OpShow)
+		a.touch(coll, hoopinspect.AccessRead)
+	case name == "datasize":
+		a.effect(hoopinspect.OpShow)
+		if coll != "" {
+			a.record(coll, hoopinspect.AccessRead)
+		}
+	case showCommands[name]:
+		a.effect(hoopinspect.OpShow)
+
+	case name == "killcursors" || name == "killop":
+		a.effect(hoopinspect.OpOther)
+
+	default:
+		// applyOps replays oplog entries, eval runs JavaScript, and new
+		// releases add commands this table has never seen. Any of them
+		// can write, and a name is not evidence it does not.
+		a.incomplete = fmt.Sprintf("command %q is not one this decoder classifies", cmd.key)
+	}
+	return a
+}
+
+// pipeline walks aggregation stages for the collections they read and
+// write. A pipeline is a read until a stage says otherwise:
+//
+//	$out          replaces its target: insert and delete
+//	$merge        upserts into its target: insert and update
+//	$lookup       reads `from`, and its own pipeline is walked too
+//	$graphLookup  reads `from`
+//	$unionWith    reads its collection and walks its pipeline
+//	$facet        walks each of its sub-pipelines
+func (a *analysis) pipeline(p element) {
+	stages, ok := p.doc()
+	if !ok {
+		return
+	}
+	for _, s := range stages.elements() {
+		stage, ok := s.doc()
+		if !ok {
+			continue
+		}
+		op, ok := stage.first()
+		if !ok {
+			continue
+		}
+		switch op.key {
+		case "$out":
+			a.effect(hoopinspect.OpInsert)
+			a.effect(hoopinspect.OpDelete)
+			a.target(op, hoopinspect.AccessWrite)
+		case "$merge":
+			a.effect(hoopinspect.OpInsert)
+			a.effect(hoopinspect.OpUpdate)
+			if d, ok := op.doc(); ok && op.typ == bsonDocument {
+				if into, ok := d.lookup("into"); ok {
+					a.target(into, hoopinspect.AccessWrite)
+				}
+			} else {
+				a.target(op, hoopinspect.AccessWrite)
+			}
+		case "$lookup", "$graphLookup":
+			d, ok := op.doc()
+			if !ok {
+				continue
+			}
+			if from, ok := d.lookup("from"); ok {
+				a.target(from, hoopinspect.AccessRead)
+			}
+			if sub, ok := d.lookup("pipeline"); ok {
+				a.pipeline(sub)
+			}
+		case "$unionWith":
+			if op.typ == bsonString {
+				a.target(op, hoopinspect.AccessRead)
+				continue
+			}
+			d, ok := op.doc()
+			if !ok {
+				continue
+			}
+			a.touch(stringField(d, "coll"), hoopinspect.AccessRead)
+			if sub, ok := d.lookup("pipeline"); ok {
+				a.pipeline(sub)
+			}
+		case "$facet":
+			d, ok := op.doc()
+			if !ok {
+				continue
+			}
+			for _, sub := range d.elements() {
+				a.pipeline(sub)
+			}
+		}
+	}
+}
+
+// mapReduceOut reads mapReduce's `out`, which is a collection name, or
+// {inline: 1}, or {replace|merge|reduce: coll, db: ...}.
+func (a *analysis) mapReduceOut(body document) {
+	out, ok := body.lookup("out")
+	if !ok {
+		return
+	}
+	if s, ok := out.str(); ok {
+		a.effect(hoopinspect.OpInsert)
+		a.effect(hoopinspect.OpDelete)
+		a.touch(s, hoopinspect.AccessWrite)
+		return
+	}
+	d, ok := out.doc()
+	if !ok {
+		return
+	}
+	mode, ok := d.first()
+	if !ok || mode.key == "inline" {
+		return
+	}
+	coll, _ := mode.str()
+	switch mode.key {
+	case "replace":
+		a.effect(hoopinspect.OpInsert)
+		a.effect(hoopinspect.OpDelete)
+	default:
+		a.effect(hoopinspect.OpInsert)
+		a.effect(hoopinspect.OpUpdate)
+	}
+	if db := stringField(d, "db"); db != "" && coll != "" {
+		a.record(db+"."+coll, hoopinspect.AccessWrite)
+		return
+	}
+	a.touch(coll, hoopinspect.AccessWrite)
+}
+
+// bulkWrite is the 8.0 cross-collection command. It runs against admin and
+// names its collections in nsInfo as full namespaces; each op refers to one
+// by index and names its kind by its first key.
+func (a *analysis) bulkWrite(inv invocation) {
+	for _, ns := range inv.documents("nsInfo") {
+		if s := stringField(ns, "ns"); s != "" {
+			a.record(s, hoopinspect.AccessWrite)
+		}
+	}
+	for _, op := range inv.documents("ops") {
+		kind, ok := op.first()
+		if !ok {
+			continue
+		}
+		switch kind.key {
+		case "insert":
+			a.effect(hoopinspect.OpInsert)
+		case "update":
+			a.effect(hoopinspect.OpUpdate)
+			if e, ok := op.lookup("upsert"); ok && e.truthy() {
+				a.effect(hoopinspect.OpInsert)
+			}
+		case "delete":
+			a.effect(hoopinspect.OpDelete)
+		default:
+			a.incomplete = fmt.Sprintf("bulkWrite op %q is not one this decoder classifies", kind.key)
+		}
+	}
+	if len(a.effects) == 0 && a.incomplete == "" {
+		// No ops at all is a command the server rejects, but reporting it
+		// as doing nothing would be a guess.
+		a.incomplete = "bulkWrite carried no ops this decoder could read"
+	}
+}
+
+// explain plans its inner command without executing it. The inner
+// command's collections are reported as reads and the operation as a
+// select, the way AnalyzeSQL reports a SQL EXPLAIN. An inner command that
+// could not be classified leaves the explain unclassified too.
+func (a *analysis) explain(cmd element) {
+	inner, ok := cmd.doc()
+	if !ok || cmd.typ != bsonDocument {
+		a.incomplete = "explain did not carry a command document"
+		return
+	}
+	in := classify(invocation{body: inner}, a.db)
+	a.incomplete = in.incomplete
+	a.effect(hoopinspect.OpSelect)
+	for _, r := range in.relations {
+		a.record(r.Name, hoopinspect.AccessRead)
+	}
+}
+
+// documents returns the array a command carries under key, whether it
+// arrived in the body or as an OP_MSG document sequence. The server accepts
+// either and so must this.
+func (inv invocation) documents(key string) []document {
+	var out []document
+	if e, ok := inv.body.lookup(key); ok && e.typ == bsonArray {
+		arr, _ := e.doc()
+		for _, el := range arr.elements() {
+			if d, ok := el.doc(); ok && el.typ == bsonDocument {
+				out = append(out, d)
+			}
+		}
+	}
+	return append(out, inv.sequences[key]...)
+}
+
+func stringField(d document, key string) string {
+	if e, ok := d.lookup(key); ok {
+		s, _ := e.str()
+		return s
+	}
+	return ""
+}
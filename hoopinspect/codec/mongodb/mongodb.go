@@ -0,0 +1,670 @@
+// Package mongodb decodes the MongoDB wire protocol far enough to recover the
+// command a client sent, classify it the way the SQL codecs classify a
+// statement, and describe the cursor batches the server sends back.
+//
+// Envoy's mongo_proxy filter was removed upstream, and it never did more than
+// count operations by name. Nothing in front of a mongod could say "no
+// deletes on customers", so Mongo connections ran unpoliced.
+//
+// Wire format reference:
+// https://www.mongodb.com/docs/manual/reference/mongodb-wire-protocol/
+//
+// Every message carries a 16-byte header, little-endian:
+//
+//	int32  messageLength, INCLUDING the header
+//	int32  requestID
+//	int32  responseTo, the requestID this message answers
+//	int32  opCode
+//
+// Modern drivers send everything as OP_MSG: a flag word and a body document
+// whose FIRST KEY names the command (`{find: "customers", filter: ...}`),
+// optionally followed by document sequences that carry bulk payloads such as
+// an insert's documents. The server dispatches on that first key and nothing
+// else, so this decoder does too.
+//
+// OP_QUERY survives for the initial handshake, and OP_INSERT, OP_UPDATE and
+// OP_DELETE still reach servers older than 6.0. They are decoded rather than
+// waved through, because a legacy write opcode is a write all the same.
+// OP_COMPRESSED is unwrapped when the compressor is zlib or none; see
+// decompress for the others.
+//
+// # Mapping onto statements
+//
+// A command becomes one Statement. Text is the command rendered as relaxed
+// Extended JSON, the notation mongosh prints, with any document sequences
+// folded back in as the arrays they stand for. Operation and Effects come
+// from the command name and, for an aggregation, from its stages: `$out`
+// and `$merge` write their target, so a pipeline ending in one is not a read.
+// Relations are collections, named `db.collection` and lowercased the way
+// AnalyzeSQL lowercases table names, so a `table` rule naming `customers`
+// matches `appdb.customers` exactly as it does on a SQL lane.
+//
+// A command this decoder does not know comes back as OpUnknown with the
+// reason in Metadata["sql.incomplete"], the key the SQL codecs use. The
+// command set is large and grows every release, and some of it
+// (applyOps, for one) performs writes no name reveals. The one fail-closed
+// rule that covers an unreadable SQL statement covers it too.
+//
+// # TLS belongs to whatever fronts this
+//
+// MongoDB's TLS is on-connect: the client's first bytes are a ClientHello.
+// Forwarded to a server with TLS enabled, those bytes set up a session the
+// relay can never read, so this decoder refuses them with ErrStreamUnsafe.
+// Terminate TLS in front of the relay, and use upstream_tls toward the
+// server.
+package mongodb
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+func init() { hoopinspect.Register(func() hoopinspect.Codec { return &Codec{} }) }
+
+// Opcodes. https://www.mongodb.com/docs/manual/reference/mongodb-wire-protocol/#opcodes
+const (
+	opReply        = 1
+	opUpdate       = 2001
+	opInsert       = 2002
+	opQuery        = 2004
+	opGetMore      = 2005
+	opDelete       = 2006
+	opKillCursors  = 2007
+	opCommand      = 2010
+	opCommandReply = 2011
+	opCompressed   = 2012
+	opMsg          = 2013
+)
+
+// OP_MSG flag bits.
+const (
+	flagChecksumPresent = 1 << 0
+	flagMoreToCome      = 1 << 1
+)
+
+// Compressor ids in OP_COMPRESSED.
+const (
+	compressorNoop   = 0
+	compressorSnappy = 1
+	compressorZlib   = 2
+	compressorZstd   = 3
+)
+
+const (
+	headerLen = 16
+
+	// maxMessageLen is the server's own maxMessageSizeBytes. A header
+	// claiming more is not a MongoDB message.
+	maxMessageLen = 48_000_000
+)
+
+// ErrMalformed means the stream is not valid MongoDB wire protocol.
+var ErrMalformed = errors.New("hoopinspect/mongodb: malformed message")
+
+// Codec implements hoopinspect.Codec for MongoDB.
+//
+// It holds no state between messages: every command names its database in
+// `$db` and its collection in its own body, and every reply says which
+// request it answers. The type is a struct so the factory in init has
+// something to return per connection, like every other codec.
+type Codec struct{}
+
+func (*Codec) Protocol() hoopinspect.Protocol { return hoopinspect.MongoDB }
+
+// header is a message's fixed prefix.
+type header struct {
+	length     int
+	requestID  int32
+	responseTo int32
+	opCode     int32
+}
+
+func readHeader(b []byte) header {
+	return header{
+		length:     int(int32(binary.LittleEndian.Uint32(b))),
+		requestID:  int32(binary.LittleEndian.Uint32(b[4:])),
+		responseTo: int32(binary.LittleEndian.Uint32(b[8:])),
+		opCode:     int32(binary.LittleEndian.Uint32(b[12:])),
+	}
+}
+
+// knownOpcode reports whether an opcode exists in the protocol. It is checked
+// as soon as a header arrives, before waiting for the body, so bytes that are
+// not MongoDB at all fail at once instead of stalling on a length that means
+// nothing.
+func knownOpcode(op int32) bool {
+	switch op {
+	case opReply, opUpdate, opInsert, opQuery, opGetMore, opDelete,
+		opKillCursors, opCommand, opCommandReply, opCompressed, opMsg:
+		return true
+	}
+	return false
+}
+
+// Decode implements hoopinspect.Codec.
+//
+// Metadata keys set on client statements:
+//
+//	"mongodb.command"       the command name as sent, e.g. "findAndModify"
+//	"mongodb.opcode"        "OP_MSG", "OP_QUERY", or the legacy opcode
+//	"mongodb.request_id"    the message's requestID, which a reply must echo
+//	"mongodb.more_to_come"  "true" when the client expects no reply at all
+//
+// Server messages yield a statement for each cursor batch, carrying
+// Statement.Result, and for each command error. See response.go.
+func (c *Codec) Decode(dir hoopinspect.Direction, data []byte) ([]hoopinspect.Statement, int, error) {
+	var stmts []hoopinspect.Statement
+	pos := 0
+	for pos < len(data) {
+		if len(data)-pos < headerLen {
+			return stmts, pos, nil
+		}
+		h := readHeader(data[pos:])
+		if h.length < headerLen || h.length > maxMessageLen || !knownOpcode(h.opCode) {
+			if dir == hoopinspect.FromClient && looksLikeTLS(data[pos:]) {
+				return stmts, pos, fmt.Errorf(
+					"%w: the client opened a TLS session, which forwarded to the server "+
+						"would make every later byte unreadable to policy, masking and the "+
+						"audit trail; terminate TLS in front of this decoder and connect "+
+						"to it in plaintext",
+					hoopinspect.ErrStreamUnsafe)
+			}
+			return stmts, pos, ErrMalformed
+		}
+		if len(data)-pos < h.length {
+			return stmts, pos, nil // partial message, retain it
+		}
+		body := data[pos+headerLen : pos+h.length]
+
+		var (
+			got []hoopinspect.Statement
+			err error
+		)
+		if dir == hoopinspect.FromServer {
+			got = c.reply(h, body)
+		} else {
+			got, err = c.request(h, body)
+		}
+		if err != nil {
+			return stmts, pos, err
+		}
+		stmts = append(stmts, got...)
+		pos += h.length
+	}
+	return stmts, pos, nil
+}
+
+// looksLikeTLS reports a ClientHello: a handshake record (content type 22,
+// major version 3) whose first message is type 1. It is consulted only for
+// bytes that already failed as a MongoDB header, since a message 790 bytes
+// long starts with the same two bytes as a TLS record.
+func looksLikeTLS(b []byte) bool {
+	return len(b) >= 6 && b[0] == 0x16 && b[1] == 0x03 && b[2] <= 0x04 && b[5] == 0x01
+}
+
+// request decodes one client message.
+func (c *Codec) request(h header, body []byte) ([]hoopinspect.Statement, error) {
+	if h.opCode == opCompressed {
+		op, inner, err := decompress(body)
+		if err != nil {
+			return nil, err
+		}
+		h.opCode = op
+		body = inner
+	}
+
+	switch h.opCode {
+	case opMsg:
+		inv, ok := parseMsg(body)
+		if !ok {
+			return []hoopinspect.Statement{unreadable(h, "OP_MSG",
+				"the message's sections could not be decoded")}, nil
+		}
+		return c.commandStatement(h, "OP_MSG", inv), nil
+
+	case opQuery:
+		return c.query(h, body), nil
+
+	case opInsert, opUpdate, opDelete, opGetMore, opKillCursors:
+		return []hoopinspect.Statement{legacy(h, body)}, nil
+
+	case opCommand:
+		// OP_COMMAND was removed in 4.2 and no current driver sends it,
+		// but a 3.6 server still executes it.
+		return []hoopinspect.Statement{unreadable(h, "OP_COMMAND",
+			"OP_COMMAND is not decoded; no supported driver sends it")}, nil
+	}
+	// A reply opcode travelling toward the server.
+	return nil, ErrMalformed
+}
+
+// invocation is one command as the server will see it.
+type invocation struct {
+	// body is the command document. Its first key is the command name.
+	body document
+
+	// sequences are OP_MSG kind-1 sections by identifier ("documents",
+	// "updates", "deletes", "ops", "nsInfo"). The server treats each as if
+	// the body had an array of that name.
+	sequences map[string][]document
+
+	// moreToCome is OP_MSG's flag for a message the server must not answer,
+	// which is how a driver sends an unacknowledged write.
+	moreToCome bool
+}
+
+// parseMsg decodes an OP_MSG body:
+//
+//	uint32    flagBits
+//	sections  kind 0: one document, the command body
+//	          kind 1: int32 size, cstring identifier, documents
+//	uint32    checksum, present when flagBits says so
+func parseMsg(b []byte) (invocation, bool) {
+	var inv invocation
+	if len(b) < 4 {
+		return inv, false
+	}
+	flags := binary.LittleEndian.Uint32(b)
+	inv.moreToCome = flags&flagMoreToCome != 0
+	b = b[4:]
+	if flags&flagChecksumPresent != 0 {
+		if len(b) < 4 {
+			return inv, false
+		}
+		b = b[:len(b)-4]
+	}
+
+	for len(b) > 0 {
+		kind := b[0]
+		b = b[1:]
+		switch kind {
+		case 0:
+			if inv.body != nil {
+				return inv, false // the server refuses a second body
+			}
+			d, n, err := readDocument(b)
+			if err != nil {
+				return inv, false
+			}
+			inv.body = d
+			b = b[n:]
+		case 1:
+			if len(b) < 4 {
+				return inv, false
+			}
+			size := int(int32(binary.LittleEndian.Uint32(b)))
+			if size < 4 || size > len(b) {
+				return inv, false
+			}
+			sec := b[4:size]
+			nul := indexNUL(sec)
+			if nul < 0 {
+				return inv, false
+			}
+			id := string(sec[:nul])
+			var docs []document
+			for rest := sec[nul+1:]; len(rest) > 0; {
+				d, n, err := readDocument(rest)
+				if err != nil {
+					return inv, false
+				}
+				docs = append(docs, d)
+				rest = rest[n:]
+			}
+			if inv.sequences == nil {
+				inv.sequences = map[string][]document{}
+			}
+			inv.sequences[id] = append(inv.sequences[id], docs...)
+			b = b[size:]
+		default:
+			// Kind 2 is internal to the server; anything else is not
+			// defined.
+			return inv, false
+		}
+	}
+	return inv, inv.body != nil
+}
+
+// query decodes an OP_QUERY:
+//
+//	int32     flags
+//	cstring   fullCollectionName, "db.collection"
+//	int32     numberToSkip
+//	int32     numberToReturn
+//	document  query
+//	document  returnFieldsSelector, optional
+//
+// Against "db.$cmd" the query is a command, and that is how drivers send
+// their first hello. Against anything else it is a legacy find.
+func (c *Codec) query(h header, b []byte) []hoopinspect.Statement {
+	if len(b) < 4 {
+		return []hoopinspect.Statement{unreadable(h, "OP_QUERY", "the query is truncated")}
+	}
+	b = b[4:]
+	nul := indexNUL(b)
+	if nul < 0 || len(b) < nul+1+8 {
+		return []hoopinspect.Statement{unreadable(h, "OP_QUERY", "the query is truncated")}
+	}
+	ns := string(b[:nul])
+	q, _, err := readDocument(b[nul+1+8:])
+	if err != nil {
+		return []hoopinspect.Statement{unreadable(h, "OP_QUERY",
+			"the query document could not be decoded")}
+	}
+
+	db, coll, _ := strings.Cut(ns, ".")
+	if coll != "$cmd" {
+		var sb strings.Builder
+		sb.WriteString(`{"find": `)
+		writeJSONString(&sb, coll)
+		sb.WriteString(`, "filter": `)
+		q.renderJSON(&sb, false)
+		sb.WriteString(`}`)
+		a := analysis{db: db}
+		a.effect(hoopinspect.OpSelect)
+		a.touch(coll, hoopinspect.AccessRead)
+		return []hoopinspect.Statement{a.statement(h, "OP_QUERY", "find", sb.String())}
+	}
+
+	// A command sent through OP_QUERY may be wrapped so that read
+	// preference can ride beside it: {$query: {cmd}, $readPreference: ...}.
+	// The server unwraps either spelling, so this does too.
+	if e, ok := q.first(); ok && (e.key == "$query" || e.key == "query") {
+		if inner, ok := e.doc(); ok && e.typ == bsonDocument {
+			q = inner
+		}
+	}
+	body := appendDB(q, db)
+	return c.commandStatement(h, "OP_QUERY", invocation{body: body})
+}
+
+// appendDB returns the command with `$db` set, so an OP_QUERY command is
+// classified with the same database an OP_MSG names in its body.
+func appendDB(d document, db string) document {
+	if _, ok := d.lookup("$db"); ok || db == "" {
+		return d
+	}
+	el := []byte{bsonString}
+	el = append(el, "$db"...)
+	el = append(el, 0)
+	el = binary.LittleEndian.AppendUint32(el, uint32(len(db)+1))
+	el = append(el, db...)
+	el = append(el, 0)
+
+	out := make([]byte, 0, len(d)+len(el))
+	out = append(out, d[:len(d)-1]...)
+	out = append(out, el...)
+	out = append(out, 0)
+	binary.LittleEndian.PutUint32(out, uint32(len(out)))
+	return document(out)
+}
+
+// legacy decodes the pre-3.6 CRUD opcodes. Servers before 6.0 still execute
+// them, and a write that arrives by a route the decoder ignores is a write
+// no rule saw.
+//
+//	OP_INSERT        int32 flags, cstring ns, documents
+//	OP_UPDATE        int32 0, cstring ns, int32 flags, selector, update
+//	OP_DELETE        int32 0, cstring ns, int32 flags, selector
+//	OP_GET_MORE      int32 0, cstring ns, int32 numberToReturn, int64 cursorID
+//	OP_KILL_CURSORS  int32 0, int32 count, int64 cursorIDs
+//
+// The text is the modern command each one is equivalent to, so the audit
+// trail reads the same whichever opcode carried it.
+func legacy(h header, b []byte) hoopinspect.Statement {
+	name := opcodeName(h.opCode)
+	if h.opCode == opKillCursors {
+		a := analysis{}
+		a.effect(hoopinspect.OpOther)
+		return a.statement(h, name, "killCursors", `{"killCursors": "legacy"}`)
+	}
+	if len(b) < 4 {
+		return unreadable(h, name, "the message is truncated")
+	}
+	nul := indexNUL(b[4:])
+	if nul < 0 {
+		return unreadable(h, name, "the message is truncated")
+	}
+	db, coll, _ := strings.Cut(string(b[4:4+nul]), ".")
+	rest := b[4+nul+1:]
+	a := analysis{db: db}
+
+	var sb strings.Builder
+	var cmd string
+	switch h.opCode {
+	case opInsert:
+		cmd = "insert"
+		a.effect(hoopinspect.OpInsert)
+		a.touch(coll, hoopinspect.AccessWrite)
+		sb.WriteString(`{"insert": `)
+		writeJSONString(&sb, coll)
+		sb.WriteString(`, "documents": [`)
+		for i := 0; len(rest) > 0; i++ {
+			d, n, err := readDocument(rest)
+			if err != nil {
+				return unreadable(h, name, "a document could not be decoded")
+			}
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			d.renderJSON(&sb, false)
+			rest = rest[n:]
+		}
+		sb.WriteString(`]}`)
+
+	case opUpdate, opDelete:
+		if len(rest) < 4 {
+			return unreadable(h, name, "the message is truncated")
+		}
+		flags := binary.LittleEndian.Uint32(rest)
+		sel, n, err := readDocument(rest[4:])
+		if err != nil {
+			return unreadable(h, name, "the selector could not be decoded")
+		}
+		a.touch(coll, hoopinspect.AccessWrite)
+		if h.opCode == opDelete {
+			cmd = "delete"
+			a.effect(hoopinspect.OpDelete)
+			sb.WriteString(`{"delete": `)
+			writeJSONString(&sb, coll)
+			sb.WriteString(`, "deletes": [{"q": `)
+			sel.renderJSON(&sb, false)
+			// Bit 0 is SingleRemove.
+			sb.WriteString(`, "limit": ` + strconv.Itoa(int(flags&1)) + `}]}`)
+			break
+		}
+		upd, _, err := readDocument(rest[4+n:])
+		if err != nil {
+			return unreadable(h, name, "the update could not be decoded")
+		}
+		cmd = "update"
+		a.effect(hoopinspect.OpUpdate)
+		// Bit 0 is Upsert, bit 1 MultiUpdate.
+		if flags&1 != 0 {
+			a.effect(hoopinspect.OpInsert)
+		}
+		sb.WriteString(`{"update": `)
+		writeJSONString(&sb, coll)
+		sb.WriteString(`, "updates": [{"q": `)
+		sel.renderJSON(&sb, false)
+		sb.WriteString(`, "u": `)
+		upd.renderJSON(&sb, false)
+		sb.WriteString(`, "upsert": ` + strconv.FormatBool(flags&1 != 0))
+		sb.WriteString(`, "multi": ` + strconv.FormatBool(flags&2 != 0) + `}]}`)
+
+	case opGetMore:
+		cmd = "getMore"
+		a.effect(hoopinspect.OpSelect)
+		a.touch(coll, hoopinspect.AccessRead)
+		sb.WriteString(`{"getMore": "legacy", "collection": `)
+		writeJSONString(&sb, coll)
+		sb.WriteString(`}`)
+	}
+	return a.statement(h, name, cmd, sb.String())
+}
+
+// decompress unwraps an OP_COMPRESSED:
+//
+//	int32  originalOpcode
+//	int32  uncompressedSize
+//	uint8  compressorId
+//	bytes  the original message body, compressed
+//
+// zlib is in the standard library and "noop" is no compression at all.
+// snappy and zstd are not, and this module takes no dependencies, so a
+// client that negotiated either is refused: every message after the
+// handshake would be unreadable, and forwarding them would run the session
+// with no policy at all. No driver compresses by default; the client opts in
+// with `compressors=` in its connection string, and removing it, or naming
+// zlib, is the fix.
+func decompress(b []byte) (int32, []byte, error) {
+	if len(b) < 9 {
+		return 0, nil, ErrMalformed
+	}
+	op := int32(binary.LittleEndian.Uint32(b))
+	size := int(int32(binary.LittleEndian.Uint32(b[4:])))
+	if size < 0 || size > maxMessageLen-headerLen {
+		return 0, nil, ErrMalformed
+	}
+	payload := b[9:]
+
+	switch b[8] {
+	case compressorNoop:
+		if len(payload) != size {
+			return 0, nil, ErrMalformed
+		}
+		return op, payload, nil
+	case compressorZlib:
+		zr, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return 0, nil, ErrMalformed
+		}
+		out, err := io.ReadAll(io.LimitReader(zr, int64(size)+1))
+		if err != nil || len(out) != size {
+			return 0, nil, ErrMalformed
+		}
+		return op, out, nil
+	case compressorSnappy, compressorZstd:
+		name := "snappy"
+		if b[8] == compressorZstd {
+			name = "zstd"
+		}
+		return 0, nil, fmt.Errorf(
+			"%w: the client compresses its messages with %s, which this decoder "+
+				"cannot read, so no later command could be inspected; remove "+
+				"compressors=%s from the connection string or use zlib",
+			hoopinspect.ErrStreamUnsafe, name, name)
+	}
+	return 0, nil, ErrMalformed
+}
+
+func opcodeName(op int32) string {
+	switch op {
+	case opMsg:
+		return "OP_MSG"
+	case opQuery:
+		return "OP_QUERY"
+	case opReply:
+		return "OP_REPLY"
+	case opInsert:
+		return "OP_INSERT"
+	case opUpdate:
+		return "OP_UPDATE"
+	case opDelete:
+		return "OP_DELETE"
+	case opGetMore:
+		return "OP_GET_MORE"
+	case opKillCursors:
+		return "OP_KILL_CURSORS"
+	}
+	return strconv.Itoa(int(op))
+}
+
+// commandStatement classifies an invocation into a statement. Connection
+// housekeeping (the handshake, authentication, heartbeats) yields none.
+func (c *Codec) commandStatement(h header, opcode string, inv invocation) []hoopinspect.Statement {
+	e, ok := inv.body.first()
+	if !ok {
+		return []hoopinspect.Statement{unreadable(h, opcode, "the command document is empty")}
+	}
+	if housekeeping[strings.ToLower(e.key)] {
+		return nil
+	}
+	db := ""
+	if v, ok := inv.body.lookup("$db"); ok {
+		db, _ = v.str()
+	}
+	s := classify(inv, db).statement(h, opcode, e.key, renderCommand(inv))
+	if inv.moreToCome {
+		s.Metadata["mongodb.more_to_come"] = "true"
+	}
+	return []hoopinspect.Statement{s}
+}
+
+// unreadable reports a message whose command could not be recovered. The
+// operation is OpUnknown and the reason travels under the key the SQL codecs
+// use, so one fail-closed rule covers both.
+func unreadable(h header, opcode, reason string) hoopinspect.Statement {
+	return hoopinspect.Statement{
+		Protocol:  hoopinspect.MongoDB,
+		Direction: hoopinspect.FromClient,
+		Operation: hoopinspect.OpUnknown,
+		Metadata: map[string]string{
+			"mongodb.opcode":                  opcode,
+			"mongodb.request_id":              strconv.Itoa(int(h.requestID)),
+			hoopinspect.MetadataSQLIncomplete: reason,
+		},
+	}
+}
+
+// renderCommand renders the body with its document sequences folded in.
+//
+// A password in createUser or updateUser is replaced: the text becomes an
+// audit record, and a record holding the credential it audited has leaked
+// it.
+func renderCommand(inv invocation) string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, e := range inv.body.elements() {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		writeJSONString(&sb, e.key)
+		sb.WriteString(": ")
+		if e.key == "pwd" {
+			sb.WriteString(`"[REDACTED]"`)
+			continue
+		}
+		e.renderJSON(&sb)
+	}
+	// Map order is random; the audit text must not be.
+	ids := make([]string, 0, len(inv.sequences))
+	for id := range inv.sequences {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		// The body is never empty here: its first key named the command.
+		sb.WriteString(", ")
+		writeJSONString(&sb, id)
+		sb.WriteString(": [")
+		for i, d := range inv.sequences[id] {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			d.renderJSON(&sb, false)
+		}
+		sb.WriteByte(']')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
@@ -0,0 +1,537 @@
+package mongodb_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/codec/mongodb"
+)
+
+// d is a BSON document written as alternating keys and values, in order.
+// Values are string, int (int32), int64, float64, bool, nil, d, or a.
+type d []any
+
+// a is a BSON array.
+type a []any
+
+func (doc d) bson() []byte {
+	out := []byte{0, 0, 0, 0}
+	for i := 0; i+1 < len(doc); i += 2 {
+		out = appendElement(out, doc[i].(string), doc[i+1])
+	}
+	out = append(out, 0)
+	binary.LittleEndian.PutUint32(out, uint32(len(out)))
+	return out
+}
+
+func (arr a) bson() []byte {
+	var doc d
+	for i, v := range arr {
+		doc = append(doc, strconv.Itoa(i), v)
+	}
+	return doc.bson()
+}
+
+func appendElement(out []byte, key string, v any) []byte {
+	typ, val := encode(v)
+	out = append(out, typ)
+	out = append(out, key...)
+	out = append(out, 0)
+	return append(out, val...)
+}
+
+func encode(v any) (byte, []byte) {
+	switch v := v.(type) {
+	case string:
+		b := binary.LittleEndian.AppendUint32(nil, uint32(len(v)+1))
+		return 0x02, append(append(b, v...), 0)
+	case int:
+		return 0x10, binary.LittleEndian.AppendUint32(nil, uint32(int32(v)))
+	case int64:
+		return 0x12, binary.LittleEndian.AppendUint64(nil, uint64(v))
+	case float64:
+		return 0x01, binary.LittleEndian.AppendUint64(nil, math.Float64bits(v))
+	case bool:
+		if v {
+			return 0x08, []byte{1}
+		}
+		return 0x08, []byte{0}
+	case nil:
+		return 0x0a, nil
+	case d:
+		return 0x03, v.bson()
+	case a:
+		return 0x04, v.bson()
+	}
+	panic(fmt.Sprintf("unsupported BSON test value %T", v))
+}
+
+func header(length, requestID, responseTo, opCode int) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(length))
+	b = binary.LittleEndian.AppendUint32(b, uint32(requestID))
+	b = binary.LittleEndian.AppendUint32(b, uint32(responseTo))
+	return binary.LittleEndian.AppendUint32(b, uint32(opCode))
+}
+
+func message(requestID, responseTo, opCode int, body []byte) []byte {
+	return append(header(16+len(body), requestID, responseTo, opCode), body...)
+}
+
+// sequence is an OP_MSG kind-1 section.
+type sequence struct {
+	id   string
+	docs []d
+}
+
+// opMsg builds an OP_MSG carrying body and any document sequences.
+func opMsg(requestID int, flags uint32, body d, seqs ...sequence) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, flags)
+	b = append(b, 0)
+	b = append(b, body.bson()...)
+	for _, s := range seqs {
+		var sec []byte
+		sec = append(sec, s.id...)
+		sec = append(sec, 0)
+		for _, doc := range s.docs {
+			sec = append(sec, doc.bson()...)
+		}
+		b = append(b, 1)
+		b = binary.LittleEndian.AppendUint32(b, uint32(4+len(sec)))
+		b = append(b, sec...)
+	}
+	return message(requestID, 0, 2013, b)
+}
+
+func command(body d) []byte { return opMsg(1, 0, body) }
+
+func opQuery(ns string, q d) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, 0)
+	b = append(b, ns...)
+	b = append(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, 0)          // skip
+	b = binary.LittleEndian.AppendUint32(b, ^uint32(0)) // return -1
+	return message(7, 0, 2004, append(b, q.bson()...))
+}
+
+func newInspector(t *testing.T) *hoopinspect.Inspector {
+	t.Helper()
+	i, err := hoopinspect.New(hoopinspect.MongoDB)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return i
+}
+
+func inspectClient(t *testing.T, stream []byte) []hoopinspect.Statement {
+	t.Helper()
+	stmts, err := newInspector(t).Inspect(hoopinspect.FromClient, stream)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	return stmts
+}
+
+func one(t *testing.T, stream []byte) hoopinspect.Statement {
+	t.Helper()
+	stmts := inspectClient(t, stream)
+	if len(stmts) != 1 {
+		t.Fatalf("got %d statements, want 1: %+v", len(stmts), stmts)
+	}
+	return stmts[0]
+}
+
+// relations renders Relations as "name:access" for comparison.
+func relations(s hoopinspect.Statement) string {
+	var parts []string
+	for _, r := range s.Relations {
+		parts = append(parts, r.Name+":"+string(r.Access))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestCommandClassification(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    d
+		wantOp  hoopinspect.Operation
+		wantRel string
+	}{
+		{"find", d{"find", "customers", "filter", d{"id", 1}, "$db", "appdb"},
+			hoopinspect.OpSelect, "appdb.customers:read"},
+		{"count", d{"count", "Customers", "$db", "appdb"},
+			hoopinspect.OpSelect, "appdb.customers:read"},
+		{"insert", d{"insert", "orders", "documents", a{d{"n", 1}}, "$db", "appdb"},
+			hoopinspect.OpInsert, "appdb.orders:write"},
+		{"update", d{"update", "orders", "updates", a{d{"q", d{}, "u", d{"$set", d{"n", 2}}}}, "$db", "appdb"},
+			hoopinspect.OpUpdate, "appdb.orders:write"},
+		{"delete", d{"delete", "customers", "deletes", a{d{"q", d{}, "limit", 0}}, "$db", "appdb"},
+			hoopinspect.OpDelete, "appdb.customers:write"},
+		{"findAndModify remove", d{"findAndModify", "jobs", "remove", true, "$db", "appdb"},
+			hoopinspect.OpDelete, "appdb.jobs:write"},
+		{"findAndModify update", d{"findAndModify", "jobs", "update", d{"$inc", d{"n", 1}}, "$db", "appdb"},
+			hoopinspect.OpUpdate, "appdb.jobs:write"},
+		{"aggregate read", d{"aggregate", "orders", "pipeline", a{
+			d{"$match", d{"n", 1}},
+			d{"$lookup", d{"from", "customers", "localField", "c", "foreignField", "_id", "as", "c"}},
+		}, "cursor", d{}, "$db", "appdb"},
+			hoopinspect.OpSelect, "appdb.orders:read appdb.customers:read"},
+		{"aggregate $out", d{"aggregate", "customers", "pipeline", a{
+			d{"$match", d{}}, d{"$out", "archive"},
+		}, "cursor", d{}, "$db", "appdb"},
+			hoopinspect.OpDelete, "appdb.customers:read appdb.archive:write"},
+		{"aggregate $merge elsewhere", d{"aggregate", "customers", "pipeline", a{
+			d{"$merge", d{"into", d{"db", "reporting", "coll", "daily"}}},
+		}, "cursor", d{}, "$db", "appdb"},
+			hoopinspect.OpUpdate, "appdb.customers:read reporting.daily:write"},
+		{"nested pipelines", d{"aggregate", "a", "pipeline", a{
+			d{"$facet", d{"x", a{d{"$lookup", d{"from", "b", "pipeline", a{d{"$unionWith", "c"}}, "as", "j"}}}}},
+		}, "cursor", d{}, "$db", "appdb"},
+			hoopinspect.OpSelect, "appdb.a:read appdb.b:read appdb.c:read"},
+		{"mapReduce to a collection", d{"mapReduce", "orders", "out", d{"merge", "totals"}, "$db", "appdb"},
+			hoopinspect.OpUpdate, "appdb.orders:read appdb.totals:write"},
+		{"mapReduce inline", d{"mapReduce", "orders", "out", d{"inline", 1}, "$db", "appdb"},
+			hoopinspect.OpSelect, "appdb.orders:read"},
+		{"create view", d{"create", "v", "viewOn", "customers", "pipeline", a{}, "$db", "appdb"},
+			hoopinspect.OpCreate, "appdb.v:write appdb.customers:read"},
+		{"createIndexes", d{"createIndexes", "customers", "indexes", a{}, "$db", "appdb"},
+			hoopinspect.OpCreate, "appdb.customers:write"},
+		{"drop", d{"drop", "customers", "$db", "appdb"},
+			hoopinspect.OpDrop, "appdb.customers:write"},
+		{"dropDatabase", d{"dropDatabase", 1, "$db", "appdb"},
+			hoopinspect.OpDrop, ""},
+		{"collMod", d{"collMod", "customers", "validator", d{}, "$db", "appdb"},
+			hoopinspect.OpAlter, "appdb.customers:write"},
+		{"renameCollection over a target", d{"renameCollection", "appdb.staging", "to", "appdb.live", "dropTarget", true, "$db", "admin"},
+			hoopinspect.OpDrop, "appdb.staging:write appdb.live:write"},
+		{"createUser", d{"createUser", "eve", "pwd", "x", "roles", a{}, "$db", "admin"},
+			hoopinspect.OpCreate, ""},
+		{"grantRolesToUser", d{"grantRolesToUser", "eve", "roles", a{"root"}, "$db", "admin"},
+			hoopinspect.OpGrant, ""},
+		{"explain", d{"explain", d{"delete", "customers", "deletes", a{}}, "$db", "appdb"},
+			hoopinspect.OpSelect, "appdb.customers:read"},
+		{"listCollections", d{"listCollections", 1, "$db", "appdb"},
+			hoopinspect.OpShow, ""},
+		{"listIndexes", d{"listIndexes", "customers", "$db", "appdb"},
+			hoopinspect.OpShow, "appdb.customers:read"},
+		{"commitTransaction", d{"commitTransaction", 1, "$db", "admin"},
+			hoopinspect.OpCommit, ""},
+		{"killCursors", d{"killCursors", "customers", "cursors", a{int64(1)}, "$db", "appdb"},
+			hoopinspect.OpOther, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := one(t, command(tc.body))
+			if s.Operation != tc.wantOp || relations(s) != tc.wantRel {
+				t.Errorf("got %s [%s], want %s [%s]\n  text: %s",
+					s.Operation, relations(s), tc.wantOp, tc.wantRel, s.Text)
+			}
+			if s.Protocol != hoopinspect.MongoDB || s.Metadata["mongodb.opcode"] != "OP_MSG" ||
+				s.Metadata["mongodb.command"] != tc.body[0] {
+				t.Errorf("protocol %q, metadata %v", s.Protocol, s.Metadata)
+			}
+		})
+	}
+}
+
+// Tables and Database follow from Relations and $db, so a table rule and a
+// database rule written for SQL apply unchanged.
+func TestStatementCarriesDatabaseAndTables(t *testing.T) {
+	s := one(t, command(d{"delete", "Customers", "deletes", a{}, "$db", "appdb"}))
+	if s.Database != "appdb" {
+		t.Errorf("Database = %q, want appdb", s.Database)
+	}
+	if len(s.Tables) != 1 || s.Tables[0] != "appdb.customers" {
+		t.Errorf("Tables = %v, want [appdb.customers]", s.Tables)
+	}
+}
+
+// A command this decoder has no entry for can do anything. It must come back
+// unknown, with a reason, so the fail-closed rule catches it.
+func TestUnclassifiedCommandIsUnknown(t *testing.T) {
+	for _, body := range []d{
+		{"applyOps", a{d{"op", "d", "ns", "appdb.customers", "o", d{}}}, "$db", "admin"},
+		{"someFutureCommand", 1, "$db", "appdb"},
+		{"explain", d{"applyOps", a{}}, "$db", "admin"},
+	} {
+		s := one(t, command(body))
+		if s.Operation != hoopinspect.OpUnknown {
+			t.Errorf("%s: got %s, want unknown", body[0], s.Operation)
+		}
+		if s.Metadata[hoopinspect.MetadataSQLIncomplete] == "" {
+			t.Errorf("%s: no reason recorded", body[0])
+		}
+	}
+}
+
+// Upserts insert. A rule refusing inserts must see the one an update makes.
+func TestUpsertReportsInsert(t *testing.T) {
+	s := one(t, command(d{"update", "orders", "updates", a{
+		d{"q", d{"id", 1}, "u", d{"$set", d{"n", 1}}, "upsert", true},
+	}, "$db", "appdb"}))
+	if !containsOp(s.Effects, hoopinspect.OpInsert) || s.Operation != hoopinspect.OpUpdate {
+		t.Errorf("got %s %v, want update with an insert effect", s.Operation, s.Effects)
+	}
+}
+
+func containsOp(ops []hoopinspect.Operation, want hoopinspect.Operation) bool {
+	for _, op := range ops {
+		if op == want {
+			return true
+		}
+	}
+	return false
+}
+
+// Drivers send bulk payloads as document sequences rather than arrays in
+// the body. They are the same command to the server, so they must be to the
+// decoder: an upsert in a sequence is still an upsert, and the documents
+// still belong in the audit text.
+func TestDocumentSequencesAreFolded(t *testing.T) {
+	s := one(t, opMsg(1, 0, d{"update", "orders", "$db", "appdb"},
+		sequence{"updates", []d{{"q", d{}, "u", d{"n", 1}, "upsert", true}}}))
+	if !containsOp(s.Effects, hoopinspect.OpInsert) {
+		t.Errorf("an upsert in a sequence was missed: %v", s.Effects)
+	}
+
+	s = one(t, opMsg(1, 0, d{"insert", "orders", "$db", "appdb"},
+		sequence{"documents", []d{{"n", 1}, {"n", 2}}}))
+	want := `{"insert": "orders", "$db": "appdb", "documents": [{"n": 1}, {"n": 2}]}`
+	if s.Text != want {
+		t.Errorf("Text = %s\n want %s", s.Text, want)
+	}
+}
+
+// bulkWrite names its collections in nsInfo and its kinds in ops, and both
+// may arrive as sequences.
+func TestBulkWrite(t *testing.T) {
+	s := one(t, opMsg(1, 0, d{"bulkWrite", 1, "$db", "admin"},
+		sequence{"ops", []d{{"insert", 0, "document", d{}}, {"delete", 1, "filter", d{}}}},
+		sequence{"nsInfo", []d{{"ns", "appdb.orders"}, {"ns", "appdb.customers"}}}))
+	if s.Operation != hoopinspect.OpDelete || relations(s) != "appdb.orders:write appdb.customers:write" {
+		t.Errorf("got %s [%s]", s.Operation, relations(s))
+	}
+}
+
+// The text is relaxed Extended JSON, the notation mongosh prints, so an
+// audit record reads the way the operator would have typed the command.
+func TestTextIsExtendedJSON(t *testing.T) {
+	s := one(t, command(d{"find", "customers", "filter", d{"age", d{"$lt", 30}, "score", 1.0},
+		"limit", int64(5), "$db", "appdb"}))
+	want := `{"find": "customers", "filter": {"age": {"$lt": 30}, "score": 1.0}, "limit": 5, "$db": "appdb"}`
+	if s.Text != want {
+		t.Errorf("Text = %s\n want %s", s.Text, want)
+	}
+}
+
+// A password must never reach the audit trail.
+func TestPasswordIsRedacted(t *testing.T) {
+	s := one(t, command(d{"createUser", "eve", "pwd", "hunter2", "roles", a{}, "$db", "admin"}))
+	if strings.Contains(s.Text, "hunter2") {
+		t.Errorf("password in audit text: %s", s.Text)
+	}
+}
+
+// Connection housekeeping is not a statement. A policy that saw every
+// heartbeat would have an audit trail of nothing else.
+func TestHousekeepingProducesNoStatement(t *testing.T) {
+	stream := bytes.Join([][]byte{
+		command(d{"hello", 1, "$db", "admin"}),
+		command(d{"saslStart", 1, "mechanism", "SCRAM-SHA-256", "payload", "x", "$db", "admin"}),
+		command(d{"ping", 1, "$db", "admin"}),
+		opQuery("admin.$cmd", d{"isMaster", 1}),
+	}, nil)
+	if stmts := inspectClient(t, stream); len(stmts) != 0 {
+		t.Errorf("got %+v, want no statements", stmts)
+	}
+}
+
+// OP_QUERY against $cmd is a command, and the wrapper that carries a read
+// preference must not hide it.
+func TestOpQueryCommand(t *testing.T) {
+	s := one(t, opQuery("appdb.$cmd", d{"$query", d{"drop", "customers"}, "$readPreference", d{"mode", "primary"}}))
+	if s.Operation != hoopinspect.OpDrop || relations(s) != "appdb.customers:write" {
+		t.Errorf("got %s [%s]", s.Operation, relations(s))
+	}
+	if s.Database != "appdb" || s.Metadata["mongodb.opcode"] != "OP_QUERY" || s.Metadata["mongodb.request_id"] != "7" {
+		t.Errorf("database %q, metadata %v", s.Database, s.Metadata)
+	}
+
+	s = one(t, opQuery("appdb.customers", d{"ssn", "123"}))
+	if s.Operation != hoopinspect.OpSelect || relations(s) != "appdb.customers:read" {
+		t.Errorf("legacy find: got %s [%s]", s.Operation, relations(s))
+	}
+}
+
+// The legacy write opcodes still reach servers before 6.0. A decoder that
+// only read OP_MSG would let every one of them through unseen.
+func TestLegacyWriteOpcodes(t *testing.T) {
+	ns := func(flags uint32, s string) []byte {
+		b := binary.LittleEndian.AppendUint32(nil, flags)
+		return append(append(b, s...), 0)
+	}
+	insert := message(1, 0, 2002, append(ns(0, "appdb.orders"), d{"n", 1}.bson()...))
+
+	del := append(ns(0, "appdb.customers"), binary.LittleEndian.AppendUint32(nil, 0)...)
+	del = message(2, 0, 2006, append(del, d{}.bson()...))
+
+	upd := append(ns(0, "appdb.orders"), binary.LittleEndian.AppendUint32(nil, 1)...) // Upsert
+	upd = append(upd, d{"id", 1}.bson()...)
+	upd = message(3, 0, 2001, append(upd, d{"$set", d{"n", 2}}.bson()...))
+
+	stmts := inspectClient(t, bytes.Join([][]byte{insert, del, upd}, nil))
+	if len(stmts) != 3 {
+		t.Fatalf("got %d statements, want 3: %+v", len(stmts), stmts)
+	}
+	want := []struct {
+		op     hoopinspect.Operation
+		rel    string
+		opcode string
+	}{
+		{hoopinspect.OpInsert, "appdb.orders:write", "OP_INSERT"},
+		{hoopinspect.OpDelete, "appdb.customers:write", "OP_DELETE"},
+		{hoopinspect.OpUpdate, "appdb.orders:write", "OP_UPDATE"},
+	}
+	for i, w := range want {
+		s := stmts[i]
+		if s.Operation != w.op || relations(s) != w.rel || s.Metadata["mongodb.opcode"] != w.opcode {
+			t.Errorf("stmt %d = %s [%s] %v, want %s [%s] %s",
+				i, s.Operation, relations(s), s.Metadata, w.op, w.rel, w.opcode)
+		}
+	}
+	if !containsOp(stmts[2].Effects, hoopinspect.OpInsert) {
+		t.Error("the legacy upsert flag was not reported as an insert")
+	}
+}
+
+// A message split across reads is retained until it is whole.
+func TestSplitMessageIsReassembled(t *testing.T) {
+	msg := command(d{"delete", "customers", "deletes", a{}, "$db", "appdb"})
+	i := newInspector(t)
+	for _, cut := range []int{3, 16, len(msg) - 1} {
+		i.Reset()
+		stmts, err := i.Inspect(hoopinspect.FromClient, msg[:cut])
+		if err != nil || len(stmts) != 0 {
+			t.Fatalf("cut %d: first half gave %v, %v", cut, stmts, err)
+		}
+		stmts, err = i.Inspect(hoopinspect.FromClient, msg[cut:])
+		if err != nil || len(stmts) != 1 || stmts[0].Operation != hoopinspect.OpDelete {
+			t.Fatalf("cut %d: second half gave %+v, %v", cut, stmts, err)
+		}
+	}
+}
+
+// compress wraps an uncompressed message in OP_COMPRESSED.
+func compress(t *testing.T, msg []byte, compressor byte) []byte {
+	t.Helper()
+	payload := msg[16:]
+	if compressor == 2 {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(payload)
+		zw.Close()
+		payload = buf.Bytes()
+	}
+	b := append([]byte{}, msg[12:16]...) // original opcode
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(msg)-16))
+	b = append(b, compressor)
+	return message(9, 0, 2012, append(b, payload...))
+}
+
+func TestZlibCompressionIsRead(t *testing.T) {
+	for _, compressor := range []byte{0, 2} {
+		s := one(t, compress(t, command(d{"drop", "customers", "$db", "appdb"}), compressor))
+		if s.Operation != hoopinspect.OpDrop {
+			t.Errorf("compressor %d: got %s, want drop", compressor, s.Operation)
+		}
+	}
+}
+
+// A compressor this decoder cannot read would hide every command after the
+// handshake. That is refused, not forwarded.
+func TestUnreadableCompressionIsUnsafe(t *testing.T) {
+	for _, compressor := range []byte{1, 3} {
+		_, err := newInspector(t).Inspect(hoopinspect.FromClient,
+			compress(t, command(d{"drop", "customers", "$db", "appdb"}), compressor))
+		if !errors.Is(err, hoopinspect.ErrStreamUnsafe) {
+			t.Errorf("compressor %d: err = %v, want ErrStreamUnsafe", compressor, err)
+		}
+	}
+}
+
+// A client that opens TLS through the relay would end up in a session with
+// the server that nothing can read.
+func TestClientHelloIsUnsafe(t *testing.T) {
+	hello := []byte{0x16, 0x03, 0x01, 0x02, 0x00, 0x01, 0x00, 0x01, 0xfc, 0x03, 0x03}
+	hello = append(hello, bytes.Repeat([]byte{0x5a}, 32)...)
+	_, err := newInspector(t).Inspect(hoopinspect.FromClient, hello)
+	if !errors.Is(err, hoopinspect.ErrStreamUnsafe) {
+		t.Errorf("err = %v, want ErrStreamUnsafe", err)
+	}
+}
+
+func TestGarbageIsMalformed(t *testing.T) {
+	for _, stream := range [][]byte{
+		header(16, 1, 0, 4242),       // no such opcode
+		header(8, 1, 0, 2013),        // shorter than its own header
+		header(1<<30, 1, 0, 2013),    // larger than the server accepts
+		message(1, 0, 1, d{}.bson()), // a reply, sent by the client
+	} {
+		if _, err := newInspector(t).Inspect(hoopinspect.FromClient, stream); !errors.Is(err, mongodb.ErrMalformed) {
+			t.Errorf("%x: err = %v, want ErrMalformed", stream[:16], err)
+		}
+	}
+}
+
+// A message that is framed correctly but whose body does not decode is a
+// statement this decoder cannot read, not a stream it gives up on. Giving
+// up would forward it.
+func TestUndecodableBodyIsUnknown(t *testing.T) {
+	body := binary.LittleEndian.AppendUint32(nil, 0)
+	body = append(body, 0, 0xff, 0xff, 0xff, 0x7f) // a document claiming 2 GiB
+	s := one(t, message(1, 0, 2013, body))
+	if s.Operation != hoopinspect.OpUnknown || s.Metadata[hoopinspect.MetadataSQLIncomplete] == "" {
+		t.Errorf("got %s %v, want unknown with a reason", s.Operation, s.Metadata)
+	}
+}
+
+// An unacknowledged write says so, because an error reply to it would be
+// read as the answer to the next command.
+func TestMoreToComeIsRecorded(t *testing.T) {
+	s := one(t, opMsg(1, 1<<1, d{"insert", "orders", "documents", a{}, "$db", "appdb"}))
+	if s.Metadata["mongodb.more_to_come"] != "true" {
+		t.Errorf("metadata %v", s.Metadata)
+	}
+}
+
+func TestChecksumIsStripped(t *testing.T) {
+	msg := opMsg(1, 1, d{"drop", "customers", "$db", "appdb"})
+	msg = append(msg, 0xde, 0xad, 0xbe, 0xef)
+	binary.LittleEndian.PutUint32(msg, uint32(len(msg)))
+	if s := one(t, msg); s.Operation != hoopinspect.OpDrop {
+		t.Errorf("got %s, want drop", s.Operation)
+	}
+}
+
+func TestDecodeDoesNotPanic(t *testing.T) {
+	valid := command(d{"aggregate", "a", "pipeline", a{d{"$out", "b"}}, "$db", "appdb"})
+	for cut := 0; cut <= len(valid); cut++ {
+		for _, flip := range []int{-1, 4, 16, 21, 25, 30} {
+			in := append([]byte{}, valid[:cut]...)
+			if flip >= 0 && flip < len(in) {
+				in[flip] ^= 0xff
+			}
+			for _, dir := range []hoopinspect.Direction{hoopinspect.FromClient, hoopinspect.FromServer} {
+				_, _ = newInspector(t).Inspect(dir, in)
+			}
+		}
+	}
+}
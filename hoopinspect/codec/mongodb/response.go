@@ -0,0 +1,204 @@
+package mongodb
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+const (
+	// maxColumns bounds the field names collected from one batch. A
+	// collection has no schema, so a batch of documents with generated keys
+	// could otherwise grow the list without limit.
+	maxColumns = 1024
+
+	// replyQueryFailure is OP_REPLY's flag for a failed legacy query, whose
+	// one document carries $err.
+	replyQueryFailure = 1 << 1
+)
+
+// reply decodes one server message. It returns a statement for each cursor
+// batch and for each command that failed, and nothing for the rest: a hello
+// reply or a write acknowledgement describes no data leaving the server.
+//
+// # Columns without a schema
+//
+// A MongoDB result has no RowDescription. Documents in one batch may each
+// have different fields, so Result.Columns is the union of the top-level
+// field names across the batch, in order of first appearance, each with the
+// BSON type it first arrived as in DataTypeOID. Nested fields are not
+// listed: a rule about `ssn` is about a top-level field, as it is about a
+// column, and a nested one would need a path syntax no rule speaks.
+//
+// Metadata keys:
+//
+//	"mongodb.opcode"       "OP_MSG", or "OP_REPLY" for an answer to OP_QUERY
+//	"mongodb.response_to"  the requestID of the command this answers
+//	"mongodb.error_code"   the server's error code, on a failed command
+//	"mongodb.code_name"    its symbolic name, e.g. "Unauthorized"
+func (c *Codec) reply(h header, body []byte) []hoopinspect.Statement {
+	if h.opCode == opCompressed {
+		op, inner, err := decompress(body)
+		if err != nil {
+			// The client opted into a compressor this decoder cannot
+			// read, and its requests have already been refused for it.
+			// A response is only described, never the reason a session
+			// is unsafe, so it is skipped.
+			return nil
+		}
+		h.opCode = op
+		body = inner
+	}
+
+	switch h.opCode {
+	case opMsg:
+		inv, ok := parseMsg(body)
+		if !ok {
+			return nil
+		}
+		if s, ok := commandReply(h, inv.body); ok {
+			return []hoopinspect.Statement{s}
+		}
+	case opReply:
+		return legacyReply(h, body)
+	}
+	return nil
+}
+
+// commandReply describes a command's reply document, reporting false when it
+// is neither an error nor a cursor batch.
+func commandReply(h header, d document) (hoopinspect.Statement, bool) {
+	if e, ok := d.lookup("ok"); ok && !e.truthy() {
+		md := map[string]string{}
+		if code, ok := d.lookup("code"); ok {
+			md["mongodb.error_code"] = strconv.FormatInt(number(code), 10)
+		}
+		if name := stringField(d, "codeName"); name != "" {
+			md["mongodb.code_name"] = name
+		}
+		return response(h, stringField(d, "errmsg"), nil, md), true
+	}
+
+	cur, ok := d.lookup("cursor")
+	if !ok {
+		return hoopinspect.Statement{}, false
+	}
+	cd, ok := cur.doc()
+	if !ok {
+		return hoopinspect.Statement{}, false
+	}
+	for _, key := range []string{"firstBatch", "nextBatch"} {
+		if b, ok := cd.lookup(key); ok && b.typ == bsonArray {
+			batch, _ := b.doc()
+			var docs []document
+			for _, el := range batch.elements() {
+				if doc, ok := el.doc(); ok && el.typ == bsonDocument {
+					docs = append(docs, doc)
+				}
+			}
+			return response(h, "", describe(docs), nil), true
+		}
+	}
+	return hoopinspect.Statement{}, false
+}
+
+// legacyReply decodes OP_REPLY, the answer to an OP_QUERY:
+//
+//	int32   responseFlags
+//	int64   cursorID
+//	int32   startingFrom
+//	int32   numberReturned
+//	documents
+//
+// A command sent through OP_QUERY gets its reply document here, and a legacy
+// find gets its rows.
+func legacyReply(h header, b []byte) []hoopinspect.Statement {
+	if len(b) < 20 {
+		return nil
+	}
+	flags := binary.LittleEndian.Uint32(b)
+	var docs []document
+	for rest := b[20:]; len(rest) > 0; {
+		d, n, err := readDocument(rest)
+		if err != nil {
+			return nil
+		}
+		docs = append(docs, d)
+		rest = rest[n:]
+	}
+
+	if flags&replyQueryFailure != 0 {
+		msg := ""
+		if len(docs) > 0 {
+			msg = stringField(docs[0], "$err")
+		}
+		return []hoopinspect.Statement{response(h, msg, nil, nil)}
+	}
+	if len(docs) == 1 {
+		if _, ok := docs[0].lookup("ok"); ok {
+			if s, ok := commandReply(h, docs[0]); ok {
+				return []hoopinspect.Statement{s}
+			}
+			return nil
+		}
+	}
+	return []hoopinspect.Statement{response(h, "", describe(docs), nil)}
+}
+
+// describe summarizes a batch: how many documents, and which fields.
+func describe(docs []document) *hoopinspect.ResultDetail {
+	detail := &hoopinspect.ResultDetail{RowCount: len(docs)}
+	seen := map[string]bool{}
+	for _, d := range docs {
+		for _, e := range d.elements() {
+			if seen[e.key] {
+				continue
+			}
+			if len(detail.Columns) == maxColumns {
+				// The fields past the limit are unlisted, which a
+				// column rule must read as "not known absent".
+				detail.Truncated = true
+				return detail
+			}
+			seen[e.key] = true
+			detail.Columns = append(detail.Columns,
+				hoopinspect.Column{Name: e.key, DataTypeOID: uint32(e.typ)})
+		}
+	}
+	return detail
+}
+
+func response(h header, text string, detail *hoopinspect.ResultDetail, extra map[string]string) hoopinspect.Statement {
+	md := map[string]string{
+		"mongodb.opcode":      opcodeName(h.opCode),
+		"mongodb.response_to": strconv.Itoa(int(h.responseTo)),
+	}
+	for k, v := range extra {
+		md[k] = v
+	}
+	return hoopinspect.Statement{
+		Protocol:  hoopinspect.MongoDB,
+		Direction: hoopinspect.FromServer,
+		Text:      text,
+		// A reply carries no verb; the operation lives on the command.
+		Operation: hoopinspect.OpUnknown,
+		Result:    detail,
+		Metadata:  md,
+	}
+}
+
+// number reads any BSON numeric as an integer, the way the server reports
+// error codes in whichever width the driver's language preferred.
+func number(e element) int64 {
+	switch e.typ {
+	case bsonInt32:
+		return int64(int32(binary.LittleEndian.Uint32(e.value)))
+	case bsonInt64:
+		return int64(binary.LittleEndian.Uint64(e.value))
+	case bsonDouble:
+		return int64(math.Float64frombits(binary.LittleEndian.Uint64(e.value)))
+	}
+	return 0
+}
@@ -0,0 +1,137 @@
+package mongodb_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+func inspectServer(t *testing.T, stream []byte) []hoopinspect.Statement {
+	t.Helper()
+	stmts, err := newInspector(t).Inspect(hoopinspect.FromServer, stream)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	return stmts
+}
+
+func reply(responseTo int, body d) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, 0)
+	b = append(b, 0)
+	return message(100, responseTo, 2013, append(b, body.bson()...))
+}
+
+func columnNames(r *hoopinspect.ResultDetail) []string {
+	var out []string
+	for _, c := range r.Columns {
+		out = append(out, c.Name)
+	}
+	return out
+}
+
+// A cursor batch is a result set. Its columns are the union of the
+// documents' top-level fields, because documents in one collection need not
+// share any.
+func TestCursorBatchIsAResult(t *testing.T) {
+	stmts := inspectServer(t, reply(5, d{
+		"cursor", d{"firstBatch", a{
+			d{"_id", 1, "name", "ada"},
+			d{"_id", 2, "ssn", "123-45-6789"},
+		}, "id", int64(0), "ns", "appdb.customers"},
+		"ok", 1.0,
+	}))
+	if len(stmts) != 1 {
+		t.Fatalf("got %d statements, want 1: %+v", len(stmts), stmts)
+	}
+	s := stmts[0]
+	if s.Result == nil || s.Result.RowCount != 2 {
+		t.Fatalf("Result = %+v, want 2 rows", s.Result)
+	}
+	if got := columnNames(s.Result); !equalStrings(got, []string{"_id", "name", "ssn"}) {
+		t.Errorf("columns = %v", got)
+	}
+	if s.Result.Columns[0].DataTypeOID != 0x10 {
+		t.Errorf("_id type = %#x, want int32 (0x10)", s.Result.Columns[0].DataTypeOID)
+	}
+	if s.Direction != hoopinspect.FromServer || s.Operation != hoopinspect.OpUnknown ||
+		s.Metadata["mongodb.response_to"] != "5" {
+		t.Errorf("direction %s, operation %s, metadata %v", s.Direction, s.Operation, s.Metadata)
+	}
+}
+
+func TestGetMoreBatchIsAResult(t *testing.T) {
+	stmts := inspectServer(t, reply(6, d{"cursor", d{"nextBatch", a{d{"x", 1}}, "id", int64(9)}, "ok", 1}))
+	if len(stmts) != 1 || stmts[0].Result == nil || stmts[0].Result.RowCount != 1 {
+		t.Fatalf("got %+v, want one batch of one", stmts)
+	}
+}
+
+// A failed command is auditable as a failure.
+func TestCommandErrorIsReported(t *testing.T) {
+	stmts := inspectServer(t, reply(5, d{"ok", 0.0, "errmsg", "not authorized on appdb", "code", 13, "codeName", "Unauthorized"}))
+	if len(stmts) != 1 {
+		t.Fatalf("got %d statements, want 1", len(stmts))
+	}
+	s := stmts[0]
+	if s.Text != "not authorized on appdb" || s.Metadata["mongodb.error_code"] != "13" ||
+		s.Metadata["mongodb.code_name"] != "Unauthorized" {
+		t.Errorf("got %q %v", s.Text, s.Metadata)
+	}
+}
+
+// A write acknowledgement or a hello reply carries no rows.
+func TestOtherRepliesProduceNothing(t *testing.T) {
+	stream := bytes.Join([][]byte{
+		reply(1, d{"isWritablePrimary", true, "maxWireVersion", 21, "ok", 1.0}),
+		reply(2, d{"n", 3, "ok", 1.0}),
+	}, nil)
+	if stmts := inspectServer(t, stream); len(stmts) != 0 {
+		t.Errorf("got %+v, want nothing", stmts)
+	}
+}
+
+// OP_REPLY answers OP_QUERY: a legacy find's rows, or a command's document.
+func TestLegacyReply(t *testing.T) {
+	opReply := func(flags uint32, docs ...d) []byte {
+		b := binary.LittleEndian.AppendUint32(nil, flags)
+		b = binary.LittleEndian.AppendUint64(b, 0)
+		b = binary.LittleEndian.AppendUint32(b, 0)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(docs)))
+		for _, doc := range docs {
+			b = append(b, doc.bson()...)
+		}
+		return message(100, 7, 1, b)
+	}
+
+	stmts := inspectServer(t, opReply(0, d{"a", 1}, d{"b", 2}, d{"a", 3}))
+	if len(stmts) != 1 || stmts[0].Result.RowCount != 3 ||
+		!equalStrings(columnNames(stmts[0].Result), []string{"a", "b"}) {
+		t.Errorf("rows: got %+v", stmts)
+	}
+	if stmts[0].Metadata["mongodb.opcode"] != "OP_REPLY" {
+		t.Errorf("metadata %v", stmts[0].Metadata)
+	}
+
+	if stmts := inspectServer(t, opReply(0, d{"ismaster", true, "ok", 1.0})); len(stmts) != 0 {
+		t.Errorf("hello reply: got %+v", stmts)
+	}
+
+	stmts = inspectServer(t, opReply(1<<1, d{"$err", "bad query", "code", 2}))
+	if len(stmts) != 1 || stmts[0].Text != "bad query" {
+		t.Errorf("query failure: got %+v", stmts)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
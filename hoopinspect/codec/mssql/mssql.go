@@ -271,16 +271,19 @@ func (c *Codec) Decode(dir hoopinspect.Direction, data []byte) ([]hoopinspect.St
 			// here than anywhere: Operation is already OpUnknown, and
 			// the reason says which construct defeated it.
 			md[hoopinspect.MetadataSQLIncomplete] = a.Reason
+		} else if !a.ColumnsComplete {
+			md[hoopinspect.MetadataColumnsIncomplete] = a.ColumnsReason
 		}
 		stmts = append(stmts, hoopinspect.Statement{
-			Protocol:  hoopinspect.MSSQL,
-			Direction: hoopinspect.FromClient,
-			Text:      text,
-			Operation: a.Operation,
-			Effects:   a.Effects,
-			Relations: a.Relations,
-			Tables:    a.Tables,
-			Metadata:  md,
+			Protocol:        hoopinspect.MSSQL,
+			Direction:       hoopinspect.FromClient,
+			Text:            text,
+			Operation:       a.Operation,
+			Effects:         a.Effects,
+			Relations:       a.Relations,
+			Tables:          a.Tables,
+			UnboundedWrites: a.UnboundedWrites,
+			Metadata:        md,
 		})
 	}
 }
@@ -0,0 +1,127 @@
+package mysql
+
+// LimitRows drops result-set rows the caller refuses, renumbering what
+// follows so the client still sees an unbroken sequence. It satisfies
+// gate.RowLimiter.
+//
+// # Sequence ids
+//
+// Every packet of a response carries a sequence id one past the last, and a
+// client that sees a gap rejects the stream ("packets out of order"). Masking
+// avoids the problem by never adding or removing a packet; a limit cannot,
+// since removing packets is the point. So once a row is dropped, every later
+// packet of the same response, through the terminator that ends it, has its
+// sequence id lowered by the number of physical packets dropped. The shift
+// ends with the response, because the next command starts the count over.
+//
+// # Result sets
+//
+// The count resets at each result set's terminator, so each result of a
+// multi-statement query is limited on its own, the way each is described by
+// its own Statement.
+//
+// A trailing partial packet is held until its remainder arrives, in a buffer
+// separate from Rewrite's.
+func (c *Codec) LimitRows(data []byte, admit func(rows int, bytes int64) bool) ([]byte, error) {
+	if len(c.limit.pending) > 0 {
+		c.limit.pending = append(c.limit.pending, data...)
+		data = c.limit.pending
+		c.limit.pending = nil
+	}
+
+	var (
+		out []byte
+		pos int
+	)
+	for pos < len(data) {
+		p, n, err := readPacket(data[pos:])
+		if err != nil {
+			// Forward the rest untouched: the client's own parser is the
+			// authority on a stream this codec cannot frame.
+			return append(out, data[pos:]...), err
+		}
+		if n == 0 {
+			break // partial packet
+		}
+		raw := data[pos : pos+n]
+		pos += n
+
+		l := &c.limit
+		switch l.state.next(p) {
+		case kindRow:
+			l.rows++
+			l.bytes += int64(n)
+			if !admit(l.rows, l.bytes) {
+				l.dropped = true
+				l.shift += byte(physicalPackets(raw))
+				continue
+			}
+		case kindResultEnd, kindErr:
+			l.rows, l.bytes, l.dropped = 0, 0, false
+		}
+
+		if l.shift != 0 {
+			raw = renumber(raw, l.shift)
+		}
+		if l.state.phase == phaseIdle {
+			l.shift = 0
+		}
+		out = append(out, raw...)
+	}
+
+	if pos < len(data) {
+		c.limit.pending = append(c.limit.pending[:0], data[pos:]...)
+	}
+	return out, nil
+}
+
+// FlushLimit releases a partial packet still held when the connection closes.
+// Inside a result set that has already lost rows, the fragment can only be
+// more of what the limit withheld, and stays dropped.
+func (c *Codec) FlushLimit() []byte {
+	out := c.limit.pending
+	c.limit.pending = nil
+	if c.limit.dropped {
+		return nil
+	}
+	return out
+}
+
+// rowLimit is LimitRows' state. It follows the responses with a
+// responseState of its own, because it runs on its own copy of the stream.
+type rowLimit struct {
+	state responseState
+
+	// rows and bytes count what the server has sent in the current result
+	// set, and dropped whether any of it was withheld.
+	rows    int
+	bytes   int64
+	dropped bool
+
+	// shift is how many physical packets the current response has lost.
+	shift byte
+
+	pending []byte
+}
+
+// physicalPackets counts the packets a logical message spans: more than one
+// when its payload reached 16 MiB and continued.
+func physicalPackets(raw []byte) int {
+	n := 0
+	for pos := 0; pos+headerLen <= len(raw); n++ {
+		pos += headerLen + (int(raw[pos]) | int(raw[pos+1])<<8 | int(raw[pos+2])<<16)
+	}
+	return n
+}
+
+// renumber returns a copy of a logical message with the sequence id of every
+// physical packet in it lowered by shift. A copy, because raw aliases the
+// caller's read buffer.
+func renumber(raw []byte, shift byte) []byte {
+	out := append([]byte(nil), raw...)
+	for pos := 0; pos+headerLen <= len(out); {
+		out[pos+3] -= shift
+		pos += headerLen + (int(out[pos]) | int(out[pos+1])<<8 | int(out[pos+2])<<16)
+	}
+	return out
+}
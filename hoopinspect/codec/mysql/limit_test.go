@@ -0,0 +1,115 @@
+package mysql_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	my "github.com/hoophq/hoopinspect/codec/mysql"
+)
+
+// maxRows admits the first n rows of every result set.
+func maxRows(n int) func(int, int64) bool {
+	return func(rows int, _ int64) bool { return rows <= n }
+}
+
+func limitAll(t *testing.T, stream []byte, admit func(int, int64) bool) []byte {
+	t.Helper()
+	c := &my.Codec{}
+	out, err := c.LimitRows(stream, admit)
+	if err != nil {
+		t.Fatalf("LimitRows: %v", err)
+	}
+	return append(out, c.FlushLimit()...)
+}
+
+// Dropping rows renumbers the rest of the response, so the client still sees
+// sequence ids without a gap.
+func TestLimitRowsRenumbersTheResponse(t *testing.T) {
+	rows := [][][]byte{{str("1")}, {str("2")}, {str("3")}, {str("4")}}
+	stream := append(loggedIn(), resultSet([]string{"id"}, rows, 0)...)
+
+	out := limitAll(t, stream, maxRows(2))
+	want := append(loggedIn(), resultSet([]string{"id"}, rows[:2], 0)...)
+	if !bytes.Equal(out, want) {
+		t.Fatalf("got  %v\nwant %v", sequenceIDs(out), sequenceIDs(want))
+	}
+	stmts := mustReparse(t, out)
+	if len(stmts) != 1 || stmts[0].Result.RowCount != 2 {
+		t.Errorf("reparsed %+v, want one result of 2 rows", stmts)
+	}
+}
+
+// Each result of a multi-statement query is limited on its own, and the
+// renumbering carries across the boundary between them.
+func TestLimitRowsAcrossMultipleResults(t *testing.T) {
+	const moreResults = 0x0008
+	first := resultSet([]string{"a"}, [][][]byte{{str("1")}, {str("2")}}, moreResults)
+	// The second result set continues the first response's sequence.
+	second := packet(byte(len(sequenceIDs(first))+1), []byte{1})
+	seq := byte(len(sequenceIDs(first)) + 1)
+	for _, p := range [][]byte{
+		columnDef(seq+1, "b", typeVarString),
+		eofPacket(seq+2, 0),
+		textRow(seq+3, str("3")),
+		textRow(seq+4, str("4")),
+		eofPacket(seq+5, 0),
+	} {
+		second = append(second, p...)
+	}
+	stream := bytes.Join([][]byte{loggedIn(), first, second}, nil)
+
+	var seen []int
+	out := limitAll(t, stream, func(rows int, _ int64) bool {
+		seen = append(seen, rows)
+		return rows <= 1
+	})
+	if got, want := fmt.Sprint(seen), "[1 2 1 2]"; got != want {
+		t.Errorf("admit saw rows %s, want %s", got, want)
+	}
+
+	ids := sequenceIDs(out[len(loggedIn()):])
+	for i := 1; i < len(ids); i++ {
+		if ids[i] != ids[i-1]+1 {
+			t.Fatalf("sequence ids have a gap: %v", ids)
+		}
+	}
+	if stmts := mustReparse(t, out); len(stmts) != 2 {
+		t.Errorf("reparsed %d results, want 2", len(stmts))
+	}
+}
+
+// The next response starts its sequence over and must not inherit the shift.
+func TestLimitRowsShiftEndsWithTheResponse(t *testing.T) {
+	rows := [][][]byte{{str("1")}, {str("2")}}
+	stream := bytes.Join([][]byte{loggedIn(), resultSet([]string{"id"}, rows, 0), okPacket(1, 0)}, nil)
+
+	out := limitAll(t, stream, maxRows(1))
+	if !bytes.HasSuffix(out, okPacket(1, 0)) {
+		t.Errorf("the next response was renumbered: %v", sequenceIDs(out))
+	}
+}
+
+// The relay's reads have nothing to do with packet boundaries; every split
+// must produce the same bytes.
+func TestLimitRowsSplitReadMatrix(t *testing.T) {
+	rows := [][][]byte{{str("1")}, {str("2")}, {str("3")}}
+	stream := append(loggedIn(), resultSet([]string{"id"}, rows, 0)...)
+	whole := limitAll(t, stream, maxRows(1))
+
+	for cut := 0; cut <= len(stream); cut++ {
+		c := &my.Codec{}
+		var out []byte
+		for _, chunk := range [][]byte{stream[:cut], stream[cut:]} {
+			got, err := c.LimitRows(chunk, maxRows(1))
+			if err != nil {
+				t.Fatalf("cut=%d: %v", cut, err)
+			}
+			out = append(out, got...)
+		}
+		out = append(out, c.FlushLimit()...)
+		if !bytes.Equal(out, whole) {
+			t.Fatalf("cut=%d produced different bytes than the unsplit stream", cut)
+		}
+	}
+}
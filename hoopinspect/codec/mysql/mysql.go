@@ -0,0 +1,671 @@
+// Package mysql decodes the MySQL client/server protocol far enough to recover
+// the SQL a client sent, and to describe and mask the result sets the server
+// sends back. MariaDB speaks the same protocol and is covered by the same
+// decoder.
+//
+// Envoy's MySQL filter parses no SQL at all: it counts logins and reports
+// "a query happened". That leaves MySQL, which is most of a typical fleet,
+// without the statement-level policy the Postgres lane has.
+//
+// Wire format reference:
+// https://dev.mysql.com/doc/dev/mysql-server/latest/PAGE_PROTOCOL.html
+//
+// Every packet carries a 4-byte header:
+//
+//	int<3>  payload length, little-endian
+//	int<1>  sequence id
+//
+// A payload of exactly 0xFFFFFF bytes is continued by the next packet, so a
+// logical message larger than 16 MiB spans several. This decoder joins them
+// before parsing, for the same reason the TDS decoder reassembles: a
+// statement classified from a fragment is a statement classified wrongly.
+//
+// Four commands carry what a policy needs:
+//
+//	0x03 COM_QUERY         the statement text, possibly several statements
+//	                       when the client enabled CLIENT_MULTI_STATEMENTS
+//	0x16 COM_STMT_PREPARE  one statement with '?' placeholders
+//	0x17 COM_STMT_EXECUTE  a statement id; the text was sent at prepare time
+//	0x02 COM_INIT_DB       the schema name, which is what `USE db` becomes
+//	                       in the mysql client
+//
+// # The sequence id is the phase marker
+//
+// Every command a client sends starts a new exchange at sequence id 0, and
+// nothing else it sends does: the login response, authentication
+// continuations and LOCAL INFILE uploads all continue an exchange the server
+// opened, so they carry 1 or more. That makes the client direction decodable
+// without tracking authentication plugins, which change between server
+// versions and which a relay has no business understanding.
+//
+// # TLS belongs to whatever fronts this
+//
+// MySQL negotiates TLS in-band: the server advertises CLIENT_SSL in its
+// greeting and a client that wants TLS answers with a 32-byte SSLRequest
+// before switching the socket over. Everything after that is ciphertext, so
+// this decoder refuses the stream with ErrStreamUnsafe rather than reporting
+// no statements for the life of the connection. The relay in package proxy
+// withdraws the CLIENT_SSL offer from the greeting, so a client that merely
+// prefers TLS stays in plaintext and one that requires it fails with its own
+// error instead of reaching this.
+package mysql
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/lexer"
+)
+
+func init() { hoopinspect.Register(func() hoopinspect.Codec { return &Codec{} }) }
+
+// Command bytes: the first byte of every payload a client sends at sequence
+// id 0. Only the ones this decoder acts on are named.
+const (
+	comInitDB          = 0x02
+	comQuery           = 0x03
+	comStmtPrepare     = 0x16
+	comStmtExecute     = 0x17
+	comStmtClose       = 0x19
+	comResetConnection = 0x1f
+)
+
+// Capability flags from the client's login response.
+// https://dev.mysql.com/doc/dev/mysql-server/latest/group__group__cs__capabilities__flags.html
+const (
+	clientConnectWithDB    uint32 = 0x00000008
+	clientProtocol41       uint32 = 0x00000200
+	clientSSL              uint32 = 0x00000800
+	clientSecureConnection uint32 = 0x00008000
+	clientPluginAuthLenenc uint32 = 0x00200000
+	clientQueryAttributes  uint32 = 0x08000000
+)
+
+const (
+	headerLen = 4
+
+	// maxPacketPayload is the largest payload one physical packet carries.
+	// A packet of exactly this size says "the message continues".
+	maxPacketPayload = 0xFFFFFF
+
+	// sslRequestLen is the size of the SSLRequest payload: the first 32
+	// bytes of a login response, sent alone.
+	sslRequestLen = 32
+
+	// maxMessageLen bounds reassembly of a logical message. The server's own
+	// max_allowed_packet can reach 1 GiB; 64 MiB is where the Postgres codec
+	// stops too, for the same reason: evaluating a statement that large
+	// inline would stall the policy engine, and buffering without a bound
+	// hands a client the relay's memory.
+	maxMessageLen = 64 << 20
+)
+
+// ErrMalformed means the stream is not valid MySQL protocol.
+var ErrMalformed = errors.New("hoopinspect/mysql: malformed packet")
+
+// Codec implements hoopinspect.Codec for MySQL.
+//
+// It is stateful in both directions. Going one way it remembers what the
+// client prepared, because an execute names a statement by id alone; going
+// the other it follows result sets, because a column definition describes
+// every row after it. One Codec per connection PER DIRECTION; the gate builds
+// two, so no field here is visible to the other half of the stream.
+type Codec struct {
+	// loggedIn latches once the client's login response has been read.
+	loggedIn bool
+
+	// flags are the capabilities the client claimed at login. Only
+	// CLIENT_QUERY_ATTRIBUTES changes how a command is laid out.
+	flags uint32
+
+	// database is the schema the client last selected, at login or through
+	// COM_INIT_DB. Every statement carries it.
+	database string
+
+	// prepared maps a statement id to the text it was prepared from.
+	//
+	// The id is assigned by the server, in a reply this half of the stream
+	// never sees, so it is PREDICTED: both MySQL and MariaDB number a
+	// session's prepared statements from 1, consuming an id even when the
+	// prepare fails. A prediction that drifts can only mislabel a statement
+	// that was itself inspected and allowed at prepare time, because a denied
+	// prepare ends the connection before it gets an id.
+	prepared   map[uint32]string
+	lastStmtID uint32
+
+	// Response-decoding state. resp follows the result set currently
+	// streaming; rowCount accumulates across reads until a terminator.
+	resp     responseState
+	rowCount int
+
+	// Rewrite state, independent of the decode state above: Decode inspects,
+	// Rewrite rebuilds, and the two run on independent copies of the stream.
+	//
+	// rw follows the result set the rewriter is masking; pending holds a
+	// trailing partial packet until its remainder arrives.
+	rw      responseState
+	pending []byte
+
+	// limit is LimitRows' state; see rowLimit.
+	limit rowLimit
+}
+
+func (*Codec) Protocol() hoopinspect.Protocol { return hoopinspect.MySQL }
+
+// packet is one logical message: its payload with continuations joined, and
+// the sequence id of its first physical packet.
+type packet struct {
+	seq     byte
+	payload []byte
+}
+
+// readPacket returns the logical message at the start of data and the number
+// of bytes it spans. n == 0 with a nil error means it is not fully buffered.
+//
+// The common case, a message that fits one packet, aliases data rather than
+// copying it.
+func readPacket(data []byte) (p packet, n int, err error) {
+	pos := 0
+	for {
+		if len(data)-pos < headerLen {
+			return packet{}, 0, nil
+		}
+		size := int(data[pos]) | int(data[pos+1])<<8 | int(data[pos+2])<<16
+		if pos == 0 {
+			p.seq = data[pos+3]
+		}
+		if len(p.payload)+size > maxMessageLen {
+			return packet{}, 0, ErrMalformed
+		}
+		end := pos + headerLen + size
+		if end > len(data) {
+			return packet{}, 0, nil
+		}
+		if pos == 0 && size < maxPacketPayload {
+			p.payload = data[headerLen:end]
+			return p, end, nil
+		}
+		p.payload = append(p.payload, data[pos+headerLen:end]...)
+		pos = end
+		if size < maxPacketPayload {
+			return p, pos, nil
+		}
+	}
+}
+
+// Decode implements hoopinspect.Codec.
+//
+// Metadata keys set on returned statements:
+//
+//	"mysql.command"       "COM_QUERY", "COM_STMT_PREPARE", "COM_STMT_EXECUTE"
+//	                      or "COM_INIT_DB"
+//	"mysql.statement_id"  the prepared-statement id, for a prepare or execute
+//
+// Server → client messages yield one statement per completed result set,
+// carrying Statement.Result, and one per ERR packet. See response.go.
+func (c *Codec) Decode(dir hoopinspect.Direction, data []byte) ([]hoopinspect.Statement, int, error) {
+	if dir == hoopinspect.FromServer {
+		return c.decodeResponse(data)
+	}
+
+	var stmts []hoopinspect.Statement
+	pos := 0
+	for pos < len(data) {
+		p, n, err := readPacket(data[pos:])
+		if err != nil {
+			return stmts, pos, err
+		}
+		if n == 0 {
+			return stmts, pos, nil // partial packet, retain it
+		}
+
+		if p.seq != 0 {
+			// Part of an exchange the server opened. The first one a client
+			// ever sends is its login response, which says whether the rest
+			// of the stream will be readable at all.
+			if !c.loggedIn {
+				if err := c.login(p.payload); err != nil {
+					return stmts, pos, err
+				}
+			}
+			pos += n
+			continue
+		}
+
+		// A command. A client that skipped the login response entirely is
+		// not one this decoder will see, but a command is a command.
+		c.loggedIn = true
+		stmts = append(stmts, c.command(p.payload)...)
+		pos += n
+	}
+	return stmts, pos, nil
+}
+
+// login reads the client's first packet: the login response, or an
+// SSLRequest announcing that every later byte will be ciphertext.
+func (c *Codec) login(payload []byte) error {
+	if len(payload) < 4 {
+		return ErrMalformed
+	}
+	flags := binary.LittleEndian.Uint32(payload[:4])
+	if len(payload) == sslRequestLen && flags&clientSSL != 0 {
+		return fmt.Errorf(
+			"%w: the client asked to switch this session to TLS, which would make "+
+				"every later byte unreadable to policy, masking and the audit trail; "+
+				"terminate TLS in front of this decoder, or connect with "+
+				"--ssl-mode=DISABLED (mysql clients upgrade by default whenever "+
+				"the server offers it)",
+			hoopinspect.ErrStreamUnsafe)
+	}
+	c.loggedIn = true
+	if flags&clientProtocol41 == 0 {
+		// Protocol 3.20 predates every server this relay supports. Nothing
+		// in it changes how a command is laid out.
+		return nil
+	}
+	c.flags = flags
+	c.database = loginDatabase(payload, flags)
+	return nil
+}
+
+// loginDatabase returns the schema a HandshakeResponse41 connects to, or ""
+// when it names none or cannot be read.
+//
+// Layout:
+//
+//	int<4>    capability flags
+//	int<4>    max packet size
+//	int<1>    character set
+//	byte<23>  filler
+//	string    username, NUL-terminated
+//	...       auth response: length-encoded, 1-byte length, or
+//	          NUL-terminated, depending on the flags
+//	string    database, NUL-terminated, when CLIENT_CONNECT_WITH_DB
+func loginDatabase(payload []byte, flags uint32) string {
+	if flags&clientConnectWithDB == 0 || len(payload) < sslRequestLen {
+		return ""
+	}
+	rest := payload[sslRequestLen:]
+	i := indexNUL(rest)
+	if i < 0 {
+		return ""
+	}
+	rest = rest[i+1:]
+
+	switch {
+	case flags&clientPluginAuthLenenc != 0:
+		_, n, ok := lenencString(rest)
+		if !ok {
+			return ""
+		}
+		rest = rest[n:]
+	case flags&clientSecureConnection != 0:
+		if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+			return ""
+		}
+		rest = rest[1+int(rest[0]):]
+	default:
+		j := indexNUL(rest)
+		if j < 0 {
+			return ""
+		}
+		rest = rest[j+1:]
+	}
+	return cstring(rest)
+}
+
+// command decodes one client command into the statements it carries.
+func (c *Codec) command(payload []byte) []hoopinspect.Statement {
+	if len(payload) == 0 {
+		return nil
+	}
+	body := payload[1:]
+
+	switch payload[0] {
+	case comQuery:
+		text, ok := queryText(body, c.flags&clientQueryAttributes != 0)
+		if !ok {
+			// The attribute block could not be stepped over, so where the
+			// statement starts is a guess. Report the command without one:
+			// an operation of unknown is what a fail-closed rule keys on.
+			return []hoopinspect.Statement{c.unreadable("COM_QUERY",
+				"query attributes could not be decoded, so the statement text could not be located")}
+		}
+		var stmts []hoopinspect.Statement
+		for _, s := range splitQuery(text) {
+			stmts = append(stmts, c.newStatement(s, "COM_QUERY", ""))
+		}
+		return stmts
+
+	case comStmtPrepare:
+		c.lastStmtID++
+		id := c.lastStmtID
+		text := string(body)
+		if c.prepared == nil {
+			c.prepared = map[uint32]string{}
+		}
+		c.prepared[id] = text
+		return []hoopinspect.Statement{c.newStatement(text, "COM_STMT_PREPARE", formatID(id))}
+
+	case comStmtExecute:
+		if len(body) < 4 {
+			return []hoopinspect.Statement{c.unreadable("COM_STMT_EXECUTE",
+				"the execute is too short to name a statement")}
+		}
+		id := binary.LittleEndian.Uint32(body[:4])
+		text, ok := c.prepared[id]
+		if !ok {
+			st := c.unreadable("COM_STMT_EXECUTE",
+				"the execute names a statement id this connection was never seen preparing")
+			st.Metadata["mysql.statement_id"] = formatID(id)
+			return []hoopinspect.Statement{st}
+		}
+		return []hoopinspect.Statement{c.newStatement(text, "COM_STMT_EXECUTE", formatID(id))}
+
+	case comStmtClose:
+		if len(body) >= 4 {
+			delete(c.prepared, binary.LittleEndian.Uint32(body[:4]))
+		}
+
+	case comResetConnection:
+		// The server deallocates every prepared statement. It does not
+		// rewind the id counter, so neither does this.
+		c.prepared = nil
+
+	case comInitDB:
+		c.database = string(body)
+		return []hoopinspect.Statement{c.initDB(c.database)}
+	}
+	return nil
+}
+
+// initDB reports a schema switch.
+//
+// The protocol carries only the name. The text is rendered as the USE
+// statement the mysql client turned into this command, so the audit trail
+// reads the same whichever way the switch was spelled.
+func (c *Codec) initDB(name string) hoopinspect.Statement {
+	return hoopinspect.Statement{
+		Protocol:  hoopinspect.MySQL,
+		Direction: hoopinspect.FromClient,
+		Text:      "USE `" + strings.ReplaceAll(name, "`", "``") + "`",
+		Operation: hoopinspect.OpOther,
+		Database:  name,
+		Metadata:  map[string]string{"mysql.command": "COM_INIT_DB"},
+	}
+}
+
+// splitQuery breaks a COM_QUERY payload on top-level semicolons.
+//
+// Policy depends on the split: with CLIENT_MULTI_STATEMENTS on,
+// `SELECT 1; DROP TABLE users` is ONE command, and classifying it by its
+// leading verb would wave the DROP through.
+//
+// The split runs under MySQL's own lexical rules. Backtick identifiers, #
+// comments, backslash escapes and the live body of a /*! */ comment all
+// decide where a semicolon stands, and Postgres rules get each of them wrong:
+// `SELECT 1 # ;` has no second statement, and `/*!50001 ; DROP TABLE t */`
+// does.
+func splitQuery(q string) []string {
+	return lexer.Split(q, lexer.MySQL)
+}
+
+func (c *Codec) newStatement(text, command, stmtID string) hoopinspect.Statement {
+	a := hoopinspect.AnalyzeSQL(text, hoopinspect.MySQL)
+	md := map[string]string{"mysql.command": command}
+	if stmtID != "" {
+		md["mysql.statement_id"] = stmtID
+	}
+	if !a.Complete {
+		md[hoopinspect.MetadataSQLIncomplete] = a.Reason
+	} else if !a.ColumnsComplete {
+		md[hoopinspect.MetadataColumnsIncomplete] = a.ColumnsReason
+	}
+	return hoopinspect.Statement{
+		Protocol:        hoopinspect.MySQL,
+		Direction:       hoopinspect.FromClient,
+		Text:            text,
+		Operation:       a.Operation,
+		Effects:         a.Effects,
+		Relations:       a.Relations,
+		Tables:          a.Tables,
+		UnboundedWrites: a.UnboundedWrites,
+		Database:        c.database,
+		Metadata:        md,
+	}
+}
+
+// unreadable reports a command whose statement could not be recovered. The
+// operation is OpUnknown and the reason travels the same way a lexer's does,
+// so one fail-closed rule covers both.
+func (c *Codec) unreadable(command, reason string) hoopinspect.Statement {
+	return hoopinspect.Statement{
+		Protocol:  hoopinspect.MySQL,
+		Direction: hoopinspect.FromClient,
+		Operation: hoopinspect.OpUnknown,
+		Database:  c.database,
+		Metadata: map[string]string{
+			"mysql.command":                   command,
+			hoopinspect.MetadataSQLIncomplete: reason,
+		},
+	}
+}
+
+// queryText returns the statement in a COM_QUERY body.
+//
+// With CLIENT_QUERY_ATTRIBUTES negotiated (MySQL 8.0.23+), the statement is
+// preceded by a block of named, typed values:
+//
+//	int<lenenc>  parameter count
+//	int<lenenc>  parameter set count, always 1
+//	if parameter count > 0:
+//	  byte<n>    NULL bitmap, (count+7)/8 bytes
+//	  int<1>     new-params-bind flag, always 1
+//	  per parameter: int<2> type, string<lenenc> name
+//	  per non-NULL parameter: the value, in the binary protocol encoding
+//	string<EOF>  the statement
+//
+// Every value has to be stepped over by its type to find where the statement
+// starts, so a type this decoder cannot size makes the text unrecoverable.
+func queryText(body []byte, attrs bool) (string, bool) {
+	if !attrs {
+		return string(body), true
+	}
+	count, n, ok := lenencInt(body)
+	if !ok {
+		return "", false
+	}
+	pos := n
+	if _, n, ok = lenencInt(body[pos:]); !ok {
+		return "", false
+	}
+	pos += n
+	if count == 0 {
+		return string(body[pos:]), true
+	}
+	if count > uint64(len(body)) {
+		return "", false // each parameter costs at least a byte
+	}
+
+	bitmapLen := int(count+7) / 8
+	if pos+bitmapLen+1 > len(body) {
+		return "", false
+	}
+	bitmap := body[pos : pos+bitmapLen]
+	pos += bitmapLen
+	if body[pos] != 1 {
+		return "", false // types not sent, so values cannot be sized
+	}
+	pos++
+
+	types := make([]byte, count)
+	for i := range types {
+		if pos+2 > len(body) {
+			return "", false
+		}
+		types[i] = body[pos]
+		pos += 2
+		_, n, ok := lenencString(body[pos:])
+		if !ok {
+			return "", false
+		}
+		pos += n
+	}
+	for i, typ := range types {
+		if bitmap[i/8]&(1<<(i%8)) != 0 {
+			continue // NULL, no value on the wire
+		}
+		n, ok := skipBinaryValue(typ, body[pos:])
+		if !ok {
+			return "", false
+		}
+		pos += n
+	}
+	return string(body[pos:]), true
+}
+
+// Column types, as they appear in a column definition and in the binary
+// protocol. https://dev.mysql.com/doc/dev/mysql-server/latest/field__types_8h.html
+const (
+	typeDecimal    = 0x00
+	typeTiny       = 0x01
+	typeShort      = 0x02
+	typeLong       = 0x03
+	typeFloat      = 0x04
+	typeDouble     = 0x05
+	typeNull       = 0x06
+	typeTimestamp  = 0x07
+	typeLongLong   = 0x08
+	typeInt24      = 0x09
+	typeDate       = 0x0a
+	typeTime       = 0x0b
+	typeDateTime   = 0x0c
+	typeYear       = 0x0d
+	typeVarChar    = 0x0f
+	typeBit        = 0x10
+	typeJSON       = 0xf5
+	typeNewDecimal = 0xf6
+	typeGeometry   = 0xff
+)
+
+// skipBinaryValue returns the size of one binary-protocol value of the given
+// type at the start of b.
+//
+// Integers and floats are fixed-width, temporal values carry a 1-byte length,
+// and everything else (strings, blobs, decimals, JSON, geometry) is a
+// length-encoded string.
+func skipBinaryValue(typ byte, b []byte) (int, bool) {
+	var n int
+	switch typ {
+	case typeNull:
+		return 0, true
+	case typeTiny:
+		n = 1
+	case typeShort, typeYear:
+		n = 2
+	case typeLong, typeInt24, typeFloat:
+		n = 4
+	case typeLongLong, typeDouble:
+		n = 8
+	case typeTimestamp, typeDate, typeTime, typeDateTime:
+		if len(b) < 1 {
+			return 0, false
+		}
+		n = 1 + int(b[0])
+	case typeDecimal, typeVarChar, typeBit, typeJSON, typeNewDecimal:
+		_, n, ok := lenencString(b)
+		return n, ok
+	default:
+		// 0xf7..0xfe are ENUM, SET, the blob family and the string types;
+		// 0xff is GEOMETRY. All length-encoded.
+		if typ < 0xf7 {
+			return 0, false
+		}
+		_, n, ok := lenencString(b)
+		return n, ok
+	}
+	if n > len(b) {
+		return 0, false
+	}
+	return n, true
+}
+
+// lenencInt decodes a length-encoded integer.
+//
+// 0xFB and 0xFF are not integers: the first marks a NULL cell in a text row
+// and the second opens an ERR packet, so a caller reaching either here is
+// reading the wrong thing.
+func lenencInt(b []byte) (v uint64, n int, ok bool) {
+	if len(b) == 0 {
+		return 0, 0, false
+	}
+	switch b[0] {
+	case 0xfc:
+		n = 3
+	case 0xfd:
+		n = 4
+	case 0xfe:
+		n = 9
+	case 0xfb, 0xff:
+		return 0, 0, false
+	default:
+		return uint64(b[0]), 1, true
+	}
+	if len(b) < n {
+		return 0, 0, false
+	}
+	for i := n - 1; i >= 1; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, n, true
+}
+
+// lenencString decodes a length-encoded string, returning its bytes and the
+// total size including the length prefix.
+func lenencString(b []byte) ([]byte, int, bool) {
+	l, n, ok := lenencInt(b)
+	if !ok || l > uint64(len(b)-n) {
+		return nil, 0, false
+	}
+	end := n + int(l)
+	return b[n:end], end, true
+}
+
+// appendLenenc appends a length-encoded integer.
+func appendLenenc(b []byte, v uint64) []byte {
+	switch {
+	case v < 0xfb:
+		return append(b, byte(v))
+	case v < 1<<16:
+		return append(b, 0xfc, byte(v), byte(v>>8))
+	case v < 1<<24:
+		return append(b, 0xfd, byte(v), byte(v>>8), byte(v>>16))
+	default:
+		b = append(b, 0xfe)
+		return binary.LittleEndian.AppendUint64(b, v)
+	}
+}
+
+func formatID(id uint32) string { return strconv.FormatUint(uint64(id), 10) }
+
+// cstring returns the bytes up to the first NUL, or the whole slice when
+// unterminated.
+func cstring(b []byte) string {
+	if i := indexNUL(b); i >= 0 {
+		return string(b[:i])
+	}
+	return string(b)
+}
+
+func indexNUL(b []byte) int {
+	for i := range b {
+		if b[i] == 0 {
+			return i
+		}
+	}
+	return -1
+}
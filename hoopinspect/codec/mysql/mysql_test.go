@@ -0,0 +1,347 @@
+package mysql_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+	_ "github.com/hoophq/hoopinspect/codec/mysql"
+)
+
+// packet frames a payload with the 4-byte MySQL header.
+func packet(seq byte, payload []byte) []byte {
+	n := len(payload)
+	return append([]byte{byte(n), byte(n >> 8), byte(n >> 16), seq}, payload...)
+}
+
+func command(cmd byte, body []byte) []byte { return packet(0, append([]byte{cmd}, body...)) }
+
+func comQuery(sql string) []byte   { return command(0x03, []byte(sql)) }
+func comPrepare(sql string) []byte { return command(0x16, []byte(sql)) }
+func comInitDB(db string) []byte   { return command(0x02, []byte(db)) }
+
+func comExecute(id uint32) []byte {
+	body := binary.LittleEndian.AppendUint32(nil, id)
+	body = append(body, 0)                           // flags
+	body = binary.LittleEndian.AppendUint32(body, 1) // iteration count
+	return command(0x17, body)
+}
+
+const (
+	capConnectWithDB    = 0x00000008
+	capProtocol41       = 0x00000200
+	capSSL              = 0x00000800
+	capSecureConnection = 0x00008000
+	capPluginAuthLenenc = 0x00200000
+	capQueryAttributes  = 0x08000000
+)
+
+// loginResponse builds a HandshakeResponse41 naming a schema.
+func loginResponse(flags uint32, user, db string) []byte {
+	flags |= capProtocol41 | capSecureConnection | capPluginAuthLenenc
+	if db != "" {
+		flags |= capConnectWithDB
+	}
+	var b []byte
+	b = binary.LittleEndian.AppendUint32(b, flags)
+	b = binary.LittleEndian.AppendUint32(b, 1<<24) // max packet
+	b = append(b, 0xff)                            // charset
+	b = append(b, make([]byte, 23)...)
+	b = append(b, user...)
+	b = append(b, 0)
+	b = append(b, 20)
+	b = append(b, bytes.Repeat([]byte{0xaa}, 20)...) // scrambled password
+	if db != "" {
+		b = append(b, db...)
+		b = append(b, 0)
+	}
+	b = append(b, "caching_sha2_password\x00"...)
+	return packet(1, b)
+}
+
+func sslRequest() []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint32(b, capProtocol41|capSSL)
+	b = binary.LittleEndian.AppendUint32(b, 1<<24)
+	b = append(b, 0xff)
+	b = append(b, make([]byte, 23)...)
+	return packet(1, b)
+}
+
+func newInspector(t *testing.T) *hoopinspect.Inspector {
+	t.Helper()
+	i, err := hoopinspect.New(hoopinspect.MySQL)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return i
+}
+
+func inspectClient(t *testing.T, stream []byte) []hoopinspect.Statement {
+	t.Helper()
+	stmts, err := newInspector(t).Inspect(hoopinspect.FromClient, stream)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	return stmts
+}
+
+func TestComQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		wantOp  hoopinspect.Operation
+		wantTbl []string
+	}{
+		{"select", "SELECT name FROM customers", hoopinspect.OpSelect, []string{"customers"}},
+		{"delete", "DELETE FROM customers WHERE id = 1", hoopinspect.OpDelete, []string{"customers"}},
+		{"insert", "INSERT INTO orders (id) VALUES (1)", hoopinspect.OpInsert, []string{"orders"}},
+		{"update", "UPDATE accounts SET x = 1", hoopinspect.OpUpdate, []string{"accounts"}},
+		{"drop", "DROP TABLE customers", hoopinspect.OpDrop, []string{"customers"}},
+		{"schema qualified", "SELECT * FROM appdb.customers", hoopinspect.OpSelect, []string{"appdb.customers"}},
+		{"backticks", "DELETE FROM `appdb`.`customers` WHERE id = 1", hoopinspect.OpDelete, []string{"appdb.customers"}},
+		{"backslash escape", `INSERT INTO users (name) VALUES ('O\'Brien')`, hoopinspect.OpInsert, []string{"users"}},
+		{"executable comment", "/*!50001 DROP TABLE customers */", hoopinspect.OpDrop, []string{"customers"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stmts := inspectClient(t, comQuery(tc.sql))
+			if len(stmts) != 1 {
+				t.Fatalf("got %d statements, want 1: %+v", len(stmts), stmts)
+			}
+			s := stmts[0]
+			if s.Text != tc.sql || s.Operation != tc.wantOp || !equalStrings(s.Tables, tc.wantTbl) {
+				t.Errorf("got %q %s %v, want %q %s %v",
+					s.Text, s.Operation, s.Tables, tc.sql, tc.wantOp, tc.wantTbl)
+			}
+			if s.Protocol != hoopinspect.MySQL || s.Metadata["mysql.command"] != "COM_QUERY" {
+				t.Errorf("protocol %q, metadata %v", s.Protocol, s.Metadata)
+			}
+		})
+	}
+}
+
+// With CLIENT_MULTI_STATEMENTS one COM_QUERY carries several statements, and
+// each must get its own verdict.
+func TestMultiStatementSplit(t *testing.T) {
+	stmts := inspectClient(t, comQuery("SELECT 1; DROP TABLE users"))
+	if len(stmts) != 2 {
+		t.Fatalf("got %d statements, want 2: %+v", len(stmts), stmts)
+	}
+	if stmts[1].Operation != hoopinspect.OpDrop {
+		t.Errorf("second statement = %s, want drop", stmts[1].Operation)
+	}
+}
+
+// The split follows MySQL's lexical rules: a semicolon in a # comment
+// separates nothing, and one after a minus-minus with no space does.
+func TestMultiStatementSplitUsesMySQLRules(t *testing.T) {
+	if stmts := inspectClient(t, comQuery("SELECT 1 # ; DROP TABLE users")); len(stmts) != 1 {
+		t.Errorf("a # comment was split: %+v", stmts)
+	}
+	stmts := inspectClient(t, comQuery("SELECT 1--1; DROP TABLE users"))
+	if len(stmts) != 2 || stmts[1].Operation != hoopinspect.OpDrop {
+		t.Errorf("--1 hid the statement after it: %+v", stmts)
+	}
+}
+
+// An execute carries only an id. It must be reported with the text that id
+// was prepared from, or every prepared statement is invisible to policy.
+func TestExecuteCarriesThePreparedText(t *testing.T) {
+	stream := bytes.Join([][]byte{
+		loginResponse(0, "app", ""),
+		comPrepare("SELECT * FROM customers WHERE id = ?"),
+		comPrepare("DELETE FROM sessions WHERE id = ?"),
+		comExecute(2),
+		comExecute(1),
+	}, nil)
+
+	stmts := inspectClient(t, stream)
+	if len(stmts) != 4 {
+		t.Fatalf("got %d statements, want 4: %+v", len(stmts), stmts)
+	}
+	want := []struct {
+		cmd, id string
+		op      hoopinspect.Operation
+	}{
+		{"COM_STMT_PREPARE", "1", hoopinspect.OpSelect},
+		{"COM_STMT_PREPARE", "2", hoopinspect.OpDelete},
+		{"COM_STMT_EXECUTE", "2", hoopinspect.OpDelete},
+		{"COM_STMT_EXECUTE", "1", hoopinspect.OpSelect},
+	}
+	for i, w := range want {
+		s := stmts[i]
+		if s.Metadata["mysql.command"] != w.cmd || s.Metadata["mysql.statement_id"] != w.id || s.Operation != w.op {
+			t.Errorf("stmt %d = %s %v, want %s id=%s %s", i, s.Operation, s.Metadata, w.cmd, w.id, w.op)
+		}
+	}
+}
+
+// An id this connection never prepared cannot be classified. It must fail
+// closed as unknown rather than pass as nothing.
+func TestExecuteOfAnUnknownIDIsUnknown(t *testing.T) {
+	stmts := inspectClient(t, comExecute(9))
+	if len(stmts) != 1 || stmts[0].Operation != hoopinspect.OpUnknown {
+		t.Fatalf("got %+v, want one unknown statement", stmts)
+	}
+	if stmts[0].Metadata[hoopinspect.MetadataSQLIncomplete] == "" {
+		t.Error("no reason recorded for the unknown execute")
+	}
+}
+
+// A closed statement's id no longer resolves.
+func TestStmtCloseForgetsTheText(t *testing.T) {
+	closeStmt := command(0x19, binary.LittleEndian.AppendUint32(nil, 1))
+	stmts := inspectClient(t, bytes.Join([][]byte{
+		comPrepare("SELECT 1"), closeStmt, comExecute(1),
+	}, nil))
+	if len(stmts) != 2 || stmts[1].Operation != hoopinspect.OpUnknown {
+		t.Fatalf("execute after close = %+v", stmts)
+	}
+}
+
+// The schema named at login and by COM_INIT_DB rides on every statement.
+func TestDatabaseFollowsLoginAndInitDB(t *testing.T) {
+	stmts := inspectClient(t, bytes.Join([][]byte{
+		loginResponse(0, "app", "appdb"),
+		comQuery("SELECT 1"),
+		comInitDB("billing"),
+		comQuery("SELECT 2"),
+	}, nil))
+	if len(stmts) != 3 {
+		t.Fatalf("got %d statements, want 3: %+v", len(stmts), stmts)
+	}
+	if stmts[0].Database != "appdb" {
+		t.Errorf("database after login = %q", stmts[0].Database)
+	}
+	if stmts[1].Text != "USE `billing`" || stmts[1].Metadata["mysql.command"] != "COM_INIT_DB" {
+		t.Errorf("COM_INIT_DB reported as %q %v", stmts[1].Text, stmts[1].Metadata)
+	}
+	if stmts[2].Database != "billing" {
+		t.Errorf("database after COM_INIT_DB = %q", stmts[2].Database)
+	}
+}
+
+// MySQL 8.0.23+ puts typed attributes ahead of the statement. The text must
+// be found past them, not read from the first byte of the body.
+func TestQueryAttributesAreSkipped(t *testing.T) {
+	var body []byte
+	body = append(body, 2, 1)       // two parameters, one set
+	body = append(body, 0)          // NULL bitmap: neither is NULL
+	body = append(body, 1)          // new params bind flag
+	body = append(body, 0x08, 0, 5) // LONGLONG, named...
+	body = append(body, "tx_id"...)
+	body = append(body, 0xfd, 0, 3) // VAR_STRING, named...
+	body = append(body, "app"...)
+	body = binary.LittleEndian.AppendUint64(body, 42) // LONGLONG
+	body = append(body, 5)
+	body = append(body, "batch"...) // VAR_STRING
+	body = append(body, "DELETE FROM customers WHERE id = 1"...)
+
+	stream := append(loginResponse(capQueryAttributes, "app", ""), command(0x03, body)...)
+	stmts := inspectClient(t, stream)
+	if len(stmts) != 1 || stmts[0].Text != "DELETE FROM customers WHERE id = 1" {
+		t.Fatalf("got %+v", stmts)
+	}
+	if stmts[0].Operation != hoopinspect.OpDelete {
+		t.Errorf("Operation = %s", stmts[0].Operation)
+	}
+}
+
+// An attribute type the decoder cannot size leaves the statement's start
+// unknown. Guessing would classify a fragment; unknown fails closed.
+func TestUnreadableQueryAttributesFailClosed(t *testing.T) {
+	body := []byte{1, 1, 0, 1, 0x42, 0, 1, 'x', 9, 9, 9}
+	body = append(body, "DROP TABLE t"...)
+	stream := append(loginResponse(capQueryAttributes, "app", ""), command(0x03, body)...)
+
+	stmts := inspectClient(t, stream)
+	if len(stmts) != 1 || stmts[0].Operation != hoopinspect.OpUnknown {
+		t.Fatalf("got %+v, want one unknown statement", stmts)
+	}
+}
+
+// An SSLRequest means every later byte is ciphertext. Going quietly blind
+// would report a healthy lane that enforces nothing.
+func TestSSLRequestIsRefused(t *testing.T) {
+	_, err := newInspector(t).Inspect(hoopinspect.FromClient, sslRequest())
+	if !errors.Is(err, hoopinspect.ErrStreamUnsafe) {
+		t.Fatalf("err = %v, want ErrStreamUnsafe", err)
+	}
+}
+
+// Authentication continuations and LOCAL INFILE data carry a non-zero
+// sequence id and must never be read as commands, however they begin.
+func TestContinuationPacketsAreNotCommands(t *testing.T) {
+	stream := bytes.Join([][]byte{
+		loginResponse(0, "app", ""),
+		packet(3, []byte("\x03DROP TABLE looks_like_a_query")),
+		comQuery("SELECT 1"),
+	}, nil)
+	stmts := inspectClient(t, stream)
+	if len(stmts) != 1 || stmts[0].Text != "SELECT 1" {
+		t.Fatalf("got %+v", stmts)
+	}
+}
+
+// TCP delivers bytes, not packets. Every split must yield the same result.
+func TestSplitAcrossReads(t *testing.T) {
+	stream := bytes.Join([][]byte{
+		loginResponse(0, "app", "appdb"),
+		comQuery("SELECT 1; DELETE FROM t WHERE id = 1"),
+		comPrepare("UPDATE t SET x = ? WHERE id = ?"),
+		comExecute(1),
+	}, nil)
+
+	for cut := 0; cut <= len(stream); cut++ {
+		insp := newInspector(t)
+		var got []hoopinspect.Statement
+		for _, chunk := range [][]byte{stream[:cut], stream[cut:]} {
+			stmts, err := insp.Inspect(hoopinspect.FromClient, chunk)
+			if err != nil {
+				t.Fatalf("cut=%d: %v", cut, err)
+			}
+			got = append(got, stmts...)
+		}
+		if len(got) != 4 || got[3].Operation != hoopinspect.OpUpdate || got[3].Database != "appdb" {
+			t.Fatalf("cut=%d: got %+v", cut, got)
+		}
+	}
+}
+
+// A statement larger than one packet arrives as several, and must be
+// classified whole.
+func TestMultiPacketStatementIsReassembled(t *testing.T) {
+	sql := "SELECT '" + strings.Repeat("x", 0xFFFFFF) + "'; DROP TABLE users"
+	payload := append([]byte{0x03}, sql...)
+
+	var stream []byte
+	seq := byte(0)
+	for len(payload) >= 0xFFFFFF {
+		stream = append(stream, packet(seq, payload[:0xFFFFFF])...)
+		payload = payload[0xFFFFFF:]
+		seq++
+	}
+	stream = append(stream, packet(seq, payload)...)
+
+	stmts := inspectClient(t, stream)
+	if len(stmts) != 2 || stmts[1].Operation != hoopinspect.OpDrop {
+		t.Fatalf("got %d statements; the DROP after the first packet was missed", len(stmts))
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
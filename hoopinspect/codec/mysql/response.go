@@ -0,0 +1,390 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"strconv"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// First bytes of the server packets that are not rows.
+//
+// Wire format reference:
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_basic_response_packets.html
+const (
+	respOK     = 0x00
+	respInfile = 0xfb // LOCAL INFILE request
+	respEOF    = 0xfe
+	respErr    = 0xff
+
+	// eofPacketLen is the size of a 4.1 EOF payload: header, warnings,
+	// status. An OK packet sent in its place (CLIENT_DEPRECATE_EOF) is never
+	// shorter than 7, which is how the two are told apart.
+	eofPacketLen = 5
+
+	// serverMoreResultsExists is the status flag saying another result set
+	// follows this one, from a multi-statement query or a procedure call.
+	serverMoreResultsExists = 0x0008
+
+	// maxColumns is MySQL's own limit on a table's width, and so on a result
+	// set's. A larger count is not a column count.
+	maxColumns = 4096
+)
+
+// phase is where a response stream stands.
+type phase int
+
+const (
+	// phaseLogin covers the server greeting and the authentication exchange,
+	// which end at the first OK.
+	phaseLogin phase = iota
+
+	// phaseIdle is between responses. Only a packet at sequence id 1 can
+	// start one, because the command it answers was sent at 0.
+	phaseIdle
+
+	// phaseMore is between the result sets of one response: the previous
+	// terminator said more follow, so the next packet starts one whatever
+	// its sequence id.
+	phaseMore
+
+	// phaseColumns reads column definitions; phaseColumnsEnd expects the EOF
+	// that closes them on a server not using CLIENT_DEPRECATE_EOF; phaseRows
+	// reads rows until a terminator.
+	phaseColumns
+	phaseColumnsEnd
+	phaseRows
+)
+
+// kind is what one server packet turned out to be.
+type kind int
+
+const (
+	kindOther     kind = iota // nothing to account for
+	kindRow                   // a row of the current result set
+	kindResultEnd             // the terminator of a result set
+	kindErr                   // an ERR packet, ending whatever was in progress
+)
+
+// responseState follows the server's side of the conversation without seeing
+// the client's.
+//
+// # Why the requests are not needed
+//
+// The gate hands each direction its own codec, so this half never learns
+// which command a response answers. It does not have to. Every response
+// opens at sequence id 1, and its first byte says what it is: OK, ERR, a
+// LOCAL INFILE request, or a column count. A column count can be told from
+// everything else by its size, since it is the whole payload. The only
+// responses this misreads are COM_STMT_PREPARE's, whose column and parameter
+// definitions follow an OK-shaped header, and those are skipped by the rule
+// that only sequence id 1 starts a response.
+//
+// Within a result set the sequence id is no help, because it wraps at 256 on
+// any result set of real size. Rows end at a terminator, recognized by its
+// first byte.
+type responseState struct {
+	phase phase
+
+	// count is the column count the current result set announced, and
+	// columns the definitions read so far. count may exceed len(columns)
+	// when the client asked for result sets without metadata.
+	count   int
+	columns []hoopinspect.Column
+
+	// rows is how the current result set encodes its rows, learned from the
+	// rows themselves. See rowFormat.
+	rows rowEncoding
+}
+
+// next advances the state by one packet and reports what the packet was.
+func (s *responseState) next(p packet) kind {
+	payload := p.payload
+	switch s.phase {
+	case phaseLogin:
+		if len(payload) > 0 {
+			switch payload[0] {
+			case respOK:
+				s.phase = phaseIdle
+			case respErr:
+				return kindErr
+			}
+		}
+		return kindOther
+
+	case phaseIdle:
+		if p.seq != 1 {
+			// A continuation of a response this state does not follow: the
+			// definitions after COM_STMT_PREPARE's reply, or the OK that
+			// closes a LOCAL INFILE upload.
+			return kindOther
+		}
+		return s.begin(payload)
+
+	case phaseMore:
+		return s.begin(payload)
+
+	case phaseColumns:
+		col, ok := columnDefinition(payload)
+		if !ok {
+			// Not the definition a count promised, so the count was never
+			// one. Treat the packet as the start of whatever it is.
+			s.phase = phaseIdle
+			return s.next(p)
+		}
+		s.columns = append(s.columns, col)
+		if len(s.columns) == s.count {
+			s.phase = phaseColumnsEnd
+		}
+		return kindOther
+
+	case phaseColumnsEnd:
+		s.phase = phaseRows
+		if len(payload) == eofPacketLen && payload[0] == respEOF {
+			return kindOther
+		}
+		// CLIENT_DEPRECATE_EOF: the first row, or the terminator of an
+		// empty result set, follows the definitions directly.
+		return s.next(p)
+
+	case phaseRows:
+		if len(payload) == 0 {
+			return kindRow // not a legal row, but it is where a row belongs
+		}
+		switch {
+		case payload[0] == respEOF && len(payload) < maxPacketPayload:
+			// A text row starting 0xFE would hold a cell of 16 MiB or more,
+			// so its payload could not be this short.
+			s.end(terminatorStatus(payload))
+			return kindResultEnd
+		case payload[0] == respErr:
+			s.phase = phaseIdle
+			return kindErr
+		}
+		return kindRow
+	}
+	return kindOther
+}
+
+// begin reads the first packet of a response.
+func (s *responseState) begin(payload []byte) kind {
+	s.count, s.columns, s.rows = 0, nil, rowsUnknown
+	s.phase = phaseIdle
+	if len(payload) == 0 {
+		return kindOther
+	}
+
+	switch payload[0] {
+	case respOK:
+		s.end(okStatus(payload))
+		return kindOther
+	case respErr:
+		return kindErr
+	case respInfile:
+		return kindOther
+	case respEOF:
+		// An EOF is the whole reply to a few housekeeping commands. Anything
+		// longer is an auth switch, which COM_CHANGE_USER opens: the session
+		// is logging in again.
+		if len(payload) > eofPacketLen {
+			s.phase = phaseLogin
+		}
+		return kindOther
+	}
+
+	n, size, ok := lenencInt(payload)
+	if !ok || n == 0 || n > maxColumns {
+		return kindOther
+	}
+	switch len(payload) - size {
+	case 0:
+		s.phase = phaseColumns
+	case 1:
+		// CLIENT_OPTIONAL_RESULTSET_METADATA appends a flag: 0 means the
+		// definitions were left out and the rows follow at once.
+		if payload[size] == 0 {
+			s.phase = phaseRows
+		} else {
+			s.phase = phaseColumns
+		}
+	default:
+		// A column count is the whole payload. Anything longer is a reply
+		// this state does not model, such as COM_STATISTICS's text.
+		return kindOther
+	}
+	s.count = int(n)
+	s.columns = make([]hoopinspect.Column, 0, n)
+	return kindOther
+}
+
+// end closes a result set or a bare OK, keeping the state open for the next
+// result set when the status says one follows.
+func (s *responseState) end(status uint16) {
+	if status&serverMoreResultsExists != 0 {
+		s.phase = phaseMore
+	} else {
+		s.phase = phaseIdle
+	}
+}
+
+// terminatorStatus returns the status flags of the packet that ends a result
+// set: an EOF, or on a CLIENT_DEPRECATE_EOF session an OK with the 0xFE
+// header.
+func terminatorStatus(payload []byte) uint16 {
+	if len(payload) == eofPacketLen {
+		return binary.LittleEndian.Uint16(payload[3:5])
+	}
+	return okStatus(payload)
+}
+
+// terminatorName names the packet that ended a result set, for the audit
+// trail: "EOF", or "OK" on a CLIENT_DEPRECATE_EOF session.
+func terminatorName(payload []byte) string {
+	if len(payload) == eofPacketLen {
+		return "EOF"
+	}
+	return "OK"
+}
+
+// okStatus returns the status flags of an OK packet:
+//
+//	int<1>       header, 0x00 or 0xFE
+//	int<lenenc>  affected rows
+//	int<lenenc>  last insert id
+//	int<2>       status flags
+//	int<2>       warnings
+func okStatus(payload []byte) uint16 {
+	pos := 1
+	for i := 0; i < 2; i++ {
+		_, n, ok := lenencInt(payload[min(pos, len(payload)):])
+		if !ok {
+			return 0
+		}
+		pos += n
+	}
+	if pos+2 > len(payload) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(payload[pos : pos+2])
+}
+
+// columnDefinition parses a ColumnDefinition41:
+//
+//	string<lenenc>  catalog, always "def"
+//	string<lenenc>  schema
+//	string<lenenc>  table alias
+//	string<lenenc>  table
+//	string<lenenc>  column alias
+//	string<lenenc>  column
+//	int<lenenc>     length of the fixed fields, 0x0c
+//	int<2>          character set
+//	int<4>          column length
+//	int<1>          type
+//	int<2>          flags
+//	int<1>          decimals
+//
+// The name reported is the alias, which is what the client labels the column
+// with and what a masking rule written against the result set expects.
+func columnDefinition(payload []byte) (hoopinspect.Column, bool) {
+	var fields [6][]byte
+	pos := 0
+	for i := range fields {
+		f, n, ok := lenencString(payload[pos:])
+		if !ok {
+			return hoopinspect.Column{}, false
+		}
+		fields[i] = f
+		pos += n
+	}
+	if string(fields[0]) != "def" {
+		return hoopinspect.Column{}, false
+	}
+	fixed, n, ok := lenencInt(payload[pos:])
+	if !ok || fixed < 0x0c || pos+n+int(fixed) > len(payload) {
+		return hoopinspect.Column{}, false
+	}
+	pos += n
+	return hoopinspect.Column{
+		Name:        string(fields[4]),
+		DataTypeOID: uint32(payload[pos+6]),
+	}, true
+}
+
+// errorMessage returns the message and error code of an ERR packet:
+//
+//	int<1>     0xFF
+//	int<2>     error code
+//	string[1]  '#', then string[5] SQLSTATE, on a 4.1 session
+//	string     message
+func errorMessage(payload []byte) (msg string, code uint16) {
+	if len(payload) < 3 {
+		return "", 0
+	}
+	code = binary.LittleEndian.Uint16(payload[1:3])
+	rest := payload[3:]
+	if len(rest) >= 6 && rest[0] == '#' {
+		rest = rest[6:]
+	}
+	return string(rest), code
+}
+
+// decodeResponse parses server packets, returning one Statement per
+// completed result set and one per ERR packet.
+//
+// Rows seen before a terminator accumulate on the codec, so a result set
+// split across several reads still yields exactly one Statement.
+func (c *Codec) decodeResponse(data []byte) ([]hoopinspect.Statement, int, error) {
+	var stmts []hoopinspect.Statement
+	pos := 0
+	for pos < len(data) {
+		p, n, err := readPacket(data[pos:])
+		if err != nil {
+			return stmts, pos, err
+		}
+		if n == 0 {
+			return stmts, pos, nil // partial packet, retain it
+		}
+
+		switch c.resp.next(p) {
+		case kindRow:
+			c.rowCount++
+		case kindResultEnd:
+			stmts = append(stmts, c.flushResult("", terminatorName(p.payload), nil))
+		case kindErr:
+			msg, code := errorMessage(p.payload)
+			stmts = append(stmts, c.flushResult(msg, "ERR",
+				map[string]string{"mysql.error_code": strconv.Itoa(int(code))}))
+		}
+		pos += n
+	}
+	return stmts, pos, nil
+}
+
+// flushResult turns the accumulated rows into a Statement and clears the
+// accumulator.
+//
+// Text is empty for a result set, since MySQL's terminator carries no summary
+// of what ran, and the error message for an ERR packet, so a failed query is
+// auditable as a failure rather than as silence.
+func (c *Codec) flushResult(text, terminator string, extra map[string]string) hoopinspect.Statement {
+	md := map[string]string{"mysql.message": terminator}
+	for k, v := range extra {
+		md[k] = v
+	}
+	detail := &hoopinspect.ResultDetail{RowCount: c.rowCount}
+	if len(c.resp.columns) > 0 {
+		detail.Columns = c.resp.columns
+	}
+	c.rowCount = 0
+	return hoopinspect.Statement{
+		Protocol:  hoopinspect.MySQL,
+		Direction: hoopinspect.FromServer,
+		Text:      text,
+		// A response carries no verb the client issued; the operation lives
+		// on the request statement. See the Postgres codec for why setting
+		// one here would mislead every SQL rule.
+		Operation: hoopinspect.OpUnknown,
+		Result:    detail,
+		Metadata:  md,
+	}
+}
@@ -0,0 +1,273 @@
+package mysql_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// Column types used by the fixtures.
+const (
+	typeLongLong  = 0x08
+	typeVarString = 0xfd
+)
+
+func lenencStr(s string) []byte { return append([]byte{byte(len(s))}, s...) }
+
+// greeting is a minimal HandshakeV10; only its first byte matters to the
+// response decoder.
+func greeting() []byte {
+	return packet(0, []byte("\x0a8.0.36\x00\x07\x00\x00\x00abcdefgh\x00\xff\xf7"))
+}
+
+func okPacket(seq byte, status uint16) []byte {
+	p := []byte{0x00, 0, 0}
+	p = binary.LittleEndian.AppendUint16(p, status)
+	p = append(p, 0, 0)
+	return packet(seq, p)
+}
+
+func eofPacket(seq byte, status uint16) []byte {
+	p := []byte{0xfe, 0, 0}
+	p = binary.LittleEndian.AppendUint16(p, status)
+	return packet(seq, p)
+}
+
+func errPacket(seq byte, code uint16, msg string) []byte {
+	p := binary.LittleEndian.AppendUint16([]byte{0xff}, code)
+	p = append(p, "#42S02"...)
+	p = append(p, msg...)
+	return packet(seq, p)
+}
+
+func columnDef(seq byte, name string, typ byte) []byte {
+	var p []byte
+	for _, f := range []string{"def", "appdb", "customers", "customers", name, name} {
+		p = append(p, lenencStr(f)...)
+	}
+	p = append(p, 0x0c)
+	p = append(p, 0xff, 0)    // charset
+	p = append(p, 0, 1, 0, 0) // column length
+	p = append(p, typ)        // type
+	p = append(p, 0, 0)       // flags
+	p = append(p, 0)          // decimals
+	p = append(p, 0, 0)       // filler
+	return packet(seq, p)
+}
+
+// textRow encodes a text-protocol row; a nil value is NULL.
+func textRow(seq byte, vals ...[]byte) []byte {
+	var p []byte
+	for _, v := range vals {
+		if v == nil {
+			p = append(p, 0xfb)
+			continue
+		}
+		p = append(p, byte(len(v)))
+		p = append(p, v...)
+	}
+	return packet(seq, p)
+}
+
+func str(s string) []byte { return []byte(s) }
+
+// resultSet builds a classic (EOF-delimited) text result set of VAR_STRING
+// columns, starting at sequence id 1 as a reply to a command.
+func resultSet(cols []string, rows [][][]byte, status uint16) []byte {
+	seq := byte(1)
+	out := packet(seq, []byte{byte(len(cols))})
+	for _, c := range cols {
+		seq++
+		out = append(out, columnDef(seq, c, typeVarString)...)
+	}
+	seq++
+	out = append(out, eofPacket(seq, 0)...)
+	for _, r := range rows {
+		seq++
+		out = append(out, textRow(seq, r...)...)
+	}
+	seq++
+	return append(out, eofPacket(seq, status)...)
+}
+
+// loggedIn is the server side of a completed login.
+func loggedIn() []byte { return append(greeting(), okPacket(2, 0)...) }
+
+func inspectServer(t *testing.T, stream []byte) []hoopinspect.Statement {
+	t.Helper()
+	stmts, err := newInspector(t).Inspect(hoopinspect.FromServer, stream)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	return stmts
+}
+
+func TestDecodesResultSetShape(t *testing.T) {
+	stream := append(loggedIn(), resultSet(
+		[]string{"name", "ssn"},
+		[][][]byte{
+			{str("Ada Lovelace"), str("123-45-6789")},
+			{str("Grace Hopper"), nil},
+		}, 0)...)
+
+	stmts := inspectServer(t, stream)
+	if len(stmts) != 1 {
+		t.Fatalf("got %d statements, want 1: %+v", len(stmts), stmts)
+	}
+	r := stmts[0].Result
+	if r == nil || r.RowCount != 2 || len(r.Columns) != 2 {
+		t.Fatalf("Result = %+v", r)
+	}
+	if r.Columns[1].Name != "ssn" || r.Columns[1].DataTypeOID != typeVarString {
+		t.Errorf("column = %+v", r.Columns[1])
+	}
+	if stmts[0].Direction != hoopinspect.FromServer || stmts[0].Metadata["mysql.message"] != "EOF" {
+		t.Errorf("statement = %+v", stmts[0])
+	}
+}
+
+// The values must never reach the statement: it becomes an audit record.
+func TestResultCarriesNoCellValues(t *testing.T) {
+	stream := append(loggedIn(), resultSet([]string{"ssn"}, [][][]byte{{str("123-45-6789")}}, 0)...)
+	stmts := inspectServer(t, stream)
+	if len(stmts) != 1 {
+		t.Fatalf("got %d statements", len(stmts))
+	}
+	if bytes.Contains([]byte(stmts[0].String()), []byte("123-45-6789")) {
+		t.Error("a cell value reached the statement")
+	}
+}
+
+// CLIENT_DEPRECATE_EOF drops the EOF after the definitions and ends the rows
+// with an OK carrying the 0xFE header instead.
+func TestDeprecateEOFResultSet(t *testing.T) {
+	okEnd := []byte{0xfe, 0, 0, 0x02, 0, 0, 0}
+	stream := bytes.Join([][]byte{
+		loggedIn(),
+		packet(1, []byte{1}),
+		columnDef(2, "email", typeVarString),
+		textRow(3, str("ada@example.com")),
+		textRow(4, str("grace@example.com")),
+		packet(5, okEnd),
+	}, nil)
+
+	stmts := inspectServer(t, stream)
+	if len(stmts) != 1 || stmts[0].Result.RowCount != 2 || stmts[0].Metadata["mysql.message"] != "OK" {
+		t.Fatalf("got %+v", stmts)
+	}
+}
+
+// A failed query is recorded as a failure, not as silence.
+func TestErrPacketIsRecorded(t *testing.T) {
+	stream := append(loggedIn(), errPacket(1, 1146, "Table 'appdb.nope' doesn't exist")...)
+	stmts := inspectServer(t, stream)
+	if len(stmts) != 1 {
+		t.Fatalf("got %d statements", len(stmts))
+	}
+	s := stmts[0]
+	if s.Text != "Table 'appdb.nope' doesn't exist" || s.Metadata["mysql.error_code"] != "1146" {
+		t.Errorf("statement = %q %v", s.Text, s.Metadata)
+	}
+}
+
+// A bare OK acknowledges a write; there is no result set to report.
+func TestOKIsNotAResultSet(t *testing.T) {
+	if stmts := inspectServer(t, append(loggedIn(), okPacket(1, 0)...)); len(stmts) != 0 {
+		t.Errorf("got %+v", stmts)
+	}
+}
+
+// A multi-statement query answers with several result sets in one response,
+// chained by SERVER_MORE_RESULTS_EXISTS. Each is its own statement.
+func TestConsecutiveResultSets(t *testing.T) {
+	first := resultSet([]string{"a"}, [][][]byte{{str("1")}}, 0x0008)
+	// The second result set continues the sequence rather than restarting
+	// it; renumber so its first packet does not look like a new response.
+	second := resultSet([]string{"b", "c"}, [][][]byte{{str("2"), str("3")}, {str("4"), str("5")}}, 0)
+	second = renumber(second, 7)
+
+	stmts := inspectServer(t, bytes.Join([][]byte{loggedIn(), first, second}, nil))
+	if len(stmts) != 2 {
+		t.Fatalf("got %d statements, want 2", len(stmts))
+	}
+	if stmts[0].Result.RowCount != 1 || stmts[1].Result.RowCount != 2 || len(stmts[1].Result.Columns) != 2 {
+		t.Errorf("results = %+v, %+v", stmts[0].Result, stmts[1].Result)
+	}
+}
+
+// COM_STMT_PREPARE's reply opens with an OK-shaped header and follows it
+// with definitions that are not a result set. They must not be reported as
+// one, and the next real result set must still decode.
+func TestPrepareReplyIsNotAResultSet(t *testing.T) {
+	prepOK := []byte{0x00, 1, 0, 0, 0, 1, 0, 1, 0, 0, 0, 0}
+	stream := bytes.Join([][]byte{
+		loggedIn(),
+		packet(1, prepOK),
+		columnDef(2, "?", typeLongLong),
+		eofPacket(3, 0),
+		columnDef(4, "name", typeVarString),
+		eofPacket(5, 0),
+		resultSet([]string{"name"}, [][][]byte{{str("Ada")}}, 0),
+	}, nil)
+
+	stmts := inspectServer(t, stream)
+	if len(stmts) != 1 || stmts[0].Result.RowCount != 1 {
+		t.Fatalf("got %+v", stmts)
+	}
+}
+
+// A binary-protocol result set (from COM_STMT_EXECUTE) is counted the same.
+func TestBinaryRowsAreCounted(t *testing.T) {
+	row := append([]byte{0x00, 0x00}, binary.LittleEndian.AppendUint64(nil, 7)...)
+	stream := bytes.Join([][]byte{
+		loggedIn(),
+		packet(1, []byte{1}),
+		columnDef(2, "id", typeLongLong),
+		eofPacket(3, 0),
+		packet(4, row),
+		eofPacket(5, 0),
+	}, nil)
+
+	stmts := inspectServer(t, stream)
+	if len(stmts) != 1 || stmts[0].Result.RowCount != 1 {
+		t.Fatalf("got %+v", stmts)
+	}
+}
+
+// TCP delivers bytes, not packets. Every split must yield the same result.
+func TestResponseSplitReadMatrix(t *testing.T) {
+	stream := append(loggedIn(), resultSet(
+		[]string{"name", "ssn"},
+		[][][]byte{{str("Ada"), str("123-45-6789")}, {str("Grace"), str("987-65-4321")}}, 0)...)
+
+	for cut := 0; cut <= len(stream); cut++ {
+		insp := newInspector(t)
+		var got []hoopinspect.Statement
+		for _, chunk := range [][]byte{stream[:cut], stream[cut:]} {
+			stmts, err := insp.Inspect(hoopinspect.FromServer, chunk)
+			if err != nil {
+				t.Fatalf("cut=%d: %v", cut, err)
+			}
+			got = append(got, stmts...)
+		}
+		if len(got) != 1 || got[0].Result.RowCount != 2 || len(got[0].Result.Columns) != 2 {
+			t.Fatalf("cut=%d: got %+v", cut, got)
+		}
+	}
+}
+
+// renumber rewrites the sequence ids of a packet stream to count up from
+// start.
+func renumber(stream []byte, start byte) []byte {
+	out := append([]byte(nil), stream...)
+	seq := start
+	for pos := 0; pos+4 <= len(out); {
+		n := int(out[pos]) | int(out[pos+1])<<8 | int(out[pos+2])<<16
+		out[pos+3] = seq
+		seq++
+		pos += 4 + n
+	}
+	return out
+}
@@ -0,0 +1,284 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// CellMasker rewrites one result-set cell.
+//
+// column is the name the server gave it and may be empty when the result set
+// arrived without metadata. value is the cell as it appears on the wire;
+// returning it unchanged means "leave this alone", and the rewriter then
+// forwards the row's original bytes.
+//
+// Declared as an alias rather than a defined type so this codec satisfies
+// gate.Reframer structurally; see the Postgres codec's CellMasker.
+type CellMasker = func(column string, value []byte) []byte
+
+// ErrRowUnmasked reports that a row was forwarded without masking because
+// this codec cannot rebuild it safely. Rewrite returns it alongside the
+// complete output, so the gate forwards every byte and audits the gap.
+var ErrRowUnmasked = errors.New("hoopinspect/mysql: row forwarded unmasked")
+
+// rowEncoding is how a result set encodes its rows.
+//
+// COM_QUERY answers in the text protocol, where every cell is a
+// length-encoded string. COM_STMT_EXECUTE answers in the binary protocol,
+// where a row opens with 0x00 and a NULL bitmap and each cell is encoded by
+// its column type. Nothing in the result set's header says which it is, and
+// the request that would say is on the other codec, so the rows say it
+// themselves. See rowFormat.
+type rowEncoding int
+
+const (
+	rowsUnknown rowEncoding = iota
+	rowsText
+	rowsBinary
+)
+
+// Rewrite masks a response stream, re-framing every text-protocol row it
+// changes.
+//
+// # Length prefixes
+//
+// A text row is a run of length-encoded strings inside a packet whose header
+// declares the payload size:
+//
+//	int<3>(len) int<1>(seq) [ int<lenenc>(len) bytes | 0xFB ]...
+//
+// Replacing a value with one of a different length leaves both prefixes
+// describing the old size, and the client reports "malformed packet". So a
+// changed row gets rebuilt, every length recomputed from the masked values,
+// and keeps its sequence id: the client checks that those count up without a
+// gap.
+//
+// # What is forwarded unmasked
+//
+// Three kinds of row pass through as they arrived, each reported with
+// ErrRowUnmasked so the audit trail shows the gap rather than hiding it:
+//
+//   - Binary-protocol rows, from server-side prepared statements. Masking
+//     them means re-encoding typed values, which this codec does not do.
+//   - A row that grows past one packet when masked, or arrived as several.
+//     Splitting or joining packets shifts the sequence id of everything
+//     after them in the response, and a renumbered stream is one the
+//     client rejects outright.
+//   - A row whose encoding cannot be told apart; see rowFormat.
+//
+// # Buffering
+//
+// MySQL frames every row as its own packet, so a complete packet can be
+// rewritten the moment it arrives. Only a trailing partial packet is held,
+// until its remainder arrives or Flush releases it.
+func (c *Codec) Rewrite(data []byte, mask CellMasker) ([]byte, hoopinspect.ReframeResult, error) {
+	if mask == nil {
+		return data, hoopinspect.ReframeResult{}, nil
+	}
+
+	// Prepend anything held from an earlier call: a packet split across two
+	// reads is only decodable once its halves are adjacent.
+	if len(c.pending) > 0 {
+		c.pending = append(c.pending, data...)
+		data = c.pending
+		c.pending = nil
+	}
+
+	var (
+		out      []byte
+		res      hoopinspect.ReframeResult
+		unmasked error
+		pos      int
+	)
+	for pos < len(data) {
+		p, n, err := readPacket(data[pos:])
+		if err != nil {
+			// Forward the rest untouched: the client's own parser is the
+			// authority on a stream this codec cannot frame.
+			return append(out, data[pos:]...), res, err
+		}
+		if n == 0 {
+			break // partial packet
+		}
+		raw := data[pos : pos+n]
+		pos += n
+
+		if c.rw.next(p) != kindRow {
+			out = append(out, raw...)
+			continue
+		}
+
+		rewritten, why := c.maskRow(p, mask, &res)
+		if why != "" && unmasked == nil {
+			unmasked = fmt.Errorf("%w: %s", ErrRowUnmasked, why)
+		}
+		if rewritten != nil {
+			out = append(out, rewritten...)
+		} else {
+			out = append(out, raw...)
+		}
+	}
+
+	// Retain the incomplete tail for the next call.
+	if pos < len(data) {
+		c.pending = append(c.pending[:0], data[pos:]...)
+	}
+	return out, res, unmasked
+}
+
+// Flush releases a partial packet still held when the connection closes.
+//
+// It can never be re-framed, but dropping it would truncate what the client
+// receives. Forward it as-is and let the peer decide.
+func (c *Codec) Flush(CellMasker) []byte {
+	out := c.pending
+	c.pending = nil
+	return out
+}
+
+// maskRow returns a rebuilt packet for one row when masking changed it, or
+// nil to forward the original. A non-empty reason means the row could not
+// be masked at all.
+func (c *Codec) maskRow(p packet, mask CellMasker, res *hoopinspect.ReframeResult) ([]byte, string) {
+	if len(p.payload) >= maxPacketPayload {
+		return nil, "a row larger than one packet cannot be re-framed without renumbering the response"
+	}
+
+	switch c.rw.rowFormat(p.payload) {
+	case rowsBinary:
+		return nil, "binary-protocol rows from a prepared statement are not re-encoded"
+	case rowsUnknown:
+		return nil, "the row reads as both text and binary protocol"
+	}
+
+	cells, ok := textCells(p.payload, c.rw.count)
+	if !ok {
+		return nil, "" // rowFormat already proved it parses
+	}
+
+	changed := 0
+	for i, cell := range cells {
+		if cell == nil {
+			continue // NULL
+		}
+		col := ""
+		if i < len(c.rw.columns) {
+			col = c.rw.columns[i].Name
+		}
+		masked := mask(col, cell)
+		if masked == nil {
+			// A masker must not delete a cell; treat nil as "unchanged".
+			continue
+		}
+		if string(masked) != string(cell) {
+			cells[i] = masked
+			changed++
+		}
+	}
+	if changed == 0 {
+		return nil, ""
+	}
+
+	payload := make([]byte, 0, len(p.payload)+changed*8)
+	for _, cell := range cells {
+		if cell == nil {
+			payload = append(payload, respInfile) // 0xFB, the NULL marker
+			continue
+		}
+		payload = appendLenenc(payload, uint64(len(cell)))
+		payload = append(payload, cell...)
+	}
+	if len(payload) >= maxPacketPayload {
+		return nil, "the masked row would no longer fit one packet"
+	}
+
+	res.Cells += changed
+	res.Rows++
+	out := make([]byte, 0, headerLen+len(payload))
+	out = append(out, byte(len(payload)), byte(len(payload)>>8), byte(len(payload)>>16), p.seq)
+	return append(out, payload...), ""
+}
+
+// rowFormat decides how a row is encoded, and remembers the answer for the
+// rest of its result set.
+//
+// A binary row always opens with 0x00, so any other first byte settles it as
+// text. A text row opens with 0x00 too when its first cell is empty, and
+// then both readings are tried: the one that spans the payload exactly wins.
+// When both do, the row is ambiguous, and it stays unmasked rather than
+// risk re-encoding a binary row as text, which corrupts the client's data
+// instead of protecting it. Rows in one result set share an encoding, so the
+// first row that settles it settles it for all of them.
+func (s *responseState) rowFormat(payload []byte) rowEncoding {
+	if s.rows != rowsUnknown {
+		return s.rows
+	}
+	if len(payload) > 0 && payload[0] != 0x00 {
+		s.rows = rowsText
+		return s.rows
+	}
+	_, text := textCells(payload, s.count)
+	binary := binaryRowSpans(payload, s.columns, s.count)
+	switch {
+	case text && !binary:
+		s.rows = rowsText
+	case binary && !text:
+		s.rows = rowsBinary
+	}
+	return s.rows
+}
+
+// textCells splits a text-protocol row into its cells, nil for NULL. ok is
+// false unless exactly count cells span the payload.
+func textCells(payload []byte, count int) ([][]byte, bool) {
+	cells := make([][]byte, 0, count)
+	pos := 0
+	for pos < len(payload) {
+		if payload[pos] == respInfile {
+			cells = append(cells, nil)
+			pos++
+			continue
+		}
+		v, n, ok := lenencString(payload[pos:])
+		if !ok {
+			return nil, false
+		}
+		cells = append(cells, v)
+		pos += n
+	}
+	return cells, len(cells) == count
+}
+
+// binaryRowSpans reports whether payload reads as one binary-protocol row:
+//
+//	int<1>   0x00
+//	byte<n>  NULL bitmap, (count+7+2)/8 bytes, offset by two bits
+//	...      each non-NULL value, encoded by its column type
+//
+// Without column definitions the values cannot be sized, so the answer is
+// no.
+func binaryRowSpans(payload []byte, cols []hoopinspect.Column, count int) bool {
+	if len(cols) != count || len(payload) < 1 || payload[0] != 0x00 {
+		return false
+	}
+	bitmapLen := (count + 7 + 2) / 8
+	pos := 1 + bitmapLen
+	if pos > len(payload) {
+		return false
+	}
+	bitmap := payload[1:pos]
+	for i, col := range cols {
+		bit := i + 2
+		if bitmap[bit/8]&(1<<(bit%8)) != 0 {
+			continue
+		}
+		n, ok := skipBinaryValue(byte(col.DataTypeOID), payload[pos:])
+		if !ok {
+			return false
+		}
+		pos += n
+	}
+	return pos == len(payload)
+}
@@ -0,0 +1,216 @@
+package mysql_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+	my "github.com/hoophq/hoopinspect/codec/mysql"
+)
+
+// rewriteAll runs a whole stream through one codec and returns everything it
+// emitted, flush included, the way the relay does across a connection.
+func rewriteAll(t *testing.T, stream []byte, mask func(string, []byte) []byte) ([]byte, hoopinspect.ReframeResult) {
+	t.Helper()
+	c := &my.Codec{}
+	out, res, err := c.Rewrite(stream, mask)
+	if err != nil {
+		t.Fatalf("Rewrite: %v", err)
+	}
+	return append(out, c.Flush(mask)...), res
+}
+
+// mustReparse is the assertion that matters: a client reading this stream
+// must not desynchronize.
+func mustReparse(t *testing.T, stream []byte) []hoopinspect.Statement {
+	t.Helper()
+	insp := hoopinspect.NewWithCodec(&my.Codec{})
+	stmts, err := insp.Inspect(hoopinspect.FromServer, stream)
+	if err != nil {
+		t.Fatalf("rewritten stream is not valid MySQL protocol: %v", err)
+	}
+	return stmts
+}
+
+func redactColumn(target string) func(string, []byte) []byte {
+	return func(col string, v []byte) []byte {
+		if col == target {
+			return []byte("[REDACTED]")
+		}
+		return v
+	}
+}
+
+// sequenceIDs lists the sequence id of every packet in a stream. A client
+// rejects a response whose ids do not count up without a gap.
+func sequenceIDs(stream []byte) []byte {
+	var ids []byte
+	for pos := 0; pos+4 <= len(stream); {
+		n := int(stream[pos]) | int(stream[pos+1])<<8 | int(stream[pos+2])<<16
+		ids = append(ids, stream[pos+3])
+		pos += 4 + n
+	}
+	return ids
+}
+
+// A replacement of a DIFFERENT length must leave the stream parseable, and
+// every packet keeps its sequence id.
+func TestRewriteReframesGrowAndShrink(t *testing.T) {
+	stream := append(loggedIn(), resultSet(
+		[]string{"name", "ssn"},
+		[][][]byte{
+			{str("Ada Lovelace"), str("123-45-6789")},
+			{str("Grace Hopper"), str("987-65-4321")},
+		}, 0)...)
+
+	for _, tc := range []struct {
+		name    string
+		replace string
+	}{
+		{"grows", "[REDACTED:US_SSN]"},
+		{"shrinks", "x"},
+		{"empty", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, res := rewriteAll(t, stream, func(col string, v []byte) []byte {
+				if col == "ssn" {
+					return []byte(tc.replace)
+				}
+				return v
+			})
+
+			if res.Cells != 2 || res.Rows != 2 {
+				t.Errorf("result = %+v, want 2 cells in 2 rows", res)
+			}
+			if bytes.Contains(out, []byte("123-45-6789")) {
+				t.Error("value survived")
+			}
+			if !bytes.Contains(out, []byte("Ada Lovelace")) {
+				t.Error("an untouched column was corrupted")
+			}
+			if !bytes.Equal(sequenceIDs(out), sequenceIDs(stream)) {
+				t.Errorf("sequence ids changed: %v, want %v", sequenceIDs(out), sequenceIDs(stream))
+			}
+			stmts := mustReparse(t, out)
+			if len(stmts) != 1 || stmts[0].Result.RowCount != 2 {
+				t.Fatalf("re-framed stream lost rows: %+v", stmts)
+			}
+		})
+	}
+}
+
+// NULL is the 0xFB marker, not an empty string. Re-encoding it as empty
+// would change the data the client sees.
+func TestRewritePreservesNull(t *testing.T) {
+	stream := append(loggedIn(), resultSet(
+		[]string{"name", "ssn"},
+		[][][]byte{{nil, str("123-45-6789")}}, 0)...)
+
+	out, _ := rewriteAll(t, stream, redactColumn("ssn"))
+	mustReparse(t, out)
+	want := textRow(5, nil, str("[REDACTED]"))
+	if !bytes.Contains(out, want) {
+		t.Errorf("masked row is not %x", want)
+	}
+}
+
+// A masker that changes nothing must leave the bytes byte-identical.
+func TestRewriteUnchangedIsByteIdentical(t *testing.T) {
+	stream := append(loggedIn(), resultSet([]string{"a", "b"}, [][][]byte{{str("1"), str("2")}}, 0)...)
+	out, res := rewriteAll(t, stream, func(_ string, v []byte) []byte { return v })
+	if res.Cells != 0 || !bytes.Equal(out, stream) {
+		t.Error("an unchanged stream was rewritten")
+	}
+}
+
+// The relay hands the codec whatever the socket returned. Every split must
+// produce the same bytes.
+func TestRewriteSplitReadMatrix(t *testing.T) {
+	stream := append(loggedIn(), resultSet(
+		[]string{"name", "ssn"},
+		[][][]byte{{str("Ada"), str("123-45-6789")}, {str("Grace"), str("987-65-4321")}}, 0)...)
+
+	whole, _ := rewriteAll(t, stream, redactColumn("ssn"))
+	for cut := 0; cut <= len(stream); cut++ {
+		c := &my.Codec{}
+		var out []byte
+		for _, chunk := range [][]byte{stream[:cut], stream[cut:]} {
+			if len(chunk) == 0 {
+				continue
+			}
+			got, _, err := c.Rewrite(chunk, redactColumn("ssn"))
+			if err != nil {
+				t.Fatalf("cut=%d: %v", cut, err)
+			}
+			out = append(out, got...)
+		}
+		out = append(out, c.Flush(redactColumn("ssn"))...)
+		if !bytes.Equal(out, whole) {
+			t.Fatalf("cut=%d produced different bytes than the unsplit stream", cut)
+		}
+	}
+}
+
+// A text row whose first cell is empty opens with 0x00, like a binary row.
+// The rest of the row must still settle it as text, and mask it.
+func TestEmptyFirstCellIsStillText(t *testing.T) {
+	stream := append(loggedIn(), resultSet(
+		[]string{"note", "ssn"},
+		[][][]byte{{str(""), str("123-45-6789")}}, 0)...)
+
+	out, res := rewriteAll(t, stream, redactColumn("ssn"))
+	if res.Cells != 1 || bytes.Contains(out, []byte("123-45-6789")) {
+		t.Errorf("text row with an empty first cell was not masked: %+v", res)
+	}
+}
+
+// Binary-protocol rows are not re-encoded. They go out as they came, and the
+// gap is reported rather than hidden.
+func TestBinaryRowsAreReportedUnmasked(t *testing.T) {
+	row := []byte{0x00, 0x00, 11}
+	row = append(row, "123-45-6789"...)
+	row = append(row, binary.LittleEndian.AppendUint64(nil, 7)...)
+	stream := bytes.Join([][]byte{
+		loggedIn(),
+		packet(1, []byte{2}),
+		columnDef(2, "ssn", typeVarString),
+		columnDef(3, "id", typeLongLong),
+		eofPacket(4, 0),
+		packet(5, row),
+		eofPacket(6, 0),
+	}, nil)
+
+	c := &my.Codec{}
+	out, res, err := c.Rewrite(stream, redactColumn("ssn"))
+	if !errors.Is(err, my.ErrRowUnmasked) {
+		t.Errorf("err = %v, want ErrRowUnmasked", err)
+	}
+	if res.Cells != 0 || !bytes.Equal(out, stream) {
+		t.Error("a binary row was altered")
+	}
+}
+
+func TestRewriteNilMaskerPassesThrough(t *testing.T) {
+	stream := append(loggedIn(), resultSet([]string{"ssn"}, [][][]byte{{str("123-45-6789")}}, 0)...)
+	out, _ := rewriteAll(t, stream, nil)
+	if !bytes.Equal(out, stream) {
+		t.Error("a nil masker changed the stream")
+	}
+}
+
+// Column names reset between result sets: the second set's first column is
+// not the first set's.
+func TestColumnNamesResetBetweenResultSets(t *testing.T) {
+	stream := bytes.Join([][]byte{
+		loggedIn(),
+		resultSet([]string{"ssn"}, [][][]byte{{str("123-45-6789")}}, 0),
+		resultSet([]string{"name"}, [][][]byte{{str("Ada")}}, 0),
+	}, nil)
+
+	out, res := rewriteAll(t, stream, redactColumn("ssn"))
+	if res.Cells != 1 || !bytes.Contains(out, []byte("Ada")) {
+		t.Errorf("masking leaked into the next result set: %+v", res)
+	}
+}
@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+// COPY TO STDOUT streams a table without a single DataRow, so the limiter
+// counts its CopyData messages as rows. CopyData travels in both directions
+// and inside replication's CopyBoth, which is why only the messages between a
+// CopyOutResponse and its CopyDone are counted.
+const (
+	tagCopyOutResponse = 'H'
+	tagCopyData        = 'd'
+	tagCopyDone        = 'c'
+)
+
+// LimitRows drops DataRow messages the caller refuses, keeping the stream
+// well-formed around them. It satisfies gate.RowLimiter.
+//
+// # Why dropping is safe here
+//
+// Every row is its own message and no message counts the ones before it, so
+// removing a DataRow leaves a stream any client parses. The one place the
+// count reappears is CommandComplete's tag ("SELECT 1000"), which drivers
+// report as the affected row count. When rows were dropped the tag is
+// rewritten to the number delivered, so the client's count matches the rows
+// it holds.
+//
+// # Result sets
+//
+// A result set starts after a RowDescription or a terminator, so the count
+// resets there. PortalSuspended does NOT reset it: an Execute with a row
+// limit resumes the same portal, and counting per batch would let a client
+// read a whole table 100 rows at a time under a limit of 1000.
+//
+// Like Rewrite, it holds a trailing partial message until its remainder
+// arrives, in a buffer of its own: the two run on different stages of the
+// same stream.
+func (c *Codec) LimitRows(data []byte, admit func(rows int, bytes int64) bool) ([]byte, error) {
+	if len(c.limit.pending) > 0 {
+		c.limit.pending = append(c.limit.pending, data...)
+		data = c.limit.pending
+		c.limit.pending = nil
+	}
+
+	var (
+		out []byte
+		pos int
+	)
+	for pos < len(data) {
+		if len(data)-pos < 5 {
+			break
+		}
+		tag := data[pos]
+		msgLen := binary.BigEndian.Uint32(data[pos+1 : pos+5])
+		if msgLen < 4 || msgLen > maxMessageLen {
+			// Forward the rest untouched: the client's own parser is the
+			// authority on a stream this codec cannot frame.
+			return append(out, data[pos:]...), ErrMalformed
+		}
+		total := 1 + int(msgLen)
+		if len(data)-pos < total {
+			break // partial message
+		}
+		msg := data[pos : pos+total]
+		pos += total
+
+		switch {
+		case tag == tagDataRow, tag == tagCopyData && c.limit.copying:
+			c.limit.rows++
+			c.limit.bytes += int64(total)
+			if !admit(c.limit.rows, c.limit.bytes) {
+				c.limit.dropped = true
+				continue
+			}
+			c.limit.delivered++
+		case tag == tagCommandComplete:
+			if c.limit.dropped {
+				msg = retagRowCount(msg, c.limit.delivered)
+			}
+			c.limit.reset()
+		case tag == tagRowDescription, tag == tagErrorResponse,
+			tag == tagEmptyQuery, tag == tagReadyForQuery, tag == tagCopyDone:
+			c.limit.reset()
+		case tag == tagCopyOutResponse:
+			c.limit.reset()
+			c.limit.copying = true
+		}
+		out = append(out, msg...)
+	}
+
+	if pos < len(data) {
+		c.limit.pending = append(c.limit.pending[:0], data[pos:]...)
+	}
+	return out, nil
+}
+
+// FlushLimit releases a partial message still held when the connection
+// closes. A fragment of a row the limit refused stays dropped: forwarding it
+// would hand the client part of exactly what the limit withheld.
+func (c *Codec) FlushLimit() []byte {
+	out := c.limit.pending
+	c.limit.pending = nil
+	if c.limit.dropped && len(out) > 0 && (out[0] == tagDataRow || out[0] == tagCopyData) {
+		return nil
+	}
+	return out
+}
+
+// rowLimit is LimitRows' state, kept apart from the decode and rewrite state
+// because it runs on its own copy of the stream.
+type rowLimit struct {
+	// rows and bytes count what the server has sent in the current result
+	// set, delivered how many of those rows the client received, and dropped
+	// whether any were withheld.
+	rows      int
+	bytes     int64
+	delivered int
+	dropped   bool
+
+	// copying is set between CopyOutResponse and the end of the COPY.
+	copying bool
+
+	pending []byte
+}
+
+func (l *rowLimit) reset() {
+	pending := l.pending
+	*l = rowLimit{pending: pending}
+}
+
+// retagRowCount rewrites the count at the end of a CommandComplete tag, the
+// "1000" in "SELECT 1000", leaving a tag that carries no count unchanged.
+func retagRowCount(msg []byte, n int) []byte {
+	tag := cstring(msg[5:])
+	sp := -1
+	for i := len(tag) - 1; i >= 0; i-- {
+		if tag[i] == ' ' {
+			sp = i
+			break
+		}
+	}
+	if sp < 0 || sp == len(tag)-1 {
+		return msg
+	}
+	if _, err := strconv.ParseUint(tag[sp+1:], 10, 64); err != nil {
+		return msg
+	}
+	retagged := tag[:sp+1] + strconv.Itoa(n)
+	out := make([]byte, 0, 6+len(retagged))
+	out = append(out, tagCommandComplete)
+	out = binary.BigEndian.AppendUint32(out, uint32(4+len(retagged)+1))
+	out = append(out, retagged...)
+	return append(out, 0)
+}
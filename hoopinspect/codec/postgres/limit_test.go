@@ -0,0 +1,180 @@
+package postgres_test
+
+import (
+	"bytes"
+	"testing"
+
+	pg "github.com/hoophq/hoopinspect/codec/postgres"
+)
+
+// maxRows admits the first n rows of every result set.
+func maxRows(n int) func(int, int64) bool {
+	return func(rows int, _ int64) bool { return rows <= n }
+}
+
+func limitAll(t *testing.T, c *pg.Codec, stream []byte, admit func(int, int64) bool) []byte {
+	t.Helper()
+	out, err := c.LimitRows(stream, admit)
+	if err != nil {
+		t.Fatalf("LimitRows: %v", err)
+	}
+	return append(out, c.FlushLimit()...)
+}
+
+// Dropped rows leave a stream the client parses, and the CommandComplete tag
+// reports the rows it actually received.
+func TestLimitRowsTruncatesAndRetags(t *testing.T) {
+	stream := bytes.Join([][]byte{
+		rowDescMsg("id"),
+		dataRowMsg(str("1")),
+		dataRowMsg(str("2")),
+		dataRowMsg(str("3")),
+		commandComplete("SELECT 3"),
+		readyForQuery(),
+	}, nil)
+
+	out := limitAll(t, &pg.Codec{}, stream, maxRows(2))
+	want := bytes.Join([][]byte{
+		rowDescMsg("id"),
+		dataRowMsg(str("1")),
+		dataRowMsg(str("2")),
+		commandComplete("SELECT 2"),
+		readyForQuery(),
+	}, nil)
+	if !bytes.Equal(out, want) {
+		t.Fatalf("got %q\nwant %q", out, want)
+	}
+	stmts := mustReparse(t, out)
+	if len(stmts) != 1 || stmts[0].Result.RowCount != 2 {
+		t.Errorf("reparsed %+v, want one result of 2 rows", stmts)
+	}
+}
+
+// The count starts over with each result set, and admit is told so by a
+// first row numbered 1.
+func TestLimitRowsCountsPerResultSet(t *testing.T) {
+	stream := bytes.Join([][]byte{
+		rowDescMsg("a"),
+		dataRowMsg(str("1")), dataRowMsg(str("2")),
+		commandComplete("SELECT 2"),
+		rowDescMsg("b"),
+		dataRowMsg(str("3")), dataRowMsg(str("4")),
+		commandComplete("SELECT 2"),
+		readyForQuery(),
+	}, nil)
+
+	var seen []int
+	out := limitAll(t, &pg.Codec{}, stream, func(rows int, bytes int64) bool {
+		seen = append(seen, rows)
+		return true
+	})
+	if !bytes.Equal(out, stream) {
+		t.Error("an admitting limiter changed the stream")
+	}
+	if want := []int{1, 2, 1, 2}; !equalInts(seen, want) {
+		t.Errorf("admit saw rows %v, want %v", seen, want)
+	}
+}
+
+// Bytes are the wire size of the rows, cumulative within the set.
+func TestLimitRowsReportsBytes(t *testing.T) {
+	row := dataRowMsg(str("abc"))
+	stream := bytes.Join([][]byte{rowDescMsg("a"), row, row, commandComplete("SELECT 2")}, nil)
+
+	var last int64
+	limitAll(t, &pg.Codec{}, stream, func(_ int, b int64) bool { last = b; return true })
+	if want := int64(2 * len(row)); last != want {
+		t.Errorf("bytes = %d, want %d", last, want)
+	}
+}
+
+// PortalSuspended resumes the same result set, so paging through a portal
+// with Execute's row limit cannot reset the count.
+func TestLimitRowsCountsAcrossPortalSuspended(t *testing.T) {
+	suspended := backendMsg('s', nil)
+	stream := bytes.Join([][]byte{
+		dataRowMsg(str("1")), suspended,
+		dataRowMsg(str("2")), suspended,
+		dataRowMsg(str("3")),
+		commandComplete("SELECT 3"),
+	}, nil)
+
+	out := limitAll(t, &pg.Codec{}, stream, maxRows(1))
+	if n := bytes.Count(out, dataRowMsg(str("2"))) + bytes.Count(out, dataRowMsg(str("3"))); n != 0 {
+		t.Errorf("rows past the limit survived a PortalSuspended: %q", out)
+	}
+	if !bytes.Contains(out, commandComplete("SELECT 1")) {
+		t.Errorf("tag not retagged: %q", out)
+	}
+}
+
+// COPY TO STDOUT sends a table as CopyData, and is limited like rows.
+func TestLimitRowsCountsCopyOut(t *testing.T) {
+	copyData := func(s string) []byte { return backendMsg('d', []byte(s)) }
+	stream := bytes.Join([][]byte{
+		backendMsg('H', []byte{0, 0, 0}),
+		copyData("1\tada\n"), copyData("2\tgrace\n"),
+		backendMsg('c', nil),
+		commandComplete("COPY 2"),
+	}, nil)
+
+	out := limitAll(t, &pg.Codec{}, stream, maxRows(1))
+	if bytes.Contains(out, []byte("grace")) {
+		t.Errorf("copy row past the limit survived: %q", out)
+	}
+	if !bytes.Contains(out, []byte("ada")) {
+		t.Errorf("copy row within the limit dropped: %q", out)
+	}
+}
+
+// The relay's reads have nothing to do with message boundaries; every split
+// must produce the same bytes.
+func TestLimitRowsSplitReadMatrix(t *testing.T) {
+	stream := bytes.Join([][]byte{
+		rowDescMsg("id"),
+		dataRowMsg(str("1")), dataRowMsg(str("2")), dataRowMsg(str("3")),
+		commandComplete("SELECT 3"),
+		readyForQuery(),
+	}, nil)
+	whole := limitAll(t, &pg.Codec{}, stream, maxRows(1))
+
+	for cut := 0; cut <= len(stream); cut++ {
+		c := &pg.Codec{}
+		var out []byte
+		for _, chunk := range [][]byte{stream[:cut], stream[cut:]} {
+			got, err := c.LimitRows(chunk, maxRows(1))
+			if err != nil {
+				t.Fatalf("cut=%d: %v", cut, err)
+			}
+			out = append(out, got...)
+		}
+		out = append(out, c.FlushLimit()...)
+		if !bytes.Equal(out, whole) {
+			t.Fatalf("cut=%d produced different bytes than the unsplit stream", cut)
+		}
+	}
+}
+
+// A fragment of a refused row is not released at close.
+func TestFlushLimitWithholdsRefusedFragment(t *testing.T) {
+	row := dataRowMsg(str("secret"))
+	stream := bytes.Join([][]byte{rowDescMsg("a"), dataRowMsg(str("ok")), row, row[:6]}, nil)
+
+	c := &pg.Codec{}
+	out := limitAll(t, c, stream, maxRows(1))
+	if bytes.Contains(out, []byte("secret")) || bytes.Contains(out, row[:6]) {
+		t.Errorf("refused data forwarded: %q", out)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -60,6 +60,9 @@ type Codec struct {
 	held     []byte
 	pending  []byte
 	maskCols []string
+
+	// limit is LimitRows' state; see rowLimit.
+	limit rowLimit
 }
 
 func (*Codec) Protocol() hoopinspect.Protocol { return hoopinspect.Postgres }
@@ -276,16 +279,19 @@ func newStatement(text, msgType, stmtName string) hoopinspect.Statement {
 		// operator reading the trail can tell a DO block from a
 		// malformed literal, and so a policy can branch on it.
 		md[hoopinspect.MetadataSQLIncomplete] = a.Reason
+	} else if !a.ColumnsComplete {
+		md[hoopinspect.MetadataColumnsIncomplete] = a.ColumnsReason
 	}
 	return hoopinspect.Statement{
-		Protocol:  hoopinspect.Postgres,
-		Direction: hoopinspect.FromClient,
-		Text:      text,
-		Operation: a.Operation,
-		Effects:   a.Effects,
-		Relations: a.Relations,
-		Tables:    a.Tables,
-		Metadata:  md,
+		Protocol:        hoopinspect.Postgres,
+		Direction:       hoopinspect.FromClient,
+		Text:            text,
+		Operation:       a.Operation,
+		Effects:         a.Effects,
+		Relations:       a.Relations,
+		Tables:          a.Tables,
+		UnboundedWrites: a.UnboundedWrites,
+		Metadata:        md,
 	}
 }
 
@@ -11,7 +11,8 @@
 //  1. Inspect the bytes.
 //  2. Evaluate policy on each statement.
 //  3. Audit the verdict, BEFORE the statement reaches the upstream.
-//  4. On the way back, mask, then audit what was masked.
+//  4. On the way back, apply result limits and mask, then audit what was
+//     cut and what was masked.
 //
 // Step 3 is the one to get right. Auditing after forwarding means a crash
 // between the two loses the record of the statement that crashed you.
@@ -78,6 +79,34 @@ type Reframer interface {
 	Flush(mask func(column string, value []byte) []byte) []byte
 }
 
+// RowLimiter drops result-set rows as they stream, at row boundaries.
+//
+// A codec implements it when it can remove a row without desynchronizing the
+// client. That is what a result limit needs and a statement cannot give it:
+// a codec describes a result set when the set ENDS, by which time every row
+// has been forwarded.
+//
+// LimitRows calls admit once per row, with the row's position in its result
+// set (1 for the first, which is how the caller learns a new set began) and
+// the row bytes that set has produced so far, this row included. A false
+// drops the row. Everything other than a row passes through in order. A
+// trailing partial message is held until the next call; FlushLimit releases
+// it when the connection closes, unless it belongs to a row being dropped.
+type RowLimiter interface {
+	LimitRows(data []byte, admit func(rows int, bytes int64) bool) ([]byte, error)
+	FlushLimit() []byte
+}
+
+// Pairer is a server-side codec that needs its client-side twin.
+//
+// A codec implements it when a response cannot be read without the request
+// it answers: a gRPC response message is decoded against the method the
+// request named, and only the request carries the name. New calls Pair once,
+// before either direction decodes anything.
+type Pairer interface {
+	Pair(request hoopinspect.Codec)
+}
+
 // Config assembles a Gate.
 type Config struct {
 	// Protocol selects the codec. Required.
@@ -95,6 +124,22 @@ type Config struct {
 	// Masker rewrites response payloads. Optional; nil disables masking.
 	Masker Masker
 
+	// Limits caps how much of each result set the session may read, checked
+	// row by row in order; the first limit a row crosses decides what happens
+	// to the rest of its result set. A limit that does not apply to the
+	// session's identity is dropped at New. Optional, and ignored on a
+	// protocol whose codec cannot drop rows; see LimitSupported.
+	Limits []policy.ResultLimit
+
+	// ObserveLimits records every crossing as a KindLimited event and cuts
+	// nothing, the limits' counterpart to a nil Policy.
+	ObserveLimits bool
+
+	// Live, when set, supplies Policy, Masker, Limits and ObserveLimits
+	// instead of the fields above, and is read on every chunk so a reload
+	// reaches this connection. See Live.
+	Live *Live
+
 	// FailOnAuditError makes a failed audit write deny the statement.
 	//
 	// Default false, and the default is the uncomfortable one: a broken
@@ -104,6 +149,21 @@ type Config struct {
 	// did what.
 	FailOnAuditError bool
 
+	// Approver answers for a statement a holding rule parked (see
+	// policy.ActionApprove). Nil refuses every held statement, which is
+	// what a holding rule with no one to ask should do. Live supplies it
+	// instead when set, like Policy.
+	Approver policy.Approver
+
+	// Hold, when set, is called as a statement is parked for approval and
+	// the function it returns as the wait ends, whatever the answer.
+	//
+	// The gate only waits; the connection is the caller's. A relay uses
+	// the pair to keep the session alive for as long as a person takes to
+	// answer: to hold off its own idle timeout and, where the protocol has
+	// a message for it, to tell the client it is waiting.
+	Hold func(stmt hoopinspect.Statement) (resume func())
+
 	// MaxBuffer bounds per-connection reassembly. Zero uses the inspector
 	// default.
 	MaxBuffer int
@@ -139,6 +199,12 @@ type Decision struct {
 	// Rule identifies the policy rule that denied.
 	Rule string
 
+	// Offending is the statement a denial is about, nil when the denial is
+	// about the stream rather than a statement in it. A protocol whose error
+	// frame must answer a specific request (MongoDB echoes the requestID)
+	// cannot build one without it.
+	Offending *hoopinspect.Statement
+
 	// Statements are the statements decoded from this chunk, in order.
 	// Present whether allowed or denied, so a caller can log them.
 	Statements []hoopinspect.Statement
@@ -176,17 +242,44 @@ type Gate struct {
 	sess    *session.Session
 	client  *hoopinspect.Inspector
 	server  *hoopinspect.Inspector
-	policy  policy.Evaluator
 	audit   audit.Sink
-	masker  Masker
-	polCtx  map[string]string
 	started bool
 
+	// polCtx is the session's policy context, built on the first
+	// evaluation rather than in New: a pgwire relay learns its user from
+	// the StartupMessage after New, and a rule keyed on the principal must
+	// not count every psql user as one anonymous caller.
+	polCtx     map[string]string
+	polCtxOnce sync.Once
+
+	// fixed is the stack from Config's own fields, used when Config.Live is
+	// nil. See stack.
+	fixed Stack
+
 	// reframer is the server-side codec when it can rebuild its own frames,
 	// nil otherwise. Set from the server Inspector's codec at construction,
 	// so the data path does not type-assert per packet.
 	reframer Reframer
 
+	// limiter is the server-side codec when it can drop rows, nil
+	// otherwise, discovered once for the same reason as reframer. limits
+	// are the configured limits that apply to this session's identity,
+	// chosen on the first response rather than here, since a pgwire relay
+	// learns its user from the StartupMessage after New, and chosen again
+	// whenever the stack they came from is replaced (limitsFrom).
+	//
+	// crossed is the limit the current result set has crossed, nil while it
+	// is within every limit, and delivered counts what that set has sent
+	// the client so far. Both reset when the codec reports a set's first row.
+	limiter    RowLimiter
+	limits     []policy.ResultLimit
+	limitsFrom *Stack
+	crossed    *policy.ResultLimit
+	delivered  struct {
+		rows  int
+		bytes int64
+	}
+
 	// mu guards the counters, which Close reads while a data-path goroutine
 	// may still be incrementing them. The inspectors are not guarded: they
 	// are per-direction and each direction has one reader.
@@ -246,10 +339,14 @@ func New(sess *session.Session, cfg Config) (*Gate, error) {
 		sess:   sess,
 		client: client,
 		server: server,
-		policy: cfg.Policy,
 		audit:  cfg.Audit,
-		masker: cfg.Masker,
-		polCtx: sess.PolicyContext(),
+		fixed: Stack{
+			Policy:        cfg.Policy,
+			Masker:        cfg.Masker,
+			Limits:        cfg.Limits,
+			ObserveLimits: cfg.ObserveLimits,
+			Approver:      cfg.Approver,
+		},
 	}
 	// Discover the optional re-framing capability once, so the data path
 	// does not type-assert per packet. A codec that cannot rebuild its own
@@ -258,9 +355,28 @@ func New(sess *session.Session, cfg Config) (*Gate, error) {
 	if rf, ok := server.Codec().(Reframer); ok {
 		g.reframer = rf
 	}
+	if rl, ok := server.Codec().(RowLimiter); ok {
+		g.limiter = rl
+	}
+	if p, ok := server.Codec().(Pairer); ok {
+		p.Pair(client.Codec())
+	}
 	return g, nil
 }
 
+// stack returns the policy, masker and limits in force for the next chunk.
+//
+// The caller loads it once and passes it down, so two halves of one decision
+// cannot straddle a reload.
+func (g *Gate) stack() *Stack {
+	if g.cfg.Live != nil {
+		if st := g.cfg.Live.Load(); st != nil {
+			return st
+		}
+	}
+	return &g.fixed
+}
+
 // Session returns the session this gate is inspecting.
 func (g *Gate) Session() *session.Session { return g.sess }
 
@@ -316,25 +432,35 @@ func (g *Gate) Response(ctx context.Context, data []byte) Decision {
 // worse failure than masking late. The relay MUST call this before it stops
 // pumping, and forward whatever comes back.
 //
+// A row limiter holds only a trailing partial message, which comes after
+// anything the reframer holds and so is emitted last.
+//
 // Returns nil when nothing is held or the codec does not re-frame.
 func (g *Gate) FlushResponse() []byte {
-	if g.reframer == nil {
-		return nil
+	st := g.stack()
+	var out []byte
+	switch {
+	case g.reframer == nil:
+	case st.Masker == nil:
+		out = g.reframer.Flush(nil)
+	default:
+		out = g.reframer.Flush(func(column string, value []byte) []byte {
+			masked, _, n := st.Masker.MaskCell(column, value)
+			if n == 0 {
+				return value
+			}
+			return masked
+		})
 	}
-	if g.masker == nil {
-		return g.reframer.Flush(nil)
+	if g.limiting(st) {
+		out = append(out, g.limiter.FlushLimit()...)
 	}
-	return g.reframer.Flush(func(column string, value []byte) []byte {
-		out, _, n := g.masker.MaskCell(column, value)
-		if n == 0 {
-			return value
-		}
-		return out
-	})
+	return out
 }
 
 func (g *Gate) inspect(ctx context.Context, dir hoopinspect.Direction, data []byte) Decision {
 	d := Decision{Allowed: true, Payload: data}
+	st := g.stack()
 
 	insp := g.client
 	if dir == hoopinspect.FromServer {
@@ -369,7 +495,10 @@ func (g *Gate) inspect(ctx context.Context, dir hoopinspect.Direction, data []by
 	}
 
 	for _, stmt := range stmts {
-		verdict := g.evaluate(stmt)
+		verdict := g.evaluate(st.Policy, stmt)
+		if verdict.Approval {
+			verdict = g.awaitApproval(ctx, st, dir, stmt, verdict)
+		}
 
 		g.mu.Lock()
 		g.statements++
@@ -401,6 +530,7 @@ func (g *Gate) inspect(ctx context.Context, dir hoopinspect.Direction, data []by
 				Allowed:    false,
 				Message:    "audit trail unavailable; statement refused",
 				Rule:       "audit",
+				Offending:  &stmt,
 				Statements: stmts,
 				Err:        auditErr,
 			}
@@ -413,6 +543,7 @@ func (g *Gate) inspect(ctx context.Context, dir hoopinspect.Direction, data []by
 			d.Allowed = false
 			d.Message = verdict.Message
 			d.Rule = verdict.Rule
+			d.Offending = &stmt
 			d.Payload = nil // nothing may be forwarded
 			if verdict.Err != nil {
 				d.Err = errors.Join(d.Err, verdict.Err)
@@ -424,6 +555,16 @@ func (g *Gate) inspect(ctx context.Context, dir hoopinspect.Direction, data []by
 		}
 	}
 
+	// Limits run before masking, so the masker never spends time on a row
+	// that is about to be dropped, and on the raw stream, so a row's size is
+	// what the server sent rather than what masking made of it.
+	if dir == hoopinspect.FromServer && g.limiting(st) {
+		if !g.limitRows(ctx, st, &d, data) {
+			return d
+		}
+		data = d.Payload
+	}
+
 	// Masking is response-side only: rewriting a client's request would
 	// change the statement the upstream executes, which breaks correctness
 	// instead of protecting privacy.
@@ -435,12 +576,12 @@ func (g *Gate) inspect(ctx context.Context, dir hoopinspect.Direction, data []by
 	//   - Re-framing, for a length-prefixed binary protocol where every row
 	//     and column carries its own size. Substituting bytes there
 	//     desynchronizes the client; the codec rebuilds the frames instead.
-	if dir == hoopinspect.FromServer && g.masker != nil && len(data) > 0 {
+	if dir == hoopinspect.FromServer && st.Masker != nil && len(data) > 0 {
 		switch {
 		case g.reframer != nil:
-			g.maskByReframing(ctx, &d, data)
+			g.maskByReframing(ctx, st.Masker, &d, data)
 		case substitutionSafe(g.cfg.Protocol):
-			g.maskBySubstitution(ctx, &d, data)
+			g.maskBySubstitution(ctx, st.Masker, &d, data)
 		}
 	}
 
@@ -454,8 +595,8 @@ func (g *Gate) inspect(ctx context.Context, dir hoopinspect.Direction, data []by
 // corrected the ORIGINAL bytes go out unmasked, because a masked body behind
 // a stale Content-Length is read to the old length and stops mid-token: the
 // client sees a corrupt response rather than a protected one.
-func (g *Gate) maskBySubstitution(ctx context.Context, d *Decision, data []byte) {
-	out, entities, count := g.masker.Mask(data)
+func (g *Gate) maskBySubstitution(ctx context.Context, m Masker, d *Decision, data []byte) {
+	out, entities, count := m.Mask(data)
 	if count == 0 {
 		return
 	}
@@ -489,13 +630,13 @@ func (g *Gate) maskBySubstitution(ctx context.Context, d *Decision, data []byte)
 // their result set ends, because a row cannot be rebuilt once forwarded. That
 // is safe for a relay, since the held bytes arrive on a later call or on
 // Close, and it is why Gate.Close flushes.
-func (g *Gate) maskByReframing(ctx context.Context, d *Decision, data []byte) {
+func (g *Gate) maskByReframing(ctx context.Context, m Masker, d *Decision, data []byte) {
 	var (
 		entities []string
 		seen     = map[string]bool{}
 	)
 	out, res, err := g.reframer.Rewrite(data, func(column string, value []byte) []byte {
-		masked, names, n := g.masker.MaskCell(column, value)
+		masked, names, n := m.MaskCell(column, value)
 		if n == 0 {
 			return value
 		}
@@ -524,6 +665,111 @@ func (g *Gate) maskByReframing(ctx context.Context, d *Decision, data []byte) {
 	}
 }
 
+// limiting reports whether any limit applies on this connection.
+//
+// Only the server pump calls it, so choosing the limits needs no lock.
+func (g *Gate) limiting(st *Stack) bool {
+	if g.limiter == nil {
+		return false
+	}
+	if g.limitsFrom != st {
+		g.limitsFrom = st
+		g.limits = nil
+		id := g.sess.Identity
+		for _, l := range st.Limits {
+			if l.AppliesTo(id.Subject, id.Email, id.Groups) {
+				g.limits = append(g.limits, l)
+			}
+		}
+	}
+	return len(g.limits) > 0
+}
+
+// limitRows passes a response through the codec's row limiter, reporting
+// false when a limit denied it and d now carries the denial.
+//
+// A crossing is recorded once per result set, when it happens, rather than
+// when the set ends: a denial never sees the end, and a truncation's record
+// should not depend on the server finishing a result nobody is reading.
+func (g *Gate) limitRows(ctx context.Context, st *Stack, d *Decision, data []byte) bool {
+	type crossing struct {
+		limit *policy.ResultLimit
+		rows  int
+		bytes int64
+	}
+	var hits []crossing
+
+	out, err := g.limiter.LimitRows(data, func(rows int, bytes int64) bool {
+		if rows == 1 {
+			g.crossed = nil
+			g.delivered.rows, g.delivered.bytes = 0, 0
+		}
+		if g.crossed == nil {
+			for i := range g.limits {
+				if g.limits[i].Exceeded(rows, bytes) {
+					g.crossed = &g.limits[i]
+					hits = append(hits, crossing{g.crossed, g.delivered.rows, g.delivered.bytes})
+					break
+				}
+			}
+		}
+		if g.crossed != nil && !st.ObserveLimits {
+			return false
+		}
+		g.delivered.rows, g.delivered.bytes = rows, bytes
+		return true
+	})
+	if err != nil {
+		// As with masking: a response the codec cannot frame is forwarded
+		// as produced and recorded, and the client's parser is the judge.
+		d.Err = errors.Join(d.Err, err)
+		g.writeAudit(ctx, audit.ErrorEvent(g.sess, err))
+	}
+	d.Payload = out
+
+	for _, h := range hits {
+		action := policy.LimitDeny
+		switch {
+		case st.ObserveLimits:
+			action = "observe"
+		case h.limit.Truncates():
+			action = policy.LimitTruncate
+		}
+		msg := h.limit.DenyMessage()
+		if action != policy.LimitDeny {
+			msg = h.limit.Message
+		}
+		auditErr := g.writeAudit(ctx, audit.LimitEvent(g.sess, h.limit.Name, msg, action, h.rows, h.bytes))
+		if auditErr != nil {
+			d.Err = errors.Join(d.Err, auditErr)
+		}
+		if action != policy.LimitDeny {
+			continue
+		}
+		g.mu.Lock()
+		g.denied++
+		g.mu.Unlock()
+		d.Allowed = false
+		d.Rule = h.limit.Name
+		d.Message = msg
+		d.Payload = nil
+		return false
+	}
+	return true
+}
+
+// LimitSupported reports whether a protocol's codec can enforce a
+// policy.ResultLimit, so a configuration layer can refuse limits on a lane
+// that would load them and cut nothing. Like MaskSupported, it asks the codec.
+func LimitSupported(p hoopinspect.Protocol) bool {
+	insp, err := hoopinspect.New(p)
+	if err != nil {
+		return false
+	}
+	_, ok := insp.Codec().(RowLimiter)
+	return ok
+}
+
 // MaskSupported reports whether a protocol's response payload can be masked
 // by either mechanism.
 //
@@ -560,8 +806,8 @@ func substitutionSafe(p hoopinspect.Protocol) bool {
 }
 
 // evaluate runs the policy, defaulting to allow when none is configured.
-func (g *Gate) evaluate(stmt hoopinspect.Statement) policy.Verdict {
-	if g.policy == nil {
+func (g *Gate) evaluate(pol policy.Evaluator, stmt hoopinspect.Statement) policy.Verdict {
+	if pol == nil {
 		return policy.Allow()
 	}
 	// Attach the session facts so a Rego policy can reference the actor.
@@ -572,10 +818,62 @@ func (g *Gate) evaluate(stmt hoopinspect.Statement) policy.Verdict {
 	// consults OPA on both sides of the analyzer holds TWO of them inside a
 	// policy.Chain, which a type assertion for a bare client silently
 	// misses, leaving input.context empty on exactly the lanes that need it.
-	if ce, ok := g.policy.(policy.ContextualEvaluator); ok {
+	if ce, ok := pol.(policy.ContextualEvaluator); ok {
+		g.polCtxOnce.Do(func() { g.polCtx = g.sess.PolicyContext() })
 		return ce.EvaluateWith(stmt, &policy.EvalContext{Context: g.polCtx})
 	}
-	return g.policy.Evaluate(stmt)
+	return pol.Evaluate(stmt)
+}
+
+// awaitApproval parks a held statement until the stack's Approver answers,
+// and returns the verdict the answer makes.
+//
+// Only a request is held. By the time a response arrives the upstream has
+// run the statement, and a person approving its result set would be deciding
+// whether the client may see what it already caused, with the result set
+// half-forwarded and the client waiting mid-read. A holding rule that
+// matches a response denies it.
+//
+// Nothing is audited while the statement waits. The single event for it is
+// written after, carrying the outcome and the approver, so the record is of
+// what happened rather than of what was asked.
+func (g *Gate) awaitApproval(
+	ctx context.Context, st *Stack, dir hoopinspect.Direction,
+	stmt hoopinspect.Statement, v policy.Verdict,
+) policy.Verdict {
+	if dir == hoopinspect.FromServer {
+		v.Approval = false
+		return v
+	}
+	g.polCtxOnce.Do(func() { g.polCtx = g.sess.PolicyContext() })
+	if g.cfg.Hold != nil {
+		resume := g.cfg.Hold(stmt)
+		defer resume()
+	}
+	return policy.AwaitApproval(ctx, st.Approver, v, policy.ApprovalRequest{
+		Lane:      g.sess.Connection,
+		Rule:      v.Rule,
+		Message:   v.Message,
+		Statement: stmt,
+		Context:   g.polCtx,
+	})
+}
+
+// ResponseAtBoundary reports whether the response bytes forwarded so far end
+// on a message boundary, so a caller may write a message of its own to the
+// client without landing inside one of the server's.
+//
+// A re-framing codec and a row limiter only ever hand back whole messages and
+// hold any partial one themselves. Otherwise the payload is the chunk as
+// read, and it ended on a boundary exactly when the codec kept nothing back.
+//
+// Like Response, it must not run concurrently with Response.
+func (g *Gate) ResponseAtBoundary() bool {
+	st := g.stack()
+	if (g.reframer != nil && st.Masker != nil) || g.limiting(st) {
+		return true
+	}
+	return g.server.Buffered() == 0
 }
 
 func (g *Gate) writeAudit(ctx context.Context, ev audit.Event) error {
@@ -277,6 +277,95 @@ func TestNilPolicyAllowsEverythingButStillAudits(t *testing.T) {
 	}
 }
 
+// A gate built with a Live reads the stack stored into it at the next
+// message, so a reload reaches a connection that was already open.
+func TestLiveStackReachesAnOpenGate(t *testing.T) {
+	live := gate.NewLive(gate.Stack{})
+	g, _ := gate.New(newSession(), gate.Config{
+		Protocol: hoopinspect.Postgres,
+		Policy:   denyDrops(t), // ignored: Live replaces it
+		Audit:    &recordingSink{},
+		Live:     live,
+	})
+
+	if d := g.Request(context.Background(), pgQuery("DROP TABLE customers")); !d.Allowed {
+		t.Fatal("denied before the stack had a policy")
+	}
+	live.Store(gate.Stack{Policy: denyDrops(t)})
+	if d := g.Request(context.Background(), pgQuery("DROP TABLE customers")); d.Allowed {
+		t.Error("the stored policy did not reach the open gate")
+	}
+}
+
+// approverFunc adapts a function to policy.Approver.
+type approverFunc func(policy.ApprovalRequest) policy.Approval
+
+func (f approverFunc) Approve(_ context.Context, req policy.ApprovalRequest) (policy.Approval, error) {
+	return f(req), nil
+}
+
+// A held statement waits on the approver, inside Hold, and its one audit
+// event says how the wait ended and who ended it.
+func TestHeldStatementWaitsForTheApprover(t *testing.T) {
+	hold, err := policy.NewRules([]policy.Rule{{
+		Name: "drop-needs-approval", Type: policy.MatchOperation,
+		Operations: []hoopinspect.Operation{hoopinspect.OpDrop},
+		Action:     policy.ActionApprove,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, approve := range []bool{true, false} {
+		var (
+			asked          policy.ApprovalRequest
+			held, resumed  bool
+			heldWhileAsked bool
+		)
+		sink := &recordingSink{}
+		g, _ := gate.New(newSession(), gate.Config{
+			Protocol: hoopinspect.Postgres,
+			Policy:   hold,
+			Audit:    sink,
+			Approver: approverFunc(func(req policy.ApprovalRequest) policy.Approval {
+				asked, heldWhileAsked = req, held && !resumed
+				return policy.Approval{Approved: approve, Approver: "bob@example.com"}
+			}),
+			Hold: func(hoopinspect.Statement) func() {
+				held = true
+				return func() { resumed = true }
+			},
+		})
+
+		d := g.Request(context.Background(), pgQuery("DROP TABLE staging"))
+		if d.Allowed != approve {
+			t.Fatalf("approve=%v: Allowed = %v", approve, d.Allowed)
+		}
+		if !heldWhileAsked || !resumed {
+			t.Errorf("approve=%v: the approver was not asked inside Hold", approve)
+		}
+		if asked.Lane != "appdb" || asked.Rule != "drop-needs-approval" ||
+			asked.Context["principal"] != "alice@example.com" {
+			t.Errorf("approve=%v: request = %+v", approve, asked)
+		}
+
+		kind, outcome := audit.KindStatement, policy.ApprovalApproved
+		if !approve {
+			kind, outcome = audit.KindViolation, policy.ApprovalDenied
+		}
+		ev := sink.find(kind)
+		if ev == nil {
+			t.Fatalf("approve=%v: no %s event", approve, kind)
+		}
+		if ev.Metadata[policy.AnnotationApproval] != outcome ||
+			ev.Metadata[policy.AnnotationApprover] != "bob@example.com" {
+			t.Errorf("approve=%v: metadata = %v", approve, ev.Metadata)
+		}
+		if ev.Rule != "drop-needs-approval" {
+			t.Errorf("approve=%v: Rule = %q, want the holding rule on the record", approve, ev.Rule)
+		}
+	}
+}
+
 // A partial message cannot be judged, so the gate holds it.
 func TestPartialMessageIsBufferedNotJudged(t *testing.T) {
 	sink := &recordingSink{}
@@ -546,6 +635,31 @@ func TestPolicyContextCarriesIdentity(t *testing.T) {
 	}
 }
 
+// A pgwire relay names the user after the gate exists, from the
+// StartupMessage. A rule keyed on the principal must see that name, not the
+// anonymous one the session had at New.
+func TestPolicyContextReadsIdentitySetAfterNew(t *testing.T) {
+	rules, err := policy.NewRules([]policy.Rule{{
+		Name: "one-each", Type: policy.MatchRate,
+		Rate: &policy.RateSpec{Limit: 1, WindowSec: 3600},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := func(user string) *gate.Gate {
+		sess := session.New(hoopinspect.Postgres, session.Identity{})
+		g, _ := gate.New(sess, gate.Config{Protocol: hoopinspect.Postgres, Policy: rules})
+		sess.Identity.Subject = user
+		return g
+	}
+	if d := open("alice").Request(context.Background(), pgQuery("SELECT 1")); !d.Allowed {
+		t.Fatal("alice's first statement was denied")
+	}
+	if d := open("bob").Request(context.Background(), pgQuery("SELECT 1")); !d.Allowed {
+		t.Error("bob was counted as the same principal as alice")
+	}
+}
+
 // The HTTP codec exercises the same gate, including response-side policy, the
 // case Envoy's ext_authz cannot express.
 func TestHTTPResponsePolicy(t *testing.T) {
@@ -715,3 +829,156 @@ func TestMaskingStillAppliesWhenHeaderAndBodyArriveTogether(t *testing.T) {
 		t.Errorf("declared %d but body is %d bytes: %q", declared, len(body), d.Payload)
 	}
 }
+
+// pgResult builds a one-column Postgres result set of n rows, with its
+// RowDescription and CommandComplete.
+func pgResult(n int) []byte {
+	msg := func(tag byte, payload []byte) []byte {
+		b := []byte{tag}
+		b = binary.BigEndian.AppendUint32(b, uint32(len(payload)+4))
+		return append(b, payload...)
+	}
+	desc := binary.BigEndian.AppendUint16(nil, 1)
+	desc = append(desc, "id\x00"...)
+	desc = append(desc, make([]byte, 18)...)
+	out := msg('T', desc)
+	for i := 1; i <= n; i++ {
+		v := strconv.Itoa(i)
+		row := binary.BigEndian.AppendUint16(nil, 1)
+		row = binary.BigEndian.AppendUint32(row, uint32(len(v)))
+		out = append(out, msg('D', append(row, v...))...)
+	}
+	return append(out, msg('C', []byte("SELECT "+strconv.Itoa(n)+"\x00"))...)
+}
+
+func resultRows(t *testing.T, stream []byte) int {
+	t.Helper()
+	insp, err := hoopinspect.New(hoopinspect.Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := insp.Inspect(hoopinspect.FromServer, stream)
+	if err != nil {
+		t.Fatalf("limited stream is not valid pgwire: %v", err)
+	}
+	if len(stmts) != 1 || stmts[0].Result == nil {
+		t.Fatalf("got %+v, want one result", stmts)
+	}
+	return stmts[0].Result.RowCount
+}
+
+// A denying limit refuses the response the moment a row crosses it, and
+// records the refusal as a violation.
+func TestResultLimitDenies(t *testing.T) {
+	sink := &recordingSink{}
+	g, _ := gate.New(newSession(), gate.Config{
+		Protocol: hoopinspect.Postgres,
+		Audit:    sink,
+		Limits:   []policy.ResultLimit{{Name: "rows", MaxRows: 3, Message: "too many rows"}},
+	})
+
+	d := g.Response(context.Background(), pgResult(5))
+	if d.Allowed || d.Payload != nil {
+		t.Fatalf("allowed a result over the limit: %+v", d)
+	}
+	if d.Rule != "rows" || d.Message != "too many rows" {
+		t.Errorf("rule %q message %q", d.Rule, d.Message)
+	}
+	ev := sink.find(audit.KindViolation)
+	if ev == nil || ev.Rule != "rows" || ev.Metadata["limit.rows"] != "3" || ev.Metadata["limit.action"] != "deny" {
+		t.Errorf("violation = %+v", ev)
+	}
+	if _, denied := g.Stats(); denied != 1 {
+		t.Errorf("denied = %d, want 1", denied)
+	}
+}
+
+// A truncating limit delivers the rows within it and a well-formed end, and
+// records one event for the result set however many reads it spans.
+func TestResultLimitTruncates(t *testing.T) {
+	sink := &recordingSink{}
+	g, _ := gate.New(newSession(), gate.Config{
+		Protocol: hoopinspect.Postgres,
+		Audit:    sink,
+		Limits:   []policy.ResultLimit{{Name: "bytes", MaxResultBytes: 40, Action: policy.LimitTruncate}},
+	})
+
+	stream := pgResult(10)
+	var out []byte
+	for i := 0; i < len(stream); i += 7 {
+		d := g.Response(context.Background(), stream[i:min(i+7, len(stream))])
+		if !d.Allowed {
+			t.Fatalf("truncating limit denied: %+v", d)
+		}
+		out = append(out, d.Payload...)
+	}
+	out = append(out, g.FlushResponse()...)
+
+	// Each row is 12 wire bytes, so three fit under 40.
+	if got := resultRows(t, out); got != 3 {
+		t.Errorf("delivered %d rows, want 3", got)
+	}
+	var limited int
+	for _, k := range sink.kinds() {
+		if k == audit.KindLimited {
+			limited++
+		}
+	}
+	if limited != 1 {
+		t.Errorf("got %d limited events, want 1: %v", limited, sink.kinds())
+	}
+}
+
+// Limits apply per identity: one naming other principals or groups does not
+// touch this session.
+func TestResultLimitSelectsByIdentity(t *testing.T) {
+	g, _ := gate.New(newSession(), gate.Config{
+		Protocol: hoopinspect.Postgres,
+		Limits: []policy.ResultLimit{
+			{Name: "contractors", MaxRows: 1, Groups: []string{"contractors"}},
+			{Name: "bob", MaxRows: 1, Principals: []string{"bob@example.com"}},
+		},
+	})
+	if d := g.Response(context.Background(), pgResult(5)); !d.Allowed || resultRows(t, d.Payload) != 5 {
+		t.Errorf("a limit for someone else applied: %+v", d)
+	}
+
+	g, _ = gate.New(newSession(), gate.Config{
+		Protocol: hoopinspect.Postgres,
+		Limits:   []policy.ResultLimit{{Name: "alice", MaxRows: 1, Principals: []string{"alice@example.com"}}},
+	})
+	if d := g.Response(context.Background(), pgResult(5)); d.Allowed {
+		t.Error("a limit naming this principal did not apply")
+	}
+}
+
+// Observing records the crossing and cuts nothing.
+func TestResultLimitObserveOnly(t *testing.T) {
+	sink := &recordingSink{}
+	g, _ := gate.New(newSession(), gate.Config{
+		Protocol:      hoopinspect.Postgres,
+		Audit:         sink,
+		Limits:        []policy.ResultLimit{{Name: "rows", MaxRows: 2}},
+		ObserveLimits: true,
+	})
+	d := g.Response(context.Background(), pgResult(5))
+	if !d.Allowed || resultRows(t, d.Payload) != 5 {
+		t.Fatalf("observe-only limit changed the response: %+v", d)
+	}
+	if ev := sink.find(audit.KindLimited); ev == nil || ev.Metadata["limit.action"] != "observe" {
+		t.Errorf("limited event = %+v", ev)
+	}
+}
+
+func TestLimitSupported(t *testing.T) {
+	for p, want := range map[hoopinspect.Protocol]bool{
+		hoopinspect.Postgres: true,
+		hoopinspect.MySQL:    true,
+		hoopinspect.HTTP:     false,
+		hoopinspect.MongoDB:  false,
+	} {
+		if got := gate.LimitSupported(p); got != want {
+			t.Errorf("LimitSupported(%s) = %v, want %v", p, got, want)
+		}
+	}
+}
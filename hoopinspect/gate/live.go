@@ -0,0 +1,50 @@
+package gate
+
+import (
+	"sync/atomic"
+
+	"github.com/hoophq/hoopinspect/policy"
+)
+
+// Stack is the part of a Gate's configuration a reload may replace: what it
+// decides and how it rewrites, as opposed to what it speaks and where it
+// records. Protocol, Audit and the codec are fixed for a connection's life,
+// because changing them mid-stream would leave half a message decoded by one
+// codec and half by another.
+type Stack struct {
+	Policy        policy.Evaluator
+	Masker        Masker
+	Limits        []policy.ResultLimit
+	ObserveLimits bool
+	Approver      policy.Approver
+}
+
+// Live holds the Stack every Gate built with it reads, so that a reload
+// reaches connections that are already open.
+//
+// The alternative is a stack fixed at New, and then a rule change only
+// applies to connections opened after it: the psql session that has been
+// open since Monday keeps running last week's policy until someone kills it,
+// which is the restart a reload exists to avoid.
+//
+// A Gate loads the Stack once per chunk, so every statement in one read is
+// judged by one stack, and a swap takes effect at the next read. Store is
+// atomic; there is no moment at which a gate sees one lane's policy with
+// another revision's masks.
+type Live struct {
+	cur atomic.Pointer[Stack]
+}
+
+// NewLive returns a Live holding s.
+func NewLive(s Stack) *Live {
+	l := &Live{}
+	l.Store(s)
+	return l
+}
+
+// Load returns the current stack. The result is shared and must not be
+// modified.
+func (l *Live) Load() *Stack { return l.cur.Load() }
+
+// Store replaces the stack for every gate reading this Live.
+func (l *Live) Store(s Stack) { l.cur.Store(&s) }
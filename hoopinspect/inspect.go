@@ -59,7 +59,10 @@ type Protocol string
 const (
 	Postgres Protocol = "postgres"
 	MSSQL    Protocol = "mssql"
+	MySQL    Protocol = "mysql"
+	MongoDB  Protocol = "mongodb"
 	HTTP     Protocol = "http"
+	GRPC     Protocol = "grpc"
 )
 
 // Direction says which side of the connection produced the bytes. Only
@@ -158,8 +161,16 @@ type Statement struct {
 	// could not tell", never "touches nothing".
 	Tables []string `json:"tables,omitempty"`
 
+	// UnboundedWrites lists each UPDATE and DELETE in the statement that no
+	// predicate limits, so it changes every row of its targets: no WHERE,
+	// `WHERE 1=1`, or a LIMIT standing in for one. Empty when Operation is
+	// OpUnknown, which means the scan could not tell rather than that there
+	// are none.
+	UnboundedWrites []UnboundedWrite `json:"unbounded_writes,omitempty"`
+
 	// Database is the target database when the protocol states it explicitly,
-	// which for Postgres is only at login.
+	// which for Postgres is only at login, for MySQL is at login and on
+	// every COM_INIT_DB, and for MongoDB is on every command.
 	Database string `json:"database,omitempty"`
 
 	// HTTP is set only for the http protocol and carries the request/response
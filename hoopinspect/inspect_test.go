@@ -290,3 +290,27 @@ func TestClassifyDeduplicatesTables(t *testing.T) {
 		t.Errorf("Tables = %v, want [t]", tables)
 	}
 }
+
+// Columns lowercase the way relation names do, so quoting a protected column
+// does not step around a rule naming it.
+func TestAnalyzeSQLColumns(t *testing.T) {
+	a := hoopinspect.AnalyzeSQL(`UPDATE "Customers" SET "SSN" = ssn WHERE id = 1`, hoopinspect.Postgres)
+	if !a.ColumnsComplete || len(a.Relations) != 1 {
+		t.Fatalf("analysis = %+v", a)
+	}
+	var ssn hoopinspect.ColumnRef
+	for _, c := range a.Relations[0].Columns {
+		if c.Name == "ssn" {
+			ssn = c
+		}
+	}
+	if !ssn.Has(hoopinspect.ColumnWritten) || !ssn.Has(hoopinspect.ColumnProjected) || ssn.Has(hoopinspect.ColumnFiltered) {
+		t.Errorf("ssn = %+v, want one entry both written and projected", ssn)
+	}
+
+	a = hoopinspect.AnalyzeSQL("SELECT x.ssn FROM customers c", hoopinspect.Postgres)
+	if !a.Complete || a.ColumnsComplete || a.ColumnsReason == "" {
+		t.Errorf("an unresolvable qualifier: Complete = %v, ColumnsComplete = %v, reason %q",
+			a.Complete, a.ColumnsComplete, a.ColumnsReason)
+	}
+}
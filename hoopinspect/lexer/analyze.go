@@ -28,6 +28,30 @@ type Relation struct {
 	Access Access
 }
 
+// WriteScope is one UPDATE or DELETE, and whether anything limits the rows it
+// changes.
+//
+// It exists for the statement nobody meant to run: `DELETE FROM customers`
+// sent with its WHERE clause still on the next line of the editor. Effects
+// and Relations already say that a delete writes customers. Neither can say
+// that it writes all of it.
+type WriteScope struct {
+	// Verb is Update or Delete.
+	Verb Verb
+
+	// Targets names the relations it writes, in order. Empty when the scan
+	// could not resolve them, which is not "writes nothing".
+	Targets []string
+
+	// Filtered reports a predicate limiting the rows: a WHERE that names
+	// something other than constants, or, under MySQL and T-SQL, the ON or
+	// USING of a join the target takes part in.
+	//
+	// LIMIT and TOP do not count. They bound how many rows change, not
+	// which ones, and `DELETE FROM t LIMIT 1000` in a retry loop empties t.
+	Filtered bool
+}
+
 // Analysis is what one statement does.
 type Analysis struct {
 	// Verb is the leading statement verb as written. It answers "what did
@@ -44,6 +68,11 @@ type Analysis struct {
 	// dominating read for a relation that is both.
 	Relations []Relation
 
+	// Scopes lists every UPDATE and DELETE in the statement, in order of
+	// appearance. A MERGE contributes none: its ON clause has already
+	// chosen the rows each of its branches sees.
+	Scopes []WriteScope
+
 	// Complete reports whether the scan understood the whole statement.
 	//
 	// FALSE MUST FAIL CLOSED. Every other field is best-effort when this is
@@ -52,6 +81,29 @@ type Analysis struct {
 
 	// Reason names what defeated the scan. Empty when Complete.
 	Reason string
+
+	// Columns maps a relation's Name to the columns the statement names on
+	// it, and how each is used. A relation absent from it names none, as in
+	// `SELECT count(*) FROM t`, and that reading holds only when
+	// ColumnsComplete does. AllColumns stands for a `*`, or for a write the
+	// statement did not narrow to columns.
+	//
+	// Beside Relations rather than inside it so that a Relation stays a
+	// comparable value.
+	Columns map[string][]Column
+
+	// ColumnsComplete reports whether every column reference was
+	// attributed to a relation. It is false whenever Complete is, and when
+	// a qualifier named nothing in scope or the statement is one the
+	// column scan does not model, such as a MERGE.
+	//
+	// A `SELECT *` does not clear it. That is incomplete for the relations
+	// it reads and for no other, and AllColumns on their Columns says so.
+	ColumnsComplete bool
+
+	// ColumnsReason names what defeated the column scan. Empty when
+	// ColumnsComplete.
+	ColumnsReason string
 }
 
 // Severity returns the most consequential effect, for a caller that needs one
@@ -71,6 +123,18 @@ func (a Analysis) Writes() bool {
 	return slices.ContainsFunc(a.Effects, Verb.mutating)
 }
 
+// Unfiltered returns the scopes no predicate limits: each is an UPDATE or
+// DELETE that changes every row of its targets.
+func (a Analysis) Unfiltered() []WriteScope {
+	var out []WriteScope
+	for _, s := range a.Scopes {
+		if !s.Filtered {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
 // Names returns the relation names, order preserved. It backs the legacy
 // flat Tables view.
 func (a Analysis) Names() []string {
@@ -103,19 +167,37 @@ type region struct {
 	// y` attributes x to the create and y to the select.
 	verb Verb
 
-	// firstTarget tracks whether this region has claimed its write target
+	// firstTarget tracks whether this region has claimed its write targets
 	// yet. `DELETE FROM a USING b` writes a and reads b, and only position
 	// distinguishes them.
+	//
+	// Targets are claimed a LIST at a time, not one name: MySQL's
+	// `DELETE FROM a, b USING ...` writes both. An UPDATE claims until SET,
+	// because MySQL's `UPDATE a JOIN b ON ... SET b.x = 1` can write every
+	// table before it.
 	firstTarget bool
+
+	// scope is one more than the index in analyzer.scopes of the UPDATE or
+	// DELETE heading this region, so the zero region heads none. A nested
+	// region never inherits it: the WHERE of a subquery in SET filters the
+	// subquery, not the target.
+	scope int
+
+	// merge marks a region a MERGE heads. Its `THEN UPDATE` and
+	// `THEN DELETE` branches are heads on the same region, and they open
+	// no scope of their own.
+	merge bool
 }
 
 type analyzer struct {
-	toks []Token
-	d    Dialect
+	toks  []Token
+	d     Dialect
+	rules lexRules
 
 	stack   []region
 	effects []Verb
 	rels    []Relation
+	scopes  []WriteScope
 
 	// cteNames are bound CTE aliases. A relation matching one is not a base
 	// relation, so a `tables: [x]` rule stops matching someone's CTE and a
@@ -141,6 +223,11 @@ type analyzer struct {
 	wrapper      bool
 	sawStatement bool
 
+	// renaming is true in MySQL's `RENAME TABLE a TO b, c TO d`, whose list
+	// continues across TO as well as commas. Every name in it is written:
+	// the old name disappears and the new one appears.
+	renaming bool
+
 	incomplete string
 }
 
@@ -154,6 +241,7 @@ func Analyze(sql string, d Dialect) Analysis {
 	a := &analyzer{
 		toks:       toks,
 		d:          d,
+		rules:      d.rules(),
 		stack:      []region{{kind: regTop, verb: Unknown}},
 		cteNames:   map[string]bool{},
 		incomplete: bad,
@@ -247,6 +335,17 @@ func (a *analyzer) walk() {
 			continue
 		}
 
+		// Oracle's MERGE closes its update branch with `DELETE WHERE
+		// cond`, a delete no keyword introduces. Nowhere else does
+		// DELETE meet WHERE directly.
+		if t.Text == "delete" && i+1 < len(a.toks) && a.toks[i+1].isWord("where") {
+			atHead = true
+		}
+
+		if r := a.top(); r.scope > 0 && a.filters(i) {
+			a.scopes[r.scope-1].Filtered = true
+		}
+
 		if atHead && a.head(t, i) {
 			// Several keywords are both a verb and a relation
 			// introducer: UPDATE t, TRUNCATE t, COPY t. Consuming the
@@ -275,6 +374,15 @@ func (a *analyzer) walk() {
 			continue
 		}
 
+		switch {
+		case t.Text == "set" && a.top().verb == Update:
+			// The table list of an UPDATE ends here. What follows
+			// SET, including a FROM, is read.
+			a.top().firstTarget = false
+		case t.Text == "sql_mode" && a.rules.sqlModeQuoting && a.top().verb == Set:
+			a.sqlModeAssignment(i)
+		}
+
 		atHead = a.headFollows(t.Text)
 	}
 
@@ -358,6 +466,12 @@ func (a *analyzer) head(t Token, i int) bool {
 	if why, bad := opaque[t.Text]; bad {
 		a.fail(why)
 	}
+	if why, block := plsqlBlock[t.Text]; block && a.rules.plsql {
+		// The scanner has already made the body one literal, so nothing
+		// inside it reaches the walk.
+		verb = Call
+		a.fail(why)
+	}
 
 	if verb == Explain {
 		a.explainSeen = true
@@ -369,6 +483,7 @@ func (a *analyzer) head(t Token, i int) bool {
 	}
 
 	a.wrapper = false
+	a.renaming = t.Text == "rename"
 	if a.top().kind == regTop {
 		// The statement the WITH prefixed has begun, so a later `AS (`
 		// is a subquery rather than another CTE body.
@@ -377,7 +492,20 @@ func (a *analyzer) head(t Token, i int) bool {
 	r := a.top()
 	r.verb = verb
 	r.firstTarget = true
+	r.scope = 0
+	switch {
+	case verb == Merge:
+		r.merge = true
+	case (verb == Update || verb == Delete) && !r.merge:
+		a.scopes = append(a.scopes, WriteScope{Verb: verb})
+		r.scope = len(a.scopes)
+	}
 	a.effects = append(a.effects, verb)
+	if t.Text == "replace" {
+		// MySQL's REPLACE deletes the row a new one conflicts with before
+		// inserting it. A rule refusing deletes must see that.
+		a.effects = append(a.effects, Delete)
+	}
 	return true
 }
 
@@ -406,8 +534,17 @@ func (a *analyzer) relationsAfter(i int) (int, []Relation, bool) {
 			j++
 			continue
 		}
+		if a.renaming && j+1 < len(a.toks) && a.toks[j+1].isWord("to") {
+			j++
+			continue
+		}
 		break
 	}
+	if r := a.top(); len(out) > 0 && out[0].Access == Write && r.verb != Update {
+		// The list was this statement's targets; anything named later is
+		// a source. An UPDATE's list runs to SET instead; see walk.
+		r.firstTarget = false
+	}
 	return j, out, len(out) > 0
 }
 
@@ -418,6 +555,14 @@ func (a *analyzer) relationAt(i, j int) (int, Relation, bool) {
 	for j < len(a.toks) && a.toks[j].Kind == Word && relSkip[a.toks[j].Text] {
 		j++
 	}
+	if j < len(a.toks) && a.toks[j].Kind == Punct && a.toks[j].Text == "(" &&
+		a.toks[i].Text != "insert" && a.writeTarget(a.toks[i].Text) {
+		// Oracle writes through an inline view: `UPDATE (SELECT ...) SET`.
+		// The base table is inside the parentheses, in a position this
+		// scan reads as a source, so the write would be lost. A MERGE
+		// branch's `THEN INSERT (id) VALUES` is a column list instead.
+		a.fail("write through an inline view")
+	}
 	if j >= len(a.toks) || !a.toks[j].isName() {
 		return j, Relation{}, false
 	}
@@ -438,6 +583,11 @@ func (a *analyzer) relationAt(i, j int) (int, Relation, bool) {
 		name += "." + a.toks[j+2].Text
 		j += 2
 	}
+	// MySQL's multi-table DELETE may name a target as t.*.
+	if j+2 < len(a.toks) && a.toks[j+1].Kind == Punct && a.toks[j+1].Text == "." &&
+		a.toks[j+2].Kind == Punct && a.toks[j+2].Text == "*" {
+		j += 2
+	}
 
 	// A CTE alias is not a relation. Reporting it would put someone's
 	// `WITH doomed AS ...` into a table list beside real objects.
@@ -517,18 +667,23 @@ func (a *analyzer) access(r *region, intro string) Access {
 			return Write
 		}
 	case Delete:
-		if intro == "from" && r.firstTarget {
-			r.firstTarget = false
+		if (intro == "from" || intro == "delete") && r.firstTarget {
 			return Write
 		}
 	case Update:
-		if intro == "update" && r.firstTarget {
-			r.firstTarget = false
+		// MySQL joins its targets: `UPDATE a JOIN b ON ... SET` may
+		// write either.
+		if (intro == "update" || intro == "join") && r.firstTarget {
+			return Write
+		}
+	case Insert:
+		// Every INTO of an insert is a target. Oracle's INSERT ALL
+		// names several.
+		if intro == "into" || intro == "insert" || intro == "replace" {
 			return Write
 		}
-	case Insert, Merge:
+	case Merge:
 		if intro == "into" && r.firstTarget {
-			r.firstTarget = false
 			return Write
 		}
 	case Copy:
@@ -554,7 +709,111 @@ func (a *analyzer) access(r *region, intro string) Access {
 	return Read
 }
 
+// writeTarget reports whether a relation after this introducer would be the
+// statement's write target.
+func (a *analyzer) writeTarget(intro string) bool {
+	switch a.top().verb {
+	case Insert, Update, Delete, Merge:
+		return introduces(intro, a.top().verb) && a.access(a.top(), intro) == Write
+	}
+	return false
+}
+
+// sqlModeAssignment concedes a SET of sql_mode that may change how MySQL
+// quotes; see lexRules.sqlModeQuoting. i is the sql_mode token.
+//
+// Only one literal naming no quoting mode, or DEFAULT, is accepted. Anything
+// else, a variable or an expression, is a value the scan cannot read.
+func (a *analyzer) sqlModeAssignment(i int) {
+	j := i + 1
+	for j < len(a.toks) && a.toks[j].Kind == Punct && (a.toks[j].Text == "=" || a.toks[j].Text == ":") {
+		j++
+	}
+	if j == i+1 {
+		// Not assigned here: `SET @saved = @@sql_mode` reads it.
+		return
+	}
+	end := j
+	for end < len(a.toks) && !(a.toks[end].Kind == Punct && (a.toks[end].Text == "," || a.toks[end].Text == ";")) {
+		end++
+	}
+	if v := a.toks[j:end]; len(v) == 1 &&
+		(v[0].isWord("default") || (v[0].Kind == Literal && !v[0].quotingMode)) {
+		return
+	}
+	a.fail("sql_mode may change how later statements are quoted")
+}
+
+// filters reports whether the keyword at i opens a predicate limiting the rows
+// of the UPDATE or DELETE heading its region.
+func (a *analyzer) filters(i int) bool {
+	t := a.toks[i]
+	switch {
+	case t.isWord("where"):
+	case a.rules.joinFiltersTarget && t.isWord("on"):
+	case a.rules.joinFiltersTarget && t.isWord("using") &&
+		i+1 < len(a.toks) && a.toks[i+1].Kind == Punct && a.toks[i+1].Text == "(":
+		// `JOIN b USING (id)`. Without the parenthesis this is MySQL's
+		// `DELETE FROM a USING a, b`, which is a table list.
+	default:
+		return false
+	}
+	return a.namesColumn(i + 1)
+}
+
+// namesColumn reports whether the predicate starting at j names anything but
+// constants.
+//
+// `WHERE 1=1` and `WHERE true` are what a query builder emits so that every
+// condition it appends can begin with AND, and what remains when each of
+// those conditions came out empty. They filter nothing. A predicate naming a
+// column is taken at its word: `WHERE id = id` is a tautology too, and
+// telling it apart takes an evaluator rather than a scanner.
+func (a *analyzer) namesColumn(j int) bool {
+	depth := 0
+	for ; j < len(a.toks); j++ {
+		t := a.toks[j]
+		switch t.Kind {
+		case Punct:
+			switch t.Text {
+			case "(":
+				depth++
+			case ")":
+				if depth == 0 {
+					return false
+				}
+				depth--
+			case ";":
+				return false
+			case ",":
+				if depth == 0 {
+					return false
+				}
+			}
+		case Quoted:
+			return true
+		case Word:
+			if depth == 0 && (predicateEnd[t.Text] ||
+				j+1 < len(a.toks) && (a.toks[j+1].isWord("join") || a.toks[j+1].isWord("outer"))) {
+				// The clause after the predicate, or a LEFT, RIGHT
+				// or FULL starting the next join.
+				return false
+			}
+			if !constantWord[t.Text] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (a *analyzer) addRelation(rel Relation) {
+	if r := a.top(); r.scope > 0 && rel.Access == Write {
+		s := &a.scopes[r.scope-1]
+		if !slices.Contains(s.Targets, rel.Name) {
+			s.Targets = append(s.Targets, rel.Name)
+		}
+	}
 	for i := range a.rels {
 		if a.rels[i].Name != rel.Name {
 			continue
@@ -590,20 +849,68 @@ func (a *analyzer) result() Analysis {
 		verb = Other
 	}
 
-	effects := a.effects
+	effects, scopes := a.effects, a.scopes
 	if a.explainSeen && !a.analyzeSeen {
 		// A plan is not an execution. Keeping the mutating effects here
 		// would refuse `EXPLAIN DELETE ...`, which changes nothing and is
 		// how a developer checks whether their WHERE clause is right.
-		effects = nil
+		// The target it names is read for statistics, not written, which
+		// matters once an option list such as MySQL's FORMAT=TREE or
+		// Oracle's PLAN FOR has let the inner DELETE head its statement.
+		effects, scopes = nil, nil
 		verb = Explain
+		for i := range a.rels {
+			a.rels[i].Access = Read
+		}
+	}
+
+	cols, why := a.columns()
+	if a.incomplete != "" {
+		why = a.incomplete
+	}
+	for _, rel := range a.rels {
+		switch {
+		case a.explainSeen && !a.analyzeSeen:
+			// The plan writes nothing, whatever it plans.
+			cols[rel.Name] = readOnly(cols[rel.Name])
+		case rel.Access == Write && !slices.ContainsFunc(cols[rel.Name], func(c Column) bool { return c.Use&Written != 0 }):
+			// A write the scan could not narrow to columns, a DROP,
+			// a TRUNCATE or a SELECT INTO, writes every column.
+			cols[rel.Name] = append(cols[rel.Name], Column{Name: AllColumns, Use: Written})
+		}
+		if len(cols[rel.Name]) == 0 {
+			delete(cols, rel.Name)
+		}
+	}
+	for name := range cols {
+		// A name the relation walk did not report, such as a table
+		// function it declined, is not one a caller can look up.
+		if !slices.ContainsFunc(a.rels, func(r Relation) bool { return r.Name == name }) {
+			delete(cols, name)
+		}
 	}
 
 	return Analysis{
-		Verb:      verb,
-		Effects:   effects,
-		Relations: a.rels,
-		Complete:  a.incomplete == "",
-		Reason:    a.incomplete,
+		Verb:            verb,
+		Effects:         effects,
+		Relations:       a.rels,
+		Scopes:          scopes,
+		Complete:        a.incomplete == "",
+		Reason:          a.incomplete,
+		Columns:         cols,
+		ColumnsComplete: why == "",
+		ColumnsReason:   why,
 	}
 }
+
+// readOnly drops the Written use from columns, and the columns left with no
+// use at all.
+func readOnly(cols []Column) []Column {
+	var out []Column
+	for _, c := range cols {
+		if c.Use &^= Written; c.Use != 0 {
+			out = append(out, c)
+		}
+	}
+	return out
+}
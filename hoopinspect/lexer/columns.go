@@ -0,0 +1,983 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ColumnUse says how a statement uses a column. One column can be used
+// several ways at once, so it is a set.
+type ColumnUse uint8
+
+const (
+	// Projected is a column whose value the statement hands on: a select
+	// list, a RETURNING or OUTPUT, the right side of a SET.
+	Projected ColumnUse = 1 << iota
+
+	// Filtered is a column a predicate or an ordering reads: WHERE, ON,
+	// USING, HAVING, GROUP BY, ORDER BY, an ON CONFLICT target.
+	//
+	// A filter is a read. `WHERE ssn LIKE '123%'` answers a question about
+	// ssn one row at a time and never projects it, and a binary search over
+	// that predicate recovers the value.
+	Filtered
+
+	// Written is a column the statement changes: an INSERT's column list,
+	// an UPDATE's SET targets. A DELETE writes every column of its targets.
+	Written
+)
+
+// AllColumns is the Column name standing for every column of a relation: a
+// `SELECT *`, an INSERT with no column list, a DELETE.
+//
+// It is what a request-side scan can say about `SELECT *` and no more. Which
+// columns came back is a fact about the catalog, and only the response's
+// row description carries it.
+const AllColumns = "*"
+
+// Column is one column a statement references on one relation.
+type Column struct {
+	// Name is the column as written: lowercased when bare, verbatim when
+	// quoted. AllColumns when the statement did not name them.
+	Name string
+	Use  ColumnUse
+}
+
+// clause is the part of a query block a token sits in, which decides what a
+// column named there is used for.
+type clause uint8
+
+const (
+	// clNone names no columns: before the verb, LIMIT, FOR UPDATE, the INTO
+	// of a SELECT INTO.
+	clNone clause = iota
+	clProject
+	clItems
+	clFilter
+	clAssign
+	clValues
+	clConflict
+)
+
+// use is what a column named under c is used for.
+func (c clause) use() ColumnUse {
+	switch c {
+	case clProject, clAssign, clValues:
+		return Projected
+	case clFilter, clConflict:
+		return Filtered
+	}
+	return 0
+}
+
+// colItem is one name a query block's columns can resolve against: a FROM
+// item, or a target of the block's verb.
+type colItem struct {
+	// name is the relation as written, schema-qualified when it was. Empty
+	// for a derived table or a function.
+	name  string
+	alias string
+
+	// base is false for a CTE, a derived table or a set-returning
+	// function. Their columns are some other block's columns, and that
+	// block has already reported them against its own relations.
+	base bool
+
+	// target marks a write target of the block's verb.
+	target bool
+}
+
+// names reports whether a qualifier refers to this item.
+//
+// The relation's own name matches even when an alias is declared.
+// PostgreSQL would refuse that reference; matching it anyway costs nothing
+// and attributes it where any engine that accepts it would.
+func (it *colItem) names(qual string) bool {
+	if it.alias != "" && it.alias == qual {
+		return true
+	}
+	if it.name == "" {
+		return false
+	}
+	return it.name == qual ||
+		strings.HasSuffix(it.name, "."+qual) ||
+		strings.HasSuffix(qual, "."+it.name)
+}
+
+// colRef is one column reference. It stays unresolved until the whole
+// statement is walked, because a select list names columns before the FROM
+// that says whose they are.
+type colRef struct {
+	// qual is the qualifier with its parts joined by ".", empty when the
+	// column was named bare.
+	qual string
+	name string
+	use  ColumnUse
+
+	// target resolves a bare name against the block's write targets only:
+	// `UPDATE a SET x = 1 FROM b` writes a.x, whatever b holds.
+	target bool
+
+	// item is set when the reference needed no resolving: the whole row a
+	// DELETE removes, or an INSERT with no column list writes.
+	item *colItem
+}
+
+// colScope is one query block's names.
+type colScope struct {
+	parent *colScope
+
+	// correlated marks a block that can see the one around it: a subquery
+	// in an expression, or a LATERAL item. A derived table or a CTE body
+	// cannot, so a bare name there never belongs to an enclosing block.
+	correlated bool
+
+	items []*colItem
+	refs  []colRef
+
+	// outputs are the select list's aliases, which ORDER BY may name.
+	outputs map[string]bool
+}
+
+// output records a select-list alias.
+func (s *colScope) output(name string) {
+	if s.outputs == nil {
+		s.outputs = map[string]bool{}
+	}
+	s.outputs[name] = true
+}
+
+// colSpec is the walk's position in one query block.
+type colSpec struct {
+	scope *colScope
+
+	// home is the scope of the block's own verb. It differs from scope only
+	// in the SELECT of an INSERT ... SELECT, which cannot see the INSERT's
+	// target and is walked in a scope of its own.
+	home *colScope
+	sub  bool
+
+	verb  Verb
+	state clause
+
+	// targets is true while the items named are the verb's write targets.
+	targets bool
+
+	expectItem  bool
+	expectAlias bool
+
+	// operand is true when the last token ended an operand, so that a bare
+	// word after it, in a select list, is an alias: `SELECT a b FROM t`.
+	operand bool
+
+	// lhs is true at an assignment target in SET.
+	lhs bool
+
+	// funcNext is true when the next parenthesis holds a function's
+	// arguments, where `*` is `count(*)` and not every column.
+	funcNext bool
+
+	// exists is true in the body of EXISTS, whose select list is never
+	// returned: `EXISTS (SELECT * FROM t ...)` reads no column of t.
+	exists bool
+
+	insertCols bool
+	deleteFrom bool
+
+	// ordering is true in ORDER BY, where a bare name is the select list's
+	// alias before it is anybody's column.
+	ordering bool
+}
+
+// restoreHome returns from an INSERT's SELECT to the INSERT, for the clauses
+// that follow it: ON CONFLICT, ON DUPLICATE KEY UPDATE, RETURNING.
+func (st *colSpec) restoreHome() {
+	st.scope, st.sub = st.home, false
+}
+
+// columnWalker attributes column references to relations, in a pass of its
+// own over the tokens the analysis walked.
+//
+// A separate pass rather than more state on analyzer.walk. The relation walk
+// decides access from the introducer before it, one token at a time; column
+// attribution needs a whole query block at once, because `SELECT c.ssn FROM
+// customers c` names the column before the alias that resolves it. So this
+// pass records references as it meets them and resolves them at the end.
+//
+// It is a token scanner like the rest of the package, not a parser, and it
+// errs one way: a bare column in a block reading several relations is
+// attributed to all of them, and one in a correlated subquery to the
+// enclosing blocks as well. Without the catalog there is no telling which
+// relation holds it, and over-attributing costs a false denial where
+// under-attributing costs a bypass.
+type columnWalker struct {
+	a      *analyzer
+	toks   []Token
+	close  []int
+	scopes []*colScope
+	out    map[string][]Column
+
+	incomplete string
+}
+
+// queryHead are the words a query block may open with.
+var queryHead = map[string]bool{
+	"select": true, "with": true, "values": true, "table": true,
+	"insert": true, "update": true, "delete": true, "replace": true,
+}
+
+// notAColumn are the bare words that sit where a column could and never
+// name one. Only reserved words belong here: a column named status, type or
+// key is common, and a word listed here is a column this scan never sees.
+var notAColumn = map[string]bool{
+	"all": true, "and": true, "any": true, "array": true, "as": true,
+	"asc": true, "between": true, "by": true, "case": true,
+	"collate": true, "cross": true, "default": true, "delete": true,
+	"desc": true, "distinct": true, "distinctrow": true, "do": true,
+	"else": true, "end": true, "except": true, "exists": true,
+	"false": true, "fetch": true, "for": true, "from": true, "full": true,
+	"group": true, "having": true, "ilike": true, "in": true,
+	"inner": true, "insert": true, "intersect": true, "into": true,
+	"is": true, "isnull": true, "join": true, "lateral": true,
+	"left": true, "like": true, "limit": true, "merge": true,
+	"minus": true, "natural": true, "not": true, "notnull": true,
+	"null": true, "offset": true, "on": true, "only": true, "or": true,
+	"order": true, "outer": true, "output": true, "option": true,
+	"replace": true, "returning": true, "right": true, "select": true,
+	"set": true, "similar": true, "some": true, "table": true,
+	"then": true, "top": true, "true": true, "union": true,
+	"update": true, "using": true, "values": true, "when": true,
+	"where": true, "window": true, "with": true, "apply": true,
+
+	// Functions SQL spells without parentheses.
+	"current_date": true, "current_time": true, "current_timestamp": true,
+	"current_user": true, "current_role": true, "session_user": true,
+	"user": true, "localtime": true, "localtimestamp": true,
+	"sysdate": true, "systimestamp": true,
+
+	// MySQL's statement modifiers, which sit where a relation or a
+	// select list item would.
+	"ignore": true, "low_priority": true, "high_priority": true,
+	"delayed": true, "quick": true, "straight_join": true,
+	"sql_calc_found_rows": true, "sql_no_cache": true, "sql_cache": true,
+	"sql_small_result": true, "sql_big_result": true,
+	"sql_buffer_result": true,
+}
+
+// operandWord are the keywords that end an operand, so a word after one in
+// a select list is an alias: `CASE ... END AS x`, `NULL x`.
+var operandWord = map[string]bool{
+	"end": true, "null": true, "true": true, "false": true,
+	"current_date": true, "current_time": true, "current_timestamp": true,
+	"current_user": true, "current_role": true, "session_user": true,
+	"user": true, "localtime": true, "localtimestamp": true,
+	"sysdate": true, "systimestamp": true,
+}
+
+// typedLiteral are the type names that prefix a literal, `DATE '2024-01-01'`,
+// and are otherwise ordinary column names.
+var typedLiteral = map[string]bool{
+	"date": true, "time": true, "timestamp": true, "interval": true,
+}
+
+// pseudoRow are the qualifiers naming the row being written rather than a
+// FROM item: PostgreSQL's EXCLUDED, T-SQL's INSERTED and DELETED.
+var pseudoRow = map[string]bool{
+	"excluded": true, "inserted": true, "deleted": true,
+}
+
+// columns attributes the statement's column references to its relations,
+// keyed by relation name as the analysis reports it. The second result is
+// empty when every reference was attributed, and otherwise names what
+// defeated the walk.
+func (a *analyzer) columns() (map[string][]Column, string) {
+	w := &columnWalker{
+		a:     a,
+		toks:  a.toks,
+		close: matchParens(a.toks),
+		out:   map[string][]Column{},
+	}
+	start := 0
+	for i := 0; i <= len(w.toks); i++ {
+		if i < len(w.toks) && !(w.toks[i].Kind == Punct && w.toks[i].Text == ";") {
+			continue
+		}
+		if i > start {
+			w.statement(start, i)
+		}
+		start = i + 1
+	}
+	for _, s := range w.scopes {
+		for _, r := range s.refs {
+			w.resolve(s, r)
+		}
+	}
+	return w.out, w.incomplete
+}
+
+// matchParens returns, for each "(", the index of the ")" closing it, and
+// len(toks) for one never closed. The analysis has already failed on
+// unbalanced input; this only has to not run off the end.
+func matchParens(toks []Token) []int {
+	out := make([]int, len(toks))
+	var open []int
+	for i, t := range toks {
+		out[i] = len(toks)
+		if t.Kind != Punct {
+			continue
+		}
+		switch t.Text {
+		case "(":
+			open = append(open, i)
+		case ")":
+			if n := len(open); n > 0 {
+				out[open[n-1]] = i
+				open = open[:n-1]
+			}
+		}
+	}
+	return out
+}
+
+func (w *columnWalker) fail(why string) {
+	if w.incomplete == "" {
+		w.incomplete = why
+	}
+}
+
+// end returns the index of the ")" closing the "(" at i, or hi when it is
+// not closed before hi.
+func (w *columnWalker) end(i, hi int) int {
+	return min(w.close[i], hi)
+}
+
+func (w *columnWalker) isPunct(i int, p string) bool {
+	return i >= 0 && i < len(w.toks) && w.toks[i].Kind == Punct && w.toks[i].Text == p
+}
+
+func (w *columnWalker) isWord(i int, s string) bool {
+	return i >= 0 && i < len(w.toks) && w.toks[i].isWord(s)
+}
+
+// startsQuery reports whether the tokens from lo open a query: a subquery
+// rather than an expression group.
+func (w *columnWalker) startsQuery(lo, hi int) bool {
+	if lo >= hi {
+		return false
+	}
+	t := w.toks[lo]
+	if t.Kind == Punct && t.Text == "(" {
+		return w.startsQuery(lo+1, w.end(lo, hi))
+	}
+	return t.isWord("select") || t.isWord("with") || t.isWord("values")
+}
+
+// statement walks one statement, [lo, hi).
+func (w *columnWalker) statement(lo, hi int) {
+	t := w.toks[lo]
+	switch {
+	case t.isWord("explain") || t.isWord("describe") || t.isWord("desc"):
+		// The option list sits between the wrapper and what it wraps.
+		for k := lo + 1; k < hi; k++ {
+			if w.isPunct(k, "(") {
+				k = w.end(k, hi)
+				continue
+			}
+			if w.toks[k].Kind == Word && (queryHead[w.toks[k].Text] || w.toks[k].Text == "merge") {
+				w.statement(k, hi)
+				return
+			}
+		}
+	case t.isWord("merge"):
+		// Each branch of a MERGE sees both the target and the source under
+		// its own clause, and no clause keyword says which a column is.
+		w.fail("merge is not modelled by the column scan")
+	case t.isWord("copy"):
+		w.copy(lo, hi)
+	case t.Kind == Word && queryHead[t.Text], t.Kind == Punct && t.Text == "(":
+		w.block(lo, hi, nil, false, false)
+	default:
+		// DDL names no columns of its own, but may carry a query:
+		// CREATE TABLE x AS SELECT, CREATE VIEW v AS SELECT, DECLARE c
+		// CURSOR FOR SELECT. Whatever it writes, result records as every
+		// column.
+		for k := lo + 1; k+1 < hi; k++ {
+			if w.isPunct(k, "(") {
+				k = w.end(k, hi)
+				continue
+			}
+			if (w.isWord(k, "as") || w.isWord(k, "for")) && w.startsQuery(k+1, hi) {
+				w.block(k+1, hi, nil, false, false)
+				return
+			}
+			if w.isWord(k, "select") {
+				// MySQL's CREATE TABLE t2 SELECT ... needs no AS.
+				w.block(k, hi, nil, false, false)
+				return
+			}
+		}
+	}
+}
+
+// copy walks COPY, whose column list precedes the direction deciding whether
+// the columns are read or written.
+func (w *columnWalker) copy(lo, hi int) {
+	i := lo + 1
+	if w.isPunct(i, "(") {
+		w.block(i+1, w.end(i, hi), nil, false, false)
+		return
+	}
+	if i >= hi || !w.toks[i].isName() {
+		return
+	}
+	parts, j, _ := w.chain(i, hi)
+	it := &colItem{name: strings.Join(parts, "."), base: true, target: true}
+	s := w.newScope(nil, false)
+	s.items = append(s.items, it)
+
+	use := Written
+	if w.a.copyDirection(j) == Read {
+		use = Projected
+	}
+	if w.isPunct(j+1, "(") {
+		w.columnList(s, j+2, w.end(j+1, hi), use, true)
+		return
+	}
+	s.refs = append(s.refs, colRef{name: AllColumns, use: use, item: it})
+}
+
+func (w *columnWalker) newScope(parent *colScope, correlated bool) *colScope {
+	s := &colScope{parent: parent, correlated: correlated}
+	w.scopes = append(w.scopes, s)
+	return s
+}
+
+// block walks a query block, [lo, hi), with its WITH list.
+func (w *columnWalker) block(lo, hi int, parent *colScope, correlated, exists bool) {
+	i := lo
+	if w.isWord(i, "with") {
+		i = w.with(i+1, hi, parent)
+	}
+	s := w.newScope(parent, correlated)
+	st := &colSpec{scope: s, home: s, verb: Unknown, exists: exists}
+	w.run(st, i, hi, false)
+	w.finish(st)
+}
+
+// with walks a WITH list from i, and returns the index of the statement it
+// prefixes. A CTE's name is already bound by the analysis; what matters here
+// is that its body is a block of its own.
+func (w *columnWalker) with(i, hi int, parent *colScope) int {
+	if w.isWord(i, "recursive") {
+		i++
+	}
+	for i < hi && w.toks[i].isName() {
+		i++
+		if w.isPunct(i, "(") {
+			// The CTE's column list renames the body's columns.
+			i = w.end(i, hi) + 1
+		}
+		if !w.isWord(i, "as") {
+			return i
+		}
+		i++
+		for w.isWord(i, "not") || w.isWord(i, "materialized") {
+			i++
+		}
+		if !w.isPunct(i, "(") {
+			return i
+		}
+		end := w.end(i, hi)
+		w.block(i+1, end, parent, false, false)
+		i = end + 1
+		if !w.isPunct(i, ",") {
+			return i
+		}
+		i++
+	}
+	return i
+}
+
+// finish records the whole-row writes a block's verb implies.
+func (w *columnWalker) finish(st *colSpec) {
+	if st.verb != Delete && (st.verb != Insert || st.insertCols) {
+		return
+	}
+	for _, it := range st.home.items {
+		if it.target {
+			st.home.refs = append(st.home.refs, colRef{name: AllColumns, use: Written, item: it})
+		}
+	}
+}
+
+// run walks [lo, hi) under st. expr is true inside an expression group,
+// where clause keywords are operators or function syntax rather than
+// clauses: the FROM of `extract(year FROM ts)` opens no FROM list.
+func (w *columnWalker) run(st *colSpec, lo, hi int, expr bool) {
+	for i := lo; i < hi; i++ {
+		t := w.toks[i]
+		switch t.Kind {
+		case Punct:
+			i = w.punct(st, i, hi, expr)
+		case Word:
+			if notAColumn[t.Text] {
+				i = w.keyword(st, i, hi, expr)
+				continue
+			}
+			i = w.name(st, i, hi, expr)
+		case Quoted:
+			i = w.name(st, i, hi, expr)
+		default:
+			st.operand, st.lhs = true, false
+		}
+	}
+}
+
+func (w *columnWalker) punct(st *colSpec, i, hi int, expr bool) int {
+	switch w.toks[i].Text {
+	case "(":
+		end := w.end(i, hi)
+		w.group(st, i, end, expr)
+		st.operand, st.lhs = true, false
+		return end
+	case ",":
+		st.operand = false
+		if expr {
+			break
+		}
+		switch st.state {
+		case clItems:
+			st.expectItem, st.expectAlias = true, false
+		case clAssign:
+			st.lhs = true
+		}
+	case "*":
+		// After an operand it multiplies. Inside a function's arguments
+		// it is count(*).
+		if !st.operand && !expr && st.state == clProject && !st.exists {
+			st.scope.refs = append(st.scope.refs, colRef{name: AllColumns, use: Projected})
+		}
+		st.operand, st.lhs = false, false
+	default:
+		st.operand, st.lhs = false, false
+	}
+	return i
+}
+
+// group walks the parenthesis at open, closed at end.
+func (w *columnWalker) group(st *colSpec, open, end int, expr bool) {
+	lo := open + 1
+	fn := st.funcNext
+	st.funcNext = false
+
+	if w.startsQuery(lo, end) {
+		parent, correlated := st.scope, true
+		switch {
+		case !expr && st.state == clItems && st.verb == Insert && st.targets:
+			// `INSERT INTO t (SELECT ...)` cannot see t.
+			parent, correlated = st.home.parent, false
+		case !expr && st.state == clItems && st.expectItem:
+			// A derived table sees the block around it only under
+			// LATERAL.
+			correlated = w.isWord(open-1, "lateral")
+			st.scope.items = append(st.scope.items, &colItem{})
+			st.expectItem, st.expectAlias = false, true
+		}
+		w.block(lo, end, parent, correlated, w.isWord(open-1, "exists"))
+		return
+	}
+
+	switch {
+	case expr || fn:
+		w.run(st, lo, end, true)
+	case st.state == clItems && st.verb == Insert && st.targets:
+		w.columnList(st.scope, lo, end, Written, true)
+		st.insertCols = true
+	case st.state == clItems && st.expectItem:
+		// A parenthesised join: `FROM (a JOIN b ON ...) j`.
+		w.run(st, lo, end, false)
+		st.scope.items = append(st.scope.items, &colItem{})
+		st.state, st.expectItem, st.expectAlias = clItems, false, true
+	case st.state == clItems:
+		// A column alias list, `FROM t AS x(a, b)`, or a table hint,
+		// `WITH (NOLOCK)`, `USE INDEX (i)`. Neither names a column a
+		// relation holds.
+		st.expectAlias = false
+	case st.state == clAssign && st.lhs:
+		// `SET (a, b) = (...)`.
+		w.columnList(st.scope, lo, end, Written, true)
+	case st.state == clNone:
+		// LIMIT (...), a locking clause's list, TOP (n).
+	default:
+		w.run(st, lo, end, true)
+	}
+}
+
+// columnList records the names in a parenthesised column list, [lo, end).
+func (w *columnWalker) columnList(s *colScope, lo, end int, use ColumnUse, target bool) {
+	for i := lo; i < end; i++ {
+		if w.isPunct(i, "(") {
+			i = w.end(i, end)
+			continue
+		}
+		if !w.toks[i].isName() || (w.toks[i].Kind == Word && notAColumn[w.toks[i].Text]) {
+			continue
+		}
+		parts, j, _ := w.chain(i, end)
+		s.refs = append(s.refs, colRef{
+			qual:   strings.Join(parts[:len(parts)-1], "."),
+			name:   parts[len(parts)-1],
+			use:    use,
+			target: target,
+		})
+		i = j
+	}
+}
+
+// chain reads a dotted name from i: `a`, `s.t.c`, `t.*`. It returns the
+// parts, the index of the last token consumed, and whether it ended in `*`.
+func (w *columnWalker) chain(i, hi int) ([]string, int, bool) {
+	parts := []string{w.toks[i].Text}
+	j := i
+	for j+2 < hi && w.isPunct(j+1, ".") {
+		n := w.toks[j+2]
+		if n.Kind == Punct && n.Text == "*" {
+			return parts, j + 2, true
+		}
+		if !n.isName() {
+			break
+		}
+		parts = append(parts, n.Text)
+		j += 2
+	}
+	return parts, j, false
+}
+
+// keyword handles a word in notAColumn at i and returns the index it
+// consumed through.
+func (w *columnWalker) keyword(st *colSpec, i, hi int, expr bool) int {
+	word := w.toks[i].Text
+	st.operand, st.lhs = operandWord[word], false
+	if expr {
+		return i
+	}
+
+	switch word {
+	case "select":
+		if st.verb == Insert && !st.sub {
+			// INSERT ... SELECT: the SELECT reads in a block that
+			// cannot see the target.
+			st.sub = true
+			st.scope = w.newScope(st.home.parent, false)
+		}
+		if st.verb == Unknown {
+			st.verb = Select
+		}
+		st.state = clProject
+	case "values":
+		if st.state == clAssign {
+			// MySQL's VALUES(col) in ON DUPLICATE KEY UPDATE.
+			break
+		}
+		if st.verb == Unknown {
+			st.verb = Select
+		}
+		st.state = clValues
+	case "table":
+		if st.verb == Unknown {
+			// TABLE t is SELECT * FROM t.
+			st.verb = Select
+			st.state, st.expectItem, st.expectAlias = clItems, true, false
+			st.scope.refs = append(st.scope.refs, colRef{name: AllColumns, use: Projected})
+		}
+	case "insert", "replace", "update", "delete":
+		if st.verb != Unknown {
+			// FOR UPDATE, DO UPDATE, ON DELETE, replace(...).
+			break
+		}
+		switch word {
+		case "update":
+			st.verb = Update
+		case "delete":
+			st.verb = Delete
+		default:
+			st.verb = Insert
+		}
+		st.state, st.targets, st.expectItem, st.expectAlias = clItems, true, true, false
+	case "into":
+		if st.verb == Insert && !st.sub {
+			st.state, st.targets, st.expectItem, st.expectAlias = clItems, true, true, false
+			break
+		}
+		// SELECT ... INTO names a destination. Whatever it writes,
+		// result records as every column.
+		st.state = clNone
+	case "from":
+		if w.isWord(i-1, "distinct") {
+			// IS DISTINCT FROM.
+			break
+		}
+		st.targets = false
+		if st.verb == Delete && !st.deleteFrom {
+			st.deleteFrom = true
+			w.deleteTargets(st)
+		}
+		st.state, st.expectItem, st.expectAlias = clItems, true, false
+	case "join", "apply", "straight_join":
+		// st.targets is left alone: MySQL's `UPDATE a JOIN b ON ... SET`
+		// may write either.
+		st.state, st.expectItem, st.expectAlias = clItems, true, false
+	case "on":
+		switch {
+		case w.isWord(i-1, "distinct") && w.isPunct(i+1, "("):
+			// DISTINCT ON (...) is part of the select list, and the
+			// group ends no operand: the next word is a column.
+			end := w.end(i+1, hi)
+			w.run(st, i+2, end, true)
+			st.operand = false
+			return end
+		case w.isWord(i+1, "conflict"):
+			st.restoreHome()
+			st.state = clConflict
+			return i + 1
+		case w.isWord(i+1, "duplicate"):
+			// ON DUPLICATE KEY UPDATE assigns the INSERT's target.
+			st.restoreHome()
+			st.state, st.lhs = clAssign, true
+			j := i + 1
+			for j+1 < hi && (w.isWord(j+1, "key") || w.isWord(j+1, "update")) {
+				j++
+			}
+			return j
+		default:
+			st.state = clFilter
+		}
+	case "using":
+		if w.isPunct(i+1, "(") {
+			// JOIN ... USING (id) compares id on both sides.
+			st.state = clFilter
+			break
+		}
+		// DELETE FROM a USING b.
+		st.state, st.targets, st.expectItem, st.expectAlias = clItems, false, true, false
+	case "where", "having", "group", "order", "window":
+		st.state, st.ordering = clFilter, word == "order"
+	case "do":
+		// ON CONFLICT ... DO NOTHING, or DO UPDATE SET.
+		st.state = clNone
+	case "set":
+		if st.verb != Update && st.verb != Insert {
+			break
+		}
+		if st.verb == Insert && st.state == clItems {
+			// MySQL's INSERT t SET a = 1 names its columns this way.
+			st.insertCols = true
+		}
+		st.restoreHome()
+		st.state, st.lhs, st.targets = clAssign, true, false
+	case "returning", "output":
+		st.restoreHome()
+		st.state = clProject
+	case "top":
+		// T-SQL's TOP n and TOP (n) bound the rows; they name nothing.
+		if i+1 < hi && w.toks[i+1].Kind == Number {
+			return i + 1
+		}
+		if w.isPunct(i+1, "(") {
+			return w.end(i+1, hi)
+		}
+	case "limit", "offset", "fetch", "for", "option":
+		st.state = clNone
+	case "union", "intersect", "except", "minus":
+		st.scope = w.newScope(st.scope.parent, st.scope.correlated)
+		st.state, st.ordering = clNone, false
+	case "with":
+		switch {
+		case st.state == clItems && w.isPunct(i+1, "("):
+			// A T-SQL table hint: FROM t WITH (NOLOCK).
+			return w.end(i+1, hi)
+		case w.isWord(i+1, "recursive") || w.isWord(i+2, "as") || w.isPunct(i+2, "("):
+			// PostgreSQL's `INSERT INTO t WITH x AS (...) SELECT`.
+			w.fail("a WITH list inside a statement is not modelled by the column scan")
+		}
+	case "merge":
+		w.fail("merge is not modelled by the column scan")
+	}
+	return i
+}
+
+// deleteTargets settles a DELETE's targets at its FROM.
+//
+// `DELETE FROM t` has named none yet, and the list after FROM is its targets.
+// MySQL's `DELETE a, b FROM a JOIN b` named them first, by a relation name or
+// an alias the FROM list declares, so each becomes a whole-row write resolved
+// against that list.
+func (w *columnWalker) deleteTargets(st *colSpec) {
+	s := st.scope
+	if len(s.items) == 0 {
+		st.targets = true
+		return
+	}
+	for _, it := range s.items {
+		s.refs = append(s.refs, colRef{qual: it.name, name: AllColumns, use: Written})
+	}
+	s.items = nil
+}
+
+// name handles an identifier at i, bare or quoted, and returns the index it
+// consumed through.
+func (w *columnWalker) name(st *colSpec, i, hi int, expr bool) int {
+	t := w.toks[i]
+	parts, j, star := w.chain(i, hi)
+	next := j + 1
+
+	// A cast's type, a bind variable, a field of a parenthesised value, an
+	// alias, a collation.
+	alias := w.isWord(i-1, "as") && (expr || st.state != clItems)
+	if alias || w.isPunct(i-1, ":") || w.isPunct(i-1, "@") || w.isPunct(i-1, ".") || w.isWord(i-1, "collate") {
+		if alias && !expr && st.state == clProject {
+			st.scope.output(t.Text)
+		}
+		st.operand, st.lhs = true, false
+		return j
+	}
+	if t.Kind == Word && typedLiteral[t.Text] && next < hi &&
+		(w.toks[next].Kind == Literal || w.isWord(next, "zone")) {
+		return j
+	}
+	if w.isWord(i-1, "time") && t.isWord("zone") || w.isWord(next, "by") {
+		// AT TIME ZONE, WITH TIME ZONE; PARTITION BY and the like.
+		return j
+	}
+	isCall := w.isPunct(next, "(")
+
+	if !expr && st.state == clItems {
+		switch {
+		case t.Kind == Word && (relSkip[t.Text] || notARelation[t.Text]):
+		case st.expectItem:
+			name := strings.Join(parts, ".")
+			fn := isCall && !(st.verb == Insert && st.targets)
+			it := &colItem{name: name, base: !fn && !w.a.cteNames[name], target: st.targets}
+			if fn {
+				// A set-returning function. Its arguments may read
+				// columns; its result is nobody's relation.
+				it.name, it.base = "", false
+				st.funcNext = true
+			}
+			st.scope.items = append(st.scope.items, it)
+			st.expectItem, st.expectAlias = false, true
+		case st.expectAlias && len(parts) == 1:
+			st.scope.items[len(st.scope.items)-1].alias = t.Text
+			st.expectAlias = false
+		}
+		return j
+	}
+
+	if isCall {
+		st.funcNext = true
+		return j
+	}
+	if t.Kind == Word && (t.Text == "year" || t.Text == "month" || t.Text == "day" ||
+		t.Text == "hour" || t.Text == "minute" || t.Text == "second") && w.isWord(next, "from") {
+		// extract(year FROM ts).
+		return j
+	}
+	if !expr && st.state == clProject && st.operand {
+		// `SELECT a b`: b is a's alias.
+		st.scope.output(t.Text)
+		return j
+	}
+	if st.ordering && len(parts) == 1 && st.scope.outputs[t.Text] {
+		return j
+	}
+
+	use, target := st.state.use(), false
+	if !expr && st.state == clAssign && st.lhs {
+		use, target = Written, true
+	}
+	st.operand, st.lhs = true, false
+	if use == 0 {
+		return j
+	}
+	ref := colRef{qual: strings.Join(parts[:len(parts)-1], "."), name: parts[len(parts)-1], use: use, target: target}
+	if star {
+		ref.qual, ref.name = strings.Join(parts, "."), AllColumns
+	}
+	st.scope.refs = append(st.scope.refs, ref)
+	return j
+}
+
+// resolve attributes one reference.
+func (w *columnWalker) resolve(s *colScope, r colRef) {
+	switch {
+	case r.item != nil:
+		w.attribute(r.item, r)
+	case r.qual != "":
+		w.qualified(s, r)
+	default:
+		w.bare(s, r)
+	}
+}
+
+// bare attributes an unqualified name to every relation it could belong to:
+// every base item of its block, and of each enclosing block a correlated
+// subquery can see.
+func (w *columnWalker) bare(s *colScope, r colRef) {
+	for ; s != nil; s = s.parent {
+		for _, it := range s.items {
+			if !r.target || it.target {
+				w.attribute(it, r)
+			}
+		}
+		// `SELECT *` is every column of its own block's relations.
+		if r.target || r.name == AllColumns || !s.correlated {
+			return
+		}
+	}
+}
+
+// qualified attributes a qualified name to the items its qualifier names in
+// the innermost block declaring one.
+func (w *columnWalker) qualified(s *colScope, r colRef) {
+	for sc := s; sc != nil; sc = sc.parent {
+		found := false
+		for _, it := range sc.items {
+			if it.names(r.qual) {
+				found = true
+				w.attribute(it, r)
+			}
+		}
+		if found {
+			return
+		}
+	}
+	if pseudoRow[r.qual] {
+		for sc := s; sc != nil; sc = sc.parent {
+			found := false
+			for _, it := range sc.items {
+				if it.target {
+					found = true
+					w.attribute(it, r)
+				}
+			}
+			if found {
+				return
+			}
+		}
+	}
+	w.fail(fmt.Sprintf("column qualifier %q names no relation in scope", r.qual))
+}
+
+func (w *columnWalker) attribute(it *colItem, r colRef) {
+	if !it.base || r.use == 0 {
+		return
+	}
+	cols := w.out[it.name]
+	for k := range cols {
+		if cols[k].Name == r.name {
+			cols[k].Use |= r.use
+			return
+		}
+	}
+	w.out[it.name] = append(cols, Column{Name: r.name, Use: r.use})
+}
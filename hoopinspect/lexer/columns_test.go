@@ -0,0 +1,122 @@
+package lexer_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoopinspect/lexer"
+)
+
+// columns renders an analysis's columns as "relation.column:uses", uses
+// spelled p, f and w, sorted so a case reads as a set.
+func columns(a lexer.Analysis) []string {
+	var out []string
+	for rel, cols := range a.Columns {
+		for _, c := range cols {
+			uses := ""
+			for _, u := range []struct {
+				bit  lexer.ColumnUse
+				code string
+			}{{lexer.Projected, "p"}, {lexer.Filtered, "f"}, {lexer.Written, "w"}} {
+				if c.Use&u.bit != 0 {
+					uses += u.code
+				}
+			}
+			out = append(out, rel+"."+c.Name+":"+uses)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestColumnsPerRelation(t *testing.T) {
+	for _, tc := range []struct {
+		d    lexer.Dialect
+		sql  string
+		want string
+	}{
+		{lexer.Postgres, "SELECT name FROM customers WHERE ssn = $1",
+			"customers.name:p customers.ssn:f"},
+		// Aliases resolve to the relation, and a bare name in a join goes
+		// to every relation that could hold it: over-attributing denies a
+		// statement, under-attributing leaks a column.
+		{lexer.Postgres, "SELECT c.email, total FROM customers c JOIN orders o ON o.customer_id = c.id",
+			"customers.email:p customers.id:f customers.total:p orders.customer_id:f orders.total:p"},
+		{lexer.Postgres, "SELECT * FROM customers WHERE id = 1",
+			"customers.*:p customers.id:f"},
+		{lexer.Postgres, "SELECT c.* FROM customers c JOIN orders o ON true",
+			"customers.*:p"},
+		// A select-list alias is not a column of anything.
+		{lexer.Postgres, "SELECT ssn AS x FROM customers ORDER BY x, id",
+			"customers.id:f customers.ssn:p"},
+		{lexer.Postgres, "SELECT count(*) FROM customers GROUP BY region",
+			"customers.region:f"},
+		{lexer.Postgres, "UPDATE customers SET tier = plan WHERE id = 7 RETURNING ssn",
+			"customers.id:f customers.plan:p customers.ssn:p customers.tier:w"},
+		{lexer.Postgres, "INSERT INTO archive (id, ssn) SELECT id, ssn FROM customers",
+			"archive.id:w archive.ssn:w customers.id:p customers.ssn:p"},
+		// With no column list an INSERT writes every column, and a DELETE
+		// always does.
+		{lexer.Postgres, "INSERT INTO archive VALUES (1, 'x')", "archive.*:w"},
+		{lexer.Postgres, "DELETE FROM customers WHERE id = 7",
+			"customers.*:w customers.id:f"},
+		{lexer.Postgres, "INSERT INTO t (a) VALUES (1) ON CONFLICT (a) DO UPDATE SET b = excluded.b",
+			"t.a:fw t.b:pw"},
+		// A correlated subquery's bare names may belong to the outer
+		// relation; a derived table's and a CTE's do not.
+		{lexer.Postgres, "SELECT id FROM customers c WHERE EXISTS (SELECT 1 FROM orders o WHERE o.cid = c.id AND ssn IS NULL)",
+			"customers.id:pf customers.ssn:f orders.cid:f orders.ssn:f"},
+		{lexer.Postgres, "WITH x AS (SELECT ssn FROM customers) SELECT ssn FROM x",
+			"customers.ssn:p"},
+		{lexer.Postgres, "SELECT d.n FROM (SELECT ssn AS n FROM customers) d",
+			"customers.ssn:p"},
+		// A filter is a read even with nothing projected.
+		{lexer.Postgres, "SELECT 1 FROM customers WHERE ssn LIKE '123%'",
+			"customers.ssn:f"},
+		{lexer.Postgres, "COPY customers (id, ssn) TO STDOUT",
+			"customers.id:p customers.ssn:p"},
+		{lexer.Postgres, "EXPLAIN DELETE FROM customers WHERE id = 1",
+			"customers.id:f"},
+		{lexer.Postgres, "DELETE FROM customers WHERE id IN (SELECT cid FROM orders WHERE total = 0)",
+			"customers.*:w customers.cid:p customers.id:f customers.total:f orders.cid:p orders.total:f"},
+		{lexer.MySQL, "INSERT INTO t (a, b) VALUES (1, 2) ON DUPLICATE KEY UPDATE b = VALUES(b)",
+			"t.a:w t.b:pw"},
+		{lexer.MSSQL, "SELECT TOP 5 c.ssn FROM customers c WITH (NOLOCK) ORDER BY c.id",
+			"customers.id:f customers.ssn:p"},
+		{lexer.MSSQL, "DELETE FROM customers OUTPUT deleted.ssn WHERE id = 1",
+			"customers.*:w customers.id:f customers.ssn:p"},
+	} {
+		a := lexer.Analyze(tc.sql, tc.d)
+		if !a.ColumnsComplete {
+			t.Errorf("%s: ColumnsComplete = false (%s)", tc.sql, a.ColumnsReason)
+		}
+		if got := strings.Join(columns(a), " "); got != tc.want {
+			t.Errorf("%s:\n got %s\nwant %s", tc.sql, got, tc.want)
+		}
+	}
+}
+
+// A column the scan cannot attribute is a column it cannot rule out, and
+// the analysis says so instead of dropping it.
+func TestColumnsIncomplete(t *testing.T) {
+	for _, tc := range []struct {
+		sql, reason string
+	}{
+		{"SELECT x.ssn FROM customers c", `column qualifier "x"`},
+		{"MERGE INTO t USING s ON t.id = s.id WHEN MATCHED THEN UPDATE SET a = s.a", "merge"},
+		{"DO $$ BEGIN PERFORM 1; END $$", ""},
+	} {
+		a := lexer.Analyze(tc.sql, lexer.Postgres)
+		if a.ColumnsComplete || !strings.Contains(a.ColumnsReason, tc.reason) || a.ColumnsReason == "" {
+			t.Errorf("%s: ColumnsComplete = %v, reason %q, want incomplete naming %q",
+				tc.sql, a.ColumnsComplete, a.ColumnsReason, tc.reason)
+		}
+	}
+	// A star is incomplete about the columns, not about the statement: the
+	// relation carries it, and a rule not touching that relation is
+	// unaffected.
+	if a := lexer.Analyze("SELECT * FROM customers", lexer.Postgres); !a.ColumnsComplete {
+		t.Errorf("SELECT *: ColumnsComplete = false (%s)", a.ColumnsReason)
+	}
+}
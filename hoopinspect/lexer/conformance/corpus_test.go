@@ -20,7 +20,7 @@ import (
 //     the whole statement and it does nothing", which is a lie the caller
 //     cannot detect. Not understanding is fine; it spells Complete=false.
 
-var dialects = []lexer.Dialect{lexer.Postgres, lexer.MSSQL}
+var dialects = []lexer.Dialect{lexer.Postgres, lexer.MSSQL, lexer.MySQL, lexer.Oracle}
 
 // hostile is input designed to walk the scanner off the end of something.
 // Every entry is a construct that terminated a previous implementation early
@@ -80,6 +80,28 @@ var hostile = []string{
 	"UPDATE\x93",
 	"DELETE\xc2\xa0FROM t",
 	"SELECT\xff",
+
+	// MySQL and Oracle. Each opens a region the other dialects do not have.
+	"`unterminated",
+	"SELECT `a``",
+	"#",
+	"# comment with no newline",
+	"SELECT 1--1",
+	"/*!",
+	"/*!50001 DROP TABLE t",
+	"/*M!100100",
+	"SELECT 'a\\",
+	"SET sql_mode = ",
+	"q'",
+	"q'[unterminated",
+	"nq'!x",
+	"q'\n",
+	"BEGIN",
+	"DECLARE",
+	"CREATE PROCEDURE p AS BEGIN",
+	"CREATE PROCEDURE p AS BEGIN NULL; END;\n/",
+	"/\n/\n/",
+	"SELECT * FROM v$session",
 }
 
 func TestAnalyzeNeverPanics(t *testing.T) {
@@ -98,7 +120,7 @@ func TestAnalyzeNeverPanics(t *testing.T) {
 			// input that caused it instead of the whole test.
 			t.Run(caseName(d.String()+" "+in), func(t *testing.T) {
 				got := lexer.Analyze(in, d)
-				if bad, why := violatesDMLInvariant(in, got); bad {
+				if bad, why := violatesDMLInvariant(in, d, got); bad {
 					t.Errorf("%s\n  input: %q", why, in)
 				}
 			})
@@ -117,7 +139,7 @@ func FuzzAnalyze(f *testing.F) {
 	f.Fuzz(func(t *testing.T, sql string) {
 		for _, d := range dialects {
 			got := lexer.Analyze(sql, d)
-			if bad, why := violatesDMLInvariant(sql, got); bad {
+			if bad, why := violatesDMLInvariant(sql, d, got); bad {
 				t.Errorf("%s (dialect %s)", why, d)
 			}
 		}
@@ -126,11 +148,11 @@ func FuzzAnalyze(f *testing.F) {
 
 // violatesDMLInvariant reports the "understood it completely, it does
 // nothing" contradiction described above.
-func violatesDMLInvariant(sql string, a lexer.Analysis) (bool, string) {
+func violatesDMLInvariant(sql string, d lexer.Dialect, a lexer.Analysis) (bool, string) {
 	if !a.Complete || len(a.Effects) > 0 {
 		return false, ""
 	}
-	switch firstWord(sql) {
+	switch firstWord(sql, d) {
 	case "select", "insert", "update", "delete", "merge":
 		return true, "Complete=true with no effects for a statement that starts with a DML verb"
 	}
@@ -141,18 +163,19 @@ func violatesDMLInvariant(sql string, a lexer.Analysis) (bool, string) {
 //
 // It ends the word on exactly the bytes a SQL lexer ends an identifier on:
 // ASCII whitespace and ASCII punctuation. Any byte >= 0x80 CONTINUES the
-// word, matching lexer.isWordByte.
+// word, matching lexer.isWordByte, and so do the punctuation bytes the
+// dialect allows inside a name: `$` for MySQL and Oracle, `#` for Oracle.
 //
 // That rule is what keeps the invariant honest rather than merely strict.
 // `UPDATE\x93` and `\u00a0SELECT 1` are single identifiers, so PostgreSQL
 // rejects both as syntax errors and neither is a DML statement. A friendlier
 // split would read "update" and "select" out of them and then blame the
 // scanner for not finding a statement that was never there.
-func firstWord(sql string) string {
+func firstWord(sql string, d lexer.Dialect) string {
 	trimmed := strings.TrimLeft(sql, " \t\r\n\f\v")
 	end := len(trimmed)
 	for i := range len(trimmed) {
-		if !isWordByte(trimmed[i]) {
+		if !isWordByte(trimmed[i], d) {
 			end = i
 			break
 		}
@@ -160,8 +183,10 @@ func firstWord(sql string) string {
 	return strings.ToLower(trimmed[:end])
 }
 
-func isWordByte(c byte) bool {
+func isWordByte(c byte, d lexer.Dialect) bool {
 	return c == '_' ||
+		(c == '$' && (d == lexer.MySQL || d == lexer.Oracle)) ||
+		(c == '#' && d == lexer.Oracle) ||
 		(c >= 'a' && c <= 'z') ||
 		(c >= 'A' && c <= 'Z') ||
 		(c >= '0' && c <= '9') ||
@@ -211,7 +236,7 @@ func TestPostgresRegressionCorpus(t *testing.T) {
 			} else {
 				reasons[got.Reason]++
 			}
-			if bad, why := violatesDMLInvariant(sql, got); bad {
+			if bad, why := violatesDMLInvariant(sql, lexer.Postgres, got); bad {
 				t.Errorf("%s\n  file:  %s\n  input: %q", why, path, sql)
 			}
 		}
@@ -0,0 +1,226 @@
+package conformance
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hoophq/hoopinspect/lexer"
+)
+
+// The other dialects have no oracle in this module. Nobody ships a pure-Go
+// grammar for T-SQL, MySQL or PL/SQL that could be trusted as a referee, and
+// vendoring a C one defeats the reason this module is cgo-free.
+//
+// What they do have is PostgreSQL, by translation. Each row below spells one
+// statement four ways. The PostgreSQL spelling is checked against the oracle
+// like every other fixture, and then each other spelling must produce the
+// same analysis as the PostgreSQL one: effects, relations and Complete. The
+// oracle vouches for one spelling, and the equivalence carries its verdict to
+// the rest.
+//
+// The rows are chosen where the dialects differ in how the statement is
+// SPELLED (quoting, comments, escapes, literal syntax), because that is where
+// a dialect's rules can cut a statement in a different place. Statements that
+// exist in only one dialect have no translation and are in dialectHazards.
+//
+// An empty spelling means the dialect has no faithful one. Oracle has no
+// DELETE ... USING, and MySQL's joined UPDATE writes every table before SET,
+// which the scanner reports as such; neither is the same statement as the
+// PostgreSQL row, so forcing a translation would be testing the fixture.
+
+type spelling struct {
+	group                          string
+	postgres, mssql, mysql, oracle string
+}
+
+func spellings() []spelling {
+	return []spelling{
+		{
+			group:    "quoting",
+			postgres: `DELETE FROM "Customers" WHERE "Id" = 1`,
+			mssql:    `DELETE FROM [Customers] WHERE [Id] = 1`,
+			mysql:    "DELETE FROM `Customers` WHERE `Id` = 1",
+			oracle:   `DELETE FROM "Customers" WHERE "Id" = 1`,
+		},
+		{
+			group:    "quoting",
+			postgres: `UPDATE "app"."Orders" SET n = 1 WHERE id IN (SELECT id FROM "app"."Stale")`,
+			mssql:    `UPDATE [app].[Orders] SET n = 1 WHERE id IN (SELECT id FROM [app].[Stale])`,
+			mysql:    "UPDATE `app`.`Orders` SET n = 1 WHERE id IN (SELECT id FROM `app`.`Stale`)",
+			oracle:   `UPDATE "app"."Orders" SET n = 1 WHERE id IN (SELECT id FROM "app"."Stale")`,
+		},
+		{
+			group:    "escapes",
+			postgres: `UPDATE audit SET n = E'O\'Brien; DELETE FROM customers' WHERE id = 1`,
+			mssql:    `UPDATE audit SET n = N'O''Brien; DELETE FROM customers' WHERE id = 1`,
+			mysql:    `UPDATE audit SET n = 'O\'Brien; DELETE FROM customers' WHERE id = 1`,
+			oracle:   `UPDATE audit SET n = q'[O'Brien; DELETE FROM customers]' WHERE id = 1`,
+		},
+		{
+			group:    "escapes",
+			postgres: `SELECT $$it's -- not a comment$$ FROM t`,
+			mssql:    `SELECT 'it''s -- not a comment' FROM t`,
+			mysql:    `SELECT "it's -- not a comment" FROM t`,
+			oracle:   `SELECT q'{it's -- not a comment}' FROM t`,
+		},
+		{
+			group:    "comments",
+			postgres: "INSERT INTO staging SELECT * FROM customers -- ; DELETE FROM customers",
+			mssql:    "INSERT INTO staging SELECT * FROM customers -- ; DELETE FROM customers",
+			mysql:    "INSERT INTO staging SELECT * FROM customers # ; DELETE FROM customers",
+			oracle:   "INSERT INTO staging SELECT * FROM customers -- ; DELETE FROM customers",
+		},
+		{
+			group:    "comments",
+			postgres: "SELECT 1 /* ; DROP TABLE customers */ FROM t",
+			mssql:    "SELECT 1 /* ; DROP TABLE customers */ FROM t",
+			mysql:    "SELECT 1 /*+ ; DROP TABLE customers */ FROM t",
+			oracle:   "SELECT 1 /*+ ; DROP TABLE customers */ FROM t",
+		},
+		{
+			group:    "dml-shapes",
+			postgres: `DELETE FROM a USING b WHERE a.id = b.id`,
+			mssql:    `DELETE a FROM a JOIN b ON a.id = b.id`,
+			mysql:    `DELETE a FROM a JOIN b ON a.id = b.id`,
+			oracle:   ``,
+		},
+		{
+			group:    "dml-shapes",
+			postgres: `UPDATE a SET x = b.x FROM b WHERE a.id = b.id`,
+			mssql:    `UPDATE a SET x = b.x FROM a JOIN b ON a.id = b.id`,
+			mysql:    ``,
+			oracle:   `UPDATE a SET x = (SELECT x FROM b WHERE b.id = a.id)`,
+		},
+		{
+			group:    "dml-shapes",
+			postgres: `SELECT * FROM orders ORDER BY id LIMIT 20 OFFSET 40`,
+			mssql:    `SELECT * FROM orders ORDER BY id OFFSET 40 ROWS FETCH NEXT 20 ROWS ONLY`,
+			mysql:    `SELECT * FROM orders ORDER BY id LIMIT 40, 20`,
+			oracle:   `SELECT * FROM orders ORDER BY id OFFSET 40 ROWS FETCH NEXT 20 ROWS ONLY`,
+		},
+		{
+			group:    "ddl",
+			postgres: `TRUNCATE TABLE sessions`,
+			mssql:    `TRUNCATE TABLE sessions`,
+			mysql:    `TRUNCATE TABLE sessions`,
+			oracle:   `TRUNCATE TABLE sessions`,
+		},
+		{
+			// Not a RENAME: PostgreSQL parses ALTER TABLE ... RENAME into a
+			// RenameStmt, which the oracle reads no write from.
+			group:    "ddl",
+			postgres: `ALTER TABLE orders ADD COLUMN note text`,
+			mssql:    `ALTER TABLE orders ADD note nvarchar(200)`,
+			mysql:    `ALTER TABLE orders ADD COLUMN note text`,
+			oracle:   `ALTER TABLE orders ADD note varchar2(200)`,
+		},
+	}
+}
+
+func TestDialectsAgreeWithPostgresSpelling(t *testing.T) {
+	for _, s := range spellings() {
+		t.Run(caseName(s.postgres), func(t *testing.T) {
+			want := lexer.Analyze(s.postgres, lexer.Postgres)
+			oracle, err := oracleWrites(s.postgres)
+			if err != nil {
+				t.Fatalf("oracle could not parse fixture: %v", err)
+			}
+			// The translation is only worth something if the PostgreSQL
+			// spelling is an exact match. A concession here would let every
+			// other spelling concede too and call it agreement.
+			if !want.Complete || !slices.Equal(scannerWrites(want), oracle) {
+				t.Fatalf("postgres spelling is not an exact match\n  scanner:  %v (complete=%v)\n  postgres: %v",
+					scannerWrites(want), want.Complete, oracle)
+			}
+
+			for d, sql := range map[lexer.Dialect]string{
+				lexer.MSSQL:  s.mssql,
+				lexer.MySQL:  s.mysql,
+				lexer.Oracle: s.oracle,
+			} {
+				if sql == "" {
+					continue
+				}
+				got := lexer.Analyze(sql, d)
+				if !slices.Equal(got.Effects, want.Effects) ||
+					!slices.Equal(got.Relations, want.Relations) ||
+					got.Complete != want.Complete {
+					t.Errorf("%s disagrees with its postgres spelling\n  sql:      %s\n  %-8s  %+v\n  postgres: %+v",
+						d, sql, d.String()+":", got, want)
+				}
+			}
+		})
+	}
+}
+
+// dialectHazards are statements that exist in one dialect only, each one a
+// construct where the dialect's rules decide what the statement is. The
+// expected write-set is written by hand, and the assertion is the oracle
+// test's: EITHER the scanner's write-set equals it, OR the scanner conceded.
+// A nil write-set with mustConcede is a statement no write-set describes,
+// and reporting one with confidence is the failure.
+type hazard struct {
+	dialect     lexer.Dialect
+	sql         string
+	writes      []string
+	mustConcede bool
+}
+
+func dialectHazards() []hazard {
+	return []hazard{
+		// MySQL executes the body of /*! ... */, and mysqldump's output is
+		// full of them.
+		{lexer.MySQL, "/*!40101 SET NAMES utf8mb4 */; /*!50001 DROP TABLE IF EXISTS customers */", []string{"customers"}, false},
+		{lexer.MySQL, "SELECT 1 /*!, (SELECT 1 FROM dual) */; DELETE FROM customers", []string{"customers"}, false},
+		// -- is a comment only when whitespace follows.
+		{lexer.MySQL, "SELECT 1--1; DELETE FROM customers", []string{"customers"}, false},
+		{lexer.MySQL, "SELECT 1 -- ; DELETE FROM customers", nil, false},
+		// Backslash escapes are on by default, and "..." is a string.
+		{lexer.MySQL, `SELECT 'a\'; DELETE FROM customers; -- '`, nil, false},
+		{lexer.MySQL, `SELECT "a\"; DELETE FROM customers; -- "`, nil, false},
+		{lexer.MySQL, "DELETE t1, t2 FROM t1 JOIN t2 ON t1.id = t2.id", []string{"t1", "t2"}, false},
+		{lexer.MySQL, "UPDATE t1, t2 SET t1.n = t2.n WHERE t1.id = t2.id", []string{"t1", "t2"}, false},
+		{lexer.MySQL, "INSERT t VALUES (1)", []string{"t"}, false},
+		{lexer.MySQL, "REPLACE INTO t VALUES (1)", []string{"t"}, false},
+		{lexer.MySQL, "LOAD DATA INFILE '/tmp/x' REPLACE INTO TABLE customers", []string{"customers"}, false},
+		{lexer.MySQL, "SELECT * FROM customers INTO OUTFILE '/tmp/x'", nil, false},
+		{lexer.MySQL, "RENAME TABLE live TO old, staging TO live", []string{"live", "old", "staging"}, false},
+		{lexer.MySQL, "UPDATE a JOIN b ON a.id = b.id SET a.x = b.x", []string{"a", "b"}, false},
+		// A session that changes its own quoting rules leaves every later
+		// statement scanned under the wrong ones.
+		{lexer.MySQL, "SET sql_mode = 'ANSI_QUOTES'", nil, true},
+		{lexer.MySQL, "SET SESSION sql_mode = @old", nil, true},
+
+		// A q-quote needs no escape for the quote inside it.
+		{lexer.Oracle, `SELECT q'[it's]' FROM dual; DELETE FROM customers`, []string{"customers"}, false},
+		{lexer.Oracle, `SELECT q'!a'; DELETE FROM customers; '!' FROM dual`, nil, false},
+		{lexer.Oracle, `DELETE customers WHERE id = 1`, []string{"customers"}, false},
+		{lexer.Oracle, `INSERT ALL INTO a VALUES (1) INTO b VALUES (2) SELECT * FROM dual`, []string{"a", "b"}, false},
+		{lexer.Oracle, `SELECT sid FROM v$session WHERE username = 'APP'`, nil, false},
+		// An anonymous block is a program; a unit definition is one create.
+		{lexer.Oracle, `BEGIN DELETE FROM customers; END;`, nil, true},
+		{lexer.Oracle, `DECLARE n NUMBER; BEGIN n := 1; END;`, nil, true},
+		{lexer.Oracle, "CREATE OR REPLACE PROCEDURE purge AS\nBEGIN\n  DELETE FROM customers;\nEND;\n/", nil, false},
+		{lexer.Oracle, `UPDATE (SELECT * FROM customers WHERE id = 1) SET n = 2`, nil, true},
+	}
+}
+
+func TestDialectHazards(t *testing.T) {
+	for _, h := range dialectHazards() {
+		t.Run(caseName(h.dialect.String()+" "+h.sql), func(t *testing.T) {
+			got := lexer.Analyze(h.sql, h.dialect)
+			mine := scannerWrites(got)
+			switch {
+			case h.mustConcede && got.Complete:
+				t.Errorf("want Complete=false, got writes %v effects %v", mine, got.Effects)
+			case !got.Complete:
+				if !h.mustConcede {
+					t.Logf("conceded (%s)", got.Reason)
+				}
+			case !slices.Equal(mine, h.writes):
+				t.Errorf("scanner claims Complete=true and disagrees\n  sql:      %s\n  scanner:  %v\n  expected: %v",
+					h.sql, mine, h.writes)
+			}
+		})
+	}
+}
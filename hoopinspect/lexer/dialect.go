@@ -34,6 +34,7 @@
 //   - `EXECUTE p`: the statement was named elsewhere, possibly earlier.
 //
 // All three set Complete=false with a Reason, which is the only honest answer.
+// Oracle adds a fourth, the anonymous PL/SQL block, for the same reason as DO.
 package lexer
 
 // Dialect selects the lexical rules. It is not a grammar switch: the analysis
@@ -52,12 +53,22 @@ const (
 
 	// MSSQL covers Microsoft SQL Server and T-SQL.
 	MSSQL
+
+	// MySQL covers MySQL and MariaDB under the server's default sql_mode.
+	MySQL
+
+	// Oracle covers Oracle Database SQL and the PL/SQL units sent beside it.
+	Oracle
 )
 
 func (d Dialect) String() string {
 	switch d {
 	case MSSQL:
 		return "mssql"
+	case MySQL:
+		return "mysql"
+	case Oracle:
+		return "oracle"
 	}
 	return "postgres"
 }
@@ -83,7 +94,7 @@ type lexRules struct {
 	// backslash-quote swallows the semicolon and the DELETE disappears.
 	escapeString bool
 
-	// nationalString enables N'...'. T-SQL only.
+	// nationalString enables N'...'. T-SQL and Oracle.
 	nationalString bool
 
 	// unicodeIdent enables U&"..." and the UESCAPE clause. PostgreSQL only.
@@ -93,20 +104,121 @@ type lexRules struct {
 	// PostgreSQL '[' is an array subscript.
 	bracketIdent bool
 
-	// nestedBlockComment makes /* */ nest, which both engines do and the
-	// SQL standard does not. `/* a /* b */ DELETE FROM t */` is entirely a
-	// comment; a scanner that stops at the first close reports a delete.
+	// backtickIdent enables `name` with the `` escape. MySQL only.
+	//
+	// Every MySQL client and ORM quotes identifiers this way, so without it
+	// a delete from a backtick-quoted customers has no target, and a table
+	// rule on customers never fires on the commonest spelling of a delete.
+	backtickIdent bool
+
+	// doubleQuotedString makes "..." a string literal rather than an
+	// identifier, as MySQL does unless sql_mode carries ANSI_QUOTES.
+	//
+	// The difference is not cosmetic. A MySQL string honours backslash
+	// escapes and an identifier does not, so `SELECT "a\"; DELETE FROM t;
+	// -- "` is one select under the default and a delete under ANSI_QUOTES.
+	// The scanner follows the default; sqlModeQuoting covers a session that
+	// switches.
+	doubleQuotedString bool
+
+	// qQuote enables Oracle's alternative quoting, q'[...]' and nq'[...]',
+	// where the delimiter is any byte and brackets close with their
+	// partner. A quote inside needs no escape, which is the whole point of
+	// the syntax and the reason a scanner without it ends the literal early
+	// and reads the rest of the text as SQL.
+	qQuote bool
+
+	// hashComment makes # open a comment to end of line. MySQL only.
+	hashComment bool
+
+	// dashCommentNeedsSpace requires whitespace after -- before it opens a
+	// comment, as MySQL does.
+	//
+	// MySQL reads `SELECT 1--1` as one minus minus one. A scanner that
+	// takes every -- as a comment hides the rest of the line, so
+	// `SELECT 1--1; DELETE FROM customers` loses the delete the server runs.
+	dashCommentNeedsSpace bool
+
+	// executableComment scans the body of /*! ... */ (and MariaDB's
+	// /*M! ... */) as live SQL, which is what MySQL does with it.
+	//
+	// mysqldump wraps half its output in these, and to every other engine
+	// they are comments, so a scanner that skips them never sees
+	// `/*!50001 DROP TABLE customers */`. A version number after the bang
+	// limits the body to newer servers. The scanner cannot know the server
+	// version and reads every body as live, which can only report a
+	// statement the server then skips, never miss one it runs.
+	executableComment bool
+
+	// nestedBlockComment makes /* */ nest, which PostgreSQL and T-SQL do
+	// and the SQL standard does not. `/* a /* b */ DELETE FROM t */` is
+	// entirely a comment there; a scanner that stops at the first close
+	// reports a delete.
+	//
+	// MySQL and Oracle end a comment at the first close. Flat is also the
+	// safe reading of the two: whatever it exposes is scanned as live SQL
+	// and can only add to what the analysis reports.
 	nestedBlockComment bool
 
 	// backslashInPlainString treats \ as an escape inside '...'.
 	//
-	// FALSE for both supported dialects. PostgreSQL defaults
+	// FALSE for PostgreSQL, T-SQL and Oracle. PostgreSQL defaults
 	// standard_conforming_strings=on, where a backslash is an ordinary
-	// character, and T-SQL never had backslash escapes. When a server runs
-	// with the setting off, the literal scans short and the trailing quote
-	// is left unterminated, which surfaces as Complete=false rather than as
-	// a silent misread. That is the correct failure direction.
+	// character, and neither T-SQL nor Oracle ever had backslash escapes.
+	// When a PostgreSQL server runs with the setting off, the literal scans
+	// short and the trailing quote is left unterminated, which surfaces as
+	// Complete=false rather than as a silent misread. That is the correct
+	// failure direction.
+	//
+	// TRUE for MySQL, whose default is the opposite and whose drivers
+	// escape with backslashes: `'O\'Brien'` is one literal, and scanning it
+	// short would mark every such insert incomplete. The failure direction
+	// is the wrong one when NO_BACKSLASH_ESCAPES is on, so sqlModeQuoting
+	// concedes any statement that may turn it on.
 	backslashInPlainString bool
+
+	// sqlModeQuoting concedes a SET that may change sql_mode to one that
+	// alters quoting: ANSI_QUOTES, NO_BACKSLASH_ESCAPES, or a combination
+	// mode containing either. MySQL only.
+	//
+	// Both modes move where a literal ends, and a session can set them
+	// itself, so the statements that follow would be scanned under rules
+	// the server no longer applies. A value the scanner cannot read, such
+	// as `SET sql_mode = @saved`, concedes too.
+	sqlModeQuoting bool
+
+	// dollarInIdent and hashInIdent let $ and # continue a bare
+	// identifier. Oracle allows both after the first character, which is
+	// how every dynamic performance view is named: without them
+	// `SELECT * FROM v$session` reads a relation called v. MySQL allows $
+	// but reads # as a comment.
+	dollarInIdent bool
+	hashInIdent   bool
+
+	// plsql treats a PL/SQL unit as one statement whose body is data, and a
+	// line holding only / as a statement terminator. Oracle only.
+	//
+	// An anonymous block or a CREATE PROCEDURE body contains semicolons
+	// that do not end it, so splitting on them cuts the unit into fragments
+	// that each classify as something it is not. The body is data in the
+	// same sense as a dollar-quoted function body: defining a procedure
+	// performs one effect, a create. A block is executed, and BEGIN and
+	// DECLARE at statement head concede the way DO does.
+	//
+	// The unit ends at the / line SQL*Plus and every later Oracle tool use
+	// for the purpose, or at the end of the text.
+	plsql bool
+
+	// joinFiltersTarget counts a JOIN condition as limiting the rows an
+	// UPDATE or DELETE changes. MySQL and T-SQL only.
+	//
+	// Both let the target take part in the join: `UPDATE a JOIN b ON a.id =
+	// b.id SET ...` and T-SQL's `DELETE a FROM a JOIN b ON ...` change only
+	// the rows the ON clause pairs. PostgreSQL cannot name the target inside
+	// FROM's join tree, so an ON there relates two sources to each other
+	// and `UPDATE a SET x = 1 FROM b JOIN c ON b.id = c.id` still rewrites
+	// every row of a. Only its WHERE narrows the target.
+	joinFiltersTarget bool
 }
 
 func (d Dialect) rules() lexRules {
@@ -116,6 +228,28 @@ func (d Dialect) rules() lexRules {
 			nationalString:     true,
 			bracketIdent:       true,
 			nestedBlockComment: true,
+			joinFiltersTarget:  true,
+		}
+	case MySQL:
+		return lexRules{
+			nationalString:         true,
+			backtickIdent:          true,
+			doubleQuotedString:     true,
+			hashComment:            true,
+			dashCommentNeedsSpace:  true,
+			executableComment:      true,
+			backslashInPlainString: true,
+			sqlModeQuoting:         true,
+			dollarInIdent:          true,
+			joinFiltersTarget:      true,
+		}
+	case Oracle:
+		return lexRules{
+			nationalString: true,
+			qQuote:         true,
+			dollarInIdent:  true,
+			hashInIdent:    true,
+			plsql:          true,
 		}
 	default:
 		return lexRules{
@@ -1,5 +1,7 @@
 package lexer
 
+import "strings"
+
 // Verb is a normalized SQL operation.
 //
 // The vocabulary is this package's rather than the caller's so that lexer
@@ -83,12 +85,15 @@ var statementVerb = map[string]Verb{
 	"table":     Select, // TABLE t is shorthand for SELECT * FROM t
 	"values":    Select,
 	"insert":    Insert,
+	"replace":   Insert, // MySQL; deletes the conflicting row first, see head
+	"load":      Insert, // MySQL LOAD DATA ... INTO TABLE t
 	"update":    Update,
 	"delete":    Delete,
 	"merge":     Merge,
 	"create":    Create,
 	"drop":      Drop,
 	"alter":     Alter,
+	"rename":    Alter,
 	"truncate":  Truncate,
 	"grant":     Grant,
 	"revoke":    Revoke,
@@ -108,6 +113,8 @@ var statementVerb = map[string]Verb{
 	"abort":     Rollback,
 	"savepoint": Other,
 	"explain":   Explain,
+	"describe":  Explain, // MySQL synonym, ANALYZE included
+	"desc":      Explain,
 	"analyze":   Other,
 	"vacuum":    Other,
 	"comment":   Other,
@@ -141,6 +148,15 @@ var opaque = map[string]string{
 	"exec":    "stored procedure; body is in the catalog",
 }
 
+// plsqlBlock marks the heads that open an anonymous PL/SQL block under the
+// plsql rule. A block is Oracle's DO: a program executed once, whose static
+// SQL is only part of what it can do. Elsewhere BEGIN starts a transaction
+// and DECLARE a cursor, so the entries apply to Oracle alone.
+var plsqlBlock = map[string]string{
+	"begin":   "anonymous PL/SQL block; body is interpreted at runtime",
+	"declare": "anonymous PL/SQL block; body is interpreted at runtime",
+}
+
 // CREATE FUNCTION and friends are deliberately absent from opaque.
 //
 // Defining a function performs exactly one effect, a create, and the body is
@@ -164,6 +180,14 @@ var relIntro = map[string]bool{
 	"using":    true,
 	"copy":     true,
 	"on":       true,
+
+	// The DML verbs themselves, for the engines where the keyword after
+	// them is optional. Oracle writes `DELETE customers`, MySQL `INSERT
+	// customers VALUES (...)`, and MySQL's multi-table delete names its
+	// targets before FROM. Each applies only under its own verb.
+	"delete":  true,
+	"insert":  true,
+	"replace": true,
 }
 
 // ddlVerb reports whether a verb acts on a schema object rather than on rows.
@@ -184,12 +208,23 @@ func ddlVerb(v Verb) bool {
 //     t`) and a join predicate everywhere else. Treating it as an introducer
 //     unconditionally invents a relation out of `JOIN b ON a.id = b.id`.
 //   - FROM introduces a relation for DML and a ROLE for REVOKE.
+//
+// The DML verbs introduce only under themselves. UPDATE after FOR in a
+// locking clause, or after KEY in MySQL's ON DUPLICATE KEY UPDATE, is
+// followed by a column or nothing; DELETE after ON in a foreign key is
+// followed by a referential action.
 func introduces(intro string, verb Verb) bool {
 	switch intro {
 	case "on":
 		return ddlVerb(verb)
 	case "from":
 		return verb != Grant && verb != Revoke
+	case "update":
+		return verb == Update
+	case "delete":
+		return verb == Delete
+	case "insert", "replace":
+		return verb == Insert
 	}
 	return true
 }
@@ -201,11 +236,21 @@ func introduces(intro string, verb Verb) bool {
 // The entries earn their place from real misreads. `WHEN MATCHED THEN UPDATE
 // SET n = 1` has an UPDATE with no relation of its own, so SET was taken as
 // the target; `COPY t FROM STDIN` reported a write to stdin and lost t.
+//
+// FROM and WHEN stand after a DML verb that introduces: `DELETE FROM t` is
+// the ordinary form, and `THEN DELETE WHEN NOT MATCHED` a MERGE branch. The
+// DML verbs follow ON in a foreign key's `ON DELETE CASCADE`, which under DDL
+// is a relation position. ALL and FIRST open Oracle's multi-table insert, and
+// OUTFILE and DUMPFILE are where MySQL's SELECT ... INTO sends rows instead
+// of a table.
 var notARelation = map[string]bool{
 	"set": true, "values": true, "select": true, "where": true,
 	"do": true, "on": true, "returning": true, "default": true,
 	"null": true, "stdin": true, "stdout": true, "program": true,
 	"nothing": true, "conflict": true,
+	"from": true, "when": true, "all": true, "first": true,
+	"delete": true, "update": true, "insert": true,
+	"outfile": true, "dumpfile": true,
 }
 
 // relSkip are keywords that may sit between an introducer and the name.
@@ -232,6 +277,15 @@ var relSkip = map[string]bool{
 	"unlogged":     true,
 	"global":       true,
 	"local":        true,
+
+	// INTO follows the verb that now introduces on its own: INSERT INTO t.
+	// The rest are MySQL's modifiers: DELETE QUICK FROM, INSERT IGNORE INTO.
+	"into":          true,
+	"ignore":        true,
+	"quick":         true,
+	"low_priority":  true,
+	"high_priority": true,
+	"delayed":       true,
 }
 
 // headAfter are keywords after which a statement verb may begin, and after
@@ -252,6 +306,26 @@ var headAfter = map[string]bool{
 	"returning": false, // RETURNING is a clause, not a new statement
 }
 
+// predicateEnd are the clauses that may follow the WHERE of an UPDATE or
+// DELETE, or a join's ON, at its own nesting level. A word past one of them
+// is no longer part of the predicate, so `WHERE 1=1 RETURNING id` does not
+// count `id` as a filter.
+var predicateEnd = map[string]bool{
+	"returning": true, "output": true, "order": true, "limit": true,
+	"option": true, "group": true, "having": true, "window": true,
+	"set": true, "where": true, "join": true, "inner": true,
+	"cross": true, "natural": true, "straight_join": true,
+	"union": true, "intersect": true, "except": true,
+}
+
+// constantWord are the words a predicate can hold without naming anything a
+// row could differ on: `WHERE true`, `WHERE 1 = 1 AND NOT NULL IS NULL`.
+var constantWord = map[string]bool{
+	"true": true, "false": true, "null": true, "unknown": true,
+	"and": true, "or": true, "not": true, "is": true,
+	"between": true, "in": true, "like": true,
+}
+
 // wrapperModifier are the option keywords that may sit between EXPLAIN and
 // the statement it wraps, including inside its parenthesised option list.
 //
@@ -264,4 +338,45 @@ var wrapperModifier = map[string]bool{
 	"format": true, "generic_plan": true, "memory": true, "serialize": true,
 	"on": true, "off": true, "true": true, "false": true,
 	"text": true, "json": true, "yaml": true, "xml": true,
+	// MySQL's FORMAT=TREE and Oracle's EXPLAIN PLAN FOR.
+	"tree": true, "traditional": true, "plan": true, "for": true,
+}
+
+// createModifier are the words that may sit between CREATE and the kind of a
+// PL/SQL unit: CREATE OR REPLACE EDITIONABLE PROCEDURE.
+var createModifier = map[string]bool{
+	"or": true, "replace": true, "editionable": true, "noneditionable": true,
+}
+
+// plsqlUnit are the object kinds whose definition carries a PL/SQL body.
+var plsqlUnit = map[string]bool{
+	"procedure": true, "function": true, "package": true,
+	"trigger": true, "type": true,
+}
+
+// plsqlBodyStart are the keywords that end a unit's header. IS and AS open a
+// procedure, function, package or type body; a trigger's body is a block or
+// a CALL, and a compound trigger opens with COMPOUND.
+var plsqlBodyStart = map[string]bool{
+	"is": true, "as": true, "begin": true, "declare": true,
+	"compound": true, "call": true,
+}
+
+// quotingModes are the sql_mode names that move where a MySQL literal ends:
+// ANSI_QUOTES, NO_BACKSLASH_ESCAPES, and the combination modes that include
+// ANSI_QUOTES. "ansi" covers both ANSI and ANSI_QUOTES.
+var quotingModes = []string{
+	"ansi", "no_backslash_escapes",
+	"oracle", "postgresql", "mssql", "db2", "maxdb",
+}
+
+// namesQuotingMode reports whether a literal may set one of quotingModes.
+func namesQuotingMode(content string) bool {
+	lower := strings.ToLower(content)
+	for _, m := range quotingModes {
+		if strings.Contains(lower, m) {
+			return true
+		}
+	}
+	return false
 }
@@ -432,11 +432,12 @@ func TestVerbStringsAreStable(t *testing.T) {
 func TestAnalyzeDoesNotPanic(t *testing.T) {
 	for _, sql := range []string{
 		"", " ", ";", "(", ")", "'", `"`, "$", "$$", "--", "/*", "*/",
+		"`", "#", "/*!", "/*!50001", "q'", "q'[", "nq'", "/", "\n/\n", "begin", "declare",
 		"with", "with as", "with x as", "select from", "delete from",
 		strings.Repeat("(", 200), strings.Repeat(")", 200),
 		strings.Repeat("with x as (", 50),
 	} {
-		for _, d := range []lexer.Dialect{lexer.Postgres, lexer.MSSQL} {
+		for _, d := range []lexer.Dialect{lexer.Postgres, lexer.MSSQL, lexer.MySQL, lexer.Oracle} {
 			lexer.Analyze(sql, d)
 		}
 	}
@@ -560,3 +561,312 @@ func TestObjectCreatingForms(t *testing.T) {
 		}
 	}
 }
+
+// The same statement spelled for each engine must mean the same thing, or an
+// operation or table rule means something different per lane.
+func TestDialectsAgreeOnTheSameStatement(t *testing.T) {
+	for _, spellings := range []map[lexer.Dialect]string{
+		{
+			lexer.Postgres: `DELETE FROM "Customers" WHERE id IN (SELECT id FROM "Orders")`,
+			lexer.MSSQL:    `DELETE FROM [Customers] WHERE id IN (SELECT id FROM [Orders])`,
+			lexer.MySQL:    "DELETE FROM `Customers` WHERE id IN (SELECT id FROM `Orders`)",
+			lexer.Oracle:   `DELETE FROM "Customers" WHERE id IN (SELECT id FROM "Orders")`,
+		},
+		{
+			lexer.Postgres: `INSERT INTO staging SELECT * FROM customers -- copy`,
+			lexer.MSSQL:    `INSERT INTO staging SELECT * FROM customers -- copy`,
+			lexer.MySQL:    `INSERT INTO staging SELECT * FROM customers # copy`,
+			lexer.Oracle:   `INSERT INTO staging SELECT * FROM customers -- copy`,
+		},
+		{
+			lexer.Postgres: `SELECT E'it\'s' FROM t`,
+			lexer.MSSQL:    `SELECT N'it''s' FROM t`,
+			lexer.MySQL:    `SELECT 'it\'s' FROM t`,
+			lexer.Oracle:   `SELECT q'[it's]' FROM t`,
+		},
+	} {
+		want := lexer.Analyze(spellings[lexer.Postgres], lexer.Postgres)
+		for d, sql := range spellings {
+			got := lexer.Analyze(sql, d)
+			if !slices.Equal(got.Effects, want.Effects) || !slices.Equal(got.Relations, want.Relations) ||
+				got.Complete != want.Complete {
+				t.Errorf("%s: %+v, postgres says %+v: %s", d, got, want, sql)
+			}
+		}
+	}
+}
+
+// MySQL's comment syntax differs in both directions. # is a comment; -- is
+// one only when whitespace follows, so `1--1` is arithmetic and the statement
+// after it is live.
+func TestMySQLComments(t *testing.T) {
+	for _, tc := range []struct {
+		sql    string
+		writes bool
+	}{
+		{"SELECT 1 # ; DELETE FROM customers", false},
+		{"SELECT 1 -- ; DELETE FROM customers", false},
+		{"SELECT 1--1; DELETE FROM customers", true},
+		{"SELECT 1 /* ; DELETE FROM customers */", false},
+	} {
+		if got := lexer.Analyze(tc.sql, lexer.MySQL).Writes(); got != tc.writes {
+			t.Errorf("Writes() = %v, want %v: %s", got, tc.writes, tc.sql)
+		}
+	}
+}
+
+// MySQL executes the body of /*! ... */. mysqldump wraps half its output in
+// them, and reading them as comments hides a DROP.
+func TestMySQLExecutableCommentsAreLive(t *testing.T) {
+	for _, sql := range []string{
+		"/*!50001 DROP TABLE customers */",
+		"/*!DROP TABLE customers*/ SELECT 1",
+		"SELECT 1; /*M!100100 DROP TABLE customers */",
+	} {
+		a := lexer.Analyze(sql, lexer.MySQL)
+		if got := writes(a); !slices.Equal(got, []string{"customers"}) || !a.Complete {
+			t.Errorf("writes = %v (complete=%v), want [customers]: %s", got, a.Complete, sql)
+		}
+	}
+	// An optimizer hint is an ordinary comment.
+	if a := lexer.Analyze("SELECT /*+ DROP TABLE customers */ 1", lexer.MySQL); a.Writes() {
+		t.Errorf("a hint comment was read as SQL: %v", a.Effects)
+	}
+	if a := lexer.Analyze("SELECT 1 /*!50001 DROP TABLE t", lexer.MySQL); a.Complete {
+		t.Error("Complete = true on an unterminated executable comment")
+	}
+}
+
+// MySQL's strings honour backslashes and "..." is a string. Under Postgres
+// rules both statements below hide or invent a delete.
+func TestMySQLQuoting(t *testing.T) {
+	for _, sql := range []string{
+		`UPDATE audit SET note = 'O\'Brien; DELETE FROM customers'`,
+		`UPDATE audit SET note = "a\"; DELETE FROM customers"`,
+	} {
+		a := lexer.Analyze(sql, lexer.MySQL)
+		if slices.Contains(a.Effects, lexer.Delete) || !a.Complete {
+			t.Errorf("a literal was split: %+v: %s", a, sql)
+		}
+	}
+	a := lexer.Analyze("DELETE FROM `odd``name`", lexer.MySQL)
+	if got := writes(a); !slices.Equal(got, []string{"odd`name"}) {
+		t.Errorf("writes = %v, want [odd`name] (doubled-backtick escape)", got)
+	}
+}
+
+// A session can turn on ANSI_QUOTES or NO_BACKSLASH_ESCAPES itself, after
+// which the statements above scan differently. A SET that may do so is
+// conceded; an ordinary one is not.
+func TestMySQLQuotingModeChangesFailClosed(t *testing.T) {
+	for _, tc := range []struct {
+		sql      string
+		complete bool
+	}{
+		{`SET sql_mode = 'STRICT_TRANS_TABLES,NO_ENGINE_SUBSTITUTION'`, true},
+		{`SET SESSION sql_mode = DEFAULT`, true},
+		{`SET @saved = @@sql_mode`, true},
+		{`SET SESSION sql_mode = 'ANSI_QUOTES'`, false},
+		{`SET @@session.sql_mode = 'no_backslash_escapes'`, false},
+		{`SET NAMES utf8mb4, sql_mode = 'ANSI'`, false},
+		{`SET sql_mode = @saved`, false},
+		{`SET sql_mode = CONCAT(@@sql_mode, ',ANSI_QUOTES')`, false},
+	} {
+		a := lexer.Analyze(tc.sql, lexer.MySQL)
+		if a.Complete != tc.complete {
+			t.Errorf("Complete = %v (%s), want %v: %s", a.Complete, a.Reason, tc.complete, tc.sql)
+		}
+	}
+}
+
+// MySQL's multi-table forms name several targets, and the keyword after the
+// verb is optional.
+func TestMySQLWriteTargets(t *testing.T) {
+	for _, tc := range []struct {
+		sql         string
+		write, read []string
+	}{
+		{"DELETE t1, t2 FROM t1 JOIN t2 ON t1.id = t2.id JOIN t3 ON t3.id = t1.id", []string{"t1", "t2"}, []string{"t3"}},
+		{"DELETE t1.* FROM t1 JOIN t2 ON t1.id = t2.id", []string{"t1"}, []string{"t2"}},
+		{"DELETE FROM t1, t2 USING t1 JOIN t2 JOIN t3", []string{"t1", "t2"}, []string{"t3"}},
+		{"UPDATE t1 JOIN t2 ON t1.id = t2.id SET t2.n = t1.n", []string{"t1", "t2"}, nil},
+		{"UPDATE t1, t2 SET t1.n = t2.n", []string{"t1", "t2"}, nil},
+		{"INSERT t (a) VALUES (1)", []string{"t"}, nil},
+		{"INSERT IGNORE INTO t (a) VALUES (1) ON DUPLICATE KEY UPDATE a = 2", []string{"t"}, nil},
+		{"LOAD DATA LOCAL INFILE 'x.csv' INTO TABLE customers", []string{"customers"}, nil},
+		{"SELECT * INTO OUTFILE '/tmp/x' FROM customers", nil, []string{"customers"}},
+	} {
+		a := lexer.Analyze(tc.sql, lexer.MySQL)
+		if got := writes(a); !slices.Equal(got, tc.write) {
+			t.Errorf("writes = %v, want %v: %s", got, tc.write, tc.sql)
+		}
+		if got := reads(a); !slices.Equal(got, tc.read) {
+			t.Errorf("reads = %v, want %v: %s", got, tc.read, tc.sql)
+		}
+	}
+	// REPLACE deletes the row it conflicts with.
+	if a := lexer.Analyze("REPLACE INTO t (a) VALUES (1)", lexer.MySQL); !slices.Contains(a.Effects, lexer.Delete) {
+		t.Errorf("effects = %v, want a delete", a.Effects)
+	}
+}
+
+// Oracle's q-quote needs no escape for the quote inside it, so a scanner
+// without it ends the literal early and reads the rest as SQL.
+func TestOracleQQuote(t *testing.T) {
+	for _, sql := range []string{
+		`SELECT q'[it's; DELETE FROM customers]' FROM dual`,
+		`SELECT Q'{it's; DELETE FROM customers}' FROM dual`,
+		`SELECT nq'!it's; DELETE FROM customers!' FROM dual`,
+		`SELECT q'<a]'>' FROM dual`,
+	} {
+		a := lexer.Analyze(sql, lexer.Oracle)
+		if a.Writes() || !a.Complete {
+			t.Errorf("a q-quoted literal was read as SQL: %+v: %s", a, sql)
+		}
+	}
+	if a := lexer.Analyze(`SELECT q'[unterminated FROM dual`, lexer.Oracle); a.Complete {
+		t.Error("Complete = true on an unterminated q-quote")
+	}
+}
+
+// An anonymous block runs a program, and concedes the way DO does.
+// Defining a procedure performs one effect; its body is data.
+func TestOraclePLSQLUnits(t *testing.T) {
+	for _, sql := range []string{
+		`BEGIN DELETE FROM customers; END;`,
+		`DECLARE n NUMBER; BEGIN SELECT count(*) INTO n FROM customers; END;`,
+	} {
+		a := lexer.Analyze(sql, lexer.Oracle)
+		if a.Complete || a.Reason == "" {
+			t.Errorf("an anonymous block was not conceded: %+v", a)
+		}
+	}
+
+	def := lexer.Analyze(
+		"CREATE OR REPLACE PROCEDURE purge AS\nBEGIN\n  DELETE FROM customers;\nEND;\n/\n", lexer.Oracle)
+	if !def.Complete || def.Writes() && slices.Contains(writes(def), "customers") {
+		t.Errorf("a procedure definition reported its body: %+v", def)
+	}
+	if got := def.Severity(); got != lexer.Create {
+		t.Errorf("Severity() = %q, want create", got)
+	}
+
+	// A trigger's header still names the table it fires on.
+	trg := lexer.Analyze(
+		`CREATE TRIGGER t BEFORE DELETE ON customers FOR EACH ROW BEGIN INSERT INTO audit VALUES (1); END;`,
+		lexer.Oracle)
+	if got := writes(trg); !slices.Equal(got, []string{"customers"}) {
+		t.Errorf("writes = %v, want [customers]", got)
+	}
+}
+
+// A unit's semicolons do not end it; the / line does.
+func TestOracleSplitKeepsUnitsWhole(t *testing.T) {
+	got := lexer.Split(
+		"CREATE PROCEDURE p AS\nBEGIN\n  DELETE FROM t;\n  COMMIT;\nEND;\n/\nSELECT a / b FROM t;\nDELETE x", lexer.Oracle)
+	want := []string{
+		"CREATE PROCEDURE p AS\nBEGIN\n  DELETE FROM t;\n  COMMIT;\nEND;",
+		"SELECT a / b FROM t",
+		"DELETE x",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Split = %q, want %q", got, want)
+	}
+}
+
+// Oracle writes without FROM, merges with a trailing DELETE WHERE, and
+// inserts into several tables at once.
+func TestOracleWriteTargets(t *testing.T) {
+	for _, tc := range []struct {
+		sql   string
+		write []string
+	}{
+		{`DELETE customers WHERE id = 1`, []string{"customers"}},
+		{`INSERT ALL INTO t1 VALUES (1) INTO t2 VALUES (2) SELECT * FROM dual`, []string{"t1", "t2"}},
+		{`SELECT * FROM v$session`, nil},
+	} {
+		if got := writes(lexer.Analyze(tc.sql, lexer.Oracle)); !slices.Equal(got, tc.write) {
+			t.Errorf("writes = %v, want %v: %s", got, tc.write, tc.sql)
+		}
+	}
+	merge := lexer.Analyze(
+		`MERGE INTO c USING s ON (c.id = s.id) WHEN MATCHED THEN UPDATE SET c.n = s.n DELETE WHERE c.dead = 1`,
+		lexer.Oracle)
+	if !slices.Contains(merge.Effects, lexer.Delete) {
+		t.Errorf("the MERGE delete clause was missed: %v", merge.Effects)
+	}
+	if got := reads(lexer.Analyze(`SELECT * FROM v$session`, lexer.Oracle)); !slices.Equal(got, []string{"v$session"}) {
+		t.Errorf("reads = %v, want [v$session]", got)
+	}
+	if a := lexer.Analyze(`UPDATE (SELECT * FROM customers) SET n = 1`, lexer.Oracle); a.Complete {
+		t.Errorf("a write through an inline view was reported complete: %+v", a)
+	}
+}
+
+// An option list before the wrapped statement must not turn a plan into a
+// write.
+func TestExplainOptionsDoNotWrite(t *testing.T) {
+	for _, tc := range []struct {
+		sql string
+		d   lexer.Dialect
+	}{
+		{`EXPLAIN (FORMAT JSON) DELETE FROM customers`, lexer.Postgres},
+		{`EXPLAIN FORMAT=TREE DELETE FROM customers`, lexer.MySQL},
+		{`EXPLAIN PLAN FOR DELETE FROM customers`, lexer.Oracle},
+	} {
+		if a := lexer.Analyze(tc.sql, tc.d); a.Writes() || len(writes(a)) != 0 {
+			t.Errorf("a plan reported a write: %+v: %s", a, tc.sql)
+		}
+	}
+}
+
+// An UPDATE or DELETE reports whether anything limits its rows, so a rule can
+// refuse the one whose WHERE clause never made it into the buffer.
+func TestWriteScopes(t *testing.T) {
+	for _, tc := range []struct {
+		d          lexer.Dialect
+		sql        string
+		unfiltered []string // targets of each unfiltered scope, joined
+	}{
+		{lexer.Postgres, `DELETE FROM customers`, []string{"customers"}},
+		{lexer.Postgres, `DELETE FROM customers WHERE id = 1`, nil},
+		{lexer.Postgres, `UPDATE customers SET tier = 'gold' WHERE "Id" = 1`, nil},
+		// Constants filter nothing, however they are dressed up.
+		{lexer.Postgres, `DELETE FROM customers WHERE 1=1`, []string{"customers"}},
+		{lexer.Postgres, `DELETE FROM customers WHERE (1 = 1) OR NOT NULL IS NULL`, []string{"customers"}},
+		{lexer.Postgres, `UPDATE customers SET tier = 'x' WHERE true RETURNING id`, []string{"customers"}},
+		// A WHERE in a subquery filters the subquery.
+		{lexer.Postgres, `UPDATE customers SET tier = (SELECT max(t) FROM tiers WHERE tiers.k = 1)`, []string{"customers"}},
+		// PostgreSQL's target is not in its FROM's join tree, so the ON
+		// relates the sources only.
+		{lexer.Postgres, `UPDATE customers SET tier = s.t FROM staging s JOIN tiers t ON s.k = t.k`, []string{"customers"}},
+		{lexer.Postgres, `UPDATE customers c SET tier = s.t FROM staging s WHERE c.id = s.id`, nil},
+		{lexer.Postgres, `WITH d AS (DELETE FROM customers RETURNING *) SELECT count(*) FROM d`, []string{"customers"}},
+		{lexer.Postgres, `WITH d AS (DELETE FROM a WHERE old RETURNING *) UPDATE b SET n = 0`, []string{"b"}},
+		{lexer.Postgres, `DELETE FROM a; DELETE FROM b WHERE id = 2`, []string{"a"}},
+		// The conflict or the ON clause chooses the rows.
+		{lexer.Postgres, `INSERT INTO t VALUES (1) ON CONFLICT (id) DO UPDATE SET n = 1`, nil},
+		{lexer.Postgres, `MERGE INTO t USING s ON t.id = s.id WHEN MATCHED THEN DELETE`, nil},
+		{lexer.Oracle, `MERGE INTO t USING s ON (t.id = s.id) WHEN MATCHED THEN UPDATE SET x = 1 DELETE WHERE x = 0`, nil},
+		// A plan changes nothing; an executed plan does.
+		{lexer.Postgres, `EXPLAIN DELETE FROM t`, nil},
+		{lexer.Postgres, `EXPLAIN ANALYZE DELETE FROM t`, []string{"t"}},
+		// A LIMIT bounds how many rows, not which.
+		{lexer.MySQL, `DELETE FROM t LIMIT 1000`, []string{"t"}},
+		// MySQL and T-SQL join the target itself.
+		{lexer.MySQL, `UPDATE a JOIN b ON a.id = b.id SET a.x = b.y`, nil},
+		{lexer.MySQL, `UPDATE a JOIN b ON 1 = 1 SET a.x = b.y`, []string{"a b"}},
+		{lexer.MySQL, `DELETE a FROM a JOIN b USING (id)`, nil},
+		{lexer.MySQL, `DELETE FROM a USING a, b`, []string{"a"}},
+		{lexer.MSSQL, `DELETE t FROM t INNER JOIN s ON t.id = s.id`, nil},
+		{lexer.MSSQL, `DELETE FROM t WHERE CURRENT OF c`, nil},
+	} {
+		var got []string
+		for _, s := range lexer.Analyze(tc.sql, tc.d).Unfiltered() {
+			got = append(got, strings.Join(s.Targets, " "))
+		}
+		if !slices.Equal(got, tc.unfiltered) {
+			t.Errorf("%s: unfiltered = %q, want %q", tc.sql, got, tc.unfiltered)
+		}
+	}
+}
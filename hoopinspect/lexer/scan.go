@@ -34,6 +34,11 @@ type Token struct {
 
 	// Text is lowercased for Word, verbatim for Quoted, empty for Literal.
 	Text string
+
+	// quotingMode marks a Literal whose content names a MySQL sql_mode that
+	// changes quoting. It is the one fact about a literal's content the
+	// analysis keeps, and only under sqlModeQuoting.
+	quotingMode bool
 }
 
 // isWord reports whether t is the given bare keyword. Quoted identifiers
@@ -67,8 +72,39 @@ type scanner struct {
 	// incomplete records the first construct the scanner could not finish.
 	// It travels to Analysis.Complete, and a caller fails closed on it.
 	incomplete string
+
+	// inExecComment is true inside a MySQL /*! ... */ body, so the */ that
+	// closes it is skipped rather than read as punctuation.
+	inExecComment bool
+
+	// unit and unitDepth track where the current statement stands relative
+	// to a PL/SQL unit. See trackUnit.
+	unit      unitState
+	unitDepth int
 }
 
+// unitState is the position of the current statement relative to a PL/SQL
+// unit body.
+type unitState uint8
+
+const (
+	// unitStart is the beginning of a statement.
+	unitStart unitState = iota
+
+	// unitCreate follows CREATE; the object kind decides what comes next.
+	unitCreate
+
+	// unitHeader is inside a unit's header, before the body begins.
+	unitHeader
+
+	// unitBody means the next token is the body.
+	unitBody
+
+	// unitPlain is an ordinary statement, or a unit whose body is consumed.
+	// Nothing changes until the statement ends.
+	unitPlain
+)
+
 // scan tokenizes src. The second result is empty when the whole input was
 // understood, and otherwise names what defeated it.
 func scan(src string, d Dialect) ([]Token, string) {
@@ -99,9 +135,26 @@ func (s *scanner) peek(off int) byte {
 
 // next returns the following token, or ok=false at end of input.
 func (s *scanner) next() (Token, bool) {
+	if s.unit == unitBody {
+		s.unit = unitPlain
+		return s.plsqlBody(), true
+	}
+	tok, ok := s.token()
+	if ok && s.rules.plsql {
+		s.trackUnit(tok)
+	}
+	return tok, ok
+}
+
+// token scans one token with no regard for PL/SQL units.
+func (s *scanner) token() (Token, bool) {
 	for {
 		s.skipSpace()
 		if s.pos >= len(s.src) {
+			if s.inExecComment {
+				s.fail("unterminated executable comment")
+				s.inExecComment = false
+			}
 			return Token{}, false
 		}
 		if s.skipComment() {
@@ -112,20 +165,38 @@ func (s *scanner) next() (Token, bool) {
 
 	c := s.src[s.pos]
 	switch {
+	case s.rules.plsql && c == '/' && s.slashLine():
+		s.pos++
+		return Token{Kind: Punct, Text: ";"}, true
+
 	case c == '\'':
-		return s.plainString(), true
+		return s.plainString('\''), true
 
 	case s.rules.escapeString && (c == 'E' || c == 'e') && s.peek(1) == '\'':
 		s.pos++
 		return s.escapedString(), true
 
+	case s.rules.qQuote && (c == 'Q' || c == 'q') && s.peek(1) == '\'':
+		s.pos++
+		return s.qString(), true
+
+	case s.rules.qQuote && (c == 'N' || c == 'n') && (s.peek(1) == 'Q' || s.peek(1) == 'q') && s.peek(2) == '\'':
+		s.pos += 2
+		return s.qString(), true
+
 	case s.rules.nationalString && (c == 'N' || c == 'n') && s.peek(1) == '\'':
 		s.pos++
-		return s.plainString(), true
+		return s.plainString('\''), true
+
+	case s.rules.doubleQuotedString && c == '"':
+		return s.plainString('"'), true
 
 	case c == '"':
 		return s.delimitedIdent('"', '"'), true
 
+	case s.rules.backtickIdent && c == '`':
+		return s.delimitedIdent('`', '`'), true
+
 	case s.rules.unicodeIdent && (c == 'U' || c == 'u') && s.peek(1) == '&' && s.peek(2) == '"':
 		s.pos += 2
 		return s.delimitedIdent('"', '"'), true
@@ -166,16 +237,28 @@ func (s *scanner) skipSpace() {
 
 // skipComment consumes one comment and reports whether it did.
 func (s *scanner) skipComment() bool {
-	if s.peek(0) == '-' && s.peek(1) == '-' {
-		for s.pos < len(s.src) && s.src[s.pos] != '\n' {
-			s.pos++
-		}
+	if s.peek(0) == '-' && s.peek(1) == '-' &&
+		(!s.rules.dashCommentNeedsSpace || s.peek(2) <= ' ') {
+		s.skipLine()
+		return true
+	}
+	if s.rules.hashComment && s.peek(0) == '#' {
+		s.skipLine()
+		return true
+	}
+	if s.inExecComment && s.peek(0) == '*' && s.peek(1) == '/' {
+		// The close of a /*! body. What it enclosed was scanned as SQL.
+		s.inExecComment = false
+		s.pos += 2
 		return true
 	}
 	if s.peek(0) != '/' || s.peek(1) != '*' {
 		return false
 	}
-	// Both supported engines NEST block comments, unlike the standard. A
+	if s.rules.executableComment && !s.inExecComment && s.openExecComment() {
+		return true
+	}
+	// PostgreSQL and T-SQL NEST block comments, unlike the standard. A
 	// scanner stopping at the first close reads the tail of an outer
 	// comment as live SQL: `/* a /* b */ DELETE FROM t */` is entirely
 	// comment, and stopping early reports a delete.
@@ -203,26 +286,99 @@ func (s *scanner) skipComment() bool {
 	return true
 }
 
-// plainString consumes '...' with the doubled '' escape.
-func (s *scanner) plainString() Token {
+func (s *scanner) skipLine() {
+	for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+		s.pos++
+	}
+}
+
+// openExecComment consumes the opener of a MySQL executable comment, /*! or
+// MariaDB's /*M!, with the optional version that follows it, and reports
+// whether there was one. The body is then scanned as ordinary SQL.
+func (s *scanner) openExecComment() bool {
+	n := 2
+	if s.peek(2) == 'M' && s.peek(3) == '!' {
+		n = 3
+	}
+	if s.peek(n) != '!' {
+		return false
+	}
+	s.pos += n + 1
+	// Five digits, or six since MySQL 8.0 grew a two-digit minor version.
+	for range 6 {
+		if c := s.peek(0); c < '0' || c > '9' {
+			break
+		}
+		s.pos++
+	}
+	s.inExecComment = true
+	return true
+}
+
+// plainString consumes a string delimited by quote, with the doubled-quote
+// escape: '...', and "..." where that is a string.
+func (s *scanner) plainString(quote byte) Token {
 	s.pos++ // opening quote
+	start := s.pos
 	for s.pos < len(s.src) {
 		c := s.src[s.pos]
 		if s.rules.backslashInPlainString && c == '\\' && s.pos+1 < len(s.src) {
 			s.pos += 2
 			continue
 		}
-		if c == '\'' {
-			if s.peek(1) == '\'' {
+		if c == quote {
+			if s.peek(1) == quote {
 				s.pos += 2
 				continue
 			}
 			s.pos++
-			return Token{Kind: Literal}
+			return s.literal(s.src[start : s.pos-1])
 		}
 		s.pos++
 	}
 	s.fail("unterminated string literal")
+	return s.literal(s.src[start:])
+}
+
+// literal returns the token for a string constant with this content. The
+// content is dropped; see Kind.
+func (s *scanner) literal(content string) Token {
+	return Token{Kind: Literal, quotingMode: s.rules.sqlModeQuoting && namesQuotingMode(content)}
+}
+
+// qString consumes an Oracle q'X...X' literal, positioned on its quote. The
+// byte after the quote is the delimiter, and an opening bracket closes with
+// its partner. Only the close delimiter followed by a quote ends it.
+func (s *scanner) qString() Token {
+	s.pos++ // opening quote
+	if s.pos >= len(s.src) {
+		s.fail("unterminated q-quoted string")
+		return Token{Kind: Literal}
+	}
+	open := s.src[s.pos]
+	close := open
+	switch open {
+	case '[':
+		close = ']'
+	case '(':
+		close = ')'
+	case '{':
+		close = '}'
+	case '<':
+		close = '>'
+	case ' ', '\t', '\r', '\n':
+		// Oracle refuses whitespace as a delimiter, so there is no telling
+		// where this literal was meant to end.
+		s.fail("invalid q-quote delimiter")
+		return Token{Kind: Literal}
+	}
+	s.pos++
+	if i := strings.Index(s.src[s.pos:], string([]byte{close, '\''})); i >= 0 {
+		s.pos += i + 2
+		return Token{Kind: Literal}
+	}
+	s.fail("unterminated q-quoted string")
+	s.pos = len(s.src)
 	return Token{Kind: Literal}
 }
 
@@ -310,12 +466,108 @@ func (s *scanner) number() Token {
 
 func (s *scanner) word() Token {
 	start := s.pos
-	for s.pos < len(s.src) && isWordByte(s.src[s.pos]) {
+	for s.pos < len(s.src) && (isWordByte(s.src[s.pos]) || s.continuesWord(s.src[s.pos])) {
 		s.pos++
 	}
 	return Token{Kind: Word, Text: strings.ToLower(s.src[start:s.pos])}
 }
 
+// continuesWord reports whether c continues a bare identifier in this
+// dialect beyond what isWordByte admits everywhere.
+func (s *scanner) continuesWord(c byte) bool {
+	return (c == '$' && s.rules.dollarInIdent) || (c == '#' && s.rules.hashInIdent)
+}
+
+// slashLine reports whether the '/' at s.pos stands alone on its line, which
+// is how Oracle tools end a PL/SQL unit. A '/' anywhere else is division.
+func (s *scanner) slashLine() bool {
+	for i := s.pos - 1; i >= 0 && s.src[i] != '\n'; i-- {
+		if !isLineSpace(s.src[i]) {
+			return false
+		}
+	}
+	for i := s.pos + 1; i < len(s.src) && s.src[i] != '\n'; i++ {
+		if !isLineSpace(s.src[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLineSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\f', '\v':
+		return true
+	}
+	return false
+}
+
+// trackUnit advances the PL/SQL unit state past one token.
+//
+// Only the head of a statement matters. BEGIN or DECLARE opens an anonymous
+// block whose body starts at once; CREATE [OR REPLACE] followed by one of
+// the unit kinds opens a header, and the body starts at the first keyword in
+// plsqlBodyStart outside parentheses. Anything else is an ordinary statement
+// and is left alone until it ends.
+func (s *scanner) trackUnit(t Token) {
+	if t.Kind == Punct {
+		switch t.Text {
+		case ";":
+			s.unit, s.unitDepth = unitStart, 0
+			return
+		case "(":
+			s.unitDepth++
+		case ")":
+			s.unitDepth--
+		}
+	}
+	switch s.unit {
+	case unitStart:
+		switch {
+		case t.isWord("begin"), t.isWord("declare"):
+			s.unit = unitBody
+		case t.isWord("create"):
+			s.unit = unitCreate
+		default:
+			s.unit = unitPlain
+		}
+	case unitCreate:
+		switch {
+		case t.Kind == Word && createModifier[t.Text]:
+		case t.Kind == Word && plsqlUnit[t.Text]:
+			s.unit = unitHeader
+		default:
+			s.unit = unitPlain
+		}
+	case unitHeader:
+		if s.unitDepth == 0 && t.Kind == Word && plsqlBodyStart[t.Text] {
+			s.unit = unitBody
+		}
+	}
+}
+
+// plsqlBody consumes a PL/SQL unit body and returns it as one literal.
+//
+// The body is walked token by token rather than searched for its end, so a
+// '/' line inside a string or a comment does not end it. It stops before the
+// terminating '/' line, which the next call emits as a ';'.
+func (s *scanner) plsqlBody() Token {
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.src) {
+			break
+		}
+		if s.skipComment() {
+			continue
+		}
+		if s.src[s.pos] == '/' && s.slashLine() {
+			break
+		}
+		s.token()
+	}
+	return Token{Kind: Literal}
+}
+
 // isWordByte reports whether c can appear in a bare identifier.
 //
 // '$' is excluded even though PostgreSQL allows it in identifiers after the
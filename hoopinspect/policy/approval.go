@@ -0,0 +1,238 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// ActionApprove holds a matching statement until a person approves it.
+//
+// It is the middle ground between denying and deferring. A DROP on a
+// production database is usually wrong and occasionally the job, and the
+// two answers a rule had were "never" and "let a policy decide", neither of
+// which puts a human in the loop at the moment it matters.
+//
+// A holding rule does not stop the rule set, for the reason a deferring one
+// does not: a later hard rule still denies, and a statement some rule
+// refuses outright is never put in front of an approver who might wave it
+// through. Only when nothing denies does the verdict come back held.
+const ActionApprove = "approve"
+
+// Approval outcomes, recorded under AnnotationApproval.
+const (
+	ApprovalApproved = "approved"
+	ApprovalDenied   = "denied"
+	ApprovalTimeout  = "timeout"
+
+	// ApprovalFailed is an approver that could not be asked or gave an
+	// answer that could not be read. It refuses, like the rest.
+	ApprovalFailed = "failed"
+)
+
+// Annotation keys an approval writes onto the verdict, and so onto the
+// audit event. The approver is who answered, as the approval service names
+// them; the statement never appears here.
+const (
+	AnnotationApproval = "approval"
+	AnnotationApprover = "approver"
+)
+
+// ErrApprovalTimeout reports that no answer arrived within the approver's
+// timeout.
+var ErrApprovalTimeout = errors.New("policy/approval: no answer before the timeout")
+
+// ApprovalRequest is what an Approver is asked about.
+type ApprovalRequest struct {
+	// Lane is the connection name the statement arrived on.
+	Lane string
+
+	// Rule and Message are the holding rule's name and its message.
+	Rule    string
+	Message string
+
+	Statement hoopinspect.Statement
+
+	// Context is the session's policy context: its id, the principal, the
+	// groups. The same map OPA reads as input.context.
+	Context map[string]string
+}
+
+// Approval is an approver's answer.
+type Approval struct {
+	Approved bool
+
+	// Approver names who answered. Recorded, never shown to the user.
+	Approver string
+
+	// Reason is shown to the user on a refusal.
+	Reason string
+}
+
+// Approver decides whether a held statement may run.
+//
+// Approve blocks until it has an answer. Its error is the approver's own
+// failure, never a refusal: a refusal is an Approval with Approved false.
+// Implementations must be safe for concurrent use, and must return when ctx
+// is done.
+type Approver interface {
+	Approve(ctx context.Context, req ApprovalRequest) (Approval, error)
+}
+
+// AwaitApproval asks a about the statement v held, and returns the verdict
+// the answer makes: an allow when approved, a denial otherwise. Either way
+// the outcome and the approver are on its Annotations, so the audit event
+// records who let the statement through or who stopped it.
+//
+// A nil Approver refuses. A rule that holds with nobody to ask is a
+// misconfiguration, and the statement waits for nobody.
+func AwaitApproval(ctx context.Context, a Approver, v Verdict, req ApprovalRequest) Verdict {
+	annotate := func(out Verdict, outcome, approver string) Verdict {
+		out.Annotations = mergeAnnotations(map[string]string{}, v.Annotations)
+		out.Annotations[AnnotationApproval] = outcome
+		if approver != "" {
+			out.Annotations[AnnotationApprover] = approver
+		}
+		out.Err = v.Err
+		return out
+	}
+	if a == nil {
+		out := Deny(v.Rule, "statement needs approval and no approver is configured; denying")
+		out.Err = errors.New("policy/approval: no approver configured")
+		return annotate(out, ApprovalFailed, "")
+	}
+
+	ans, err := a.Approve(ctx, req)
+	switch {
+	case errors.Is(err, ErrApprovalTimeout) || errors.Is(err, context.DeadlineExceeded):
+		return annotate(Deny(v.Rule, "no approval arrived in time; statement refused"), ApprovalTimeout, "")
+	case err != nil:
+		out := annotate(Deny(v.Rule, "approval service unavailable; denying"), ApprovalFailed, "")
+		out.Err = errors.Join(out.Err, err)
+		return out
+	case !ans.Approved:
+		msg := "statement was not approved"
+		if ans.Reason != "" {
+			msg += ": " + ans.Reason
+		}
+		return annotate(Deny(v.Rule, msg), ApprovalDenied, ans.Approver)
+	}
+	// Rule stays set on the allow: the record of an approved DROP should
+	// say which rule asked for the approval.
+	return annotate(Verdict{Rule: v.Rule, Message: v.Message}, ApprovalApproved, ans.Approver)
+}
+
+// HTTPApprover asks an approval service over HTTP.
+//
+// It POSTs the request as JSON and waits for the service to answer, which it
+// does once someone has decided:
+//
+//	{"approved": true, "approver": "alice@example.com"}
+//	{"approved": false, "approver": "bob@example.com", "reason": "not during the freeze"}
+//
+// The request is a long poll rather than a callback. The relay then needs no
+// listener of its own, and a service that forgets the request releases the
+// statement at Timeout instead of holding it forever.
+type HTTPApprover struct {
+	// URL is the approval endpoint.
+	URL string
+
+	// HTTPClient serves the requests. When nil, the client builds one.
+	HTTPClient *http.Client
+
+	// Header is sent with every request: the service's credentials.
+	Header http.Header
+
+	// Timeout bounds how long a statement waits for an answer. The
+	// session's statement is parked for all of it. Defaults to two
+	// minutes.
+	Timeout time.Duration
+}
+
+// approvalWire is the body an HTTPApprover posts.
+//
+// Statement is the document OPA reads as input, so a service that already
+// reasons about statements in Rego reads this one the same way.
+type approvalWire struct {
+	Lane      string         `json:"lane,omitempty"`
+	SessionID string         `json:"session_id,omitempty"`
+	Rule      string         `json:"rule"`
+	Message   string         `json:"message,omitempty"`
+	Identity  *inputIdentity `json:"identity,omitempty"`
+	Statement opaInput       `json:"statement"`
+}
+
+// approvalAnswer is the body a service answers with. Approved is a pointer so
+// an answer missing it is refused rather than read as false.
+type approvalAnswer struct {
+	Approved *bool  `json:"approved"`
+	Approver string `json:"approver"`
+	Reason   string `json:"reason"`
+}
+
+// Approve implements Approver.
+func (h *HTTPApprover) Approve(ctx context.Context, req ApprovalRequest) (Approval, error) {
+	body, err := json.Marshal(approvalWire{
+		Lane:      req.Lane,
+		SessionID: req.Context["session_id"],
+		Rule:      req.Rule,
+		Message:   req.Message,
+		Identity:  identityOf(req.Context),
+		Statement: newInput(req.Statement, req.Context, "", nil),
+	})
+	if err != nil {
+		return Approval{}, fmt.Errorf("policy/approval: encoding request: %w", err)
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	hr, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return Approval{}, fmt.Errorf("policy/approval: building request: %w", err)
+	}
+	for k, v := range h.Header {
+		hr.Header[k] = v
+	}
+	hr.Header.Set("Content-Type", "application/json")
+
+	client := h.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(hr)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return Approval{}, ErrApprovalTimeout
+		}
+		return Approval{}, fmt.Errorf("policy/approval: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Approval{}, fmt.Errorf("policy/approval: unexpected status %d", resp.StatusCode)
+	}
+
+	var ans approvalAnswer
+	if err := json.NewDecoder(resp.Body).Decode(&ans); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return Approval{}, ErrApprovalTimeout
+		}
+		return Approval{}, fmt.Errorf("policy/approval: decoding answer: %w", err)
+	}
+	if ans.Approved == nil {
+		return Approval{}, errors.New(`policy/approval: answer carried no "approved"`)
+	}
+	return Approval{Approved: *ans.Approved, Approver: ans.Approver, Reason: ans.Reason}, nil
+}
+
+var _ Approver = (*HTTPApprover)(nil)
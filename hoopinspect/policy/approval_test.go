@@ -0,0 +1,184 @@
+package policy_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/policy"
+)
+
+func holdDrops(t *testing.T, more ...policy.Rule) *policy.Rules {
+	t.Helper()
+	rules, err := policy.NewRules(append([]policy.Rule{{
+		Name: "drop-needs-approval", Type: policy.MatchOperation,
+		Operations: []hoopinspect.Operation{hoopinspect.OpDrop},
+		Action:     policy.ActionApprove,
+	}}, more...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+// A held verdict is a denial to anyone who does not know about holds, so a
+// caller that never asks an approver still refuses the statement.
+func TestApproveRuleHolds(t *testing.T) {
+	v := holdDrops(t).Evaluate(stmt("DROP TABLE t", hoopinspect.OpDrop, "t"))
+	if !v.Denied || !v.Approval || v.Rule != "drop-needs-approval" {
+		t.Fatalf("verdict = %+v, want a held denial naming the rule", v)
+	}
+	if !strings.Contains(v.Message, "approval") {
+		t.Errorf("message %q should say the statement waits for approval", v.Message)
+	}
+}
+
+// A later hard rule is heard before anyone is asked: an approver must never
+// be the one to wave through a statement a rule refuses outright.
+func TestApproveRuleYieldsToALaterDenial(t *testing.T) {
+	rules := holdDrops(t, policy.Rule{
+		Name: "no-customers", Type: policy.MatchTable, Tables: []string{"customers"},
+	})
+	v := rules.Evaluate(stmt("DROP TABLE customers", hoopinspect.OpDrop, "customers"))
+	if !v.Denied || v.Approval || v.Rule != "no-customers" {
+		t.Errorf("verdict = %+v, want the hard denial", v)
+	}
+}
+
+func TestChainHoldYieldsToALaterDenial(t *testing.T) {
+	drop := stmt("DROP TABLE t", hoopinspect.OpDrop, "t")
+	strict := &stubEvaluator{verdict: policy.Deny("opa", "refused by policy")}
+	if v := (policy.Chain{holdDrops(t), strict}).Evaluate(drop); !strict.ran || v.Approval || v.Rule != "opa" {
+		t.Errorf("verdict = %+v, want the later evaluator's denial", v)
+	}
+
+	allow := &stubEvaluator{verdict: policy.Allow()}
+	if v := (policy.Chain{holdDrops(t), allow}).Evaluate(drop); !allow.ran || !v.Approval {
+		t.Errorf("verdict = %+v, want the hold once nothing denies", v)
+	}
+}
+
+func TestApproveRejectedOnAIAnalysis(t *testing.T) {
+	_, err := policy.NewRules([]policy.Rule{{
+		Name: "ai", Type: policy.MatchAIAnalysis, Action: policy.ActionApprove,
+	}})
+	if err == nil || !strings.Contains(err.Error(), "risk level") {
+		t.Errorf("err = %v, want the action refused on ai_analysis", err)
+	}
+}
+
+type stubApprover struct {
+	ans policy.Approval
+	err error
+}
+
+func (s *stubApprover) Approve(context.Context, policy.ApprovalRequest) (policy.Approval, error) {
+	return s.ans, s.err
+}
+
+func TestAwaitApprovalOutcomes(t *testing.T) {
+	held := holdDrops(t).Evaluate(stmt("DROP TABLE t", hoopinspect.OpDrop, "t"))
+	for _, tc := range []struct {
+		name     string
+		a        policy.Approver
+		denied   bool
+		outcome  string
+		approver string
+		message  string
+	}{
+		{"approved", &stubApprover{ans: policy.Approval{Approved: true, Approver: "alice"}},
+			false, policy.ApprovalApproved, "alice", ""},
+		{"refused", &stubApprover{ans: policy.Approval{Approver: "bob", Reason: "not today"}},
+			true, policy.ApprovalDenied, "bob", "not today"},
+		{"timeout", &stubApprover{err: policy.ErrApprovalTimeout},
+			true, policy.ApprovalTimeout, "", "in time"},
+		{"failed", &stubApprover{err: errors.New("connection refused")},
+			true, policy.ApprovalFailed, "", "unavailable"},
+		{"no approver", nil, true, policy.ApprovalFailed, "", "no approver"},
+	} {
+		v := policy.AwaitApproval(context.Background(), tc.a, held, policy.ApprovalRequest{})
+		if v.Denied != tc.denied || v.Approval {
+			t.Errorf("%s: Denied = %v, Approval = %v; want Denied %v and the hold resolved",
+				tc.name, v.Denied, v.Approval, tc.denied)
+		}
+		if v.Rule != "drop-needs-approval" {
+			t.Errorf("%s: Rule = %q, want the holding rule either way", tc.name, v.Rule)
+		}
+		if got := v.Annotations[policy.AnnotationApproval]; got != tc.outcome {
+			t.Errorf("%s: approval = %q, want %q", tc.name, got, tc.outcome)
+		}
+		if got := v.Annotations[policy.AnnotationApprover]; got != tc.approver {
+			t.Errorf("%s: approver = %q, want %q", tc.name, got, tc.approver)
+		}
+		if tc.message != "" && !strings.Contains(v.Message, tc.message) {
+			t.Errorf("%s: message %q should mention %q", tc.name, v.Message, tc.message)
+		}
+	}
+}
+
+func TestHTTPApproverPostsAndReadsTheAnswer(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer k" {
+			http.Error(w, "who are you", http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"approved": true, "approver": "alice@example.com"}`))
+	}))
+	defer srv.Close()
+
+	a := &policy.HTTPApprover{URL: srv.URL, Header: http.Header{"Authorization": {"Bearer k"}}}
+	ans, err := a.Approve(context.Background(), policy.ApprovalRequest{
+		Lane: "appdb", Rule: "drop-needs-approval",
+		Statement: stmt("DROP TABLE t", hoopinspect.OpDrop, "t"),
+		Context:   map[string]string{"session_id": "s-1", "subject": "carol"},
+	})
+	if err != nil || !ans.Approved || ans.Approver != "alice@example.com" {
+		t.Fatalf("Approve = %+v, %v", ans, err)
+	}
+	if body["lane"] != "appdb" || body["session_id"] != "s-1" || body["rule"] != "drop-needs-approval" {
+		t.Errorf("request body = %v", body)
+	}
+	st, _ := body["statement"].(map[string]any)
+	if st["statement"] != "DROP TABLE t" || st["operation"] != "drop" {
+		t.Errorf("statement = %v, want the statement as OPA reads it", st)
+	}
+	if id, _ := body["identity"].(map[string]any); id["subject"] != "carol" {
+		t.Errorf("identity = %v", body["identity"])
+	}
+}
+
+func TestHTTPApproverRefusesAnAnswerWithoutApproved(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"approver": "alice"}`))
+	}))
+	defer srv.Close()
+
+	if _, err := (&policy.HTTPApprover{URL: srv.URL}).Approve(context.Background(), policy.ApprovalRequest{}); err == nil {
+		t.Error("an answer with no approved field was read as an answer")
+	}
+}
+
+func TestHTTPApproverTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	a := &policy.HTTPApprover{URL: srv.URL, Timeout: 50 * time.Millisecond}
+	if _, err := a.Approve(context.Background(), policy.ApprovalRequest{}); !errors.Is(err, policy.ErrApprovalTimeout) {
+		t.Errorf("err = %v, want ErrApprovalTimeout", err)
+	}
+}
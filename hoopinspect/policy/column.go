@@ -0,0 +1,174 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// MatchColumn denies when the statement reads or writes any of Columns.
+//
+// It is the table rule one level down, and it exists because a table is
+// usually too coarse a thing to protect. Nobody wants to stop support reading
+// customers; they want to stop support reading customers.ssn, and a table
+// rule can only say the first.
+//
+// The columns come from the request-side scan (Relation.Columns), and that
+// scan cannot always say. `SELECT *` names no column, and a qualifier the
+// scan could not resolve names one without saying whose. OnIncomplete decides
+// what the rule does then. By default it fails closed: a `SELECT *` from a
+// table holding a protected column is denied, which is the right answer for
+// a column that must never leave and an irritating one for a table people
+// query all day. Set it to "response" and the rule lets those statements
+// through and checks the result set's column names instead.
+const MatchColumn MatchType = "column"
+
+// On-incomplete modes for a MatchColumn rule.
+const (
+	// OnIncompleteDeny matches a statement whose columns could not be
+	// determined. The default.
+	OnIncompleteDeny = "deny"
+
+	// OnIncompleteResponse defers such a statement to its response, and
+	// matches a result set carrying a column of a protected name.
+	OnIncompleteResponse = "response"
+)
+
+// columnRef is one entry of a column rule's Columns, split.
+type columnRef struct {
+	// table is empty for a bare column name, which protects it on every
+	// relation.
+	table, column string
+}
+
+// parseColumn splits "schema.table.column", "table.column" or "column" at
+// the last dot.
+func parseColumn(s string) columnRef {
+	s = strings.ToLower(strings.TrimSpace(s))
+	i := strings.LastIndexByte(s, '.')
+	if i < 0 {
+		return columnRef{column: s}
+	}
+	return columnRef{table: s[:i], column: s[i+1:]}
+}
+
+// onRelation reports whether the reference can name a column of rel. A
+// table matches any schema qualification, the way a table rule's does.
+func (c columnRef) onRelation(rel string) bool {
+	return c.table == "" || rel == c.table || strings.HasSuffix(rel, "."+c.table)
+}
+
+// validateColumn checks a MatchColumn rule at construction.
+func (r Rule) validateColumn() error {
+	if len(r.Columns) == 0 {
+		return fmt.Errorf("%s: column rule with no columns", r.Name)
+	}
+	for _, c := range r.Columns {
+		parts := strings.Split(strings.TrimSpace(c), ".")
+		if len(parts) > 3 || slices.Contains(parts, "") || slices.Contains(parts, hoopinspect.AllColumns) {
+			return fmt.Errorf("%s: column %q: want column, table.column or schema.table.column", r.Name, c)
+		}
+	}
+	switch r.Access {
+	case "", string(hoopinspect.AccessRead), string(hoopinspect.AccessWrite):
+	default:
+		return fmt.Errorf("%s: unknown access %q (read, write, or empty for either)", r.Name, r.Access)
+	}
+	switch r.OnIncomplete {
+	case "", OnIncompleteDeny:
+	case OnIncompleteResponse:
+		// A response says which columns came back, never which were
+		// written, so deferring a write rule to it defers it to nothing.
+		if r.Access == string(hoopinspect.AccessWrite) {
+			return fmt.Errorf("%s: on_incomplete %q cannot check writes; use %q or drop access: write",
+				r.Name, OnIncompleteResponse, OnIncompleteDeny)
+		}
+	default:
+		return fmt.Errorf("%s: unknown on_incomplete %q (%q, or %q to check the result set)",
+			r.Name, r.OnIncomplete, OnIncompleteDeny, OnIncompleteResponse)
+	}
+	return nil
+}
+
+// matchesColumn reports whether the statement uses a protected column.
+//
+// On a request it reads Relation.Columns. A protected column counts when it
+// is used the way Access names: read is projected or filtered, write is
+// written. A "*" on a relation that may hold one is a match for a write
+// (a DELETE removes the column with the row, an INSERT with no column list
+// sets every one) and, for a read, is as incomplete as a statement the scan
+// gave up on.
+//
+// On a response it reads Result.Columns, and only for a rule deferring there.
+// A result set names columns and not their relations, so the check is by
+// name: a rule on customers.ssn also refuses an ssn from any other table.
+// Renaming a column with AS gets past it; the request side saw that alias,
+// and only a `*` or an unresolved reference reaches the response unchecked.
+func (r Rule) matchesColumn(stmt hoopinspect.Statement) bool {
+	refs := make([]columnRef, len(r.Columns))
+	for i, c := range r.Columns {
+		refs[i] = parseColumn(c)
+	}
+	deferred := r.OnIncomplete == OnIncompleteResponse
+
+	if stmt.Direction == hoopinspect.FromServer {
+		if !deferred || stmt.Result == nil {
+			return false
+		}
+		for _, got := range stmt.Result.Columns {
+			name := strings.ToLower(got.Name)
+			for _, ref := range refs {
+				if ref.column == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// A scan that gave up may have missed the relation too, so it counts
+	// whatever the statement names. A column scan that gave up named its
+	// relations correctly, and counts only where one could hold the column.
+	_, unread := stmt.Metadata[hoopinspect.MetadataSQLIncomplete]
+	_, unattributed := stmt.Metadata[hoopinspect.MetadataColumnsIncomplete]
+	incomplete := unread
+	for _, rel := range stmt.Relations {
+		for _, ref := range refs {
+			if !ref.onRelation(rel.Name) {
+				continue
+			}
+			incomplete = incomplete || unattributed
+			for _, col := range rel.Columns {
+				if !r.columnUseMatches(col) {
+					continue
+				}
+				if col.Name == ref.column {
+					return true
+				}
+				if col.Name != hoopinspect.AllColumns {
+					continue
+				}
+				if r.Access != string(hoopinspect.AccessRead) && col.Has(hoopinspect.ColumnWritten) {
+					return true
+				}
+				incomplete = true
+			}
+		}
+	}
+	return incomplete && !deferred
+}
+
+// columnUseMatches reports whether a column's uses satisfy the rule's
+// Access, with read covering both projected and filtered.
+func (r Rule) columnUseMatches(c hoopinspect.ColumnRef) bool {
+	read := c.Has(hoopinspect.ColumnProjected) || c.Has(hoopinspect.ColumnFiltered)
+	switch r.Access {
+	case string(hoopinspect.AccessRead):
+		return read
+	case string(hoopinspect.AccessWrite):
+		return c.Has(hoopinspect.ColumnWritten)
+	}
+	return read || c.Has(hoopinspect.ColumnWritten)
+}
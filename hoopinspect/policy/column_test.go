@@ -0,0 +1,120 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/policy"
+)
+
+func TestColumnRule(t *testing.T) {
+	rules, err := policy.NewRules([]policy.Rule{{
+		Name: "no-ssn", Type: policy.MatchColumn,
+		Columns: []string{"customers.ssn", "card_number"},
+		Message: "ssn and card numbers stay in the database",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		sql    string
+		denied bool
+	}{
+		{"SELECT ssn FROM customers", true},
+		{"SELECT c.SSN FROM public.customers c", true},
+		// A predicate reads the column as surely as a select list.
+		{"SELECT id FROM customers WHERE ssn LIKE '123%'", true},
+		{"UPDATE customers SET ssn = NULL WHERE id = 1", true},
+		{"SELECT card_number FROM payments", true},
+		{"SELECT id, name FROM customers", false},
+		{"SELECT ssn FROM employees", false},
+		{"SELECT count(*) FROM customers", false},
+		// What the scan cannot rule out, the default refuses.
+		{"SELECT * FROM customers", true},
+		{"SELECT x.ssn FROM customers c", true},
+		{"DO $$ BEGIN PERFORM 1; END $$", true},
+		{"SELECT * FROM orders", true}, // card_number is protected everywhere
+		// A DELETE takes every column with the row.
+		{"DELETE FROM customers WHERE id = 1", true},
+	} {
+		v := rules.Evaluate(analyzed(tc.sql, hoopinspect.Postgres))
+		if v.Denied != tc.denied {
+			t.Errorf("%s: denied = %v, want %v", tc.sql, v.Denied, tc.denied)
+		}
+	}
+}
+
+func TestColumnRuleAccess(t *testing.T) {
+	writes, _ := policy.NewRules([]policy.Rule{{
+		Name: "ssn-is-immutable", Type: policy.MatchColumn,
+		Columns: []string{"customers.ssn"}, Access: "write",
+	}})
+	for sql, denied := range map[string]bool{
+		"UPDATE customers SET ssn = $1 WHERE id = $2":  true,
+		"INSERT INTO customers VALUES (1, '123')":      true,
+		"INSERT INTO customers (id) VALUES (1)":        false,
+		"SELECT * FROM customers":                      false,
+		"UPDATE customers SET name = $1 WHERE ssn = 1": false,
+	} {
+		if v := writes.Evaluate(analyzed(sql, hoopinspect.Postgres)); v.Denied != denied {
+			t.Errorf("%s: denied = %v, want %v", sql, v.Denied, denied)
+		}
+	}
+}
+
+// Deferring to the response lets `SELECT *` run and refuses the result set
+// that turns out to carry the column.
+func TestColumnRuleDefersToResponse(t *testing.T) {
+	rules, err := policy.NewRules([]policy.Rule{{
+		Name: "no-ssn", Type: policy.MatchColumn,
+		Columns: []string{"customers.ssn"}, OnIncomplete: policy.OnIncompleteResponse,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.Evaluate(analyzed("SELECT * FROM customers", hoopinspect.Postgres)).Denied {
+		t.Error("SELECT * was refused on the request side")
+	}
+	if !rules.Evaluate(analyzed("SELECT ssn FROM customers", hoopinspect.Postgres)).Denied {
+		t.Error("a named column was deferred; only what the scan could not see should be")
+	}
+
+	response := func(cols ...string) hoopinspect.Statement {
+		s := hoopinspect.Statement{Protocol: hoopinspect.Postgres, Direction: hoopinspect.FromServer,
+			Result: &hoopinspect.ResultDetail{}}
+		for _, c := range cols {
+			s.Result.Columns = append(s.Result.Columns, hoopinspect.Column{Name: c})
+		}
+		return s
+	}
+	if !rules.Evaluate(response("id", "SSN")).Denied {
+		t.Error("a result set carrying ssn was allowed")
+	}
+	if rules.Evaluate(response("id", "name")).Denied {
+		t.Error("a result set without ssn was refused")
+	}
+
+	// Without the deferral the response is not the rule's business: the
+	// request side already decided.
+	strict, _ := policy.NewRules([]policy.Rule{{
+		Name: "no-ssn", Type: policy.MatchColumn, Columns: []string{"customers.ssn"},
+	}})
+	if strict.Evaluate(response("ssn")).Denied {
+		t.Error("a fail-closed rule also checked the response")
+	}
+}
+
+func TestColumnRuleValidation(t *testing.T) {
+	for name, r := range map[string]policy.Rule{
+		"no columns":           {Type: policy.MatchColumn},
+		"a star":               {Type: policy.MatchColumn, Columns: []string{"customers.*"}},
+		"an empty table":       {Type: policy.MatchColumn, Columns: []string{".ssn"}},
+		"an unknown access":    {Type: policy.MatchColumn, Columns: []string{"ssn"}, Access: "delete"},
+		"an unknown mode":      {Type: policy.MatchColumn, Columns: []string{"ssn"}, OnIncomplete: "allow"},
+		"a write on responses": {Type: policy.MatchColumn, Columns: []string{"ssn"}, Access: "write", OnIncomplete: "response"},
+	} {
+		if _, err := policy.NewRules([]policy.Rule{r}); err == nil {
+			t.Errorf("%s: NewRules accepted an invalid column rule", name)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package expr
+
+import (
+	"regexp"
+)
+
+// scope is the variables visible at one point: the environment, plus the
+// variables the enclosing macros bound.
+type scope struct {
+	env   Env
+	local map[string]*Type
+}
+
+func (s scope) lookup(name string) (*Type, bool) {
+	if t, ok := s.local[name]; ok {
+		return t, true
+	}
+	t, ok := s.env[name]
+	return t, ok
+}
+
+func (s scope) bind(name string, t *Type) scope {
+	local := make(map[string]*Type, len(s.local)+1)
+	for k, v := range s.local {
+		local[k] = v
+	}
+	local[name] = t
+	return scope{env: s.env, local: local}
+}
+
+type checker struct{ src string }
+
+func (c *checker) errorf(n node, format string, args ...any) error {
+	return errorAt(c.src, n.offset(), format, args...)
+}
+
+// check returns n's static type, or the first error in it.
+func (c *checker) check(n node, sc scope) (*Type, error) {
+	switch n := n.(type) {
+	case *litNode:
+		switch n.v.(type) {
+		case bool:
+			return Bool, nil
+		case int64:
+			return Int, nil
+		case string:
+			return String, nil
+		}
+		return Null, nil
+
+	case *identNode:
+		t, ok := sc.lookup(n.name)
+		if !ok {
+			return nil, c.errorf(n, "undefined variable %s", n.name)
+		}
+		return t, nil
+
+	case *selectNode:
+		x, err := c.check(n.x, sc)
+		if err != nil {
+			return nil, err
+		}
+		return c.field(n, x)
+
+	case *hasNode:
+		x, err := c.check(n.sel.x, sc)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := c.field(n.sel, x); err != nil {
+			return nil, err
+		}
+		return Bool, nil
+
+	case *indexNode:
+		x, err := c.check(n.x, sc)
+		if err != nil {
+			return nil, err
+		}
+		i, err := c.check(n.i, sc)
+		if err != nil {
+			return nil, err
+		}
+		switch x.Kind {
+		case KindList:
+			if !fits(i, Int) {
+				return nil, c.errorf(n.i, "a list index must be an int, not %s", i)
+			}
+			return x.Elem, nil
+		case KindMap:
+			if !fits(i, String) {
+				return nil, c.errorf(n.i, "a map key must be a string, not %s", i)
+			}
+			return x.Elem, nil
+		case KindObject:
+			// An object indexed by a literal is a field access in other
+			// clothes, and gets the same check.
+			if lit, ok := n.i.(*litNode); ok {
+				if name, ok := lit.v.(string); ok {
+					return c.field(&selectNode{at: n.i.offset(), x: n.x, field: name}, x)
+				}
+			}
+			return nil, c.errorf(n, "an object's fields are fixed; name one with a string literal or .field")
+		case KindDyn:
+			return Dyn, nil
+		}
+		return nil, c.errorf(n, "cannot index %s", x)
+
+	case *unaryNode:
+		x, err := c.check(n.x, sc)
+		if err != nil {
+			return nil, err
+		}
+		want := Bool
+		if n.op == "-" {
+			want = Int
+		}
+		if !fits(x, want) {
+			return nil, c.errorf(n, "%s needs %s, not %s", n.op, want, x)
+		}
+		return want, nil
+
+	case *binaryNode:
+		return c.binary(n, sc)
+
+	case *condNode:
+		cond, err := c.check(n.c, sc)
+		if err != nil {
+			return nil, err
+		}
+		if !fits(cond, Bool) {
+			return nil, c.errorf(n.c, "the condition of ?: must be a bool, not %s", cond)
+		}
+		t, err := c.check(n.t, sc)
+		if err != nil {
+			return nil, err
+		}
+		f, err := c.check(n.f, sc)
+		if err != nil {
+			return nil, err
+		}
+		if !comparable(t, f) {
+			return nil, c.errorf(n, "the branches of ?: differ: %s and %s", t, f)
+		}
+		return join(t, f), nil
+
+	case *listNode:
+		var elem *Type
+		for _, e := range n.elems {
+			t, err := c.check(e, sc)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case elem == nil:
+				elem = t
+			case !comparable(elem, t):
+				return nil, c.errorf(e, "a list mixes %s and %s", elem, t)
+			default:
+				elem = join(elem, t)
+			}
+		}
+		if elem == nil {
+			elem = Dyn
+		}
+		return ListOf(elem), nil
+
+	case *macroNode:
+		return c.macro(n, sc)
+
+	case *callNode:
+		return c.call(n, sc)
+	}
+	return nil, c.errorf(n, "unsupported expression")
+}
+
+// field types a.field for a of type x.
+func (c *checker) field(n *selectNode, x *Type) (*Type, error) {
+	switch x.Kind {
+	case KindObject:
+		t, ok := x.Fields[n.field]
+		if !ok {
+			return nil, c.errorf(n, "no field %q here", n.field)
+		}
+		return t, nil
+	case KindMap:
+		return x.Elem, nil
+	case KindDyn:
+		return Dyn, nil
+	}
+	return nil, c.errorf(n, "%s has no fields, so .%s means nothing", x, n.field)
+}
+
+func (c *checker) binary(n *binaryNode, sc scope) (*Type, error) {
+	l, err := c.check(n.l, sc)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.check(n.r, sc)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "&&", "||":
+		if !fits(l, Bool) || !fits(r, Bool) {
+			return nil, c.errorf(n, "%s needs two bools, not %s and %s", n.op, l, r)
+		}
+		return Bool, nil
+	case "==", "!=":
+		if !comparable(l, r) {
+			return nil, c.errorf(n, "%s compares %s with %s, which is never equal", n.op, l, r)
+		}
+		return Bool, nil
+	case "<", "<=", ">", ">=":
+		if !comparable(l, r) || !ordered(join(l, r)) {
+			return nil, c.errorf(n, "%s orders ints or strings, not %s and %s", n.op, l, r)
+		}
+		return Bool, nil
+	case "in":
+		switch r.Kind {
+		case KindList:
+			if !comparable(l, r.Elem) {
+				return nil, c.errorf(n, "in looks for %s in a list of %s", l, r.Elem)
+			}
+		case KindMap:
+			if !fits(l, String) {
+				return nil, c.errorf(n, "in looks up a map by its string keys, not %s", l)
+			}
+		case KindDyn:
+		default:
+			return nil, c.errorf(n, "in needs a list or a map on its right, not %s", r)
+		}
+		return Bool, nil
+	case "+":
+		if !comparable(l, r) {
+			return nil, c.errorf(n, "+ adds %s to %s", l, r)
+		}
+		t := join(l, r)
+		switch t.Kind {
+		case KindInt, KindString, KindList, KindDyn:
+			return t, nil
+		}
+		return nil, c.errorf(n, "+ adds ints, strings or lists, not %s", t)
+	default: // - * / %
+		if !fits(l, Int) || !fits(r, Int) {
+			return nil, c.errorf(n, "%s needs two ints, not %s and %s", n.op, l, r)
+		}
+		return Int, nil
+	}
+}
+
+func (c *checker) macro(n *macroNode, sc scope) (*Type, error) {
+	recv, err := c.check(n.recv, sc)
+	if err != nil {
+		return nil, err
+	}
+	var elem *Type
+	switch recv.Kind {
+	case KindList:
+		elem = recv.Elem
+	case KindMap:
+		elem = String // a macro over a map ranges over its keys
+	case KindDyn:
+		elem = Dyn
+	default:
+		return nil, c.errorf(n, "%s() ranges over a list or a map, not %s", n.kind, recv)
+	}
+	if _, clash := sc.lookup(n.v); clash {
+		return nil, c.errorf(n, "%s() variable %s hides a variable of the same name", n.kind, n.v)
+	}
+	body, err := c.check(n.body, sc.bind(n.v, elem))
+	if err != nil {
+		return nil, err
+	}
+	if n.kind == "map" {
+		return ListOf(body), nil
+	}
+	if !fits(body, Bool) {
+		return nil, c.errorf(n.body, "%s() needs a bool predicate, not %s", n.kind, body)
+	}
+	if n.kind == "filter" {
+		return ListOf(elem), nil
+	}
+	return Bool, nil
+}
+
+// methods lists each method's receiver, arguments and result.
+var methods = map[string]struct {
+	recv   *Type
+	args   []*Type
+	result *Type
+}{
+	"contains":   {String, []*Type{String}, Bool},
+	"startsWith": {String, []*Type{String}, Bool},
+	"endsWith":   {String, []*Type{String}, Bool},
+	"matches":    {String, []*Type{String}, Bool},
+	"lowerAscii": {String, nil, String},
+	"upperAscii": {String, nil, String},
+}
+
+func (c *checker) call(n *callNode, sc scope) (*Type, error) {
+	args := make([]*Type, len(n.args))
+	for i, a := range n.args {
+		t, err := c.check(a, sc)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = t
+	}
+
+	// size is the one function with both spellings, as in CEL.
+	if n.fn == "size" {
+		x := n.recv
+		if x == nil {
+			if len(n.args) != 1 {
+				return nil, c.errorf(n, "size() takes one argument")
+			}
+			x = n.args[0]
+		} else if len(n.args) != 0 {
+			return nil, c.errorf(n, "size() takes no argument as a method")
+		}
+		t, err := c.check(x, sc)
+		if err != nil {
+			return nil, err
+		}
+		switch t.Kind {
+		case KindString, KindList, KindMap, KindDyn:
+			return Int, nil
+		}
+		return nil, c.errorf(n, "size() measures a string, list or map, not %s", t)
+	}
+
+	m, ok := methods[n.fn]
+	if !ok || n.recv == nil {
+		return nil, c.errorf(n, "unknown function %s()", n.fn)
+	}
+	recv, err := c.check(n.recv, sc)
+	if err != nil {
+		return nil, err
+	}
+	if !fits(recv, m.recv) {
+		return nil, c.errorf(n, "%s() is a method of %s, not %s", n.fn, m.recv, recv)
+	}
+	if len(args) != len(m.args) {
+		return nil, c.errorf(n, "%s() takes %d argument(s), not %d", n.fn, len(m.args), len(args))
+	}
+	for i, want := range m.args {
+		if !fits(args[i], want) {
+			return nil, c.errorf(n.args[i], "%s() needs %s, not %s", n.fn, want, args[i])
+		}
+	}
+
+	// A literal pattern is compiled here, so a bad regex fails with the
+	// rest of the config instead of on the first statement that reaches it.
+	if n.fn == "matches" {
+		if lit, ok := n.args[0].(*litNode); ok {
+			re, err := regexp.Compile(lit.v.(string))
+			if err != nil {
+				return nil, c.errorf(n.args[0], "bad pattern: %v", err)
+			}
+			n.re = &regexpCache{re: re}
+		}
+	}
+	return m.result, nil
+}
+
+// fits reports whether a value of type t may stand where want is required.
+func fits(t, want *Type) bool {
+	return t.Kind == KindDyn || want.Kind == KindDyn || (t.Kind == want.Kind && t.Kind < KindList)
+}
+
+// comparable reports whether == between a and b can ever be true.
+func comparable(a, b *Type) bool {
+	switch {
+	case a.Kind == KindDyn || b.Kind == KindDyn:
+		return true
+	case a.Kind == KindNull || b.Kind == KindNull:
+		// null stands for an absent object, list or map.
+		other := a
+		if a.Kind == KindNull {
+			other = b
+		}
+		return other.Kind >= KindNull
+	case a.Kind != b.Kind:
+		return false
+	case a.Kind == KindList || a.Kind == KindMap:
+		return comparable(a.Elem, b.Elem)
+	}
+	return true
+}
+
+func ordered(t *Type) bool {
+	return t.Kind == KindInt || t.Kind == KindString || t.Kind == KindDyn
+}
+
+// join is the type of a value that is either a or b, given comparable(a, b).
+func join(a, b *Type) *Type {
+	switch {
+	case a.Kind == KindDyn || b.Kind == KindDyn:
+		return Dyn
+	case a.Kind == KindNull:
+		return b
+	}
+	return a
+}
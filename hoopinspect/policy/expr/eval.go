@@ -0,0 +1,559 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// regexpCache holds a matches() pattern, compiled at check time when it was
+// a literal.
+type regexpCache struct{ re *regexp.Regexp }
+
+// dynamicPatterns caches patterns that only arrive at evaluation. Bounded,
+// because the pattern is data and a map keyed on data grows without end.
+var dynamicPatterns = struct {
+	sync.Mutex
+	m map[string]*regexp.Regexp
+}{m: map[string]*regexp.Regexp{}}
+
+const maxDynamicPatterns = 256
+
+func compileDynamic(pattern string) (*regexp.Regexp, error) {
+	dynamicPatterns.Lock()
+	defer dynamicPatterns.Unlock()
+	if re, ok := dynamicPatterns.m[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(dynamicPatterns.m) >= maxDynamicPatterns {
+		clear(dynamicPatterns.m)
+	}
+	dynamicPatterns.m[pattern] = re
+	return re, nil
+}
+
+type evaluator struct {
+	src  string
+	vars Vars
+}
+
+// frame is one macro variable binding, linked to the enclosing ones.
+type frame struct {
+	name string
+	v    any
+	up   *frame
+}
+
+func (f *frame) lookup(name string) (any, bool) {
+	for ; f != nil; f = f.up {
+		if f.name == name {
+			return f.v, true
+		}
+	}
+	return nil, false
+}
+
+func (e *evaluator) errorf(n node, format string, args ...any) error {
+	return errorAt(e.src, n.offset(), format, args...)
+}
+
+func (e *evaluator) eval(n node, f *frame) (any, error) {
+	switch n := n.(type) {
+	case *litNode:
+		return n.v, nil
+
+	case *identNode:
+		if v, ok := f.lookup(n.name); ok {
+			return v, nil
+		}
+		return e.vars.Var(n.name), nil
+
+	case *selectNode:
+		x, err := e.eval(n.x, f)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := x.(map[string]any)
+		if !ok {
+			if x == nil {
+				return nil, e.errorf(n, "no %s: the value it belongs to is absent", n.field)
+			}
+			return nil, e.errorf(n, "%s has no fields", typeName(x))
+		}
+		v, ok := m[n.field]
+		if !ok {
+			return nil, e.errorf(n, "no key %q", n.field)
+		}
+		return v, nil
+
+	case *hasNode:
+		return e.has(n.sel, f), nil
+
+	case *indexNode:
+		x, err := e.eval(n.x, f)
+		if err != nil {
+			return nil, err
+		}
+		i, err := e.eval(n.i, f)
+		if err != nil {
+			return nil, err
+		}
+		switch x := x.(type) {
+		case []any:
+			k, ok := i.(int64)
+			if !ok {
+				return nil, e.errorf(n.i, "a list index must be an int, not %s", typeName(i))
+			}
+			if k < 0 || k >= int64(len(x)) {
+				return nil, e.errorf(n, "index %d is outside a list of %d", k, len(x))
+			}
+			return x[k], nil
+		case map[string]any:
+			k, ok := i.(string)
+			if !ok {
+				return nil, e.errorf(n.i, "a map key must be a string, not %s", typeName(i))
+			}
+			v, ok := x[k]
+			if !ok {
+				return nil, e.errorf(n, "no key %q", k)
+			}
+			return v, nil
+		case nil:
+			return nil, e.errorf(n, "cannot index an absent value")
+		}
+		return nil, e.errorf(n, "cannot index %s", typeName(x))
+
+	case *unaryNode:
+		x, err := e.eval(n.x, f)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "!" {
+			b, ok := x.(bool)
+			if !ok {
+				return nil, e.errorf(n, "! needs a bool, not %s", typeName(x))
+			}
+			return !b, nil
+		}
+		k, ok := x.(int64)
+		if !ok {
+			return nil, e.errorf(n, "- needs an int, not %s", typeName(x))
+		}
+		if k == math.MinInt64 {
+			return nil, e.errorf(n, "integer overflow")
+		}
+		return -k, nil
+
+	case *binaryNode:
+		return e.binary(n, f)
+
+	case *condNode:
+		c, err := e.bool(n.c, f)
+		if err != nil {
+			return nil, err
+		}
+		if c {
+			return e.eval(n.t, f)
+		}
+		return e.eval(n.f, f)
+
+	case *listNode:
+		out := make([]any, 0, len(n.elems))
+		for _, el := range n.elems {
+			v, err := e.eval(el, f)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+
+	case *macroNode:
+		return e.macro(n, f)
+
+	case *callNode:
+		return e.call(n, f)
+	}
+	return nil, e.errorf(n, "unsupported expression")
+}
+
+// has reports whether every step of a field path is present and non-null.
+// It never fails: asking whether something is there is how an expression
+// avoids the error of reading it when it is not.
+func (e *evaluator) has(n *selectNode, f *frame) bool {
+	var x any
+	switch inner := n.x.(type) {
+	case *selectNode:
+		if !e.has(inner, f) {
+			return false
+		}
+		x, _ = e.eval(inner, f)
+	default:
+		var err error
+		if x, err = e.eval(inner, f); err != nil {
+			return false
+		}
+	}
+	m, ok := x.(map[string]any)
+	if !ok {
+		return false
+	}
+	v, ok := m[n.field]
+	return ok && v != nil
+}
+
+func (e *evaluator) bool(n node, f *frame) (bool, error) {
+	v, err := e.eval(n, f)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, e.errorf(n, "expected a bool, not %s", typeName(v))
+	}
+	return b, nil
+}
+
+func (e *evaluator) binary(n *binaryNode, f *frame) (any, error) {
+	// The logical operators short-circuit, so the right side may depend on
+	// the left: has(http) && http.method == "DELETE".
+	if n.op == "&&" || n.op == "||" {
+		l, err := e.bool(n.l, f)
+		if err != nil {
+			return nil, err
+		}
+		if l == (n.op == "||") {
+			return l, nil
+		}
+		return e.bool(n.r, f)
+	}
+
+	l, err := e.eval(n.l, f)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.eval(n.r, f)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		switch r := r.(type) {
+		case []any:
+			for _, el := range r {
+				if equal(l, el) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]any:
+			k, ok := l.(string)
+			if !ok {
+				return nil, e.errorf(n, "in looks up a map by string keys, not %s", typeName(l))
+			}
+			_, ok = r[k]
+			return ok, nil
+		case nil:
+			return false, nil
+		}
+		return nil, e.errorf(n, "in needs a list or a map, not %s", typeName(r))
+	case "<", "<=", ">", ">=":
+		c, err := e.order(n, l, r)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "+":
+		switch l := l.(type) {
+		case string:
+			if r, ok := r.(string); ok {
+				return l + r, nil
+			}
+		case []any:
+			if r, ok := r.([]any); ok {
+				return append(append(make([]any, 0, len(l)+len(r)), l...), r...), nil
+			}
+		case int64:
+			if r, ok := r.(int64); ok {
+				s := l + r
+				if (s > l) != (r > 0) {
+					return nil, e.errorf(n, "integer overflow")
+				}
+				return s, nil
+			}
+		}
+		return nil, e.errorf(n, "+ adds %s to %s", typeName(l), typeName(r))
+	}
+
+	a, aok := l.(int64)
+	b, bok := r.(int64)
+	if !aok || !bok {
+		return nil, e.errorf(n, "%s needs two ints, not %s and %s", n.op, typeName(l), typeName(r))
+	}
+	switch n.op {
+	case "-":
+		d := a - b
+		if (d < a) != (b > 0) {
+			return nil, e.errorf(n, "integer overflow")
+		}
+		return d, nil
+	case "*":
+		if a != 0 && b != 0 {
+			p := a * b
+			if p/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+				return nil, e.errorf(n, "integer overflow")
+			}
+			return p, nil
+		}
+		return int64(0), nil
+	}
+	if b == 0 {
+		return nil, e.errorf(n, "division by zero")
+	}
+	if a == math.MinInt64 && b == -1 {
+		return nil, e.errorf(n, "integer overflow")
+	}
+	if n.op == "/" {
+		return a / b, nil
+	}
+	return a % b, nil
+}
+
+func (e *evaluator) order(n node, l, r any) (int, error) {
+	switch l := l.(type) {
+	case int64:
+		if r, ok := r.(int64); ok {
+			switch {
+			case l < r:
+				return -1, nil
+			case l > r:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case string:
+		if r, ok := r.(string); ok {
+			return strings.Compare(l, r), nil
+		}
+	}
+	return 0, e.errorf(n, "cannot order %s and %s", typeName(l), typeName(r))
+}
+
+func (e *evaluator) macro(n *macroNode, f *frame) (any, error) {
+	recv, err := e.eval(n.recv, f)
+	if err != nil {
+		return nil, err
+	}
+	var items []any
+	switch r := recv.(type) {
+	case []any:
+		items = r
+	case map[string]any:
+		for k := range r {
+			items = append(items, k)
+		}
+	case nil:
+		// An absent list is an empty one. A has() guard in front of every
+		// exists() would be noise, and no element of nothing matches.
+	default:
+		return nil, e.errorf(n, "%s() ranges over a list or a map, not %s", n.kind, typeName(recv))
+	}
+
+	var out []any
+	count := 0
+	for _, it := range items {
+		inner := &frame{name: n.v, v: it, up: f}
+		if n.kind == "map" {
+			v, err := e.eval(n.body, inner)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			continue
+		}
+		ok, err := e.bool(n.body, inner)
+		if err != nil {
+			return nil, err
+		}
+		switch n.kind {
+		case "exists":
+			if ok {
+				return true, nil
+			}
+		case "all":
+			if !ok {
+				return false, nil
+			}
+		case "exists_one":
+			if ok {
+				count++
+			}
+		case "filter":
+			if ok {
+				out = append(out, it)
+			}
+		}
+	}
+	switch n.kind {
+	case "exists":
+		return false, nil
+	case "all":
+		return true, nil
+	case "exists_one":
+		return count == 1, nil
+	}
+	if out == nil {
+		out = []any{}
+	}
+	return out, nil
+}
+
+func (e *evaluator) call(n *callNode, f *frame) (any, error) {
+	if n.fn == "size" {
+		x := n.recv
+		if x == nil {
+			x = n.args[0]
+		}
+		v, err := e.eval(x, f)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case string:
+			return int64(len([]rune(v))), nil
+		case []any:
+			return int64(len(v)), nil
+		case map[string]any:
+			return int64(len(v)), nil
+		case nil:
+			return int64(0), nil
+		}
+		return nil, e.errorf(n, "size() measures a string, list or map, not %s", typeName(v))
+	}
+
+	rv, err := e.eval(n.recv, f)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := rv.(string)
+	if !ok {
+		return nil, e.errorf(n, "%s() is a string method, not one of %s", n.fn, typeName(rv))
+	}
+	switch n.fn {
+	case "lowerAscii":
+		return asciiCase(s, 'A', 'Z', 'a'-'A'), nil
+	case "upperAscii":
+		return asciiCase(s, 'a', 'z', 'A'-'a'), nil
+	}
+
+	av, err := e.eval(n.args[0], f)
+	if err != nil {
+		return nil, err
+	}
+	arg, ok := av.(string)
+	if !ok {
+		return nil, e.errorf(n.args[0], "%s() needs a string, not %s", n.fn, typeName(av))
+	}
+	switch n.fn {
+	case "contains":
+		return strings.Contains(s, arg), nil
+	case "startsWith":
+		return strings.HasPrefix(s, arg), nil
+	case "endsWith":
+		return strings.HasSuffix(s, arg), nil
+	case "matches":
+		var re *regexp.Regexp
+		if n.re != nil {
+			re = n.re.re
+		} else if re, err = compileDynamic(arg); err != nil {
+			return nil, e.errorf(n.args[0], "bad pattern: %v", err)
+		}
+		return re.MatchString(s), nil
+	}
+	return nil, e.errorf(n, "unknown function %s()", n.fn)
+}
+
+// asciiCase shifts the ASCII letters in [lo, hi] by delta and leaves every
+// other byte alone, as CEL's lowerAscii and upperAscii do.
+func asciiCase(s string, lo, hi byte, delta int) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= lo && c <= hi {
+			b[i] = byte(int(c) + delta)
+		}
+	}
+	return string(b)
+}
+
+// equal is deep equality over evaluation values. Values of different types
+// are unequal rather than an error, so a dyn payload compared with the
+// wrong literal reads as "no match".
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case bool, int64, string:
+		return a == b
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := b[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case string:
+		return "string"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,213 @@
+// Package expr is the small expression language behind the policy package's
+// expression rule: boolean predicates over a typed document, compiled and
+// type-checked once and then evaluated in-process per statement.
+//
+// The syntax is a subset of CEL, chosen so that an operator who has written
+// one Kubernetes admission policy can read these without a manual:
+//
+//	operation == "update" && "payments" in tables &&
+//	    identity.groups.exists(g, g == "dba")
+//
+// # Why not OPA
+//
+// OPA remains the answer for policy that needs data, bundles or a decision
+// log. What this covers is the rule one step past the fixed matchers, a verb
+// AND a table AND a group, which today costs a network hop, a second service
+// to run and a fail-open setting to get wrong. An expression here costs
+// microseconds and cannot be unreachable.
+//
+// # Why a type checker
+//
+// A typo in a Rego rule makes the rule undefined, and an undefined deny rule
+// allows everything it was written to stop. Compile rejects an unknown field,
+// an operator applied to the wrong type and an expression that is not a
+// predicate, so the same typo fails at startup with its line and column.
+//
+// # The language
+//
+//	literals    true false null 42 "text" 'text' [1, 2]
+//	operators   ! - * / % + < <= > >= == != in && || ?:
+//	access      a.b  a["b"]  list[0]
+//	presence    has(a.b)   true when every step of the path is present
+//	functions   size(x) x.size() s.contains(t) s.startsWith(t)
+//	            s.endsWith(t) s.matches(re) s.lowerAscii() s.upperAscii()
+//	macros      list.exists(x, p) list.all(x, p) list.exists_one(x, p)
+//	            list.filter(x, p) list.map(x, e)
+//
+// A macro over a map ranges over its keys, as in CEL. Integers are 64-bit
+// and there are no floats: nothing in a statement is fractional.
+//
+// # Errors at evaluation
+//
+// A well-typed expression can still fail: a missing map key, an index past
+// the end, a field of an absent object. Eval returns the error and leaves
+// the verdict to the caller; the policy package fails closed on it, so guard
+// optional parts with has() or `in`.
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind classifies a Type.
+type Kind int
+
+const (
+	// KindDyn is a value whose type is only known at evaluation: a
+	// producer's free-form payload. Anything may be done to it, and a wrong
+	// guess is an evaluation error rather than a compile error.
+	KindDyn Kind = iota
+	KindBool
+	KindInt
+	KindString
+	KindNull
+	KindList
+	KindMap
+	KindObject
+)
+
+// Type is the static type of a value.
+//
+// A Map has string keys and Elem values, and its keys are data: any key may
+// be asked for. An Object has a fixed set of Fields, and asking for one it
+// does not have is a compile error. That distinction is the type checker's
+// whole value, so choose Object for anything with a schema.
+type Type struct {
+	Kind   Kind
+	Elem   *Type
+	Fields map[string]*Type
+}
+
+// The scalar types.
+var (
+	Dyn    = &Type{Kind: KindDyn}
+	Bool   = &Type{Kind: KindBool}
+	Int    = &Type{Kind: KindInt}
+	String = &Type{Kind: KindString}
+	Null   = &Type{Kind: KindNull}
+)
+
+// ListOf is a list of elem.
+func ListOf(elem *Type) *Type { return &Type{Kind: KindList, Elem: elem} }
+
+// MapOf is a map from string to elem.
+func MapOf(elem *Type) *Type { return &Type{Kind: KindMap, Elem: elem} }
+
+// ObjectOf is an object with exactly the given fields.
+func ObjectOf(fields map[string]*Type) *Type { return &Type{Kind: KindObject, Fields: fields} }
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case KindBool:
+		return "bool"
+	case KindInt:
+		return "int"
+	case KindString:
+		return "string"
+	case KindNull:
+		return "null"
+	case KindList:
+		return "list(" + t.Elem.String() + ")"
+	case KindMap:
+		return "map(" + t.Elem.String() + ")"
+	case KindObject:
+		return "object"
+	}
+	return "dyn"
+}
+
+// Env names the variables an expression may read, and their types.
+type Env map[string]*Type
+
+// Vars supplies variable values at evaluation.
+//
+// An interface rather than a map, so a caller can build a value only when an
+// expression reads it: most expressions read two or three variables of a
+// document with a dozen.
+//
+// Values are bool, int64, string, nil, []any and map[string]any, with an
+// Object represented as a map of its fields. A field of an absent Object
+// is nil.
+type Vars interface {
+	Var(name string) any
+}
+
+// VarMap is Vars over a plain map.
+type VarMap map[string]any
+
+// Var implements Vars.
+func (m VarMap) Var(name string) any { return m[name] }
+
+// maxSource bounds an expression's length. A predicate longer than this is
+// a program, and belongs in OPA.
+const maxSource = 4096
+
+// Program is a compiled, type-checked expression.
+type Program struct {
+	src  string
+	root node
+}
+
+// Compile parses src and type-checks it against env. The expression must be
+// a predicate: its type must be bool.
+func Compile(src string, env Env) (*Program, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, fmt.Errorf("expr: empty expression")
+	}
+	if len(src) > maxSource {
+		return nil, fmt.Errorf("expr: expression is %d bytes, over the %d limit", len(src), maxSource)
+	}
+	root, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	c := &checker{src: src}
+	t, err := c.check(root, scope{env: env})
+	if err != nil {
+		return nil, err
+	}
+	if t.Kind != KindBool && t.Kind != KindDyn {
+		return nil, fmt.Errorf("expr: the expression is %s, and a rule needs a bool", t)
+	}
+	return &Program{src: src, root: root}, nil
+}
+
+// String returns the source the program was compiled from.
+func (p *Program) String() string { return p.src }
+
+// Eval evaluates the program. A dyn-typed expression that turns out not to
+// be a bool is an error.
+func (p *Program) Eval(vars Vars) (bool, error) {
+	e := &evaluator{src: p.src, vars: vars}
+	v, err := e.eval(p.root, nil)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expr: the expression produced %s, not a bool", typeName(v))
+	}
+	return b, nil
+}
+
+// position renders a byte offset as line:column for an error message.
+func position(src string, off int) string {
+	line, col := 1, 1
+	for i, r := range src {
+		if i >= off {
+			break
+		}
+		if r == '\n' {
+			line, col = line+1, 1
+			continue
+		}
+		col++
+	}
+	return fmt.Sprintf("%d:%d", line, col)
+}
+
+// errorAt formats an error at a source offset.
+func errorAt(src string, off int, format string, args ...any) error {
+	return fmt.Errorf("expr: %s: %s", position(src, off), fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,120 @@
+package expr_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoopinspect/policy/expr"
+)
+
+var env = expr.Env{
+	"operation": expr.String,
+	"tables":    expr.ListOf(expr.String),
+	"rows":      expr.Int,
+	"context":   expr.MapOf(expr.String),
+	"identity": expr.ObjectOf(map[string]*expr.Type{
+		"principal": expr.String,
+		"groups":    expr.ListOf(expr.String),
+	}),
+	"values": expr.MapOf(expr.Dyn),
+}
+
+var vars = expr.VarMap{
+	"operation": "update",
+	"tables":    []any{"payments", "public.ledger"},
+	"rows":      int64(40),
+	"context":   map[string]any{"app": "psql"},
+	"identity":  map[string]any{"principal": "alice", "groups": []any{"eng", "dba"}},
+	"values":    map[string]any{"entities": []any{"email"}, "count": int64(3)},
+}
+
+func eval(t *testing.T, src string) bool {
+	t.Helper()
+	p, err := expr.Compile(src, env)
+	if err != nil {
+		t.Fatalf("Compile(%q): %v", src, err)
+	}
+	got, err := p.Eval(vars)
+	if err != nil {
+		t.Fatalf("Eval(%q): %v", src, err)
+	}
+	return got
+}
+
+func TestEvaluation(t *testing.T) {
+	for src, want := range map[string]bool{
+		`operation == "update" && "payments" in tables`:           true,
+		`identity.groups.exists(g, g == "dba")`:                   true,
+		`identity.groups.all(g, g.size() == 3)`:                   true,
+		`tables.exists_one(t, t.startsWith("public."))`:           true,
+		`tables.filter(t, t.contains(".")) == ["public.ledger"]`:  true,
+		`tables.map(t, t.upperAscii())[0] == "PAYMENTS"`:          true,
+		`context.exists(k, k == "app")`:                           true,
+		`"ticket" in context`:                                     false,
+		`has(context.app) && !has(context.ticket)`:                true,
+		`rows * 2 + 1 > 80 && rows % 7 == 5 && -rows < 0`:         true,
+		`rows > 10 ? operation == "update" : false`:               true,
+		`identity.principal.matches("^a[a-z]+$")`:                 true,
+		`size(values["entities"]) == 1 && values["count"] >= 3`:   true,
+		`'single' + "double" == "singledouble"`:                   true,
+		`operation == "update" || context["missing"] == "x"`:      true,
+		`operation == "select" && context["missing"] == "x"`:      false,
+		"operation == \"update\"\n\t&& tables[1].endsWith(\"r\")": true,
+	} {
+		if got := eval(t, src); got != want {
+			t.Errorf("%s = %v, want %v", src, got, want)
+		}
+	}
+}
+
+// Every compile error names where it is, because the expression sits in a
+// YAML file and the operator has to find it.
+func TestCompileErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		`identity.group == "dba"`:               `1:10: no field "group" here`,
+		`rows`:                                  "the expression is int",
+		`rows == "40"`:                          "1:6: == compares int with string",
+		`operation.matches("(")`:                "1:19: bad pattern",
+		`tables.exists(operation, true)`:        "hides a variable",
+		`nope == 1`:                             "1:1: undefined variable nope",
+		"operation == \"x\" &&\n  rows + \"1\"": "2:8: + adds int to string",
+		`operation.frobnicate()`:                "unknown function frobnicate()",
+		`"unterminated`:                         "1:1:",
+		`has(rows)`:                             "1:",
+		strings.Repeat("(", 100) + "true" + strings.Repeat(")", 100): "nests deeper",
+	} {
+		_, err := expr.Compile(src, env)
+		if err == nil {
+			t.Errorf("Compile(%q) succeeded, want %q", src, want)
+			continue
+		}
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Compile(%q) = %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestEvaluationErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		`context["ticket"] == "T-1"`: `no key "ticket"`,
+		`tables[5] == "x"`:           "outside a list of 2",
+		`values["count"] == true`:    "",
+		`rows / (rows - 40) > 1`:     "division by zero",
+	} {
+		p, err := expr.Compile(src, env)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", src, err)
+		}
+		_, err = p.Eval(vars)
+		if want == "" {
+			// Comparing dyn values of different types is simply false.
+			if err != nil {
+				t.Errorf("Eval(%q) = %v, want no error", src, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Eval(%q) = %v, want %q", src, err, want)
+		}
+	}
+}
@@ -0,0 +1,521 @@
+package expr
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// --- syntax tree ---------------------------------------------------------
+
+type node interface{ offset() int }
+
+type (
+	// litNode is a literal: bool, int64, string or nil.
+	litNode struct {
+		at int
+		v  any
+	}
+	identNode struct {
+		at   int
+		name string
+	}
+	selectNode struct {
+		at    int
+		x     node
+		field string
+	}
+	indexNode struct {
+		at   int
+		x, i node
+	}
+	unaryNode struct {
+		at int
+		op string
+		x  node
+	}
+	binaryNode struct {
+		at   int
+		op   string
+		l, r node
+	}
+	condNode struct {
+		at      int
+		c, t, f node
+	}
+	listNode struct {
+		at    int
+		elems []node
+	}
+	// callNode is a function: global when recv is nil, otherwise a method.
+	callNode struct {
+		at   int
+		fn   string
+		recv node
+		args []node
+
+		// re is a matches() pattern compiled at check time, when it was a
+		// literal. Nil means compile at evaluation.
+		re *regexpCache
+	}
+	// macroNode binds v to each element of recv in turn and evaluates body.
+	macroNode struct {
+		at   int
+		kind string
+		recv node
+		v    string
+		body node
+	}
+	// hasNode tests that every step of a field path is present.
+	hasNode struct {
+		at  int
+		sel *selectNode
+	}
+)
+
+func (n *litNode) offset() int    { return n.at }
+func (n *identNode) offset() int  { return n.at }
+func (n *selectNode) offset() int { return n.at }
+func (n *indexNode) offset() int  { return n.at }
+func (n *unaryNode) offset() int  { return n.at }
+func (n *binaryNode) offset() int { return n.at }
+func (n *condNode) offset() int   { return n.at }
+func (n *listNode) offset() int   { return n.at }
+func (n *callNode) offset() int   { return n.at }
+func (n *macroNode) offset() int  { return n.at }
+func (n *hasNode) offset() int    { return n.at }
+
+// macros are the methods whose first argument is a variable name rather
+// than a value.
+var macros = map[string]bool{
+	"exists": true, "all": true, "exists_one": true, "filter": true, "map": true,
+}
+
+// --- lexer ---------------------------------------------------------------
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokInt
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind tokKind
+	at   int
+	text string // identifier, punctuation, or the decoded string literal
+	n    int64
+}
+
+// puncts lists the operators, longest first so "<=" wins over "<".
+var puncts = []string{
+	"&&", "||", "==", "!=", "<=", ">=",
+	"(", ")", "[", "]", ".", ",", "?", ":", "!", "-", "+", "*", "/", "%", "<", ">",
+}
+
+func lex(src string) ([]token, error) {
+	var out []token
+	i := 0
+	for i < len(src) {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+			continue
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(src) {
+				r, size := utf8.DecodeRuneInString(src[j:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j += size
+			}
+			out = append(out, token{kind: tokIdent, at: i, text: src[i:j]})
+			i = j
+			continue
+		case r >= '0' && r <= '9':
+			j := i
+			for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+				j++
+			}
+			n, err := strconv.ParseInt(src[i:j], 10, 64)
+			if err != nil {
+				return nil, errorAt(src, i, "integer %s is out of range", src[i:j])
+			}
+			out = append(out, token{kind: tokInt, at: i, n: n})
+			i = j
+			continue
+		case r == '"' || r == '\'':
+			s, end, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, token{kind: tokString, at: i, text: s})
+			i = end
+			continue
+		}
+		matched := false
+		for _, p := range puncts {
+			if strings.HasPrefix(src[i:], p) {
+				out = append(out, token{kind: tokPunct, at: i, text: p})
+				i += len(p)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, errorAt(src, i, "unexpected character %q", r)
+		}
+	}
+	return append(out, token{kind: tokEOF, at: len(src)}), nil
+}
+
+// lexString decodes a quoted literal starting at src[start].
+func lexString(src string, start int) (string, int, error) {
+	quote := src[start]
+	var b strings.Builder
+	for i := start + 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\n':
+			return "", 0, errorAt(src, start, "unterminated string")
+		case c == '\\':
+			if i+1 >= len(src) {
+				return "", 0, errorAt(src, start, "unterminated string")
+			}
+			i++
+			switch src[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '\\', '"', '\'':
+				b.WriteByte(src[i])
+			default:
+				return "", 0, errorAt(src, i-1, "unknown escape \\%c", src[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errorAt(src, start, "unterminated string")
+}
+
+// --- parser --------------------------------------------------------------
+
+// maxDepth bounds nesting, so a pathological expression fails to compile
+// instead of exhausting the stack.
+const maxDepth = 64
+
+type parser struct {
+	src   string
+	toks  []token
+	pos   int
+	depth int
+}
+
+func parse(src string) (node, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, toks: toks}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.unexpected(t)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the punctuation or keyword s when it is next.
+func (p *parser) accept(s string) bool {
+	t := p.peek()
+	if (t.kind == tokPunct || t.kind == tokIdent) && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return errorAt(p.src, p.peek().at, "expected %q, found %s", s, describe(p.peek()))
+	}
+	return nil
+}
+
+func (p *parser) unexpected(t token) error {
+	return errorAt(p.src, t.at, "unexpected %s", describe(t))
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokInt:
+		return strconv.FormatInt(t.n, 10)
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return strconv.Quote(t.text)
+}
+
+// expr is the conditional, the loosest-binding form.
+func (p *parser) expr() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, errorAt(p.src, p.peek().at, "expression nests deeper than %d", maxDepth)
+	}
+
+	c, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	at := p.peek().at
+	if !p.accept("?") {
+		return c, nil
+	}
+	t, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	f, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &condNode{at: at, c: c, t: t, f: f}, nil
+}
+
+// precedence lists the binary operators loosest first.
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">=", "in"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) binary(level int) (node, error) {
+	if level == len(precedence) {
+		return p.unary()
+	}
+	l, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		op := ""
+		for _, o := range precedence[level] {
+			if (t.kind == tokPunct || (t.kind == tokIdent && o == "in")) && t.text == o {
+				op = o
+			}
+		}
+		if op == "" {
+			return l, nil
+		}
+		p.next()
+		r, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{at: t.at, op: op, l: l, r: r}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	t := p.peek()
+	if t.kind == tokPunct && (t.text == "!" || t.text == "-") {
+		p.next()
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxDepth {
+			return nil, errorAt(p.src, t.at, "expression nests deeper than %d", maxDepth)
+		}
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		// Fold a negative literal, so -9223372036854775808 is not an
+		// overflow of its own absolute value.
+		if lit, ok := x.(*litNode); ok && t.text == "-" {
+			if n, ok := lit.v.(int64); ok {
+				return &litNode{at: t.at, v: -n}, nil
+			}
+		}
+		return &unaryNode{at: t.at, op: t.text, x: x}, nil
+	}
+	return p.member()
+}
+
+func (p *parser) member() (node, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case p.accept("."):
+			name := p.next()
+			if name.kind != tokIdent {
+				return nil, errorAt(p.src, name.at, "expected a field or method name, found %s", describe(name))
+			}
+			if !p.accept("(") {
+				x = &selectNode{at: name.at, x: x, field: name.text}
+				continue
+			}
+			if macros[name.text] {
+				x, err = p.macro(name, x)
+			} else {
+				var args []node
+				args, err = p.args(")")
+				x = &callNode{at: name.at, fn: name.text, recv: x, args: args}
+			}
+			if err != nil {
+				return nil, err
+			}
+		case p.accept("["):
+			i, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &indexNode{at: t.at, x: x, i: i}
+		default:
+			return x, nil
+		}
+	}
+}
+
+// macro parses the arguments of recv.kind(v, body) after the "(".
+func (p *parser) macro(name token, recv node) (node, error) {
+	v := p.next()
+	if v.kind != tokIdent || isKeyword(v.text) {
+		return nil, errorAt(p.src, v.at, "%s() takes a variable name first, found %s", name.text, describe(v))
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	body, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &macroNode{at: name.at, kind: name.text, recv: recv, v: v.text, body: body}, nil
+}
+
+// args parses a comma-separated list up to the closing delimiter.
+func (p *parser) args(closing string) ([]node, error) {
+	var out []node
+	if p.accept(closing) {
+		return out, nil
+	}
+	for {
+		a, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+		if p.accept(closing) {
+			return out, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func isKeyword(s string) bool {
+	switch s {
+	case "true", "false", "null", "in":
+		return true
+	}
+	return false
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokInt:
+		return &litNode{at: t.at, v: t.n}, nil
+	case tokString:
+		return &litNode{at: t.at, v: t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &litNode{at: t.at, v: true}, nil
+		case "false":
+			return &litNode{at: t.at, v: false}, nil
+		case "null":
+			return &litNode{at: t.at, v: nil}, nil
+		case "in":
+			return nil, p.unexpected(t)
+		}
+		if !p.accept("(") {
+			return &identNode{at: t.at, name: t.text}, nil
+		}
+		args, err := p.args(")")
+		if err != nil {
+			return nil, err
+		}
+		if t.text == "has" {
+			if len(args) != 1 {
+				return nil, errorAt(p.src, t.at, "has() takes one field path")
+			}
+			sel, ok := args[0].(*selectNode)
+			if !ok {
+				return nil, errorAt(p.src, args[0].offset(), "has() takes a field path like has(http.body)")
+			}
+			return &hasNode{at: t.at, sel: sel}, nil
+		}
+		return &callNode{at: t.at, fn: t.text, args: args}, nil
+	case tokPunct:
+		switch t.text {
+		case "(":
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		case "[":
+			elems, err := p.args("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{at: t.at, elems: elems}, nil
+		}
+	}
+	return nil, p.unexpected(t)
+}
@@ -0,0 +1,306 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/policy/expr"
+)
+
+// MatchExpression denies when Expression, a predicate over the statement's
+// input document, is true. See package expr for the language.
+//
+// It reads the document an OPA policy receives, under the same names, so a
+// rule can move between the two without being relearned: `input.tables`
+// in Rego is `tables` here, `input.findings.pii` is `findings.pii`. The
+// expression is compiled and type-checked when the rule set is built, so a
+// misspelled field fails the config rather than leaving a rule that never
+// fires.
+//
+// An expression that fails at evaluation, reading a map key that is not
+// there, is handled like any rule that cannot be evaluated: it denies,
+// unless the rule set is FailOpen.
+const MatchExpression MatchType = "expression"
+
+// expressionEnv is the input document's schema, for the type checker.
+// Object fields mirror opaInput's JSON names exactly.
+var expressionEnv = func() expr.Env {
+	strs := expr.ListOf(expr.String)
+	finding := expr.ObjectOf(map[string]*expr.Type{
+		"rule":   expr.String,
+		"status": expr.String,
+		"reason": expr.String,
+		"values": expr.MapOf(expr.Dyn),
+	})
+	return expr.Env{
+		"protocol":  expr.String,
+		"direction": expr.String,
+		"statement": expr.String,
+		"operation": expr.String,
+		"tables":    strs,
+		"effects":   strs,
+		"relations": expr.ListOf(expr.ObjectOf(map[string]*expr.Type{
+			"name":   expr.String,
+			"access": expr.String,
+			"columns": expr.ListOf(expr.ObjectOf(map[string]*expr.Type{
+				"name": expr.String,
+				"uses": strs,
+			})),
+		})),
+		"unbounded_writes": expr.ListOf(expr.ObjectOf(map[string]*expr.Type{
+			"operation": expr.String,
+			"relations": strs,
+		})),
+		"database": expr.String,
+		"http": expr.ObjectOf(map[string]*expr.Type{
+			"method":       expr.String,
+			"path":         expr.String,
+			"query":        expr.MapOf(strs),
+			"host":         expr.String,
+			"resource":     expr.String,
+			"status_code":  expr.Int,
+			"content_type": expr.String,
+			"headers":      expr.MapOf(expr.String),
+			"body":         expr.String,
+		}),
+		"result": expr.ObjectOf(map[string]*expr.Type{
+			"columns": expr.ListOf(expr.ObjectOf(map[string]*expr.Type{
+				"name":          expr.String,
+				"data_type_oid": expr.Int,
+			})),
+			"row_count": expr.Int,
+			"truncated": expr.Bool,
+		}),
+		"metadata": expr.MapOf(expr.String),
+		"context":  expr.MapOf(expr.String),
+		"identity": expr.ObjectOf(map[string]*expr.Type{
+			"principal": expr.String,
+			"subject":   expr.String,
+			"email":     expr.String,
+			"groups":    strs,
+			"peer_addr": expr.String,
+		}),
+		"phase":    expr.String,
+		"findings": expr.MapOf(finding),
+	}
+}()
+
+// validateExpression compiles the rule's expression, keeping the program.
+func (r *Rule) validateExpression() error {
+	if r.Expression == "" {
+		return fmt.Errorf("%s: expression rule with no expression", r.Name)
+	}
+	p, err := expr.Compile(r.Expression, expressionEnv)
+	if err != nil {
+		return fmt.Errorf("%s: %v", r.Name, err)
+	}
+	r.program = p
+	return nil
+}
+
+// matchesExpression evaluates the rule against the statement's document.
+//
+// findings are what the producers established so far, the earlier rules of
+// this set included, so an expression can rule on a deferring pii rule that
+// sits above it.
+func (r Rule) matchesExpression(stmt hoopinspect.Statement, ec *EvalContext, findings map[string]Finding) (bool, error) {
+	var ctx map[string]string
+	if ec != nil {
+		ctx = ec.Context
+	}
+	in := newInput(stmt, ctx, "", findings)
+	return r.program.Eval(documentVars{&in})
+}
+
+// findingsSoFar merges the findings already on ec with the ones this rule
+// set has deferred but not yet flushed.
+func findingsSoFar(ec *EvalContext, deferred map[MatchType]Finding) map[string]Finding {
+	var base map[string]Finding
+	if ec != nil {
+		base = ec.Findings
+	}
+	if len(deferred) == 0 {
+		return base
+	}
+	out := make(map[string]Finding, len(base)+len(deferred))
+	for k, f := range base {
+		out[k] = f
+	}
+	for _, f := range deferred {
+		if prev, ok := out[f.Source]; ok {
+			f = prev.Merge(f)
+		}
+		out[f.Source] = f
+	}
+	return out
+}
+
+// documentVars presents an input document to an expression, converting a
+// field only when the expression reads it.
+type documentVars struct{ in *opaInput }
+
+func (d documentVars) Var(name string) any {
+	in := d.in
+	switch name {
+	case "protocol":
+		return in.Protocol
+	case "direction":
+		return in.Direction
+	case "statement":
+		return in.Statement
+	case "operation":
+		return in.Operation
+	case "database":
+		return in.Database
+	case "phase":
+		return in.Phase
+	case "tables":
+		return stringList(in.Tables)
+	case "effects":
+		out := make([]any, len(in.Effects))
+		for i, e := range in.Effects {
+			out[i] = string(e)
+		}
+		return out
+	case "relations":
+		out := make([]any, len(in.Relations))
+		for i, r := range in.Relations {
+			cols := make([]any, len(r.Columns))
+			for j, c := range r.Columns {
+				uses := make([]any, len(c.Uses))
+				for k, u := range c.Uses {
+					uses[k] = string(u)
+				}
+				cols[j] = map[string]any{"name": c.Name, "uses": uses}
+			}
+			out[i] = map[string]any{"name": r.Name, "access": string(r.Access), "columns": cols}
+		}
+		return out
+	case "unbounded_writes":
+		out := make([]any, len(in.UnboundedWrites))
+		for i, w := range in.UnboundedWrites {
+			out[i] = map[string]any{"operation": string(w.Operation), "relations": stringList(w.Relations)}
+		}
+		return out
+	case "http":
+		h := in.HTTP
+		if h == nil {
+			return nil
+		}
+		query := make(map[string]any, len(h.Query))
+		for k, v := range h.Query {
+			query[k] = stringList(v)
+		}
+		return map[string]any{
+			"method":       h.Method,
+			"path":         h.Path,
+			"query":        query,
+			"host":         h.Host,
+			"resource":     h.Resource,
+			"status_code":  int64(h.StatusCode),
+			"content_type": h.ContentType,
+			"headers":      stringMap(h.Headers),
+			"body":         h.Body,
+		}
+	case "result":
+		res := in.Result
+		if res == nil {
+			return nil
+		}
+		cols := make([]any, len(res.Columns))
+		for i, c := range res.Columns {
+			cols[i] = map[string]any{"name": c.Name, "data_type_oid": int64(c.DataTypeOID)}
+		}
+		return map[string]any{"columns": cols, "row_count": int64(res.RowCount), "truncated": res.Truncated}
+	case "metadata":
+		return stringMap(in.Metadata)
+	case "context":
+		return stringMap(in.Context)
+	case "identity":
+		// An anonymous session reads as an identity with nothing in it
+		// rather than an absent one, so `identity.groups.exists(...)` is
+		// false for it instead of an error that denies.
+		id := in.Identity
+		if id == nil {
+			id = &inputIdentity{}
+		}
+		return map[string]any{
+			"principal": id.Principal,
+			"subject":   id.Subject,
+			"email":     id.Email,
+			"groups":    stringList(id.Groups),
+			"peer_addr": id.PeerAddr,
+		}
+	case "findings":
+		out := make(map[string]any, len(in.Findings))
+		for k, f := range in.Findings {
+			values := make(map[string]any, len(f.Values))
+			for vk, vv := range f.Values {
+				values[vk] = dynValue(vv)
+			}
+			out[k] = map[string]any{"rule": f.Rule, "status": f.Status, "reason": f.Reason, "values": values}
+		}
+		return out
+	}
+	return nil
+}
+
+func stringList(in []string) []any {
+	out := make([]any, len(in))
+	for i, s := range in {
+		out[i] = s
+	}
+	return out
+}
+
+func stringMap(in map[string]string) map[string]any {
+	out := make(map[string]any, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+// dynValue converts a producer's free-form value into the expression
+// language's representation. A value of a type this does not name goes
+// through JSON, which is what OPA would have been sent.
+func dynValue(v any) any {
+	switch v := v.(type) {
+	case nil, bool, string, int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+		return v
+	case []string:
+		return stringList(v)
+	case map[string]string:
+		return stringMap(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = dynValue(e)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = dynValue(e)
+		}
+		return out
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var generic any
+	if json.Unmarshal(b, &generic) != nil {
+		return nil
+	}
+	return dynValue(generic)
+}
@@ -0,0 +1,140 @@
+package policy_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/policy"
+)
+
+func expressionRules(t *testing.T, rules ...policy.Rule) *policy.Rules {
+	t.Helper()
+	rs, err := policy.NewRules(rules)
+	if err != nil {
+		t.Fatalf("NewRules: %v", err)
+	}
+	return rs
+}
+
+func member(groups string) *policy.EvalContext {
+	return &policy.EvalContext{Context: map[string]string{
+		"principal": "alice", "groups": groups,
+	}}
+}
+
+func TestExpressionReadsStatementAndIdentity(t *testing.T) {
+	rules := expressionRules(t, policy.Rule{
+		Name:       "payments-updates-by-dba-only",
+		Type:       policy.MatchExpression,
+		Expression: `operation == "update" && "payments" in tables && !identity.groups.exists(g, g == "dba")`,
+		Message:    "only a DBA updates payments",
+	})
+	update := stmt("UPDATE payments SET x = 1 WHERE id = 2", hoopinspect.OpUpdate, "payments")
+
+	v := rules.EvaluateWith(update, member("eng,oncall"))
+	if !v.Denied || v.Rule != "payments-updates-by-dba-only" || v.Message != "only a DBA updates payments" {
+		t.Errorf("non-DBA update: verdict = %+v, want the rule's denial", v)
+	}
+	if v := rules.EvaluateWith(update, member("eng,dba")); v.Denied {
+		t.Errorf("DBA update denied: %+v", v)
+	}
+	select1 := stmt("SELECT * FROM payments", hoopinspect.OpSelect, "payments")
+	if v := rules.EvaluateWith(select1, member("eng")); v.Denied {
+		t.Errorf("select denied: %+v", v)
+	}
+
+	// An anonymous session has no groups, which is an answer, not an error.
+	v = rules.EvaluateWith(update, &policy.EvalContext{})
+	if !v.Denied || v.Err != nil {
+		t.Errorf("anonymous update: verdict = %+v, want a clean denial", v)
+	}
+}
+
+// An expression that cannot be evaluated is a rule that cannot be
+// evaluated: it denies with the diagnostic unless the set fails open.
+func TestExpressionEvaluationErrorFailsClosed(t *testing.T) {
+	rule := policy.Rule{
+		Name:       "ticketed",
+		Type:       policy.MatchExpression,
+		Expression: `context["ticket"] == ""`,
+	}
+	s := stmt("DELETE FROM t WHERE id = 1", hoopinspect.OpDelete, "t")
+
+	v := expressionRules(t, rule).EvaluateWith(s, &policy.EvalContext{})
+	if !v.Denied || v.Err == nil || v.Rule != "ticketed" {
+		t.Errorf("verdict = %+v, want a fail-closed denial carrying the error", v)
+	}
+	open := expressionRules(t, rule)
+	open.FailOpen = true
+	if v := open.EvaluateWith(s, &policy.EvalContext{}); v.Denied || v.Err == nil {
+		t.Errorf("fail-open verdict = %+v, want allowed with the error", v)
+	}
+
+	guarded := rule
+	guarded.Expression = `!("ticket" in context) || context["ticket"] == ""`
+	if v := expressionRules(t, guarded).EvaluateWith(s, &policy.EvalContext{}); !v.Denied || v.Err != nil {
+		t.Errorf("guarded verdict = %+v, want a clean denial", v)
+	}
+}
+
+// A deferring rule above an expression is visible to it, so the two compose
+// the way a deferring rule and an OPA policy do.
+func TestExpressionSeesEarlierDeferredFindings(t *testing.T) {
+	rules := expressionRules(t,
+		policy.Rule{Name: "ddl", Type: policy.MatchDenyWords, Words: []string{"drop"}, Action: policy.ActionDefer},
+		policy.Rule{
+			Name:       "ddl-outside-migrations",
+			Type:       policy.MatchExpression,
+			Expression: `"deny_words_list" in findings && findings.deny_words_list.values["words"].exists(w, w == "drop") && context.app != "migrate"`,
+		},
+	)
+	drop := stmt("DROP TABLE t", hoopinspect.OpDrop, "t")
+
+	ec := &policy.EvalContext{Context: map[string]string{"app": "psql"}}
+	if v := rules.EvaluateWith(drop, ec); !v.Denied || v.Rule != "ddl-outside-migrations" {
+		t.Errorf("verdict = %+v, want the expression's denial", v)
+	}
+	if _, ok := ec.Findings["deny_words_list"]; !ok {
+		t.Error("the deferred finding was not flushed onto the context")
+	}
+	ec = &policy.EvalContext{Context: map[string]string{"app": "migrate"}}
+	if v := rules.EvaluateWith(drop, ec); v.Denied {
+		t.Errorf("migration denied: %+v", v)
+	}
+}
+
+func TestExpressionDefersLikeAnyRule(t *testing.T) {
+	rules := expressionRules(t, policy.Rule{
+		Name: "wide", Type: policy.MatchExpression, Action: policy.ActionDefer,
+		Expression: `size(tables) > 2`,
+	})
+	ec := &policy.EvalContext{}
+	if v := rules.EvaluateWith(stmt("SELECT 1", hoopinspect.OpSelect, "a", "b", "c"), ec); v.Denied {
+		t.Fatalf("deferring rule denied: %+v", v)
+	}
+	f, ok := ec.Findings["expression"]
+	if !ok || f.Rule != "wide" {
+		t.Errorf("findings = %+v, want an expression finding from wide", ec.Findings)
+	}
+}
+
+func TestBadExpressionFailsValidation(t *testing.T) {
+	for expression, want := range map[string]string{
+		``:                          "expression rule with no expression",
+		`operation == "update" &&`:  "1:",
+		`identity.group == "dba"`:   `no field "group"`,
+		`size(tables)`:              "needs a bool",
+		`statement.matches("a(")`:   "bad pattern",
+		`result.row_count > "many"`: "orders ints or strings",
+	} {
+		_, err := policy.NewRules([]policy.Rule{{Name: "bad", Type: policy.MatchExpression, Expression: expression}})
+		if err == nil {
+			t.Errorf("%q: NewRules accepted it", expression)
+			continue
+		}
+		if !strings.Contains(err.Error(), want) || !strings.Contains(err.Error(), "bad:") {
+			t.Errorf("%q: error = %v, want one naming the rule and %q", expression, err, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Limit actions: what happens to a result set once it crosses a ResultLimit.
+const (
+	// LimitDeny replaces the result with the protocol's error frame and ends
+	// the connection, the same way a denied response statement does. It is
+	// the default, because a client that silently receives fewer rows than
+	// it asked for can draw the wrong conclusion from them.
+	LimitDeny = "deny"
+
+	// LimitTruncate drops every row past the limit, at a row boundary, and
+	// lets the result set end normally. The client sees a shorter result;
+	// the audit trail records that it was cut.
+	LimitTruncate = "truncate"
+)
+
+// ResultLimit caps how much of one result set may leave the server.
+//
+// It is not a Rule, because it cannot be decided the way a rule is. A codec
+// describes a result set in one Statement when the set ENDS, and by then every
+// row has already been forwarded: a rule evaluated on that statement would be
+// refusing a table after handing it over. A limit is instead enforced row by
+// row while the result streams, by a codec that can drop rows without
+// desynchronizing the client (gate.RowLimiter).
+//
+// Limits are per result set. A client that pages through a table with
+// LIMIT/OFFSET or a cursor is issuing many small queries, each under the
+// limit; bounding the session's total is a rate question and belongs
+// elsewhere.
+type ResultLimit struct {
+	// Name identifies the limit in audit output and in Decision.Rule.
+	Name string `json:"name"`
+
+	// MaxRows is the most rows one result set may return. Zero means no row
+	// limit.
+	MaxRows int `json:"max_rows,omitempty"`
+
+	// MaxResultBytes is the most row data, counted in wire bytes, one result
+	// set may return. Zero means no byte limit.
+	//
+	// It exists alongside MaxRows because a row count says nothing about a
+	// table of documents: ten rows of a JSON column can be a gigabyte.
+	MaxResultBytes int64 `json:"max_result_bytes,omitempty"`
+
+	// Action is LimitDeny or LimitTruncate. Empty denies.
+	Action string `json:"action,omitempty"`
+
+	// Principals and Groups narrow the limit to some identities. A session
+	// matches when its subject or email is in Principals, or any of its
+	// groups is in Groups. Both empty applies the limit to everyone.
+	//
+	// This is how a lane gives a reporting service account a generous cap
+	// and every human a tight one: list the tight limit first with the
+	// humans' group, the generous one after it for everyone.
+	Principals []string `json:"principals,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+
+	// Message reaches the user on denial. Leave it empty and a generated
+	// message naming the limit is used instead.
+	Message string `json:"message,omitempty"`
+}
+
+// ValidateLimits checks a limit list at startup, reporting every problem.
+func ValidateLimits(limits []ResultLimit) error {
+	var problems []string
+	for i, l := range limits {
+		name := l.Name
+		if name == "" {
+			name = fmt.Sprintf("limit[%d]", i)
+		}
+		if l.MaxRows < 0 || l.MaxResultBytes < 0 {
+			problems = append(problems, name+": max_rows and max_result_bytes cannot be negative")
+		}
+		if l.MaxRows == 0 && l.MaxResultBytes == 0 {
+			problems = append(problems, name+": limit sets neither max_rows nor max_result_bytes")
+		}
+		switch l.Action {
+		case "", LimitDeny, LimitTruncate:
+		default:
+			problems = append(problems, fmt.Sprintf(
+				"%s: unknown action %q (%s or %s; empty denies)",
+				name, l.Action, LimitDeny, LimitTruncate))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("policy: invalid limits: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// AppliesTo reports whether the limit covers an identity.
+//
+// subject and email are compared exactly. An identity provider that spells
+// one principal two ways is the provider's problem to normalize, and a
+// case-folded match here would let "Ops@" match a limit written for "ops@".
+func (l ResultLimit) AppliesTo(subject, email string, groups []string) bool {
+	if len(l.Principals) == 0 && len(l.Groups) == 0 {
+		return true
+	}
+	for _, p := range l.Principals {
+		if p != "" && (p == subject || p == email) {
+			return true
+		}
+	}
+	for _, g := range groups {
+		if slices.Contains(l.Groups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// Exceeded reports whether a result set that has reached rows rows and bytes
+// bytes, counting the row just read, is over the limit.
+func (l ResultLimit) Exceeded(rows int, bytes int64) bool {
+	return (l.MaxRows > 0 && rows > l.MaxRows) ||
+		(l.MaxResultBytes > 0 && bytes > l.MaxResultBytes)
+}
+
+// DenyMessage is the text a user reads when the limit denies a result.
+func (l ResultLimit) DenyMessage() string {
+	if l.Message != "" {
+		return l.Message
+	}
+	return fmt.Sprintf("result refused by limit %q: it returns more data than this connection may read", l.Name)
+}
+
+// Truncates reports whether the limit cuts the result rather than denying it.
+func (l ResultLimit) Truncates() bool { return l.Action == LimitTruncate }
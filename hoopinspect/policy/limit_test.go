@@ -0,0 +1,72 @@
+package policy_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoopinspect/policy"
+)
+
+func TestValidateLimits(t *testing.T) {
+	if err := policy.ValidateLimits([]policy.ResultLimit{
+		{Name: "rows", MaxRows: 1000},
+		{Name: "bytes", MaxResultBytes: 1 << 20, Action: policy.LimitTruncate},
+	}); err != nil {
+		t.Fatalf("valid limits refused: %v", err)
+	}
+
+	err := policy.ValidateLimits([]policy.ResultLimit{
+		{Name: "empty"},
+		{Name: "negative", MaxRows: -1},
+		{Name: "odd", MaxRows: 1, Action: "warn"},
+	})
+	if err == nil {
+		t.Fatal("invalid limits accepted")
+	}
+	for _, want := range []string{"empty: limit sets neither", "negative:", `odd: unknown action "warn"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestResultLimitAppliesTo(t *testing.T) {
+	everyone := policy.ResultLimit{MaxRows: 1}
+	if !everyone.AppliesTo("", "", nil) {
+		t.Error("a limit with no selectors must apply to everyone")
+	}
+
+	l := policy.ResultLimit{MaxRows: 1, Principals: []string{"ops@example.com"}, Groups: []string{"contractors"}}
+	for _, tc := range []struct {
+		subject, email string
+		groups         []string
+		want           bool
+	}{
+		{"ops@example.com", "", nil, true},
+		{"u-123", "ops@example.com", nil, true},
+		{"u-456", "", []string{"eng", "contractors"}, true},
+		{"Ops@example.com", "", nil, false},
+		{"u-789", "", []string{"eng"}, false},
+	} {
+		if got := l.AppliesTo(tc.subject, tc.email, tc.groups); got != tc.want {
+			t.Errorf("AppliesTo(%q, %q, %v) = %v, want %v", tc.subject, tc.email, tc.groups, got, tc.want)
+		}
+	}
+}
+
+func TestResultLimitExceeded(t *testing.T) {
+	l := policy.ResultLimit{MaxRows: 10, MaxResultBytes: 100}
+	for _, tc := range []struct {
+		rows  int
+		bytes int64
+		want  bool
+	}{
+		{10, 100, false},
+		{11, 50, true},
+		{5, 101, true},
+	} {
+		if got := l.Exceeded(tc.rows, tc.bytes); got != tc.want {
+			t.Errorf("Exceeded(%d, %d) = %v, want %v", tc.rows, tc.bytes, got, tc.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/hoophq/hoopinspect"
@@ -75,6 +76,11 @@ type OPAClient struct {
 	// client reads it once per evaluation, so put per-connection facts here
 	// and per-statement facts in the Statement itself.
 	Context map[string]string
+
+	// Observe, when set, is called once per decision with how long it took
+	// and the error that made it a failure, nil otherwise. It feeds a
+	// metrics endpoint; the client keeps no counters of its own.
+	Observe func(elapsed time.Duration, err error)
 }
 
 // Phase names which of a two-phase lane's OPA calls this is. It reaches Rego
@@ -127,6 +133,21 @@ type opaInput struct {
 	Metadata  map[string]string       `json:"metadata,omitempty"`
 	Context   map[string]string       `json:"context,omitempty"`
 
+	// UnboundedWrites lists each UPDATE and DELETE that no predicate
+	// limits. A Rego rule refusing them reads it the way the local
+	// unbounded_write rule does, and must also refuse an `unknown`
+	// operation, where the scan could not tell.
+	UnboundedWrites []hoopinspect.UnboundedWrite `json:"unbounded_writes,omitempty"`
+
+	// Result describes a response: its columns and row count. Absent on a
+	// request, and on a response whose codec reports no result set.
+	Result *hoopinspect.ResultDetail `json:"result,omitempty"`
+
+	// Identity is the actor, typed: Context carries the same facts as flat
+	// strings, and a group list joined with commas is a string a policy
+	// has to split before it can ask about one group.
+	Identity *inputIdentity `json:"identity,omitempty"`
+
 	// Phase is "gate", "decide", or absent on a single-call lane.
 	Phase string `json:"phase,omitempty"`
 
@@ -139,6 +160,57 @@ type opaInput struct {
 	Findings map[string]Finding `json:"findings,omitempty"`
 }
 
+// inputIdentity is input.identity.
+type inputIdentity struct {
+	Principal string   `json:"principal"`
+	Subject   string   `json:"subject,omitempty"`
+	Email     string   `json:"email,omitempty"`
+	Groups    []string `json:"groups,omitempty"`
+	PeerAddr  string   `json:"peer_addr,omitempty"`
+}
+
+// identityOf lifts the actor out of a policy context, or nil when the
+// context names none.
+func identityOf(ctx map[string]string) *inputIdentity {
+	if ctx["principal"] == "" && ctx["subject"] == "" {
+		return nil
+	}
+	id := &inputIdentity{
+		Principal: ctx["principal"],
+		Subject:   ctx["subject"],
+		Email:     ctx["email"],
+		PeerAddr:  ctx["peer_addr"],
+	}
+	if g := ctx["groups"]; g != "" {
+		id.Groups = strings.Split(g, ",")
+	}
+	return id
+}
+
+// newInput builds the input document for one statement. The expression rule
+// reads the same document, so the two evaluators cannot drift apart on what
+// a field is called or what it holds.
+func newInput(stmt hoopinspect.Statement, ctx map[string]string, phase Phase, findings map[string]Finding) opaInput {
+	return opaInput{
+		Protocol:        string(stmt.Protocol),
+		Direction:       string(stmt.Direction),
+		Statement:       stmt.Text,
+		Operation:       string(stmt.Operation),
+		Tables:          stmt.Tables,
+		Effects:         stmt.Effects,
+		Relations:       stmt.Relations,
+		UnboundedWrites: stmt.UnboundedWrites,
+		Database:        stmt.Database,
+		HTTP:            stmt.HTTP,
+		Metadata:        stmt.Metadata,
+		Context:         ctx,
+		Result:          stmt.Result,
+		Identity:        identityOf(ctx),
+		Phase:           string(phase),
+		Findings:        findings,
+	}
+}
+
 // opaResponse models OPA's Data API reply. Result is json.RawMessage,
 // deliberately, so both an object and a bare boolean decode.
 type opaResponse struct {
@@ -224,21 +296,19 @@ func (c *OPAClient) contextFor(ec *EvalContext) map[string]string {
 }
 
 func (c *OPAClient) evaluate(ctx context.Context, stmt hoopinspect.Statement, ec *EvalContext) Verdict {
-	body, err := json.Marshal(opaRequest{Input: opaInput{
-		Protocol:  string(stmt.Protocol),
-		Direction: string(stmt.Direction),
-		Statement: stmt.Text,
-		Operation: string(stmt.Operation),
-		Tables:    stmt.Tables,
-		Effects:   stmt.Effects,
-		Relations: stmt.Relations,
-		Database:  stmt.Database,
-		HTTP:      stmt.HTTP,
-		Metadata:  stmt.Metadata,
-		Context:   c.contextFor(ec),
-		Phase:     string(c.Phase),
-		Findings:  c.findingsFor(ec),
-	}})
+	if c.Observe == nil {
+		return c.decide(ctx, stmt, ec)
+	}
+	start := time.Now()
+	v := c.decide(ctx, stmt, ec)
+	c.Observe(time.Since(start), v.Err)
+	return v
+}
+
+func (c *OPAClient) decide(ctx context.Context, stmt hoopinspect.Statement, ec *EvalContext) Verdict {
+	body, err := json.Marshal(opaRequest{
+		Input: newInput(stmt, c.contextFor(ec), c.Phase, c.findingsFor(ec)),
+	})
 	if err != nil {
 		return c.failure(fmt.Errorf("policy/opa: encoding input: %w", err))
 	}
@@ -3,9 +3,10 @@
 // Two evaluators ship here, and they layer:
 //
 //   - Rules: a local, dependency-free matcher (deny-words, regex, operation
-//     and table allow/deny lists). Microseconds, no network, so it is safe on
-//     the data path. Use it for the coarse "never, under any circumstances"
-//     rules.
+//     and table allow/deny lists, per-identity rate limits, typed
+//     expressions). Microseconds, no network, so it is safe on the data
+//     path. Use it for the coarse "never, under any circumstances" rules,
+//     and for the simple combinations that do not justify a Rego bundle.
 //   - OPA: a client for Open Policy Agent's Data API. Use it for policy an
 //     InfoSec team already owns in Rego.
 //
@@ -33,6 +34,7 @@ import (
 	"strings"
 
 	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/policy/expr"
 )
 
 // Verdict is the outcome of evaluating one statement.
@@ -47,9 +49,17 @@ type Verdict struct {
 	Message string
 
 	// Rule identifies which rule produced a denial, for audit correlation.
-	// Empty on allow.
+	// Empty on allow, except an allow an approver granted, which names the
+	// rule that asked.
 	Rule string
 
+	// Approval marks a denial a person may lift: a rule with ActionApprove
+	// matched, and nothing refused the statement outright. Denied is true
+	// as well, so a caller that does not hold statements for approval
+	// refuses them, which is the fail-closed reading. The gate asks its
+	// Approver and replaces the verdict with the answer; see AwaitApproval.
+	Approval bool
+
 	// Err holds the failure when evaluation itself broke (OPA unreachable,
 	// bad regex). Denied reflects the fail-open/fail-closed choice; Err
 	// records the cause.
@@ -329,6 +339,19 @@ const (
 	// be extracted: "we could not tell" reads as unsafe.
 	MatchTable MatchType = "table"
 
+	// MatchUnboundedWrite denies an UPDATE or DELETE that no predicate
+	// limits: no WHERE, a WHERE naming only constants (`WHERE 1=1`), or a
+	// LIMIT standing in for one. Tables, when set, narrows it to those
+	// targets.
+	//
+	// It fails closed. A statement the scanner could not read
+	// (Metadata["sql.incomplete"]) may hide exactly the write this rule
+	// exists to catch, so it matches too. A MERGE, an INSERT ... ON
+	// CONFLICT and a TRUNCATE are out of scope: the first two choose
+	// their rows by a join condition, and the last is an operation rule's
+	// job.
+	MatchUnboundedWrite MatchType = "unbounded_write"
+
 	// MatchAIAnalysis sends the statement to a language model and denies
 	// according to the risk it reports.
 	//
@@ -385,6 +408,8 @@ type Rule struct {
 
 	// Tables for MatchTable, compared lowercased. A bare name matches any
 	// schema qualification: "customers" matches "public.customers".
+	//
+	// Optional on MatchUnboundedWrite, where empty guards every table.
 	Tables []string `json:"tables,omitempty"`
 
 	// RequireTableMatch makes a MatchTable rule also deny statements whose
@@ -402,6 +427,18 @@ type Rule struct {
 	// nothing.
 	Access string `json:"access,omitempty"`
 
+	// Columns for MatchColumn: "column", "table.column" or
+	// "schema.table.column", compared lowercased. A bare column is
+	// protected on every relation; a table matches any schema
+	// qualification, as in Tables. Access narrows it as it does a table
+	// rule, with read covering a column a predicate only filters on.
+	Columns []string `json:"columns,omitempty"`
+
+	// OnIncomplete is what a MatchColumn rule does with a statement whose
+	// columns the scan could not determine: "deny", the default, or
+	// "response" to let it run and check the result set's column names.
+	OnIncomplete string `json:"on_incomplete,omitempty"`
+
 	// Message reaches the user on denial. Leave it empty and the rule falls
 	// back to a generated message naming only the rule and the operation;
 	// set it.
@@ -429,6 +466,10 @@ type Rule struct {
 	// Entities for MatchPII, naming the classes a Scanner must not find.
 	Entities []string `json:"entities,omitempty"`
 
+	// Rate is a MatchRate rule's allowance. Operations, when set, narrows
+	// which statements it counts.
+	Rate *RateSpec `json:"rate,omitempty"`
+
 	// Trigger narrows which statements a MatchAIAnalysis rule classifies.
 	// A rule with an empty trigger classifies nothing, because the failure
 	// mode of "matches everything by accident" is a bill rather than an
@@ -460,6 +501,9 @@ type Rule struct {
 	// audit trail.
 	Prompt string `json:"prompt,omitempty"`
 
+	// Expression for MatchExpression, a predicate over the input document.
+	Expression string `json:"expression,omitempty"`
+
 	// HTTP-specific fields (Resources, Statuses, Fields, MaxDepth, ...).
 	// Embedded so one ordered rule set can mix SQL and HTTP matchers; a
 	// deployment fronting both a database and an API should not need two
@@ -468,6 +512,9 @@ type Rule struct {
 
 	// compiled caches the Pattern regex.
 	compiled *regexp.Regexp
+
+	// program caches the compiled Expression.
+	program *expr.Program
 }
 
 // Rules is an ordered set of local rules. The first match wins, so order
@@ -479,6 +526,11 @@ type Rules struct {
 	// it, and NewRules rejects PII rules while it is nil.
 	scanner Scanner
 
+	// rates backs MatchRate rules, counted under lane. Private to this set
+	// unless ShareRates replaced it.
+	rates *RateCounters
+	lane  string
+
 	// FailOpen inverts the error behavior: when a rule cannot be evaluated
 	// (an invalid regex), allow instead of deny. Default false.
 	FailOpen bool
@@ -501,17 +553,17 @@ func newRules(rules []Rule, hasScanner bool) (*Rules, error) {
 			r.Name = fmt.Sprintf("rule[%d]", i)
 		}
 		switch r.Action {
-		case "", ActionDefer:
+		case "", ActionDefer, ActionApprove:
 		default:
 			problems = append(problems, fmt.Sprintf(
-				"%s: unknown action %q (empty denies, %q reports a finding)",
-				r.Name, r.Action, ActionDefer))
+				"%s: unknown action %q (empty denies, %q reports a finding, %q holds for approval)",
+				r.Name, r.Action, ActionDefer, ActionApprove))
 		}
-		if r.Action == ActionDefer && r.Type == MatchAIAnalysis {
-			// The analyzer defers per risk level, and a rule saying both
+		if r.Action != "" && r.Type == MatchAIAnalysis {
+			// The analyzer acts per risk level, and a rule saying both
 			// would leave two answers for one question.
 			problems = append(problems, r.Name+
-				": ai_analysis defers per risk level through high/medium/low, not through action")
+				": ai_analysis acts per risk level through high/medium/low, not through action")
 		}
 		switch r.Type {
 		case MatchDenyWords:
@@ -544,10 +596,25 @@ func newRules(rules []Rule, hasScanner bool) (*Rules, error) {
 					"%s: unknown access %q (read, write, or empty for either)",
 					r.Name, r.Access))
 			}
+		case MatchColumn:
+			if err := r.validateColumn(); err != nil {
+				problems = append(problems, err.Error())
+			}
+		case MatchUnboundedWrite:
+			// Tables is optional: an unfiltered delete is rarely what
+			// anyone meant on any table.
+		case MatchRate:
+			if err := r.validateRate(); err != nil {
+				problems = append(problems, err.Error())
+			}
 		case MatchPII:
 			if err := r.validatePII(hasScanner); err != nil {
 				problems = append(problems, err.Error())
 			}
+		case MatchExpression:
+			if err := r.validateExpression(); err != nil {
+				problems = append(problems, err.Error())
+			}
 		case MatchAIAnalysis:
 			// Rules cannot evaluate this type: it needs a provider, a
 			// deadline and a cache, none of which belong to a local
@@ -571,7 +638,7 @@ func newRules(rules []Rule, hasScanner bool) (*Rules, error) {
 	if len(problems) > 0 {
 		return nil, fmt.Errorf("policy: invalid rules: %s", strings.Join(problems, "; "))
 	}
-	return &Rules{rules: out}, nil
+	return &Rules{rules: out, rates: NewRateCounters()}, nil
 }
 
 // ActionDefer reports a match as a Finding instead of denying it.
@@ -598,6 +665,10 @@ func (r *Rules) EvaluateWith(stmt hoopinspect.Statement, ec *EvalContext) Verdic
 	// named no-cpf find". The rule names ride along inside.
 	var deferred map[MatchType]Finding
 
+	// held is the first holding rule's verdict, returned only if nothing
+	// denies outright.
+	var held *Verdict
+
 	// Flushed on EVERY exit, including a denial. What the scanner found is
 	// true whether or not a later rule refused the statement, and dropping
 	// it on the deny path would make the record depend on rule ORDER: move
@@ -625,10 +696,41 @@ func (r *Rules) EvaluateWith(stmt hoopinspect.Statement, ec *EvalContext) Verdic
 				})
 				continue
 			}
+			if rule.Action == ActionApprove {
+				held = holdFor(held, rule, rule.piiMessage(entities))
+				continue
+			}
 			return Deny(rule.Name, rule.piiMessage(entities))
 		}
 
-		matched, err := rule.matches(stmt)
+		// Rate rules need the identity off the context, and counters
+		// Rules owns.
+		if rule.Type == MatchRate {
+			if !rule.rateCounts(stmt) || r.rates.take(r.lane, rule, rule.rateKey(stmt, ec)) {
+				continue
+			}
+			if rule.Action == ActionDefer {
+				deferred = recordMatch(deferred, rule, nil)
+				continue
+			}
+			if rule.Action == ActionApprove {
+				held = holdFor(held, rule, rule.rateMessage())
+				continue
+			}
+			return Deny(rule.Name, rule.rateMessage())
+		}
+
+		var (
+			matched bool
+			err     error
+		)
+		if rule.Type == MatchExpression {
+			// An expression reads the findings so far, which are Rules'
+			// own deferrals as much as the context's.
+			matched, err = rule.matchesExpression(stmt, ec, findingsSoFar(ec, deferred))
+		} else {
+			matched, err = rule.matches(stmt)
+		}
 		if err != nil {
 			if r.FailOpen {
 				return Verdict{Err: err}
@@ -647,11 +749,28 @@ func (r *Rules) EvaluateWith(stmt hoopinspect.Statement, ec *EvalContext) Verdic
 			deferred = recordMatch(deferred, rule, rule.findingValues(stmt))
 			continue
 		}
+		if rule.Action == ActionApprove {
+			held = holdFor(held, rule, rule.approvalMessageOr(stmt))
+			continue
+		}
 		return Deny(rule.Name, rule.messageOr(stmt))
 	}
+	if held != nil {
+		return *held
+	}
 	return Allow()
 }
 
+// holdFor records a holding rule's match. The first one wins: it is the
+// rule the approver is asked about, and order expresses precedence here as
+// it does for denials.
+func holdFor(held *Verdict, rule Rule, msg string) *Verdict {
+	if held != nil {
+		return held
+	}
+	return &Verdict{Denied: true, Approval: true, Rule: rule.Name, Message: msg}
+}
+
 // recordMatch folds one matching rule into its type's finding.
 //
 // Values merge by union so two rules of one type report the whole of what
@@ -721,6 +840,15 @@ func appendUnique(dst []string, add ...string) []string {
 	return dst
 }
 
+// approvalMessageOr is what the approver and, while it waits, the user are
+// told about a held statement.
+func (r Rule) approvalMessageOr(stmt hoopinspect.Statement) string {
+	if r.Message != "" {
+		return r.Message
+	}
+	return fmt.Sprintf("statement held for approval by policy rule %q (operation=%s)", r.Name, stmt.Operation)
+}
+
 func (r Rule) messageOr(stmt hoopinspect.Statement) string {
 	if r.Message != "" {
 		return r.Message
@@ -790,6 +918,30 @@ func (r Rule) matches(stmt hoopinspect.Statement) (bool, error) {
 			}
 		}
 		return false, nil
+
+	case MatchColumn:
+		return r.matchesColumn(stmt), nil
+
+	case MatchUnboundedWrite:
+		if _, unread := stmt.Metadata[hoopinspect.MetadataSQLIncomplete]; unread {
+			return true, nil
+		}
+		for _, w := range stmt.UnboundedWrites {
+			if len(r.Tables) == 0 || len(w.Relations) == 0 {
+				// A target the scan could not name may be the one
+				// the rule protects.
+				return true, nil
+			}
+			for _, want := range r.Tables {
+				want = strings.ToLower(want)
+				for _, got := range w.Relations {
+					if got == want || strings.HasSuffix(got, "."+want) {
+						return true, nil
+					}
+				}
+			}
+		}
+		return false, nil
 	}
 	return false, fmt.Errorf("policy: unknown rule type %q", r.Type)
 }
@@ -941,7 +1093,10 @@ func (c Chain) Evaluate(stmt hoopinspect.Statement) Verdict {
 // producer's finding reaches a policy and how a policy reaches back to
 // request a producer that would not otherwise have run.
 func (c Chain) EvaluateWith(stmt hoopinspect.Statement, ec *EvalContext) Verdict {
-	var errs error
+	var (
+		errs error
+		held *Verdict
+	)
 	for _, e := range c {
 		var v Verdict
 		if ce, ok := e.(ContextualEvaluator); ok {
@@ -953,6 +1108,16 @@ func (c Chain) EvaluateWith(stmt hoopinspect.Statement, ec *EvalContext) Verdict
 		// analyzer's risk level belongs in the audit record whether or
 		// not anything denied.
 		ec.Annotations = mergeAnnotations(ec.Annotations, v.Annotations)
+		if v.Denied && v.Approval {
+			// A hold is not a denial yet. The evaluators after it still
+			// run, so one that refuses outright is heard before anybody
+			// is asked to approve.
+			if held == nil {
+				held = &v
+			}
+			errs = errors.Join(errs, v.Err)
+			continue
+		}
 		if v.Denied {
 			v.Err = errors.Join(errs, v.Err)
 			v.Annotations = ec.Annotations
@@ -960,5 +1125,10 @@ func (c Chain) EvaluateWith(stmt hoopinspect.Statement, ec *EvalContext) Verdict
 		}
 		errs = errors.Join(errs, v.Err)
 	}
+	if held != nil {
+		held.Err = errs
+		held.Annotations = ec.Annotations
+		return *held
+	}
 	return Verdict{Err: errs, Annotations: ec.Annotations}
 }
@@ -144,6 +144,71 @@ func TestRequireTableMatchFailsClosed(t *testing.T) {
 	}
 }
 
+// analyzed builds a statement the way a SQL codec does.
+func analyzed(sql string, proto hoopinspect.Protocol) hoopinspect.Statement {
+	a := hoopinspect.AnalyzeSQL(sql, proto)
+	s := hoopinspect.Statement{
+		Protocol:        proto,
+		Direction:       hoopinspect.FromClient,
+		Text:            sql,
+		Operation:       a.Operation,
+		Relations:       a.Relations,
+		Tables:          a.Tables,
+		UnboundedWrites: a.UnboundedWrites,
+	}
+	if !a.Complete {
+		s.Metadata = map[string]string{hoopinspect.MetadataSQLIncomplete: a.Reason}
+	} else if !a.ColumnsComplete {
+		s.Metadata = map[string]string{hoopinspect.MetadataColumnsIncomplete: a.ColumnsReason}
+	}
+	return s
+}
+
+func TestUnboundedWrite(t *testing.T) {
+	rules, err := policy.NewRules([]policy.Rule{{
+		Name: "no-unbounded", Type: policy.MatchUnboundedWrite,
+		Message: "UPDATE and DELETE need a WHERE clause",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		sql    string
+		proto  hoopinspect.Protocol
+		denied bool
+	}{
+		{"DELETE FROM customers", hoopinspect.Postgres, true},
+		{"UPDATE customers SET tier = 'gold' WHERE 1=1", hoopinspect.Postgres, true},
+		{"DELETE FROM customers LIMIT 10", hoopinspect.MySQL, true},
+		{"DELETE FROM customers WHERE id = 7", hoopinspect.Postgres, false},
+		{"UPDATE c SET n = 1 FROM c JOIN s ON c.id = s.id", hoopinspect.MSSQL, false},
+		{"SELECT * FROM customers", hoopinspect.Postgres, false},
+		{"TRUNCATE customers", hoopinspect.Postgres, false},
+		// Unreadable: the scan cannot rule the write out, so it fails
+		// closed.
+		{"DO $$ BEGIN DELETE FROM customers; END $$", hoopinspect.Postgres, true},
+	} {
+		v := rules.Evaluate(analyzed(tc.sql, tc.proto))
+		if v.Denied != tc.denied {
+			t.Errorf("%s: denied = %v, want %v", tc.sql, v.Denied, tc.denied)
+		}
+		if v.Denied && v.Message != "UPDATE and DELETE need a WHERE clause" {
+			t.Errorf("%s: message = %q", tc.sql, v.Message)
+		}
+	}
+
+	scoped, _ := policy.NewRules([]policy.Rule{{
+		Name: "no-unbounded-customers", Type: policy.MatchUnboundedWrite,
+		Tables: []string{"Customers"},
+	}})
+	if !scoped.Evaluate(analyzed("DELETE FROM public.customers", hoopinspect.Postgres)).Denied {
+		t.Error("an unfiltered delete of a protected table was allowed")
+	}
+	if scoped.Evaluate(analyzed("DELETE FROM scratch", hoopinspect.Postgres)).Denied {
+		t.Error("an unfiltered delete of an unprotected table was denied")
+	}
+}
+
 func TestPatternMatch(t *testing.T) {
 	rules, _ := policy.NewRules([]policy.Rule{{
 		Name:    "no-unbounded-delete",
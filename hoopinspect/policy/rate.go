@@ -0,0 +1,334 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// MatchRate denies a statement once its identity has spent its allowance:
+// "at most 20 DELETEs per minute per user", "no more than 1000 statements per
+// session".
+//
+// It is the one stateful rule type. Every other rule judges a statement on
+// its own, which cannot express a runaway script or an agent in a retry loop:
+// each of its statements is individually fine, and the problem is that there
+// are ten thousand of them.
+const MatchRate MatchType = "rate"
+
+// Rate algorithms.
+const (
+	// RateFixedWindow counts statements in consecutive windows of
+	// WindowSec, aligned to the clock, and denies past Limit until the next
+	// window opens. With no WindowSec the window never closes, which makes
+	// it a quota: pair it with per: [session] for "1000 statements per
+	// session".
+	RateFixedWindow = "fixed_window"
+
+	// RateTokenBucket refills Limit tokens per WindowSec continuously, up
+	// to Burst, and spends one per statement. It smooths where a fixed
+	// window allows two full windows back to back across a boundary.
+	RateTokenBucket = "token_bucket"
+)
+
+// What a rate rule can count per. The lane is always part of the key, so
+// one rule shared by two listeners keeps two allowances.
+const (
+	RatePerSubject   = "subject"
+	RatePerSession   = "session"
+	RatePerOperation = "operation"
+)
+
+// RateSpec is a MatchRate rule's allowance.
+type RateSpec struct {
+	// Limit is how many statements one key may run per window.
+	Limit int `json:"limit"`
+
+	// WindowSec is the window length. Required for a token bucket; a fixed
+	// window without one is a quota that never resets.
+	WindowSec int `json:"window_sec,omitempty"`
+
+	// Algorithm is fixed_window (the default) or token_bucket.
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// Burst caps a token bucket's savings. Defaults to Limit, so an idle
+	// key can spend one window's allowance at once and no more.
+	Burst int `json:"burst,omitempty"`
+
+	// Per names what the allowance is counted per: subject, session,
+	// operation. Empty counts per subject, which is what "per user" means
+	// and what most rules want.
+	Per []string `json:"per,omitempty"`
+}
+
+func (s *RateSpec) algorithm() string {
+	if s.Algorithm == "" {
+		return RateFixedWindow
+	}
+	return s.Algorithm
+}
+
+func (s *RateSpec) window() time.Duration { return time.Duration(s.WindowSec) * time.Second }
+
+func (s *RateSpec) burst() int {
+	if s.Burst > 0 {
+		return s.Burst
+	}
+	return s.Limit
+}
+
+func (s *RateSpec) per() []string {
+	if len(s.Per) == 0 {
+		return []string{RatePerSubject}
+	}
+	return s.Per
+}
+
+func (r Rule) validateRate() error {
+	s := r.Rate
+	if s == nil {
+		return fmt.Errorf("%s: rate rule with no rate block", r.Name)
+	}
+	var problems []string
+	if s.Limit <= 0 {
+		problems = append(problems, "rate.limit must be positive")
+	}
+	if s.WindowSec < 0 {
+		problems = append(problems, "rate.window_sec must not be negative")
+	}
+	switch s.algorithm() {
+	case RateFixedWindow:
+		if s.Burst != 0 {
+			problems = append(problems, "rate.burst applies only to token_bucket")
+		}
+	case RateTokenBucket:
+		if s.WindowSec == 0 {
+			problems = append(problems, "a token_bucket needs rate.window_sec to refill over")
+		}
+		if s.Burst < 0 {
+			problems = append(problems, "rate.burst must not be negative")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf(
+			"unknown rate.algorithm %q (%s or %s)", s.Algorithm, RateFixedWindow, RateTokenBucket))
+	}
+	for _, p := range s.Per {
+		switch p {
+		case RatePerSubject, RatePerSession, RatePerOperation:
+		default:
+			problems = append(problems, fmt.Sprintf(
+				"unknown rate.per %q (subject, session or operation)", p))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: %s", r.Name, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// rateKey is the allowance a statement draws on.
+//
+// The principal and session come from the evaluation context the gate
+// seeds. A caller evaluating without one counts every statement against a
+// single anonymous principal, which is the strict reading.
+func (r Rule) rateKey(stmt hoopinspect.Statement, ec *EvalContext) string {
+	var ctx map[string]string
+	if ec != nil {
+		ctx = ec.Context
+	}
+	s := r.Rate
+	// The algorithm and window are part of the key so a reload that changes
+	// either starts the rule over instead of reading a count in one shape
+	// as a balance in the other. A changed limit keeps the count.
+	parts := []string{r.Name, s.algorithm(), fmt.Sprint(s.WindowSec)}
+	for _, p := range s.per() {
+		switch p {
+		case RatePerSubject:
+			parts = append(parts, "s="+ctx["principal"])
+		case RatePerSession:
+			parts = append(parts, "id="+ctx["session_id"])
+		case RatePerOperation:
+			parts = append(parts, "op="+string(stmt.Operation))
+		}
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// rateCounts reports whether a rate rule applies to stmt at all: Operations,
+// when set, narrows the statements it counts.
+func (r Rule) rateCounts(stmt hoopinspect.Statement) bool {
+	return len(r.Operations) == 0 || slices.Contains(r.Operations, stmt.Operation)
+}
+
+func (r Rule) rateMessage() string {
+	if r.Message != "" {
+		return r.Message
+	}
+	s := r.Rate
+	if s.WindowSec == 0 {
+		return fmt.Sprintf("statement quota exceeded: rule %q allows %d", r.Name, s.Limit)
+	}
+	return fmt.Sprintf("rate limit exceeded: rule %q allows %d per %s",
+		r.Name, s.Limit, s.window())
+}
+
+// RateCounters holds the allowances every rate rule draws on.
+//
+// It lives outside Rules so it can outlive one: a config reload builds new
+// Rules, and a user who had spent their allowance must not get a fresh one
+// because an operator fixed a typo in an unrelated rule. The sidecar keeps
+// one for the life of the process and hands it to every lane's Rules with
+// ShareRates; a Rules built without that gets a private one.
+type RateCounters struct {
+	// Now is the clock. Defaults to time.Now; injectable so a test can move
+	// through a window without sleeping.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*rateEntry
+	stats   map[[2]string]*RateStat
+	sweeps  int
+}
+
+// RateStat is one rule's running totals on one lane, as /stats reports them.
+type RateStat struct {
+	Lane    string `json:"lane"`
+	Rule    string `json:"rule"`
+	Allowed int64  `json:"allowed"`
+	Denied  int64  `json:"denied"`
+
+	// Keys is how many allowances are live: roughly, how many users or
+	// sessions the rule is tracking right now.
+	Keys int `json:"keys"`
+}
+
+type rateEntry struct {
+	stat *RateStat
+
+	// A fixed window counts from start; a token bucket holds tokens as of
+	// start.
+	start  time.Time
+	count  int
+	tokens float64
+
+	// expires is when the entry carries no information any more: its
+	// window has closed, or its bucket has refilled. Evicting then is
+	// indistinguishable from keeping it.
+	expires time.Time
+}
+
+// quotaIdle is how long a quota (a fixed window with no length) is kept
+// without use. A quota never resets, so its entry is otherwise immortal,
+// and one per session would grow without bound on a busy lane.
+const quotaIdle = 24 * time.Hour
+
+// sweepEvery is how many takes pass between evictions of expired entries.
+const sweepEvery = 1024
+
+// NewRateCounters returns empty counters on the wall clock.
+func NewRateCounters() *RateCounters {
+	return &RateCounters{
+		Now:     time.Now,
+		entries: make(map[string]*rateEntry),
+		stats:   make(map[[2]string]*RateStat),
+	}
+}
+
+// take spends one statement from key's allowance under rule, and reports
+// whether there was one to spend. A denied statement spends nothing, so a
+// client retrying in a loop cannot push its own recovery further away.
+func (c *RateCounters) take(lane string, rule Rule, key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.Now()
+	if c.sweeps++; c.sweeps%sweepEvery == 0 {
+		c.sweep(now)
+	}
+
+	s := rule.Rate
+	full := lane + "\x00" + key
+	e := c.entries[full]
+	if e == nil || !now.Before(e.expires) {
+		st := c.stats[[2]string{lane, rule.Name}]
+		if st == nil {
+			st = &RateStat{Lane: lane, Rule: rule.Name}
+			c.stats[[2]string{lane, rule.Name}] = st
+		}
+		if e != nil {
+			st.Keys--
+		}
+		e = &rateEntry{stat: st, start: now, tokens: float64(s.burst())}
+		if s.algorithm() == RateFixedWindow && s.WindowSec > 0 {
+			e.start = now.Truncate(s.window())
+		}
+		c.entries[full] = e
+		st.Keys++
+	}
+
+	var ok bool
+	switch s.algorithm() {
+	case RateTokenBucket:
+		refill := now.Sub(e.start).Seconds() / s.window().Seconds() * float64(s.Limit)
+		e.tokens = min(e.tokens+refill, float64(s.burst()))
+		e.start = now
+		if ok = e.tokens >= 1; ok {
+			e.tokens--
+		}
+		missing := float64(s.burst()) - e.tokens
+		e.expires = now.Add(time.Duration(missing / float64(s.Limit) * float64(s.window())))
+	default:
+		if ok = e.count < s.Limit; ok {
+			e.count++
+		}
+		if s.WindowSec > 0 {
+			e.expires = e.start.Add(s.window())
+		} else {
+			e.expires = now.Add(quotaIdle)
+		}
+	}
+
+	if ok {
+		e.stat.Allowed++
+	} else {
+		e.stat.Denied++
+	}
+	return ok
+}
+
+func (c *RateCounters) sweep(now time.Time) {
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			e.stat.Keys--
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Stats returns every rule's totals, ordered by lane and rule.
+func (c *RateCounters) Stats() []RateStat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]RateStat, 0, len(c.stats))
+	for _, st := range c.stats {
+		out = append(out, *st)
+	}
+	slices.SortFunc(out, func(a, b RateStat) int {
+		if n := strings.Compare(a.Lane, b.Lane); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Rule, b.Rule)
+	})
+	return out
+}
+
+// ShareRates makes r count its rate rules in c, under lane. Call it before r
+// evaluates anything; it is how allowances survive a reload and how /stats
+// reads them.
+func (r *Rules) ShareRates(c *RateCounters, lane string) {
+	r.rates, r.lane = c, lane
+}
@@ -0,0 +1,177 @@
+package policy_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/policy"
+)
+
+// clock is a RateCounters clock a test moves by hand.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func rateRules(t *testing.T, spec policy.RateSpec, ops ...hoopinspect.Operation) (*policy.Rules, *clock) {
+	t.Helper()
+	rules, err := policy.NewRules([]policy.Rule{{
+		Name: "deletes", Type: policy.MatchRate, Operations: ops,
+		Rate: &spec, Message: "slow down",
+	}})
+	if err != nil {
+		t.Fatalf("NewRules: %v", err)
+	}
+	clk := &clock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	c := policy.NewRateCounters()
+	c.Now = clk.now
+	rules.ShareRates(c, "appdb")
+	return rules, clk
+}
+
+func as(principal, session string) *policy.EvalContext {
+	return &policy.EvalContext{Context: map[string]string{
+		"principal": principal, "session_id": session,
+	}}
+}
+
+// run evaluates n DELETEs as ec and returns how many were denied.
+func run(rules *policy.Rules, ec *policy.EvalContext, n int) (denied int) {
+	for range n {
+		if rules.EvaluateWith(stmt("DELETE FROM t WHERE id=1", hoopinspect.OpDelete), ec).Denied {
+			denied++
+		}
+	}
+	return denied
+}
+
+func TestFixedWindowDeniesPastTheLimitUntilTheNextWindow(t *testing.T) {
+	rules, clk := rateRules(t, policy.RateSpec{Limit: 3, WindowSec: 60})
+	alice := as("alice", "s1")
+
+	if n := run(rules, alice, 5); n != 2 {
+		t.Fatalf("denied %d of 5, want 2", n)
+	}
+	v := rules.EvaluateWith(stmt("DELETE FROM t", hoopinspect.OpDelete), alice)
+	if v.Message != "slow down" || v.Rule != "deletes" {
+		t.Errorf("verdict = %+v, want the operator's message", v)
+	}
+
+	clk.advance(time.Minute)
+	if n := run(rules, alice, 3); n != 0 {
+		t.Errorf("denied %d in a fresh window", n)
+	}
+}
+
+// One user spending their allowance does not spend anyone else's.
+func TestRateIsCountedPerSubject(t *testing.T) {
+	rules, _ := rateRules(t, policy.RateSpec{Limit: 1, WindowSec: 60})
+	if n := run(rules, as("alice", "s1"), 2); n != 1 {
+		t.Fatalf("alice denied %d, want 1", n)
+	}
+	if n := run(rules, as("bob", "s2"), 1); n != 0 {
+		t.Error("bob was denied on alice's allowance")
+	}
+	// Reconnecting does not reset a per-subject allowance.
+	if n := run(rules, as("alice", "s3"), 1); n != 1 {
+		t.Error("a new session reset alice's allowance")
+	}
+}
+
+// A quota with no window, per session: a new session starts over.
+func TestSessionQuota(t *testing.T) {
+	rules, clk := rateRules(t, policy.RateSpec{Limit: 2, Per: []string{policy.RatePerSession}})
+	if n := run(rules, as("alice", "s1"), 3); n != 1 {
+		t.Fatalf("denied %d of 3, want 1", n)
+	}
+	clk.advance(time.Hour)
+	if n := run(rules, as("alice", "s1"), 1); n != 1 {
+		t.Error("a quota reset with time")
+	}
+	if n := run(rules, as("alice", "s2"), 2); n != 0 {
+		t.Error("a new session inherited the old one's quota")
+	}
+}
+
+// A token bucket refills continuously rather than at a boundary.
+func TestTokenBucketRefills(t *testing.T) {
+	rules, clk := rateRules(t, policy.RateSpec{
+		Algorithm: policy.RateTokenBucket, Limit: 60, WindowSec: 60, Burst: 2,
+	})
+	alice := as("alice", "s1")
+	if n := run(rules, alice, 3); n != 1 {
+		t.Fatalf("denied %d of 3 against a burst of 2, want 1", n)
+	}
+	clk.advance(time.Second) // one token at 60/min
+	if n := run(rules, alice, 2); n != 1 {
+		t.Errorf("denied %d of 2 after one second's refill, want 1", n)
+	}
+	clk.advance(time.Hour)
+	if n := run(rules, alice, 3); n != 1 {
+		t.Errorf("savings exceeded the burst: denied %d of 3, want 1", n)
+	}
+}
+
+// Operations narrows what the rule counts; other statements neither spend
+// the allowance nor are denied by it.
+func TestRateCountsOnlyItsOperations(t *testing.T) {
+	rules, _ := rateRules(t, policy.RateSpec{Limit: 1, WindowSec: 60}, hoopinspect.OpDelete)
+	alice := as("alice", "s1")
+	for range 5 {
+		if rules.EvaluateWith(stmt("SELECT 1", hoopinspect.OpSelect), alice).Denied {
+			t.Fatal("a SELECT was counted by a DELETE rule")
+		}
+	}
+	if n := run(rules, alice, 2); n != 1 {
+		t.Errorf("denied %d DELETEs of 2, want 1", n)
+	}
+}
+
+// Counters shared across two rule sets, as across a reload, keep the
+// allowance, and Stats reports what the rule did.
+func TestSharedCountersSurviveANewRuleSet(t *testing.T) {
+	c := policy.NewRateCounters()
+	build := func() *policy.Rules {
+		r, err := policy.NewRules([]policy.Rule{{
+			Name: "deletes", Type: policy.MatchRate,
+			Rate: &policy.RateSpec{Limit: 2, WindowSec: 3600},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.ShareRates(c, "appdb")
+		return r
+	}
+	alice := as("alice", "s1")
+	run(build(), alice, 2)
+	if n := run(build(), alice, 1); n != 1 {
+		t.Fatal("a rebuilt rule set handed out a fresh allowance")
+	}
+
+	stats := c.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if s := stats[0]; s.Lane != "appdb" || s.Rule != "deletes" || s.Allowed != 2 || s.Denied != 1 || s.Keys != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestRateRuleValidation(t *testing.T) {
+	cases := map[string]*policy.RateSpec{
+		"no rate block":            nil,
+		"rate.limit must be":       {WindowSec: 60},
+		"needs rate.window_sec":    {Limit: 1, Algorithm: policy.RateTokenBucket},
+		"burst applies only":       {Limit: 1, WindowSec: 60, Burst: 5},
+		"unknown rate.algorithm":   {Limit: 1, WindowSec: 60, Algorithm: "leaky"},
+		`unknown rate.per "table"`: {Limit: 1, Per: []string{"table"}},
+	}
+	for want, spec := range cases {
+		_, err := policy.NewRules([]policy.Rule{{Name: "r", Type: policy.MatchRate, Rate: spec}})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v", want, err)
+		}
+	}
+}
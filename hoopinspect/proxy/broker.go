@@ -0,0 +1,414 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/hoophq/hoopinspect/session"
+)
+
+// Login is what a Broker resolves a client's credential to: who the client
+// is, and the upstream login the relay makes on its behalf.
+type Login struct {
+	// Identity is the session's, taken from the credential the client
+	// proved rather than the user it named.
+	Identity session.Identity
+
+	// User and Password log in to the upstream. The password never leaves
+	// the relay: the client sees only the upstream's answer.
+	User     string
+	Password []byte
+}
+
+// ErrLoginRefused is what a Broker returns for a credential it does not
+// recognize. The client is told its password failed; the log and the audit
+// trail get the error.
+var ErrLoginRefused = errors.New("hoopinspect/proxy: credential refused")
+
+// Authentication message selectors, the int32 after an 'R' tag.
+const (
+	pgAuthOK           uint32 = 0
+	pgAuthCleartext    uint32 = 3
+	pgAuthMD5          uint32 = 5
+	pgAuthSASLContinue uint32 = 11
+	pgAuthSASLFinal    uint32 = 12
+)
+
+// maxAuthMessage bounds one message of the login exchange. Every legitimate
+// one is a few hundred bytes.
+const maxAuthMessage = 16 << 10
+
+// brokerLogin runs a postgres login with the relay in the middle as a party
+// to it, rather than a pipe for it.
+//
+// # The exchange
+//
+// To the client the relay is the server: it takes the StartupMessage, asks
+// for a password, and hands the password to the Broker, which says whose
+// credential it is. To the upstream the relay is the client: it sends the
+// same StartupMessage with the broker's user in it, and answers a
+// SCRAM-SHA-256 challenge with the broker's password. The upstream's
+// AuthenticationOk goes back to the client, and from there the two pumps
+// carry the session as they carry any other.
+//
+// The client authenticates first, so a stranger's connection never costs the
+// upstream a login.
+//
+// # Why the client sends a cleartext password
+//
+// Its "password" is a token the relay has to see to look up. SCRAM would
+// keep it off the wire, but it needs a verifier per token, salted and
+// stored, for no gain over TLS: the token is only ever checked here. It is
+// the pattern RDS IAM authentication uses, and like that pattern it is safe
+// only on an encrypted or local leg. requireTLS refuses a client that did
+// not negotiate TLS before it is asked for anything.
+//
+// # What comes back
+//
+// Connections that read through what this function buffered, so no byte
+// either side sent past the login is lost, and the identity the broker
+// named. A CancelRequest is not a login: it is forwarded untouched and
+// returns brokered false.
+func brokerLogin(
+	client, upstream net.Conn,
+	broker func(user string, secret []byte) (Login, error),
+	requireTLS bool,
+	timeout time.Duration,
+) (c, u net.Conn, id session.Identity, brokered bool, err error) {
+	if timeout > 0 {
+		deadline := time.Now().Add(timeout)
+		if err := client.SetDeadline(deadline); err != nil {
+			return nil, nil, id, false, err
+		}
+		if err := upstream.SetDeadline(deadline); err != nil {
+			return nil, nil, id, false, err
+		}
+	}
+
+	cr := bufio.NewReaderSize(client, 16<<10)
+	startup, err := readStartup(cr)
+	if err != nil {
+		return nil, nil, id, false, err
+	}
+	if binary.BigEndian.Uint32(startup[4:8]) == pgCancelRequestCode {
+		if _, err := upstream.Write(startup); err != nil {
+			return nil, nil, id, false, err
+		}
+		c, u, err = finishBroker(client, cr, upstream, nil, timeout)
+		return c, u, id, false, err
+	}
+	params, err := startupParams(startup)
+	if err != nil {
+		return nil, nil, id, false, err
+	}
+	claimed := paramValue(params, "user")
+
+	if requireTLS && tlsConnOf(client) == nil {
+		writePgFatal(client, "28000", "this relay requires an SSL connection for a brokered login")
+		return nil, nil, id, false, errors.New("client asked for a brokered login without TLS")
+	}
+
+	// The client's half: ask for the credential, and let the broker say
+	// whose it is.
+	if _, err := client.Write(pgAuthRequest(pgAuthCleartext, nil)); err != nil {
+		return nil, nil, id, false, err
+	}
+	tag, body, err := readPgMessage(cr)
+	if err != nil {
+		// psql hangs up here to prompt for a password it did not have, then
+		// reconnects with one. That is not a failed login.
+		return nil, nil, id, false, fmt.Errorf("client left before sending a password: %w", err)
+	}
+	if tag != 'p' {
+		return nil, nil, id, false, fmt.Errorf("client answered the password request with a %q message", tag)
+	}
+	secret, _, _ := bytes.Cut(body, []byte{0})
+	login, err := broker(claimed, secret)
+	if err != nil {
+		writePgFatal(client, "28P01", fmt.Sprintf("password authentication failed for user %q", claimed))
+		return nil, nil, id, false, fmt.Errorf("login as %q: %w", claimed, err)
+	}
+
+	// The upstream's half, as the broker's user.
+	params = setParam(params, "user", login.User)
+	if _, err := upstream.Write(buildStartup(startup[4:8], params)); err != nil {
+		return nil, nil, id, false, fmt.Errorf("sending the brokered startup: %w", err)
+	}
+	ur := bufio.NewReaderSize(upstream, 16<<10)
+	if err := upstreamLogin(ur, upstream, client, login); err != nil {
+		return nil, nil, id, false, err
+	}
+	if _, err := client.Write(pgAuthRequest(pgAuthOK, nil)); err != nil {
+		return nil, nil, id, false, err
+	}
+
+	c, u, err = finishBroker(client, cr, upstream, ur, timeout)
+	return c, u, login.Identity, true, err
+}
+
+// upstreamLogin answers the upstream's authentication request with the
+// broker's credential, up to the AuthenticationOk it does not forward.
+//
+// Only SCRAM-SHA-256 is spoken, and AuthenticationOk outright for a trust
+// rule. A cleartext or MD5 request is refused rather than answered: the
+// first would send the password the relay exists to hold to whatever
+// answered the dial, and MD5 is a hash an eavesdropper can replay.
+func upstreamLogin(ur *bufio.Reader, upstream, client net.Conn, login Login) error {
+	// inSASL is an exchange begun and not yet verified. AuthenticationOk
+	// inside one is a server skipping the proof of its own identity.
+	var scram *scramClient
+	inSASL := false
+	for {
+		tag, body, err := readPgMessage(ur)
+		if err != nil {
+			return fmt.Errorf("reading the upstream's login reply: %w", err)
+		}
+		switch tag {
+		case 'E':
+			// The client gets the upstream's own words: a missing database
+			// or a connection limit is something it can act on.
+			forwardPgMessage(client, tag, body)
+			return fmt.Errorf("upstream refused the brokered login: %s", pgErrorMessage(body))
+		case 'v':
+			// NegotiateProtocolVersion answers the client's startup, which
+			// the upstream received intact, so it is the client's to read.
+			forwardPgMessage(client, tag, body)
+			continue
+		case 'R':
+		default:
+			return fmt.Errorf("upstream sent a %q message during login", tag)
+		}
+		if len(body) < 4 {
+			return errors.New("upstream sent a truncated authentication message")
+		}
+		code, data := binary.BigEndian.Uint32(body[:4]), body[4:]
+
+		switch code {
+		case pgAuthOK:
+			if inSASL {
+				return errors.New("upstream accepted the login without completing SCRAM")
+			}
+			return nil
+		case pgAuthSASL:
+			if !slices.Contains(saslMechanisms(data), pgSCRAM) {
+				return fmt.Errorf("upstream offered no %s; the broker speaks only that", pgSCRAM)
+			}
+			scram, inSASL = &scramClient{password: login.Password}, true
+			first, err := scram.first()
+			if err != nil {
+				return err
+			}
+			msg := append([]byte(pgSCRAM), 0)
+			msg = binary.BigEndian.AppendUint32(msg, uint32(len(first)))
+			if err := writePgMessage(upstream, 'p', append(msg, first...)); err != nil {
+				return err
+			}
+		case pgAuthSASLContinue:
+			if scram == nil {
+				return errors.New("upstream continued a SASL exchange that never began")
+			}
+			final, err := scram.final(data)
+			if err != nil {
+				return err
+			}
+			if err := writePgMessage(upstream, 'p', final); err != nil {
+				return err
+			}
+		case pgAuthSASLFinal:
+			if scram == nil {
+				return errors.New("upstream finished a SASL exchange that never began")
+			}
+			if err := scram.verify(data); err != nil {
+				return err
+			}
+			inSASL = false
+		case pgAuthCleartext, pgAuthMD5:
+			return fmt.Errorf("upstream asked for a %s password; the broker answers only %s "+
+				"(set the role's password with password_encryption = scram-sha-256)",
+				map[uint32]string{pgAuthCleartext: "cleartext", pgAuthMD5: "MD5"}[code], pgSCRAM)
+		default:
+			return fmt.Errorf("upstream asked for authentication method %d, which the broker does not speak", code)
+		}
+	}
+}
+
+// finishBroker clears the login deadline and hands back connections that
+// read through what the login buffered.
+func finishBroker(
+	client net.Conn, cr *bufio.Reader, upstream net.Conn, ur *bufio.Reader, timeout time.Duration,
+) (net.Conn, net.Conn, error) {
+	if timeout > 0 {
+		if err := client.SetDeadline(time.Time{}); err != nil {
+			return nil, nil, err
+		}
+		if err := upstream.SetDeadline(time.Time{}); err != nil {
+			return nil, nil, err
+		}
+	}
+	var u net.Conn = upstream
+	if ur != nil {
+		u = &bufferedConn{Conn: upstream, r: ur}
+	}
+	return &bufferedConn{Conn: client, r: cr}, u, nil
+}
+
+// readStartup reads an untagged startup-shaped packet whole.
+func readStartup(r *bufio.Reader) ([]byte, error) {
+	hdr, err := r.Peek(pgNegotiateLen)
+	if err != nil {
+		return nil, fmt.Errorf("reading the startup packet: %w", err)
+	}
+	n := binary.BigEndian.Uint32(hdr[:4])
+	if n < pgNegotiateLen || n > maxStartupPacket {
+		return nil, fmt.Errorf("startup packet of %d bytes", n)
+	}
+	pkt := make([]byte, n)
+	if _, err := io.ReadFull(r, pkt); err != nil {
+		return nil, fmt.Errorf("reading the startup packet: %w", err)
+	}
+	return pkt, nil
+}
+
+// startupParams returns a v3 StartupMessage's parameters in order, as
+// alternating keys and values.
+func startupParams(pkt []byte) ([]string, error) {
+	if binary.BigEndian.Uint32(pkt[4:8])>>16 != 3 {
+		return nil, fmt.Errorf("startup packet for protocol %d, want 3", binary.BigEndian.Uint32(pkt[4:8])>>16)
+	}
+	var out []string
+	rest := pkt[8:]
+	for {
+		k, after, ok := bytes.Cut(rest, []byte{0})
+		if !ok {
+			return nil, errors.New("unterminated startup parameters")
+		}
+		if len(k) == 0 {
+			return out, nil
+		}
+		v, after, ok := bytes.Cut(after, []byte{0})
+		if !ok {
+			return nil, errors.New("unterminated startup parameters")
+		}
+		out = append(out, string(k), string(v))
+		rest = after
+	}
+}
+
+func paramValue(params []string, key string) string {
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i] == key {
+			return params[i+1]
+		}
+	}
+	return ""
+}
+
+func setParam(params []string, key, value string) []string {
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i] == key {
+			params[i+1] = value
+			return params
+		}
+	}
+	return append(params, key, value)
+}
+
+// buildStartup reassembles a StartupMessage from its version and parameters.
+func buildStartup(version []byte, params []string) []byte {
+	out := make([]byte, 4, 64)
+	out = append(out, version...)
+	for _, p := range params {
+		out = append(append(out, p...), 0)
+	}
+	out = append(out, 0)
+	binary.BigEndian.PutUint32(out[:4], uint32(len(out)))
+	return out
+}
+
+// readPgMessage reads one tagged message, returning its tag and body.
+func readPgMessage(r *bufio.Reader) (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[1:5])
+	if n < 4 || n > maxAuthMessage {
+		return 0, nil, fmt.Errorf("a %q message of %d bytes during login", hdr[0], n)
+	}
+	body := make([]byte, n-4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return hdr[0], body, nil
+}
+
+func writePgMessage(w io.Writer, tag byte, body []byte) error {
+	msg := make([]byte, 0, 5+len(body))
+	msg = append(msg, tag)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(body)+4))
+	_, err := w.Write(append(msg, body...))
+	return err
+}
+
+// forwardPgMessage passes one message to the client. Best effort: the login
+// is failing either way, and the message only explains why.
+func forwardPgMessage(client net.Conn, tag byte, body []byte) {
+	_ = writePgMessage(client, tag, body)
+}
+
+// pgAuthRequest builds an authentication message with the given selector.
+func pgAuthRequest(code uint32, data []byte) []byte {
+	body := binary.BigEndian.AppendUint32(nil, code)
+	msg := []byte{pgTagAuth}
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(body)+len(data)+4))
+	return append(append(msg, body...), data...)
+}
+
+// writePgFatal sends a FATAL ErrorResponse with a login's SQLSTATE, which
+// psql shows as it would the server's own refusal.
+func writePgFatal(client net.Conn, code, msg string) {
+	var body []byte
+	for _, f := range []struct {
+		t byte
+		v string
+	}{{'S', "FATAL"}, {'V', "FATAL"}, {'C', code}, {'M', msg}} {
+		body = append(append(append(body, f.t), f.v...), 0)
+	}
+	forwardPgMessage(client, 'E', append(body, 0))
+}
+
+// pgErrorMessage is an ErrorResponse's human-readable field.
+func pgErrorMessage(body []byte) string {
+	for len(body) > 1 {
+		t := body[0]
+		v, rest, ok := bytes.Cut(body[1:], []byte{0})
+		if !ok {
+			break
+		}
+		if t == 'M' {
+			return string(v)
+		}
+		body = rest
+	}
+	return "no message"
+}
+
+// saslMechanisms lists the mechanisms in an AuthenticationSASL body.
+func saslMechanisms(data []byte) []string {
+	var out []string
+	for {
+		m, rest, ok := bytes.Cut(data, []byte{0})
+		if !ok || len(m) == 0 {
+			return out
+		}
+		out = append(out, string(m))
+		data = rest
+	}
+}
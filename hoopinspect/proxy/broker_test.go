@@ -0,0 +1,302 @@
+package proxy_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/audit"
+	"github.com/hoophq/hoopinspect/proxy"
+	"github.com/hoophq/hoopinspect/session"
+)
+
+// scramUpstream is a postgres server that knows one role and demands
+// SCRAM-SHA-256 for it, then records what the session sends.
+type scramUpstream struct {
+	ln             net.Listener
+	user, password string
+
+	mu       sync.Mutex
+	startups []string // the user each startup named
+	after    []byte   // bytes received after a successful login
+	logins   int
+}
+
+func newSCRAMUpstream(t *testing.T, user, password string) *scramUpstream {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &scramUpstream{ln: ln, user: user, password: password}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go u.serve(c)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return u
+}
+
+func (u *scramUpstream) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return
+	}
+	pkt := make([]byte, binary.BigEndian.Uint32(hdr[:])-4)
+	io.ReadFull(r, pkt)
+	fields := strings.Split(string(pkt[4:]), "\x00")
+	var user string
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "user" {
+			user = fields[i+1]
+		}
+	}
+	u.mu.Lock()
+	u.startups = append(u.startups, user)
+	u.mu.Unlock()
+
+	send := func(tag byte, body []byte) {
+		msg := append([]byte{tag}, binary.BigEndian.AppendUint32(nil, uint32(len(body)+4))...)
+		c.Write(append(msg, body...))
+	}
+	auth := func(code uint32, data string) { send('R', append(binary.BigEndian.AppendUint32(nil, code), data...)) }
+	recv := func() []byte {
+		var h [5]byte
+		if _, err := io.ReadFull(r, h[:]); err != nil {
+			return nil
+		}
+		b := make([]byte, binary.BigEndian.Uint32(h[1:])-4)
+		io.ReadFull(r, b)
+		return b
+	}
+	fail := func() { send('E', []byte("SFATAL\x00C28P01\x00Mpassword authentication failed\x00\x00")) }
+
+	if user != u.user {
+		fail()
+		return
+	}
+	auth(10, "SCRAM-SHA-256-PLUS\x00SCRAM-SHA-256\x00\x00")
+	initial := recv()
+	mech, rest, _ := bytes.Cut(initial, []byte{0})
+	if string(mech) != "SCRAM-SHA-256" || len(rest) < 4 {
+		fail()
+		return
+	}
+	clientFirst := string(rest[4:])
+	bare := strings.TrimPrefix(clientFirst, "n,,")
+	cnonce := bare[strings.Index(bare, "r=")+2:]
+	salt := []byte("pepper-salt")
+	serverFirst := "r=" + cnonce + "srvnonce,s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+	auth(11, serverFirst)
+
+	clientFinal := string(recv())
+	proofAt := strings.LastIndex(clientFinal, ",p=")
+	proof, _ := base64.StdEncoding.DecodeString(clientFinal[proofAt+3:])
+	authMessage := bare + "," + serverFirst + "," + clientFinal[:proofAt]
+
+	mac := func(k []byte, m string) []byte { h := hmac.New(sha256.New, k); h.Write([]byte(m)); return h.Sum(nil) }
+	salted, _ := pbkdf2.Key(sha256.New, u.password, salt, 4096, 32)
+	stored := sha256.Sum256(mac(salted, "Client Key"))
+	sig := mac(stored[:], authMessage)
+	if len(proof) != len(sig) {
+		fail()
+		return
+	}
+	for i := range sig {
+		sig[i] ^= proof[i]
+	}
+	if got := sha256.Sum256(sig); !bytes.Equal(got[:], stored[:]) {
+		fail()
+		return
+	}
+	auth(12, "v="+base64.StdEncoding.EncodeToString(mac(mac(salted, "Server Key"), authMessage)))
+	auth(0, "")
+	send('Z', []byte("I"))
+	u.mu.Lock()
+	u.logins++
+	u.mu.Unlock()
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		u.mu.Lock()
+		u.after = append(u.after, buf[:n]...)
+		u.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (u *scramUpstream) snapshot() (startups []string, logins int, after []byte) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.startups...), u.logins, append([]byte(nil), u.after...)
+}
+
+// tokenBroker knows one token, alice's, and logs her in as app.
+func tokenBroker(user string, secret []byte) (proxy.Login, error) {
+	if string(secret) != "alice-token" {
+		return proxy.Login{}, proxy.ErrLoginRefused
+	}
+	return proxy.Login{
+		Identity: session.Identity{Subject: "alice", Groups: []string{"analysts"}},
+		User:     "app",
+		Password: []byte("s3cret-db-password"),
+	}, nil
+}
+
+// brokeredClient logs in through the relay as a psql would, returning the
+// first message the relay answers the password with and a reader positioned
+// after it.
+func brokeredClient(t *testing.T, addr, user, token string) (net.Conn, *bufio.Reader, byte, []byte) {
+	t.Helper()
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+	c.Write(pgStartupAs(user))
+	r := bufio.NewReader(c)
+	read := func() (byte, []byte) {
+		var h [5]byte
+		if _, err := io.ReadFull(r, h[:]); err != nil {
+			t.Fatalf("reading from the relay: %v", err)
+		}
+		b := make([]byte, binary.BigEndian.Uint32(h[1:])-4)
+		io.ReadFull(r, b)
+		return h[0], b
+	}
+	tag, body := read()
+	if tag != 'R' || binary.BigEndian.Uint32(body) != 3 {
+		t.Fatalf("relay opened with %q %v, want a cleartext password request", tag, body)
+	}
+	pw := append([]byte(token), 0)
+	c.Write(append(append([]byte{'p'}, binary.BigEndian.AppendUint32(nil, uint32(len(pw)+4))...), pw...))
+	tag, body = read()
+	return c, r, tag, body
+}
+
+// The request's case: the client proves a token, the relay logs in as the
+// lane's role with a password the client never sees, and the session is
+// the token holder's.
+func TestBrokeredLoginUsesTheRelaysCredential(t *testing.T) {
+	up := newSCRAMUpstream(t, "app", "s3cret-db-password")
+	sink := audit.NewMemorySink(64)
+	srv := startServer(t, proxy.Config{
+		Upstream: up.ln.Addr().String(),
+		Protocol: hoopinspect.Postgres,
+		Audit:    sink,
+		Broker:   tokenBroker,
+	})
+
+	c, r, tag, body := brokeredClient(t, srv.Addr().String(), "alice", "alice-token")
+	if tag != 'R' || binary.BigEndian.Uint32(body) != 0 {
+		t.Fatalf("relay answered the token with %q %q, want AuthenticationOk", tag, body)
+	}
+	var z [6]byte
+	if _, err := io.ReadFull(r, z[:]); err != nil || z[0] != 'Z' {
+		t.Fatalf("after AuthenticationOk got %q, %v; want the upstream's ReadyForQuery", z, err)
+	}
+	q := pgQuery("SELECT 1")
+	c.Write(q)
+	c.Close()
+
+	events := waitForEnd(sink)
+	if !hasKind(events, audit.KindStatement) {
+		t.Fatal("no statement recorded")
+	}
+	// session_start is written before the login, as on every pgwire lane;
+	// everything after it carries the holder.
+	for _, ev := range events {
+		if ev.Kind != audit.KindSessionStart && ev.Principal != "alice" {
+			t.Errorf("event %s has principal %q, want the token's holder", ev.Kind, ev.Principal)
+		}
+	}
+	startups, logins, after := up.snapshot()
+	if logins != 1 || len(startups) != 1 || startups[0] != "app" {
+		t.Errorf("upstream saw startups %q and %d logins, want one as app", startups, logins)
+	}
+	if !bytes.Equal(after, q) {
+		t.Errorf("upstream received %q after login, want only the query", after)
+	}
+}
+
+// A wrong token is refused by the relay, and costs the upstream nothing.
+func TestBrokeredLoginRefusesAnUnknownToken(t *testing.T) {
+	up := newSCRAMUpstream(t, "app", "s3cret-db-password")
+	sink := audit.NewMemorySink(64)
+	srv := startServer(t, proxy.Config{
+		Upstream: up.ln.Addr().String(),
+		Protocol: hoopinspect.Postgres,
+		Audit:    sink,
+		Broker:   tokenBroker,
+	})
+
+	c, _, tag, body := brokeredClient(t, srv.Addr().String(), "alice", "guess")
+	defer c.Close()
+	if tag != 'E' || !bytes.Contains(body, []byte("28P01")) {
+		t.Errorf("relay answered a bad token with %q %q, want a 28P01 FATAL", tag, body)
+	}
+	events := waitForEnd(sink)
+	var refused bool
+	for _, ev := range events {
+		refused = refused || (ev.Kind == audit.KindError && strings.Contains(ev.Error, "credential refused"))
+	}
+	if !refused {
+		t.Error("the refused login left no error in the audit trail")
+	}
+	if startups, _, _ := up.snapshot(); len(startups) != 0 {
+		t.Errorf("a refused client reached the upstream: %q", startups)
+	}
+}
+
+// The relay's password is wrong for the upstream: the client gets the
+// upstream's refusal, and no session opens.
+func TestBrokeredLoginReportsTheUpstreamsRefusal(t *testing.T) {
+	up := newSCRAMUpstream(t, "app", "rotated-password")
+	srv := startServer(t, proxy.Config{
+		Upstream: up.ln.Addr().String(),
+		Protocol: hoopinspect.Postgres,
+		Broker:   tokenBroker,
+	})
+	c, _, tag, body := brokeredClient(t, srv.Addr().String(), "alice", "alice-token")
+	defer c.Close()
+	if tag != 'E' || !bytes.Contains(body, []byte("password authentication failed")) {
+		t.Errorf("relay answered with %q %q, want the upstream's error", tag, body)
+	}
+	if _, logins, _ := up.snapshot(); logins != 0 {
+		t.Error("upstream logged in with the wrong password")
+	}
+}
+
+func TestBrokerNeedsPostgres(t *testing.T) {
+	_, err := proxy.NewServer(proxy.Config{
+		Listen: "127.0.0.1:0", Upstream: "db:3306", Protocol: hoopinspect.MySQL, Broker: tokenBroker,
+	})
+	if err == nil {
+		t.Error("a broker on a mysql lane was accepted")
+	}
+	if errors.Is(err, proxy.ErrLoginRefused) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
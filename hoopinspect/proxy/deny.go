@@ -3,7 +3,10 @@ package proxy
 import (
 	"encoding/binary"
 	"fmt"
+	"math"
+	"strconv"
 	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/hoophq/hoopinspect"
 )
@@ -27,6 +30,12 @@ import (
 type ProtocolDenyWriter struct{}
 
 // Deny implements DenyWriter.
+//
+// MongoDB gets no frame here. Its error is a reply to one request, and
+// without the statement there is no request id to answer; DenyStatement
+// builds it. gRPC gets the connection-level half of GRPCStatus: with no
+// call to answer, the client learns why the connection ended and nothing
+// more.
 func (ProtocolDenyWriter) Deny(proto hoopinspect.Protocol, dir hoopinspect.Direction, msg string) []byte {
 	if msg == "" {
 		msg = "denied by policy"
@@ -36,12 +45,160 @@ func (ProtocolDenyWriter) Deny(proto hoopinspect.Protocol, dir hoopinspect.Direc
 		return PostgresError(msg)
 	case hoopinspect.MSSQL:
 		return MSSQLError(msg)
+	case hoopinspect.MySQL:
+		return MySQLError(msg)
 	case hoopinspect.HTTP:
 		return HTTPForbidden(msg)
+	case hoopinspect.GRPC:
+		return GRPCStatus(msg, 0)
 	}
 	return nil
 }
 
+// DenyStatement implements StatementDenyWriter. Every protocol but MongoDB
+// and gRPC renders the same frame Deny does.
+//
+// A gRPC denial of a request ends the call on the stream the request opened,
+// with a status the client raises as that call's error.
+//
+// A MongoDB denial answers the request the statement came from: the
+// request's own id for a command, or the id a denied reply was answering.
+// Two requests get no frame at all. An OP_MSG sent with moreToCome, and the
+// legacy write opcodes, are unacknowledged writes; the driver reads nothing
+// back for them, and an unsolicited reply would sit in its socket to be
+// misread as the answer to whatever it sends next.
+func (w ProtocolDenyWriter) DenyStatement(proto hoopinspect.Protocol, dir hoopinspect.Direction, msg string, stmt hoopinspect.Statement) []byte {
+	if proto == hoopinspect.GRPC && dir == hoopinspect.FromClient {
+		if msg == "" {
+			msg = "denied by policy"
+		}
+		id, err := strconv.ParseUint(stmt.Metadata["grpc.stream_id"], 10, 31)
+		if err != nil {
+			return w.Deny(proto, dir, msg)
+		}
+		return GRPCStatus(msg, uint32(id))
+	}
+	if proto != hoopinspect.MongoDB {
+		return w.Deny(proto, dir, msg)
+	}
+	if msg == "" {
+		msg = "denied by policy"
+	}
+	md := stmt.Metadata
+	if md["mongodb.more_to_come"] == "true" {
+		return nil
+	}
+	id := md["mongodb.request_id"]
+	if dir == hoopinspect.FromServer {
+		id = md["mongodb.response_to"]
+	}
+	requestID, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return nil
+	}
+	switch md["mongodb.opcode"] {
+	case "OP_MSG":
+		return MongoDBError(msg, int32(requestID), false)
+	case "OP_QUERY", "OP_REPLY":
+		return MongoDBError(msg, int32(requestID), true)
+	}
+	return nil
+}
+
+// MongoDB wire constants for a synthesized command failure.
+// https://www.mongodb.com/docs/manual/reference/mongodb-wire-protocol/
+const (
+	mongoOpReply = 1
+	mongoOpMsg   = 2013
+
+	// mongoQueryFailure is OP_REPLY's flag for a failed query.
+	mongoQueryFailure = 1 << 1
+
+	// mongoUnauthorized is error code 13, which drivers surface as an
+	// ordinary command failure and never retry.
+	mongoUnauthorized     int32 = 13
+	mongoUnauthorizedName       = "Unauthorized"
+
+	// maxMongoMessageChars keeps an operator-authored message to a size no
+	// driver's error display chokes on. The server's own limit is far
+	// larger.
+	maxMongoMessageChars = 4000
+)
+
+// MongoDBError builds a failed command reply to the request with id
+// responseTo. The body is the document the server itself sends on an
+// authorization failure:
+//
+//	{ok: 0.0, errmsg: "...", code: 13, codeName: "Unauthorized"}
+//
+// A driver matches the reply to its request by responseTo and raises it as
+// a command error, so mongosh prints
+//
+//	MongoServerError[Unauthorized]: destructive commands are not permitted
+//
+// legacy answers an OP_QUERY with OP_REPLY, which is how drivers send their
+// first hello and older ones send everything. The reply sets QueryFailure
+// and carries `$err` as well, the field a pre-3.6 driver reads.
+func MongoDBError(msg string, responseTo int32, legacy bool) []byte {
+	msg = truncateChars(msg, maxMongoMessageChars)
+
+	var doc []byte
+	doc = binary.LittleEndian.AppendUint32(doc, 0) // length, patched below
+	doc = bsonDouble(doc, "ok", 0)
+	doc = bsonString(doc, "errmsg", msg)
+	if legacy {
+		doc = bsonString(doc, "$err", msg)
+	}
+	doc = bsonInt32(doc, "code", mongoUnauthorized)
+	doc = bsonString(doc, "codeName", mongoUnauthorizedName)
+	doc = append(doc, 0)
+	binary.LittleEndian.PutUint32(doc, uint32(len(doc)))
+
+	var body []byte
+	opCode := int32(mongoOpMsg)
+	if legacy {
+		opCode = mongoOpReply
+		body = binary.LittleEndian.AppendUint32(body, mongoQueryFailure)
+		body = binary.LittleEndian.AppendUint64(body, 0) // cursorID
+		body = binary.LittleEndian.AppendUint32(body, 0) // startingFrom
+		body = binary.LittleEndian.AppendUint32(body, 1) // numberReturned
+	} else {
+		body = binary.LittleEndian.AppendUint32(body, 0) // flagBits
+		body = append(body, 0)                           // section kind 0: the body
+	}
+	body = append(body, doc...)
+
+	out := make([]byte, 0, 16+len(body))
+	out = binary.LittleEndian.AppendUint32(out, uint32(16+len(body)))
+	out = binary.LittleEndian.AppendUint32(out, 0) // requestID
+	out = binary.LittleEndian.AppendUint32(out, uint32(responseTo))
+	out = binary.LittleEndian.AppendUint32(out, uint32(opCode))
+	return append(out, body...)
+}
+
+func bsonDouble(b []byte, key string, v float64) []byte {
+	b = append(b, 0x01)
+	b = append(b, key...)
+	b = append(b, 0)
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+}
+
+func bsonString(b []byte, key, v string) []byte {
+	b = append(b, 0x02)
+	b = append(b, key...)
+	b = append(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(v)+1))
+	b = append(b, v...)
+	return append(b, 0)
+}
+
+func bsonInt32(b []byte, key string, v int32) []byte {
+	b = append(b, 0x10)
+	b = append(b, key...)
+	b = append(b, 0)
+	return binary.LittleEndian.AppendUint32(b, uint32(v))
+}
+
 // TDS token-stream constants for a synthesized server error.
 // https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/9805e9fa-1f8b-4cf8-8f78-8d2602228635
 const (
@@ -138,6 +295,64 @@ func ucs2(s string) []byte {
 	return out
 }
 
+// MySQL ERR_Packet constants for a synthesized server error.
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_basic_err_packet.html
+const (
+	mysqlErrHeader byte = 0xFF
+
+	// mysqlErrCode 1227 is ER_SPECIFIC_ACCESS_DENIED_ERROR, and 42000 its
+	// SQLSTATE: the server's own "you may not do this", which every driver
+	// surfaces as an ordinary error rather than a protocol failure.
+	mysqlErrCode  uint16 = 1227
+	mysqlSQLState        = "42000"
+
+	// maxMySQLMessage keeps the packet inside a single 3-byte length, so an
+	// operator-authored message of any size still produces one valid frame.
+	// The server truncates its own messages far shorter than this.
+	maxMySQLMessage = 8 << 10
+)
+
+// MySQLError builds an ERR_Packet, framed as a reply to the command the
+// client just sent:
+//
+//	int<3>     payload length
+//	int<1>     sequence id 1: the command was 0, so its reply is 1
+//	int<1>     0xFF
+//	int<2>     error code
+//	string[1]  '#', the SQLSTATE marker
+//	string[5]  SQLSTATE
+//	string     message
+//
+// The mysql client prints it as
+//
+//	ERROR 1227 (42000): destructive statements are not permitted on appdb
+//
+// and reports the lost connection on the next command, which is the truth.
+// A denial raised mid-response arrives at a sequence id the client does not
+// expect; it reports the packet as out of order, and the socket closes
+// either way.
+func MySQLError(msg string) []byte {
+	if len(msg) > maxMySQLMessage {
+		// Cut on a character boundary, never mid-rune, so the client is not
+		// handed an invalid encoding.
+		cut := maxMySQLMessage
+		for cut > 0 && !utf8.RuneStart(msg[cut]) {
+			cut--
+		}
+		msg = msg[:cut]
+	}
+	payload := make([]byte, 0, 9+len(msg))
+	payload = append(payload, mysqlErrHeader)
+	payload = binary.LittleEndian.AppendUint16(payload, mysqlErrCode)
+	payload = append(payload, '#')
+	payload = append(payload, mysqlSQLState...)
+	payload = append(payload, msg...)
+
+	out := make([]byte, 0, 4+len(payload))
+	out = append(out, byte(len(payload)), byte(len(payload)>>8), byte(len(payload)>>16), 1)
+	return append(out, payload...)
+}
+
 // PostgresError builds an ErrorResponse ('E') message.
 //
 // Wire format: each field is a one-byte type code and a NUL-terminated value,
@@ -169,6 +384,31 @@ func PostgresError(msg string) []byte {
 	return append(out, body...)
 }
 
+// PostgresNotice builds a NoticeResponse ('N') message.
+//
+// A notice is the one message a Postgres server may send at any point in the
+// conversation, and clients print it and keep reading, which makes it the
+// only way to tell a psql user their statement is waiting rather than hung.
+func PostgresNotice(msg string) []byte {
+	var body []byte
+	field := func(code byte, val string) {
+		body = append(body, code)
+		body = append(body, val...)
+		body = append(body, 0)
+	}
+
+	field('S', "NOTICE")
+	field('V', "NOTICE")
+	field('C', "00000") // successful_completion: a notice reports no failure
+	field('M', msg)
+	body = append(body, 0)
+
+	out := make([]byte, 0, 5+len(body))
+	out = append(out, 'N')
+	out = binary.BigEndian.AppendUint32(out, uint32(len(body)+4))
+	return append(out, body...)
+}
+
 // HTTPForbidden builds a 403 response.
 //
 // It sets Connection: close because the caller is about to drop the socket.
@@ -184,3 +424,110 @@ func HTTPForbidden(msg string) []byte {
 			"Connection: close\r\n"+
 			"\r\n%s", len(body), body))
 }
+
+// HTTP/2 and gRPC constants for a synthesized status.
+// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+const (
+	h2FrameHeaders  = 0x1
+	h2FrameSettings = 0x4
+	h2FrameGoAway   = 0x7
+
+	h2EndStream  = 0x1
+	h2EndHeaders = 0x4
+
+	// grpcPermissionDenied is status 7, which every gRPC library raises as
+	// an ordinary call failure and none retries.
+	grpcPermissionDenied = "7"
+
+	// maxGRPCMessageChars keeps the percent-encoded message, at up to
+	// twelve bytes a character, inside one HEADERS frame of the 16 KiB
+	// every HTTP/2 peer accepts.
+	maxGRPCMessageChars = 1000
+)
+
+// GRPCStatus ends the call on streamID with PERMISSION_DENIED and the
+// operator's message, and closes the connection:
+//
+//	SETTINGS  empty, in case the server has not sent its own yet: a client
+//	          reads nothing before the server's SETTINGS
+//	HEADERS   on streamID, ending it: a trailers-only response,
+//	          :status 200, grpc-status 7, grpc-message
+//	GOAWAY    NO_ERROR, the message as debug data
+//
+// grpcurl prints it as
+//
+//	ERROR:
+//	  Code: PermissionDenied
+//	  Message: the admin service is not reachable through this proxy
+//
+// A streamID of 0 leaves out the HEADERS frame, for a denial that answers no
+// call. The GOAWAY names the highest stream id there is, so a client treats
+// every call in flight as possibly processed and does not replay one that
+// was.
+//
+// The header block uses only the static table and literals that are not
+// indexed, so it decodes whatever the server's encoder has left in the
+// client's dynamic table.
+func GRPCStatus(msg string, streamID uint32) []byte {
+	msg = truncateChars(msg, maxGRPCMessageChars)
+
+	out := h2Frame(nil, h2FrameSettings, 0, 0, nil)
+	if streamID != 0 {
+		block := []byte{0x88} // :status 200, static index 8
+		// content-type, name at static index 31: 0000 1111, then 31-15.
+		block = append(block, 0x0f, 0x10)
+		block = hpackLiteral(block, "application/grpc")
+		for _, f := range [][2]string{
+			{"grpc-status", grpcPermissionDenied},
+			{"grpc-message", grpcPercentEncode(msg)},
+		} {
+			block = append(block, 0x00) // literal without indexing, new name
+			block = hpackLiteral(block, f[0])
+			block = hpackLiteral(block, f[1])
+		}
+		out = h2Frame(out, h2FrameHeaders, h2EndStream|h2EndHeaders, streamID, block)
+	}
+
+	goaway := binary.BigEndian.AppendUint32(nil, 0x7fffffff) // last stream id
+	goaway = binary.BigEndian.AppendUint32(goaway, 0)        // NO_ERROR
+	goaway = append(goaway, msg...)
+	return h2Frame(out, h2FrameGoAway, 0, 0, goaway)
+}
+
+func h2Frame(dst []byte, typ, flags byte, stream uint32, payload []byte) []byte {
+	n := len(payload)
+	dst = append(dst, byte(n>>16), byte(n>>8), byte(n), typ, flags)
+	dst = binary.BigEndian.AppendUint32(dst, stream)
+	return append(dst, payload...)
+}
+
+// hpackLiteral appends a raw (not Huffman-coded) HPACK string.
+func hpackLiteral(dst []byte, s string) []byte {
+	n := len(s)
+	if n < 127 {
+		dst = append(dst, byte(n))
+	} else {
+		dst = append(dst, 127)
+		for n -= 127; n >= 128; n >>= 7 {
+			dst = append(dst, byte(n&0x7f|0x80))
+		}
+		dst = append(dst, byte(n))
+	}
+	return append(dst, s...)
+}
+
+// grpcPercentEncode encodes a grpc-message value: every byte outside
+// printable ASCII, and '%' itself, as %XX over the UTF-8 encoding.
+func grpcPercentEncode(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			b = append(b, '%', hex[c>>4], hex[c&0xf])
+			continue
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
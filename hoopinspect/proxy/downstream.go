@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/session"
 )
 
 // Postgres sends three untagged packets before normal message flow, each an
@@ -242,3 +244,50 @@ type bufferedConn struct {
 }
 
 func (c *bufferedConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+
+// peerCertificate returns the client certificate a downstream handshake
+// verified, or nil when the connection is not TLS or the client presented
+// none.
+func peerCertificate(c net.Conn) *x509.Certificate {
+	tc := tlsConnOf(c)
+	if tc == nil {
+		return nil
+	}
+	chains := tc.ConnectionState().VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil
+	}
+	return chains[0][0]
+}
+
+// tlsConnOf unwraps the connection negotiateDownstream returned to the TLS
+// session under it, or nil when the client did not negotiate one.
+func tlsConnOf(c net.Conn) *tls.Conn {
+	if bc, ok := c.(*bufferedConn); ok {
+		c = bc.Conn
+	}
+	tc, _ := c.(*tls.Conn)
+	return tc
+}
+
+// fillIdentity copies into id what from knows and id does not.
+func fillIdentity(id *session.Identity, from session.Identity) {
+	if id.Subject == "" {
+		id.Subject = from.Subject
+	}
+	if id.Email == "" {
+		id.Email = from.Email
+	}
+	if len(id.Groups) == 0 {
+		id.Groups = from.Groups
+	}
+	for k, v := range from.Attributes {
+		if _, ok := id.Attributes[k]; ok {
+			continue
+		}
+		if id.Attributes == nil {
+			id.Attributes = make(map[string]string, len(from.Attributes))
+		}
+		id.Attributes[k] = v
+	}
+}
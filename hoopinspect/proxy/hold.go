@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/gate"
+)
+
+// holdInterval is how often a held session is kept alive.
+const holdInterval = 15 * time.Second
+
+// holdKeepalive keeps a session alive while one of its statements waits for
+// an approver.
+//
+// A held statement stops the client pump, and nothing else on the
+// connection moves: the client sent its statement and waits, the upstream
+// was sent nothing and says nothing. Left alone, the relay's own IdleTimeout
+// closes the upstream half in the middle of somebody's decision. The
+// keepalive pushes the upstream read deadline forward for as long as the
+// hold lasts, and on a Postgres lane it also sends the client a notice, which
+// psql prints and which keeps any idle timer between the two sides from
+// firing.
+//
+// Other protocols get no message. MySQL, MSSQL and MongoDB have no frame a
+// server may send unprompted, and an HTTP client is waiting for exactly one
+// response. A gRPC client would answer an HTTP/2 PING with an ACK the
+// upstream never asked for. They rely on TCP keepalives, which Go enables on
+// every connection it dials or accepts.
+//
+// The notice goes to the client on the same socket the server pump writes
+// responses to, so the two share mu: a notice lands between two writes, and
+// only when the gate says the last one ended a message.
+type holdKeepalive struct {
+	proto hoopinspect.Protocol
+	idle  time.Duration
+	log   *slog.Logger
+
+	mu sync.Mutex
+
+	// Set by handle once the broker and TLS steps have settled which
+	// connections the pumps use, and before either starts. hold only runs
+	// inside a pump.
+	g                *gate.Gate
+	client, upstream net.Conn
+}
+
+// hold is gate.Config.Hold.
+func (k *holdKeepalive) hold(stmt hoopinspect.Statement) (resume func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	k.log.Info("statement held for approval", "operation", stmt.Operation)
+	go func() {
+		defer wg.Done()
+		tick := time.NewTicker(holdInterval)
+		defer tick.Stop()
+		for {
+			k.keepalive()
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (k *holdKeepalive) keepalive() {
+	if k.idle > 0 {
+		_ = k.upstream.SetReadDeadline(time.Now().Add(k.idle + holdInterval))
+	}
+	if k.proto != hoopinspect.Postgres {
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.g.ResponseAtBoundary() {
+		return // a result set is mid-message; the next tick tries again
+	}
+	_ = k.client.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	_, _ = k.client.Write(PostgresNotice("statement is waiting for approval"))
+	_ = k.client.SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,136 @@
+package proxy
+
+import "bytes"
+
+// The MySQL server speaks first, with a greeting that advertises what it
+// supports. Only the fields up to the low capability word matter here:
+//
+//	int<3>     payload length
+//	int<1>     sequence id, 0
+//	int<1>     protocol version, 10
+//	string     server version, NUL-terminated
+//	int<4>     connection id
+//	string[8]  auth-plugin-data-part-1
+//	int<1>     filler
+//	int<2>     capability flags, lower 16 bits
+//
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_connection_phase_packets_protocol_handshake_v10.html
+const (
+	mysqlHeaderLen        = 4
+	mysqlGreetingV10 byte = 0x0a
+	mysqlClientSSL        = 0x0800
+
+	// maxGreetingFrame bounds what greetingReassembler will hold. A real
+	// greeting is about eighty bytes; the cap stops a server that declares
+	// 16 MiB from making the relay buffer it.
+	maxGreetingFrame = 8 << 10
+)
+
+// stripSSLOffer clears CLIENT_SSL in a MySQL server greeting, returning the
+// rewritten frame and whether anything changed.
+//
+// # Why the offer cannot be relayed
+//
+// A client that sees CLIENT_SSL answers with an SSLRequest and starts a TLS
+// handshake, and it does so by DEFAULT: the mysql client and most drivers run
+// with ssl-mode=PREFERRED, and every MySQL server since 5.7 generates a
+// certificate at first start. The handshake would run between the client and
+// the server straight through the relay, and every byte after it would be
+// ciphertext the codec refuses with ErrStreamUnsafe. Every default connection
+// would fail.
+//
+// Withdrawing the offer changes nothing a client that merely prefers TLS can
+// object to: it proceeds in plaintext, the same as against a server with
+// ssl=0. A client that requires TLS fails with its own, accurate error
+// ("SSL is required, but the server does not support it"), which is better
+// than a lane that comes up and enforces nothing.
+//
+// The flag is a bit in a fixed-width field, so the frame keeps its length and
+// nothing after it moves.
+func stripSSLOffer(frame []byte) ([]byte, bool) {
+	if len(frame) < mysqlHeaderLen+1 || frame[mysqlHeaderLen] != mysqlGreetingV10 {
+		return frame, false
+	}
+	size := int(frame[0]) | int(frame[1])<<8 | int(frame[2])<<16
+	body := frame[mysqlHeaderLen:min(len(frame), mysqlHeaderLen+size)]
+
+	nul := bytes.IndexByte(body[1:], 0)
+	if nul < 0 {
+		return frame, false
+	}
+	// version NUL, connection id, auth data part 1, filler
+	off := 1 + nul + 1 + 4 + 8 + 1
+	if off+2 > len(body) {
+		return frame, false
+	}
+	caps := uint16(body[off]) | uint16(body[off+1])<<8
+	if caps&mysqlClientSSL == 0 {
+		return frame, false
+	}
+	caps &^= mysqlClientSSL
+
+	out := append([]byte(nil), frame...)
+	out[mysqlHeaderLen+off] = byte(caps)
+	out[mysqlHeaderLen+off+1] = byte(caps >> 8)
+	return out, true
+}
+
+// greetingReassembler holds a MySQL greeting split across reads until it is
+// whole, so stripSSLOffer is handed the field it rewrites.
+//
+// It mirrors saslReassembler, for the same reason: the flag sits twenty-odd
+// bytes into the stream and a Read boundary can land before it. A missed
+// rewrite is a client that upgrades to TLS and a session that fails closed,
+// intermittently. Only the first server frame is ever held; the greeting is
+// the first thing a MySQL server sends, so nothing after it is buffered.
+type greetingReassembler struct {
+	pending []byte
+	done    bool
+}
+
+// feed takes one read from the server and returns the bytes to forward, plus
+// whether the offer was stripped from them. An empty return means a partial
+// greeting is being held.
+func (r *greetingReassembler) feed(chunk []byte) (out []byte, stripped bool) {
+	if r.done || len(chunk) == 0 {
+		return chunk, false
+	}
+
+	data := chunk
+	if len(r.pending) > 0 {
+		r.pending = append(r.pending, chunk...)
+		data = r.pending
+	}
+
+	if len(data) < mysqlHeaderLen {
+		return r.hold(data), false
+	}
+	total := mysqlHeaderLen + (int(data[0]) | int(data[1])<<8 | int(data[2])<<16)
+	if total > maxGreetingFrame {
+		// Not a greeting worth waiting for. Forward it and let the client's
+		// own parser be the authority on its protocol.
+		return r.release(data), false
+	}
+	if total > len(data) {
+		return r.hold(data), false
+	}
+
+	rewritten, changed := stripSSLOffer(data)
+	return r.release(rewritten), changed
+}
+
+// hold keeps a partial frame until the rest of it arrives.
+func (r *greetingReassembler) hold(data []byte) []byte {
+	if len(r.pending) == 0 {
+		// data aliases pump's read buffer, which the next Read overwrites.
+		r.pending = append([]byte(nil), data...)
+	}
+	return nil
+}
+
+// release forwards data and retires the reassembler.
+func (r *greetingReassembler) release(data []byte) []byte {
+	r.done = true
+	r.pending = nil
+	return data
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"bytes"
+	"testing"
+)
+
+// mysqlGreeting builds a HandshakeV10 packet with the given low capability
+// word, followed by enough of the rest to look like the real thing.
+func mysqlGreeting(caps uint16) []byte {
+	var body []byte
+	body = append(body, mysqlGreetingV10)
+	body = append(body, "8.0.36"...)
+	body = append(body, 0)
+	body = append(body, 7, 0, 0, 0)    // connection id
+	body = append(body, "abcdefgh"...) // auth-plugin-data-part-1
+	body = append(body, 0)             // filler
+	body = append(body, byte(caps), byte(caps>>8))
+	body = append(body, 0xff)                // character set
+	body = append(body, 2, 0)                // status flags
+	body = append(body, 0xff, 0xdf)          // capability flags, upper
+	body = append(body, 21)                  // auth data length
+	body = append(body, make([]byte, 10)...) // reserved
+	body = append(body, "ijklmnopqrst\x00"...)
+	body = append(body, "caching_sha2_password\x00"...)
+
+	out := []byte{byte(len(body)), byte(len(body) >> 8), byte(len(body) >> 16), 0}
+	return append(out, body...)
+}
+
+// The offer sits past a variable-length version string, so a Read boundary
+// can land anywhere before it. Every split must produce the same bytes.
+func TestGreetingReassemblyStripsSSLAcrossEverySplit(t *testing.T) {
+	in := mysqlGreeting(0xffff) // CLIENT_SSL among everything else
+	whole, changed := stripSSLOffer(in)
+	if !changed {
+		t.Fatal("the unsplit greeting was not stripped; the fixture is wrong")
+	}
+	if !bytes.Equal(whole, mysqlGreeting(0xffff&^mysqlClientSSL)) {
+		t.Fatalf("stripped greeting = %x", whole)
+	}
+
+	for size := 1; size <= len(in); size++ {
+		var (
+			r        greetingReassembler
+			out      []byte
+			stripped bool
+		)
+		for i := 0; i < len(in); i += size {
+			got, ch := r.feed(in[i:min(i+size, len(in))])
+			out = append(out, got...)
+			stripped = stripped || ch
+		}
+		if !stripped || !bytes.Equal(out, whole) {
+			t.Fatalf("%d-byte reads: forwarded %x, want %x", size, out, whole)
+		}
+	}
+}
+
+// A greeting with no offer passes through untouched, and after the first
+// frame nothing is held: a result set is not a greeting.
+func TestGreetingReassemblyRetiresAfterTheFirstFrame(t *testing.T) {
+	var r greetingReassembler
+	plain := mysqlGreeting(0xffff &^ mysqlClientSSL)
+	if out, changed := r.feed(plain); changed || !bytes.Equal(out, plain) {
+		t.Fatalf("a greeting without CLIENT_SSL was altered: %x", out)
+	}
+
+	later := mysqlGreeting(0xffff)[:10]
+	if out, changed := r.feed(later); changed || !bytes.Equal(out, later) {
+		t.Errorf("a post-greeting chunk was held or altered: %x", out)
+	}
+}
+
+// The length is the server's claim. One too large to be a greeting is
+// forwarded rather than buffered.
+func TestGreetingReassemblyRefusesAnAbsurdLength(t *testing.T) {
+	msg := []byte{0xff, 0xff, 0xff, 0, mysqlGreetingV10}
+
+	var r greetingReassembler
+	out, changed := r.feed(msg)
+	if changed || !bytes.Equal(out, msg) {
+		t.Errorf("held or altered the frame: %x", out)
+	}
+}
@@ -19,6 +19,7 @@ package proxy
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -50,6 +51,21 @@ type DenyWriter interface {
 	Deny(proto hoopinspect.Protocol, dir hoopinspect.Direction, message string) []byte
 }
 
+// StatementDenyWriter is a DenyWriter that can also answer the statement a
+// denial is about. The pump prefers it whenever the gate names one.
+//
+// It exists for protocols whose error frame is a reply to a specific
+// request. A MongoDB driver matches every reply to a request by the id it
+// echoes, and discards one that answers nothing; a frame built without the
+// statement would be read as noise and the user would see a dropped socket
+// after all.
+type StatementDenyWriter interface {
+	DenyWriter
+
+	// DenyStatement renders message as the reply to stmt.
+	DenyStatement(proto hoopinspect.Protocol, dir hoopinspect.Direction, message string, stmt hoopinspect.Statement) []byte
+}
+
 // Config configures a Server.
 type Config struct {
 	// Listen is the address to accept on ("0.0.0.0:15432", or a path when
@@ -91,9 +107,23 @@ type Config struct {
 	Audit  audit.Sink
 	Masker gate.Masker
 
+	// Limits and ObserveLimits are passed through the same way; see
+	// gate.Config.Limits.
+	Limits        []policy.ResultLimit
+	ObserveLimits bool
+
+	// Live, when set, replaces Policy, Masker and the limits for every
+	// connection on this server, open ones included; see gate.Live.
+	Live *gate.Live
+
 	// FailOnAuditError makes a failed audit write deny the statement.
 	FailOnAuditError bool
 
+	// Approver answers for statements a holding rule parks; see
+	// gate.Config.Approver. While one waits, the relay keeps the session
+	// alive (holdKeepalive). Live supplies it instead when set.
+	Approver policy.Approver
+
 	// DenyWriter renders denials in-protocol. Optional.
 	DenyWriter DenyWriter
 
@@ -106,6 +136,33 @@ type Config struct {
 	// cert, or a credential token, and this function extracts it.
 	IdentityFn func(net.Conn) session.Identity
 
+	// ProxyProtocol requires every connection to open with a PROXY protocol
+	// v2 header, which is read before anything else. IdentityFn then
+	// receives a connection whose RemoteAddr is the client the header
+	// names, and ProxyHeaderOf returns the header, TLVs included.
+	//
+	// Required rather than accepted when present. A listener that took the
+	// header optionally would take it from anyone who can reach the port,
+	// and that is a client choosing its own identity. A connection without
+	// one is closed.
+	ProxyProtocol bool
+
+	// CertIdentity derives an identity from the client certificate a
+	// DownstreamTLS handshake verified. It runs after the handshake, which
+	// is after IdentityFn, and fills only what IdentityFn left empty.
+	CertIdentity func(*x509.Certificate) session.Identity
+
+	// Broker makes the relay a party to a postgres login rather than a pipe
+	// for it. The client's password goes to Broker, which names whose
+	// credential it is and the upstream login to make in its place; the
+	// relay then logs in upstream with SCRAM-SHA-256. See brokerLogin.
+	//
+	// The password crosses the client's leg in the clear, so with
+	// DownstreamTLS set a client that did not negotiate TLS is refused
+	// before it is asked for one. Without DownstreamTLS, only local clients
+	// should be able to reach the listener.
+	Broker func(user string, secret []byte) (Login, error)
+
 	// CodecFactory overrides how each connection's Gate builds its codecs.
 	// Nil uses the registry. See gate.Config.CodecFactory: it exists so a
 	// lane can turn on HTTP body capture, which the argument-free registry
@@ -159,6 +216,9 @@ func NewServer(cfg Config) (*Server, error) {
 	if _, err := hoopinspect.New(cfg.Protocol); err != nil {
 		return nil, fmt.Errorf("hoopinspect/proxy: %w", err)
 	}
+	if cfg.Broker != nil && cfg.Protocol != hoopinspect.Postgres {
+		return nil, fmt.Errorf("hoopinspect/proxy: a credential broker needs postgres, not %s", cfg.Protocol)
+	}
 	if cfg.Network == "" {
 		cfg.Network = "tcp"
 	}
@@ -327,6 +387,17 @@ func (s *Server) untrack(c net.Conn) {
 
 // handle relays one connection.
 func (s *Server) handle(ctx context.Context, client net.Conn) {
+	if s.cfg.ProxyProtocol {
+		pc, _, err := readProxyHeader(client, s.cfg.DialTimeout)
+		if err != nil {
+			// Not a session: nothing was said by anyone with an identity.
+			s.log.Warn("connection refused without a valid PROXY header",
+				"peer", client.RemoteAddr().String(), "error", err)
+			return
+		}
+		client = pc
+	}
+
 	identity := session.Identity{PeerAddr: client.RemoteAddr().String()}
 	if s.cfg.IdentityFn != nil {
 		identity = s.cfg.IdentityFn(client)
@@ -341,11 +412,17 @@ func (s *Server) handle(ctx context.Context, client net.Conn) {
 
 	log := s.log.With("session", string(sess.ID), "principal", identity.Principal())
 
+	keep := &holdKeepalive{proto: s.cfg.Protocol, idle: s.cfg.IdleTimeout, log: log}
 	g, err := gate.New(sess, gate.Config{
 		Protocol:         s.cfg.Protocol,
 		Policy:           s.cfg.Policy,
 		Audit:            s.cfg.Audit,
 		Masker:           s.cfg.Masker,
+		Limits:           s.cfg.Limits,
+		ObserveLimits:    s.cfg.ObserveLimits,
+		Live:             s.cfg.Live,
+		Approver:         s.cfg.Approver,
+		Hold:             keep.hold,
 		FailOnAuditError: s.cfg.FailOnAuditError,
 		CodecFactory:     s.cfg.CodecFactory,
 	})
@@ -403,12 +480,52 @@ func (s *Server) handle(ctx context.Context, client net.Conn) {
 	// Written here, before the pumps start, so the two pump goroutines only
 	// ever read it. An IdentityFn the operator supplied wins, because it saw
 	// a verified subject from the fronting proxy and this is a client claim.
+	//
+	// A client certificate the handshake verified sits between the two: it
+	// is proof rather than a claim, but of less than the fronting proxy's
+	// authentication when there is one.
+	if s.cfg.CertIdentity != nil {
+		if cert := peerCertificate(client); cert != nil {
+			fillIdentity(&sess.Identity, s.cfg.CertIdentity(cert))
+			log = log.With("principal", sess.Identity.Principal())
+		}
+	}
+
+	// A brokered login replaces the claim with the credential's holder, and
+	// a failed one is recorded: it is someone presenting a credential this
+	// relay was given to check. A client hanging up mid-login is not.
+	if s.cfg.Broker != nil {
+		var (
+			brokered bool
+			holder   session.Identity
+			err      error
+		)
+		client, upstream, holder, brokered, err = brokerLogin(
+			client, upstream, s.cfg.Broker, s.cfg.DownstreamTLS != nil, s.cfg.DialTimeout)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				log.Debug("client left during a brokered login", "error", err)
+				return
+			}
+			log.Warn("brokered login failed", "claimed_user", claimedUser, "error", err)
+			if s.cfg.Audit != nil {
+				_ = s.cfg.Audit.Write(ctx, audit.ErrorEvent(sess, err))
+			}
+			return
+		}
+		if brokered {
+			fillIdentity(&sess.Identity, holder)
+			claimedUser = ""
+			log = log.With("principal", sess.Identity.Principal())
+		}
+	}
 	if claimedUser != "" && sess.Identity.Subject == "" {
 		sess.Identity.Subject = claimedUser
 		log = log.With("principal", claimedUser)
 	}
 
 	log.Info("session opened", "upstream", s.cfg.Upstream)
+	keep.g, keep.client, keep.upstream, keep.log = g, client, upstream, log
 
 	// Both directions run concurrently; the first to finish tears down the
 	// other by closing its peer, which unblocks the pending Read.
@@ -418,12 +535,12 @@ func (s *Server) handle(ctx context.Context, client net.Conn) {
 	go func() {
 		defer wg.Done()
 		defer upstream.Close()
-		s.pump(ctx, g, client, upstream, hoopinspect.FromClient, log)
+		s.pump(ctx, g, &keep.mu, client, upstream, hoopinspect.FromClient, log)
 	}()
 	go func() {
 		defer wg.Done()
 		defer client.Close()
-		s.pump(ctx, g, upstream, client, hoopinspect.FromServer, log)
+		s.pump(ctx, g, &keep.mu, upstream, client, hoopinspect.FromServer, log)
 	}()
 
 	wg.Wait()
@@ -470,9 +587,14 @@ func (s *Server) dialUpstream(ctx context.Context) (net.Conn, error) {
 // On a denial it writes the in-protocol error (when a DenyWriter is
 // configured) and returns, which closes both halves via the deferred closes
 // in handle. It forwards nothing.
+//
+// The server direction holds respMu from inspecting a response to having
+// written it, which is what lets a held session's keepalive write to the
+// client between two responses; see holdKeepalive.
 func (s *Server) pump(
 	ctx context.Context,
 	g *gate.Gate,
+	respMu *sync.Mutex,
 	src, dst net.Conn,
 	dir hoopinspect.Direction,
 	log *slog.Logger,
@@ -483,6 +605,8 @@ func (s *Server) pump(
 	// masking bug. Only the server direction can hold anything.
 	if dir == hoopinspect.FromServer {
 		defer func() {
+			respMu.Lock()
+			defer respMu.Unlock()
 			if tail := g.FlushResponse(); len(tail) > 0 {
 				_, _ = dst.Write(tail)
 			}
@@ -498,6 +622,14 @@ func (s *Server) pump(
 		s.cfg.UpstreamTLS != nil {
 		sasl = &saslReassembler{}
 	}
+	// A MySQL greeting offers TLS that the client would negotiate straight
+	// through the relay; see stripSSLOffer. Every MySQL lane needs the
+	// offer withdrawn, since no configuration here can read a session the
+	// client encrypted itself.
+	var greeting *greetingReassembler
+	if dir == hoopinspect.FromServer && s.cfg.Protocol == hoopinspect.MySQL {
+		greeting = &greetingReassembler{}
+	}
 
 	buf := make([]byte, 32*1024)
 	for {
@@ -536,47 +668,32 @@ func (s *Server) pump(
 				}
 				chunk = stripped
 			}
-
-			var d gate.Decision
-			if dir == hoopinspect.FromClient {
-				d = g.Request(ctx, chunk)
-			} else {
-				d = g.Response(ctx, chunk)
-			}
-
-			if d.Err != nil {
-				log.Warn("inspection reported an error", "direction", string(dir), "error", d.Err)
-			}
-
-			if !d.Allowed {
-				s.denied.Add(1)
-				log.Info("statement denied",
-					"direction", string(dir), "rule", d.Rule, "message", d.Message)
-
-				// Deliver the reason to the CLIENT, whichever direction the
-				// denial came from: on a response denial the offending bytes
-				// travel toward the client, so the client needs to know why
-				// the connection ended.
-				if s.cfg.DenyWriter != nil {
-					target := dst
-					if dir == hoopinspect.FromClient {
-						target = src // the client is the source of a request
-					}
-					if frame := s.cfg.DenyWriter.Deny(s.cfg.Protocol, dir, d.Message); len(frame) > 0 {
-						_ = target.SetWriteDeadline(time.Now().Add(5 * time.Second))
-						_, _ = target.Write(frame)
+			if greeting != nil {
+				stripped, changed := greeting.feed(chunk)
+				if changed {
+					log.Debug("removed CLIENT_SSL from the server's greeting",
+						"reason", "a client-negotiated TLS session is unreadable to the gate")
+				}
+				if len(stripped) == 0 {
+					if readErr != nil {
+						log.Debug("server ended mid-greeting",
+							"error", readErr, "held", len(chunk))
+						return
 					}
+					continue // a partial greeting; wait for the rest
 				}
-				return
+				chunk = stripped
 			}
 
-			if len(d.Payload) > 0 {
-				if _, err := dst.Write(d.Payload); err != nil {
-					if !isClosed(err) {
-						log.Debug("forward failed", "direction", string(dir), "error", err)
-					}
-					return
-				}
+			if dir == hoopinspect.FromServer {
+				respMu.Lock()
+			}
+			more := s.relay(ctx, g, respMu, chunk, src, dst, dir, log)
+			if dir == hoopinspect.FromServer {
+				respMu.Unlock()
+			}
+			if !more {
+				return
 			}
 		}
 
@@ -589,6 +706,107 @@ func (s *Server) pump(
 	}
 }
 
+// relay runs one chunk through the gate and forwards what it allows,
+// reporting whether the pump should keep going.
+//
+// The server direction calls it holding respMu; the client direction takes
+// it only to write a gRPC denial (writeBetweenResponses).
+func (s *Server) relay(
+	ctx context.Context,
+	g *gate.Gate,
+	respMu *sync.Mutex,
+	chunk []byte,
+	src, dst net.Conn,
+	dir hoopinspect.Direction,
+	log *slog.Logger,
+) bool {
+	var d gate.Decision
+	if dir == hoopinspect.FromClient {
+		d = g.Request(ctx, chunk)
+	} else {
+		d = g.Response(ctx, chunk)
+	}
+
+	if d.Err != nil {
+		log.Warn("inspection reported an error", "direction", string(dir), "error", d.Err)
+	}
+
+	if !d.Allowed {
+		s.denied.Add(1)
+		log.Info("statement denied",
+			"direction", string(dir), "rule", d.Rule, "message", d.Message)
+
+		// Deliver the reason to the CLIENT, whichever direction the
+		// denial came from: on a response denial the offending bytes
+		// travel toward the client, so the client needs to know why
+		// the connection ended.
+		if s.cfg.DenyWriter != nil {
+			target := dst
+			if dir == hoopinspect.FromClient {
+				target = src // the client is the source of a request
+			}
+			var frame []byte
+			if sw, ok := s.cfg.DenyWriter.(StatementDenyWriter); ok && d.Offending != nil {
+				frame = sw.DenyStatement(s.cfg.Protocol, dir, d.Message, *d.Offending)
+			} else {
+				frame = s.cfg.DenyWriter.Deny(s.cfg.Protocol, dir, d.Message)
+			}
+			switch {
+			case len(frame) == 0:
+			case dir == hoopinspect.FromClient && s.cfg.Protocol == hoopinspect.GRPC:
+				writeBetweenResponses(g, respMu, target, frame, log)
+			default:
+				_ = target.SetWriteDeadline(time.Now().Add(5 * time.Second))
+				_, _ = target.Write(frame)
+			}
+		}
+		return false
+	}
+
+	if len(d.Payload) > 0 {
+		if _, err := dst.Write(d.Payload); err != nil {
+			if !isClosed(err) {
+				log.Debug("forward failed", "direction", string(dir), "error", err)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// denyBoundaryWait bounds how long a gRPC denial waits for the server pump
+// to finish the frame it is writing.
+const denyBoundaryWait = 2 * time.Second
+
+// writeBetweenResponses writes a request-side denial to the client between
+// two of the server's frames.
+//
+// On every other protocol the client is waiting for the answer to the
+// request just denied, so nothing is travelling toward it. HTTP/2
+// multiplexes: other calls' responses share the socket, one may be half
+// written, and a frame landing inside it corrupts the connection for every
+// call on it, the denied one included. So the denial waits, briefly, for the
+// server pump to end on a frame boundary; past that the connection closes
+// without it.
+func writeBetweenResponses(g *gate.Gate, respMu *sync.Mutex, client net.Conn, frame []byte, log *slog.Logger) {
+	deadline := time.Now().Add(denyBoundaryWait)
+	for {
+		respMu.Lock()
+		if g.ResponseAtBoundary() {
+			_ = client.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			_, _ = client.Write(frame)
+			respMu.Unlock()
+			return
+		}
+		respMu.Unlock()
+		if time.Now().After(deadline) {
+			log.Debug("denial not delivered", "reason", "the server never paused between frames")
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 // isClosed suppresses the routine teardown races between the two pump
 // goroutines closing each other's peer.
 func isClosed(err error) bool {
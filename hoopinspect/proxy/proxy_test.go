@@ -13,6 +13,7 @@ import (
 	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/hoophq/hoopinspect"
 	"github.com/hoophq/hoopinspect/audit"
@@ -236,6 +237,77 @@ func TestDeniedStatementNeverReachesUpstream(t *testing.T) {
 	}
 }
 
+// approveAfter approves every statement once delay has passed.
+type approveAfter time.Duration
+
+func (d approveAfter) Approve(ctx context.Context, _ policy.ApprovalRequest) (policy.Approval, error) {
+	select {
+	case <-time.After(time.Duration(d)):
+		return policy.Approval{Approved: true, Approver: "bob"}, nil
+	case <-ctx.Done():
+		return policy.Approval{}, ctx.Err()
+	}
+}
+
+// A held statement keeps its session: the client hears that it is waiting,
+// the idle timeout does not close the upstream under it, and once approved
+// the statement goes through on the same connection.
+func TestHeldStatementKeepsTheSessionAlive(t *testing.T) {
+	up := newEchoUpstream(t, nil)
+	hold, err := policy.NewRules([]policy.Rule{{
+		Name: "drop-needs-approval", Type: policy.MatchOperation,
+		Operations: []hoopinspect.Operation{hoopinspect.OpDrop},
+		Action:     policy.ActionApprove,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := startServer(t, proxy.Config{
+		Upstream:    up.addr(),
+		Protocol:    hoopinspect.Postgres,
+		Policy:      hold,
+		Approver:    approveAfter(300 * time.Millisecond),
+		IdleTimeout: 100 * time.Millisecond,
+		DenyWriter:  proxy.ProtocolDenyWriter{},
+	})
+
+	c, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	query := pgQuery("DROP TABLE staging")
+	if _, err := c.Write(query); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	c.SetReadDeadline(time.Now().Add(3 * time.Second))
+	notice := proxy.PostgresNotice("statement is waiting for approval")
+	got := make([]byte, len(notice)+len(query))
+	if _, err := io.ReadFull(c, got); err != nil {
+		t.Fatalf("read: %v (the session did not survive the hold)", err)
+	}
+	if !bytes.Equal(got[:len(notice)], notice) {
+		t.Errorf("first frame = %q, want the waiting notice", got[:len(notice)])
+	}
+	if !bytes.Equal(got[len(notice):], query) {
+		t.Errorf("then %q, want the approved statement echoed back", got[len(notice):])
+	}
+}
+
+func TestPostgresNoticeFrame(t *testing.T) {
+	frame := proxy.PostgresNotice("waiting")
+	if frame[0] != 'N' {
+		t.Fatalf("tag = %q, want 'N'", frame[0])
+	}
+	if declared := binary.BigEndian.Uint32(frame[1:5]); int(declared) != len(frame)-1 {
+		t.Errorf("declared length %d does not match frame length %d", declared, len(frame)-1)
+	}
+	if !bytes.Contains(frame, []byte("NOTICE")) || !bytes.Contains(frame, []byte("waiting")) {
+		t.Errorf("frame = %q, want a NOTICE carrying the message", frame)
+	}
+}
+
 func TestSessionAuditLifecycle(t *testing.T) {
 	up := newEchoUpstream(t, nil)
 	sink := audit.NewMemorySink(64)
@@ -481,6 +553,40 @@ func TestPostgresErrorFrame(t *testing.T) {
 	}
 }
 
+func TestMySQLErrorFrame(t *testing.T) {
+	frame := proxy.MySQLError("nope")
+
+	declared := int(frame[0]) | int(frame[1])<<8 | int(frame[2])<<16
+	if declared != len(frame)-4 {
+		t.Errorf("declared length %d does not match payload length %d", declared, len(frame)-4)
+	}
+	// Sequence id 1: the reply to a command the client sent at 0. Anything
+	// else and the client reports "packets out of order" instead of the
+	// message.
+	if frame[3] != 1 {
+		t.Errorf("sequence id = %d, want 1", frame[3])
+	}
+	if frame[4] != 0xFF {
+		t.Fatalf("header = %#x, want 0xFF (ERR_Packet)", frame[4])
+	}
+	if !bytes.HasPrefix(frame[7:], []byte("#42000nope")) {
+		t.Errorf("SQLSTATE and message = %q", frame[7:])
+	}
+}
+
+// An oversized message must still fit one packet, cut on a rune boundary.
+func TestMySQLErrorFrameBoundsTheMessage(t *testing.T) {
+	frame := proxy.MySQLError(strings.Repeat("é", 10<<10))
+
+	declared := int(frame[0]) | int(frame[1])<<8 | int(frame[2])<<16
+	if declared != len(frame)-4 {
+		t.Fatalf("declared length %d does not match payload length %d", declared, len(frame)-4)
+	}
+	if !utf8.Valid(frame[13:]) {
+		t.Error("truncation split a character")
+	}
+}
+
 func TestHTTPForbiddenFrame(t *testing.T) {
 	frame := string(proxy.HTTPForbidden("nope"))
 
@@ -500,10 +606,49 @@ func TestHTTPForbiddenFrame(t *testing.T) {
 	}
 }
 
+// The denial answers the call that was denied: a trailers-only response on
+// its stream, then GOAWAY so nothing else is sent on the connection.
+func TestGRPCStatusFrame(t *testing.T) {
+	stmt := hoopinspect.Statement{Metadata: map[string]string{"grpc.stream_id": "5"}}
+	frame := (proxy.ProtocolDenyWriter{}).DenyStatement(hoopinspect.GRPC, hoopinspect.FromClient, "no 100% access", stmt)
+
+	var types []byte
+	var headers []byte
+	for b := frame; len(b) > 0; {
+		if len(b) < 9 {
+			t.Fatalf("%d trailing bytes", len(b))
+		}
+		n := 9 + (int(b[0])<<16 | int(b[1])<<8 | int(b[2]))
+		types = append(types, b[3])
+		if b[3] == 0x1 {
+			if b[4] != 0x5 || binary.BigEndian.Uint32(b[5:]) != 5 {
+				t.Errorf("HEADERS flags %#x stream %d, want END_STREAM|END_HEADERS on 5",
+					b[4], binary.BigEndian.Uint32(b[5:]))
+			}
+			headers = b[9:n]
+		}
+		b = b[n:]
+	}
+	if string(types) != "\x04\x01\x07" {
+		t.Fatalf("frame types %x, want SETTINGS HEADERS GOAWAY", types)
+	}
+	if !bytes.Contains(headers, []byte("\x0bgrpc-status\x017")) {
+		t.Error("grpc-status 7 missing")
+	}
+	if !bytes.Contains(headers, []byte("no 100%25 access")) {
+		t.Errorf("grpc-message not percent-encoded: %q", headers)
+	}
+
+	// Without a call to answer only the connection is closed.
+	if f := (proxy.ProtocolDenyWriter{}).Deny(hoopinspect.GRPC, hoopinspect.FromClient, "x"); bytes.Contains(f, []byte("grpc-status")) {
+		t.Error("a denial with no stream id answered a call")
+	}
+}
+
 func TestDenyWriterDispatch(t *testing.T) {
 	w := proxy.ProtocolDenyWriter{}
 	for _, proto := range []hoopinspect.Protocol{
-		hoopinspect.Postgres, hoopinspect.HTTP,
+		hoopinspect.Postgres, hoopinspect.MSSQL, hoopinspect.MySQL, hoopinspect.HTTP, hoopinspect.GRPC,
 	} {
 		if len(w.Deny(proto, hoopinspect.FromClient, "x")) == 0 {
 			t.Errorf("%s produced no deny frame", proto)
@@ -512,9 +657,116 @@ func TestDenyWriterDispatch(t *testing.T) {
 	// A protocol with no shipped codec gets no frame: without a decoder
 	// there is no statement to explain a denial about, and emitting bytes a
 	// driver misparses is worse than closing.
-	if w.Deny(hoopinspect.Protocol("mongodb"), hoopinspect.FromClient, "x") != nil {
+	if w.Deny(hoopinspect.Protocol("redis"), hoopinspect.FromClient, "x") != nil {
 		t.Error("an unsupported protocol produced a deny frame")
 	}
+	// MongoDB's error answers one request, so without the statement there
+	// is nothing to address it to.
+	if w.Deny(hoopinspect.MongoDB, hoopinspect.FromClient, "x") != nil {
+		t.Error("mongodb produced a deny frame with no request to answer")
+	}
+}
+
+// mongoCommand builds an OP_MSG whose body is {<name>: <coll>, $db: "appdb"}.
+func mongoCommand(requestID uint32, name, coll string) []byte {
+	str := func(b []byte, key, v string) []byte {
+		b = append(b, 0x02)
+		b = append(b, key...)
+		b = append(b, 0)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(v)+1))
+		return append(append(b, v...), 0)
+	}
+	doc := str(str([]byte{0, 0, 0, 0}, name, coll), "$db", "appdb")
+	doc = append(doc, 0)
+	binary.LittleEndian.PutUint32(doc, uint32(len(doc)))
+
+	body := append([]byte{0, 0, 0, 0, 0}, doc...) // flagBits, section kind 0
+	out := binary.LittleEndian.AppendUint32(nil, uint32(16+len(body)))
+	out = binary.LittleEndian.AppendUint32(out, requestID)
+	out = binary.LittleEndian.AppendUint32(out, 0)
+	out = binary.LittleEndian.AppendUint32(out, 2013)
+	return append(out, body...)
+}
+
+// The frame must decode as what it claims to be: the MongoDB codec reads it
+// back as a failed command answering the request it names.
+func TestMongoDBErrorFrame(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		frame := proxy.MongoDBError("nope", 42, legacy)
+		if got := binary.LittleEndian.Uint32(frame); int(got) != len(frame) {
+			t.Errorf("legacy=%v: declared length %d, frame is %d", legacy, got, len(frame))
+		}
+		if got := binary.LittleEndian.Uint32(frame[8:]); got != 42 {
+			t.Errorf("legacy=%v: responseTo = %d, want 42", legacy, got)
+		}
+
+		insp, err := hoopinspect.New(hoopinspect.MongoDB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmts, err := insp.Inspect(hoopinspect.FromServer, frame)
+		if err != nil || len(stmts) != 1 {
+			t.Fatalf("legacy=%v: decoded %+v, %v", legacy, stmts, err)
+		}
+		if stmts[0].Text != "nope" {
+			t.Errorf("legacy=%v: message = %q", legacy, stmts[0].Text)
+		}
+		if !legacy && (stmts[0].Metadata["mongodb.error_code"] != "13" ||
+			stmts[0].Metadata["mongodb.code_name"] != "Unauthorized") {
+			t.Errorf("metadata %v", stmts[0].Metadata)
+		}
+	}
+}
+
+// An unacknowledged write gets no reply: the driver would read it as the
+// answer to its next command.
+func TestMongoDBDenyStatementRespectsMoreToCome(t *testing.T) {
+	stmt := hoopinspect.Statement{Metadata: map[string]string{
+		"mongodb.opcode": "OP_MSG", "mongodb.request_id": "3", "mongodb.more_to_come": "true",
+	}}
+	if f := (proxy.ProtocolDenyWriter{}).DenyStatement(hoopinspect.MongoDB, hoopinspect.FromClient, "x", stmt); f != nil {
+		t.Errorf("got a %d-byte frame for an unacknowledged write", len(f))
+	}
+}
+
+func TestMongoDBDenialAnswersTheRequest(t *testing.T) {
+	up := newEchoUpstream(t, nil)
+
+	srv := startServer(t, proxy.Config{
+		Upstream:   up.addr(),
+		Protocol:   hoopinspect.MongoDB,
+		Connection: "appdb",
+		Policy:     denyDrops(t),
+		Audit:      audit.NewMemorySink(64),
+		DenyWriter: proxy.ProtocolDenyWriter{},
+	})
+
+	c, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Write(mongoCommand(77, "drop", "customers")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	c.SetReadDeadline(time.Now().Add(3 * time.Second))
+	frame, err := io.ReadAll(c)
+	if len(frame) < 16 {
+		t.Fatalf("connection closed with no reply (%v)", err)
+	}
+	if got := binary.LittleEndian.Uint32(frame[8:]); got != 77 {
+		t.Errorf("responseTo = %d, want 77: a driver discards a reply to no request", got)
+	}
+	if !bytes.Contains(frame, []byte("destructive statements are not permitted on appdb")) {
+		t.Errorf("reply does not carry the operator message: %q", frame)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if len(up.got()) != 0 {
+		t.Errorf("upstream received %d bytes on a denied command", len(up.got()))
+	}
 }
 
 func TestDenyFrameFallsBackToAGenericMessage(t *testing.T) {
@@ -0,0 +1,240 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// proxySignature opens every PROXY protocol v2 header.
+//
+// https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+var proxySignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyHeaderLen is the fixed part: signature, version/command, family and
+// the length of what follows.
+const proxyHeaderLen = 16
+
+// The TLV types this package reads itself.
+const (
+	// PP2TypeSSL describes the TLS session the sending proxy terminated,
+	// with the client certificate's CN as a sub-TLV.
+	PP2TypeSSL byte = 0x20
+
+	pp2SubtypeSSLCN      byte = 0x22
+	pp2ClientCertConn    byte = 0x02
+	pp2ClientCertSession byte = 0x04
+)
+
+// ErrNoProxyHeader reports a connection that did not open with a PROXY
+// protocol v2 header on a listener that requires one.
+var ErrNoProxyHeader = errors.New("hoopinspect/proxy: connection did not start with a PROXY v2 header")
+
+// TLV is one type-length-value record from a PROXY v2 header.
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// ProxyHeader is a parsed PROXY protocol v2 header.
+//
+// It is what a fronting proxy knows about the connection it is relaying: the
+// real client address, and in its TLVs whatever it authenticated. Envoy's
+// proxy_protocol transport socket can add custom TLVs (types 0xE0 to 0xEF are
+// reserved for that) carrying a subject or groups from a filter that already
+// verified them, which gives a TCP lane the identity an HTTP lane gets from a
+// header.
+type ProxyHeader struct {
+	// Local is the LOCAL command: the sending proxy's own connection, a
+	// health check, carrying no client. Source and Dest are invalid and
+	// TLVs are still read.
+	Local bool
+
+	Source, Dest netip.AddrPort
+
+	TLVs []TLV
+}
+
+// TLV returns the value of the first TLV of type t.
+func (h ProxyHeader) TLV(t byte) ([]byte, bool) {
+	for _, tlv := range h.TLVs {
+		if tlv.Type == t {
+			return tlv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// SSLClientCN returns the client certificate CN the sending proxy reported,
+// only when it also reports that the certificate was presented and verified.
+// A CN from an unverified certificate is anyone's to choose.
+func (h ProxyHeader) SSLClientCN() (string, bool) {
+	v, ok := h.TLV(PP2TypeSSL)
+	if !ok || len(v) < 5 {
+		return "", false
+	}
+	client, verify := v[0], binary.BigEndian.Uint32(v[1:5])
+	if client&(pp2ClientCertConn|pp2ClientCertSession) == 0 || verify != 0 {
+		return "", false
+	}
+	subs, err := parseTLVs(v[5:])
+	if err != nil {
+		return "", false
+	}
+	for _, s := range subs {
+		if s.Type == pp2SubtypeSSLCN && len(s.Value) > 0 {
+			return string(s.Value), true
+		}
+	}
+	return "", false
+}
+
+// ParseProxyHeader parses a PROXY v2 header at the start of b, returning it
+// and its length in bytes. It returns io.ErrUnexpectedEOF when b holds only
+// part of one, and ErrNoProxyHeader when b does not start with one.
+//
+// The v1 text format is refused rather than parsed: it carries no TLVs, so it
+// cannot carry an identity, and a listener configured for identity that
+// accepted it would quietly record every session as anonymous.
+func ParseProxyHeader(b []byte) (ProxyHeader, int, error) {
+	if len(b) < proxyHeaderLen {
+		if bytes.HasPrefix(proxySignature, b) {
+			return ProxyHeader{}, 0, io.ErrUnexpectedEOF
+		}
+		return ProxyHeader{}, 0, ErrNoProxyHeader
+	}
+	if !bytes.Equal(b[:12], proxySignature) {
+		return ProxyHeader{}, 0, ErrNoProxyHeader
+	}
+	if b[12]>>4 != 2 {
+		return ProxyHeader{}, 0, fmt.Errorf("hoopinspect/proxy: PROXY header version %d, want 2", b[12]>>4)
+	}
+	total := proxyHeaderLen + int(binary.BigEndian.Uint16(b[14:16]))
+	if len(b) < total {
+		return ProxyHeader{}, 0, io.ErrUnexpectedEOF
+	}
+	body := b[proxyHeaderLen:total]
+
+	var h ProxyHeader
+	switch cmd := b[12] & 0x0F; cmd {
+	case 0:
+		h.Local = true
+	case 1:
+	default:
+		return ProxyHeader{}, 0, fmt.Errorf("hoopinspect/proxy: unknown PROXY command %d", cmd)
+	}
+
+	var addrLen int
+	switch fam := b[13] >> 4; fam {
+	case 0: // AF_UNSPEC
+	case 1: // AF_INET
+		addrLen = 12
+		if len(body) >= addrLen && !h.Local {
+			h.Source = netip.AddrPortFrom(netip.AddrFrom4([4]byte(body[0:4])), binary.BigEndian.Uint16(body[8:10]))
+			h.Dest = netip.AddrPortFrom(netip.AddrFrom4([4]byte(body[4:8])), binary.BigEndian.Uint16(body[10:12]))
+		}
+	case 2: // AF_INET6
+		addrLen = 36
+		if len(body) >= addrLen && !h.Local {
+			h.Source = netip.AddrPortFrom(netip.AddrFrom16([16]byte(body[0:16])), binary.BigEndian.Uint16(body[32:34]))
+			h.Dest = netip.AddrPortFrom(netip.AddrFrom16([16]byte(body[16:32])), binary.BigEndian.Uint16(body[34:36]))
+		}
+	case 3: // AF_UNIX: two 108-byte paths, which say nothing about a client
+		addrLen = 216
+	default:
+		return ProxyHeader{}, 0, fmt.Errorf("hoopinspect/proxy: unknown PROXY address family %d", fam)
+	}
+	if len(body) < addrLen {
+		return ProxyHeader{}, 0, fmt.Errorf("hoopinspect/proxy: PROXY header too short for its address family")
+	}
+	tlvs, err := parseTLVs(body[addrLen:])
+	if err != nil {
+		return ProxyHeader{}, 0, err
+	}
+	h.TLVs = tlvs
+	return h, total, nil
+}
+
+func parseTLVs(b []byte) ([]TLV, error) {
+	var out []TLV
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, errors.New("hoopinspect/proxy: truncated PROXY TLV")
+		}
+		n := int(binary.BigEndian.Uint16(b[1:3]))
+		if len(b) < 3+n {
+			return nil, errors.New("hoopinspect/proxy: PROXY TLV overruns the header")
+		}
+		out = append(out, TLV{Type: b[0], Value: b[3 : 3+n]})
+		b = b[3+n:]
+	}
+	return out, nil
+}
+
+// readProxyHeader consumes the PROXY v2 header a connection must open with.
+//
+// It reads exactly the header and nothing past it, so the protocol's own
+// first bytes are left on the socket for whatever reads next, and the
+// returned connection reports the header's client as its RemoteAddr. A LOCAL
+// header keeps the socket's own address.
+func readProxyHeader(conn net.Conn, timeout time.Duration) (net.Conn, ProxyHeader, error) {
+	if timeout > 0 {
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, ProxyHeader{}, err
+		}
+	}
+	buf := make([]byte, proxyHeaderLen, proxyHeaderLen+256)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, ProxyHeader{}, fmt.Errorf("reading the PROXY header: %w", err)
+	}
+	if _, _, err := ParseProxyHeader(buf); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, ProxyHeader{}, err
+	}
+	rest := int(binary.BigEndian.Uint16(buf[14:16]))
+	buf = append(buf, make([]byte, rest)...)
+	if _, err := io.ReadFull(conn, buf[proxyHeaderLen:]); err != nil {
+		return nil, ProxyHeader{}, fmt.Errorf("reading the PROXY header: %w", err)
+	}
+	h, _, err := ParseProxyHeader(buf)
+	if err != nil {
+		return nil, ProxyHeader{}, err
+	}
+	if timeout > 0 {
+		if err := conn.SetReadDeadline(time.Time{}); err != nil {
+			return nil, ProxyHeader{}, err
+		}
+	}
+	pc := &proxiedConn{Conn: conn, header: h}
+	return pc, h, nil
+}
+
+// proxiedConn is a connection that arrived through a PROXY v2 sender.
+type proxiedConn struct {
+	net.Conn
+	header ProxyHeader
+}
+
+// RemoteAddr is the client the header named, which is the address the audit
+// trail wants; the socket's own peer is the fronting proxy.
+func (c *proxiedConn) RemoteAddr() net.Addr {
+	if c.header.Local || !c.header.Source.IsValid() {
+		return c.Conn.RemoteAddr()
+	}
+	return net.TCPAddrFromAddrPort(c.header.Source)
+}
+
+// ProxyHeaderOf returns the PROXY v2 header a connection arrived with. It is
+// for an IdentityFn on a listener with ProxyProtocol set, which receives the
+// connection after the header was read.
+func ProxyHeaderOf(c net.Conn) (ProxyHeader, bool) {
+	pc, ok := c.(*proxiedConn)
+	if !ok {
+		return ProxyHeader{}, false
+	}
+	return pc.header, true
+}
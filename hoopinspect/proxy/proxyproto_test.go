@@ -0,0 +1,282 @@
+package proxy_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/audit"
+	"github.com/hoophq/hoopinspect/proxy"
+	"github.com/hoophq/hoopinspect/session"
+)
+
+// proxyV2 builds a PROXY v2 PROXY-command header for an IPv4 client.
+func proxyV2(src, dst string, tlvs ...proxy.TLV) []byte {
+	s, d := net.ParseIP(src).To4(), net.ParseIP(dst).To4()
+	var body []byte
+	body = append(body, s...)
+	body = append(body, d...)
+	body = binary.BigEndian.AppendUint16(body, 51234)
+	body = binary.BigEndian.AppendUint16(body, 5432)
+	for _, t := range tlvs {
+		body = append(body, t.Type)
+		body = binary.BigEndian.AppendUint16(body, uint16(len(t.Value)))
+		body = append(body, t.Value...)
+	}
+	h := []byte("\r\n\r\n\x00\r\nQUIT\n")
+	h = append(h, 0x21, 0x11) // v2 PROXY, AF_INET STREAM
+	h = binary.BigEndian.AppendUint16(h, uint16(len(body)))
+	return append(h, body...)
+}
+
+// sslTLV is the standard SSL TLV: client flags, verify result, then a CN.
+func sslTLV(verified bool, cn string) proxy.TLV {
+	v := []byte{0x01 | 0x02} // PP2_CLIENT_SSL | PP2_CLIENT_CERT_CONN
+	verify := uint32(0)
+	if !verified {
+		verify = 1
+	}
+	v = binary.BigEndian.AppendUint32(v, verify)
+	v = append(v, 0x22)
+	v = binary.BigEndian.AppendUint16(v, uint16(len(cn)))
+	v = append(v, cn...)
+	return proxy.TLV{Type: proxy.PP2TypeSSL, Value: v}
+}
+
+func TestParseProxyHeader(t *testing.T) {
+	raw := proxyV2("10.1.2.3", "10.0.0.9",
+		proxy.TLV{Type: 0xE0, Value: []byte("alice@example.com")},
+		sslTLV(true, "alice"))
+	raw = append(raw, "trailing protocol bytes"...)
+
+	h, n, err := proxy.ParseProxyHeader(raw)
+	if err != nil {
+		t.Fatalf("ParseProxyHeader: %v", err)
+	}
+	if string(raw[n:]) != "trailing protocol bytes" {
+		t.Errorf("header length %d leaves %q, want only the protocol's bytes", n, raw[n:])
+	}
+	if h.Source.String() != "10.1.2.3:51234" {
+		t.Errorf("source = %s", h.Source)
+	}
+	if v, ok := h.TLV(0xE0); !ok || string(v) != "alice@example.com" {
+		t.Errorf("TLV 0xE0 = %q, %v", v, ok)
+	}
+	if cn, ok := h.SSLClientCN(); !ok || cn != "alice" {
+		t.Errorf("SSLClientCN = %q, %v, want alice", cn, ok)
+	}
+
+	unverified, _, _ := proxy.ParseProxyHeader(proxyV2("10.1.2.3", "10.0.0.9", sslTLV(false, "mallory")))
+	if cn, ok := unverified.SSLClientCN(); ok {
+		t.Errorf("SSLClientCN trusted an unverified certificate: %q", cn)
+	}
+
+	if _, _, err := proxy.ParseProxyHeader(raw[:20]); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("partial header: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+	for name, b := range map[string][]byte{
+		"v1 text":   []byte("PROXY TCP4 10.1.2.3 10.0.0.9 51234 5432\r\n"),
+		"no header": pgQuery("SELECT 1"),
+	} {
+		if _, _, err := proxy.ParseProxyHeader(b); !errors.Is(err, proxy.ErrNoProxyHeader) {
+			t.Errorf("%s: err = %v, want ErrNoProxyHeader", name, err)
+		}
+	}
+}
+
+// waitForEnd polls sink until the session-end event lands.
+func waitForEnd(sink *audit.MemorySink) []audit.Event {
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if hasKind(sink.Events(), audit.KindSessionEnd) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return sink.Events()
+}
+
+// The TCP lane case the header exists for: the subject reaches the audit
+// trail, the header's client replaces the proxy's address, and the upstream
+// never sees a byte of the header.
+func TestProxyHeaderCarriesIdentityToTheAuditTrail(t *testing.T) {
+	up := newEchoUpstream(t, nil)
+	sink := audit.NewMemorySink(64)
+	srv := startServer(t, proxy.Config{
+		Upstream:      up.addr(),
+		Protocol:      hoopinspect.Postgres,
+		Audit:         sink,
+		ProxyProtocol: true,
+		IdentityFn: func(c net.Conn) session.Identity {
+			id := session.Identity{PeerAddr: c.RemoteAddr().String()}
+			if h, ok := proxy.ProxyHeaderOf(c); ok {
+				v, _ := h.TLV(0xE0)
+				id.Subject = string(v)
+			}
+			return id
+		},
+	})
+
+	c, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := pgQuery("SELECT 1")
+	c.Write(append(proxyV2("10.1.2.3", "10.0.0.9", proxy.TLV{Type: 0xE0, Value: []byte("alice@example.com")}), q...))
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	io.ReadFull(c, make([]byte, len(q)))
+	c.Close()
+
+	events := waitForEnd(sink)
+	if !hasKind(events, audit.KindStatement) {
+		t.Fatal("no statement recorded")
+	}
+	for _, ev := range events {
+		if ev.Principal != "alice@example.com" {
+			t.Errorf("event %s has principal %q, want the TLV's subject", ev.Kind, ev.Principal)
+		}
+	}
+	if !bytes.Equal(up.got(), q) {
+		t.Errorf("upstream received %q, want only the query", up.got())
+	}
+}
+
+func TestConnectionWithoutProxyHeaderIsClosed(t *testing.T) {
+	up := newEchoUpstream(t, nil)
+	sink := audit.NewMemorySink(64)
+	srv := startServer(t, proxy.Config{
+		Upstream:      up.addr(),
+		Protocol:      hoopinspect.Postgres,
+		Audit:         sink,
+		ProxyProtocol: true,
+	})
+
+	c, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// Exactly a header's length, so the relay has read enough to refuse.
+	c.Write(append(pgQuery("SELECT 1"), make([]byte, 16)...))
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Error("the relay answered a connection with no PROXY header")
+	}
+	if len(up.got()) != 0 || len(sink.Events()) != 0 {
+		t.Errorf("a refused connection reached the upstream (%d bytes) or the audit trail (%d events)",
+			len(up.got()), len(sink.Events()))
+	}
+}
+
+// clientCert is a self-signed client certificate that is also the CA the
+// relay verifies it against.
+func clientCert(t *testing.T, spiffe string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(spiffe)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "deploy-bot"},
+		URIs:                  []*url.URL{u},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// A verified client certificate names the session, and beats the user the
+// startup packet claims.
+func TestClientCertificateNamesThePgwireSession(t *testing.T) {
+	cert, pool := clientCert(t, "spiffe://prod/sa/deploy-bot")
+	serverTLS := serverCert(t)
+	serverTLS.ClientCAs = pool
+	serverTLS.ClientAuth = tls.RequireAndVerifyClientCert
+
+	up := newEchoUpstream(t, nil)
+	sink := audit.NewMemorySink(64)
+	srv := startServer(t, proxy.Config{
+		Upstream:      up.addr(),
+		Protocol:      hoopinspect.Postgres,
+		Audit:         sink,
+		DownstreamTLS: serverTLS,
+		CertIdentity: func(c *x509.Certificate) session.Identity {
+			return session.Identity{Subject: c.URIs[0].String()}
+		},
+	})
+
+	raw, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.SetDeadline(time.Now().Add(3 * time.Second))
+	raw.Write(binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 8), 80877103))
+	answer := make([]byte, 1)
+	if _, err := io.ReadFull(raw, answer); err != nil || answer[0] != 'S' {
+		t.Fatalf("SSLRequest answer = %q, %v", answer, err)
+	}
+	c := tls.Client(raw, &tls.Config{Certificates: []tls.Certificate{cert}, InsecureSkipVerify: true})
+	startup := pgStartupAs("postgres")
+	c.Write(startup)
+	io.ReadFull(c, make([]byte, len(startup)))
+	c.Close()
+
+	for _, ev := range waitForEnd(sink) {
+		if ev.Kind == audit.KindSessionEnd && ev.Principal != "spiffe://prod/sa/deploy-bot" {
+			t.Errorf("principal = %q, want the certificate's URI SAN over the claimed user", ev.Principal)
+		}
+	}
+}
+
+func serverCert(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "relay"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+// pgStartupAs is a v3 StartupMessage naming user.
+func pgStartupAs(user string) []byte {
+	params := []byte("user\x00" + user + "\x00\x00")
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(params)))
+	b = binary.BigEndian.AppendUint32(b, 3<<16)
+	return append(b, params...)
+}
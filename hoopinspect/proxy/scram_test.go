@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+)
+
+// The SCRAM-SHA-256 example exchange from RFC 7677, section 3.
+func TestSCRAMClientMatchesRFC7677(t *testing.T) {
+	c := &scramClient{user: "user", password: []byte("pencil"), nonce: "rOprNGfwEbeRWgbNEkqO"}
+	first, err := c.first()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != "n,,n=user,r=rOprNGfwEbeRWgbNEkqO" {
+		t.Errorf("client-first = %q", first)
+	}
+
+	final, err := c.final([]byte("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+	if string(final) != want {
+		t.Errorf("client-final = %q, want %q", final, want)
+	}
+
+	if err := c.verify([]byte("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")); err != nil {
+		t.Errorf("the RFC's server signature did not verify: %v", err)
+	}
+	if err := c.verify([]byte("v=AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")); err == nil {
+		t.Error("a forged server signature verified")
+	}
+}
+
+func TestSCRAMClientRefusesAForeignNonce(t *testing.T) {
+	c := &scramClient{password: []byte("pencil"), nonce: "abc"}
+	c.first()
+	if _, err := c.final([]byte("r=xyz123,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")); err == nil ||
+		!strings.Contains(err.Error(), "does not extend ours") {
+		t.Errorf("final with another exchange's nonce: err = %v", err)
+	}
+}
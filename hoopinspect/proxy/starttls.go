@@ -40,6 +40,10 @@ const (
 // So the negotiation is chosen per protocol, and a protocol whose TLS starts
 // immediately (HTTP) gets the plain handshake.
 //
+// gRPC starts immediately too, and also has to ask for HTTP/2 by ALPN: a
+// gRPC server refuses a TLS session that did not, and the relay forwards
+// the client's h2c bytes as they are.
+//
 // The returned conn is a *tls.Conn: reads yield DECRYPTED bytes. That is the
 // property the gate depends on. TLS protects the hop to the upstream; it does
 // not hide the payload from the inspector, which is the whole point of
@@ -69,10 +73,19 @@ func startTLS(conn net.Conn, addr string, proto hoopinspect.Protocol, cfg *tls.C
 		cfg.ServerName = host
 	}
 
+	if proto == hoopinspect.GRPC && len(cfg.NextProtos) == 0 {
+		cfg = cfg.Clone()
+		cfg.NextProtos = []string{"h2"}
+	}
+
 	tc := tls.Client(conn, cfg)
 	if err := tc.Handshake(); err != nil {
 		return nil, fmt.Errorf("upstream TLS handshake: %w", err)
 	}
+	if proto == hoopinspect.GRPC && tc.ConnectionState().NegotiatedProtocol != "h2" {
+		tc.Close()
+		return nil, fmt.Errorf("upstream TLS: the server did not agree to HTTP/2 (ALPN h2)")
+	}
 	return tc, nil
 }
 
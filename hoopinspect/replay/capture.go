@@ -0,0 +1,395 @@
+// Package replay runs captured traffic through a lane's codec, policy and
+// masker offline, and reports what that lane would have done to every
+// statement in it.
+//
+// The gate is a pure function over bytes, so a recorded conversation can be
+// fed through it exactly as the relay would have, with no network and no
+// database. That answers the question a rule change raises before anyone
+// enforces it: which of last week's statements would this have denied, and
+// which did the old config deny that this one lets through?
+//
+// # Inputs
+//
+// Two capture formats, both holding whole TCP conversations:
+//
+//   - A libpcap file, as tcpdump -w writes it. Flows are reassembled from
+//     the TCP segments: retransmissions are dropped, reordered segments are
+//     put back in order, and a gap the capture missed ends that direction,
+//     because a codec fed bytes with a hole in them decodes garbage.
+//   - A dump, this package's own format: length-prefixed chunks tagged with
+//     a flow and a direction. It is what a caller writes when it already has
+//     the two byte streams and no packets, and what WriteDump produces.
+//
+// pcapng is not read. tcpdump writes libpcap by default; convert an ng file
+// with `editcap -F libpcap`.
+package replay
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// Flow is one TCP conversation, in the order its bytes were seen.
+type Flow struct {
+	// Client and Server are the two endpoints. Client is the side that
+	// sent the SYN; in a capture that started mid-conversation it is the
+	// side with the higher port, the usual ephemeral-port guess.
+	Client, Server netip.AddrPort
+
+	// Chunks are the payloads in capture order, reassembled per direction.
+	Chunks []Chunk
+
+	// Partial reports that the capture missed the start of the
+	// conversation or a segment in the middle of it, so a codec may see a
+	// stream it cannot frame.
+	Partial bool
+}
+
+// Chunk is one read's worth of bytes travelling in one direction.
+type Chunk struct {
+	Direction hoopinspect.Direction
+	Data      []byte
+
+	// Time is when the capture saw it, zero when the format has none.
+	Time time.Time
+}
+
+// pcap file magic numbers, as written in the file's own byte order.
+const (
+	pcapMagicMicro = 0xa1b2c3d4
+	pcapMagicNano  = 0xa1b23c4d
+)
+
+// Link types this reader understands.
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLoop     = 108
+	linkSLL      = 113
+	linkIPv4     = 228
+	linkIPv6     = 229
+	linkSLL2     = 276
+)
+
+// ErrNotPcap reports a file that does not start with a libpcap header,
+// pcapng included.
+var ErrNotPcap = errors.New("hoopinspect/replay: not a libpcap file (pcapng is not supported; convert with editcap -F libpcap)")
+
+// ReadPcap reads a libpcap capture and returns its TCP flows in the order
+// each was first seen. Packets that are not TCP over IPv4 or IPv6, and IP
+// fragments, are skipped.
+func ReadPcap(r io.Reader) ([]Flow, error) {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("hoopinspect/replay: reading pcap header: %w", err)
+	}
+
+	var order binary.ByteOrder
+	var nano bool
+	switch {
+	case binary.LittleEndian.Uint32(hdr[0:4]) == pcapMagicMicro:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr[0:4]) == pcapMagicMicro:
+		order = binary.BigEndian
+	case binary.LittleEndian.Uint32(hdr[0:4]) == pcapMagicNano:
+		order, nano = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(hdr[0:4]) == pcapMagicNano:
+		order, nano = binary.BigEndian, true
+	default:
+		return nil, ErrNotPcap
+	}
+	link := order.Uint32(hdr[20:24]) & 0x0fffffff
+
+	asm := newAssembler()
+	var rec [16]byte
+	for n := 0; ; n++ {
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			// A capture cut off mid-record (tcpdump killed without a
+			// flush) still holds every complete packet before it.
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, fmt.Errorf("hoopinspect/replay: packet %d: %w", n, err)
+		}
+		sec, frac := int64(order.Uint32(rec[0:4])), int64(order.Uint32(rec[4:8]))
+		if !nano {
+			frac *= 1000
+		}
+		data := make([]byte, order.Uint32(rec[8:12]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			break
+		}
+		if seg, ok := decodePacket(link, data); ok {
+			seg.time = time.Unix(sec, frac).UTC()
+			asm.add(seg)
+		}
+	}
+	return asm.flows(), nil
+}
+
+// segment is one TCP packet, decoded as far as reassembly needs.
+type segment struct {
+	src, dst netip.AddrPort
+	seq      uint32
+	syn, ack bool
+	fin, rst bool
+	payload  []byte
+	time     time.Time
+}
+
+func decodePacket(link uint32, b []byte) (segment, bool) {
+	var ethertype uint16
+	switch link {
+	case linkEthernet:
+		if len(b) < 14 {
+			return segment{}, false
+		}
+		ethertype, b = binary.BigEndian.Uint16(b[12:14]), b[14:]
+		// 802.1Q and 802.1ad tags, possibly stacked.
+		for (ethertype == 0x8100 || ethertype == 0x88a8) && len(b) >= 4 {
+			ethertype, b = binary.BigEndian.Uint16(b[2:4]), b[4:]
+		}
+	case linkSLL:
+		if len(b) < 16 {
+			return segment{}, false
+		}
+		ethertype, b = binary.BigEndian.Uint16(b[14:16]), b[16:]
+	case linkSLL2:
+		if len(b) < 20 {
+			return segment{}, false
+		}
+		ethertype, b = binary.BigEndian.Uint16(b[0:2]), b[20:]
+	case linkNull, linkLoop:
+		// A 4-byte address family, in the capturing host's byte order for
+		// NULL and big-endian for LOOP; the version nibble says the same.
+		if len(b) < 4 {
+			return segment{}, false
+		}
+		b = b[4:]
+	case linkRaw, linkIPv4, linkIPv6:
+	default:
+		return segment{}, false
+	}
+	if len(b) == 0 {
+		return segment{}, false
+	}
+	switch {
+	case ethertype == 0x0800 || (ethertype == 0 && b[0]>>4 == 4):
+		return decodeIPv4(b)
+	case ethertype == 0x86dd || (ethertype == 0 && b[0]>>4 == 6):
+		return decodeIPv6(b)
+	}
+	return segment{}, false
+}
+
+func decodeIPv4(b []byte) (segment, bool) {
+	if len(b) < 20 || b[0]>>4 != 4 {
+		return segment{}, false
+	}
+	ihl := int(b[0]&0x0f) * 4
+	total := int(binary.BigEndian.Uint16(b[2:4]))
+	if b[9] != 6 || ihl < 20 || total < ihl || total > len(b) {
+		return segment{}, false
+	}
+	// A fragment carries part of a segment; reassembling IP is out of
+	// scope, and a database conversation on a sane MTU has none.
+	if frag := binary.BigEndian.Uint16(b[6:8]); frag&0x2000 != 0 || frag&0x1fff != 0 {
+		return segment{}, false
+	}
+	src, _ := netip.AddrFromSlice(b[12:16])
+	dst, _ := netip.AddrFromSlice(b[16:20])
+	return decodeTCP(src, dst, b[ihl:total])
+}
+
+func decodeIPv6(b []byte) (segment, bool) {
+	if len(b) < 40 || b[0]>>4 != 6 {
+		return segment{}, false
+	}
+	plen := int(binary.BigEndian.Uint16(b[4:6]))
+	// Extension headers are not walked: TCP directly after the fixed
+	// header is what every database conversation looks like.
+	if b[6] != 6 || 40+plen > len(b) {
+		return segment{}, false
+	}
+	src, _ := netip.AddrFromSlice(b[8:24])
+	dst, _ := netip.AddrFromSlice(b[24:40])
+	return decodeTCP(src, dst, b[40:40+plen])
+}
+
+func decodeTCP(src, dst netip.Addr, b []byte) (segment, bool) {
+	if len(b) < 20 {
+		return segment{}, false
+	}
+	off := int(b[12]>>4) * 4
+	if off < 20 || off > len(b) {
+		return segment{}, false
+	}
+	flags := b[13]
+	return segment{
+		src:     netip.AddrPortFrom(src.Unmap(), binary.BigEndian.Uint16(b[0:2])),
+		dst:     netip.AddrPortFrom(dst.Unmap(), binary.BigEndian.Uint16(b[2:4])),
+		seq:     binary.BigEndian.Uint32(b[4:8]),
+		fin:     flags&0x01 != 0,
+		syn:     flags&0x02 != 0,
+		rst:     flags&0x04 != 0,
+		ack:     flags&0x10 != 0,
+		payload: b[off:],
+	}, true
+}
+
+// assembler turns segments into flows.
+type assembler struct {
+	open  map[[2]netip.AddrPort]*stream
+	order []*stream
+}
+
+// stream is one flow under assembly.
+type stream struct {
+	flow   Flow
+	halves [2]half // indexed by direction: 0 client to server, 1 back
+	closed bool
+}
+
+// half is one direction's reassembly state.
+type half struct {
+	started bool
+	next    uint32
+	pending map[uint32]segment
+}
+
+func newAssembler() *assembler {
+	return &assembler{open: make(map[[2]netip.AddrPort]*stream)}
+}
+
+func flowKey(a, b netip.AddrPort) [2]netip.AddrPort {
+	if a.Compare(b) > 0 {
+		a, b = b, a
+	}
+	return [2]netip.AddrPort{a, b}
+}
+
+func (a *assembler) add(seg segment) {
+	key := flowKey(seg.src, seg.dst)
+	s := a.open[key]
+
+	// A fresh SYN on a finished four-tuple is a new conversation: client
+	// ports get reused, and two psql sessions must not read as one.
+	if seg.syn && !seg.ack && s != nil && (s.closed || len(s.flow.Chunks) > 0) {
+		s = nil
+	}
+	if s == nil {
+		s = &stream{}
+		switch {
+		case seg.syn && !seg.ack:
+			s.flow.Client, s.flow.Server = seg.src, seg.dst
+		case seg.syn && seg.ack:
+			s.flow.Client, s.flow.Server = seg.dst, seg.src
+		case seg.src.Port() > seg.dst.Port():
+			s.flow.Client, s.flow.Server = seg.src, seg.dst
+			s.flow.Partial = true
+		default:
+			s.flow.Client, s.flow.Server = seg.dst, seg.src
+			s.flow.Partial = true
+		}
+		a.open[key] = s
+		a.order = append(a.order, s)
+	}
+
+	dir, h := hoopinspect.FromClient, &s.halves[0]
+	if seg.src != s.flow.Client {
+		dir, h = hoopinspect.FromServer, &s.halves[1]
+	}
+
+	if seg.rst {
+		s.closed = true
+	}
+	if seg.syn {
+		h.started, h.next = true, seg.seq+1
+		return
+	}
+	if seg.fin {
+		s.closed = true
+	}
+	if len(seg.payload) == 0 {
+		return
+	}
+	if !h.started {
+		// The capture began after the handshake: take the first segment
+		// seen as the start of the stream.
+		h.started, h.next = true, seg.seq
+	}
+	h.accept(seg, func(data []byte, t time.Time) {
+		s.flow.Chunks = append(s.flow.Chunks, Chunk{Direction: dir, Data: data, Time: t})
+	})
+}
+
+// accept emits seg if it is next in sequence, trimming what was already
+// emitted, and then anything buffered that it makes contiguous. A segment
+// from the future waits.
+func (h *half) accept(seg segment, emit func([]byte, time.Time)) {
+	for {
+		ahead := int32(seg.seq - h.next)
+		switch {
+		case ahead > 0:
+			if h.pending == nil {
+				h.pending = make(map[uint32]segment)
+			}
+			h.pending[seg.seq] = seg
+			return
+		case ahead < 0:
+			// A retransmission, whole or overlapping.
+			if -int(ahead) >= len(seg.payload) {
+				return
+			}
+			seg.payload = seg.payload[-ahead:]
+		}
+		emit(seg.payload, seg.time)
+		h.next += uint32(len(seg.payload))
+
+		next, ok := h.pendingAt()
+		if !ok {
+			return
+		}
+		seg = next
+	}
+}
+
+// pendingAt removes and returns a buffered segment that starts at or before
+// next, if any.
+func (h *half) pendingAt() (segment, bool) {
+	for seq, seg := range h.pending {
+		if int32(seq-h.next) <= 0 {
+			delete(h.pending, seq)
+			return seg, true
+		}
+	}
+	return segment{}, false
+}
+
+func (a *assembler) flows() []Flow {
+	out := make([]Flow, 0, len(a.order))
+	for _, s := range a.order {
+		for i := range s.halves {
+			if len(s.halves[i].pending) > 0 {
+				// Bytes after a hole cannot be framed; dropping them
+				// is the honest end of what the capture holds.
+				s.flow.Partial = true
+			}
+		}
+		if len(s.flow.Chunks) == 0 {
+			continue
+		}
+		out = append(out, s.flow)
+	}
+	return out
+}
@@ -0,0 +1,183 @@
+package replay_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/replay"
+)
+
+// pcapWriter builds a little-endian, microsecond, Ethernet libpcap file.
+type pcapWriter struct {
+	buf bytes.Buffer
+	t   time.Time
+}
+
+func newPcap() *pcapWriter {
+	w := &pcapWriter{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:4], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(hdr[4:6], 2)
+	binary.LittleEndian.PutUint16(hdr[6:8], 4)
+	binary.LittleEndian.PutUint32(hdr[16:20], 65535)
+	binary.LittleEndian.PutUint32(hdr[20:24], 1) // Ethernet
+	w.buf.Write(hdr)
+	return w
+}
+
+const (
+	flagFIN = 0x01
+	flagSYN = 0x02
+	flagACK = 0x10
+)
+
+// tcp appends one Ethernet/IPv4/TCP frame.
+func (w *pcapWriter) tcp(src, dst netip.AddrPort, seq uint32, flags byte, payload []byte) {
+	tcp := make([]byte, 20, 20+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:2], src.Port())
+	binary.BigEndian.PutUint16(tcp[2:4], dst.Port())
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	tcp = append(tcp, payload...)
+
+	ip := make([]byte, 20, 20+len(tcp))
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+	ip[9] = 6
+	s, d := src.Addr().As4(), dst.Addr().As4()
+	copy(ip[12:16], s[:])
+	copy(ip[16:20], d[:])
+	ip = append(ip, tcp...)
+
+	frame := append(make([]byte, 12), 0x08, 0x00)
+	frame = append(frame, ip...)
+
+	rec := make([]byte, 16)
+	w.t = w.t.Add(time.Millisecond)
+	binary.LittleEndian.PutUint32(rec[0:4], uint32(w.t.Unix()))
+	binary.LittleEndian.PutUint32(rec[4:8], uint32(w.t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(rec[8:12], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(rec[12:16], uint32(len(frame)))
+	w.buf.Write(rec)
+	w.buf.Write(frame)
+}
+
+func stream(f replay.Flow, dir hoopinspect.Direction) string {
+	var out []byte
+	for _, c := range f.Chunks {
+		if c.Direction == dir {
+			out = append(out, c.Data...)
+		}
+	}
+	return string(out)
+}
+
+var (
+	cli = netip.MustParseAddrPort("10.0.0.7:51234")
+	srv = netip.MustParseAddrPort("10.0.0.9:5432")
+)
+
+// Retransmitted and reordered segments come out once, in order, and the
+// side that sent the SYN is the client.
+func TestReadPcapReassembles(t *testing.T) {
+	w := newPcap()
+	w.tcp(cli, srv, 100, flagSYN, nil)
+	w.tcp(srv, cli, 900, flagSYN|flagACK, nil)
+	w.tcp(cli, srv, 101, flagACK, []byte("hello "))
+	w.tcp(cli, srv, 112, flagACK, []byte("again"))   // ahead of a gap
+	w.tcp(cli, srv, 107, flagACK, []byte("world"))   // fills it
+	w.tcp(cli, srv, 101, flagACK, []byte("hello "))  // retransmission
+	w.tcp(cli, srv, 110, flagACK, []byte("ldagain")) // overlaps both
+	w.tcp(srv, cli, 901, flagACK, []byte("ok"))
+	w.tcp(cli, srv, 117, flagFIN|flagACK, nil)
+
+	flows, err := replay.ReadPcap(&w.buf)
+	if err != nil {
+		t.Fatalf("ReadPcap: %v", err)
+	}
+	if len(flows) != 1 {
+		t.Fatalf("flows = %d, want 1", len(flows))
+	}
+	f := flows[0]
+	if f.Client != cli || f.Server != srv || f.Partial {
+		t.Errorf("client %v server %v partial %v", f.Client, f.Server, f.Partial)
+	}
+	if got := stream(f, hoopinspect.FromClient); got != "hello worldagain" {
+		t.Errorf("client stream = %q", got)
+	}
+	if got := stream(f, hoopinspect.FromServer); got != "ok" {
+		t.Errorf("server stream = %q", got)
+	}
+	if f.Chunks[0].Time.IsZero() {
+		t.Error("chunk time not taken from the capture")
+	}
+}
+
+// A reused client port after the first conversation closed is a second
+// flow, and a capture that missed the handshake guesses the client by port.
+func TestReadPcapSeparatesFlows(t *testing.T) {
+	w := newPcap()
+	w.tcp(cli, srv, 100, flagSYN, nil)
+	w.tcp(cli, srv, 101, flagACK, []byte("one"))
+	w.tcp(cli, srv, 104, flagFIN|flagACK, nil)
+	w.tcp(cli, srv, 500, flagSYN, nil)
+	w.tcp(cli, srv, 501, flagACK, []byte("two"))
+	other := netip.MustParseAddrPort("10.0.0.8:40000")
+	w.tcp(srv, other, 7, flagACK, []byte("mid"))
+
+	flows, err := replay.ReadPcap(&w.buf)
+	if err != nil {
+		t.Fatalf("ReadPcap: %v", err)
+	}
+	if len(flows) != 3 {
+		t.Fatalf("flows = %d, want 3", len(flows))
+	}
+	if stream(flows[0], hoopinspect.FromClient) != "one" || stream(flows[1], hoopinspect.FromClient) != "two" {
+		t.Error("a reused port merged two conversations")
+	}
+	if f := flows[2]; f.Client != other || !f.Partial {
+		t.Errorf("mid-stream flow: client %v partial %v", f.Client, f.Partial)
+	}
+}
+
+func TestReadPcapRefusesOtherFormats(t *testing.T) {
+	ng := []byte{0x0a, 0x0d, 0x0d, 0x0a, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if _, err := replay.ReadPcap(bytes.NewReader(ng)); !errors.Is(err, replay.ErrNotPcap) {
+		t.Errorf("err = %v, want ErrNotPcap", err)
+	}
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	in := []replay.Flow{
+		{Chunks: []replay.Chunk{
+			{Direction: hoopinspect.FromClient, Data: []byte("q"), Time: at},
+			{Direction: hoopinspect.FromServer, Data: []byte("r")},
+		}},
+		{Chunks: []replay.Chunk{{Direction: hoopinspect.FromClient, Data: []byte("second")}}},
+	}
+	var buf bytes.Buffer
+	if err := replay.WriteDump(&buf, in); err != nil {
+		t.Fatalf("WriteDump: %v", err)
+	}
+	out, err := replay.ReadDump(&buf)
+	if err != nil {
+		t.Fatalf("ReadDump: %v", err)
+	}
+	if len(out) != 2 || len(out[0].Chunks) != 2 || string(out[1].Chunks[0].Data) != "second" {
+		t.Fatalf("round trip = %+v", out)
+	}
+	if c := out[0].Chunks; !c[0].Time.Equal(at) || !c[1].Time.IsZero() || c[1].Direction != hoopinspect.FromServer {
+		t.Errorf("chunks = %+v", c)
+	}
+
+	if _, err := replay.ReadDump(bytes.NewReader([]byte("not a dump"))); !errors.Is(err, replay.ErrNotDump) {
+		t.Errorf("err = %v, want ErrNotDump", err)
+	}
+}
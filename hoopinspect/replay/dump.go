@@ -0,0 +1,130 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// dumpMagic opens every dump, so a pcap passed as a dump (or the reverse)
+// is refused by name rather than decoded as garbage.
+const dumpMagic = "HIDUMP1\n"
+
+// Direction bytes in a dump record.
+const (
+	dumpFromClient = 'C'
+	dumpFromServer = 'S'
+)
+
+// dumpHeaderLen is a record's fixed part: flow, direction, time, length.
+const dumpHeaderLen = 4 + 1 + 8 + 4
+
+// maxDumpChunk bounds one record, so a corrupt length cannot make the
+// reader allocate gigabytes before it notices.
+const maxDumpChunk = 64 << 20
+
+// ErrNotDump reports a file that does not start with the dump magic.
+var ErrNotDump = errors.New("hoopinspect/replay: not a hoop-inspect dump")
+
+// WriteDump writes flows in the dump format.
+//
+// The format is the magic line "HIDUMP1\n" and then one record per chunk, in
+// order:
+//
+//	flow      uint32, big-endian: which conversation, numbered from 0
+//	direction 1 byte: 'C' client to server, 'S' server to client
+//	time      int64, big-endian: Unix nanoseconds, 0 when unknown
+//	length    uint32, big-endian
+//	payload   length bytes
+//
+// Records of different flows may interleave. Endpoints are not recorded; a
+// dump is for bytes the writer already knew the meaning of.
+func WriteDump(w io.Writer, flows []Flow) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(dumpMagic); err != nil {
+		return err
+	}
+	var hdr [dumpHeaderLen]byte
+	for i, f := range flows {
+		for _, c := range f.Chunks {
+			binary.BigEndian.PutUint32(hdr[0:4], uint32(i))
+			hdr[4] = dumpFromClient
+			if c.Direction == hoopinspect.FromServer {
+				hdr[4] = dumpFromServer
+			}
+			var ns int64
+			if !c.Time.IsZero() {
+				ns = c.Time.UnixNano()
+			}
+			binary.BigEndian.PutUint64(hdr[5:13], uint64(ns))
+			binary.BigEndian.PutUint32(hdr[13:17], uint32(len(c.Data)))
+			if _, err := bw.Write(hdr[:]); err != nil {
+				return err
+			}
+			if _, err := bw.Write(c.Data); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadDump reads a dump written by WriteDump, returning its flows in the
+// order each was first seen.
+func ReadDump(r io.Reader) ([]Flow, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(dumpMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != dumpMagic {
+		return nil, ErrNotDump
+	}
+
+	var (
+		out   []Flow
+		index = map[uint32]int{}
+		hdr   [dumpHeaderLen]byte
+	)
+	for n := 0; ; n++ {
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return out, nil
+			}
+			return nil, fmt.Errorf("hoopinspect/replay: dump record %d: %w", n, err)
+		}
+		var dir hoopinspect.Direction
+		switch hdr[4] {
+		case dumpFromClient:
+			dir = hoopinspect.FromClient
+		case dumpFromServer:
+			dir = hoopinspect.FromServer
+		default:
+			return nil, fmt.Errorf("hoopinspect/replay: dump record %d: unknown direction %q", n, hdr[4])
+		}
+		size := binary.BigEndian.Uint32(hdr[13:17])
+		if size > maxDumpChunk {
+			return nil, fmt.Errorf("hoopinspect/replay: dump record %d: %d bytes exceeds the %d limit",
+				n, size, maxDumpChunk)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, fmt.Errorf("hoopinspect/replay: dump record %d: %w", n, err)
+		}
+
+		c := Chunk{Direction: dir, Data: data}
+		if ns := int64(binary.BigEndian.Uint64(hdr[5:13])); ns != 0 {
+			c.Time = time.Unix(0, ns).UTC()
+		}
+		id := binary.BigEndian.Uint32(hdr[0:4])
+		i, seen := index[id]
+		if !seen {
+			i = len(out)
+			index[id] = i
+			out = append(out, Flow{})
+		}
+		out[i].Chunks = append(out[i].Chunks, c)
+	}
+}
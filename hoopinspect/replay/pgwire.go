@@ -0,0 +1,113 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+// pgwire's pre-startup requests. Each is exactly 8 bytes and answered with
+// one byte.
+const (
+	pgSSLRequestCode    uint32 = 80877103
+	pgGSSEncRequestCode uint32 = 80877104
+	pgNegotiateLen             = 8
+)
+
+// pgNegotiation removes pgwire's pre-startup exchange from a flow, the part
+// the relay answers itself before the gate sees a byte, and reports the user
+// the StartupMessage names.
+//
+// encrypted is true when the server accepted TLS or GSS encryption: every
+// byte after the acceptance is ciphertext, so the flow holds nothing a codec
+// can read.
+func pgNegotiation(f Flow) (out Flow, user string, encrypted bool) {
+	client, server := streamOf(f, hoopinspect.FromClient), streamOf(f, hoopinspect.FromServer)
+
+	var skipClient, skipServer int
+	for {
+		c := client[skipClient:]
+		if len(c) < pgNegotiateLen || binary.BigEndian.Uint32(c[0:4]) != pgNegotiateLen {
+			break
+		}
+		code := binary.BigEndian.Uint32(c[4:8])
+		if code != pgSSLRequestCode && code != pgGSSEncRequestCode {
+			break
+		}
+		skipClient += pgNegotiateLen
+		if skipServer >= len(server) {
+			break
+		}
+		answer := server[skipServer]
+		skipServer++
+		if answer == 'S' || answer == 'G' {
+			return f, "", true
+		}
+	}
+
+	out = f
+	out.Chunks = trimStream(f.Chunks, hoopinspect.FromClient, skipClient)
+	out.Chunks = trimStream(out.Chunks, hoopinspect.FromServer, skipServer)
+	return out, startupUser(client[skipClient:]), false
+}
+
+// startupUser reads the user parameter from a v3 StartupMessage, the same
+// way the relay names a pgwire session's principal. "" when the bytes are
+// not one.
+func startupUser(pkt []byte) string {
+	if len(pkt) < 9 {
+		return ""
+	}
+	length := binary.BigEndian.Uint32(pkt[0:4])
+	if length < 9 || int(length) > len(pkt) || binary.BigEndian.Uint32(pkt[4:8])>>16 != 3 {
+		return ""
+	}
+	params := pkt[8:length]
+	for {
+		k, rest, ok := bytes.Cut(params, []byte{0})
+		if !ok || len(k) == 0 {
+			return ""
+		}
+		v, rest, ok := bytes.Cut(rest, []byte{0})
+		if !ok {
+			return ""
+		}
+		if string(k) == "user" {
+			return string(v)
+		}
+		params = rest
+	}
+}
+
+// streamOf concatenates one direction's chunks.
+func streamOf(f Flow, dir hoopinspect.Direction) []byte {
+	var out []byte
+	for _, c := range f.Chunks {
+		if c.Direction == dir {
+			out = append(out, c.Data...)
+		}
+	}
+	return out
+}
+
+// trimStream drops the first n bytes travelling in dir, and any chunk left
+// empty by it.
+func trimStream(chunks []Chunk, dir hoopinspect.Direction, n int) []Chunk {
+	if n == 0 {
+		return chunks
+	}
+	out := make([]Chunk, 0, len(chunks))
+	for _, c := range chunks {
+		if c.Direction == dir && n > 0 {
+			cut := min(n, len(c.Data))
+			n -= cut
+			c.Data = c.Data[cut:]
+			if len(c.Data) == 0 {
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
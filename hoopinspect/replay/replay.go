@@ -0,0 +1,370 @@
+package replay
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/audit"
+	"github.com/hoophq/hoopinspect/gate"
+	"github.com/hoophq/hoopinspect/proxy"
+	"github.com/hoophq/hoopinspect/session"
+)
+
+// Lane is what a replay runs the traffic through: one listener's protocol
+// and resolved stack.
+type Lane struct {
+	Protocol hoopinspect.Protocol
+
+	// Connection names the lane on the session, as the relay's does, so a
+	// policy reading input.context.connection sees what it would live.
+	Connection string
+
+	Stack gate.Stack
+
+	// CodecFactory overrides the registry codec, as on gate.Config.
+	CodecFactory func() hoopinspect.Codec
+
+	// Clock, when set, is called with each chunk's capture time before the
+	// chunk is inspected. A rate rule counts against the wall clock, and a
+	// week of traffic replayed in a second would spend every allowance in
+	// it; pointing the rule's clock here counts in capture time instead.
+	Clock func(time.Time)
+
+	// ProxyIdentity marks a lane that requires a PROXY v2 header. Run
+	// strips the header from each flow's client stream and takes the
+	// session's identity from it, as the relay does; a flow without one is
+	// skipped, because the relay would have closed it.
+	ProxyIdentity func(proxy.ProxyHeader) session.Identity
+}
+
+// Outcome is what a lane did with one statement.
+type Outcome string
+
+const (
+	OutcomeAllowed Outcome = "allow"
+	OutcomeDenied  Outcome = "deny"
+
+	// OutcomeUnreached marks a statement after a denied one in the same
+	// read. The gate stops at the first denial and the relay then closes
+	// the connection, so live this statement would never have run, and no
+	// rule judged it.
+	OutcomeUnreached Outcome = "unreached"
+)
+
+// Verdict is one statement's outcome.
+type Verdict struct {
+	// Flow and Chunk locate the read the statement arrived in, and Index
+	// its position among that read's statements. The three together are
+	// the same under every config, because the codec and not the policy
+	// decides where statements are, which is what lets Diff pair them.
+	Flow, Chunk, Index int
+
+	Direction hoopinspect.Direction
+	Operation hoopinspect.Operation
+	Statement string
+
+	Outcome Outcome
+	Rule    string
+	Message string
+}
+
+// String renders the outcome the way a diff line reads it.
+func (v Verdict) String() string {
+	switch {
+	case v.Outcome == "":
+		return "absent"
+	case v.Outcome == OutcomeDenied && v.Rule != "":
+		return fmt.Sprintf("deny (%s)", v.Rule)
+	}
+	return string(v.Outcome)
+}
+
+// Rewrite is what a lane did to one response read: masked cells in it, or
+// cut it at a result limit.
+type Rewrite struct {
+	Flow, Chunk int
+
+	MaskedCells int
+	Entities    []string
+
+	// Limit names the result limit the read crossed, and LimitAction what
+	// it did: deny, truncate or observe.
+	Limit       string
+	LimitAction string
+}
+
+// String renders the rewrite the way a diff line reads it.
+func (r Rewrite) String() string {
+	var s string
+	if r.MaskedCells > 0 {
+		s = fmt.Sprintf("masked %d cell(s)", r.MaskedCells)
+	}
+	if r.Limit != "" {
+		if s != "" {
+			s += ", "
+		}
+		s += fmt.Sprintf("limit %s (%s)", r.Limit, r.LimitAction)
+	}
+	if s == "" {
+		return "untouched"
+	}
+	return s
+}
+
+// Skip is a flow the replay could not run, and why.
+type Skip struct {
+	Flow   int
+	Reason string
+}
+
+// Report is everything one lane made of a capture.
+type Report struct {
+	Flows    int
+	Verdicts []Verdict
+	Rewrites []Rewrite
+	Skipped  []Skip
+
+	// Errors are what the codec or the masker reported, as the audit trail
+	// would record them: a stream it could not frame, a mask it skipped.
+	Errors []string
+}
+
+// Totals counts a report's outcomes.
+type Totals struct {
+	Statements, Allowed, Denied, Unreached int
+	MaskedCells, Limited                   int
+}
+
+// Totals sums the report.
+func (r Report) Totals() Totals {
+	var t Totals
+	for _, v := range r.Verdicts {
+		switch v.Outcome {
+		case OutcomeAllowed:
+			t.Allowed++
+		case OutcomeDenied:
+			t.Denied++
+		case OutcomeUnreached:
+			t.Unreached++
+		}
+		t.Statements++
+	}
+	for _, rw := range r.Rewrites {
+		t.MaskedCells += rw.MaskedCells
+		if rw.Limit != "" {
+			t.Limited++
+		}
+	}
+	return t
+}
+
+// Run feeds every flow through a gate built from lane, one gate per flow as
+// the relay builds one per connection, and reports what it decided.
+//
+// Unlike the relay it does not stop a flow at a denial. The capture carries
+// on past the statement, and a report that stopped there would hide every
+// later one, which is exactly the part a rule change needs checked. The
+// statements the relay would never have seen are the ones in the denied
+// read itself, reported as OutcomeUnreached.
+func Run(ctx context.Context, flows []Flow, lane Lane) Report {
+	rep := Report{Flows: len(flows)}
+	for i, f := range flows {
+		var id session.Identity
+		if lane.ProxyIdentity != nil {
+			h, n, err := proxy.ParseProxyHeader(streamOf(f, hoopinspect.FromClient))
+			if err != nil {
+				rep.Skipped = append(rep.Skipped, Skip{Flow: i,
+					Reason: "the lane requires a PROXY header and the flow has none: " + err.Error()})
+				continue
+			}
+			f.Chunks = trimStream(f.Chunks, hoopinspect.FromClient, n)
+			id = lane.ProxyIdentity(h)
+		}
+
+		var user string
+		if lane.Protocol == hoopinspect.Postgres {
+			var encrypted bool
+			if f, user, encrypted = pgNegotiation(f); encrypted {
+				rep.Skipped = append(rep.Skipped, Skip{Flow: i,
+					Reason: "the session negotiated TLS or GSS encryption; nothing after it is readable"})
+				continue
+			}
+		}
+
+		if id.Subject == "" {
+			id.Subject = user
+		}
+		if id.PeerAddr == "" && f.Client.IsValid() {
+			id.PeerAddr = f.Client.String()
+		}
+		sess := session.New(lane.Protocol, id)
+		sess.Connection = lane.Connection
+
+		sink := &collector{}
+		g, err := gate.New(sess, gate.Config{
+			Protocol:      lane.Protocol,
+			Policy:        lane.Stack.Policy,
+			Masker:        lane.Stack.Masker,
+			Limits:        lane.Stack.Limits,
+			ObserveLimits: lane.Stack.ObserveLimits,
+			Audit:         sink,
+			CodecFactory:  lane.CodecFactory,
+		})
+		if err != nil {
+			rep.Skipped = append(rep.Skipped, Skip{Flow: i, Reason: err.Error()})
+			continue
+		}
+
+		for ci, c := range f.Chunks {
+			if lane.Clock != nil && !c.Time.IsZero() {
+				lane.Clock(c.Time)
+			}
+			var d gate.Decision
+			if c.Direction == hoopinspect.FromClient {
+				d = g.Request(ctx, c.Data)
+			} else {
+				d = g.Response(ctx, c.Data)
+			}
+			rep.record(i, ci, c.Direction, d, sink.drain())
+		}
+		_ = g.FlushResponse()
+		_ = g.Close(ctx)
+	}
+	return rep
+}
+
+// record files one read's decision and the events the gate wrote for it.
+func (r *Report) record(flow, chunk int, dir hoopinspect.Direction, d gate.Decision, events []audit.Event) {
+	judged := 0
+	rw := Rewrite{Flow: flow, Chunk: chunk}
+	for _, ev := range events {
+		switch {
+		case ev.Metadata["limit.action"] != "":
+			rw.Limit, rw.LimitAction = ev.Rule, ev.Metadata["limit.action"]
+		case ev.Kind == audit.KindStatement || ev.Kind == audit.KindViolation:
+			v := Verdict{
+				Flow: flow, Chunk: chunk, Index: judged,
+				Direction: ev.Direction, Operation: ev.Operation, Statement: ev.Statement,
+				Outcome: OutcomeAllowed,
+			}
+			if !ev.Allowed {
+				v.Outcome, v.Rule, v.Message = OutcomeDenied, ev.Rule, ev.Message
+			}
+			r.Verdicts = append(r.Verdicts, v)
+			judged++
+		case ev.Kind == audit.KindMasked:
+			rw.MaskedCells += ev.MaskedCount
+			for _, e := range ev.MaskedEntities {
+				if !slices.Contains(rw.Entities, e) {
+					rw.Entities = append(rw.Entities, e)
+				}
+			}
+		case ev.Kind == audit.KindError:
+			r.Errors = append(r.Errors, fmt.Sprintf("flow %d read %d: %s", flow, chunk, ev.Error))
+		}
+	}
+
+	switch {
+	case d.Offending != nil:
+		for _, stmt := range d.Statements[min(judged, len(d.Statements)):] {
+			r.Verdicts = append(r.Verdicts, Verdict{
+				Flow: flow, Chunk: chunk, Index: judged,
+				Direction: stmt.Direction, Operation: stmt.Operation, Statement: stmt.Text,
+				Outcome: OutcomeUnreached,
+			})
+			judged++
+		}
+	case !d.Allowed && rw.Limit == "":
+		// Denied with no statement to blame: the codec refused a stream it
+		// could not follow past this point, whatever the policy said.
+		r.Verdicts = append(r.Verdicts, Verdict{
+			Flow: flow, Chunk: chunk, Index: judged, Direction: dir,
+			Outcome: OutcomeDenied, Rule: d.Rule, Message: d.Message,
+		})
+	}
+	if rw.MaskedCells > 0 || rw.Limit != "" {
+		r.Rewrites = append(r.Rewrites, rw)
+	}
+}
+
+// collector is the audit sink a replay reads its gate's decisions from.
+// The gate writes from the caller's goroutine, so it needs no lock.
+type collector struct{ events []audit.Event }
+
+func (c *collector) Write(_ context.Context, ev audit.Event) error {
+	c.events = append(c.events, ev)
+	return nil
+}
+
+func (c *collector) Close() error { return nil }
+
+func (c *collector) drain() []audit.Event {
+	out := c.events
+	c.events = nil
+	return out
+}
+
+// Change is one place two reports disagree.
+type Change struct {
+	Flow, Chunk, Index int
+
+	// Statement is the text the two verdicts are about, empty for a
+	// rewrite, which is about a response.
+	Statement string
+
+	Before, After string
+}
+
+// Diff lists every statement and response read that before and after
+// treated differently, in capture order. Both reports must come from the
+// same capture.
+func Diff(before, after Report) []Change {
+	type key struct{ flow, chunk, index int }
+	var out []Change
+
+	verdicts := map[key][2]Verdict{}
+	for side, rep := range [2]Report{before, after} {
+		for _, v := range rep.Verdicts {
+			k := key{v.Flow, v.Chunk, v.Index}
+			pair := verdicts[k]
+			pair[side] = v
+			verdicts[k] = pair
+		}
+	}
+	for k, pair := range verdicts {
+		b, a := pair[0], pair[1]
+		if b.Outcome == a.Outcome && b.Rule == a.Rule {
+			continue
+		}
+		stmt := cmp.Or(a.Statement, b.Statement)
+		out = append(out, Change{Flow: k.flow, Chunk: k.chunk, Index: k.index,
+			Statement: stmt, Before: b.String(), After: a.String()})
+	}
+
+	rewrites := map[key][2]Rewrite{}
+	for side, rep := range [2]Report{before, after} {
+		for _, rw := range rep.Rewrites {
+			// Index -1 sorts a read's rewrite ahead of its statements.
+			k := key{rw.Flow, rw.Chunk, -1}
+			pair := rewrites[k]
+			pair[side] = rw
+			rewrites[k] = pair
+		}
+	}
+	for k, pair := range rewrites {
+		b, a := pair[0].String(), pair[1].String()
+		if b == a {
+			continue
+		}
+		out = append(out, Change{Flow: k.flow, Chunk: k.chunk, Index: k.index, Before: b, After: a})
+	}
+
+	slices.SortFunc(out, func(x, y Change) int {
+		return cmp.Or(cmp.Compare(x.Flow, y.Flow), cmp.Compare(x.Chunk, y.Chunk), cmp.Compare(x.Index, y.Index))
+	})
+	return out
+}
@@ -0,0 +1,130 @@
+package replay_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+	_ "github.com/hoophq/hoopinspect/codec/all"
+	"github.com/hoophq/hoopinspect/gate"
+	"github.com/hoophq/hoopinspect/policy"
+	"github.com/hoophq/hoopinspect/replay"
+)
+
+func pgQuery(sql string) []byte {
+	var b bytes.Buffer
+	b.WriteByte('Q')
+	binary.Write(&b, binary.BigEndian, uint32(len(sql)+5))
+	b.WriteString(sql)
+	b.WriteByte(0)
+	return b.Bytes()
+}
+
+func pgStartup(user string) []byte {
+	params := "user\x00" + user + "\x00\x00"
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(params)))
+	b = binary.BigEndian.AppendUint32(b, 3<<16)
+	return append(b, params...)
+}
+
+func pgSSLRequest() []byte {
+	return binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 8), 80877103)
+}
+
+func client(b []byte) replay.Chunk { return replay.Chunk{Direction: hoopinspect.FromClient, Data: b} }
+func server(b []byte) replay.Chunk { return replay.Chunk{Direction: hoopinspect.FromServer, Data: b} }
+
+// pgFlow is a session that asked for TLS, was refused, and then ran the
+// given queries, one read each.
+func pgFlow(answer byte, queries ...string) replay.Flow {
+	f := replay.Flow{Chunks: []replay.Chunk{
+		client(pgSSLRequest()),
+		server([]byte{answer}),
+		client(pgStartup("alice")),
+	}}
+	for _, q := range queries {
+		f.Chunks = append(f.Chunks, client(pgQuery(q)))
+	}
+	return f
+}
+
+func denyDrops(t *testing.T) gate.Stack {
+	t.Helper()
+	r, err := policy.NewRules([]policy.Rule{{
+		Name:       "no-destructive",
+		Type:       policy.MatchOperation,
+		Operations: []hoopinspect.Operation{hoopinspect.OpDrop, hoopinspect.OpDelete},
+	}})
+	if err != nil {
+		t.Fatalf("NewRules: %v", err)
+	}
+	return gate.Stack{Policy: r}
+}
+
+func outcomes(rep replay.Report) []replay.Outcome {
+	out := make([]replay.Outcome, 0, len(rep.Verdicts))
+	for _, v := range rep.Verdicts {
+		out = append(out, v.Outcome)
+	}
+	return out
+}
+
+// A replay reports every statement in the capture: the ones after a denied
+// read are still judged, and the ones inside it are unreached.
+func TestRunContinuesPastDenials(t *testing.T) {
+	flows := []replay.Flow{pgFlow('N', "SELECT 1; DROP TABLE t; SELECT 2", "DELETE FROM t", "SELECT 3")}
+	rep := replay.Run(context.Background(), flows,
+		replay.Lane{Protocol: hoopinspect.Postgres, Stack: denyDrops(t)})
+
+	if len(rep.Skipped) != 0 || len(rep.Errors) != 0 {
+		t.Fatalf("skipped %v, errors %v", rep.Skipped, rep.Errors)
+	}
+	want := []replay.Outcome{
+		replay.OutcomeAllowed, replay.OutcomeDenied, replay.OutcomeUnreached,
+		replay.OutcomeDenied, replay.OutcomeAllowed,
+	}
+	got := outcomes(rep)
+	if len(got) != len(want) {
+		t.Fatalf("outcomes = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("outcomes = %v, want %v", got, want)
+		}
+	}
+	if v := rep.Verdicts[1]; v.Rule != "no-destructive" || v.Operation != hoopinspect.OpDrop {
+		t.Errorf("denied verdict = %+v", v)
+	}
+	if tot := rep.Totals(); tot.Statements != 5 || tot.Denied != 2 || tot.Unreached != 1 {
+		t.Errorf("totals = %+v", tot)
+	}
+}
+
+func TestRunSkipsEncryptedSessions(t *testing.T) {
+	rep := replay.Run(context.Background(), []replay.Flow{pgFlow('S', "SELECT 1")},
+		replay.Lane{Protocol: hoopinspect.Postgres})
+	if len(rep.Skipped) != 1 || len(rep.Verdicts) != 0 {
+		t.Errorf("skipped %v, verdicts %v", rep.Skipped, rep.Verdicts)
+	}
+}
+
+func TestDiffListsChangedVerdicts(t *testing.T) {
+	flows := []replay.Flow{pgFlow('N', "SELECT 1", "DROP TABLE t")}
+	before := replay.Run(context.Background(), flows, replay.Lane{Protocol: hoopinspect.Postgres})
+	after := replay.Run(context.Background(), flows,
+		replay.Lane{Protocol: hoopinspect.Postgres, Stack: denyDrops(t)})
+
+	changes := replay.Diff(before, after)
+	if len(changes) != 1 {
+		t.Fatalf("changes = %+v, want 1", changes)
+	}
+	c := changes[0]
+	if c.Statement != "DROP TABLE t" || c.Before != "allow" || c.After != "deny (no-destructive)" {
+		t.Errorf("change = %+v", c)
+	}
+	if len(replay.Diff(after, after)) != 0 {
+		t.Error("a report differs from itself")
+	}
+}
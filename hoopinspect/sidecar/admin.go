@@ -0,0 +1,333 @@
+package sidecar
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hoophq/hoopinspect/analyzer"
+)
+
+// The admin scopes. Each names a group of endpoints a credential may reach.
+const (
+	ScopeHealth = "health" // GET /healthz
+	ScopeStats  = "stats"  // GET /stats and /metrics
+	ScopeConfig = "config" // GET /config
+	ScopeReload = "reload" // POST /config/reload
+	ScopeAudit  = "audit"  // GET /events and /api/...: statement text and identities
+)
+
+var adminScopes = []string{ScopeHealth, ScopeStats, ScopeConfig, ScopeReload, ScopeAudit}
+
+// scopeOf names the scope a request needs. An unknown path needs a
+// credential of any kind, so an anonymous caller cannot map the endpoint by
+// telling 404 from 401.
+func scopeOf(r *http.Request) string {
+	switch p := r.URL.Path; {
+	case p == "/healthz":
+		return ScopeHealth
+	case p == "/stats", p == "/metrics":
+		return ScopeStats
+	case p == "/config/reload":
+		return ScopeReload
+	case p == "/config":
+		return ScopeConfig
+	case p == "/events", strings.HasPrefix(p, "/api/"):
+		return ScopeAudit
+	}
+	return ""
+}
+
+// enabled reports whether the endpoint authenticates anyone.
+func (a AdminConfig) enabled() bool { return len(a.Tokens) > 0 || len(a.Clients) > 0 }
+
+// anonymous is the scopes a request without a credential reaches.
+func (a AdminConfig) anonymous() []string {
+	if !a.enabled() {
+		return adminScopes
+	}
+	if a.Anonymous == nil {
+		return []string{ScopeHealth}
+	}
+	return a.Anonymous
+}
+
+// validate checks the admin section, reading every file it names so a bad
+// path or a world-readable token fails -validate rather than the first probe.
+func (a AdminConfig) validate() []string {
+	var problems []string
+	if a.Listen == "" {
+		if a.TLS != nil || a.enabled() || a.Anonymous != nil {
+			problems = append(problems, "admin: tls, tokens, clients or anonymous set but admin.listen is empty")
+		}
+		return problems
+	}
+	if a.TLS != nil {
+		if _, err := a.TLS.build(); err != nil {
+			problems = append(problems, "admin.tls: "+err.Error())
+		}
+	}
+
+	checkScopes := func(who string, scopes []string, required bool) {
+		if required && len(scopes) == 0 {
+			problems = append(problems, who+": no scopes")
+		}
+		for _, s := range scopes {
+			if !slices.Contains(adminScopes, s) {
+				problems = append(problems, fmt.Sprintf("%s: unknown scope %q (one of %s)",
+					who, s, strings.Join(adminScopes, ", ")))
+			}
+		}
+	}
+	names := map[string]bool{}
+	checkName := func(kind string, i int, name string) string {
+		who := fmt.Sprintf("admin.%s[%d]", kind, i)
+		if name == "" {
+			problems = append(problems, who+": no name")
+			return who
+		}
+		who = fmt.Sprintf("admin.%s[%s]", kind, name)
+		if names[name] {
+			problems = append(problems, who+": duplicate name")
+		}
+		names[name] = true
+		return who
+	}
+
+	for i, t := range a.Tokens {
+		who := checkName("tokens", i, t.Name)
+		checkScopes(who, t.Scopes, true)
+		if t.File == "" {
+			problems = append(problems, who+": no file")
+		} else if s, err := analyzer.ReadSecretFile(t.File); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", who, err))
+		} else if s.IsZero() {
+			problems = append(problems, fmt.Sprintf("%s: %s is empty", who, t.File))
+		}
+	}
+	// A bearer token crossing a network in the clear is a disclosed token.
+	// Loopback stays allowed: nothing else can see that traffic, and it is
+	// where a probe through kubectl port-forward lands.
+	if len(a.Tokens) > 0 && a.TLS == nil && !loopback(a.Listen) {
+		problems = append(problems, fmt.Sprintf(
+			"admin: tokens on %s need admin.tls; a bearer token sent in the clear "+
+				"off loopback is disclosed to the network", a.Listen))
+	}
+
+	for i, c := range a.Clients {
+		who := checkName("clients", i, c.Name)
+		checkScopes(who, c.Scopes, true)
+		if c.CommonName == "" && c.URI == "" {
+			problems = append(problems, who+": set common_name, uri or both")
+		}
+	}
+	if len(a.Clients) > 0 && (a.TLS == nil || a.TLS.ClientCAFile == "") {
+		problems = append(problems, "admin: clients need admin.tls.client_ca_file to verify certificates against")
+	}
+
+	checkScopes("admin.anonymous", a.Anonymous, false)
+	if a.Anonymous != nil && !a.enabled() {
+		// Without a credential to hold, anonymous is everyone, and a
+		// narrowed list here would read as a restriction that is not there.
+		problems = append(problems, "admin: anonymous set with no tokens or clients, so every endpoint is open anyway")
+	}
+	return problems
+}
+
+// build turns the admin TLS section into a server config.
+func (t *AdminTLSConfig) build() (*tls.Config, error) {
+	if t.CertFile == "" || t.KeyFile == "" {
+		return nil, fmt.Errorf("needs both cert_file and key_file")
+	}
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("keypair: %w", err)
+	}
+	out := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if t.ClientCAFile != "" {
+		pem, err := os.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client_ca_file %q contains no usable certificate", t.ClientCAFile)
+		}
+		out.ClientCAs = pool
+		// IfGiven rather than Require: the probe that reaches /healthz
+		// and the operator holding a token carry no certificate, and a
+		// certificate that IS presented is still verified in full.
+		out.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return out, nil
+}
+
+// loopback reports whether a listen address binds only loopback.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// adminAuth decides which admin requests to serve.
+type adminAuth struct {
+	enabled   bool
+	anonymous []string
+	tokens    []adminToken
+	clients   []AdminClient
+	tls       *tls.Config
+}
+
+type adminToken struct {
+	name   string
+	sum    [sha256.Size]byte
+	scopes []string
+}
+
+// buildAdminAuth reads the credentials the admin section names. It runs
+// before the endpoint starts, so a token file that went missing since
+// -validate fails startup instead of locking out every caller.
+func buildAdminAuth(a AdminConfig) (*adminAuth, error) {
+	out := &adminAuth{enabled: a.enabled(), anonymous: a.anonymous(), clients: a.Clients}
+	if a.TLS != nil {
+		cfg, err := a.TLS.build()
+		if err != nil {
+			return nil, fmt.Errorf("admin.tls: %w", err)
+		}
+		out.tls = cfg
+	}
+	for _, t := range a.Tokens {
+		s, err := analyzer.ReadSecretFile(t.File)
+		if err != nil {
+			return nil, fmt.Errorf("admin.tokens[%s]: %w", t.Name, err)
+		}
+		// Compared as digests, so the comparison takes the same time
+		// whatever the length of the guess.
+		out.tokens = append(out.tokens, adminToken{
+			name: t.Name, sum: sha256.Sum256(s.Bytes()), scopes: t.Scopes,
+		})
+	}
+	return out, nil
+}
+
+// adminCaller carries the authenticated credential's name on a request.
+type adminCaller struct{}
+
+// callerOf names who made an admin request, or "" for an anonymous one.
+func callerOf(r *http.Request) string {
+	name, _ := r.Context().Value(adminCaller{}).(string)
+	return name
+}
+
+// wrap puts authentication in front of the admin handlers.
+//
+// A request presenting a bearer token that matches nothing is refused even
+// on an anonymous path: a wrong credential is a misconfiguration worth
+// surfacing, and a probe that means to be anonymous sends none.
+func (a *adminAuth) wrap(next http.Handler, log *slog.Logger) http.Handler {
+	if !a.enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		need := scopeOf(r)
+		granted := slices.Clone(a.anonymous)
+		var callers []string
+
+		if cert := verifiedLeaf(r); cert != nil {
+			for _, c := range a.clients {
+				if certMatches(cert, c) {
+					callers = append(callers, c.Name)
+					granted = append(granted, c.Scopes...)
+				}
+			}
+		}
+		if h := r.Header.Get("Authorization"); h != "" {
+			tok, ok := strings.CutPrefix(h, "Bearer ")
+			t := a.token(tok)
+			if !ok || t == nil {
+				a.refuse(w, r, log, http.StatusUnauthorized, need, "", "bad bearer token")
+				return
+			}
+			callers = append(callers, t.name)
+			granted = append(granted, t.scopes...)
+		}
+
+		caller := strings.Join(callers, ",")
+		switch {
+		case need != "" && slices.Contains(granted, need):
+		case need == "" && caller != "":
+			// Authenticated; let the mux answer for an unknown path.
+		case caller == "":
+			a.refuse(w, r, log, http.StatusUnauthorized, need, "", "no credential")
+			return
+		default:
+			a.refuse(w, r, log, http.StatusForbidden, need, caller, "scope not granted")
+			return
+		}
+		if caller != "" {
+			r = r.WithContext(context.WithValue(r.Context(), adminCaller{}, caller))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *adminAuth) token(presented string) *adminToken {
+	sum := sha256.Sum256([]byte(presented))
+	var found *adminToken
+	// Every token is compared, so timing does not say which one was close.
+	for i := range a.tokens {
+		if subtle.ConstantTimeCompare(sum[:], a.tokens[i].sum[:]) == 1 && found == nil {
+			found = &a.tokens[i]
+		}
+	}
+	return found
+}
+
+func (a *adminAuth) refuse(w http.ResponseWriter, r *http.Request, log *slog.Logger, status int, need, caller, why string) {
+	log.Warn("admin request refused",
+		"method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr,
+		"scope", need, "caller", caller, "reason", why)
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="hoop-inspect admin"`)
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// verifiedLeaf is the client certificate the handshake verified, if any.
+// An unverified one never reaches here with client_ca_file set, and is
+// ignored without it.
+func verifiedLeaf(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+func certMatches(cert *x509.Certificate, c AdminClient) bool {
+	if c.CommonName != "" && cert.Subject.CommonName != c.CommonName {
+		return false
+	}
+	if c.URI != "" && !slices.ContainsFunc(cert.URIs, func(u *url.URL) bool { return u.String() == c.URI }) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package sidecar
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func tokenFile(t *testing.T, token string, mode os.FileMode) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(p, []byte(token+"\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// adminHandler is the admin mux's shape without its dependencies: every
+// path answers 200, so a status other than 200 is the authenticator's.
+func adminHandler(t *testing.T, cfg AdminConfig) http.Handler {
+	t.Helper()
+	auth, err := buildAdminAuth(cfg)
+	if err != nil {
+		t.Fatalf("buildAdminAuth: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, callerOf(r))
+	})
+	return auth.wrap(mux, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func adminGet(h http.Handler, method, path, token string, cert *x509.Certificate) (int, string) {
+	r := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if cert != nil {
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code, w.Body.String()
+}
+
+// The split the security review asked for: a probe reaches /healthz with
+// nothing, and statement text needs a reader credential.
+func TestAdminScopesSeparateProbesFromAuditReaders(t *testing.T) {
+	h := adminHandler(t, AdminConfig{
+		Listen: "127.0.0.1:9090",
+		Tokens: []AdminToken{
+			{Name: "scraper", File: tokenFile(t, "s3cret-scrape", 0o600), Scopes: []string{ScopeStats}},
+			{Name: "auditor", File: tokenFile(t, "s3cret-audit", 0o600), Scopes: []string{ScopeAudit, ScopeConfig}},
+		},
+	})
+
+	for _, c := range []struct {
+		method, path, token string
+		want                int
+	}{
+		{"GET", "/healthz", "", http.StatusOK},
+		{"GET", "/stats", "", http.StatusUnauthorized},
+		{"GET", "/stats", "s3cret-scrape", http.StatusOK},
+		{"GET", "/metrics", "s3cret-scrape", http.StatusOK},
+		{"GET", "/metrics", "s3cret-audit", http.StatusForbidden},
+		{"GET", "/api/sessions", "s3cret-scrape", http.StatusForbidden},
+		{"GET", "/api/sessions", "s3cret-audit", http.StatusOK},
+		{"GET", "/events", "", http.StatusUnauthorized},
+		{"POST", "/config/reload", "s3cret-audit", http.StatusForbidden},
+		{"GET", "/healthz", "guess", http.StatusUnauthorized},
+		{"GET", "/nowhere", "", http.StatusUnauthorized},
+	} {
+		if got, _ := adminGet(h, c.method, c.path, c.token, nil); got != c.want {
+			t.Errorf("%s %s with %q = %d, want %d", c.method, c.path, c.token, got, c.want)
+		}
+	}
+	if _, caller := adminGet(h, "GET", "/api/events", "s3cret-audit", nil); caller != "auditor" {
+		t.Errorf("caller = %q, want auditor", caller)
+	}
+}
+
+func TestAdminClientCertificateGrantsItsScopes(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://prod/ns/ops/sa/deployer")
+	h := adminHandler(t, AdminConfig{
+		Listen:    ":9090",
+		Clients:   []AdminClient{{Name: "deployer", URI: spiffe.String(), Scopes: []string{ScopeReload}}},
+		Anonymous: []string{},
+	})
+	deployer := &x509.Certificate{Subject: pkix.Name{CommonName: "deploy"}, URIs: []*url.URL{spiffe}}
+	stranger := &x509.Certificate{Subject: pkix.Name{CommonName: "deploy"}}
+
+	if got, caller := adminGet(h, "POST", "/config/reload", "", deployer); got != http.StatusOK || caller != "deployer" {
+		t.Errorf("deployer reload = %d as %q, want 200 as deployer", got, caller)
+	}
+	if got, _ := adminGet(h, "POST", "/config/reload", "", stranger); got != http.StatusUnauthorized {
+		t.Errorf("unmatched certificate reload = %d, want 401", got)
+	}
+	// anonymous: [] closes /healthz too.
+	if got, _ := adminGet(h, "GET", "/healthz", "", nil); got != http.StatusUnauthorized {
+		t.Errorf("closed /healthz = %d, want 401", got)
+	}
+}
+
+func TestAdminWithoutCredentialsStaysOpen(t *testing.T) {
+	h := adminHandler(t, AdminConfig{Listen: "127.0.0.1:9090"})
+	for _, path := range []string{"/healthz", "/api/sessions", "/events"} {
+		if got, _ := adminGet(h, "GET", path, "", nil); got != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200 with authentication off", path, got)
+		}
+	}
+}
+
+func TestAdminConfigValidation(t *testing.T) {
+	good := tokenFile(t, "tok", 0o600)
+	for name, c := range map[string]struct {
+		cfg  AdminConfig
+		want string
+	}{
+		"token in the clear off loopback": {
+			AdminConfig{Listen: ":9090", Tokens: []AdminToken{{Name: "a", File: good, Scopes: []string{ScopeAudit}}}},
+			"need admin.tls",
+		},
+		"world-readable token": {
+			AdminConfig{Listen: "127.0.0.1:9090", Tokens: []AdminToken{{Name: "a", File: tokenFile(t, "tok", 0o644), Scopes: []string{ScopeAudit}}}},
+			"want 0600",
+		},
+		"unknown scope": {
+			AdminConfig{Listen: "127.0.0.1:9090", Tokens: []AdminToken{{Name: "a", File: good, Scopes: []string{"read"}}}},
+			`unknown scope "read"`,
+		},
+		"clients without a client CA": {
+			AdminConfig{Listen: "127.0.0.1:9090", Clients: []AdminClient{{Name: "c", CommonName: "x", Scopes: []string{ScopeStats}}}},
+			"client_ca_file",
+		},
+		"tls without a keypair": {
+			AdminConfig{Listen: "127.0.0.1:9090", TLS: &AdminTLSConfig{}},
+			"cert_file and key_file",
+		},
+		"anonymous with nothing to restrict": {
+			AdminConfig{Listen: "127.0.0.1:9090", Anonymous: []string{ScopeHealth}},
+			"every endpoint is open",
+		},
+	} {
+		problems := strings.Join(c.cfg.validate(), "; ")
+		if !strings.Contains(problems, c.want) {
+			t.Errorf("%s: problems = %q, want %q", name, problems, c.want)
+		}
+	}
+
+	ok := AdminConfig{Listen: "localhost:9090", Tokens: []AdminToken{{Name: "a", File: good, Scopes: []string{ScopeAudit}}}}
+	if problems := ok.validate(); len(problems) > 0 {
+		t.Errorf("loopback token config refused: %v", problems)
+	}
+}
@@ -29,12 +29,19 @@ type HTTPCodecConfig struct {
 	// CaptureBody includes request and response bodies in the Statement.
 	CaptureBody bool `json:"capture_body"`
 
-	// MaxBodyBytes truncates a captured body. Zero uses the codec default.
+	// MaxBodyBytes truncates a captured body, and bounds the GraphQL
+	// request the codec reads. Zero uses the codec default.
 	MaxBodyBytes int `json:"max_body_bytes,omitempty"`
 
 	// Headers names the headers to expose, matched case-insensitively.
 	// There is no capture-all.
 	Headers []string `json:"headers,omitempty"`
+
+	// GraphQLPersistedQueries maps a persisted-query hash to its document.
+	// A client sending a hash this map does not hold is refused as
+	// unreadable, so a lane in front of a persisted-query API lists the
+	// documents its clients were built with.
+	GraphQLPersistedQueries map[string]string `json:"graphql_persisted_queries,omitempty"`
 }
 
 // forbiddenHeaders are never allowlistable.
@@ -63,6 +70,7 @@ func (h *HTTPCodecConfig) validate(lane string) []string {
 	if h.MaxBodyBytes < 0 {
 		problems = append(problems, fmt.Sprintf("listener %q: http.max_body_bytes is negative", lane))
 	}
+	problems = append(problems, validateGraphQLDocuments(lane, h.GraphQLPersistedQueries)...)
 	return problems
 }
 
@@ -379,6 +387,7 @@ func buildAnalyzerEvaluators(
 			CacheTTL:      time.Duration(cfg.Cache.TTLSec) * time.Second,
 			MaxCalls:      cfg.MaxCalls,
 			Redact:        redact,
+			Observe:       metrics.observeAnalyzer(r.Name),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
@@ -176,6 +176,25 @@ func TestOrdinaryHeaderIsAccepted(t *testing.T) {
 	}
 }
 
+// A persisted query that does not parse would refuse every request naming its
+// hash; it is reported at load instead.
+func TestUnparsablePersistedQueryIsRefused(t *testing.T) {
+	mk := func(doc string) *Config {
+		return &Config{
+			Listeners: []ListenerConfig{{
+				Name: "api", Protocol: "http", Listen: ":1", Upstream: "h:1",
+				HTTP: &HTTPCodecConfig{GraphQLPersistedQueries: map[string]string{"abc": doc}},
+			}},
+		}
+	}
+	if err := mk(`query { users { id }`).Validate(); err == nil || !strings.Contains(err.Error(), `"abc"`) {
+		t.Errorf("err = %v, want the broken document named by its hash", err)
+	}
+	if err := mk(`query { users { id } }`).Validate(); err != nil {
+		t.Errorf("a valid document was refused: %v", err)
+	}
+}
+
 // A credential in an endpoint URL would be published by GET /config, which
 // reports the analyzer endpoint.
 func TestEndpointCarryingCredentialsIsRefused(t *testing.T) {
@@ -0,0 +1,167 @@
+package sidecar
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hoophq/hoopinspect/analyzer"
+	"github.com/hoophq/hoopinspect/audit"
+)
+
+// validateHashChain checks the hash_chain block, reading the signing key so a
+// bad one fails -validate rather than the first checkpoint.
+func (a AuditConfig) validateHashChain() []string {
+	hc := a.HashChain
+	if hc == nil {
+		return nil
+	}
+	var problems []string
+	// The chain resumes from the file on every start. Stdout has nothing to
+	// resume from, so each restart would begin a second chain at seq 1 and
+	// every verification after it would report the join as tampering.
+	if a.File == "" || a.File == "-" {
+		problems = append(problems, "audit.hash_chain needs audit.file; a chain on stdout restarts at every start")
+	}
+	switch {
+	case hc.CheckpointEvery < 0:
+		problems = append(problems, "audit.hash_chain.checkpoint_every is negative")
+	case hc.CheckpointEvery > 0 && hc.SigningKeyFile == "":
+		problems = append(problems, "audit.hash_chain.checkpoint_every is set but signing_key_file is not")
+	case hc.CheckpointEvery == 0 && hc.SigningKeyFile != "":
+		problems = append(problems, "audit.hash_chain.signing_key_file is set but checkpoint_every is not, so nothing is signed")
+	}
+	if hc.SigningKeyFile != "" {
+		if _, err := hc.signingKey(); err != nil {
+			problems = append(problems, fmt.Sprintf("audit.hash_chain: %v", err))
+		}
+	}
+	return problems
+}
+
+func (hc *HashChainConfig) signingKey() (ed25519.PrivateKey, error) {
+	if hc.SigningKeyFile == "" {
+		return nil, nil
+	}
+	s, err := analyzer.ReadSecretFile(hc.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return audit.ParseSigningKey(s.Bytes())
+}
+
+// buildHashChain returns the chain for the audit file at path, resumed after
+// the last record already in it.
+func buildHashChain(hc *HashChainConfig, path string) (*audit.Chain, error) {
+	key, err := hc.signingKey()
+	if err != nil {
+		return nil, fmt.Errorf("audit.hash_chain: %w", err)
+	}
+	c := audit.NewChain(audit.ChainOptions{CheckpointEvery: hc.CheckpointEvery, SigningKey: key})
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open audit file: %w", err)
+	}
+	defer f.Close()
+	seq, hash, err := audit.ChainTail(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c.Resume(seq, hash)
+	return c, nil
+}
+
+// auditMain is the audit subcommand. verify is its only verb for now; the
+// noun comes first so the next one has somewhere to go.
+//
+// It reads JSONL only. A sqlite store is verified with its VerifyChain,
+// from the program that embeds it: this binary does not link a database
+// driver (see AuditConfig.QuerySessions).
+func auditMain(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, "usage: hoop-inspect audit verify -file audit.jsonl [-public-key key.pub]")
+		return fmt.Errorf("%w: want \"audit verify\"", ErrUsage)
+	}
+	fs := flag.NewFlagSet("hoop-inspect audit verify", flag.ContinueOnError)
+	var (
+		file   = fs.String("file", "", "JSONL audit file to verify")
+		pubKey = fs.String("public-key", "", "Ed25519 public key (PEM) to check checkpoints with")
+	)
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+	if *file == "" {
+		fs.Usage()
+		return fmt.Errorf("%w: -file is required", ErrUsage)
+	}
+
+	var pub ed25519.PublicKey
+	if *pubKey != "" {
+		b, err := os.ReadFile(*pubKey)
+		if err != nil {
+			return err
+		}
+		if pub, err = audit.ParseVerifyKey(b); err != nil {
+			return fmt.Errorf("%s: %w", *pubKey, err)
+		}
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rep, err := audit.VerifyJSONL(f, pub)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *file, err)
+	}
+	return reportChain(out, *file, rep, pub != nil)
+}
+
+// reportChain prints what verification found and returns an error when it
+// found anything, so the exit status is the verdict a cron job checks.
+func reportChain(out io.Writer, name string, rep audit.ChainReport, keyed bool) error {
+	fmt.Fprintf(out, "%s: %d record(s)", name, rep.Records)
+	if rep.Last > 0 {
+		fmt.Fprintf(out, ", chain seq %d-%d", rep.First, rep.Last)
+	}
+	if rep.Unchained > 0 {
+		fmt.Fprintf(out, ", %d written before the chain", rep.Unchained)
+	}
+	fmt.Fprintln(out)
+
+	switch {
+	case rep.Checkpoints > 0:
+		fmt.Fprintf(out, "  %d checkpoint(s) verified; signed through seq %d", rep.Checkpoints, rep.Signed)
+		if rep.Last > rep.Signed {
+			fmt.Fprintf(out, ", %d record(s) after it covered by hashes only", rep.Last-rep.Signed)
+		}
+		fmt.Fprintln(out)
+	case rep.Unverified > 0:
+		fmt.Fprintf(out, "  %d checkpoint(s) not checked: no -public-key\n", rep.Unverified)
+	case keyed:
+		fmt.Fprintln(out, "  no checkpoints: nothing is signed")
+	}
+
+	for _, p := range rep.Problems {
+		fmt.Fprintf(out, "  %s\n", p)
+	}
+	if !rep.OK() {
+		return fmt.Errorf("%s: %d chain problem(s)", name, len(rep.Problems))
+	}
+	if rep.Last == 0 {
+		return fmt.Errorf("%s: no chained records", name)
+	}
+	fmt.Fprintln(out, "  chain OK")
+	return nil
+}
@@ -0,0 +1,205 @@
+package sidecar
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hoophq/hoopinspect/analyzer"
+	"github.com/hoophq/hoopinspect/audit"
+)
+
+// syslogFacilities are the facility names syslog.facility accepts. The
+// kernel's, mail's and the rest are left out: an audit trail filed under
+// them is one nobody looks for there.
+var syslogFacilities = map[string]int{
+	"user": 1, "daemon": 3, "auth": 4, "authpriv": 10,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// validateRemoteSinks checks the syslog, otlp and http blocks, reading every
+// key and header file so a bad one fails -validate rather than the first
+// event.
+func (a AuditConfig) validateRemoteSinks() []string {
+	var problems []string
+	if s := a.Syslog; s != nil {
+		if _, err := s.options(); err != nil {
+			problems = append(problems, fmt.Sprintf("audit.syslog: %v", err))
+		}
+	}
+	if h := a.HTTP; h != nil {
+		if _, err := h.options(); err != nil {
+			problems = append(problems, fmt.Sprintf("audit.http: %v", err))
+		}
+	}
+	if o := a.OTLP; o != nil {
+		if _, err := o.options(); err != nil {
+			problems = append(problems, fmt.Sprintf("audit.otlp: %v", err))
+		}
+	}
+	return problems
+}
+
+func (s *SyslogSinkConfig) options() (audit.SyslogOptions, error) {
+	o := audit.SyslogOptions{Network: s.Network, Addr: s.Addr, AppName: s.AppName}
+	switch s.Network {
+	case "udp", "tcp":
+		if s.TLS != nil {
+			return o, fmt.Errorf("tls is set but network is %s; use network: tls", s.Network)
+		}
+	case "tls":
+		c, err := s.TLS.BuildTLS()
+		if err != nil {
+			return o, fmt.Errorf("tls: %w", err)
+		}
+		o.TLS = c
+	default:
+		return o, fmt.Errorf("network %q: want udp, tcp or tls", s.Network)
+	}
+	if _, _, err := net.SplitHostPort(s.Addr); err != nil {
+		return o, fmt.Errorf("addr: %w", err)
+	}
+	if s.Facility != "" {
+		f, ok := syslogFacilities[s.Facility]
+		if !ok {
+			return o, fmt.Errorf("facility %q: want user, daemon, auth, authpriv or local0-local7", s.Facility)
+		}
+		o.Facility = f
+	}
+	return o, nil
+}
+
+func (h *HTTPSinkConfig) options() (audit.HTTPOptions, error) {
+	o := audit.HTTPOptions{URL: h.URL}
+	if err := collectorURL(h.URL); err != nil {
+		return o, fmt.Errorf("url: %w", err)
+	}
+	var err error
+	if o.Client, o.Header, err = h.RemoteSinkConfig.client(); err != nil {
+		return o, err
+	}
+	if h.HMACKeyFile != "" {
+		s, err := analyzer.ReadSecretFile(h.HMACKeyFile)
+		if err != nil {
+			return o, fmt.Errorf("hmac_key_file: %w", err)
+		}
+		o.HMACKey = s.Bytes()
+	}
+	return o, nil
+}
+
+func (c *OTLPSinkConfig) options() (audit.OTLPOptions, error) {
+	o := audit.OTLPOptions{Endpoint: c.Endpoint, ServiceName: c.ServiceName}
+	if err := collectorURL(c.Endpoint); err != nil {
+		return o, fmt.Errorf("endpoint: %w", err)
+	}
+	var err error
+	o.Client, o.Header, err = c.RemoteSinkConfig.client()
+	return o, err
+}
+
+// collectorURL refuses a URL events cannot safely be sent to. Plain http is
+// allowed only to loopback: the events carry statement text and the
+// identities that ran it, and a collector agent on the same host is the one
+// case where nobody else is on the wire.
+func collectorURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch {
+	case u.Host == "":
+		return fmt.Errorf("%q has no host", raw)
+	case u.Scheme == "https":
+		return nil
+	case u.Scheme == "http" && loopback(net.JoinHostPort(u.Hostname(), "0")):
+		return nil
+	case u.Scheme == "http":
+		return fmt.Errorf("%q is plain http to a host that is not loopback; use https", u.Redacted())
+	}
+	return fmt.Errorf("%q: want an http or https URL", u.Redacted())
+}
+
+// client builds the HTTP client and headers a remote sink sends with,
+// reading each header file.
+func (r RemoteSinkConfig) client() (*http.Client, http.Header, error) {
+	for _, f := range []struct {
+		name string
+		n    int
+	}{
+		{"max_batch", r.MaxBatch}, {"flush_interval_ms", r.FlushIntervalMS},
+		{"max_pending", r.MaxPending}, {"timeout_sec", r.TimeoutSec},
+	} {
+		if f.n < 0 {
+			return nil, nil, fmt.Errorf("%s is negative", f.name)
+		}
+	}
+	if r.Retries < -1 {
+		return nil, nil, fmt.Errorf("retries is %d; -1 turns retries off", r.Retries)
+	}
+
+	header := http.Header{}
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+	for k, path := range r.HeaderFiles {
+		s, err := analyzer.ReadSecretFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("header_files[%s]: %w", k, err)
+		}
+		header.Set(k, string(s.Bytes()))
+	}
+
+	tlsConf, err := r.TLS.BuildTLS()
+	if err != nil {
+		return nil, nil, fmt.Errorf("tls: %w", err)
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = tlsConf
+	return &http.Client{Transport: tr}, header, nil
+}
+
+func (r RemoteSinkConfig) batch() audit.BatchOptions {
+	return audit.BatchOptions{
+		MaxBatch:      r.MaxBatch,
+		FlushInterval: time.Duration(r.FlushIntervalMS) * time.Millisecond,
+		MaxPending:    r.MaxPending,
+		Retries:       r.Retries,
+		Timeout:       time.Duration(r.TimeoutSec) * time.Second,
+	}
+}
+
+// buildRemoteSinks returns the configured remote sinks, in the order
+// syslog, otlp, http.
+func buildRemoteSinks(cfg AuditConfig, opts audit.SinkOptions) ([]audit.Sink, error) {
+	var sinks []audit.Sink
+	if cfg.Syslog != nil {
+		o, err := cfg.Syslog.options()
+		if err != nil {
+			return nil, fmt.Errorf("audit.syslog: %w", err)
+		}
+		s, err := audit.NewSyslogSink(o, opts)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if cfg.OTLP != nil {
+		o, err := cfg.OTLP.options()
+		if err != nil {
+			return nil, fmt.Errorf("audit.otlp: %w", err)
+		}
+		sinks = append(sinks, audit.NewOTLPSink(o, opts, cfg.OTLP.batch()))
+	}
+	if cfg.HTTP != nil {
+		o, err := cfg.HTTP.options()
+		if err != nil {
+			return nil, fmt.Errorf("audit.http: %w", err)
+		}
+		sinks = append(sinks, audit.NewHTTPSink(o, opts, cfg.HTTP.batch()))
+	}
+	return sinks, nil
+}
@@ -0,0 +1,127 @@
+package sidecar
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hoophq/hoopinspect/audit"
+)
+
+func TestRemoteSinkValidation(t *testing.T) {
+	for name, c := range map[string]struct {
+		a    AuditConfig
+		want string
+	}{
+		"syslog over a unix socket": {
+			AuditConfig{Syslog: &SyslogSinkConfig{Network: "unix", Addr: "/dev/log"}},
+			`network "unix"`,
+		},
+		"syslog tls settings on tcp": {
+			AuditConfig{Syslog: &SyslogSinkConfig{Network: "tcp", Addr: "siem:514", TLS: &TLSConfig{}}},
+			"use network: tls",
+		},
+		"an unknown facility": {
+			AuditConfig{Syslog: &SyslogSinkConfig{Network: "udp", Addr: "siem:514", Facility: "mail"}},
+			`facility "mail"`,
+		},
+		"plain http off the host": {
+			AuditConfig{HTTP: &HTTPSinkConfig{URL: "http://siem.internal/ingest"}},
+			"use https",
+		},
+		"a world-readable hmac key": {
+			AuditConfig{HTTP: &HTTPSinkConfig{URL: "https://siem.internal/ingest",
+				HMACKeyFile: tokenFile(t, "k", 0o644)}},
+			"want 0600",
+		},
+		"an otlp header file that is missing": {
+			AuditConfig{OTLP: &OTLPSinkConfig{Endpoint: "https://otel/v1/logs",
+				RemoteSinkConfig: RemoteSinkConfig{HeaderFiles: map[string]string{"X-Api-Key": filepath.Join(t.TempDir(), "nope")}}}},
+			"header_files[X-Api-Key]",
+		},
+		"negative retries": {
+			AuditConfig{OTLP: &OTLPSinkConfig{Endpoint: "http://127.0.0.1:4318/v1/logs",
+				RemoteSinkConfig: RemoteSinkConfig{Retries: -2}}},
+			"retries is -2",
+		},
+	} {
+		problems := strings.Join(c.a.validateRemoteSinks(), "; ")
+		if !strings.Contains(problems, c.want) {
+			t.Errorf("%s: problems = %q, want %q", name, problems, c.want)
+		}
+	}
+
+	ok := AuditConfig{
+		Syslog: &SyslogSinkConfig{Network: "tls", Addr: "siem:6514", Facility: "authpriv"},
+		OTLP:   &OTLPSinkConfig{Endpoint: "http://localhost:4318/v1/logs"},
+		HTTP: &HTTPSinkConfig{URL: "https://siem.internal/ingest", HMACKeyFile: tokenFile(t, "k", 0o600),
+			RemoteSinkConfig: RemoteSinkConfig{Retries: -1}},
+	}
+	if problems := ok.validateRemoteSinks(); len(problems) > 0 {
+		t.Errorf("valid remote sinks were refused: %v", problems)
+	}
+}
+
+// The sinks arrive from config with the settings the file has, and an
+// unreachable collector fails the write the gate checks under fail_closed.
+func TestBuildAuditShipsToCollectors(t *testing.T) {
+	var mu sync.Mutex
+	var got []*http.Request
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, r)
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	key := tokenFile(t, "s3cret", 0o600)
+	ac, err := buildAudit(AuditConfig{
+		File:             filepath.Join(t.TempDir(), "audit.jsonl"),
+		RedactStatements: true,
+		HTTP: &HTTPSinkConfig{URL: srv.URL + "/ingest", HMACKeyFile: key,
+			RemoteSinkConfig: RemoteSinkConfig{HeaderFiles: map[string]string{"Authorization": tokenFile(t, "Bearer abc", 0o600)}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.sink.Write(context.Background(), audit.Event{Kind: audit.KindStatement, SessionID: "s1", Statement: "SELECT ssn FROM people"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || strings.Contains(bodies[0], "ssn") || !strings.Contains(bodies[0], `"statement":"sha256:`) {
+		t.Fatalf("requests = %d, bodies = %q; want one redacted batch", len(got), bodies)
+	}
+	r := got[0]
+	if r.Header.Get("Authorization") != "Bearer abc" ||
+		r.Header.Get(audit.HeaderSignature) != audit.SignBody([]byte("s3cret"), r.Header.Get(audit.HeaderTimestamp), []byte(bodies[0])) {
+		t.Errorf("headers = %v", r.Header)
+	}
+}
+
+func TestSyslogSinkFailsTheWriteWhenUnreachable(t *testing.T) {
+	ac, err := buildAudit(AuditConfig{
+		File:   filepath.Join(t.TempDir(), "audit.jsonl"),
+		Syslog: &SyslogSinkConfig{Network: "tcp", Addr: "127.0.0.1:1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ac.sink.Close()
+	if err := ac.sink.Write(context.Background(), audit.Event{Kind: audit.KindStatement}); err == nil ||
+		!strings.Contains(err.Error(), "audit: syslog") {
+		t.Errorf("err = %v, want the syslog sink's failure", err)
+	}
+}
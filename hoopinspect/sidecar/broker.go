@@ -0,0 +1,152 @@
+package sidecar
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/analyzer"
+	"github.com/hoophq/hoopinspect/proxy"
+	"github.com/hoophq/hoopinspect/session"
+)
+
+// validateBroker checks a listener's broker block, reading every secret it
+// names so a missing token fails -validate rather than a login.
+func validateBroker(l ListenerConfig, name string) []string {
+	b := l.Broker
+	if b == nil {
+		return nil
+	}
+	var problems []string
+	if l.Protocol != string(hoopinspect.Postgres) {
+		problems = append(problems, fmt.Sprintf("%s: broker is only supported on postgres, not %q", name, l.Protocol))
+	}
+	if b.User == "" {
+		problems = append(problems, name+": broker.user is empty")
+	}
+	if _, err := b.password(); err != nil {
+		problems = append(problems, fmt.Sprintf("%s: broker: %v", name, err))
+	}
+
+	if len(b.Clients) == 0 {
+		problems = append(problems, name+": broker has no clients, so nobody can log in")
+	}
+	names := map[string]bool{}
+	sums := map[[sha256.Size]byte]string{}
+	for i, c := range b.Clients {
+		who := fmt.Sprintf("%s: broker.clients[%d]", name, i)
+		if c.Name == "" {
+			problems = append(problems, who+": no name")
+		} else {
+			who = fmt.Sprintf("%s: broker.clients[%s]", name, c.Name)
+			if names[c.Name] {
+				problems = append(problems, who+": duplicate name")
+			}
+			names[c.Name] = true
+		}
+		tok, err := readToken(c.TokenFile)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", who, err))
+			continue
+		}
+		// One token for two names would log everyone holding it in as
+		// whichever is listed first, and the audit would say so with
+		// confidence.
+		sum := sha256.Sum256(tok.Bytes())
+		if other, dup := sums[sum]; dup {
+			problems = append(problems, fmt.Sprintf("%s: same token as %s", who, other))
+		}
+		sums[sum] = c.Name
+	}
+
+	// The token arrives as a cleartext password message. Loopback and a
+	// unix socket keep it on the host, as they keep an admin token there.
+	if l.DownstreamTLS == nil && l.Network != "unix" && !loopback(l.Listen) {
+		problems = append(problems, fmt.Sprintf(
+			"%s: broker on %s needs downstream_tls; a client sends its token as a "+
+				"cleartext password, disclosed to the network without it", name, l.Listen))
+	}
+	if l.ProxyProtocol.namesSubject() || (l.DownstreamTLS != nil && l.DownstreamTLS.ClientSubject != "") {
+		problems = append(problems, name+
+			": the subject comes from the broker's token or from proxy_protocol/client_subject, not both")
+	}
+	return problems
+}
+
+// password reads the upstream role's password from wherever the config put
+// it.
+func (b *BrokerConfig) password() (analyzer.Secret, error) {
+	switch {
+	case b.PasswordFile != "" && b.PasswordEnv != "":
+		return analyzer.Secret{}, fmt.Errorf("set password_file or password_env, not both")
+	case b.PasswordFile != "":
+		s, err := analyzer.ReadSecretFile(b.PasswordFile)
+		if err == nil && s.IsZero() {
+			err = fmt.Errorf("password_file %s is empty", b.PasswordFile)
+		}
+		return s, err
+	case b.PasswordEnv != "":
+		v := os.Getenv(b.PasswordEnv)
+		if v == "" {
+			return analyzer.Secret{}, fmt.Errorf("password_env %s is unset or empty", b.PasswordEnv)
+		}
+		return analyzer.NewSecret([]byte(v)), nil
+	}
+	return analyzer.Secret{}, fmt.Errorf("no password_file or password_env for user %q", b.User)
+}
+
+func readToken(path string) (analyzer.Secret, error) {
+	if path == "" {
+		return analyzer.Secret{}, fmt.Errorf("no token_file")
+	}
+	s, err := analyzer.ReadSecretFile(path)
+	if err == nil && s.IsZero() {
+		err = fmt.Errorf("token_file %s is empty", path)
+	}
+	return s, err
+}
+
+type brokerToken struct {
+	sum    [sha256.Size]byte
+	client BrokerClient
+}
+
+// buildBroker reads the broker's secrets once, at startup, and returns the
+// function proxy.Server checks each login with.
+func buildBroker(b BrokerConfig) (func(string, []byte) (proxy.Login, error), error) {
+	pw, err := b.password()
+	if err != nil {
+		return nil, fmt.Errorf("broker: %w", err)
+	}
+	var tokens []brokerToken
+	for _, c := range b.Clients {
+		tok, err := readToken(c.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("broker.clients[%s]: %w", c.Name, err)
+		}
+		tokens = append(tokens, brokerToken{sum: sha256.Sum256(tok.Bytes()), client: c})
+	}
+
+	return func(_ string, secret []byte) (proxy.Login, error) {
+		sum := sha256.Sum256(secret)
+		var found *BrokerClient
+		// Every token is compared, so timing does not say which one was
+		// close, as with the admin endpoint's.
+		for i := range tokens {
+			if subtle.ConstantTimeCompare(sum[:], tokens[i].sum[:]) == 1 && found == nil {
+				found = &tokens[i].client
+			}
+		}
+		if found == nil {
+			return proxy.Login{}, proxy.ErrLoginRefused
+		}
+		return proxy.Login{
+			Identity: session.Identity{Subject: found.Name, Groups: slices.Clone(found.Groups)},
+			User:     b.User,
+			Password: pw.Bytes(),
+		}, nil
+	}, nil
+}
@@ -0,0 +1,106 @@
+package sidecar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoopinspect/proxy"
+)
+
+func TestBrokerValidation(t *testing.T) {
+	pw := tokenFile(t, "db-password", 0o600)
+	alice := tokenFile(t, "alice-token", 0o600)
+	lane := func(l ListenerConfig) *Config {
+		if l.Protocol == "" {
+			l.Protocol = "postgres"
+		}
+		if l.Listen == "" {
+			l.Listen = "127.0.0.1:5432"
+		}
+		l.Upstream = "db:5432"
+		return &Config{Listeners: []ListenerConfig{l}}
+	}
+	good := func() *BrokerConfig {
+		return &BrokerConfig{User: "app", PasswordFile: pw, Clients: []BrokerClient{{Name: "alice", TokenFile: alice}}}
+	}
+
+	for name, c := range map[string]struct {
+		l    ListenerConfig
+		want string
+	}{
+		"mysql lane": {
+			ListenerConfig{Protocol: "mysql", Broker: good()},
+			"only supported on postgres",
+		},
+		"token in the clear off loopback": {
+			ListenerConfig{Listen: ":5432", Broker: good()},
+			"needs downstream_tls",
+		},
+		"no password": {
+			ListenerConfig{Broker: &BrokerConfig{User: "app", Clients: good().Clients}},
+			"no password_file or password_env",
+		},
+		"unset env": {
+			ListenerConfig{Broker: &BrokerConfig{User: "app", PasswordEnv: "HOOP_TEST_UNSET_PASSWORD", Clients: good().Clients}},
+			"is unset or empty",
+		},
+		"world-readable token": {
+			ListenerConfig{Broker: &BrokerConfig{User: "app", PasswordFile: pw,
+				Clients: []BrokerClient{{Name: "bob", TokenFile: tokenFile(t, "bob", 0o644)}}}},
+			"want 0600",
+		},
+		"one token, two names": {
+			ListenerConfig{Broker: &BrokerConfig{User: "app", PasswordFile: pw,
+				Clients: []BrokerClient{{Name: "alice", TokenFile: alice}, {Name: "bob", TokenFile: alice}}}},
+			"same token as alice",
+		},
+		"two subject sources": {
+			ListenerConfig{Broker: good(), ProxyProtocol: &ProxyProtocolConfig{SubjectTLV: 0xE0}},
+			"not both",
+		},
+	} {
+		err := lane(c.l).Validate()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: err = %v, want %q", name, err, c.want)
+		}
+	}
+
+	t.Setenv("HOOP_TEST_DB_PASSWORD", "db-password")
+	env := good()
+	env.PasswordFile, env.PasswordEnv = "", "HOOP_TEST_DB_PASSWORD"
+	if err := lane(ListenerConfig{Network: "unix", Listen: "/run/pg.sock", Broker: env}).Validate(); err != nil {
+		t.Errorf("a unix-socket broker with its password in the environment was refused: %v", err)
+	}
+}
+
+func TestBuildBrokerResolvesTokensToHolders(t *testing.T) {
+	t.Setenv("HOOP_TEST_DB_PASSWORD", "db-password")
+	check, err := buildBroker(BrokerConfig{
+		User:        "app",
+		PasswordEnv: "HOOP_TEST_DB_PASSWORD",
+		Clients: []BrokerClient{
+			{Name: "alice", TokenFile: tokenFile(t, "alice-token", 0o600), Groups: []string{"analysts"}},
+			{Name: "ci", TokenFile: tokenFile(t, "shared-ci-secret", 0o600)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The user the client names is not consulted: the token decides.
+	login, err := check("postgres", []byte("alice-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.Identity.Subject != "alice" || login.Identity.Groups[0] != "analysts" ||
+		login.User != "app" || string(login.Password) != "db-password" {
+		t.Errorf("login = %+v", login)
+	}
+	if login, _ := check("ci", []byte("shared-ci-secret")); login.Identity.Subject != "ci" {
+		t.Errorf("shared secret logged in as %q, want ci", login.Identity.Subject)
+	}
+	if _, err := check("alice", []byte("alice-token\n")); !errors.Is(err, proxy.ErrLoginRefused) {
+		t.Errorf("a near-miss token: err = %v, want ErrLoginRefused", err)
+	}
+}
@@ -71,7 +71,8 @@ type ListenerConfig struct {
 	// Name identifies the listener in logs. Defaults to Connection.
 	Name string `json:"name"`
 
-	// Protocol selects the codec: postgres, mssql or http.
+	// Protocol selects the codec: postgres, mssql, mysql, mongodb, http or
+	// grpc.
 	Protocol string `json:"protocol"`
 
 	// Listen is the bind address, or a filesystem path when Network is
@@ -91,7 +92,8 @@ type ListenerConfig struct {
 	// Upstream may change under it.
 	Connection string `json:"connection"`
 
-	// UpstreamTLS enables TLS to the backend.
+	// UpstreamTLS enables TLS to the backend. Not supported on `mysql`,
+	// which negotiates TLS inside its login exchange rather than on connect.
 	UpstreamTLS *TLSConfig `json:"upstream_tls"`
 
 	// DownstreamTLS lets the relay terminate the CLIENT's TLS on this lane.
@@ -118,6 +120,21 @@ type ListenerConfig struct {
 	// anywhere else and a caller can assert any identity.
 	IdentityHeader string `json:"identity_header"`
 
+	// ProxyProtocol makes the listener require a PROXY protocol v2 header
+	// on every connection and read the client's identity from its TLVs.
+	// It is how a TCP lane (postgres, mssql, mysql) behind Envoy learns who
+	// is connecting, which IdentityHeader cannot tell it.
+	//
+	// The same caveat as IdentityHeader, harder: the header is believed, so
+	// nothing but the proxy that writes it may reach this listener.
+	ProxyProtocol *ProxyProtocolConfig `json:"proxy_protocol,omitempty"`
+
+	// Broker makes the relay answer a postgres client's login itself and
+	// log in to the upstream with its own credential, so the database
+	// password stays here and the session is whoever's token the client
+	// presented. Only `postgres`. See BrokerConfig.
+	Broker *BrokerConfig `json:"broker,omitempty"`
+
 	// IdleTimeoutSec closes a connection with no traffic. Zero disables it.
 	// Interactive sessions idle between keystrokes, so a short value breaks
 	// psql; leaving it unset is the safe default.
@@ -134,7 +151,9 @@ type ListenerConfig struct {
 	// reported. Listener-first lets a lane's specific message beat a generic
 	// default for the same statement.
 	//
-	// OPA and Enforce REPLACE when set. Two decision endpoints cannot merge
+	// Limits concatenate the same way and for the same reason.
+	//
+	// OPA, Approval and Enforce REPLACE when set. Two decision endpoints cannot merge
 	// into one, and a lane that says enforce:false means it.
 	Policy *PolicyConfig `json:"policy,omitempty"`
 
@@ -148,6 +167,14 @@ type ListenerConfig struct {
 	// HTTP configures what this lane's HTTP codec captures. Only valid on
 	// an http lane.
 	HTTP *HTTPCodecConfig `json:"http,omitempty"`
+
+	// GRPC configures what this lane's gRPC codec decodes and captures.
+	// Only valid on a grpc lane.
+	GRPC *GRPCCodecConfig `json:"grpc,omitempty"`
+
+	// Tests are policy regression cases for this listener, run by -test
+	// against the resolved lane and ignored by a normal start. See TestCase.
+	Tests []TestCase `json:"tests,omitempty"`
 }
 
 // TLSConfig configures an upstream TLS connection.
@@ -168,6 +195,73 @@ type TLSConfig struct {
 	// purpose and startup logs a warning when it is on: a proxy built to
 	// inspect sensitive traffic should not silently accept any certificate.
 	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+
+	// ClientCAFile and ClientSubject are downstream_tls only, and refused on
+	// upstream_tls. ClientCAFile makes the lane require a client
+	// certificate that verifies against it. ClientSubject names the
+	// certificate field the session's subject comes from: cn, or the first
+	// uri, email or dns SAN. An email SAN also fills the identity's email.
+	ClientCAFile  string `json:"client_ca_file,omitempty"`
+	ClientSubject string `json:"client_subject,omitempty"`
+}
+
+// ProxyProtocolConfig names the PROXY v2 TLVs a listener reads identity from.
+//
+// TLV types are numbers because the sender chooses them: Envoy's
+// proxy_protocol transport socket adds custom TLVs by type, and 0xE0 to 0xEF
+// is the range the specification reserves for that. Leave a field zero to
+// read nothing from it.
+type ProxyProtocolConfig struct {
+	SubjectTLV int `json:"subject_tlv,omitempty"`
+	EmailTLV   int `json:"email_tlv,omitempty"`
+
+	// GroupsTLV carries the groups comma-separated.
+	GroupsTLV int `json:"groups_tlv,omitempty"`
+
+	// SubjectFromSSLCN takes the subject from the client certificate CN
+	// the sender reports in its standard SSL TLV, when it also reports the
+	// certificate verified. For a sender that terminated mTLS itself. A
+	// subject_tlv, when both are present, wins.
+	SubjectFromSSLCN bool `json:"subject_from_ssl_cn,omitempty"`
+}
+
+// namesSubject reports whether the config reads a subject at all.
+func (p *ProxyProtocolConfig) namesSubject() bool {
+	return p != nil && (p.SubjectTLV != 0 || p.SubjectFromSSLCN)
+}
+
+// BrokerConfig is a postgres lane's credential broker: the tokens clients
+// log in with, and the one upstream role the relay logs in as for all of
+// them.
+//
+// A client sends its token where it would send a password. The relay checks
+// it, records the token's name as the session's subject, and answers the
+// upstream's SCRAM-SHA-256 challenge with the password named here. A static
+// shared secret is one client entry; per-user tokens are one each.
+type BrokerConfig struct {
+	// User is the upstream role.
+	User string `json:"user"`
+
+	// PasswordFile or PasswordEnv holds the role's password; set exactly
+	// one. The file must not be readable by group or other.
+	PasswordFile string `json:"password_file,omitempty"`
+	PasswordEnv  string `json:"password_env,omitempty"`
+
+	// Clients are the credentials the relay accepts.
+	Clients []BrokerClient `json:"clients"`
+}
+
+// BrokerClient is one credential a client may log in with.
+type BrokerClient struct {
+	// Name is the session's subject when this token logs in.
+	Name string `json:"name"`
+
+	// TokenFile holds the token, under the same permission rule as a
+	// password_file.
+	TokenFile string `json:"token_file"`
+
+	// Groups are the session's groups, for policy.
+	Groups []string `json:"groups,omitempty"`
 }
 
 // PolicyConfig configures enforcement.
@@ -179,6 +273,12 @@ type PolicyConfig struct {
 	// OPA, when URL is set, is consulted after the local rules pass.
 	OPA *OPAConfig `json:"opa"`
 
+	// Limits caps how much of one result set a session may read; see
+	// policy.ResultLimit. They concatenate like Rules, this listener's
+	// first, and the first limit a row crosses decides. In observe-only
+	// mode a crossing is recorded and nothing is cut.
+	Limits []policy.ResultLimit `json:"limits,omitempty"`
+
 	// Enforce false runs in observe-only mode: the gate inspects and audits
 	// everything and denies nothing. Teams run this way for a week before
 	// turning enforcement on, and it is the default so a misconfigured rule
@@ -188,6 +288,49 @@ type PolicyConfig struct {
 	// false: a lane rolling out behind an enforcing default needs to say
 	// observe-only, and a zero bool cannot express that.
 	Enforce *bool `json:"enforce,omitempty"`
+
+	// Approval is the service a rule with action "approve" asks; see
+	// policy.HTTPApprover. It replaces like OPA: one lane, one place to ask.
+	Approval *ApprovalConfig `json:"approval,omitempty"`
+}
+
+// ApprovalConfig configures the approval service a held statement waits on.
+type ApprovalConfig struct {
+	// URL receives a POST per held statement and answers once someone has
+	// decided. https, or http to loopback: the request carries the
+	// statement and who ran it.
+	URL string `json:"url"`
+
+	// TimeoutSec is how long a statement waits before it is refused.
+	// Default 120.
+	TimeoutSec int `json:"timeout_sec,omitempty"`
+
+	// HeaderFiles are sent on every request, each value read from a file
+	// of mode 0600, as on the remote audit sinks.
+	HeaderFiles map[string]string `json:"header_files,omitempty"`
+
+	// TLS verifies the service.
+	TLS *TLSConfig `json:"tls,omitempty"`
+}
+
+// approver builds the lane's Approver, reading every header file.
+func (a *ApprovalConfig) approver() (*policy.HTTPApprover, error) {
+	if err := collectorURL(a.URL); err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	if a.TimeoutSec < 0 {
+		return nil, fmt.Errorf("timeout_sec is negative")
+	}
+	client, header, err := RemoteSinkConfig{HeaderFiles: a.HeaderFiles, TLS: a.TLS}.client()
+	if err != nil {
+		return nil, err
+	}
+	return &policy.HTTPApprover{
+		URL:        a.URL,
+		HTTPClient: client,
+		Header:     header,
+		Timeout:    time.Duration(a.TimeoutSec) * time.Second,
+	}, nil
 }
 
 // OPAConfig configures the OPA client.
@@ -233,6 +376,7 @@ func (o *OPAConfig) client(phase policy.Phase) *policy.OPAClient {
 		Timeout:  timeout,
 		FailOpen: o.FailOpen,
 		Phase:    phase,
+		Observe:  metrics.observeOPA(string(phase)),
 	}
 }
 
@@ -303,11 +447,180 @@ type AuditConfig struct {
 	// convenience: a sink outage then stops traffic, which is correct for a
 	// system that exists to prove who did what.
 	FailClosed bool `json:"fail_closed"`
+
+	// HashChain makes the audit file tamper-evident: every record carries a
+	// sequence number and a hash linking it to the one before, and with a
+	// signing key, every Nth record is signed. `hoop-inspect audit verify`
+	// checks a file.
+	HashChain *HashChainConfig `json:"hash_chain,omitempty"`
+
+	// Syslog, OTLP and HTTP ship every event to a remote collector as well
+	// as to the file. Each is one more sink beside it, so each honors
+	// redact_statements and max_statement_bytes, sits behind
+	// async_queue_size with the rest, and under fail_closed denies the
+	// statement whose record it could not take.
+	Syslog *SyslogSinkConfig `json:"syslog,omitempty"`
+	OTLP   *OTLPSinkConfig   `json:"otlp,omitempty"`
+	HTTP   *HTTPSinkConfig   `json:"http,omitempty"`
+}
+
+// HashChainConfig configures the audit file's hash chain.
+type HashChainConfig struct {
+	// CheckpointEvery signs every Nth record with SigningKeyFile.
+	CheckpointEvery int `json:"checkpoint_every"`
+
+	// SigningKeyFile is an Ed25519 private key, PEM PKCS #8, mode 0600.
+	// Without checkpoints the chain shows that the file was edited only to
+	// someone who did not also re-chain it; a signature is what an editor
+	// cannot recompute.
+	SigningKeyFile string `json:"signing_key_file"`
+}
+
+// SyslogSinkConfig sends events to a syslog collector as RFC 5424 messages.
+type SyslogSinkConfig struct {
+	// Network is "udp", "tcp" or "tls". UDP reports nothing about delivery,
+	// so fail_closed cannot act on a collector it cannot reach.
+	Network string `json:"network"`
+
+	// Addr is the collector's host:port.
+	Addr string `json:"addr"`
+
+	// TLS verifies the collector on the tls network, and is refused on the
+	// others.
+	TLS *TLSConfig `json:"tls,omitempty"`
+
+	// Facility is a facility name: user, auth, authpriv, daemon, or local0
+	// through local7. Default local0.
+	Facility string `json:"facility,omitempty"`
+
+	// AppName is the APP-NAME header field. Default hoop-inspect.
+	AppName string `json:"app_name,omitempty"`
+}
+
+// HTTPSinkConfig posts batches of events, as JSON lines, to a collector.
+type HTTPSinkConfig struct {
+	// URL receives the batches. https, unless the host is loopback.
+	URL string `json:"url"`
+
+	// HMACKeyFile signs every request body with the key it holds, mode
+	// 0600; the receiver checks X-Hoop-Signature against the same key.
+	HMACKeyFile string `json:"hmac_key_file,omitempty"`
+
+	RemoteSinkConfig
+}
+
+// OTLPSinkConfig exports events as OpenTelemetry log records over OTLP/HTTP.
+type OTLPSinkConfig struct {
+	// Endpoint is the collector's logs URL, /v1/logs included. https,
+	// unless the host is loopback, where a collector agent usually runs.
+	Endpoint string `json:"endpoint"`
+
+	// ServiceName is the resource's service.name. Default hoop-inspect.
+	ServiceName string `json:"service_name,omitempty"`
+
+	RemoteSinkConfig
+}
+
+// RemoteSinkConfig is what the HTTP and OTLP sinks share. Embedded, so its
+// fields sit beside url and endpoint in the file.
+type RemoteSinkConfig struct {
+	// Headers are sent on every request. HeaderFiles are too, each value
+	// read from a file of mode 0600, for the header that carries an API
+	// key: a credential in the config file is one every reader of the
+	// config has.
+	Headers     map[string]string `json:"headers,omitempty"`
+	HeaderFiles map[string]string `json:"header_files,omitempty"`
+
+	// TLS verifies the collector.
+	TLS *TLSConfig `json:"tls,omitempty"`
+
+	// MaxBatch is the most events a request carries. Default 500.
+	MaxBatch int `json:"max_batch,omitempty"`
+
+	// FlushIntervalMS is how long an event waits for a batch to fill.
+	// Default 1000.
+	FlushIntervalMS int `json:"flush_interval_ms,omitempty"`
+
+	// MaxPending bounds the events held for the collector; past it, and
+	// while a batch has failed through its retries, the sink refuses
+	// events. Default ten batches.
+	MaxPending int `json:"max_pending,omitempty"`
+
+	// Retries is how many times a failed batch is retried, with backoff,
+	// before the sink refuses events. Default 3; -1 for none.
+	Retries int `json:"retries,omitempty"`
+
+	// TimeoutSec bounds one request. Default 10.
+	TimeoutSec int `json:"timeout_sec,omitempty"`
 }
 
 // AdminConfig configures the health/stats endpoint.
 type AdminConfig struct {
 	Listen string `json:"listen"`
+
+	// TLS serves the endpoint over HTTPS, and with client_ca_file set,
+	// verifies the client certificates Clients authenticate by.
+	TLS *AdminTLSConfig `json:"tls,omitempty"`
+
+	// Tokens and Clients turn authentication on. With neither set every
+	// endpoint is open, which is what every config written before they
+	// existed meant, and which is only safe on loopback.
+	//
+	// Each credential carries scopes rather than a single "admin" bit,
+	// because the callers are not alike: a kubelet probe needs /healthz, a
+	// scraper /stats, a deploy script /config/reload, and only an auditor
+	// should read statement text through /events and /api.
+	Tokens  []AdminToken  `json:"tokens,omitempty"`
+	Clients []AdminClient `json:"clients,omitempty"`
+
+	// Anonymous is what a request with no credential may reach once
+	// authentication is on. Unset leaves /healthz open (["health"]), so a
+	// probe that cannot carry a token keeps working; `[]` closes it too.
+	Anonymous []string `json:"anonymous,omitempty"`
+}
+
+// AdminTLSConfig is the admin endpoint's server side of TLS.
+//
+// Its own type rather than a TLSConfig, for the reason BuildDownstreamTLS
+// gives: a CA here verifies CLIENTS, and a field named ca_file that meant
+// "trust the upstream" everywhere else would be read wrong.
+type AdminTLSConfig struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+
+	// ClientCAFile verifies client certificates. A client presenting none
+	// is still served, and authenticates by token or reaches only the
+	// anonymous scopes; a client presenting one that does not verify fails
+	// the handshake.
+	ClientCAFile string `json:"client_ca_file,omitempty"`
+}
+
+// AdminToken is a bearer credential for the admin endpoint.
+type AdminToken struct {
+	// Name identifies the credential in the log, never the token itself.
+	Name string `json:"name"`
+
+	// File holds the token, and is refused when group or other can read
+	// it. A path rather than the value, so the config can live in a
+	// ConfigMap and the token in a Secret.
+	File string `json:"file"`
+
+	Scopes []string `json:"scopes"`
+}
+
+// AdminClient grants scopes to a client certificate verified against
+// tls.client_ca_file. Set CommonName, URI or both; a certificate must match
+// every field set.
+type AdminClient struct {
+	Name string `json:"name"`
+
+	// CommonName matches the certificate subject's CN.
+	CommonName string `json:"common_name,omitempty"`
+
+	// URI matches a URI SAN exactly, which is where a SPIFFE ID lives.
+	URI string `json:"uri,omitempty"`
+
+	Scopes []string `json:"scopes"`
 }
 
 // LoadConfig reads and validates a config file.
@@ -343,8 +656,8 @@ func LoadConfigBytes(data []byte) (*Config, error) {
 
 // resolve merges a listener's overrides onto the top-level defaults.
 //
-// Policy rules concatenate with the listener's first; OPA and Enforce replace
-// when the listener sets them. Mask replaces wholesale. See the field
+// Policy rules concatenate with the listener's first; OPA, Approval and
+// Enforce replace when the listener sets them. Mask replaces wholesale. See the field
 // documentation on ListenerConfig for why each field merges the way it does.
 //
 // Pure: it reads config and returns config, so a test can assert the merge
@@ -352,9 +665,11 @@ func LoadConfigBytes(data []byte) (*Config, error) {
 // effects.
 func (c *Config) resolve(lc ListenerConfig) (PolicyConfig, MaskConfig) {
 	pc := PolicyConfig{
-		Rules:   c.Policy.Rules,
-		OPA:     c.Policy.OPA,
-		Enforce: c.Policy.Enforce,
+		Rules:    c.Policy.Rules,
+		OPA:      c.Policy.OPA,
+		Limits:   c.Policy.Limits,
+		Enforce:  c.Policy.Enforce,
+		Approval: c.Policy.Approval,
 	}
 	if o := lc.Policy; o != nil {
 		if len(o.Rules) > 0 {
@@ -366,12 +681,21 @@ func (c *Config) resolve(lc ListenerConfig) (PolicyConfig, MaskConfig) {
 			merged = append(merged, c.Policy.Rules...)
 			pc.Rules = merged
 		}
+		if len(o.Limits) > 0 {
+			merged := make([]policy.ResultLimit, 0, len(o.Limits)+len(c.Policy.Limits))
+			merged = append(merged, o.Limits...)
+			merged = append(merged, c.Policy.Limits...)
+			pc.Limits = merged
+		}
 		if o.OPA != nil {
 			pc.OPA = o.OPA
 		}
 		if o.Enforce != nil {
 			pc.Enforce = o.Enforce
 		}
+		if o.Approval != nil {
+			pc.Approval = o.Approval
+		}
 	}
 
 	mc := c.Mask
@@ -445,8 +769,25 @@ func (c *Config) Validate() error {
 			}
 		}
 
+		problems = append(problems, validateIdentitySources(l, name)...)
+		problems = append(problems, validateBroker(l, name)...)
+
+		// upstream_tls on mysql would send a ClientHello where the server
+		// expects to speak first with its greeting. The server drops the
+		// connection, and every login on the lane fails with a handshake
+		// error that names neither the relay nor this setting.
+		if l.UpstreamTLS != nil && l.Protocol == string(hoopinspect.MySQL) {
+			problems = append(problems, fmt.Sprintf(
+				"%s: upstream_tls is not supported on mysql (the server speaks "+
+					"first and negotiates TLS inside its login exchange)", name))
+		}
+
 		problems = append(problems, c.validateLane(l, name)...)
+		problems = append(problems, validateTests(l, name)...)
 	}
+	problems = append(problems, c.Admin.validate()...)
+	problems = append(problems, c.Audit.validateHashChain()...)
+	problems = append(problems, c.Audit.validateRemoteSinks()...)
 
 	if len(problems) > 0 {
 		return fmt.Errorf("invalid config:\n  - %s", strings.Join(problems, "\n  - "))
@@ -488,6 +829,35 @@ func (c *Config) validateLane(lc ListenerConfig, name string) []string {
 		problems = append(problems, name+": policy.opa set but url is empty")
 	}
 
+	// A holding rule with nobody to ask refuses everything it matches,
+	// which is a deny rule that says it is something else.
+	for _, r := range localRules {
+		if r.Action == policy.ActionApprove && pc.Approval == nil {
+			problems = append(problems, fmt.Sprintf(
+				"%s: rule %q holds statements for approval, and the lane has no "+
+					"policy.approval.url to ask; set one or drop the action",
+				name, r.Name))
+		}
+	}
+	if pc.Approval != nil {
+		if _, err := pc.Approval.approver(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: policy.approval: %v", name, err))
+		}
+	}
+
+	// A limit on a protocol whose codec cannot drop rows would load and cut
+	// nothing, leaving the table it was written to protect readable in full.
+	if len(pc.Limits) > 0 {
+		if err := policy.ValidateLimits(pc.Limits); err != nil {
+			problems = append(problems, name+": "+err.Error())
+		}
+		if p := hoopinspect.Protocol(lc.Protocol); p != "" && !gate.LimitSupported(p) {
+			problems = append(problems, fmt.Sprintf(
+				"%s: policy.limits are not supported on %s (its codec cannot drop "+
+					"rows from a response without corrupting it)", name, lc.Protocol))
+		}
+	}
+
 	// An http block on a lane with no HTTP codec would load and do nothing,
 	// which is the failure this package refuses everywhere else.
 	if lc.HTTP != nil {
@@ -498,6 +868,14 @@ func (c *Config) validateLane(lc ListenerConfig, name string) []string {
 		}
 		problems = append(problems, lc.HTTP.validate(name)...)
 	}
+	if lc.GRPC != nil {
+		if hoopinspect.Protocol(lc.Protocol) != hoopinspect.GRPC {
+			problems = append(problems, fmt.Sprintf(
+				"%s: a \"grpc\" block is only valid on a grpc listener, not %s",
+				name, lc.Protocol))
+		}
+		problems = append(problems, lc.GRPC.validate(name)...)
+	}
 
 	// An ai_analysis rule on an HTTP lane with no body capture classifies
 	// nothing: HTTPBuilder.Build returns ok=false on an empty body, and the
@@ -545,6 +923,15 @@ func (c *Config) validateLane(lc ListenerConfig, name string) []string {
 					"desynchronizes the client). Set mask.enabled false on this listener.",
 				name, lc.Protocol))
 		}
+		// A gRPC response is read against the schema of the method that
+		// produced it. Without one the codec reads no message, and a mask
+		// rule would load and never fire.
+		if hoopinspect.Protocol(lc.Protocol) == hoopinspect.GRPC &&
+			(lc.GRPC == nil || lc.GRPC.DescriptorSet == "") {
+			problems = append(problems, fmt.Sprintf(
+				"%s: mask.enabled is true but the grpc listener has no grpc.descriptor_set, "+
+					"so no response message can be read to mask", name))
+		}
 	}
 	return problems
 }
@@ -737,6 +1124,11 @@ func (t *TLSConfig) BuildTLS() (*tls.Config, error) {
 // so those fields are meaningless and a keypair is mandatory rather than
 // optional. Sharing one builder would silently accept a lane configured with
 // only a ca_file and then fail every handshake at runtime.
+//
+// With ClientCAFile set, a client certificate is REQUIRED, not merely
+// verified when offered: a lane that takes its subject from the certificate
+// and let a client without one through would record that session as
+// anonymous, or as whatever user the startup packet claimed.
 func (t *TLSConfig) BuildDownstreamTLS() (*tls.Config, error) {
 	if t == nil {
 		return nil, nil
@@ -748,8 +1140,21 @@ func (t *TLSConfig) BuildDownstreamTLS() (*tls.Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("downstream_tls keypair: %w", err)
 	}
-	return &tls.Config{
+	out := &tls.Config{
 		Certificates: []tls.Certificate{cert},
 		MinVersion:   tls.VersionTLS12,
-	}, nil
+	}
+	if t.ClientCAFile != "" {
+		pem, err := os.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client_ca_file %q contains no usable certificate", t.ClientCAFile)
+		}
+		out.ClientCAs = pool
+		out.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return out, nil
 }
@@ -108,6 +108,19 @@ func TestValidationRejectsDuplicateListenAddress(t *testing.T) {
 	}
 }
 
+// MySQL's server speaks first and upgrades inside the login exchange, so a
+// TLS-on-connect dial can only ever fail. Refused at startup rather than on
+// every login.
+func TestUpstreamTLSRefusedOnMySQL(t *testing.T) {
+	cfg := &Config{Listeners: []ListenerConfig{
+		{Protocol: "mysql", Listen: ":3306", Upstream: "h:3306", UpstreamTLS: &TLSConfig{}},
+	}}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "upstream_tls is not supported on mysql") {
+		t.Errorf("upstream_tls on mysql accepted: %v", err)
+	}
+}
+
 func TestValidationRejectsBadNetwork(t *testing.T) {
 	cfg := &Config{Listeners: []ListenerConfig{
 		{Protocol: "postgres", Listen: ":1", Upstream: "h:1", Network: "udp"},
@@ -129,6 +142,20 @@ func TestBadPolicyRuleFailsAtLoad(t *testing.T) {
 	}
 }
 
+// The same holds for an expression: a misspelled field is a type error with
+// a position, not a rule that never fires.
+func TestBadExpressionFailsAtLoad(t *testing.T) {
+	p := writeConfig(t, `{
+      "listeners": [{"protocol":"postgres","listen":":1","upstream":"h:1"}],
+      "policy": {"rules":[{"name":"r","type":"expression","expression":"identity.group == \"dba\""}]}
+    }`)
+
+	_, err := LoadConfig(p)
+	if err == nil || !strings.Contains(err.Error(), `1:10: no field "group"`) {
+		t.Fatalf("LoadConfig = %v, want the expression's type error", err)
+	}
+}
+
 // Mask rule SHAPES are no longer validated here, since only the plugin knows
 // which entity names it detects. This package must still catch masking
 // switched on with nothing to do, which would look enabled in the config and
@@ -162,6 +189,32 @@ func TestMaskWithoutPluginIsRefused(t *testing.T) {
 	}
 }
 
+// A grpc block that cannot do what it asks for is a load error: a mask
+// with no schema to read responses against would load and never fire.
+func TestGRPCLaneValidation(t *testing.T) {
+	for name, tc := range map[string]struct {
+		proto string
+		grpc  *GRPCCodecConfig
+		mask  bool
+		want  string
+	}{
+		"grpc block on http":  {"http", &GRPCCodecConfig{}, false, "only valid on a grpc listener"},
+		"capture without set": {"grpc", &GRPCCodecConfig{CaptureMessages: true}, false, "needs grpc.descriptor_set"},
+		"mask without set":    {"grpc", nil, true, "no grpc.descriptor_set"},
+		"missing set":         {"grpc", &GRPCCodecConfig{DescriptorSet: filepath.Join(t.TempDir(), "api.pb")}, false, "grpc.descriptor_set"},
+	} {
+		cfg := &Config{
+			Listeners: []ListenerConfig{{Name: "api", Protocol: tc.proto, Listen: ":1", Upstream: "h:1", GRPC: tc.grpc}},
+		}
+		if tc.mask {
+			cfg.Mask = MaskConfig{Enabled: ptr(true), Rules: []byte(`[{"name":"r","entity":"US_SSN","strategy":"redact"}]`)}
+		}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", name, err, tc.want)
+		}
+	}
+}
+
 // Observe-only is the default so a misconfigured rule cannot take production
 // down on first deploy.
 func TestEnforceDefaultsOff(t *testing.T) {
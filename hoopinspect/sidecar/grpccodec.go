@@ -0,0 +1,102 @@
+package sidecar
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hoophq/hoopinspect"
+	codecgrpc "github.com/hoophq/hoopinspect/codec/grpc"
+)
+
+// GRPCCodecConfig controls what a lane's gRPC codec decodes and exposes to
+// policy.
+//
+// Without a descriptor set a gRPC lane still reads every call's method, so
+// http_resource and operation rules work; the set is what lets column rules
+// and masks reach the fields inside messages. As with HTTPCodecConfig, the
+// defaults expose nothing.
+type GRPCCodecConfig struct {
+	// DescriptorSet is the path to a serialized FileDescriptorSet for the
+	// services behind this lane, as written by
+	// `protoc --include_imports --descriptor_set_out=api.pb` or
+	// `buf build -o api.pb`.
+	DescriptorSet string `json:"descriptor_set,omitempty"`
+
+	// CaptureMessages renders each decoded request message as JSON into
+	// the statement. Requires DescriptorSet.
+	CaptureMessages bool `json:"capture_messages"`
+
+	// MaxMessageBytes bounds a request message the codec reads; one larger
+	// is refused as unreadable. Zero uses the codec default, 4 MiB.
+	MaxMessageBytes int `json:"max_message_bytes,omitempty"`
+
+	// Headers names the request metadata to expose, matched
+	// case-insensitively. The names forbiddenHeaders lists are refused here
+	// as on an http lane.
+	Headers []string `json:"headers,omitempty"`
+}
+
+func (g *GRPCCodecConfig) validate(lane string) []string {
+	if g == nil {
+		return nil
+	}
+	var problems []string
+	for _, name := range g.Headers {
+		lower := strings.ToLower(strings.TrimSpace(name))
+		for _, bad := range forbiddenHeaders {
+			if lower == bad {
+				problems = append(problems, fmt.Sprintf(
+					"listener %q: header %q may not be exposed to policy", lane, name))
+			}
+		}
+	}
+	if g.MaxMessageBytes < 0 {
+		problems = append(problems, fmt.Sprintf("listener %q: grpc.max_message_bytes is negative", lane))
+	}
+	// Capture with nothing to decode against would load and capture
+	// nothing.
+	if g.CaptureMessages && g.DescriptorSet == "" {
+		problems = append(problems, fmt.Sprintf(
+			"listener %q: grpc.capture_messages needs grpc.descriptor_set to decode messages with", lane))
+	}
+	if g.DescriptorSet != "" {
+		if _, err := loadDescriptorSet(g.DescriptorSet); err != nil {
+			problems = append(problems, fmt.Sprintf("listener %q: grpc.descriptor_set: %v", lane, err))
+		}
+	}
+	return problems
+}
+
+func loadDescriptorSet(path string) (*codecgrpc.Descriptors, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return codecgrpc.ParseDescriptorSet(data)
+}
+
+// grpcCodecFactory builds a codec factory for a gRPC lane, loading its
+// descriptor set once for every connection to share. It is the one place
+// the sidecar names codec/grpc, for the reason newHTTPCodec is the one place
+// it names codec/http.
+//
+// Returns nil when the lane wants the registry default.
+func grpcCodecFactory(proto hoopinspect.Protocol, g *GRPCCodecConfig) (func() hoopinspect.Codec, error) {
+	if g == nil || proto != hoopinspect.GRPC {
+		return nil, nil
+	}
+	opts := codecgrpc.Options{
+		CaptureMessages: g.CaptureMessages,
+		MaxMessageBytes: g.MaxMessageBytes,
+		Headers:         g.Headers,
+	}
+	if g.DescriptorSet != "" {
+		d, err := loadDescriptorSet(g.DescriptorSet)
+		if err != nil {
+			return nil, fmt.Errorf("grpc.descriptor_set: %w", err)
+		}
+		opts.Descriptors = d
+	}
+	return func() hoopinspect.Codec { return codecgrpc.New(opts) }, nil
+}
@@ -1,6 +1,9 @@
 package sidecar
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/hoophq/hoopinspect"
 	codechttp "github.com/hoophq/hoopinspect/codec/http"
 )
@@ -20,6 +23,28 @@ func newHTTPCodec(cfg HTTPCodecConfig) func() hoopinspect.Codec {
 		CaptureBody:  cfg.CaptureBody,
 		MaxBodyBytes: cfg.MaxBodyBytes,
 		Headers:      cfg.Headers,
+
+		PersistedQueries: cfg.GraphQLPersistedQueries,
 	}
 	return func() hoopinspect.Codec { return codechttp.New(opts) }
 }
+
+// validateGraphQLDocuments checks each persisted query parses. A document
+// that does not would fail closed on every request naming its hash, which is
+// a config error better reported at load than as a stream of denials.
+func validateGraphQLDocuments(lane string, docs map[string]string) []string {
+	hashes := make([]string, 0, len(docs))
+	for h := range docs {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+
+	var problems []string
+	for _, h := range hashes {
+		if err := codechttp.ValidateGraphQL(docs[h]); err != nil {
+			problems = append(problems, fmt.Sprintf(
+				"listener %q: http.graphql_persisted_queries[%q]: %v", lane, h, err))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,147 @@
+package sidecar
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/hoophq/hoopinspect/proxy"
+	"github.com/hoophq/hoopinspect/session"
+)
+
+// The certificate fields client_subject may name.
+var clientSubjects = []string{"cn", "uri", "email", "dns"}
+
+// validateIdentitySources checks a listener's proxy_protocol block and the
+// identity half of its downstream_tls.
+func validateIdentitySources(l ListenerConfig, name string) []string {
+	var problems []string
+	if pp := l.ProxyProtocol; pp != nil {
+		tlvs := map[string]int{"subject_tlv": pp.SubjectTLV, "email_tlv": pp.EmailTLV, "groups_tlv": pp.GroupsTLV}
+		seen := map[int]string{}
+		for _, field := range []string{"subject_tlv", "email_tlv", "groups_tlv"} {
+			t := tlvs[field]
+			switch {
+			case t == 0:
+				continue
+			case t < 0 || t > 0xFF:
+				problems = append(problems, fmt.Sprintf("%s: proxy_protocol.%s %d is not a TLV type (1 to 255)", name, field, t))
+			case t == int(proxy.PP2TypeSSL):
+				// The SSL TLV is a structure, not a string; reading it as
+				// a subject would record binary flags as a user name.
+				problems = append(problems, fmt.Sprintf(
+					"%s: proxy_protocol.%s 0x20 is the SSL TLV; use subject_from_ssl_cn for its CN", name, field))
+			}
+			if other, dup := seen[t]; dup {
+				problems = append(problems, fmt.Sprintf("%s: proxy_protocol.%s and %s name the same TLV", name, other, field))
+			}
+			seen[t] = field
+		}
+		if pp.SubjectTLV == 0 && pp.EmailTLV == 0 && pp.GroupsTLV == 0 && !pp.SubjectFromSSLCN {
+			// Accepting the header for the client address alone is
+			// legitimate, but it is rarely what someone setting this
+			// meant, and a lane that records nobody looks like one that
+			// records everybody until the audit is read.
+			problems = append(problems, name+
+				": proxy_protocol reads no identity; set subject_tlv, email_tlv, groups_tlv or subject_from_ssl_cn")
+		}
+	}
+
+	if t := l.UpstreamTLS; t != nil && (t.ClientCAFile != "" || t.ClientSubject != "") {
+		problems = append(problems, name+
+			": client_ca_file and client_subject verify the CLIENT, and belong on downstream_tls, not upstream_tls")
+	}
+	if t := l.DownstreamTLS; t != nil && t.ClientSubject != "" {
+		if !slices.Contains(clientSubjects, t.ClientSubject) {
+			problems = append(problems, fmt.Sprintf("%s: downstream_tls.client_subject %q is not one of %s",
+				name, t.ClientSubject, strings.Join(clientSubjects, ", ")))
+		}
+		if t.ClientCAFile == "" {
+			problems = append(problems, name+
+				": downstream_tls.client_subject needs client_ca_file; an unverified certificate names whoever made it")
+		}
+		// Two sources for one answer would leave precedence to read in
+		// the source. Pick the one that authenticated the client.
+		if l.ProxyProtocol.namesSubject() {
+			problems = append(problems, name+
+				": the subject comes from proxy_protocol or from downstream_tls.client_subject, not both")
+		}
+	}
+	return problems
+}
+
+// proxyIdentity reads a session's identity from the PROXY v2 header the
+// listener required. The peer address is the header's client, which
+// proxy.Server already put on the connection.
+func proxyIdentity(pp ProxyProtocolConfig) func(net.Conn) session.Identity {
+	fromHeader := proxyHeaderIdentity(pp)
+	return func(c net.Conn) session.Identity {
+		h, ok := proxy.ProxyHeaderOf(c)
+		if !ok {
+			return session.Identity{PeerAddr: c.RemoteAddr().String()}
+		}
+		id := fromHeader(h)
+		id.PeerAddr = c.RemoteAddr().String()
+		return id
+	}
+}
+
+// proxyHeaderIdentity maps a PROXY v2 header to an identity, with the
+// header's client as the peer. Replay uses it directly, having no connection
+// to ask.
+func proxyHeaderIdentity(pp ProxyProtocolConfig) func(proxy.ProxyHeader) session.Identity {
+	str := func(h proxy.ProxyHeader, t int) string {
+		if t == 0 {
+			return ""
+		}
+		v, _ := h.TLV(byte(t))
+		return strings.TrimSpace(string(v))
+	}
+	return func(h proxy.ProxyHeader) session.Identity {
+		var id session.Identity
+		if h.Source.IsValid() {
+			id.PeerAddr = h.Source.String()
+		}
+		id.Subject = str(h, pp.SubjectTLV)
+		if id.Subject == "" && pp.SubjectFromSSLCN {
+			id.Subject, _ = h.SSLClientCN()
+		}
+		id.Email = str(h, pp.EmailTLV)
+		for _, g := range strings.Split(str(h, pp.GroupsTLV), ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				id.Groups = append(id.Groups, g)
+			}
+		}
+		return id
+	}
+}
+
+// certIdentity reads a session's subject from a verified client
+// certificate.
+func certIdentity(field string) func(*x509.Certificate) session.Identity {
+	return func(cert *x509.Certificate) session.Identity {
+		var id session.Identity
+		switch field {
+		case "cn":
+			id.Subject = cert.Subject.CommonName
+		case "uri":
+			if len(cert.URIs) > 0 {
+				id.Subject = cert.URIs[0].String()
+			}
+		case "email":
+			if len(cert.EmailAddresses) > 0 {
+				id.Subject = cert.EmailAddresses[0]
+			}
+		case "dns":
+			if len(cert.DNSNames) > 0 {
+				id.Subject = cert.DNSNames[0]
+			}
+		}
+		if len(cert.EmailAddresses) > 0 {
+			id.Email = cert.EmailAddresses[0]
+		}
+		return id
+	}
+}
@@ -0,0 +1,113 @@
+package sidecar
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoopinspect/proxy"
+)
+
+func TestIdentitySourceValidation(t *testing.T) {
+	base := func(l ListenerConfig) *Config {
+		l.Protocol, l.Listen, l.Upstream = "postgres", ":5432", "db:5432"
+		return &Config{Listeners: []ListenerConfig{l}}
+	}
+	for name, c := range map[string]struct {
+		l    ListenerConfig
+		want string
+	}{
+		"SSL TLV as a string": {
+			ListenerConfig{ProxyProtocol: &ProxyProtocolConfig{SubjectTLV: 0x20}},
+			"use subject_from_ssl_cn",
+		},
+		"TLV out of range": {
+			ListenerConfig{ProxyProtocol: &ProxyProtocolConfig{GroupsTLV: 256}},
+			"is not a TLV type",
+		},
+		"one TLV twice": {
+			ListenerConfig{ProxyProtocol: &ProxyProtocolConfig{SubjectTLV: 0xE0, EmailTLV: 0xE0}},
+			"name the same TLV",
+		},
+		"header read for nothing": {
+			ListenerConfig{ProxyProtocol: &ProxyProtocolConfig{}},
+			"reads no identity",
+		},
+		"client subject without a CA": {
+			ListenerConfig{DownstreamTLS: &TLSConfig{ClientSubject: "uri"}},
+			"needs client_ca_file",
+		},
+		"unknown client subject": {
+			ListenerConfig{DownstreamTLS: &TLSConfig{ClientCAFile: "ca.pem", ClientSubject: "serial"}},
+			"is not one of cn, uri, email, dns",
+		},
+		"client CA on the upstream side": {
+			ListenerConfig{UpstreamTLS: &TLSConfig{ClientCAFile: "ca.pem"}},
+			"belong on downstream_tls",
+		},
+		"two subject sources": {
+			ListenerConfig{
+				ProxyProtocol: &ProxyProtocolConfig{SubjectFromSSLCN: true},
+				DownstreamTLS: &TLSConfig{ClientCAFile: "ca.pem", ClientSubject: "cn"},
+			},
+			"not both",
+		},
+	} {
+		err := base(c.l).Validate()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: err = %v, want %q", name, err, c.want)
+		}
+	}
+
+	// Groups from the header and the subject from the certificate is one
+	// source per field, and fine.
+	ok := base(ListenerConfig{
+		ProxyProtocol: &ProxyProtocolConfig{GroupsTLV: 0xE1},
+		DownstreamTLS: &TLSConfig{ClientCAFile: "ca.pem", ClientSubject: "uri"},
+	})
+	if problems := validateIdentitySources(ok.Listeners[0], "l"); len(problems) > 0 {
+		t.Errorf("groups from the header with a certificate subject refused: %v", problems)
+	}
+}
+
+func TestProxyHeaderIdentity(t *testing.T) {
+	h := proxy.ProxyHeader{
+		Source: netip.MustParseAddrPort("10.1.2.3:51234"),
+		TLVs: []proxy.TLV{
+			{Type: 0xE1, Value: []byte(" dba, oncall ,,")},
+			{Type: 0xE2, Value: []byte("alice@example.com")},
+		},
+	}
+	id := proxyHeaderIdentity(ProxyProtocolConfig{SubjectTLV: 0xE0, EmailTLV: 0xE2, GroupsTLV: 0xE1})(h)
+	if id.Subject != "" || id.Email != "alice@example.com" || id.PeerAddr != "10.1.2.3:51234" {
+		t.Errorf("identity = %+v", id)
+	}
+	if !slices.Equal(id.Groups, []string{"dba", "oncall"}) {
+		t.Errorf("groups = %q, want [dba oncall]", id.Groups)
+	}
+}
+
+func TestCertIdentityFields(t *testing.T) {
+	u, _ := url.Parse("spiffe://prod/sa/etl")
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "etl"},
+		URIs:           []*url.URL{u},
+		EmailAddresses: []string{"etl@example.com"},
+		DNSNames:       []string{"etl.prod.internal"},
+	}
+	for field, want := range map[string]string{
+		"cn":    "etl",
+		"uri":   "spiffe://prod/sa/etl",
+		"email": "etl@example.com",
+		"dns":   "etl.prod.internal",
+	} {
+		id := certIdentity(field)(cert)
+		if id.Subject != want || id.Email != "etl@example.com" {
+			t.Errorf("client_subject %s: identity = %+v, want subject %q", field, id, want)
+		}
+	}
+}
@@ -248,11 +248,22 @@ func TestMaskOnUnmaskableProtocolIsRefused(t *testing.T) {
 	det := stubPlugin{entities: []string{"US_SSN"}}
 	mc := MaskConfig{Enabled: ptr(true), Rules: []byte(`[{"entity":"US_SSN"}]`)}
 
-	if _, err := buildMasker(mc, det, hoopinspect.Protocol("mysql")); err == nil {
+	if _, err := buildMasker(mc, det, hoopinspect.Protocol("redis")); err == nil {
 		t.Error("buildMasker accepted a protocol with no codec and no masking path")
 	}
 }
 
+// MySQL text-protocol rows are re-framed by the codec, so the lane must
+// accept masking the same way postgres does.
+func TestMaskOnMySQLIsAccepted(t *testing.T) {
+	det := stubPlugin{entities: []string{"US_SSN"}}
+	mc := MaskConfig{Enabled: ptr(true), Rules: []byte(`[{"entity":"US_SSN"}]`)}
+
+	if _, err := buildMasker(mc, det, hoopinspect.MySQL); err != nil {
+		t.Errorf("buildMasker refused mysql: %v", err)
+	}
+}
+
 // The same mask config is fine on HTTP, which can re-tag Content-Length.
 func TestMaskOnHTTPIsAccepted(t *testing.T) {
 	cfg := &Config{
@@ -355,3 +366,53 @@ func TestGlobalOnlyConfigStillAppliesToEveryLane(t *testing.T) {
 		}
 	}
 }
+
+// Limits concatenate like rules, listener first, and reach the lane with the
+// lane's enforcement mode.
+func TestResolveConcatenatesLimitsListenerFirst(t *testing.T) {
+	cfg := &Config{
+		Policy: PolicyConfig{Limits: []policy.ResultLimit{{Name: "global", MaxRows: 10000}}},
+		Listeners: []ListenerConfig{{
+			Name: "lane", Protocol: "postgres", Listen: ":1", Upstream: "h:1",
+			Policy: &PolicyConfig{
+				Limits:  []policy.ResultLimit{{Name: "lane", MaxRows: 100, Groups: []string{"contractors"}}},
+				Enforce: ptr(true),
+			},
+		}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	lanes, err := buildLanes(cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("buildLanes: %v", err)
+	}
+	ln := lanes[0]
+	if len(ln.limits) != 2 || ln.limits[0].Name != "lane" || ln.limits[1].Name != "global" {
+		t.Errorf("limits = %+v, want lane then global", ln.limits)
+	}
+	if ln.observeLimits {
+		t.Error("an enforcing lane only observes its limits")
+	}
+}
+
+// A limit on a protocol whose codec cannot drop rows would load and cut
+// nothing, and a limit with nothing to limit is a typo.
+func TestLimitsValidated(t *testing.T) {
+	cfg := &Config{Listeners: []ListenerConfig{
+		{Name: "api", Protocol: "http", Listen: ":1", Upstream: "h:1",
+			Policy: &PolicyConfig{Limits: []policy.ResultLimit{{Name: "rows", MaxRows: 10}}}},
+		{Name: "db", Protocol: "postgres", Listen: ":2", Upstream: "h:2",
+			Policy: &PolicyConfig{Limits: []policy.ResultLimit{{Name: "empty"}}}},
+	}}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid limits accepted")
+	}
+	for _, want := range []string{"api: policy.limits are not supported on http", "db: policy: invalid limits: empty"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%v", want, err)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package sidecar
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hoophq/hoopinspect/audit"
+	"github.com/hoophq/hoopinspect/proxy"
+)
+
+// metrics is the process's Prometheus registry.
+//
+// Package-level, as a Prometheus client's default registry is, because the
+// things it counts are built in three places (a lane's sink in buildServer,
+// an OPA client in OPAConfig.client, an analyzer in buildAnalyzerEvaluators)
+// and rebuilt on every reload. Threading one handle through all of them
+// would change every signature between Main and a policy for the sake of a
+// counter.
+var metrics = newMetricSet()
+
+// Histogram buckets, in seconds. OPA answers from memory on the same host or
+// the next pod; a model is a network round trip plus inference.
+var (
+	opaBuckets      = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+	analyzerBuckets = []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+)
+
+type metricSet struct {
+	statements *counterVec
+	denials    *counterVec
+	masked     *counterVec
+	maskedVals *counterVec
+
+	opaSeconds      *histogramVec
+	opaErrors       *counterVec
+	analyzerSeconds *histogramVec
+	analyzerErrors  *counterVec
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{
+		statements: newCounterVec("hoopinspect_statements_total",
+			"Statements inspected.", "lane", "protocol", "operation"),
+		denials: newCounterVec("hoopinspect_denials_total",
+			"Statements and result sets denied, by the rule that denied them.", "lane", "protocol", "operation", "rule"),
+		masked: newCounterVec("hoopinspect_mask_applications_total",
+			"Responses in which masking rewrote at least one value.", "lane", "protocol"),
+		maskedVals: newCounterVec("hoopinspect_masked_values_total",
+			"Values masking rewrote.", "lane", "protocol"),
+
+		opaSeconds: newHistogramVec("hoopinspect_opa_decision_seconds",
+			"Time to an OPA decision, failures included.", opaBuckets, "phase"),
+		opaErrors: newCounterVec("hoopinspect_opa_errors_total",
+			"OPA decisions that failed: unreachable, a bad status or an unreadable answer.", "phase"),
+		analyzerSeconds: newHistogramVec("hoopinspect_analyzer_call_seconds",
+			"Time to a risk analyzer provider answer, failures included. Cached verdicts are not calls.",
+			analyzerBuckets, "rule"),
+		analyzerErrors: newCounterVec("hoopinspect_analyzer_errors_total",
+			"Risk analyzer calls that failed or returned no usable answer.", "rule"),
+	}
+}
+
+// observeOPA returns the Observe hook for an OPA client in phase.
+func (m *metricSet) observeOPA(phase string) func(time.Duration, error) {
+	if phase == "" {
+		phase = "single"
+	}
+	return func(d time.Duration, err error) {
+		m.opaSeconds.observe(d.Seconds(), phase)
+		if err != nil {
+			m.opaErrors.inc(1, phase)
+		}
+	}
+}
+
+// observeAnalyzer returns the Observe hook for the analyzer behind rule.
+func (m *metricSet) observeAnalyzer(rule string) func(time.Duration, error) {
+	return func(d time.Duration, err error) {
+		m.analyzerSeconds.observe(d.Seconds(), rule)
+		if err != nil {
+			m.analyzerErrors.inc(1, rule)
+		}
+	}
+}
+
+// laneSink counts one lane's events on their way to the audit sink.
+//
+// The counters are read off the events rather than from hooks in the gate,
+// so a metric and the audit row it summarizes are the same fact: a denial
+// the dashboard shows is one an auditor can find.
+type laneSink struct {
+	audit.Sink
+	lane string
+	m    *metricSet
+}
+
+func (m *metricSet) laneSink(lane string, inner audit.Sink) audit.Sink {
+	return &laneSink{Sink: inner, lane: lane, m: m}
+}
+
+func (s *laneSink) Write(ctx context.Context, ev audit.Event) error {
+	proto := string(ev.Protocol)
+	switch ev.Kind {
+	case audit.KindStatement:
+		s.m.statements.inc(1, s.lane, proto, string(ev.Operation))
+	case audit.KindViolation:
+		// A limit's denial has no statement of its own; the one the gate
+		// inspected was already counted.
+		if ev.Metadata["limit.action"] == "" {
+			s.m.statements.inc(1, s.lane, proto, string(ev.Operation))
+		}
+		s.m.denials.inc(1, s.lane, proto, string(ev.Operation), ev.Rule)
+	case audit.KindMasked:
+		s.m.masked.inc(1, s.lane, proto)
+		s.m.maskedVals.inc(float64(ev.MaskedCount), s.lane, proto)
+	}
+	return s.Sink.Write(ctx, ev)
+}
+
+// writeMetrics renders the registry and the scrape-time gauges in the
+// Prometheus text format, version 0.0.4.
+func writeMetrics(w io.Writer, m *metricSet, gauges []gauge) {
+	for _, c := range []*counterVec{m.statements, m.denials, m.masked, m.maskedVals} {
+		c.write(w)
+	}
+	m.opaSeconds.write(w)
+	m.opaErrors.write(w)
+	m.analyzerSeconds.write(w)
+	m.analyzerErrors.write(w)
+
+	// Gauges arrive grouped by name, each family's help written once.
+	for i, g := range gauges {
+		if i == 0 || gauges[i-1].name != g.name {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", g.name, g.help, g.name, g.kind)
+		}
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labels, formatFloat(g.value))
+	}
+}
+
+// scrapeGauges reads the values that already live elsewhere: each lane's
+// connections, which its Server counts, and the audit queue, which the
+// AsyncSink holds. servers and lanes are built in lockstep, as /stats relies
+// on.
+func scrapeGauges(servers []*proxy.Server, lanes []lane, ac auditChain) []gauge {
+	var active, total, limit []gauge
+	for i, s := range servers {
+		a, t, _ := s.Stats()
+		l := labelString([]string{"lane"}, []string{lanes[i].name})
+		active = append(active, gauge{"hoopinspect_connections_active",
+			"Open client connections.", "gauge", l, float64(a)})
+		total = append(total, gauge{"hoopinspect_connections_total",
+			"Client connections accepted.", "counter", l, float64(t)})
+		// Unlimited has no number to plot a ceiling at, so it has no series.
+		if m := lanes[i].cfg.MaxConns; m > 0 {
+			limit = append(limit, gauge{"hoopinspect_connections_max",
+				"The lane's max_conns; connections past it are refused.", "gauge", l, float64(m)})
+		}
+	}
+	out := append(append(active, total...), limit...)
+
+	out = append(out, gauge{"hoopinspect_build_info",
+		"The running build.", "gauge", labelString([]string{"version"}, []string{Version}), 1})
+	if a := ac.async; a != nil {
+		out = append(out,
+			gauge{"hoopinspect_audit_queue_depth", "Audit events queued and not yet written.", "gauge", "", float64(a.Depth())},
+			gauge{"hoopinspect_audit_queue_capacity", "The audit queue's size, async_queue_size.", "gauge", "", float64(a.Capacity())},
+			gauge{"hoopinspect_audit_dropped_total", "Audit events refused because the queue was full.", "counter", "", float64(a.Dropped())},
+		)
+	}
+	return out
+}
+
+// gauge is one value read at scrape time rather than kept in the registry:
+// a connection count or a queue depth already lives somewhere, and copying
+// it into a second place only makes it stale. kind is "gauge" or "counter".
+type gauge struct {
+	name, help, kind string
+	labels           string
+	value            float64
+}
+
+// counterVec is a counter family keyed by its label values.
+type counterVec struct {
+	name, help string
+	labels     []string
+
+	mu   sync.Mutex
+	vals map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, vals: map[string]float64{}}
+}
+
+func (c *counterVec) inc(n float64, values ...string) {
+	key := labelString(c.labels, values)
+	c.mu.Lock()
+	c.vals[key] += n
+	c.mu.Unlock()
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range slices.Sorted(maps.Keys(c.vals)) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, key, formatFloat(c.vals[key]))
+	}
+}
+
+// histogramVec is a histogram family keyed by its label values.
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu   sync.Mutex
+	vals map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, vals: map[string]*histogram{}}
+}
+
+func (h *histogramVec) observe(v float64, values ...string) {
+	key := labelString(h.labels, values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist := h.vals[key]
+	if hist == nil {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.vals[key] = hist
+	}
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		hist.counts[i]++
+	}
+	hist.sum += v
+	hist.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range slices.Sorted(maps.Keys(h.vals)) {
+		hist := h.vals[key]
+		// The le label joins the family's own; key is "{...}" or "".
+		prefix := "{"
+		if key != "" {
+			prefix = key[:len(key)-1] + ","
+		}
+		var cum uint64
+		for i, b := range h.buckets {
+			cum += hist.counts[i]
+			fmt.Fprintf(w, "%s_bucket%sle=\"%s\"} %d\n", h.name, prefix, formatFloat(b), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket%sle=\"+Inf\"} %d\n", h.name, prefix, hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, key, formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, key, hist.count)
+	}
+}
+
+// labelString renders {name="value",...}, escaped as the text format
+// requires, or "" for a family without labels.
+func labelString(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+		b.WriteString(n)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(v))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
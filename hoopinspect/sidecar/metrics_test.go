@@ -0,0 +1,85 @@
+package sidecar
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/audit"
+)
+
+func scrape(m *metricSet, gauges []gauge) string {
+	var b strings.Builder
+	writeMetrics(&b, m, gauges)
+	return b.String()
+}
+
+// The lane counters come from the events the gate writes, so a statement, a
+// denial and a masked response each land in their own family.
+func TestLaneSinkCountsEvents(t *testing.T) {
+	m := newMetricSet()
+	s := m.laneSink("reporting", audit.NewMemorySink(16))
+	ctx := context.Background()
+	for _, ev := range []audit.Event{
+		{Kind: audit.KindStatement, Protocol: hoopinspect.Postgres, Operation: hoopinspect.OpSelect},
+		{Kind: audit.KindStatement, Protocol: hoopinspect.Postgres, Operation: hoopinspect.OpSelect},
+		{Kind: audit.KindViolation, Protocol: hoopinspect.Postgres, Operation: hoopinspect.OpDelete, Rule: "no-deletes"},
+		// A limit's denial follows a statement already counted.
+		{Kind: audit.KindViolation, Protocol: hoopinspect.Postgres, Operation: hoopinspect.OpSelect, Rule: "row-cap",
+			Metadata: map[string]string{"limit.action": "deny"}},
+		{Kind: audit.KindMasked, Protocol: hoopinspect.Postgres, MaskedCount: 3},
+		{Kind: audit.KindSessionStart, Protocol: hoopinspect.Postgres},
+	} {
+		if err := s.Write(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := scrape(m, nil)
+	for _, want := range []string{
+		`hoopinspect_statements_total{lane="reporting",protocol="postgres",operation="select"} 2`,
+		`hoopinspect_statements_total{lane="reporting",protocol="postgres",operation="delete"} 1`,
+		`hoopinspect_denials_total{lane="reporting",protocol="postgres",operation="delete",rule="no-deletes"} 1`,
+		`hoopinspect_denials_total{lane="reporting",protocol="postgres",operation="select",rule="row-cap"} 1`,
+		`hoopinspect_mask_applications_total{lane="reporting",protocol="postgres"} 1`,
+		`hoopinspect_masked_values_total{lane="reporting",protocol="postgres"} 3`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("missing %s in:\n%s", want, out)
+		}
+	}
+}
+
+func TestMetricsExposition(t *testing.T) {
+	m := newMetricSet()
+	opa := m.observeOPA("")
+	opa(3*time.Millisecond, nil)
+	opa(2*time.Second, errors.New("unreachable"))
+	m.observeAnalyzer(`risk "v2"`)(40*time.Millisecond, nil)
+
+	out := scrape(m, []gauge{
+		{"hoopinspect_connections_active", "Open client connections.", "gauge", `{lane="a"}`, 2},
+		{"hoopinspect_connections_active", "Open client connections.", "gauge", `{lane="b"}`, 0},
+	})
+	for _, want := range []string{
+		"# TYPE hoopinspect_opa_decision_seconds histogram",
+		`hoopinspect_opa_decision_seconds_bucket{phase="single",le="0.0025"} 0`,
+		`hoopinspect_opa_decision_seconds_bucket{phase="single",le="0.005"} 1`,
+		`hoopinspect_opa_decision_seconds_bucket{phase="single",le="2.5"} 2`,
+		`hoopinspect_opa_decision_seconds_bucket{phase="single",le="+Inf"} 2`,
+		`hoopinspect_opa_decision_seconds_count{phase="single"} 2`,
+		`hoopinspect_opa_errors_total{phase="single"} 1`,
+		`hoopinspect_analyzer_call_seconds_bucket{rule="risk \"v2\"",le="0.05"} 1`,
+		`hoopinspect_connections_active{lane="b"} 0`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("missing %s in:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "# TYPE hoopinspect_connections_active gauge"); n != 1 {
+		t.Errorf("connections_active declared %d times, want once", n)
+	}
+}
@@ -0,0 +1,236 @@
+package sidecar
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hoophq/hoopinspect/gate"
+	"github.com/hoophq/hoopinspect/policy"
+)
+
+// Reloader re-reads the config the process was started with and builds its
+// detection plugin, the way Setup did at startup. Main passes a closure over
+// Setup and its arguments; nil disables reloading.
+type Reloader func() (*Config, Plugin, error)
+
+// errReloadUnavailable is returned by a reload on a process started without
+// a Reloader, such as one embedding Run with a Config it built by hand.
+var errReloadUnavailable = errors.New(
+	"config reload is not available: this process was not started from a config file")
+
+// Revision identifies the config a running process is enforcing.
+//
+// Number counts successful loads, starting at 1, so an operator can tell
+// "the reload took" from "the reload was refused and revision 3 is still
+// running". Digest names the content, so two pods can be compared without
+// reading either one's file.
+type Revision struct {
+	Number   int       `json:"number"`
+	Digest   string    `json:"digest"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+func newRevision(number int, cfg *Config) Revision {
+	// Marshal, not the file's bytes: YAML and JSON spellings of one config,
+	// or a comment edit, should not read as a policy change.
+	b, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(b)
+	return Revision{
+		Number:   number,
+		Digest:   "sha256:" + hex.EncodeToString(sum[:]),
+		LoadedAt: time.Now().UTC(),
+	}
+}
+
+// runtime is the running process's view of its own config: the lanes in
+// force, the Live stack each lane's server reads, and the revision they came
+// from.
+//
+// Lanes are swapped rather than servers restarted, because a restart is what
+// reloading exists to avoid: it drops every open psql session on the pod.
+// What a live connection reads per chunk (policy, masks, limits) changes in
+// place through gate.Live. What it cannot change mid-connection (protocol,
+// listen address, upstream, TLS, the audit sinks) needs a restart, and a
+// reload that changes any of it is refused whole rather than half-applied.
+type runtime struct {
+	// reloadMu serializes reloads, so a SIGHUP and a POST arriving together
+	// cannot interleave their builds and leave lanes from two files.
+	reloadMu sync.Mutex
+
+	mu       sync.RWMutex
+	cfg      *Config
+	lanes    []lane
+	revision Revision
+
+	// live is indexed like lanes and fixed for the process's life: the
+	// servers hold these pointers, and a reload stores into them.
+	live   []*gate.Live
+	reload Reloader
+	log    *slog.Logger
+
+	// rates are the allowances every lane's rate rules draw on. One for
+	// the process, so a reload does not hand a throttled user a fresh one.
+	rates *policy.RateCounters
+}
+
+func newRuntime(cfg *Config, lanes []lane, reload Reloader, log *slog.Logger) *runtime {
+	rt := &runtime{
+		cfg:      cfg,
+		lanes:    lanes,
+		revision: newRevision(1, cfg),
+		reload:   reload,
+		log:      log,
+		rates:    policy.NewRateCounters(),
+	}
+	for _, ln := range lanes {
+		shareRates(ln.policy, rt.rates, ln.name)
+		rt.live = append(rt.live, gate.NewLive(ln.stack()))
+	}
+	return rt
+}
+
+// snapshot returns the lanes, config and revision in force, consistently.
+func (rt *runtime) snapshot() ([]lane, *Config, Revision) {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	return rt.lanes, rt.cfg, rt.revision
+}
+
+// Reload re-reads and re-validates the config and, if it passes, swaps every
+// lane's stack at once. On any error the running config is untouched.
+//
+// It runs the same checks as startup, in the same order, so a file that
+// would not start does not reload either: the all-problems-at-once
+// validator, the analyzer credential check, and the lane build that proves
+// pii entities resolve.
+func (rt *runtime) Reload() (Revision, error) {
+	rt.reloadMu.Lock()
+	defer rt.reloadMu.Unlock()
+
+	_, current, rev := rt.snapshot()
+	if rt.reload == nil {
+		return rev, errReloadUnavailable
+	}
+	cfg, det, err := rt.reload()
+	if err != nil {
+		return rev, err
+	}
+	if problems := restartRequired(current, cfg); len(problems) > 0 {
+		return rev, fmt.Errorf("config changes need a restart:\n  - %s",
+			strings.Join(problems, "\n  - "))
+	}
+	if err := checkPIIPlugin(cfg, det); err != nil {
+		return rev, err
+	}
+	ac, err := setupAnalyzer(cfg, det)
+	if err != nil {
+		return rev, err
+	}
+	if err := verifyAnalyzer(ac); err != nil {
+		return rev, err
+	}
+	lanes, err := buildLanes(cfg, det, ac)
+	if err != nil {
+		return rev, err
+	}
+
+	rt.mu.Lock()
+	for i, ln := range lanes {
+		shareRates(ln.policy, rt.rates, ln.name)
+		rt.live[i].Store(ln.stack())
+	}
+	rt.cfg, rt.lanes = cfg, lanes
+	rt.revision = newRevision(rev.Number+1, cfg)
+	rev = rt.revision
+	rt.mu.Unlock()
+
+	rt.log.Info("config reloaded", "revision", rev.Number, "digest", rev.Digest)
+	for _, ln := range lanes {
+		logLane(rt.log, ln)
+	}
+	return rev, nil
+}
+
+// shareRates points every Rules in a lane's evaluator at the process's rate
+// counters, under the lane's name. buildPolicy nests Rules in a Chain, so it
+// walks one.
+func shareRates(ev policy.Evaluator, c *policy.RateCounters, lane string) {
+	switch e := ev.(type) {
+	case *policy.Rules:
+		e.ShareRates(c, lane)
+	case policy.Chain:
+		for _, inner := range e {
+			shareRates(inner, c, lane)
+		}
+	}
+}
+
+// restartRequired lists what differs between two configs that a reload
+// cannot apply.
+//
+// A listener is compared with its policy and mask removed, field by field, so
+// the refusal names what to undo. The listener list is compared by position,
+// because lanes and servers are joined by index: adding or reordering one
+// is a different set of sockets.
+func restartRequired(old, cur *Config) []string {
+	var problems []string
+	if len(old.Listeners) != len(cur.Listeners) {
+		return []string{fmt.Sprintf("the listener count changed (%d to %d)",
+			len(old.Listeners), len(cur.Listeners))}
+	}
+	for i := range old.Listeners {
+		a, b := old.Listeners[i], cur.Listeners[i]
+		a.Policy, a.Mask, b.Policy, b.Mask = nil, nil, nil, nil
+		a.Tests, b.Tests = nil, nil
+		if changed := changedFields(a, b); len(changed) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: %s changed",
+				old.Listeners[i].displayName(i), strings.Join(changed, ", ")))
+		}
+	}
+	if changed := changedFields(
+		struct {
+			Audit    AuditConfig `json:"audit"`
+			Admin    AdminConfig `json:"admin"`
+			LogLevel string      `json:"log_level"`
+		}{old.Audit, old.Admin, old.LogLevel},
+		struct {
+			Audit    AuditConfig `json:"audit"`
+			Admin    AdminConfig `json:"admin"`
+			LogLevel string      `json:"log_level"`
+		}{cur.Audit, cur.Admin, cur.LogLevel},
+	); len(changed) > 0 {
+		problems = append(problems, strings.Join(changed, ", ")+" changed")
+	}
+	return problems
+}
+
+// changedFields names the top-level JSON fields whose values differ.
+func changedFields(a, b any) []string {
+	var ma, mb map[string]json.RawMessage
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	_ = json.Unmarshal(ja, &ma)
+	_ = json.Unmarshal(jb, &mb)
+
+	var out []string
+	for k, va := range ma {
+		if string(va) != string(mb[k]) {
+			out = append(out, k)
+		}
+	}
+	for k := range mb {
+		if _, ok := ma[k]; !ok {
+			out = append(out, k)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
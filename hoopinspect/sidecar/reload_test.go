@@ -0,0 +1,175 @@
+package sidecar
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+)
+
+const reloadBase = `{
+  "listeners": [{
+    "name": "appdb", "protocol": "postgres",
+    "listen": "127.0.0.1:15432", "upstream": "db:5432"
+  }],
+  "policy": {%s}
+}`
+
+// startRuntime loads path as Run would and returns the runtime over it,
+// with a Reloader that re-reads the same file.
+func startRuntime(t *testing.T, path string) *runtime {
+	t.Helper()
+	cfg, det, err := Setup(path, nil, nil)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	lanes, err := buildLanes(cfg, det, nil)
+	if err != nil {
+		t.Fatalf("buildLanes: %v", err)
+	}
+	reload := func() (*Config, Plugin, error) { return Setup(path, nil, nil) }
+	return newRuntime(cfg, lanes, reload, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func rewrite(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("rewrite config: %v", err)
+	}
+}
+
+const dropRule = `"enforce": true, "rules": [{"name": "no-drop", "type": "operation", "operations": ["drop"]}]`
+
+func denies(rt *runtime, op hoopinspect.Operation) bool {
+	pol := rt.live[0].Load().Policy
+	return pol != nil && pol.Evaluate(hoopinspect.Statement{Operation: op}).Denied
+}
+
+// A reload reaches the Live stack an open connection's gate reads, which is
+// the whole point: the session that was open before the edit is governed by
+// the edit.
+func TestReloadSwapsTheLiveStack(t *testing.T) {
+	path := writeConfig(t, strings.Replace(reloadBase, "%s", "", 1))
+	rt := startRuntime(t, path)
+	live := rt.live[0]
+	if denies(rt, hoopinspect.OpDrop) {
+		t.Fatal("observe-only lane denied before the reload")
+	}
+
+	rewrite(t, path, strings.Replace(reloadBase, "%s", dropRule, 1))
+	rev, err := rt.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if rev.Number != 2 {
+		t.Errorf("revision = %d, want 2", rev.Number)
+	}
+	if rt.live[0] != live {
+		t.Error("the reload replaced the Live a server holds instead of storing into it")
+	}
+	if !denies(rt, hoopinspect.OpDrop) {
+		t.Error("the reloaded rule does not deny")
+	}
+	lanes, _, cur := rt.snapshot()
+	if cur != rev || len(lanes[0].rules) != 1 {
+		t.Errorf("snapshot not updated: revision %+v, rules %v", cur, lanes[0].rules)
+	}
+}
+
+// A file that would not start does not reload, and the process keeps
+// enforcing what it had.
+func TestReloadOfInvalidConfigKeepsTheRunningOne(t *testing.T) {
+	path := writeConfig(t, strings.Replace(reloadBase, "%s", dropRule, 1))
+	rt := startRuntime(t, path)
+	_, _, before := rt.snapshot()
+
+	rewrite(t, path, strings.Replace(reloadBase, "%s",
+		`"enforce": true, "rules": [{"name": "", "type": "nonsense"}]`, 1))
+	rev, err := rt.Reload()
+	if err == nil {
+		t.Fatal("an invalid config reloaded")
+	}
+	if rev != before {
+		t.Errorf("refusal reported revision %+v, want the running %+v", rev, before)
+	}
+	if !denies(rt, hoopinspect.OpDrop) {
+		t.Error("a refused reload changed the running policy")
+	}
+}
+
+// Changing what a listener is, rather than what it enforces, needs new
+// sockets; the reload is refused with the field named.
+func TestReloadRefusesRestartRequiredChanges(t *testing.T) {
+	path := writeConfig(t, strings.Replace(reloadBase, "%s", "", 1))
+	rt := startRuntime(t, path)
+
+	moved := strings.Replace(reloadBase, "db:5432", "replica:5432", 1)
+	rewrite(t, path, strings.Replace(moved, "%s", dropRule, 1))
+	_, err := rt.Reload()
+	if err == nil || !strings.Contains(err.Error(), "appdb: upstream changed") {
+		t.Fatalf("err = %v, want a refusal naming the upstream", err)
+	}
+	if denies(rt, hoopinspect.OpDrop) {
+		t.Error("a refused reload applied its policy anyway")
+	}
+}
+
+func TestReloadWithoutReloaderIsUnavailable(t *testing.T) {
+	path := writeConfig(t, strings.Replace(reloadBase, "%s", "", 1))
+	rt := startRuntime(t, path)
+	rt.reload = nil
+	if _, err := rt.Reload(); err != errReloadUnavailable {
+		t.Errorf("err = %v, want errReloadUnavailable", err)
+	}
+}
+
+// The digest names the content, so a no-op reload keeps it and an edit
+// changes it.
+func TestRevisionDigestFollowsContent(t *testing.T) {
+	path := writeConfig(t, strings.Replace(reloadBase, "%s", "", 1))
+	rt := startRuntime(t, path)
+	_, _, first := rt.snapshot()
+
+	same, err := rt.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if same.Digest != first.Digest || same.Number != 2 {
+		t.Errorf("unchanged file: %+v after %+v", same, first)
+	}
+
+	rewrite(t, path, strings.Replace(reloadBase, "%s", dropRule, 1))
+	edited, err := rt.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if edited.Digest == first.Digest {
+		t.Error("an edited config kept its digest")
+	}
+}
+
+// A throttled user stays throttled across a reload: the allowance lives in
+// the process's counters, not in the rule set the reload replaced.
+func TestReloadKeepsRateAllowances(t *testing.T) {
+	const rate = `"enforce": true, "rules": [{"name": "deletes", "type": "rate",
+	  "operations": ["delete"], "rate": {"limit": 1, "window_sec": 3600}}]`
+	path := writeConfig(t, strings.Replace(reloadBase, "%s", rate, 1))
+	rt := startRuntime(t, path)
+
+	del := hoopinspect.Statement{Operation: hoopinspect.OpDelete}
+	if rt.live[0].Load().Policy.Evaluate(del).Denied {
+		t.Fatal("the first DELETE was denied")
+	}
+	if _, err := rt.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !rt.live[0].Load().Policy.Evaluate(del).Denied {
+		t.Error("the reload handed out a fresh allowance")
+	}
+	if st := rt.rates.Stats(); len(st) != 1 || st[0].Lane != "appdb" || st[0].Denied != 1 {
+		t.Errorf("rate stats = %+v", st)
+	}
+}
@@ -0,0 +1,253 @@
+package sidecar
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/policy"
+	"github.com/hoophq/hoopinspect/replay"
+)
+
+// replayStatementWidth bounds the statement text on a verdict line. The
+// line is for finding the statement, not reading it; the audit trail and
+// the capture both hold the whole thing.
+const replayStatementWidth = 72
+
+// replayMain is the replay subcommand: run captured traffic through one
+// listener's stack, offline, and print what it decided.
+//
+// It builds the lane exactly as a start would, through Setup and buildLanes,
+// so a config that replays clean is the config that runs, and one that
+// would not start does not replay. With -baseline it runs the same capture
+// through a second config and prints where the two disagree, which is the
+// question a rule change actually asks: not "what does this deny" but "what
+// does this deny that we did not deny yesterday".
+func replayMain(args []string, load Loader, build PluginBuilder, out io.Writer) error {
+	fs := flag.NewFlagSet("hoop-inspect replay", flag.ContinueOnError)
+	var (
+		configPath   = fs.String("config", "", "path to the config to evaluate")
+		baselinePath = fs.String("baseline", "", "path to the config to compare against")
+		pcapPath     = fs.String("pcap", "", "libpcap capture to replay")
+		dumpPath     = fs.String("dump", "", "hoop-inspect dump to replay")
+		listener     = fs.String("listener", "", "listener whose stack to use; required with more than one")
+		port         = fs.Int("port", 0, "server port of the flows to replay; defaults to the listener's upstream and listen ports")
+	)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+	if *configPath == "" {
+		fs.Usage()
+		return fmt.Errorf("%w: -config is required", ErrUsage)
+	}
+	if (*pcapPath == "") == (*dumpPath == "") {
+		fs.Usage()
+		return fmt.Errorf("%w: exactly one of -pcap and -dump is required", ErrUsage)
+	}
+
+	flows, err := readCapture(*pcapPath, *dumpPath)
+	if err != nil {
+		return err
+	}
+
+	after, ln, err := replayLane(*configPath, *listener, load, build)
+	if err != nil {
+		return err
+	}
+	flows, ignored := serverFlows(flows, ln.cfg, *port)
+	fmt.Fprintf(out, "replaying %d flow(s) through %s (%s)", len(flows), ln.name, after.Protocol)
+	if ignored > 0 {
+		fmt.Fprintf(out, ", %d flow(s) to other ports ignored", ignored)
+	}
+	fmt.Fprintln(out)
+
+	ctx := context.Background()
+	rep := replay.Run(ctx, flows, after)
+	printReport(out, rep)
+
+	if *baselinePath == "" {
+		return nil
+	}
+	before, bln, err := replayLane(*baselinePath, *listener, load, build)
+	if err != nil {
+		return fmt.Errorf("baseline: %w", err)
+	}
+	if before.Protocol != after.Protocol {
+		return fmt.Errorf("baseline: listener %s is %s, not %s", bln.name, before.Protocol, after.Protocol)
+	}
+	changes := replay.Diff(replay.Run(ctx, flows, before), rep)
+	fmt.Fprintf(out, "\ncompared with %s: %d change(s)\n", *baselinePath, len(changes))
+	for _, c := range changes {
+		at := fmt.Sprintf("flow %d read %d", c.Flow, c.Chunk)
+		if c.Statement == "" {
+			fmt.Fprintf(out, "  %-20s %s -> %s  (response)\n", at, c.Before, c.After)
+			continue
+		}
+		fmt.Fprintf(out, "  %-20s %s -> %s  %s\n", at, c.Before, c.After, clip(c.Statement))
+	}
+	return nil
+}
+
+// readCapture loads whichever of the two capture formats was given.
+func readCapture(pcapPath, dumpPath string) ([]replay.Flow, error) {
+	path, read := pcapPath, replay.ReadPcap
+	if dumpPath != "" {
+		path, read = dumpPath, replay.ReadDump
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	flows, err := read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return flows, nil
+}
+
+// replayLane builds one listener of the config at path into a replay.Lane,
+// returning the built lane alongside for its name and listener config.
+//
+// Every lane is built, not only the chosen one, because that is what a
+// start does and a config whose other lanes are broken would not run.
+func replayLane(path, name string, load Loader, build PluginBuilder) (replay.Lane, lane, error) {
+	cfg, det, err := Setup(path, load, build)
+	if err != nil {
+		return replay.Lane{}, lane{}, err
+	}
+	if err := checkPIIPlugin(cfg, det); err != nil {
+		return replay.Lane{}, lane{}, err
+	}
+	ac, err := setupAnalyzer(cfg, det)
+	if err != nil {
+		return replay.Lane{}, lane{}, err
+	}
+	if err := verifyAnalyzer(ac); err != nil {
+		return replay.Lane{}, lane{}, err
+	}
+	lanes, err := buildLanes(cfg, det, ac)
+	if err != nil {
+		return replay.Lane{}, lane{}, err
+	}
+
+	var ln *lane
+	switch {
+	case name != "":
+		for i := range lanes {
+			if lanes[i].name == name {
+				ln = &lanes[i]
+			}
+		}
+		if ln == nil {
+			return replay.Lane{}, lane{}, fmt.Errorf("%w: %s has no listener %q", ErrUsage, path, name)
+		}
+	case len(lanes) == 1:
+		ln = &lanes[0]
+	default:
+		names := make([]string, 0, len(lanes))
+		for _, l := range lanes {
+			names = append(names, l.name)
+		}
+		return replay.Lane{}, lane{}, fmt.Errorf("%w: %s has %d listeners; pick one with -listener (%s)",
+			ErrUsage, path, len(lanes), strings.Join(names, ", "))
+	}
+
+	// A rate rule counts against its counters' clock. Replay moves that
+	// clock with the capture, so an allowance is spent at the rate the
+	// traffic arrived rather than the rate the file is read.
+	now := time.Now()
+	rates := policy.NewRateCounters()
+	rates.Now = func() time.Time { return now }
+	shareRates(ln.policy, rates, ln.name)
+
+	rl := replay.Lane{
+		Protocol:     hoopinspect.Protocol(ln.cfg.Protocol),
+		Connection:   ln.cfg.Connection,
+		Stack:        ln.stack(),
+		CodecFactory: ln.codecFactory,
+		Clock:        func(t time.Time) { now = t },
+	}
+	if pp := ln.cfg.ProxyProtocol; pp != nil {
+		rl.ProxyIdentity = proxyHeaderIdentity(*pp)
+	}
+	return rl, *ln, nil
+}
+
+// serverFlows keeps the flows addressed to the lane: the given port, or
+// else the listener's upstream or listen port. A capture taken on a busy
+// host carries other conversations, and running a MySQL flow through a
+// Postgres codec reports nothing but framing errors.
+//
+// Flows without endpoints, which is every flow in a dump, are kept.
+func serverFlows(flows []replay.Flow, lc ListenerConfig, port int) ([]replay.Flow, int) {
+	ports := map[uint16]bool{}
+	if port > 0 {
+		ports[uint16(port)] = true
+	} else {
+		for _, addr := range []string{lc.Upstream, lc.Listen} {
+			if _, p, err := net.SplitHostPort(addr); err == nil {
+				if n, err := strconv.ParseUint(p, 10, 16); err == nil {
+					ports[uint16(n)] = true
+				}
+			}
+		}
+	}
+
+	out := flows[:0:0]
+	ignored := 0
+	for _, f := range flows {
+		if f.Server.IsValid() && len(ports) > 0 && !ports[f.Server.Port()] {
+			ignored++
+			continue
+		}
+		out = append(out, f)
+	}
+	return out, ignored
+}
+
+// printReport writes one line per statement, then what was skipped, what
+// went wrong, and the totals.
+func printReport(out io.Writer, rep replay.Report) {
+	for _, v := range rep.Verdicts {
+		dir := "client"
+		if v.Direction == hoopinspect.FromServer {
+			dir = "server"
+		}
+		fmt.Fprintf(out, "  %-20s %-6s %-10s %-28s %s\n",
+			fmt.Sprintf("flow %d read %d", v.Flow, v.Chunk), dir, v.Operation, v.String(), clip(v.Statement))
+	}
+	for _, rw := range rep.Rewrites {
+		fmt.Fprintf(out, "  %-20s %-6s %-10s %s\n",
+			fmt.Sprintf("flow %d read %d", rw.Flow, rw.Chunk), "server", "response", rw.String())
+	}
+	for _, s := range rep.Skipped {
+		fmt.Fprintf(out, "  flow %d skipped: %s\n", s.Flow, s.Reason)
+	}
+	for _, e := range rep.Errors {
+		fmt.Fprintf(out, "  error: %s\n", e)
+	}
+	t := rep.Totals()
+	fmt.Fprintf(out, "%d statement(s): %d allowed, %d denied, %d unreached; %d masked cell(s), %d limited read(s)\n",
+		t.Statements, t.Allowed, t.Denied, t.Unreached, t.MaskedCells, t.Limited)
+}
+
+// clip flattens a statement to one line and cuts it to the verdict width.
+func clip(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > replayStatementWidth {
+		return string(r[:replayStatementWidth-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package sidecar
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/replay"
+)
+
+// writeDump stores one Postgres flow that starts up and runs queries.
+func writeDump(t *testing.T, queries ...string) string {
+	t.Helper()
+	params := "user\x00alice\x00\x00"
+	startup := binary.BigEndian.AppendUint32(nil, uint32(8+len(params)))
+	startup = binary.BigEndian.AppendUint32(startup, 3<<16)
+	f := replay.Flow{Chunks: []replay.Chunk{{Direction: hoopinspect.FromClient, Data: append(startup, params...)}}}
+	for _, q := range queries {
+		msg := append([]byte{'Q'}, binary.BigEndian.AppendUint32(nil, uint32(len(q)+5))...)
+		msg = append(append(msg, q...), 0)
+		f.Chunks = append(f.Chunks, replay.Chunk{Direction: hoopinspect.FromClient, Data: msg})
+	}
+
+	p := filepath.Join(t.TempDir(), "capture.dump")
+	out, err := os.Create(p)
+	if err != nil {
+		t.Fatalf("create dump: %v", err)
+	}
+	defer out.Close()
+	if err := replay.WriteDump(out, []replay.Flow{f}); err != nil {
+		t.Fatalf("WriteDump: %v", err)
+	}
+	return p
+}
+
+// The subcommand reports each statement under the new config and, given a
+// baseline, exactly the statements whose outcome the edit changed.
+func TestReplayComparesAgainstBaseline(t *testing.T) {
+	before := writeConfig(t, strings.Replace(reloadBase, "%s", "", 1))
+	after := writeConfig(t, strings.Replace(reloadBase, "%s", dropRule, 1))
+	dump := writeDump(t, "SELECT 1", "DROP TABLE t")
+
+	var out strings.Builder
+	err := replayMain([]string{"-config", after, "-baseline", before, "-dump", dump}, nil, nil, &out)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"replaying 1 flow(s) through appdb (postgres)",
+		"deny (no-drop)",
+		"2 statement(s): 1 allowed, 1 denied",
+		": 1 change(s)",
+		"allow -> deny (no-drop)  DROP TABLE t",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestReplayNeedsExactlyOneCapture(t *testing.T) {
+	cfg := writeConfig(t, strings.Replace(reloadBase, "%s", "", 1))
+	var out strings.Builder
+	err := replayMain([]string{"-config", cfg}, nil, nil, &out)
+	if !errors.Is(err, ErrUsage) {
+		t.Errorf("err = %v, want ErrUsage", err)
+	}
+}
@@ -26,6 +26,7 @@ package sidecar
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -114,7 +115,10 @@ var ErrUsage = errors.New("usage")
 //
 //	hoop-inspect -config /etc/hoop-inspect/config.yaml
 //	hoop-inspect -validate -config config.yaml   # check and exit
+//	hoop-inspect -test -config config.yaml       # run the tests: sections
 //	hoop-inspect -version
+//	hoop-inspect replay -config new.yaml -baseline old.yaml -pcap capture.pcap
+//	hoop-inspect audit verify -file audit.jsonl -public-key audit.pub
 func Main(version string, load Loader, build PluginBuilder) error {
 	Version = version
 
@@ -123,6 +127,15 @@ func Main(version string, load Loader, build PluginBuilder) error {
 		syntax = "YAML or JSON"
 	}
 
+	// replay is a subcommand rather than a flag: it takes its own flags,
+	// and none of the ones below mean anything to it.
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		return replayMain(os.Args[2:], load, build, os.Stdout)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		return auditMain(os.Args[2:], os.Stdout)
+	}
+
 	// A local FlagSet rather than flag.CommandLine: the global one is
 	// ExitOnError, so a typo in an argument would kill the process from
 	// inside this package before any of the code below ran.
@@ -130,6 +143,7 @@ func Main(version string, load Loader, build PluginBuilder) error {
 	var (
 		configPath = fs.String("config", "", "path to the config file ("+syntax+")")
 		validate   = fs.Bool("validate", false, "validate the config and exit")
+		runTests   = fs.Bool("test", false, "run each listener's policy tests and exit")
 		showVer    = fs.Bool("version", false, "print the version and exit")
 	)
 	if err := fs.Parse(os.Args[1:]); err != nil {
@@ -155,6 +169,14 @@ func Main(version string, load Loader, build PluginBuilder) error {
 		return err
 	}
 
+	if *runTests {
+		results, err := Test(cfg, det)
+		if err != nil {
+			return err
+		}
+		return ReportTests(os.Stdout, results)
+	}
+
 	if *validate {
 		lanes, err := Validate(cfg, det)
 		if err != nil {
@@ -167,7 +189,9 @@ func Main(version string, load Loader, build PluginBuilder) error {
 		return nil
 	}
 
-	return Run(cfg, det)
+	return RunWithReload(cfg, det, func() (*Config, Plugin, error) {
+		return Setup(*configPath, load, build)
+	})
 }
 
 // LaneInfo is one resolved listener, as Validate reports it.
@@ -266,7 +290,19 @@ func checkPIIPlugin(cfg *Config, det Plugin) error {
 // det is the optional detection plugin. Passing nil disables masking and
 // rejects any pii policy rule. Call Run to embed the relay in your own
 // binary; the shipped one goes through Main.
+//
+// A process started with Run cannot reload its config, because it has no
+// file to re-read; use RunWithReload for that.
 func Run(cfg *Config, det Plugin) error {
+	return RunWithReload(cfg, det, nil)
+}
+
+// RunWithReload is Run with a way to re-read the config: on SIGHUP, and on
+// POST /config/reload when the admin endpoint is on, it calls reload and
+// swaps every lane's policy, masks and limits for the result, open
+// connections included. A config that fails validation, or that changes
+// something only a restart can apply, is refused and the running one stays.
+func RunWithReload(cfg *Config, det Plugin, reload Reloader) error {
 	if err := checkPIIPlugin(cfg, det); err != nil {
 		return err
 	}
@@ -313,34 +349,26 @@ func Run(cfg *Config, det Plugin) error {
 		os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	rt := newRuntime(cfg, lanes, reload, log)
 	servers := make([]*proxy.Server, 0, len(lanes))
-	for _, ln := range lanes {
-		srv, serr := buildServer(ln, cfg.Audit, auditSink, log)
+	for i, ln := range lanes {
+		srv, serr := buildServer(ln, rt.live[i], cfg.Audit, auditSink, log)
 		if serr != nil {
 			return serr
 		}
 		servers = append(servers, srv)
-
-		// One line per lane naming what it enforces. The config file does not
-		// show what a lane inherited, so this logs the RESOLVED stack: it turns
-		// "why did this not deny" into a minute of reading instead of an
-		// afternoon.
-		log.Info("lane ready",
-			"listener", ln.name,
-			"protocol", ln.cfg.Protocol,
-			"upstream", ln.cfg.Upstream,
-			"enforcing", ln.policy != nil,
-			"rules", len(ln.rules),
-			"opa", ln.opaURL,
-			"masking", ln.masker != nil)
-		if ln.policy == nil {
-			log.Warn("lane is observe-only; no statement will be denied",
-				"listener", ln.name, "hint", "set policy.enforce=true on this listener or at the top level")
-		}
+		logLane(log, ln)
 	}
 
+	if reload != nil {
+		go reloadOnHangup(ctx, rt)
+	}
 	if cfg.Admin.Listen != "" {
-		go serveAdmin(ctx, cfg.Admin.Listen, servers, lanes, ac, cfg.Analyzer, log)
+		auth, aerr := buildAdminAuth(cfg.Admin)
+		if aerr != nil {
+			return aerr
+		}
+		go serveAdmin(ctx, cfg.Admin.Listen, auth, servers, rt, ac, log)
 	}
 
 	var wg sync.WaitGroup
@@ -374,6 +402,45 @@ func Run(cfg *Config, det Plugin) error {
 	return nil
 }
 
+// logLane writes one line per lane naming what it enforces. The config file
+// does not show what a lane inherited, so this logs the RESOLVED stack: it
+// turns "why did this not deny" into a minute of reading instead of an
+// afternoon. A reload logs it again, for the same reason.
+func logLane(log *slog.Logger, ln lane) {
+	log.Info("lane ready",
+		"listener", ln.name,
+		"protocol", ln.cfg.Protocol,
+		"upstream", ln.cfg.Upstream,
+		"enforcing", ln.policy != nil,
+		"rules", len(ln.rules),
+		"opa", ln.opaURL,
+		"masking", ln.masker != nil)
+	if ln.policy == nil {
+		log.Warn("lane is observe-only; no statement will be denied",
+			"listener", ln.name, "hint", "set policy.enforce=true on this listener or at the top level")
+	}
+}
+
+// reloadOnHangup reloads the config on every SIGHUP until ctx ends. A
+// refused reload is logged and otherwise ignored: the process keeps serving
+// the revision it had.
+func reloadOnHangup(ctx context.Context, rt *runtime) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if rev, err := rt.Reload(); err != nil {
+				rt.log.Error("config reload refused; the running config is unchanged",
+					"revision", rev.Number, "error", err)
+			}
+		}
+	}
+}
+
 func (l ListenerConfig) displayName(i int) string {
 	switch {
 	case l.Name != "":
@@ -396,6 +463,15 @@ type lane struct {
 	policy policy.Evaluator
 	masker gate.Masker
 
+	// approver answers for the statements a holding rule parks, nil on a
+	// lane without policy.approval.
+	approver policy.Approver
+
+	// limits are the lane's resolved result limits, and observeLimits
+	// whether they only record, which is the lane's observe-only mode.
+	limits        []policy.ResultLimit
+	observeLimits bool
+
 	// codecFactory overrides the registry for this lane. Nil means the
 	// registry default, which is every lane that did not configure capture.
 	codecFactory func() hoopinspect.Codec
@@ -416,6 +492,17 @@ type lane struct {
 	captureBody bool
 }
 
+// stack is the part of the lane a reload can replace on a running server.
+func (ln lane) stack() gate.Stack {
+	return gate.Stack{
+		Policy:        ln.policy,
+		Masker:        ln.masker,
+		Limits:        ln.limits,
+		ObserveLimits: ln.observeLimits,
+		Approver:      ln.approver,
+	}
+}
+
 // buildLanes resolves and builds every listener's stack.
 //
 // Exhaustive rather than fail-fast, matching Validate: a config with three
@@ -447,14 +534,34 @@ func buildLanes(cfg *Config, det Plugin, ac *analyzerDeps) ([]lane, error) {
 			continue
 		}
 
+		var approver policy.Approver
+		if pc.Approval != nil {
+			a, err := pc.Approval.approver()
+			if err != nil {
+				problems = append(problems, name+": policy.approval: "+err.Error())
+				continue
+			}
+			approver = a
+		}
+
 		proto := hoopinspect.Protocol(lc.Protocol)
+		factory := httpCodecFactory(proto, lc.HTTP)
+		if proto == hoopinspect.GRPC {
+			if factory, err = grpcCodecFactory(proto, lc.GRPC); err != nil {
+				problems = append(problems, name+": "+err.Error())
+				continue
+			}
+		}
 		ln := lane{
-			cfg:          lc,
-			name:         name,
-			policy:       pol,
-			masker:       masker,
-			codecFactory: httpCodecFactory(proto, lc.HTTP),
-			captureBody:  lc.HTTP != nil && lc.HTTP.CaptureBody,
+			cfg:           lc,
+			name:          name,
+			policy:        pol,
+			masker:        masker,
+			approver:      approver,
+			limits:        pc.Limits,
+			observeLimits: !pc.enforcing(),
+			codecFactory:  factory,
+			captureBody:   lc.HTTP != nil && lc.HTTP.CaptureBody,
 		}
 		if pol != nil {
 			ln.rules = make([]string, 0, len(pc.Rules))
@@ -516,9 +623,12 @@ func checkPIIEntities(pc PolicyConfig, det Plugin) []string {
 	return problems
 }
 
-// buildServer turns one resolved lane into a running-capable Server.
+// buildServer turns one resolved lane into a running-capable Server. The
+// server reads its policy, masks and limits from live, which starts out
+// holding ln's and is where a reload stores their replacements.
 func buildServer(
 	ln lane,
+	live *gate.Live,
 	ac AuditConfig,
 	sink audit.Sink,
 	log *slog.Logger,
@@ -549,6 +659,21 @@ func buildServer(
 	if lc.IdentityHeader != "" {
 		identityFn = headerIdentity(lc.IdentityHeader)
 	}
+	if lc.ProxyProtocol != nil {
+		identityFn = proxyIdentity(*lc.ProxyProtocol)
+	}
+	var certFn func(*x509.Certificate) session.Identity
+	if lc.DownstreamTLS != nil && lc.DownstreamTLS.ClientSubject != "" {
+		certFn = certIdentity(lc.DownstreamTLS.ClientSubject)
+	}
+	var broker func(string, []byte) (proxy.Login, error)
+	if lc.Broker != nil {
+		if broker, err = buildBroker(*lc.Broker); err != nil {
+			return nil, fmt.Errorf("%s: %w", ln.name, err)
+		}
+		log.Info("brokering postgres logins on this lane",
+			"listener", ln.name, "upstream_user", lc.Broker.User, "clients", len(lc.Broker.Clients))
+	}
 
 	return proxy.NewServer(proxy.Config{
 		Listen:           lc.Listen,
@@ -558,12 +683,14 @@ func buildServer(
 		DownstreamTLS:    downstreamTLS,
 		Protocol:         hoopinspect.Protocol(lc.Protocol),
 		Connection:       lc.Connection,
-		Policy:           ln.policy,
-		Audit:            sink,
-		Masker:           ln.masker,
+		Live:             live,
+		Audit:            metrics.laneSink(ln.name, sink),
 		FailOnAuditError: ac.FailClosed,
 		DenyWriter:       proxy.ProtocolDenyWriter{},
 		IdentityFn:       identityFn,
+		ProxyProtocol:    lc.ProxyProtocol != nil,
+		CertIdentity:     certFn,
+		Broker:           broker,
 		CodecFactory:     ln.codecFactory,
 		IdleTimeout:      time.Duration(lc.IdleTimeoutSec) * time.Second,
 		MaxConns:         lc.MaxConns,
@@ -623,6 +750,7 @@ type auditChain struct {
 	sink  audit.Sink
 	mem   *audit.MemorySink
 	query *store.MemoryStore
+	async *audit.AsyncSink
 }
 
 // buildAudit assembles the sink chain.
@@ -638,6 +766,13 @@ func buildAudit(cfg AuditConfig) (auditChain, error) {
 	var out auditChain
 	var sinks []audit.Sink
 
+	// Built before the file is opened, so a bad collector config does not
+	// leave it open behind the error.
+	remote, err := buildRemoteSinks(cfg, opts)
+	if err != nil {
+		return out, err
+	}
+
 	switch cfg.File {
 	case "", "-":
 		// No file configured: record to stdout rather than discard. A
@@ -645,12 +780,23 @@ func buildAudit(cfg AuditConfig) (auditChain, error) {
 		// /dev/null.
 		sinks = append(sinks, audit.NewJSONLSink(os.Stdout, opts))
 	default:
+		// The chain is read from the file before it is opened for append,
+		// so it resumes after the last record this file already holds.
+		fileOpts := opts
+		if cfg.HashChain != nil {
+			c, err := buildHashChain(cfg.HashChain, cfg.File)
+			if err != nil {
+				return out, err
+			}
+			fileOpts.Chain = c
+		}
 		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
 		if err != nil {
 			return out, fmt.Errorf("open audit file: %w", err)
 		}
-		sinks = append(sinks, audit.NewJSONLSink(f, opts))
+		sinks = append(sinks, audit.NewJSONLSink(f, fileOpts))
 	}
+	sinks = append(sinks, remote...)
 
 	if cfg.MemoryBuffer > 0 {
 		out.mem = audit.NewMemorySink(cfg.MemoryBuffer)
@@ -666,7 +812,8 @@ func buildAudit(cfg AuditConfig) (auditChain, error) {
 
 	out.sink = audit.NewMultiSink(sinks...)
 	if cfg.AsyncQueueSize > 0 {
-		out.sink = audit.NewAsyncSink(out.sink, cfg.AsyncQueueSize)
+		out.async = audit.NewAsyncSink(out.sink, cfg.AsyncQueueSize)
+		out.sink = out.async
 	}
 	return out, nil
 }
@@ -674,14 +821,16 @@ func buildAudit(cfg AuditConfig) (auditChain, error) {
 // serveAdmin exposes health and stats.
 //
 // It binds separately from the data path so a platform can scrape it without
-// reaching the proxy, and vice versa.
+// reaching the proxy, and vice versa. Handlers read the lanes from rt on
+// every request, so they describe the revision in force after a reload.
+// auth stands in front of every handler; see AdminConfig for the scopes.
 func serveAdmin(
 	ctx context.Context,
 	addr string,
+	auth *adminAuth,
 	servers []*proxy.Server,
-	lanes []lane,
+	rt *runtime,
 	ac auditChain,
-	analyzerCfg *AnalyzerConfig,
 	log *slog.Logger,
 ) {
 	mux := http.NewServeMux()
@@ -699,6 +848,7 @@ func serveAdmin(
 			Total  int64  `json:"total"`
 			Denied int64  `json:"denied"`
 		}
+		lanes, _, _ := rt.snapshot()
 		out := make([]stat, 0, len(servers))
 		for i, s := range servers {
 			active, total, denied := s.Stats()
@@ -718,9 +868,21 @@ func serveAdmin(
 		_ = json.NewEncoder(w).Encode(map[string]any{
 			"version":   Version,
 			"listeners": out,
+			// Per rule and lane, so a denial count climbing on one rule
+			// says which allowance is too tight, or which caller is
+			// running away.
+			"rates": rt.rates.Stats(),
 		})
 	})
 
+	// The same counters as /stats and more, in the format a Prometheus
+	// scrape reads; see metrics.
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		lanes, _, _ := rt.snapshot()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, metrics, scrapeGauges(servers, lanes, ac))
+	})
+
 	// The resolved enforcement stack, per lane.
 	//
 	// The config file does not show inheritance: a lane's rules are its own
@@ -749,7 +911,13 @@ func serveAdmin(
 			// and cannot answer from the config file alone.
 			AIRules     []string `json:"ai_rules,omitempty"`
 			CaptureBody bool     `json:"capture_body,omitempty"`
+
+			// Limits names the result limits on this lane, in the order
+			// a crossing is checked.
+			Limits []string `json:"limits,omitempty"`
 		}
+		lanes, cfg, rev := rt.snapshot()
+		analyzerCfg := cfg.Analyzer
 		out := make([]laneView, 0, len(lanes))
 		for _, ln := range lanes {
 			v := laneView{
@@ -764,6 +932,9 @@ func serveAdmin(
 				AIRules:     ln.analyzed,
 				CaptureBody: ln.captureBody,
 			}
+			for _, l := range ln.limits {
+				v.Limits = append(v.Limits, l.Name)
+			}
 			if v.Rules == nil {
 				v.Rules = []string{} // render [] rather than null
 			}
@@ -773,8 +944,9 @@ func serveAdmin(
 			out = append(out, v)
 		}
 		resp := map[string]any{
-			"version": Version,
-			"lanes":   out,
+			"version":  Version,
+			"revision": rev,
+			"lanes":    out,
 		}
 		// The analyzer view names the provider, the model and the HOST it
 		// talks to — never the path, never a query string, and never the
@@ -799,6 +971,31 @@ func serveAdmin(
 		_ = json.NewEncoder(w).Encode(resp)
 	})
 
+	// Reload is a POST so that a crawler or a browser prefetch following
+	// links on the admin port cannot trigger one. The response carries the
+	// revision in force either way: the new one on success, and on refusal
+	// the one still running, so a deploy script can tell the two apart
+	// without a second request.
+	mux.HandleFunc("POST /config/reload", func(w http.ResponseWriter, r *http.Request) {
+		rev, err := rt.Reload()
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			log.Error("config reload refused; the running config is unchanged",
+				"revision", rev.Number, "caller", callerOf(r), "error", err)
+			status := http.StatusUnprocessableEntity
+			if errors.Is(err, errReloadUnavailable) {
+				status = http.StatusNotImplemented
+			}
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"error":    err.Error(),
+				"revision": rev,
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"revision": rev})
+	})
+
 	if ac.mem != nil {
 		// The recent-events endpoint is a debugging aid. The ring drops old
 		// events, so a compliance reader gets an incomplete record.
@@ -830,7 +1027,8 @@ func serveAdmin(
 
 	srv := &http.Server{
 		Addr:              addr,
-		Handler:           mux,
+		Handler:           auth.wrap(mux, log),
+		TLSConfig:         auth.tls,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 	go func() {
@@ -840,8 +1038,20 @@ func serveAdmin(
 		_ = srv.Shutdown(shutCtx)
 	}()
 
-	log.Info("admin endpoint listening", "listen", addr)
-	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	log.Info("admin endpoint listening", "listen", addr,
+		"tls", auth.tls != nil, "authenticated", auth.enabled)
+	if !auth.enabled && !loopback(addr) {
+		// /events and /api serve every statement every user ran.
+		log.Warn("admin endpoint is unauthenticated and not on loopback; "+
+			"anyone who can reach it can read the audit trail", "listen", addr)
+	}
+	var err error
+	if auth.tls != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Error("admin endpoint failed", "error", err)
 	}
 }
@@ -0,0 +1,342 @@
+package sidecar
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/hoophq/hoopinspect"
+	"github.com/hoophq/hoopinspect/gate"
+	"github.com/hoophq/hoopinspect/session"
+)
+
+// Expected verdicts a TestCase may name.
+const (
+	ExpectAllow = "allow"
+	ExpectDeny  = "deny"
+)
+
+// TestCase is one policy regression test on a listener: something a client
+// could send, and the verdict the lane must reach on it.
+//
+// A rule with no test is a rule nobody has seen fire. Validation proves a
+// regex compiles and an entity name resolves; it cannot prove that the rule
+// written to stop `DELETE FROM users` matches what the codec makes of
+// `delete from "Users"`, and the first place that gap shows is production.
+// A case is run through the lane's own codec, so it tests the statement the
+// relay would see rather than a hand-built approximation of it.
+type TestCase struct {
+	// Name identifies the case in the report. Defaults to its position.
+	Name string `json:"name"`
+
+	// Statement is SQL text, on a postgres, mysql or mssql lane. It is
+	// framed as the protocol's plain query message, so several statements
+	// separated by semicolons are one read, as they are on the wire.
+	Statement string `json:"statement,omitempty"`
+
+	// Request is an HTTP request, on an http lane.
+	Request *TestRequest `json:"request,omitempty"`
+
+	// User is the session's authenticated subject, for rules and OPA
+	// policies that read the principal. Empty means an anonymous session.
+	User string `json:"user,omitempty"`
+
+	// Expect is "allow" or "deny".
+	Expect string `json:"expect"`
+
+	// Rule, with expect deny, is the rule that must be the one denying. A
+	// denial by some other rule fails the case: the statement is stopped
+	// today, but by a rule that was not written for it and may be removed
+	// for reasons that have nothing to do with it.
+	Rule string `json:"rule,omitempty"`
+}
+
+// TestRequest is the HTTP request a TestCase sends.
+type TestRequest struct {
+	// Method defaults to GET.
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// displayName is the case's name, or its position when it has none.
+func (tc TestCase) displayName(i int) string {
+	if tc.Name != "" {
+		return tc.Name
+	}
+	return fmt.Sprintf("test %d", i+1)
+}
+
+// validateTests checks one listener's test cases.
+func validateTests(lc ListenerConfig, name string) []string {
+	var problems []string
+	proto := hoopinspect.Protocol(lc.Protocol)
+	if len(lc.Tests) > 0 && proto == hoopinspect.MongoDB {
+		// A MongoDB statement is a BSON command with no text form a case
+		// could be written in; the rendering the codec puts in Text is for
+		// reading, not for parsing back.
+		return []string{name + ": tests are not supported on mongodb listeners"}
+	}
+	if len(lc.Tests) > 0 && proto == hoopinspect.GRPC {
+		// A gRPC call is HTTP/2 frames around a binary message, neither of
+		// which a case has a form to write in.
+		return []string{name + ": tests are not supported on grpc listeners"}
+	}
+	for i, tc := range lc.Tests {
+		where := fmt.Sprintf("%s: test %q", name, tc.displayName(i))
+		switch tc.Expect {
+		case ExpectAllow, ExpectDeny:
+		case "":
+			problems = append(problems, where+": no expect (allow or deny)")
+		default:
+			problems = append(problems, fmt.Sprintf("%s: expect must be allow or deny, got %q", where, tc.Expect))
+		}
+		if tc.Rule != "" && tc.Expect == ExpectAllow {
+			problems = append(problems, where+": rule names the denying rule, and the case expects allow")
+		}
+
+		switch {
+		case proto == hoopinspect.HTTP && tc.Request == nil:
+			problems = append(problems, where+": an http listener's test needs a request")
+		case proto == hoopinspect.HTTP && tc.Statement != "":
+			problems = append(problems, where+": an http listener's test takes a request, not a statement")
+		case proto != hoopinspect.HTTP && tc.Request != nil:
+			problems = append(problems, fmt.Sprintf("%s: request is only valid on an http listener, not %s", where, lc.Protocol))
+		case proto != hoopinspect.HTTP && strings.TrimSpace(tc.Statement) == "":
+			problems = append(problems, where+": no statement")
+		}
+		if tc.Request != nil && !strings.HasPrefix(tc.Request.Path, "/") {
+			problems = append(problems, fmt.Sprintf("%s: request.path must start with /, got %q", where, tc.Request.Path))
+		}
+	}
+	return problems
+}
+
+// TestResult is one case's outcome.
+type TestResult struct {
+	Listener string
+	Name     string
+
+	// Want and Got render the verdicts: "allow", "deny", or "deny (rule)".
+	Want, Got string
+
+	Passed bool
+}
+
+// ErrTestsFailed marks a test run in which a case did not reach its verdict.
+var ErrTestsFailed = errors.New("policy tests failed")
+
+// Test builds every lane and runs its test cases through it.
+//
+// The lanes are built exactly as Validate builds them, so a config whose
+// tests pass is one that would start. The error is for a config that
+// cannot be built; a failing case is a result, not an error, so a caller
+// can report all of them.
+//
+// Cases run in order and share their lane, which matters only for a rate
+// rule: its allowance is spent across the lane's cases as it would be
+// across one principal's statements.
+func Test(cfg *Config, det Plugin) ([]TestResult, error) {
+	if err := checkPIIPlugin(cfg, det); err != nil {
+		return nil, err
+	}
+	ac, err := setupAnalyzer(cfg, det)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyAnalyzer(ac); err != nil {
+		return nil, err
+	}
+	lanes, err := buildLanes(cfg, det, ac)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []TestResult
+	for _, ln := range lanes {
+		for i, tc := range ln.cfg.Tests {
+			want := tc.Expect
+			if tc.Rule != "" {
+				want = fmt.Sprintf("%s (%s)", ExpectDeny, tc.Rule)
+			}
+			got, passed := runCase(ln, tc)
+			out = append(out, TestResult{
+				Listener: ln.name,
+				Name:     tc.displayName(i),
+				Want:     want,
+				Got:      got,
+				Passed:   passed,
+			})
+		}
+	}
+	return out, nil
+}
+
+// ReportTests writes one line per result and a count, and returns
+// ErrTestsFailed when any case failed.
+func ReportTests(w io.Writer, results []TestResult) error {
+	failed := 0
+	for _, r := range results {
+		if r.Passed {
+			fmt.Fprintf(w, "  PASS  %-16s %s\n", r.Listener, r.Name)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "  FAIL  %-16s %s: want %s, got %s\n", r.Listener, r.Name, r.Want, r.Got)
+	}
+	fmt.Fprintf(w, "%d test(s), %d failed\n", len(results), failed)
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d", ErrTestsFailed, failed, len(results))
+	}
+	return nil
+}
+
+// runCase sends one case through a gate built from the lane, the way the
+// relay builds one per connection, and compares the decision.
+func runCase(ln lane, tc TestCase) (got string, passed bool) {
+	proto := hoopinspect.Protocol(ln.cfg.Protocol)
+	sess := session.New(proto, session.Identity{Subject: tc.User})
+	sess.Connection = ln.cfg.Connection
+
+	st := ln.stack()
+	g, err := gate.New(sess, gate.Config{
+		Protocol:      proto,
+		Policy:        st.Policy,
+		Masker:        st.Masker,
+		Limits:        st.Limits,
+		ObserveLimits: st.ObserveLimits,
+		CodecFactory:  ln.codecFactory,
+	})
+	if err != nil {
+		return "error: " + err.Error(), false
+	}
+	ctx := context.Background()
+	defer g.Close(ctx)
+
+	d := g.Request(ctx, caseWire(proto, tc))
+	switch {
+	case len(d.Statements) == 0 && d.Err != nil:
+		return "error: " + d.Err.Error(), false
+	case len(d.Statements) == 0:
+		return "no statement (the codec read nothing from the case)", false
+	case d.Allowed:
+		got = ExpectAllow
+		if tc.Expect == ExpectDeny && st.Policy == nil {
+			got += " (the listener is observe-only)"
+		}
+		return got, tc.Expect == ExpectAllow
+	}
+	got = fmt.Sprintf("%s (%s)", ExpectDeny, d.Rule)
+	return got, tc.Expect == ExpectDeny && (tc.Rule == "" || tc.Rule == d.Rule)
+}
+
+// caseWire frames a case as the first message a client would send for it.
+func caseWire(proto hoopinspect.Protocol, tc TestCase) []byte {
+	switch proto {
+	case hoopinspect.Postgres:
+		// Simple query: 'Q', int32 length including itself, text, NUL.
+		b := []byte{'Q'}
+		b = binary.BigEndian.AppendUint32(b, uint32(len(tc.Statement)+5))
+		return append(append(b, tc.Statement...), 0)
+	case hoopinspect.MySQL:
+		return mysqlQuery(tc.Statement)
+	case hoopinspect.MSSQL:
+		return mssqlBatch(tc.Statement)
+	case hoopinspect.HTTP:
+		return httpRequest(tc.Request)
+	}
+	return nil
+}
+
+// mysqlMaxPayload is the largest payload one MySQL packet carries; a longer
+// command continues in the next packet.
+const mysqlMaxPayload = 1<<24 - 1
+
+// mysqlQuery frames a COM_QUERY: int<3> length, int<1> sequence id, then
+// the command byte and text.
+func mysqlQuery(text string) []byte {
+	payload := append([]byte{0x03}, text...)
+	var out []byte
+	for seq := byte(0); ; seq++ {
+		n := min(len(payload), mysqlMaxPayload)
+		out = append(out, byte(n), byte(n>>8), byte(n>>16), seq)
+		out = append(out, payload[:n]...)
+		payload = payload[n:]
+		// A payload of exactly the maximum is followed by an empty packet,
+		// so the reader knows it ended.
+		if n < mysqlMaxPayload {
+			return out
+		}
+	}
+}
+
+// mssqlPacketPayload bounds one TDS packet's payload, well under the
+// default negotiated packet size.
+const mssqlPacketPayload = 4096 - 8
+
+// mssqlBatch frames a SQLBatch: the ALL_HEADERS block with its one required
+// transaction descriptor, then the text as UCS-2LE, split into packets.
+func mssqlBatch(text string) []byte {
+	body := binary.LittleEndian.AppendUint32(nil, 22) // ALL_HEADERS total length
+	body = binary.LittleEndian.AppendUint32(body, 18) // header length
+	body = binary.LittleEndian.AppendUint16(body, 2)  // transaction descriptor
+	body = append(body, make([]byte, 8)...)           // no open transaction
+	body = binary.LittleEndian.AppendUint32(body, 1)  // outstanding requests
+	for _, u := range utf16.Encode([]rune(text)) {
+		body = binary.LittleEndian.AppendUint16(body, u)
+	}
+
+	var out []byte
+	for id := byte(1); ; id++ {
+		n := min(len(body), mssqlPacketPayload)
+		status := byte(0)
+		if n == len(body) {
+			status = 0x01 // end of message
+		}
+		out = append(out, 0x01, status)
+		out = binary.BigEndian.AppendUint16(out, uint16(n+8))
+		out = append(out, 0, 0, id, 0)
+		out = append(out, body[:n]...)
+		body = body[n:]
+		if status == 0x01 {
+			return out
+		}
+	}
+}
+
+// httpRequest renders an HTTP/1.1 request. Host and Content-Length are set
+// unless the case sets them.
+func httpRequest(r *TestRequest) []byte {
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = "GET"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", method, r.Path)
+
+	has := map[string]bool{}
+	keys := make([]string, 0, len(r.Headers))
+	for k := range r.Headers {
+		keys = append(keys, k)
+		has[strings.ToLower(k)] = true
+	}
+	sort.Strings(keys)
+	if !has["host"] {
+		b.WriteString("Host: policy-test\r\n")
+	}
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, r.Headers[k])
+	}
+	if !has["content-length"] && (r.Body != "" || method == "POST" || method == "PUT" || method == "PATCH") {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n", len(r.Body))
+	}
+	b.WriteString("\r\n")
+	b.WriteString(r.Body)
+	return []byte(b.String())
+}
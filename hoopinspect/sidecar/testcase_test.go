@@ -0,0 +1,118 @@
+package sidecar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func mustLoad(t *testing.T, body string) *Config {
+	t.Helper()
+	cfg, err := LoadConfigBytes([]byte(body))
+	if err != nil {
+		t.Fatalf("LoadConfigBytes: %v", err)
+	}
+	return cfg
+}
+
+// A case on each text protocol is framed the way that protocol's client
+// frames it and reaches the rule through the real codec.
+func TestCasesRunThroughEachCodec(t *testing.T) {
+	cfg := mustLoad(t, `{
+  "policy": {"enforce": true, "rules": [
+    {"name": "no-drop", "type": "operation", "operations": ["drop"]},
+    {"name": "no-admin", "type": "http_resource", "resources": ["/admin/**"]}
+  ]},
+  "listeners": [
+    {"name": "pg", "protocol": "postgres", "listen": "127.0.0.1:1", "upstream": "db:5432", "tests": [
+      {"name": "drops", "statement": "DROP TABLE users", "expect": "deny", "rule": "no-drop"},
+      {"name": "batched drop", "statement": "SELECT 1; drop table \"Users\"", "expect": "deny"},
+      {"name": "reads", "statement": "SELECT * FROM users", "expect": "allow"}
+    ]},
+    {"name": "my", "protocol": "mysql", "listen": "127.0.0.1:2", "upstream": "db:3306", "tests": [
+      {"statement": "DROP TABLE users", "expect": "deny", "rule": "no-drop"},
+      {"statement": "SELECT 1", "expect": "allow"}
+    ]},
+    {"name": "ms", "protocol": "mssql", "listen": "127.0.0.1:3", "upstream": "db:1433", "tests": [
+      {"statement": "DROP TABLE users", "expect": "deny", "rule": "no-drop"},
+      {"statement": "SELECT 1", "expect": "allow"}
+    ]},
+    {"name": "api", "protocol": "http", "listen": "127.0.0.1:4", "upstream": "api:80", "tests": [
+      {"request": {"method": "delete", "path": "/admin/users/1"}, "expect": "deny", "rule": "no-admin"},
+      {"request": {"method": "POST", "path": "/orders", "body": "{}"}, "expect": "allow"}
+    ]}
+  ]
+}`)
+	results, err := Test(cfg, nil)
+	if err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+	if len(results) != 9 {
+		t.Fatalf("results = %d, want 9", len(results))
+	}
+	for _, r := range results {
+		if !r.Passed {
+			t.Errorf("%s %s: want %s, got %s", r.Listener, r.Name, r.Want, r.Got)
+		}
+	}
+}
+
+// A case fails when another rule does the denying, and when the lane is
+// observe-only, and the report says why.
+func TestFailingCasesAreReported(t *testing.T) {
+	cfg := mustLoad(t, `{
+  "listeners": [
+    {"name": "strict", "protocol": "postgres", "listen": "127.0.0.1:1", "upstream": "db:5432",
+     "policy": {"enforce": true, "rules": [{"name": "no-writes", "type": "operation", "operations": ["drop", "delete"]}]},
+     "tests": [{"name": "drops", "statement": "DROP TABLE t", "expect": "deny", "rule": "no-drop"}]},
+    {"name": "watching", "protocol": "postgres", "listen": "127.0.0.1:2", "upstream": "db:5432",
+     "tests": [{"name": "drops", "statement": "DROP TABLE t", "expect": "deny"}]}
+  ]
+}`)
+	results, err := Test(cfg, nil)
+	if err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+	var out strings.Builder
+	err = ReportTests(&out, results)
+	if !errors.Is(err, ErrTestsFailed) {
+		t.Fatalf("err = %v, want ErrTestsFailed", err)
+	}
+	for _, want := range []string{
+		"FAIL  strict           drops: want deny (no-drop), got deny (no-writes)",
+		"got allow (the listener is observe-only)",
+		"2 test(s), 2 failed",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestMalformedCasesFailValidation(t *testing.T) {
+	_, err := LoadConfigBytes([]byte(`{
+  "listeners": [
+    {"name": "pg", "protocol": "postgres", "listen": "127.0.0.1:1", "upstream": "db:5432", "tests": [
+      {"name": "no verdict", "statement": "SELECT 1"},
+      {"name": "rule on allow", "statement": "SELECT 1", "expect": "allow", "rule": "x"},
+      {"name": "request on sql", "request": {"path": "/"}, "expect": "allow"}
+    ]},
+    {"name": "mongo", "protocol": "mongodb", "listen": "127.0.0.1:2", "upstream": "db:27017", "tests": [
+      {"statement": "find", "expect": "allow"}
+    ]}
+  ]
+}`))
+	if err == nil {
+		t.Fatal("malformed tests loaded")
+	}
+	for _, want := range []string{
+		`test "no verdict": no expect`,
+		`test "rule on allow": rule names the denying rule`,
+		`test "request on sql": request is only valid on an http listener`,
+		"mongo: tests are not supported on mongodb listeners",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error lacks %q: %v", want, err)
+		}
+	}
+}
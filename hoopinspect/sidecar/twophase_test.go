@@ -235,6 +235,28 @@ func TestLocalDeferWithoutOPAIsRefused(t *testing.T) {
 	}
 }
 
+// A holding rule with no approval service refuses everything it matches,
+// and an approval URL that would carry statements in the clear is refused
+// like an audit collector's.
+func TestApproveWithoutApprovalURLIsRefused(t *testing.T) {
+	cfg := pgLane(denyWordsRule("drop-needs-approval", policy.ActionApprove))
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "drop-needs-approval") ||
+		!strings.Contains(err.Error(), "policy.approval.url") {
+		t.Fatalf("err = %v, want the rule and the missing approval url named", err)
+	}
+
+	cfg.Listeners[0].Policy.Approval = &ApprovalConfig{URL: "http://approvals.internal/hold"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "use https") {
+		t.Errorf("err = %v, want plain http to a remote host refused", err)
+	}
+
+	cfg.Listeners[0].Policy.Approval.URL = "https://approvals.internal/hold"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
 // An unknown action decodes into a rule that denies, so `action: warn` would
 // block what the operator meant to let through with a warning.
 func TestUnknownLocalActionIsRefused(t *testing.T) {
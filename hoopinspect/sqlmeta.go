@@ -30,6 +30,11 @@ import (
 // Metadata["sql.incomplete"]. That is deliberate and it is the fail-closed
 // path: a rule naming `unknown` refuses what the scanner could not read,
 // and one that does not name it accepts that risk explicitly.
+//
+// Columns follow the same pattern one level down. A statement whose
+// relations were read but whose columns were not all attributed carries
+// Metadata["sql.columns_incomplete"], and a column rule fails closed on it
+// unless told otherwise.
 
 // Access says whether a statement reads a relation or changes it.
 type Access string
@@ -48,12 +53,66 @@ const (
 type Relation struct {
 	Name   string `json:"name"`
 	Access Access `json:"access"`
+
+	// Columns lists the columns the statement names on this relation,
+	// lowercased. AllColumns stands for all of them: a `SELECT *`,
+	// an INSERT with no column list, a DELETE. Empty does not mean none
+	// were read when SQLAnalysis.ColumnsComplete is false.
+	Columns []ColumnRef `json:"columns,omitempty"`
+}
+
+// ColumnUse is one way a statement uses a column.
+type ColumnUse string
+
+const (
+	// ColumnProjected is a column whose value the statement returns or
+	// copies elsewhere.
+	ColumnProjected ColumnUse = "projected"
+
+	// ColumnFiltered is a column a predicate, join condition or ordering
+	// reads. It counts as a read: a WHERE clause recovers a value one
+	// comparison at a time as surely as a select list hands it over.
+	ColumnFiltered ColumnUse = "filtered"
+
+	// ColumnWritten is a column the statement changes.
+	ColumnWritten ColumnUse = "written"
+)
+
+// AllColumns is the ColumnRef name standing for every column of a relation.
+const AllColumns = "*"
+
+// ColumnRef is one column of a Relation and every way it is used.
+type ColumnRef struct {
+	Name string      `json:"name"`
+	Uses []ColumnUse `json:"uses"`
+}
+
+// Has reports whether the column is used as u.
+func (c ColumnRef) Has(u ColumnUse) bool {
+	return slices.Contains(c.Uses, u)
+}
+
+// UnboundedWrite is an UPDATE or DELETE that no predicate limits: it changes
+// every row of the relations it names.
+type UnboundedWrite struct {
+	// Operation is OpUpdate or OpDelete.
+	Operation Operation `json:"operation"`
+
+	// Relations names its targets, lowercased. Empty when the scan could
+	// not resolve them, which a rule naming tables must read as a match.
+	Relations []string `json:"relations,omitempty"`
 }
 
 // MetadataSQLIncomplete names the metadata key carrying why a scan could not
 // finish. Present only when Operation is OpUnknown for that reason.
 const MetadataSQLIncomplete = "sql.incomplete"
 
+// MetadataColumnsIncomplete names the metadata key carrying why the column
+// scan could not attribute every column, on a statement whose relations WERE
+// read. Absent when MetadataSQLIncomplete is present, which already says
+// more.
+const MetadataColumnsIncomplete = "sql.columns_incomplete"
+
 // SQLAnalysis is what one statement does.
 type SQLAnalysis struct {
 	// Operation is the most consequential effect, or OpUnknown when the
@@ -71,12 +130,28 @@ type SQLAnalysis struct {
 	// access split.
 	Tables []string
 
+	// UnboundedWrites lists each UPDATE and DELETE with no WHERE clause,
+	// or only a constant one. Empty when Complete is false, which is "no
+	// idea" rather than "none".
+	UnboundedWrites []UnboundedWrite
+
 	// Complete reports whether the scan understood the whole statement.
 	// False MUST fail closed.
 	Complete bool
 
 	// Reason names what defeated the scan. Empty when Complete.
 	Reason string
+
+	// ColumnsComplete reports whether every column reference was attributed
+	// to a relation. It is false whenever Complete is, and also for a
+	// qualifier naming nothing in scope or a statement whose column flow is
+	// not modelled, such as MERGE. A `SELECT *` leaves it true: the "*"
+	// column on the relation already says what is unknown.
+	ColumnsComplete bool
+
+	// ColumnsReason names what defeated the column scan. Empty when
+	// ColumnsComplete.
+	ColumnsReason string
 }
 
 // AnalyzeSQL classifies a statement for the dialect the protocol implies.
@@ -86,8 +161,11 @@ type SQLAnalysis struct {
 // both without mangling one of them.
 func AnalyzeSQL(sql string, proto Protocol) SQLAnalysis {
 	d := lexer.Postgres
-	if proto == MSSQL {
+	switch proto {
+	case MSSQL:
 		d = lexer.MSSQL
+	case MySQL:
+		d = lexer.MySQL
 	}
 	a := lexer.Analyze(sql, d)
 
@@ -113,6 +191,9 @@ func AnalyzeSQL(sql string, proto Protocol) SQLAnalysis {
 		Operation: operationOf(a.Severity(), copyWrites),
 		Complete:  a.Complete,
 		Reason:    a.Reason,
+
+		ColumnsComplete: a.ColumnsComplete,
+		ColumnsReason:   a.ColumnsReason,
 	}
 	if !a.Complete {
 		// The scanner met something it does not model. Reporting its
@@ -145,10 +226,53 @@ func AnalyzeSQL(sql string, proto Protocol) SQLAnalysis {
 			// rules compare against it. Changing that quietly would
 			// stop deployed table rules matching.
 			name := strings.ToLower(r.Name)
-			out.Relations = append(out.Relations, Relation{Name: name, Access: acc})
+			out.Relations = append(out.Relations, Relation{Name: name, Access: acc, Columns: columnRefs(a.Columns[r.Name])})
 			out.Tables = append(out.Tables, name)
 		}
 	}
+	if a.Complete {
+		for _, s := range a.Unfiltered() {
+			w := UnboundedWrite{Operation: operationOf(s.Verb, false)}
+			for _, t := range s.Targets {
+				w.Relations = append(w.Relations, strings.ToLower(t))
+			}
+			out.UnboundedWrites = append(out.UnboundedWrites, w)
+		}
+	}
+	return out
+}
+
+// columnRefs converts the scanner's columns, lowercasing them for the same
+// reason relation names are. `SELECT "SSN"` and `SELECT ssn` are different
+// columns in PostgreSQL, but a rule naming ssn must not be sidestepped by
+// quoting it, and a false match is a denial rather than a leak.
+func columnRefs(cols []lexer.Column) []ColumnRef {
+	if len(cols) == 0 {
+		return nil
+	}
+	out := make([]ColumnRef, 0, len(cols))
+	index := map[string]int{}
+	for _, c := range cols {
+		name := strings.ToLower(c.Name)
+		i, seen := index[name]
+		if !seen {
+			i = len(out)
+			index[name] = i
+			out = append(out, ColumnRef{Name: name})
+		}
+		for _, u := range []struct {
+			bit lexer.ColumnUse
+			use ColumnUse
+		}{
+			{lexer.Projected, ColumnProjected},
+			{lexer.Filtered, ColumnFiltered},
+			{lexer.Written, ColumnWritten},
+		} {
+			if c.Use&u.bit != 0 && !out[i].Has(u.use) {
+				out[i].Uses = append(out[i].Uses, u.use)
+			}
+		}
+	}
 	return out
 }
 
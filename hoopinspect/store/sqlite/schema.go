@@ -9,7 +9,7 @@ import (
 // schemaVersion is the version this build writes. It gives a future
 // migration somewhere to stand: an older binary opening a newer file can
 // refuse rather than misread columns it does not know about.
-const schemaVersion = 1
+const schemaVersion = 2
 
 // schemaDDL is applied at every open. Every statement is idempotent, so
 // opening an existing database is a no-op rather than a migration.
@@ -73,7 +73,15 @@ CREATE TABLE IF NOT EXISTS events (
 	statement_count INTEGER NOT NULL DEFAULT 0,
 	denied_count    INTEGER NOT NULL DEFAULT 0,
 	http            TEXT NOT NULL DEFAULT '',
-	metadata        TEXT NOT NULL DEFAULT ''
+	metadata        TEXT NOT NULL DEFAULT '',
+	-- The audit.Chain link, set when the store seals (Store.SetChain). The
+	-- chain's own sequence, not the rowid: a rowid is reassigned by nobody,
+	-- but it is also checked by nobody, and the chain is the record a
+	-- verifier trusts.
+	chain_seq       INTEGER NOT NULL DEFAULT 0,
+	prev_hash       TEXT NOT NULL DEFAULT '',
+	hash            TEXT NOT NULL DEFAULT '',
+	checkpoint      TEXT NOT NULL DEFAULT ''
 );
 
 -- Every index below backs a field SessionFilter or EventFilter narrows on, or
@@ -132,6 +140,17 @@ CREATE INDEX IF NOT EXISTS idx_events_operation ON events(operation) WHERE opera
 CREATE INDEX IF NOT EXISTS idx_events_rule ON events(rule) WHERE rule <> '';
 `
 
+// migrations upgrade a database written at version i+1 to version i+2. The
+// DDL above already creates the current shape, so they only ever run on a
+// file an older build created.
+var migrations = []string{
+	// 1 -> 2: the hash chain columns.
+	`ALTER TABLE events ADD COLUMN chain_seq INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE events ADD COLUMN prev_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN checkpoint TEXT NOT NULL DEFAULT '';`,
+}
+
 // applySchema creates the tables and records the version. Safe to call on an
 // existing database.
 func applySchema(ctx context.Context, db *sql.DB) error {
@@ -149,6 +168,26 @@ func applySchema(ctx context.Context, db *sql.DB) error {
 		if _, err := db.ExecContext(ctx, `INSERT INTO schema_version(version) VALUES (?)`, schemaVersion); err != nil {
 			return fmt.Errorf("hoopinspect/store/sqlite: record schema version: %w", err)
 		}
+	case found < schemaVersion:
+		// One transaction, so a failed step leaves the file at the version
+		// it claims. Rows written before the upgrade keep empty chain
+		// columns, which a verifier reads as history from before the chain.
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("hoopinspect/store/sqlite: begin migration: %w", err)
+		}
+		defer tx.Rollback()
+		for v := found; v < schemaVersion; v++ {
+			if _, err := tx.ExecContext(ctx, migrations[v-1]); err != nil {
+				return fmt.Errorf("hoopinspect/store/sqlite: migrate schema %d to %d: %w", v, v+1, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_version(version) VALUES (?)`, schemaVersion); err != nil {
+			return fmt.Errorf("hoopinspect/store/sqlite: record schema version: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("hoopinspect/store/sqlite: commit migration: %w", err)
+		}
 	case found > schemaVersion:
 		// Refuse rather than misread. A newer writer may have repurposed a
 		// column; an error at open beats serving wrong audit data.
@@ -16,6 +16,7 @@ package sqlite
 
 import (
 	"context"
+	"crypto/ed25519"
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
@@ -57,6 +58,9 @@ type Store struct {
 	// data path where a blocked write blocks the user's query.
 	writeMu sync.Mutex
 
+	// chain, when set, seals every event under writeMu; see SetChain.
+	chain *audit.Chain
+
 	closeOnce sync.Once
 	closeErr  error
 }
@@ -188,6 +192,12 @@ func (s *Store) Write(ctx context.Context, ev audit.Event) error {
 	s.writeMu.Lock()
 	defer s.writeMu.Unlock()
 
+	// Sealed under writeMu, so chain order is insert order, and advanced
+	// only after the commit, so a rolled-back write leaves no gap.
+	if s.chain != nil {
+		ev = s.chain.Seal(ev)
+	}
+
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("hoopinspect/store/sqlite: begin: %w", err)
@@ -203,22 +213,77 @@ func (s *Store) Write(ctx context.Context, ev audit.Event) error {
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("hoopinspect/store/sqlite: commit: %w", err)
 	}
+	if s.chain != nil {
+		s.chain.Advance(ev)
+	}
+	return nil
+}
+
+// SetChain makes the store seal every event it writes into c, resuming after
+// the last chained row already stored. Call it before the first Write.
+//
+// The sessions table is not chained. It is derived from the events, and a
+// verifier that trusts the events can rebuild it.
+func (s *Store) SetChain(ctx context.Context, c *audit.Chain) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var (
+		seq  int64
+		hash string
+	)
+	err := s.db.QueryRowContext(ctx,
+		`SELECT chain_seq, hash FROM events WHERE hash <> '' ORDER BY seq DESC LIMIT 1`).Scan(&seq, &hash)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return fmt.Errorf("hoopinspect/store/sqlite: read chain tail: %w", err)
+	default:
+		c.Resume(uint64(seq), hash)
+	}
+	s.chain = c
 	return nil
 }
 
+// VerifyChain checks every stored event, in insertion order, against the
+// hash chain; see audit.Verifier. pub checks the signed checkpoints and may
+// be nil.
+func (s *Store) VerifyChain(ctx context.Context, pub ed25519.PublicKey) (audit.ChainReport, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+eventColumns+` FROM events ORDER BY seq ASC`)
+	if err != nil {
+		return audit.ChainReport{}, fmt.Errorf("hoopinspect/store/sqlite: query events: %w", err)
+	}
+	defer rows.Close()
+
+	v := audit.NewVerifier(pub)
+	for rows.Next() {
+		rec, err := scanEvent(rows)
+		if err != nil {
+			return audit.ChainReport{}, err
+		}
+		v.Add(rec.Event)
+	}
+	if err := rows.Err(); err != nil {
+		return audit.ChainReport{}, fmt.Errorf("hoopinspect/store/sqlite: scan events: %w", err)
+	}
+	return v.Report(), nil
+}
+
 func insertEvent(ctx context.Context, tx *sql.Tx, ev audit.Event) error {
 	_, err := tx.ExecContext(ctx, `
 INSERT INTO events (
 	session_id, kind, timestamp, principal, protocol, connection, operation,
 	statement, tables, allowed, rule, message, direction, masked_entities,
-	masked_count, error, duration_ns, statement_count, denied_count, http, metadata
-) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+	masked_count, error, duration_ns, statement_count, denied_count, http, metadata,
+	chain_seq, prev_hash, hash, checkpoint
+) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
 		string(ev.SessionID), string(ev.Kind), micros(ev.Timestamp), ev.Principal,
 		string(ev.Protocol), ev.Connection, string(ev.Operation), ev.Statement,
 		encodeJSON(ev.Tables), boolInt(ev.Allowed), ev.Rule, ev.Message,
 		string(ev.Direction), encodeJSON(ev.MaskedEntities), ev.MaskedCount,
 		ev.Error, int64(ev.Duration), ev.StatementCount, ev.DeniedCount,
-		encodeJSON(ev.HTTP), encodeJSON(ev.Metadata))
+		encodeJSON(ev.HTTP), encodeJSON(ev.Metadata),
+		int64(ev.ChainSeq), ev.PrevHash, ev.Hash, ev.Checkpoint)
 	if err != nil {
 		return fmt.Errorf("hoopinspect/store/sqlite: insert event: %w", err)
 	}
@@ -619,7 +684,7 @@ func eventWhere(f store.EventFilter) ([]string, []any) {
 const eventColumns = `seq, session_id, kind, timestamp, principal, protocol,
 	connection, operation, statement, tables, allowed, rule, message, direction,
 	masked_entities, masked_count, error, duration_ns, statement_count,
-	denied_count, http, metadata`
+	denied_count, http, metadata, chain_seq, prev_hash, hash, checkpoint`
 
 // Events lists events oldest first, paging on seq ascending so a timeline
 // reads top to bottom and a concurrent insert appends beyond the window
@@ -674,7 +739,7 @@ func scanEvent(sc scanner) (store.EventRecord, error) {
 	var (
 		rec                                store.EventRecord
 		sid, kind, proto, op, dir          string
-		ts, durationNS                     int64
+		ts, durationNS, chainSeq           int64
 		allowed                            int
 		tablesJSON, entitiesJSON           string
 		httpJSON, metaJSON                 string
@@ -683,7 +748,8 @@ func scanEvent(sc scanner) (store.EventRecord, error) {
 	err := sc.Scan(&rec.Seq, &sid, &kind, &ts, &rec.Principal, &proto,
 		&rec.Connection, &op, &rec.Statement, &tablesJSON, &allowed, &rec.Rule,
 		&rec.Message, &dir, &entitiesJSON, &maskC, &rec.Error, &durationNS,
-		&statementCount, &deniedCount, &httpJSON, &metaJSON)
+		&statementCount, &deniedCount, &httpJSON, &metaJSON,
+		&chainSeq, &rec.PrevHash, &rec.Hash, &rec.Checkpoint)
 	if err != nil {
 		return store.EventRecord{}, fmt.Errorf("hoopinspect/store/sqlite: scan event: %w", err)
 	}
@@ -699,6 +765,7 @@ func scanEvent(sc scanner) (store.EventRecord, error) {
 	rec.Duration = time.Duration(durationNS)
 	rec.StatementCount = statementCount
 	rec.DeniedCount = deniedCount
+	rec.ChainSeq = uint64(chainSeq)
 
 	if err := decodeJSON(tablesJSON, &rec.Tables); err != nil {
 		return store.EventRecord{}, err
@@ -2,6 +2,8 @@ package sqlite_test
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"path/filepath"
@@ -1086,8 +1088,8 @@ func TestSchemaVersionRecordedAndGuarded(t *testing.T) {
 	if err := s.DB().QueryRowContext(ctx, `SELECT MAX(version) FROM schema_version`).Scan(&v); err != nil {
 		t.Fatalf("schema_version table missing, so a future migration has nowhere to stand: %v", err)
 	}
-	if v != 1 {
-		t.Errorf("schema_version = %d, want 1", v)
+	if v != 2 {
+		t.Errorf("schema_version = %d, want 2", v)
 	}
 
 	// Simulate a newer binary having written this file. Refusing to open
@@ -1156,3 +1158,103 @@ func equalIDs(a, b []session.ID) bool {
 	}
 	return true
 }
+
+// A file from before the hash chain gains its columns on open, and its old
+// rows read as history from before the chain.
+func TestSchemaMigratesVersion1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v1.db")
+	ctx := context.Background()
+
+	s, err := sqlitestore.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, s, audit.Event{Kind: audit.KindStatement, SessionID: "s1", Timestamp: base, Statement: "SELECT 1"})
+	for _, stmt := range []string{
+		`ALTER TABLE events DROP COLUMN chain_seq`,
+		`ALTER TABLE events DROP COLUMN prev_hash`,
+		`ALTER TABLE events DROP COLUMN hash`,
+		`ALTER TABLE events DROP COLUMN checkpoint`,
+		`DELETE FROM schema_version`,
+		`INSERT INTO schema_version(version) VALUES (1)`,
+	} {
+		if _, err := s.DB().ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	s.Close()
+
+	s, err = sqlitestore.Open(path)
+	if err != nil {
+		t.Fatalf("opening a version 1 file: %v", err)
+	}
+	defer s.Close()
+	if err := s.SetChain(ctx, audit.NewChain(audit.ChainOptions{})); err != nil {
+		t.Fatal(err)
+	}
+	write(t, s, audit.Event{Kind: audit.KindStatement, SessionID: "s1", Timestamp: base.Add(time.Second), Statement: "SELECT 2"})
+	rep, err := s.VerifyChain(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.OK() || rep.Unchained != 1 || rep.Last != 1 {
+		t.Errorf("report = %+v, want the old row unchained and the new one at seq 1", rep)
+	}
+}
+
+// The store seals what it writes, resumes across a reopen, and VerifyChain
+// finds a row edited behind its back.
+func TestChainedStoreVerifies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.db")
+	ctx := context.Background()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	open := func() *sqlitestore.Store {
+		s, err := sqlitestore.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetChain(ctx, audit.NewChain(audit.ChainOptions{CheckpointEvery: 3, SigningKey: priv})); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	s := open()
+	for i := range 4 {
+		write(t, s, audit.Event{
+			Kind: audit.KindStatement, SessionID: "s1", Timestamp: base.Add(time.Duration(i) * time.Nanosecond),
+			Principal: "alice", Statement: "SELECT 1", Tables: []string{"t"},
+			Metadata: map[string]string{"k": "v"}, HTTP: &hoopinspect.HTTPDetail{Method: "GET"},
+		})
+	}
+	s.Close()
+	s = open()
+	defer s.Close()
+	write(t, s, audit.Event{Kind: audit.KindSessionEnd, SessionID: "s1", Timestamp: base.Add(time.Second)})
+
+	rep, err := s.VerifyChain(ctx, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.OK() || rep.Last != 5 || rep.Checkpoints != 1 || rep.Signed != 3 {
+		t.Fatalf("report = %+v, want seq 1-5 with a checkpoint at 3", rep)
+	}
+
+	if _, err := s.DB().ExecContext(ctx, `UPDATE events SET principal = 'mallory' WHERE chain_seq = 2`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB().ExecContext(ctx, `DELETE FROM events WHERE chain_seq = 4`); err != nil {
+		t.Fatal(err)
+	}
+	rep, err = s.VerifyChain(ctx, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, p := range rep.Problems {
+		kinds = append(kinds, p.Kind)
+	}
+	if strings.Join(kinds, ",") != audit.ProblemModified+","+audit.ProblemGap {
+		t.Errorf("problems = %v, want the edit and the deletion", rep.Problems)
+	}
+}
@@ -1,10 +1,13 @@
 import { useState } from 'react'
 import { Group, Stack, Text } from '@mantine/core'
-import { ShieldCheck } from 'lucide-react'
+import { ShieldAlert, ShieldCheck } from 'lucide-react'
 import Alert from '@/components/Alert'
 import Button from '@/components/Button'
 import Modal from '@/components/Modal'
 import Select from '@/components/Select'
+import Switch from '@/components/Switch'
+import Textarea from '@/components/Textarea'
+import TextInput from '@/components/TextInput'
 import { useNativeAccessStore, FLOW_STATUS } from '@/stores/useNativeAccessStore'
 import {
   ACCESS_DURATION_OPTIONS,
@@ -15,6 +18,9 @@ import { formatDurationSec } from './helpers'
 
 function RequestAccessForm({ connectionName, connection, onCancel }) {
   const [duration, setDuration] = useState(DEFAULT_ACCESS_DURATION_MINUTES)
+  const [breakGlass, setBreakGlass] = useState(false)
+  const [justification, setJustification] = useState('')
+  const [incident, setIncident] = useState('')
   const requestAccess = useNativeAccessStore((s) => s.requestAccess)
   const status = useNativeAccessStore((s) => s.statusByName[connectionName])
   const isRequesting = status === FLOW_STATUS.REQUESTING
@@ -23,7 +29,17 @@ function RequestAccessForm({ connectionName, connection, onCancel }) {
 
   // Pass the JIT window through in seconds. The CLJS version divided by 60 and
   // the API multiplied it back, losing any window that was not a whole minute.
-  const submit = () => requestAccess(connectionName, jitSec ?? Number(duration) * 60)
+  // Break-glass skips the review, so its window is picked here and bounded by
+  // the rule's break-glass max duration rather than the fixed JIT window.
+  const submit = () =>
+    breakGlass
+      ? requestAccess(connectionName, Number(duration) * 60, {
+          justification: justification.trim(),
+          incident_reference: incident.trim(),
+        })
+      : requestAccess(connectionName, jitSec ?? Number(duration) * 60)
+  const canSubmit = !breakGlass || (justification.trim() && incident.trim())
+  const showDuration = !jitSec || breakGlass
 
   return (
     <Stack gap="lg">
@@ -35,11 +51,18 @@ function RequestAccessForm({ connectionName, connection, onCancel }) {
 
       {/* sky, not blue: `blue` is not defined in theme.js and falls back to
           Mantine's stock palette, outside the product's identity. */}
-      {jitSec ? (
+      {breakGlass ? (
+        <Alert color="red" icon={<ShieldAlert size={16} />}>
+          Break-glass grants access right away, without waiting for a review. Reviewers are
+          notified and must acknowledge it afterwards, with the justification and incident below.
+        </Alert>
+      ) : jitSec ? (
         <Alert color="sky" icon={<ShieldCheck size={16} />}>
           {`This resource role has a fixed just-in-time window of ${formatDurationSec(jitSec)}. A reviewer must approve the request before the credentials are issued.`}
         </Alert>
-      ) : (
+      ) : null}
+
+      {showDuration && (
         <Stack gap="xs">
           {/* The dropdown is portalled and Mantine gives a combobox z-index
               300, so inside this 400 modal it opened BEHIND the dialog. */}
@@ -58,12 +81,43 @@ function RequestAccessForm({ connectionName, connection, onCancel }) {
         </Stack>
       )}
 
+      <Switch
+        label="Break glass for an incident"
+        checked={breakGlass}
+        onChange={(e) => setBreakGlass(e.currentTarget.checked)}
+        disabled={isRequesting}
+      />
+      {breakGlass && (
+        <Stack gap="xs">
+          <Textarea
+            label="Justification"
+            placeholder="Why this access can't wait for a review"
+            value={justification}
+            onChange={(e) => setJustification(e.currentTarget.value)}
+            maxLength={2000}
+            required
+          />
+          <TextInput
+            label="Incident reference"
+            placeholder="e.g. INC-1234"
+            value={incident}
+            onChange={(e) => setIncident(e.currentTarget.value)}
+            maxLength={255}
+            required
+          />
+        </Stack>
+      )}
+
       <Group justify="flex-end" gap="sm">
         <Button variant="default" onClick={onCancel} disabled={isRequesting}>
           Cancel
         </Button>
-        <Button onClick={submit} loading={isRequesting}>
-          {jitSec ? 'Request access' : 'Confirm and connect'}
+        <Button onClick={submit} loading={isRequesting} disabled={!canSubmit}>
+          {breakGlass
+            ? 'Break glass and connect'
+            : jitSec
+              ? 'Request access'
+              : 'Confirm and connect'}
         </Button>
       </Group>
     </Stack>
@@ -19,12 +19,13 @@ export const connectionCredentialsService = {
 
   // Omitting accessDurationSec issues a persistent credential (expire_at null).
   // Review-required connections reject that and need an explicit window.
-  create: (connectionName, accessDurationSec) =>
+  // breakGlass ({ justification, incident_reference }) skips their review.
+  create: (connectionName, accessDurationSec, breakGlass) =>
     api
-      .post(
-        `/connections/${connectionName}/credentials`,
-        accessDurationSec ? { access_duration_seconds: accessDurationSec } : {}
-      )
+      .post(`/connections/${connectionName}/credentials`, {
+        ...(accessDurationSec ? { access_duration_seconds: accessDurationSec } : {}),
+        ...(breakGlass ? { break_glass: breakGlass } : {}),
+      })
       .then((res) => ({ status: res.status, data: res.data })),
 
   // Issues the credential for an approved review. Still answers 202 while the
@@ -316,8 +316,9 @@ export const useNativeAccessStore = create((set, get) => ({
    *
    * `accessDurationSec` is null for a persistent credential. Review-required
    * connections must pass a real window — the gateway 400s without one.
+   * `breakGlass` issues the credentials of a review-required connection at once.
    */
-  requestAccess: async (connectionName, accessDurationSec) => {
+  requestAccess: async (connectionName, accessDurationSec, breakGlass = null) => {
     set((s) => ({
       statusByName: setIn(s.statusByName, connectionName, FLOW_STATUS.REQUESTING),
       errorByName: removeFrom(s.errorByName, connectionName),
@@ -326,7 +327,8 @@ export const useNativeAccessStore = create((set, get) => ({
     try {
       const { status, data } = await connectionCredentialsService.create(
         connectionName,
-        accessDurationSec
+        accessDurationSec,
+        breakGlass
       )
 
       if (status === 202 || data.has_review) {